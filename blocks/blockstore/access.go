@@ -0,0 +1,235 @@
+package blockstore
+
+import (
+	"context"
+	"encoding/binary"
+	"sync"
+	"time"
+
+	blocks "github.com/ipfs/go-ipfs/blocks"
+
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+)
+
+// AccessPrefix namespaces the access records of blocks in the datastore.
+var AccessPrefix = ds.NewKey("/local/access")
+
+// AccessFlushInterval is how often the access records kept in memory are
+// written to the datastore.
+var AccessFlushInterval = time.Minute
+
+// Access records when a block was last read or written, and how many
+// times it was.
+type Access struct {
+	Last  time.Time
+	Count uint32
+	Size  uint32
+}
+
+func (a Access) bytes() []byte {
+	b := make([]byte, 16)
+	binary.BigEndian.PutUint64(b, uint64(a.Last.UnixNano()))
+	binary.BigEndian.PutUint32(b[8:], a.Count)
+	binary.BigEndian.PutUint32(b[12:], a.Size)
+	return b
+}
+
+func accessFromBytes(b []byte) (Access, bool) {
+	if len(b) != 16 {
+		return Access{}, false
+	}
+	return Access{
+		Last:  time.Unix(0, int64(binary.BigEndian.Uint64(b))),
+		Count: binary.BigEndian.Uint32(b[8:]),
+		Size:  binary.BigEndian.Uint32(b[12:]),
+	}, true
+}
+
+// AccessBlockstore records the accesses to the blocks of a blockstore, for
+// eviction policies to pick the blocks to remove. The accesses are counted in
+// memory and added to the records of the datastore every AccessFlushInterval,
+// so that a busy node neither reads nor writes the datastore for every block
+// it reads.
+type AccessBlockstore struct {
+	Blockstore
+	d ds.Datastore
+
+	// flushLk serializes the flushes with the reads and deletions of the
+	// records, which then never see the ones being written
+	flushLk sync.Mutex
+
+	lk sync.Mutex
+	// dirty holds the accesses since the last flush: their count, and the
+	// time and size of the last one
+	dirty map[string]Access
+
+	// now is overridden in tests.
+	now func() time.Time
+}
+
+// NewAccessBlockstore wraps bs, recording the accesses in d until ctx is
+// done.
+func NewAccessBlockstore(ctx context.Context, bs Blockstore, d ds.Datastore) *AccessBlockstore {
+	a := &AccessBlockstore{
+		Blockstore: bs,
+		d:          d,
+		dirty:      make(map[string]Access),
+		now:        time.Now,
+	}
+	go a.flushLoop(ctx)
+	return a
+}
+
+func (a *AccessBlockstore) flushLoop(ctx context.Context) {
+	t := time.NewTicker(AccessFlushInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			if err := a.Flush(); err != nil {
+				log.Warningf("failed to record block accesses: %s", err)
+			}
+			return
+		}
+		if err := a.Flush(); err != nil {
+			log.Warningf("failed to record block accesses: %s", err)
+		}
+	}
+}
+
+func accessKey(c *cid.Cid) ds.Key {
+	return AccessPrefix.ChildString(c.String())
+}
+
+func (a *AccessBlockstore) touch(c *cid.Cid, size int) {
+	a.lk.Lock()
+	defer a.lk.Unlock()
+
+	k := c.KeyString()
+	acc := a.dirty[k]
+	acc.Last = a.now()
+	acc.Count++
+	acc.Size = uint32(size)
+	a.dirty[k] = acc
+}
+
+// merge adds the accesses since the last flush to the record acc.
+func merge(acc, since Access) Access {
+	acc.Last = since.Last
+	acc.Count += since.Count
+	acc.Size = since.Size
+	return acc
+}
+
+func (a *AccessBlockstore) stored(c *cid.Cid) (Access, bool) {
+	v, err := a.d.Get(accessKey(c))
+	if err != nil {
+		return Access{}, false
+	}
+	b, ok := v.([]byte)
+	if !ok {
+		return Access{}, false
+	}
+	return accessFromBytes(b)
+}
+
+// Access returns the access record of c, if it has one.
+func (a *AccessBlockstore) Access(c *cid.Cid) (Access, bool) {
+	a.flushLk.Lock()
+	defer a.flushLk.Unlock()
+
+	acc, ok := a.stored(c)
+
+	a.lk.Lock()
+	defer a.lk.Unlock()
+	if since, found := a.dirty[c.KeyString()]; found {
+		return merge(acc, since), true
+	}
+	return acc, ok
+}
+
+// Flush adds the accesses counted in memory to the records of the datastore.
+func (a *AccessBlockstore) Flush() error {
+	a.flushLk.Lock()
+	defer a.flushLk.Unlock()
+
+	a.lk.Lock()
+	dirty := a.dirty
+	a.dirty = make(map[string]Access)
+	a.lk.Unlock()
+
+	if len(dirty) == 0 {
+		return nil
+	}
+
+	var put func(ds.Key, interface{}) error
+	var commit func() error
+	if bd, ok := a.d.(ds.Batching); ok {
+		b, err := bd.Batch()
+		if err != nil {
+			return err
+		}
+		put, commit = b.Put, b.Commit
+	} else {
+		put, commit = a.d.Put, func() error { return nil }
+	}
+
+	for k, since := range dirty {
+		c, err := cid.Cast([]byte(k))
+		if err != nil {
+			continue
+		}
+		acc, _ := a.stored(c)
+		if err := put(accessKey(c), merge(acc, since).bytes()); err != nil {
+			return err
+		}
+	}
+	return commit()
+}
+
+func (a *AccessBlockstore) Get(c *cid.Cid) (blocks.Block, error) {
+	b, err := a.Blockstore.Get(c)
+	if err == nil {
+		a.touch(c, len(b.RawData()))
+	}
+	return b, err
+}
+
+func (a *AccessBlockstore) Put(b blocks.Block) error {
+	if err := a.Blockstore.Put(b); err != nil {
+		return err
+	}
+	a.touch(b.Cid(), len(b.RawData()))
+	return nil
+}
+
+func (a *AccessBlockstore) PutMany(bs []blocks.Block) error {
+	if err := a.Blockstore.PutMany(bs); err != nil {
+		return err
+	}
+	for _, b := range bs {
+		a.touch(b.Cid(), len(b.RawData()))
+	}
+	return nil
+}
+
+func (a *AccessBlockstore) DeleteBlock(c *cid.Cid) error {
+	if err := a.Blockstore.DeleteBlock(c); err != nil {
+		return err
+	}
+
+	a.flushLk.Lock()
+	defer a.flushLk.Unlock()
+
+	a.lk.Lock()
+	delete(a.dirty, c.KeyString())
+	a.lk.Unlock()
+
+	err := a.d.Delete(accessKey(c))
+	if err != nil && err != ds.ErrNotFound {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package blockstore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	blocks "github.com/ipfs/go-ipfs/blocks"
+
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+	syncds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore/sync"
+)
+
+func TestAccessRecords(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	d := syncds.MutexWrap(ds.NewMapDatastore())
+	a := NewAccessBlockstore(ctx, NewBlockstore(d), d)
+	now := time.Unix(1000, 0)
+	a.now = func() time.Time { return now }
+
+	b := blocks.NewBlock([]byte("accessed"))
+	if err := a.Put(b); err != nil {
+		t.Fatal(err)
+	}
+
+	now = now.Add(time.Hour)
+	if _, err := a.Get(b.Cid()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Has(b.Cid()); err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(when string) {
+		acc, ok := a.Access(b.Cid())
+		if !ok {
+			t.Fatalf("%s: no access record", when)
+		}
+		if !acc.Last.Equal(now) || acc.Count != 2 || acc.Size != uint32(len(b.RawData())) {
+			t.Fatalf("%s: unexpected access record %+v", when, acc)
+		}
+	}
+	check("before flush")
+
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if len(a.dirty) != 0 {
+		t.Fatal("expected the records to be flushed")
+	}
+	check("after flush")
+
+	// the accesses after a flush add up with the record
+	if _, err := a.Get(b.Cid()); err != nil {
+		t.Fatal(err)
+	}
+	if acc, ok := a.Access(b.Cid()); !ok || acc.Count != 3 {
+		t.Fatalf("expected the access to add up with the record, got %+v", acc)
+	}
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if acc, ok := a.Access(b.Cid()); !ok || acc.Count != 3 {
+		t.Fatalf("expected the access to be added to the record, got %+v", acc)
+	}
+
+	// a new blockstore over the same datastore sees the records
+	a2 := NewAccessBlockstore(ctx, NewBlockstore(d), d)
+	if acc, ok := a2.Access(b.Cid()); !ok || acc.Count != 3 {
+		t.Fatalf("expected the record to be persisted, got %+v", acc)
+	}
+
+	if err := a.DeleteBlock(b.Cid()); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := a.Access(b.Cid()); ok {
+		t.Fatal("expected the record to be deleted with the block")
+	}
+}
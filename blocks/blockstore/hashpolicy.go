@@ -0,0 +1,84 @@
+package blockstore
+
+import (
+	"fmt"
+
+	blocks "github.com/ipfs/go-ipfs/blocks"
+
+	mh "gx/ipfs/QmVGtdTZdTFaLsaj2RwdVG8jcjNNcp1DE914DKZ2kHmXHw/go-multihash"
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+)
+
+// ErrHashDenied is returned for the blocks whose hash function is denied by
+// a HashPolicy.
+type ErrHashDenied struct {
+	Cid *cid.Cid
+}
+
+func (e ErrHashDenied) Error() string {
+	return fmt.Sprintf("the hash function of %s, %s, is denied", e.Cid, mh.Codes[e.Cid.Prefix().MhType])
+}
+
+// HashPolicy denies hash functions, such as the deprecated ones.
+type HashPolicy struct {
+	denied map[uint64]bool
+}
+
+// NewHashPolicy returns the policy denying the hash functions named, as in
+// multihash, such as "sha1".
+func NewHashPolicy(names []string) (*HashPolicy, error) {
+	p := &HashPolicy{denied: make(map[uint64]bool)}
+	for _, name := range names {
+		code, ok := mh.Names[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown hash function %q", name)
+		}
+		p.denied[code] = true
+	}
+	return p, nil
+}
+
+// Check returns an ErrHashDenied if the hash function of c is denied.
+func (p *HashPolicy) Check(c *cid.Cid) error {
+	if p.denied[c.Prefix().MhType] {
+		return ErrHashDenied{c}
+	}
+	return nil
+}
+
+// hashPolicyBlockstore refuses to store and to return the blocks whose hash
+// function is denied.
+type hashPolicyBlockstore struct {
+	Blockstore
+	policy *HashPolicy
+}
+
+// NewHashPolicyBlockstore wraps bs, refusing to store and to return the
+// blocks whose hash function policy denies. The blocks already stored can
+// still be listed and removed.
+func NewHashPolicyBlockstore(bs Blockstore, policy *HashPolicy) Blockstore {
+	return &hashPolicyBlockstore{Blockstore: bs, policy: policy}
+}
+
+func (bs *hashPolicyBlockstore) Get(c *cid.Cid) (blocks.Block, error) {
+	if err := bs.policy.Check(c); err != nil {
+		return nil, err
+	}
+	return bs.Blockstore.Get(c)
+}
+
+func (bs *hashPolicyBlockstore) Put(b blocks.Block) error {
+	if err := bs.policy.Check(b.Cid()); err != nil {
+		return err
+	}
+	return bs.Blockstore.Put(b)
+}
+
+func (bs *hashPolicyBlockstore) PutMany(bl []blocks.Block) error {
+	for _, b := range bl {
+		if err := bs.policy.Check(b.Cid()); err != nil {
+			return err
+		}
+	}
+	return bs.Blockstore.PutMany(bl)
+}
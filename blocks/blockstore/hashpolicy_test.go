@@ -0,0 +1,62 @@
+package blockstore
+
+import (
+	"testing"
+
+	blocks "github.com/ipfs/go-ipfs/blocks"
+
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+	syncds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore/sync"
+	mh "gx/ipfs/QmVGtdTZdTFaLsaj2RwdVG8jcjNNcp1DE914DKZ2kHmXHw/go-multihash"
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+)
+
+func TestHashPolicy(t *testing.T) {
+	if _, err := NewHashPolicy([]string{"sha0"}); err == nil {
+		t.Fatal("expected an unknown hash function to fail")
+	}
+	policy, err := NewHashPolicy([]string{"sha1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	base := NewBlockstore(syncds.MutexWrap(ds.NewMapDatastore()))
+	bs := NewHashPolicyBlockstore(base, policy)
+
+	pref := cid.Prefix{Version: 1, Codec: cid.Raw, MhType: mh.SHA1, MhLength: -1}
+	c, err := pref.Sum([]byte("sha1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	denied, err := blocks.NewBlockWithCid([]byte("sha1"), c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	allowed := blocks.NewBlock([]byte("sha2"))
+
+	if err := bs.Put(denied); err == nil {
+		t.Fatal("expected a sha1 block to be refused")
+	}
+	if err := bs.PutMany([]blocks.Block{allowed, denied}); err == nil {
+		t.Fatal("expected a batch with a sha1 block to be refused")
+	}
+	if err := bs.Put(allowed); err != nil {
+		t.Fatal(err)
+	}
+
+	// stored before the policy
+	if err := base.Put(denied); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bs.Get(denied.Cid()); err == nil {
+		t.Fatal("expected a sha1 block not to be returned")
+	} else if _, ok := err.(ErrHashDenied); !ok {
+		t.Fatalf("expected ErrHashDenied, got %s", err)
+	}
+	if _, err := bs.Get(allowed.Cid()); err != nil {
+		t.Fatal(err)
+	}
+	if err := bs.DeleteBlock(denied.Cid()); err != nil {
+		t.Fatal(err)
+	}
+}
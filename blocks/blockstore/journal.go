@@ -0,0 +1,324 @@
+package blockstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	blocks "github.com/ipfs/go-ipfs/blocks"
+
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+	dsq "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore/query"
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+)
+
+// JournalPrefix namespaces the entries of the block journal in the
+// datastore.
+var JournalPrefix = ds.NewKey("/local/journal")
+
+// DefaultJournalRetention is how long journal entries are kept by default.
+const DefaultJournalRetention = 30 * 24 * time.Hour
+
+// JournalPruneInterval is how often the entries past the retention are
+// removed.
+var JournalPruneInterval = time.Hour
+
+// Operations of journal entries
+const (
+	JournalPut    = "put"
+	JournalDelete = "delete"
+)
+
+// JournalLocal is the source of the writes made through the blockstore
+// itself, rather than a view of Source.
+const JournalLocal = "local"
+
+// JournalEntry records the addition or the removal of a block, and the
+// operation of the node it came from. Seq numbers the entries in order, from
+// 1.
+type JournalEntry struct {
+	Seq    uint64
+	Op     string
+	Cid    *cid.Cid
+	Size   int `json:",omitempty"` // of added blocks
+	Time   time.Time
+	Source string
+}
+
+type journalEntriesBySeq []JournalEntry
+
+func (s journalEntriesBySeq) Len() int           { return len(s) }
+func (s journalEntriesBySeq) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s journalEntriesBySeq) Less(i, j int) bool { return s[i].Seq < s[j].Seq }
+
+func journalKey(seq uint64) ds.Key {
+	// zero padded so that keys sort by sequence
+	return JournalPrefix.ChildString(fmt.Sprintf("%020d", seq))
+}
+
+// JournalBlockstore records the blocks added to and removed from a
+// blockstore in a journal, so that the blocks added since an entry are found
+// without listing the whole blockstore. Blocks put again while stored aren't
+// recorded twice. The entries older than the retention are pruned, but for
+// the last one, which numbers the next.
+type JournalBlockstore struct {
+	Blockstore
+	d         ds.Datastore
+	retention time.Duration
+
+	lk     sync.Mutex
+	seq    uint64 // of the last entry
+	pruned uint64 // of the last entry pruned
+
+	// now is overridden in tests.
+	now func() time.Time
+}
+
+// NewJournalBlockstore wraps bs, recording the writes in d after the entries
+// already there. Until ctx is done, the entries older than retention are
+// pruned every JournalPruneInterval; a retention of 0 keeps them all.
+func NewJournalBlockstore(ctx context.Context, bs Blockstore, d ds.Datastore, retention time.Duration) (*JournalBlockstore, error) {
+	j := &JournalBlockstore{
+		Blockstore: bs,
+		d:          d,
+		retention:  retention,
+		now:        time.Now,
+	}
+
+	res, err := d.Query(dsq.Query{
+		Prefix:   JournalPrefix.String(),
+		KeysOnly: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+	var first uint64
+	for {
+		e, ok := res.NextSync()
+		if !ok {
+			break
+		}
+		if e.Error != nil {
+			return nil, e.Error
+		}
+		seq, err := strconv.ParseUint(ds.RawKey(e.Key).BaseNamespace(), 10, 64)
+		if err != nil {
+			log.Warningf("invalid block journal key %s", e.Key)
+			continue
+		}
+		if seq > j.seq {
+			j.seq = seq
+		}
+		if first == 0 || seq < first {
+			first = seq
+		}
+	}
+	if first > 0 {
+		j.pruned = first - 1
+	}
+
+	if retention > 0 {
+		go j.pruneLoop(ctx)
+	}
+	return j, nil
+}
+
+func (j *JournalBlockstore) pruneLoop(ctx context.Context) {
+	t := time.NewTicker(JournalPruneInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return
+		}
+		if _, err := j.Prune(j.now().Add(-j.retention)); err != nil {
+			log.Warningf("failed to prune the block journal: %s", err)
+		}
+	}
+}
+
+// Prune removes the entries journaled before t, but for the last one, and
+// returns how many it removed.
+func (j *JournalBlockstore) Prune(t time.Time) (int, error) {
+	entries, err := j.Entries(j.Pruned())
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for i, e := range entries {
+		if i == len(entries)-1 || !e.Time.Before(t) {
+			break
+		}
+		if err := j.d.Delete(journalKey(e.Seq)); err != nil && err != ds.ErrNotFound {
+			return removed, err
+		}
+		removed++
+
+		j.lk.Lock()
+		j.pruned = e.Seq
+		j.lk.Unlock()
+	}
+	return removed, nil
+}
+
+// Seq returns the number of the last entry, 0 while the journal is empty.
+func (j *JournalBlockstore) Seq() uint64 {
+	j.lk.Lock()
+	defer j.lk.Unlock()
+	return j.seq
+}
+
+// Pruned returns the number of the last entry pruned, 0 if none was. The
+// entries up to it are gone.
+func (j *JournalBlockstore) Pruned() uint64 {
+	j.lk.Lock()
+	defer j.lk.Unlock()
+	return j.pruned
+}
+
+// Entries returns the entries after since, in order.
+func (j *JournalBlockstore) Entries(since uint64) ([]JournalEntry, error) {
+	res, err := j.d.Query(dsq.Query{Prefix: JournalPrefix.String()})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	var entries []JournalEntry
+	for {
+		e, ok := res.NextSync()
+		if !ok {
+			break
+		}
+		if e.Error != nil {
+			return nil, e.Error
+		}
+		b, ok := e.Value.([]byte)
+		if !ok {
+			continue
+		}
+		var entry JournalEntry
+		if err := json.Unmarshal(b, &entry); err != nil {
+			log.Warningf("invalid block journal entry %s: %s", e.Key, err)
+			continue
+		}
+		if entry.Seq > since {
+			entries = append(entries, entry)
+		}
+	}
+	sort.Sort(journalEntriesBySeq(entries))
+	return entries, nil
+}
+
+// record appends entries to the journal, numbering them.
+func (j *JournalBlockstore) record(entries []JournalEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	j.lk.Lock()
+	defer j.lk.Unlock()
+
+	var put func(ds.Key, interface{}) error
+	var commit func() error
+	if bd, ok := j.d.(ds.Batching); ok {
+		b, err := bd.Batch()
+		if err != nil {
+			return err
+		}
+		put, commit = b.Put, b.Commit
+	} else {
+		put, commit = j.d.Put, func() error { return nil }
+	}
+
+	now := j.now()
+	seq := j.seq
+	for _, e := range entries {
+		seq++
+		e.Seq = seq
+		e.Time = now
+		v, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		if err := put(journalKey(seq), v); err != nil {
+			return err
+		}
+	}
+	if err := commit(); err != nil {
+		return err
+	}
+	j.seq = seq
+	return nil
+}
+
+func (j *JournalBlockstore) putMany(bs []blocks.Block, source string) error {
+	var added []JournalEntry
+	for _, b := range bs {
+		if has, _ := j.Blockstore.Has(b.Cid()); !has {
+			added = append(added, JournalEntry{
+				Op:     JournalPut,
+				Cid:    b.Cid(),
+				Size:   len(b.RawData()),
+				Source: source,
+			})
+		}
+	}
+	if err := j.Blockstore.PutMany(bs); err != nil {
+		return err
+	}
+	return j.record(added)
+}
+
+func (j *JournalBlockstore) deleteBlock(c *cid.Cid, source string) error {
+	if err := j.Blockstore.DeleteBlock(c); err != nil {
+		return err
+	}
+	return j.record([]JournalEntry{{
+		Op:     JournalDelete,
+		Cid:    c,
+		Source: source,
+	}})
+}
+
+func (j *JournalBlockstore) Put(b blocks.Block) error {
+	return j.putMany([]blocks.Block{b}, JournalLocal)
+}
+
+func (j *JournalBlockstore) PutMany(bs []blocks.Block) error {
+	return j.putMany(bs, JournalLocal)
+}
+
+func (j *JournalBlockstore) DeleteBlock(c *cid.Cid) error {
+	return j.deleteBlock(c, JournalLocal)
+}
+
+// Source returns a view of the blockstore whose writes are journaled as
+// coming from source, such as "bitswap" or "gc".
+func (j *JournalBlockstore) Source(source string) Blockstore {
+	return &journalSource{j, source}
+}
+
+type journalSource struct {
+	*JournalBlockstore
+	source string
+}
+
+func (s *journalSource) Put(b blocks.Block) error {
+	return s.putMany([]blocks.Block{b}, s.source)
+}
+
+func (s *journalSource) PutMany(bs []blocks.Block) error {
+	return s.putMany(bs, s.source)
+}
+
+func (s *journalSource) DeleteBlock(c *cid.Cid) error {
+	return s.deleteBlock(c, s.source)
+}
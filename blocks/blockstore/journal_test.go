@@ -0,0 +1,119 @@
+package blockstore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	blocks "github.com/ipfs/go-ipfs/blocks"
+
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+	syncds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore/sync"
+)
+
+func TestJournal(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	d := syncds.MutexWrap(ds.NewMapDatastore())
+	j, err := NewJournalBlockstore(ctx, NewBlockstore(d), d, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b1 := blocks.NewBlock([]byte("first"))
+	b2 := blocks.NewBlock([]byte("second"))
+	b3 := blocks.NewBlock([]byte("third"))
+	if err := j.Put(b1); err != nil {
+		t.Fatal(err)
+	}
+	// b1 is already stored, and not journaled again
+	if err := j.PutMany([]blocks.Block{b1, b2, b3}); err != nil {
+		t.Fatal(err)
+	}
+	if j.Seq() != 3 {
+		t.Fatalf("expected 3 entries, got %d", j.Seq())
+	}
+
+	entries, err := j.Entries(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries after the first, got %d", len(entries))
+	}
+	for i, b := range []blocks.Block{b2, b3} {
+		e := entries[i]
+		if e.Seq != uint64(i+2) || e.Op != JournalPut || e.Source != JournalLocal ||
+			!e.Cid.Equals(b.Cid()) || e.Size != len(b.RawData()) {
+			t.Fatalf("unexpected entry %+v", e)
+		}
+	}
+
+	if err := j.Source("gc").DeleteBlock(b2.Cid()); err != nil {
+		t.Fatal(err)
+	}
+	entries, err = j.Entries(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Op != JournalDelete || entries[0].Source != "gc" || !entries[0].Cid.Equals(b2.Cid()) {
+		t.Fatalf("expected the removal to be journaled, got %+v", entries)
+	}
+
+	// a new blockstore over the same datastore goes on after the entries
+	j2, err := NewJournalBlockstore(ctx, NewBlockstore(d), d, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j2.Seq() != 4 {
+		t.Fatalf("expected the journal to go on after 4, got %d", j2.Seq())
+	}
+}
+
+func TestJournalPrune(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	d := syncds.MutexWrap(ds.NewMapDatastore())
+	j, err := NewJournalBlockstore(ctx, NewBlockstore(d), d, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1000, 0)
+	j.now = func() time.Time { return now }
+
+	for i, data := range []string{"old", "older", "recent"} {
+		if i == 2 {
+			now = now.Add(time.Hour)
+		}
+		if err := j.Put(blocks.NewBlock([]byte(data))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removed, err := j.Prune(now.Add(-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 2 || j.Pruned() != 2 {
+		t.Fatalf("expected the 2 old entries to be pruned, got %d, up to %d", removed, j.Pruned())
+	}
+
+	// the last entry is kept, for the journal to go on after it
+	removed, err = j.Prune(now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 0 {
+		t.Fatalf("expected the last entry to be kept, got %d removed", removed)
+	}
+
+	j2, err := NewJournalBlockstore(ctx, NewBlockstore(d), d, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j2.Seq() != 3 || j2.Pruned() != 2 {
+		t.Fatalf("expected entries 3 to 3, got %d to %d", j2.Pruned()+1, j2.Seq())
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"os"
 	"sort"
 	"sync"
+	"time"
 
 	cmds "github.com/ipfs/go-ipfs/commands"
 	"github.com/ipfs/go-ipfs/core"
@@ -17,7 +18,9 @@ import (
 	corehttp "github.com/ipfs/go-ipfs/core/corehttp"
 	corerepo "github.com/ipfs/go-ipfs/core/corerepo"
 	"github.com/ipfs/go-ipfs/core/corerouting"
+	memtune "github.com/ipfs/go-ipfs/core/memtune"
 	nodeMount "github.com/ipfs/go-ipfs/fuse/node"
+	keystore "github.com/ipfs/go-ipfs/keystore"
 	fsrepo "github.com/ipfs/go-ipfs/repo/fsrepo"
 	migrate "github.com/ipfs/go-ipfs/repo/fsrepo/migrations"
 
@@ -47,6 +50,7 @@ const (
 	unrestrictedApiAccessKwd  = "unrestricted-api"
 	writableKwd               = "writable"
 	enableFloodSubKwd         = "enable-pubsub-experiment"
+	enableIPNSPubSubKwd       = "enable-namesys-pubsub"
 	enableMultiplexKwd        = "enable-mplex-experiment"
 	// apiAddrKwd    = "address-api"
 	// swarmAddrKwd  = "address-swarm"
@@ -132,6 +136,14 @@ be enabled by running the daemon as:
 This will later be transitioned into a config option once it gets out of the
 'experimental' stage.
 
+IPNS over pubsub
+
+With --enable-namesys-pubsub, the IPNS records the node publishes are also
+broadcast on a pubsub topic per name, and the names it resolves are
+subscribed to, so that it gets their new records as soon as they are
+published. The first resolution of a name still goes through the DHT. This
+is experimental, and enables pubsub.
+
 DEPRECATION NOTICE
 
 Previously, ipfs used an environment variable as seen below:
@@ -158,6 +170,7 @@ Headers.
 		cmds.BoolOption(offlineKwd, "Run offline. Do not connect to the rest of the network but provide local API.").Default(false),
 		cmds.BoolOption(migrateKwd, "If true, assume yes at the migrate prompt. If false, assume no."),
 		cmds.BoolOption(enableFloodSubKwd, "Instantiate the ipfs daemon with the experimental pubsub feature enabled."),
+		cmds.BoolOption(enableIPNSPubSubKwd, "Enable IPNS record distribution through pubsub; enables pubsub."),
 		cmds.BoolOption(enableMultiplexKwd, "Add the experimental 'go-multiplex' stream muxer to libp2p on construction.").Default(true),
 		// TODO: add way to override addresses. tricky part: updating the config if also --init.
 		// cmds.StringOption(apiAddrKwd, "Address for the daemon rpc API (overrides config)"),
@@ -275,14 +288,51 @@ func daemonFunc(req cmds.Request, res cmds.Response) {
 		break
 	}
 
+	// the passphrase of an encrypted keystore is asked for on start, while
+	// there is a terminal to type it on
+	if ks, ok := repo.Keystore().(*keystore.EncryptedKeystore); ok {
+		if err := ks.Unlock(); err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+	}
+
+	// the identity can't be trusted while its rotation is under way
+	if err := corerepo.CheckIdentityRotation(repo); err != nil {
+		res.SetError(err, cmds.ErrNormal)
+		return
+	}
+
+	// a daemon which did not shut down cleanly may have left the repo in a
+	// state the node can't be built on
+	report, err := corerepo.Recover(repo, ctx.ConfigRoot)
+	if err != nil {
+		res.SetError(fmt.Errorf("recovering from an unclean shutdown: %s", err), cmds.ErrNormal)
+		return
+	}
+	if report != nil {
+		fmt.Println("Recovered from an unclean shutdown of the daemon:")
+		for _, line := range report.Summary() {
+			fmt.Printf("  %s\n", line)
+			log.Warning("recovery: ", line)
+		}
+		fmt.Println("See 'ipfs repo recovery-report' for details.")
+	}
+
 	cfg, err := ctx.GetConfig()
 	if err != nil {
 		res.SetError(err, cmds.ErrNormal)
 		return
 	}
 
+	if err := memtune.Apply(cfg.Memory); err != nil {
+		res.SetError(err, cmds.ErrNormal)
+		return
+	}
+
 	offline, _, _ := req.Option(offlineKwd).Bool()
 	pubsub, _, _ := req.Option(enableFloodSubKwd).Bool()
+	ipnsps, _, _ := req.Option(enableIPNSPubSubKwd).Bool()
 	mplex, _, _ := req.Option(enableMultiplexKwd).Bool()
 
 	// Start assembling node config
@@ -292,18 +342,27 @@ func daemonFunc(req cmds.Request, res cmds.Response) {
 		Online:    !offline,
 		ExtraOpts: map[string]bool{
 			"pubsub": pubsub,
+			"ipnsps": ipnsps,
 			"mplex":  mplex,
 		},
 		//TODO(Kubuxu): refactor Online vs Offline by adding Permanent vs Ephemeral
 	}
 
-	routingOption, _, err := req.Option(routingOptionKwd).String()
+	routingOption, routingGiven, err := req.Option(routingOptionKwd).String()
 	if err != nil {
 		res.SetError(err, cmds.ErrNormal)
 		return
 	}
-	switch routingOption {
-	case routingOptionSupernodeKwd:
+	switch {
+	case !routingGiven && len(cfg.Routing.Routers) > 0:
+		// the router stack of the config, unless overridden
+		ncfg.Routing, err = corerouting.Stack(cfg.Routing, ctx.ConfigRoot)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			repo.Close() // because ownership hasn't been transferred to the node
+			return
+		}
+	case routingOption == routingOptionSupernodeKwd:
 		servers, err := cfg.SupernodeRouting.ServerIPFSAddrs()
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
@@ -319,11 +378,11 @@ func daemonFunc(req cmds.Request, res cmds.Response) {
 		}
 
 		ncfg.Routing = corerouting.SupernodeClient(infos...)
-	case routingOptionDHTClientKwd:
+	case routingOption == routingOptionDHTClientKwd:
 		ncfg.Routing = core.DHTClientOption
-	case routingOptionDHTKwd:
+	case routingOption == routingOptionDHTKwd:
 		ncfg.Routing = core.DHTOption
-	case routingOptionNoneKwd:
+	case routingOption == routingOptionNoneKwd:
 		ncfg.Routing = core.NilRouterOption
 	default:
 		res.SetError(fmt.Errorf("unrecognized routing option: %s", routingOption), cmds.ErrNormal)
@@ -345,7 +404,15 @@ func daemonFunc(req cmds.Request, res cmds.Response) {
 
 	printSwarmAddrs(node)
 
+	if err := corerepo.MarkDaemonRunning(repo); err != nil {
+		log.Error("error recording the daemon run: ", err)
+	}
+
 	defer func() {
+		if err := corerepo.MarkDaemonStopped(repo); err != nil {
+			log.Error("error recording the daemon shutdown: ", err)
+		}
+
 		// We wait for the node to close first, as the node has children
 		// that it will wait for before closing, such as the API server.
 		node.Close()
@@ -360,6 +427,9 @@ func daemonFunc(req cmds.Request, res cmds.Response) {
 	ctx.ConstructNode = func() (*core.IpfsNode, error) {
 		return node, nil
 	}
+	ctx.OpenKeystore = func(string) (keystore.Keystore, error) {
+		return node.Repo.Keystore(), nil
+	}
 
 	// construct api endpoint - every time
 	err, apiErrc := serveHTTPApi(req)
@@ -393,6 +463,38 @@ func daemonFunc(req cmds.Request, res cmds.Response) {
 		return
 	}
 
+	// eviction of unpinned blocks - if Datastore.Eviction.Policy is set
+	var evictErrc <-chan error
+	if node.Accesses != nil {
+		e, err := corerepo.NewEvictor(node)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		errc := make(chan error)
+		go func() {
+			errc <- e.Run(req.Context())
+			close(errc)
+		}()
+		evictErrc = errc
+	}
+
+	// verification of the blocks - if Datastore.Scrub.DailyFraction is set
+	var scrubErrc <-chan error
+	if cfg.Datastore.Scrub.DailyFraction != 0 {
+		s, err := corerepo.NewScrubber(node)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		errc := make(chan error)
+		go func() {
+			errc <- s.Run(req.Context())
+			close(errc)
+		}()
+		scrubErrc = errc
+	}
+
 	// construct http gateway - if it is set in the config
 	var gwErrc <-chan error
 	if len(cfg.Addresses.Gateway) > 0 {
@@ -404,13 +506,33 @@ func daemonFunc(req cmds.Request, res cmds.Response) {
 		}
 	}
 
+	// pins interrupted by the shutdown of the last daemon - if online
+	if !offline {
+		go func() {
+			if err := corerepo.ResumePins(req.Context(), node); err != nil {
+				log.Error("error resuming the interrupted pins: ", err)
+			}
+		}()
+	}
+
+	// paths followed by 'ipfs pin add --follow'
+	followInterval := corerepo.DefaultFollowInterval
+	if cfg.Pinning.FollowInterval != "" {
+		followInterval, err = time.ParseDuration(cfg.Pinning.FollowInterval)
+		if err != nil {
+			res.SetError(fmt.Errorf("invalid Pinning.FollowInterval: %s", err), cmds.ErrNormal)
+			return
+		}
+	}
+	go corerepo.FollowPins(req.Context(), node, followInterval)
+
 	// initialize metrics collector
 	prometheus.MustRegister(&corehttp.IpfsNodeCollector{Node: node})
 
 	fmt.Printf("Daemon is ready\n")
 	// collect long-running errors and block for shutdown
 	// TODO(cryptix): our fuse currently doesnt follow this pattern for graceful shutdown
-	for err := range merge(apiErrc, gwErrc, gcErrc) {
+	for err := range merge(apiErrc, gwErrc, gcErrc, evictErrc, scrubErrc) {
 		if err != nil {
 			log.Error(err)
 			res.SetError(err, cmds.ErrNormal)
@@ -460,14 +582,21 @@ func serveHTTPApi(req cmds.Request) (error, <-chan error) {
 
 	var opts = []corehttp.ServeOption{
 		corehttp.MetricsCollectionOption("api"),
+		corehttp.LoadSheddingOption("api"),
+		corehttp.IdempotencyOption(),
+		corehttp.CommandLimitsOption(),
+		corehttp.PluginOption(corehttp.PluginServerAPI),
 		corehttp.CommandsOption(*req.InvocContext()),
+		corehttp.UploadOption(*req.InvocContext()),
 		corehttp.WebUIOption,
+		corehttp.WebUIAPIOption(cfg.API.WebUIWritable),
 		gatewayOpt,
 		corehttp.VersionOption(),
 		defaultMux("/debug/vars"),
 		defaultMux("/debug/pprof/"),
 		corehttp.MetricsScrapingOption("/debug/metrics/prometheus"),
 		corehttp.LogOption(),
+		corehttp.EventsOption(),
 	}
 
 	if len(cfg.Gateway.RootRedirect) > 0 {
@@ -543,6 +672,8 @@ func serveHTTPGateway(req cmds.Request) (error, <-chan error) {
 
 	var opts = []corehttp.ServeOption{
 		corehttp.MetricsCollectionOption("gateway"),
+		corehttp.LoadSheddingOption("gateway"),
+		corehttp.PluginOption(corehttp.PluginServerGateway),
 		corehttp.CommandsROOption(*req.InvocContext()),
 		corehttp.VersionOption(),
 		corehttp.IPNSHostnameOption(),
@@ -12,6 +12,7 @@ import (
 	assets "github.com/ipfs/go-ipfs/assets"
 	cmds "github.com/ipfs/go-ipfs/commands"
 	core "github.com/ipfs/go-ipfs/core"
+	keystore "github.com/ipfs/go-ipfs/keystore"
 	namesys "github.com/ipfs/go-ipfs/namesys"
 	config "github.com/ipfs/go-ipfs/repo/config"
 	fsrepo "github.com/ipfs/go-ipfs/repo/fsrepo"
@@ -32,6 +33,13 @@ located at ~/.ipfs. To change the repo location, set the $IPFS_PATH
 environment variable:
 
     export IPFS_PATH=/path/to/ipfsrepo
+
+With --new-mnemonic, the keypair is an ed25519 key derived from a new BIP 39
+mnemonic, which is printed: written down, it recovers the identity of the
+node with --mnemonic, and the keys derived from it with
+'ipfs key gen --derive-from-mnemonic'.
+
+    ipfs init --mnemonic="$(cat phrase)"
 `,
 	},
 	Arguments: []cmds.Argument{
@@ -40,6 +48,8 @@ environment variable:
 	Options: []cmds.Option{
 		cmds.IntOption("bits", "b", "Number of bits to use in the generated RSA private key.").Default(nBitsForKeypairDefault),
 		cmds.BoolOption("empty-repo", "e", "Don't add and pin help files to the local storage.").Default(false),
+		cmds.StringOption("mnemonic", "Derive the key of the node from this BIP 39 mnemonic."),
+		cmds.BoolOption("new-mnemonic", "Derive the key of the node from a new BIP 39 mnemonic, and print it.").Default(false),
 
 		// TODO need to decide whether to expose the override as a file or a
 		// directory. That is: should we allow the user to also specify the
@@ -96,6 +106,48 @@ environment variable:
 			}
 		}
 
+		mnemonic, _, err := req.Option("mnemonic").String()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		newMnemonic, _, err := req.Option("new-mnemonic").Bool()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		if mnemonic != "" && newMnemonic {
+			res.SetError(errors.New("--mnemonic and --new-mnemonic can't be given together"), cmds.ErrClient)
+			return
+		}
+
+		if newMnemonic {
+			mnemonic, err = keystore.NewMnemonic()
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+			fmt.Printf("the mnemonic of the node, write it down to recover its keys:\n  %s\n", mnemonic)
+		}
+
+		if mnemonic != "" {
+			sk, err := keystore.KeyFromMnemonic(mnemonic, keystore.IdentityDerivationPath)
+			if err != nil {
+				res.SetError(err, cmds.ErrClient)
+				return
+			}
+
+			if conf == nil {
+				conf, err = config.InitWithKey(os.Stdout, sk)
+			} else {
+				conf.Identity, err = config.IdentityFromKey(sk)
+			}
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+		}
+
 		if err := doInit(os.Stdout, req.InvocContext().ConfigRoot, empty, nBitsForKeypair, conf); err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
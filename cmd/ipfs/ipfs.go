@@ -5,6 +5,7 @@ import (
 
 	cmds "github.com/ipfs/go-ipfs/commands"
 	commands "github.com/ipfs/go-ipfs/core/commands"
+	files "github.com/ipfs/go-ipfs/core/commands/files"
 )
 
 // This is the CLI root, used for executing commands accessible to CLI clients.
@@ -93,12 +94,25 @@ var cmdDetailsMap = map[*cmds.Command]cmdDetails{
 
 	// daemonCmd allows user to initialize the config. Thus, it may be called
 	// without using the config as input
-	daemonCmd:                             {doesNotUseConfigAsInput: true, cannotRunOnDaemon: true},
-	commandsClientCmd:                     {doesNotUseRepo: true},
-	commands.CommandsDaemonCmd:            {doesNotUseRepo: true},
-	commands.VersionCmd:                   {doesNotUseConfigAsInput: true, doesNotUseRepo: true}, // must be permitted to run before init
-	commands.LogCmd:                       {cannotRunOnClient: true},
-	commands.ActiveReqsCmd:                {cannotRunOnClient: true},
-	commands.RepoFsckCmd:                  {cannotRunOnDaemon: true},
-	commands.ConfigCmd.Subcommand("edit"): {cannotRunOnDaemon: true, doesNotUseRepo: true},
+	daemonCmd:                                 {doesNotUseConfigAsInput: true, cannotRunOnDaemon: true},
+	commandsClientCmd:                         {doesNotUseRepo: true},
+	commands.CommandsDaemonCmd:                {doesNotUseRepo: true},
+	commands.VersionCmd:                       {doesNotUseConfigAsInput: true, doesNotUseRepo: true}, // must be permitted to run before init
+	commands.LogCmd:                           {cannotRunOnClient: true},
+	commands.ActiveReqsCmd:                    {cannotRunOnClient: true},
+	commands.RepoFsckCmd:                      {cannotRunOnDaemon: true},
+	commands.RepoReshardCmd:                   {cannotRunOnDaemon: true},
+	commands.UpdateCmd.Subcommand("apply"):    {cannotRunOnDaemon: true},
+	commands.UpdateCmd.Subcommand("rollback"): {doesNotUseRepo: true},
+	files.FilesBeginCmd:                       {cannotRunOnClient: true},
+	files.FilesCommitCmd:                      {cannotRunOnClient: true},
+	commands.ConfigCmd.Subcommand("edit"):     {cannotRunOnDaemon: true, doesNotUseRepo: true},
+	commands.ConfigCmd.Subcommand("show"):     {doesNotUseRepo: true},
+	commands.KeyCmd.Subcommand("encrypt"):     {cannotRunOnDaemon: true},
+	commands.KeyCmd.Subcommand("rotate"):      {cannotRunOnDaemon: true},
+	commands.PinCmd.Subcommand("jobs"):        {cannotRunOnClient: true},
+
+	// unlike the version, the releases are fetched by the daemon, with its
+	// network
+	commands.VersionCmd.Subcommand("check"): {},
 }
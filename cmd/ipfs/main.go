@@ -22,6 +22,7 @@ import (
 	cmdsHttp "github.com/ipfs/go-ipfs/commands/http"
 	core "github.com/ipfs/go-ipfs/core"
 	coreCmds "github.com/ipfs/go-ipfs/core/commands"
+	keystore "github.com/ipfs/go-ipfs/keystore"
 	repo "github.com/ipfs/go-ipfs/repo"
 	config "github.com/ipfs/go-ipfs/repo/config"
 	fsrepo "github.com/ipfs/go-ipfs/repo/fsrepo"
@@ -41,6 +42,7 @@ var errRequestCanceled = errors.New("request canceled")
 
 const (
 	EnvEnableProfiling = "IPFS_PROF"
+	EnvApiAuth         = "IPFS_API_AUTH"
 	cpuProfile         = "ipfs.cpuprof"
 	heapProfile        = "ipfs.memprof"
 )
@@ -50,6 +52,8 @@ type cmdInvocation struct {
 	cmd  *cmds.Command
 	req  cmds.Request
 	node *core.IpfsNode
+	// keystore is the keystore opened without the node, if any
+	keystore keystore.Keystore
 }
 
 // main roadmap:
@@ -64,6 +68,8 @@ func main() {
 
 func mainRet() int {
 	rand.Seed(time.Now().UnixNano())
+	fsrepo.KeystorePassphrase = promptPassphrase
+	coreCmds.KeyPassphrase = promptKeyPassphrase
 	ctx := logging.ContextWithLoggable(context.Background(), loggables.Uuid("session"))
 	var err error
 	var invoc cmdInvocation
@@ -230,6 +236,17 @@ func (i *cmdInvocation) constructNodeFunc(ctx context.Context) func() (*core.Ipf
 	}
 }
 
+// openKeystore opens the keystore of the repo alone, for the commands which
+// need no node.
+func (i *cmdInvocation) openKeystore(repoPath string) (keystore.Keystore, error) {
+	ks, err := fsrepo.OpenKeystore(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	i.keystore = ks
+	return ks, nil
+}
+
 func (i *cmdInvocation) close() {
 	// let's not forget teardown. If a node was initialized, we must close it.
 	// Note that this means the underlying req.Context().Node variable is exposed.
@@ -238,6 +255,13 @@ func (i *cmdInvocation) close() {
 		log.Info("Shutting down node...")
 		i.node.Close()
 	}
+
+	// a token keystore logs out of the token
+	if c, ok := i.keystore.(io.Closer); ok {
+		if err := c.Close(); err != nil {
+			log.Warning("error closing the keystore: ", err)
+		}
+	}
 }
 
 func (i *cmdInvocation) Parse(ctx context.Context, args []string) error {
@@ -261,6 +285,8 @@ func (i *cmdInvocation) Parse(ctx context.Context, args []string) error {
 	// this sets up the function that will initialize the node
 	// this is so that we can construct the node lazily.
 	cmdctx.ConstructNode = i.constructNodeFunc(ctx)
+	// the key commands only open the keystore
+	cmdctx.OpenKeystore = i.openKeystore
 
 	// if no encoding was specified by user, default to plaintext encoding
 	// (if command doesn't support plaintext, use JSON instead)
@@ -395,20 +421,36 @@ func commandShouldRunOnDaemon(details cmdDetails, req cmds.Request, root *cmds.C
 		return nil, fmt.Errorf("command disabled: %s", path[0])
 	}
 
-	if details.doesNotUseRepo && details.canRunOnClient() {
+	// did user specify an api to use for this command? the daemon takes it
+	// for the address to serve the api on instead.
+	apiAddrStr, _, err := req.Option(coreCmds.ApiOption).String()
+	if err != nil {
+		return nil, err
+	}
+	remoteApi := apiAddrStr != "" && req.Command() != daemonCmd
+
+	if details.doesNotUseRepo && details.canRunOnClient() && !remoteApi {
 		return nil, nil
 	}
 
+	// running it locally instead would act on the local repo, not on the
+	// node the user asked for.
+	if remoteApi && details.cannotRunOnDaemon {
+		return nil, cmds.ClientError(fmt.Sprintf("'ipfs %s' cannot run on an api given with --api", strings.Join(path, " ")))
+	}
+
 	// at this point need to know whether api is running. we defer
 	// to this point so that we dont check unnecessarily
 
-	// did user specify an api to use for this command?
-	apiAddrStr, _, err := req.Option(coreCmds.ApiOption).String()
+	apiAuth, _, err := req.Option(coreCmds.ApiAuthOption).String()
 	if err != nil {
 		return nil, err
 	}
+	if apiAuth == "" {
+		apiAuth = os.Getenv(EnvApiAuth)
+	}
 
-	client, err := getApiClient(req.InvocContext().ConfigRoot, apiAddrStr)
+	client, err := getApiClient(req.InvocContext().ConfigRoot, apiAddrStr, apiAuth)
 	if err == repo.ErrApiNotRunning {
 		if apiAddrStr != "" && req.Command() != daemonCmd {
 			// if user SPECIFIED an api, and this cmd is not daemon
@@ -599,7 +641,9 @@ var checkIPFSWinFmt = "Otherwise check:\n\ttasklist | findstr ipfs"
 // getApiClient checks the repo, and the given options, checking for
 // a running API service. if there is one, it returns a client.
 // otherwise, it returns errApiNotRunning, or another error.
-func getApiClient(repoPath, apiAddrStr string) (cmdsHttp.Client, error) {
+// apiAddrStr is a multiaddr, an http or https URL, or the name of a remote in
+// the config.
+func getApiClient(repoPath, apiAddrStr, apiAuth string) (cmdsHttp.Client, error) {
 	var apiErrorFmt string
 	switch {
 	case osh.IsUnix():
@@ -610,6 +654,28 @@ func getApiClient(repoPath, apiAddrStr string) (cmdsHttp.Client, error) {
 		apiErrorFmt = apiFileErrorFmt
 	}
 
+	if len(apiAddrStr) != 0 && !strings.HasPrefix(apiAddrStr, "/") && !isAPIURL(apiAddrStr) {
+		rem, err := getRemote(repoPath, apiAddrStr)
+		if err != nil {
+			return nil, err
+		}
+		apiAddrStr = rem.API
+		if apiAuth == "" {
+			apiAuth = rem.Auth
+		}
+	}
+
+	if isAPIURL(apiAddrStr) {
+		u, err := url.Parse(apiAddrStr)
+		if err != nil {
+			return nil, err
+		}
+		if u.Host == "" {
+			return nil, fmt.Errorf("API URL %s has no host", apiAddrStr)
+		}
+		return cmdsHttp.NewClientWithAuth(u.Scheme, u.Host, apiAuth)
+	}
+
 	var addr ma.Multiaddr
 	var err error
 	if len(apiAddrStr) != 0 {
@@ -633,18 +699,40 @@ func getApiClient(repoPath, apiAddrStr string) (cmdsHttp.Client, error) {
 	if len(addr.Protocols()) == 0 {
 		return nil, fmt.Errorf(apiErrorFmt, repoPath, "multiaddr doesn't provide any protocols")
 	}
-	return apiClientForAddr(addr)
+	return apiClientForAddr(addr, apiAuth)
 }
 
-func apiClientForAddr(addr ma.Multiaddr) (cmdsHttp.Client, error) {
+// getRemote returns the remote called name in the config of the repo.
+func getRemote(repoPath, name string) (config.Remote, error) {
+	cfg, err := loadConfig(repoPath)
+	if err != nil {
+		return config.Remote{}, fmt.Errorf("cannot look up remote %q: %s", name, err)
+	}
+	rem, ok := cfg.Remotes[name]
+	if !ok {
+		return config.Remote{}, fmt.Errorf("no remote named %q, see 'ipfs remote ls'", name)
+	}
+	return rem, nil
+}
+
+func apiClientForAddr(addr ma.Multiaddr, auth string) (cmdsHttp.Client, error) {
 	_, host, err := manet.DialArgs(addr)
 	if err != nil {
 		return nil, err
 	}
 
+	if auth != "" {
+		return cmdsHttp.NewClientWithAuth("http", host, auth)
+	}
 	return cmdsHttp.NewClient(host), nil
 }
 
+// isAPIURL tells whether the API given is an http or https URL rather than a
+// multiaddr.
+func isAPIURL(api string) bool {
+	return strings.HasPrefix(api, "http://") || strings.HasPrefix(api, "https://")
+}
+
 func isConnRefused(err error) bool {
 	// unwrap url errors from http calls
 	if urlerr, ok := err.(*url.Error); ok {
@@ -15,3 +15,21 @@ func TestIsCientErr(t *testing.T) {
 		t.Errorf("misidentified pointer")
 	}
 }
+
+func TestVersionCheckRunsOnDaemon(t *testing.T) {
+	details, err := commandDetails([]string{"version"}, Root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !details.doesNotUseRepo {
+		t.Error("expected 'version' to run without the repo")
+	}
+
+	details, err = commandDetails([]string{"version", "check"}, Root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if details.doesNotUseRepo || details.cannotRunOnDaemon {
+		t.Errorf("expected 'version check' to run on the daemon, got %+v", details)
+	}
+}
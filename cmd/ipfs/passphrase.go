@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	coreCmds "github.com/ipfs/go-ipfs/core/commands"
+	fsrepo "github.com/ipfs/go-ipfs/repo/fsrepo"
+)
+
+// promptPassphrase returns the passphrase of the keystore set in the
+// environment or, if there is none, the one typed on the terminal.
+func promptPassphrase(confirm bool) ([]byte, error) {
+	if os.Getenv(fsrepo.EnvKeystorePassphrase) != "" || !isTerminal(os.Stdin) {
+		return fsrepo.EnvPassphrase(confirm)
+	}
+
+	r := bufio.NewReader(os.Stdin)
+	p, err := readPassphrase(r, "Enter the keystore passphrase: ")
+	if err != nil || !confirm {
+		return p, err
+	}
+	p2, err := readPassphrase(r, "Enter it again: ")
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(p, p2) {
+		return nil, errors.New("the passphrases don't match")
+	}
+	return p, nil
+}
+
+// promptKeyPassphrase returns the passphrase of a key file set in the
+// environment or, if there is none, the one typed on the terminal.
+func promptKeyPassphrase(file string, confirm bool) ([]byte, error) {
+	if os.Getenv(coreCmds.EnvKeyPassphrase) != "" || !isTerminal(os.Stdin) {
+		return coreCmds.KeyPassphraseFromEnv(file, confirm)
+	}
+
+	r := bufio.NewReader(os.Stdin)
+	p, err := readPassphrase(r, fmt.Sprintf("Enter the passphrase of %s: ", file))
+	if err != nil || !confirm {
+		return p, err
+	}
+	p2, err := readPassphrase(r, "Enter it again: ")
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(p, p2) {
+		return nil, errors.New("the passphrases don't match")
+	}
+	return p, nil
+}
+
+func readPassphrase(r *bufio.Reader, prompt string) ([]byte, error) {
+	fmt.Fprint(os.Stderr, prompt)
+
+	// the passphrase is not echoed while it is typed
+	if err := stty(os.Stdin, "-echo"); err != nil {
+		return nil, err
+	}
+	defer func() {
+		stty(os.Stdin, "echo")
+		fmt.Fprintln(os.Stderr)
+	}()
+
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	return []byte(strings.TrimRight(line, "\r\n")), nil
+}
+
+// isTerminal tells whether f is a terminal, which stty can only query then.
+func isTerminal(f *os.File) bool {
+	return stty(f, "-g") == nil
+}
+
+func stty(f *os.File, arg string) error {
+	cmd := exec.Command("stty", arg)
+	cmd.Stdin = f
+	return cmd.Run()
+}
@@ -10,10 +10,11 @@ const (
 type Argument struct {
 	Name          string
 	Type          ArgumentType
-	Required      bool // error if no value is specified
-	Variadic      bool // unlimited values can be specfied
-	SupportsStdin bool // can accept stdin as a value
-	Recursive     bool // supports recursive file adding (with '-r' flag)
+	Required      bool   // error if no value is specified
+	Variadic      bool   // unlimited values can be specfied
+	SupportsStdin bool   // can accept stdin as a value
+	Recursive     bool   // supports recursive file adding (with '-r' flag)
+	OptionalWith  string // option which makes a required argument optional
 	Description   string
 }
 
@@ -46,6 +47,13 @@ func (a Argument) EnableStdin() Argument {
 	return a
 }
 
+// MakeOptionalWith makes the argument optional when the option named option
+// is given, as when the option provides the value in its place.
+func (a Argument) MakeOptionalWith(option string) Argument {
+	a.OptionalWith = option
+	return a
+}
+
 func (a Argument) EnableRecursive() Argument {
 	if a.Type != ArgFile {
 		panic("Only FileArgs can enable recursive")
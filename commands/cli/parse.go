@@ -47,7 +47,7 @@ func Parse(input []string, stdin *os.File, root *cmds.Command) (cmds.Request, *c
 		}
 	}
 
-	stringArgs, fileArgs, err := ParseArgs(req, stringVals, stdin, cmd.Arguments, root)
+	stringArgs, fileArgs, err := ParseArgs(req, stringVals, stdin, cmd.ArgumentsWith(opts), root)
 	if err != nil {
 		return req, cmd, path, err
 	}
@@ -86,7 +86,17 @@ func ParseArgs(req cmds.Request, inputs []string, stdin *os.File, argDefs []cmds
 			return nil, nil, u.ErrCast()
 		}
 	}
-	return parseArgs(inputs, stdin, argDefs, recursive, hidden, root)
+
+	// if '--follow-symlinks' is provided, add the files symlinks point to
+	followOpt := req.Option("follow-symlinks")
+	follow := false
+	if followOpt != nil {
+		follow, _, err = followOpt.Bool()
+		if err != nil {
+			return nil, nil, u.ErrCast()
+		}
+	}
+	return parseArgs(inputs, stdin, argDefs, recursive, hidden, follow, root)
 }
 
 // Parse a command line made up of sub-commands, short arguments, long arguments and positional arguments
@@ -236,8 +246,8 @@ func parseOpts(args []string, root *cmds.Command) (
 				}
 
 				// If we've come across an external binary call, pass all the remaining
-				// arguments on to it
-				if cmd.External {
+				// arguments on to it, unless they name one of its builtin subcommands
+				if cmd.External && (i+1 >= len(args) || cmd.Subcommand(args[i+1]) == nil) {
 					stringVals = append(stringVals, args[i+1:]...)
 					return
 				}
@@ -256,7 +266,7 @@ func parseOpts(args []string, root *cmds.Command) (
 
 const msgStdinInfo = "ipfs: Reading from %s; send Ctrl-d to stop."
 
-func parseArgs(inputs []string, stdin *os.File, argDefs []cmds.Argument, recursive, hidden bool, root *cmds.Command) ([]string, []files.File, error) {
+func parseArgs(inputs []string, stdin *os.File, argDefs []cmds.Argument, recursive, hidden, follow bool, root *cmds.Command) ([]string, []files.File, error) {
 	// ignore stdin on Windows
 	if osh.IsWindows() {
 		stdin = nil
@@ -329,7 +339,7 @@ func parseArgs(inputs []string, stdin *os.File, argDefs []cmds.Argument, recursi
 					fpath = stdin.Name()
 					file = files.NewReaderFile("", fpath, r, nil)
 				} else {
-					nf, err := appendFile(fpath, argDef, recursive, hidden)
+					nf, err := appendFile(fpath, argDef, recursive, hidden, follow)
 					if err != nil {
 						return nil, nil, err
 					}
@@ -338,8 +348,10 @@ func parseArgs(inputs []string, stdin *os.File, argDefs []cmds.Argument, recursi
 				}
 
 				fileArgs[fpath] = file
-			} else if stdin != nil && argDef.SupportsStdin &&
-				argDef.Required && !fillingVariadic {
+			} else if stdin != nil && argDef.SupportsStdin && !fillingVariadic &&
+				(argDef.Required || !isTerminal(stdin)) {
+				// optional files are only read from stdin when it is
+				// piped or redirected, rather than waited for
 				r, err := maybeWrapStdin(stdin, msgStdinInfo)
 				if err != nil {
 					return nil, nil, err
@@ -399,7 +411,7 @@ const notRecursiveFmtStr = "'%s' is a directory, use the '-%s' flag to specify d
 const dirNotSupportedFmtStr = "Invalid path '%s', argument '%s' does not support directories"
 const winDriveLetterFmtStr = "%q is a drive letter, not a drive path"
 
-func appendFile(fpath string, argDef *cmds.Argument, recursive, hidden bool) (files.File, error) {
+func appendFile(fpath string, argDef *cmds.Argument, recursive, hidden, follow bool) (files.File, error) {
 	// resolve Windows relative dot paths like `X:.\somepath`
 	if osh.IsWindows() {
 		if len(fpath) >= 3 && fpath[1:3] == ":." {
@@ -429,6 +441,16 @@ func appendFile(fpath string, argDef *cmds.Argument, recursive, hidden bool) (fi
 	if err != nil {
 		return nil, err
 	}
+	newFile := files.NewSerialFile
+	if follow {
+		newFile = files.NewSerialFileFollowingLinks
+		if stat.Mode()&os.ModeSymlink != 0 {
+			// the argument is checked as what it points to
+			if stat, err = os.Stat(fpath); err != nil {
+				return nil, err
+			}
+		}
+	}
 
 	if stat.IsDir() {
 		if !argDef.Recursive {
@@ -440,10 +462,10 @@ func appendFile(fpath string, argDef *cmds.Argument, recursive, hidden bool) (fi
 	}
 
 	if osh.IsWindows() {
-		return windowsParseFile(fpath, hidden, stat)
+		return windowsParseFile(fpath, hidden, stat, newFile)
 	}
 
-	return files.NewSerialFile(path.Base(fpath), fpath, hidden, stat)
+	return newFile(path.Base(fpath), fpath, hidden, stat)
 }
 
 // Inform the user if a file is waiting on input
@@ -460,6 +482,13 @@ func maybeWrapStdin(f *os.File, msg string) (io.ReadCloser, error) {
 	return f, nil
 }
 
+// isTerminal tells whether f is a terminal, or another character device
+// such as /dev/null.
+func isTerminal(f *os.File) bool {
+	isTty, err := isTty(f)
+	return err != nil || isTty
+}
+
 func isTty(f *os.File) (bool, error) {
 	fInfo, err := f.Stat()
 	if err != nil {
@@ -496,7 +525,7 @@ func (r *messageReader) Close() error {
 	return r.r.Close()
 }
 
-func windowsParseFile(fpath string, hidden bool, stat os.FileInfo) (files.File, error) {
+func windowsParseFile(fpath string, hidden bool, stat os.FileInfo, newFile func(string, string, bool, os.FileInfo) (files.File, error)) (files.File, error) {
 	// special cases for Windows drive roots i.e. `X:\` and their long form `\\?\X:\`
 	// drive path must be preserved as `X:\` (or it's longform) and not converted to `X:`, `X:.`, `\`, or `/` here
 	switch len(fpath) {
@@ -507,7 +536,7 @@ func windowsParseFile(fpath string, hidden bool, stat os.FileInfo) (files.File,
 		}
 		// `X:\` needs to preserve the `\`, path.Base(filepath.ToSlash(fpath)) results in `X:` which is not valid
 		if fpath[1:3] == ":\\" {
-			return files.NewSerialFile(fpath, fpath, hidden, stat)
+			return newFile(fpath, fpath, hidden, stat)
 		}
 	case 6:
 		// `\\?\X:` long prefix form of `X:`, still ambiguous
@@ -518,9 +547,9 @@ func windowsParseFile(fpath string, hidden bool, stat os.FileInfo) (files.File,
 		// `\\?\X:\` long prefix form is translated into short form `X:\`
 		if fpath[:4] == "\\\\?\\" && fpath[5] == ':' && fpath[6] == '\\' {
 			fpath = string(fpath[4]) + ":\\"
-			return files.NewSerialFile(fpath, fpath, hidden, stat)
+			return newFile(fpath, fpath, hidden, stat)
 		}
 	}
 
-	return files.NewSerialFile(path.Base(filepath.ToSlash(fpath)), fpath, hidden, stat)
+	return newFile(path.Base(filepath.ToSlash(fpath)), fpath, hidden, stat)
 }
@@ -177,6 +177,14 @@ func TestArgumentParsing(t *testing.T) {
 					commands.StringArg("b", true, false, "another arg"),
 				},
 			},
+			"optionalwith": {
+				Options: []commands.Option{
+					commands.StringOption("instead", "a value in place of the arg"),
+				},
+				Arguments: []commands.Argument{
+					commands.StringArg("a", true, false, "some arg").MakeOptionalWith("instead"),
+				},
+			},
 			"stdinenabled": {
 				Arguments: []commands.Argument{
 					commands.StringArg("a", true, true, "some arg").EnableStdin(),
@@ -253,6 +261,10 @@ func TestArgumentParsing(t *testing.T) {
 	testFail([]string{"reversedoptional"}, nil, "didn't provide any args, 1 required")
 	testFail([]string{"reversedoptional", "value1", "value2", "value3"}, nil, "provided too many args, only takes 1")
 
+	test([]string{"optionalwith", "value!"}, nil, []string{"value!"})
+	test([]string{"optionalwith", "--instead=value!"}, nil, []string{})
+	testFail([]string{"optionalwith"}, nil, "didn't provide any args nor the option, arg is required")
+
 	// Use a temp file to simulate stdin
 	fileToSimulateStdin := func(t *testing.T, content string) *os.File {
 		fstdin, err := ioutil.TempFile("", "")
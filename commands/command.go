@@ -212,10 +212,11 @@ func (c *Command) GetOptions(path []string) (map[string]Option, error) {
 
 func (c *Command) CheckArguments(req Request) error {
 	args := req.(*request).arguments
+	argDefs := c.ArgumentsWith(req.Options())
 
 	// count required argument definitions
 	numRequired := 0
-	for _, argDef := range c.Arguments {
+	for _, argDef := range argDefs {
 		if argDef.Required {
 			numRequired++
 		}
@@ -223,7 +224,7 @@ func (c *Command) CheckArguments(req Request) error {
 
 	// iterate over the arg definitions
 	valueIndex := 0 // the index of the current value (in `args`)
-	for i, argDef := range c.Arguments {
+	for i, argDef := range argDefs {
 		// skip optional argument definitions if there aren't
 		// sufficient remaining values
 		if len(args)-valueIndex <= numRequired && !argDef.Required ||
@@ -264,6 +265,20 @@ func (c *Command) CheckArguments(req Request) error {
 	return nil
 }
 
+// ArgumentsWith returns the argument definitions of the command, with the
+// required arguments made optional by the options of opts, given by their
+// names.
+func (c *Command) ArgumentsWith(opts OptMap) []Argument {
+	argDefs := make([]Argument, len(c.Arguments))
+	for i, argDef := range c.Arguments {
+		if argDef.Required && argDef.OptionalWith != "" && opts[argDef.OptionalWith] != nil {
+			argDef.Required = false
+		}
+		argDefs[i] = argDef
+	}
+	return argDefs
+}
+
 // Subcommand returns the subcommand with the given id
 func (c *Command) Subcommand(id string) *Command {
 	return c.Subcommands[id]
@@ -20,9 +20,34 @@ type serialFile struct {
 	stat              os.FileInfo
 	current           *File
 	handleHiddenFiles bool
+
+	// followLinks replaces the symlinks by the files they point to, and
+	// ancestors are the real paths of the directories above, so that links
+	// to them don't loop.
+	followLinks bool
+	ancestors   []string
 }
 
+// NewSerialFile returns the file at path, named name, with the symlinks in
+// it stored as links.
 func NewSerialFile(name, path string, hidden bool, stat os.FileInfo) (File, error) {
+	return newSerialFile(name, path, hidden, false, nil, stat)
+}
+
+// NewSerialFileFollowingLinks returns the file at path, named name, with the
+// symlinks in it replaced by the files they point to.
+func NewSerialFileFollowingLinks(name, path string, hidden bool, stat os.FileInfo) (File, error) {
+	return newSerialFile(name, path, hidden, true, nil, stat)
+}
+
+func newSerialFile(name, path string, hidden, follow bool, ancestors []string, stat os.FileInfo) (File, error) {
+	if follow && stat.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("cannot follow the symlink %s: %s", name, err)
+		}
+		stat = target
+	}
 
 	switch mode := stat.Mode(); {
 	case mode.IsRegular():
@@ -38,7 +63,27 @@ func NewSerialFile(name, path string, hidden bool, stat os.FileInfo) (File, erro
 		if err != nil {
 			return nil, err
 		}
-		return &serialFile{name, path, contents, stat, nil, hidden}, nil
+		if follow {
+			real, err := filepath.EvalSymlinks(path)
+			if err != nil {
+				return nil, err
+			}
+			for _, a := range ancestors {
+				if a == real {
+					return nil, fmt.Errorf("the symlink %s loops back to %s", name, real)
+				}
+			}
+			ancestors = append(ancestors[:len(ancestors):len(ancestors)], real)
+		}
+		return &serialFile{
+			name:              name,
+			path:              path,
+			files:             contents,
+			stat:              stat,
+			handleHiddenFiles: hidden,
+			followLinks:       follow,
+			ancestors:         ancestors,
+		}, nil
 	case mode&os.ModeSymlink != 0:
 		target, err := os.Readlink(path)
 		if err != nil {
@@ -87,7 +132,7 @@ func (f *serialFile) NextFile() (File, error) {
 	// recursively call the constructor on the next file
 	// if it's a regular file, we will open it as a ReaderFile
 	// if it's a directory, files in it will be opened serially
-	sf, err := NewSerialFile(fileName, filePath, f.handleHiddenFiles, stat)
+	sf, err := newSerialFile(fileName, filePath, f.handleHiddenFiles, f.followLinks, f.ancestors, stat)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,86 @@
+package files
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSerialFileSymlinks(t *testing.T) {
+	tdir, err := ioutil.TempDir("", "serialfile-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tdir)
+
+	root := filepath.Join(tdir, "root")
+	if err := os.MkdirAll(filepath.Join(root, "dir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(root, "dir", "file"), []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("dir/file", filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	// next returns the only file of the directory d named name
+	next := func(d File, name string) File {
+		for {
+			f, err := d.NextFile()
+			if err != nil {
+				t.Fatalf("%s not found: %s", name, err)
+			}
+			if filepath.Base(f.FileName()) == name {
+				return f
+			}
+		}
+	}
+
+	stat, err := os.Lstat(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := NewSerialFile("root", root, false, stat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l, ok := next(d, "link").(*Symlink); !ok || l.Target != "dir/file" {
+		t.Fatalf("expected the symlink to be kept, got %#v", l)
+	}
+
+	d, err = NewSerialFileFollowingLinks("root", root, false, stat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := next(d, "link")
+	if _, ok := f.(*Symlink); ok || f.IsDirectory() {
+		t.Fatal("expected the symlink to be followed")
+	}
+	b, err := ioutil.ReadAll(f)
+	if err != nil && err != io.EOF {
+		t.Fatal(err)
+	}
+	if string(b) != "content" {
+		t.Fatalf("expected the content of the target, got %q", b)
+	}
+
+	// a link to an ancestor would be followed forever
+	if err := os.Symlink("..", filepath.Join(root, "dir", "up")); err != nil {
+		t.Fatal(err)
+	}
+	d, err = NewSerialFileFollowingLinks("root", root, false, stat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub := next(d, "dir")
+	// the first entry of dir is file, up comes next
+	if _, err := sub.NextFile(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sub.NextFile(); err == nil {
+		t.Fatal("expected the loop to be refused")
+	}
+}
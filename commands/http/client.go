@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/url"
 	"reflect"
@@ -24,7 +25,8 @@ const (
 )
 
 var OptionSkipMap = map[string]bool{
-	"api": true,
+	"api":      true,
+	"api-auth": true,
 }
 
 // Client is the commands HTTP client interface.
@@ -33,17 +35,57 @@ type Client interface {
 }
 
 type client struct {
+	scheme        string
 	serverAddress string
 	httpClient    *http.Client
+	auth          string
 }
 
 func NewClient(address string) Client {
 	return &client{
+		scheme:        "http",
 		serverAddress: address,
 		httpClient:    http.DefaultClient,
 	}
 }
 
+// NewClientWithAuth returns a client of the API at address over scheme,
+// http or https, sending auth with its requests, for APIs behind a proxy
+// checking credentials: user:password is sent as basic credentials, anything
+// else as a bearer token. Credentials are only sent over http to loopback
+// addresses, where they cannot be read on their way.
+func NewClientWithAuth(scheme, address, auth string) (Client, error) {
+	switch scheme {
+	case "https":
+	case "http":
+		if auth != "" && !isLoopbackHost(address) {
+			return nil, fmt.Errorf("refusing to send the API credentials over plain http to %s, use https", address)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported API scheme: %s", scheme)
+	}
+	return &client{
+		scheme:        scheme,
+		serverAddress: address,
+		httpClient:    http.DefaultClient,
+		auth:          auth,
+	}, nil
+}
+
+// isLoopbackHost tells whether the host of address, host:port, is a
+// loopback address or localhost.
+func isLoopbackHost(address string) bool {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		host = address
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
 func (c *client) Send(req cmds.Request) (cmds.Response, error) {
 
 	if req.Context() == nil {
@@ -79,7 +121,7 @@ func (c *client) Send(req cmds.Request) (cmds.Response, error) {
 	}
 
 	path := strings.Join(req.Path(), "/")
-	url := fmt.Sprintf(ApiUrlFormat, c.serverAddress, ApiPath, path, query)
+	url := fmt.Sprintf("%s://%s%s/%s?%s", c.scheme, c.serverAddress, ApiPath, path, query)
 
 	httpReq, err := http.NewRequest("POST", url, reader)
 	if err != nil {
@@ -94,6 +136,14 @@ func (c *client) Send(req cmds.Request) (cmds.Response, error) {
 	}
 	httpReq.Header.Set(uaHeader, config.ApiVersion)
 
+	if c.auth != "" {
+		if i := strings.Index(c.auth, ":"); i >= 0 {
+			httpReq.SetBasicAuth(c.auth[:i], c.auth[i+1:])
+		} else {
+			httpReq.Header.Set("Authorization", "Bearer "+c.auth)
+		}
+	}
+
 	httpReq.Cancel = req.Context().Done()
 	httpReq.Close = true
 
@@ -0,0 +1,31 @@
+package http
+
+import (
+	"testing"
+)
+
+func TestNewClientWithAuth(t *testing.T) {
+	cases := []struct {
+		scheme, address, auth string
+		ok                    bool
+	}{
+		{"http", "127.0.0.1:5001", "admin:secret", true},
+		{"http", "[::1]:5001", "admin:secret", true},
+		{"http", "localhost:5001", "tok123", true},
+		{"http", "gw1.example.com:5001", "", true},
+		{"http", "gw1.example.com:5001", "admin:secret", false},
+		{"http", "10.0.0.5:5001", "tok123", false},
+		{"https", "gw1.example.com", "admin:secret", true},
+		{"ftp", "gw1.example.com", "", false},
+	}
+
+	for _, c := range cases {
+		_, err := NewClientWithAuth(c.scheme, c.address, c.auth)
+		if c.ok && err != nil {
+			t.Errorf("%s://%s with auth %q: unexpected error: %s", c.scheme, c.address, c.auth, err)
+		}
+		if !c.ok && err == nil {
+			t.Errorf("%s://%s with auth %q: expected an error", c.scheme, c.address, c.auth)
+		}
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"sync"
 
 	cmds "github.com/ipfs/go-ipfs/commands"
+	bitswap "github.com/ipfs/go-ipfs/exchange/bitswap"
 	"github.com/ipfs/go-ipfs/repo/config"
 
 	cors "gx/ipfs/QmPG2kW5t27LuHgHnvhUwbHCNHAt2eUcb4gPHqofrESUdB/cors"
@@ -111,6 +112,22 @@ func NewHandler(ctx cmds.Context, root *cmds.Command, cfg *ServerConfig) http.Ha
 	return &Handler{internal, c.Handler(internal)}
 }
 
+// Guard wraps h, an endpoint of the API outside of the commands, with the
+// CORS handling of cfg, and refuses the requests whose origin or referer cfg
+// doesn't allow, as the commands do.
+func Guard(cfg *ServerConfig, h http.Handler) http.Handler {
+	c := cors.New(*cfg.cORSOpts)
+	return c.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !allowOrigin(r, cfg) || !allowReferer(r, cfg) {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte("403 - Forbidden"))
+			log.Warningf("API blocked request to %s. (possible CSRF)", r.URL)
+			return
+		}
+		h.ServeHTTP(w, r)
+	}))
+}
+
 func (i Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// Call the CORS handler which wraps the internal handler.
 	i.corsHandler.ServeHTTP(w, r)
@@ -173,7 +190,8 @@ func (i internalHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	//ps: take note of the name clash - commands.Context != context.Context
 	req.SetInvocContext(i.ctx)
 
-	err = req.SetRootContext(ctx)
+	// the blocks fetched are reported by 'ipfs stats want' as wanted by the command
+	err = req.SetRootContext(bitswap.WithRequester(ctx, fmt.Sprintf("%s #%d", rlog.Command, rlog.ID)))
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -370,6 +388,14 @@ func (cfg *ServerConfig) SetAllowedMethods(methods ...string) {
 	cfg.cORSOpts.AllowedMethods = methods
 }
 
+// SetAllowedHeaders sets the headers cross-origin requests may carry, besides
+// the simple ones.
+func (cfg *ServerConfig) SetAllowedHeaders(headers ...string) {
+	cfg.cORSOptsRWMutex.Lock()
+	defer cfg.cORSOptsRWMutex.Unlock()
+	cfg.cORSOpts.AllowedHeaders = headers
+}
+
 func (cfg *ServerConfig) SetAllowCredentials(flag bool) {
 	cfg.cORSOptsRWMutex.Lock()
 	defer cfg.cORSOptsRWMutex.Unlock()
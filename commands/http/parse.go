@@ -52,8 +52,9 @@ func Parse(r *http.Request, root *cmds.Command) (cmds.Request, error) {
 	stringArgs = append(stringArgs, stringArgs2...)
 
 	// count required argument definitions
+	argDefs := cmd.ArgumentsWith(opts)
 	numRequired := 0
-	for _, argDef := range cmd.Arguments {
+	for _, argDef := range argDefs {
 		if argDef.Required {
 			numRequired++
 		}
@@ -66,7 +67,7 @@ func Parse(r *http.Request, root *cmds.Command) (cmds.Request, error) {
 
 	valIndex := 0
 	requiredFile := ""
-	for _, argDef := range cmd.Arguments {
+	for _, argDef := range argDefs {
 		// skip optional argument definitions if there aren't sufficient remaining values
 		if valCount-valIndex <= numRequired && !argDef.Required {
 			continue
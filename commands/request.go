@@ -13,6 +13,7 @@ import (
 
 	"github.com/ipfs/go-ipfs/commands/files"
 	"github.com/ipfs/go-ipfs/core"
+	"github.com/ipfs/go-ipfs/keystore"
 	"github.com/ipfs/go-ipfs/repo/config"
 	u "gx/ipfs/QmWbjfz3u6HkAdPh34dgPchGbQjob6LXLhAeCGii2TX69n/go-ipfs-util"
 )
@@ -29,6 +30,9 @@ type Context struct {
 
 	node          *core.IpfsNode
 	ConstructNode func() (*core.IpfsNode, error)
+
+	keystore     keystore.Keystore
+	OpenKeystore func(path string) (keystore.Keystore, error)
 }
 
 // GetConfig returns the config of the current Command exection
@@ -57,6 +61,29 @@ func (c *Context) GetNode() (*core.IpfsNode, error) {
 	return c.node, err
 }
 
+// GetKeystore returns the keystore of the current Command exection
+// context: the one of the node if it is constructed, or else the one
+// opened with the provided function, without the rest of the repo. The
+// node is constructed if there is no such function.
+func (c *Context) GetKeystore() (keystore.Keystore, error) {
+	if c.node != nil {
+		return c.node.Repo.Keystore(), nil
+	}
+	if c.OpenKeystore == nil {
+		n, err := c.GetNode()
+		if err != nil {
+			return nil, err
+		}
+		return n.Repo.Keystore(), nil
+	}
+
+	var err error
+	if c.keystore == nil {
+		c.keystore, err = c.OpenKeystore(c.ConfigRoot)
+	}
+	return c.keystore, err
+}
+
 // NodeWithoutConstructing returns the underlying node variable
 // so that clients may close it.
 func (c *Context) NodeWithoutConstructing() *core.IpfsNode {
@@ -0,0 +1,305 @@
+package core
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	config "github.com/ipfs/go-ipfs/repo/config"
+
+	identify "gx/ipfs/QmQA5mdxru8Bh6dpC9PJfSkumqnmHgJX7knxSgBo5Lpime/go-libp2p/p2p/protocol/identify"
+	identifypb "gx/ipfs/QmQA5mdxru8Bh6dpC9PJfSkumqnmHgJX7knxSgBo5Lpime/go-libp2p/p2p/protocol/identify/pb"
+	inet "gx/ipfs/QmRscs8KxrSmSv4iuevHv8JfuUzHBMoqiaHzxfDRiksd6e/go-libp2p-net"
+	mamask "gx/ipfs/QmSMZwvs3n4GBikZ7hKzT17c3bk65FmyZo2JqtJ16swqCv/multiaddr-filter"
+	p2phost "gx/ipfs/QmUywuGNZoUKV8B9iyvup9bPkLiMrhTsyVMkeSXW5VxAfC/go-libp2p-host"
+	ggio "gx/ipfs/QmZ4Qi3GaRbjcx28Sme5eMH7RQjGkt8wHxt2a65oLaeFEV/gogo-protobuf/io"
+	addrutil "gx/ipfs/QmbH3urJHTrZSUETgvQRriWM6mMFqyNSwCqnhknxfSGVWv/go-addr-util"
+	ma "gx/ipfs/QmcyqRMCAXVtYPS4DiBrA7sezL9rRGfW8Ctx7cywL4TXJj/go-multiaddr"
+	manet "gx/ipfs/Qmf1Gq7N45Rpuw7ev47uWgH6dLPtdnvcMRNPkVBwqjLJg2/go-multiaddr-net"
+)
+
+// Placeholders of Addresses.Announce, replaced by each public address the
+// node was seen at, through NAT port mappings or by its peers.
+const (
+	placeholderPublicIP4 = "{public-ip4}"
+	placeholderPublicIP6 = "{public-ip6}"
+)
+
+// announceRefresh is how long the announced addresses are computed for.
+// They are computed again afterwards, so that changes of interfaces and of
+// the public address are announced without a restart.
+const announceRefresh = 10 * time.Second
+
+// announcer computes the addresses the node announces to its peers, from
+// Addresses.Announce and Addresses.NoAnnounce.
+type announcer struct {
+	announce   []string
+	noAnnounce map[string]bool
+	filters    []*net.IPNet
+	family     addressFamily
+
+	// interfaceAddrs lists the addresses of the interfaces of the host,
+	// for unspecified addresses like /ip4/0.0.0.0/tcp/4001.
+	interfaceAddrs func() ([]ma.Multiaddr, error)
+
+	mu       sync.Mutex
+	cached   []ma.Multiaddr
+	cachedAt time.Time
+}
+
+func newAnnouncer(cfg config.Addresses, family addressFamily) (*announcer, error) {
+	a := &announcer{
+		announce:       cfg.Announce,
+		noAnnounce:     make(map[string]bool),
+		family:         family,
+		interfaceAddrs: manet.InterfaceMultiaddrs,
+	}
+
+	for _, s := range cfg.Announce {
+		s = strings.Replace(s, placeholderPublicIP4, "127.0.0.1", -1)
+		s = strings.Replace(s, placeholderPublicIP6, "::1", -1)
+		if _, err := ma.NewMultiaddr(s); err != nil {
+			return nil, fmt.Errorf("invalid address in Addresses.Announce: %s", s)
+		}
+	}
+
+	for _, s := range cfg.NoAnnounce {
+		if f, err := mamask.NewMask(s); err == nil {
+			a.filters = append(a.filters, f)
+			continue
+		}
+		maddr, err := ma.NewMultiaddr(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address in Addresses.NoAnnounce: %s", s)
+		}
+		a.noAnnounce[maddr.String()] = true
+	}
+	return a, nil
+}
+
+// enabled tells whether the announced addresses differ from the ones the
+// host finds.
+func (a *announcer) enabled() bool {
+	return len(a.announce) > 0 || len(a.noAnnounce) > 0 || len(a.filters) > 0 || a.family != addressFamily{}
+}
+
+// addrs returns the addresses to announce, given all the addresses of the
+// host, which include the mapped and observed ones.
+func (a *announcer) addrs(all []ma.Multiaddr) []ma.Multiaddr {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.cached != nil && time.Since(a.cachedAt) < announceRefresh {
+		return a.cached
+	}
+
+	out := a.compute(all)
+	if !sameAddrs(out, a.cached) {
+		log.Infof("announcing %s", out)
+	}
+	a.cached, a.cachedAt = out, time.Now()
+	return out
+}
+
+func (a *announcer) compute(all []ma.Multiaddr) []ma.Multiaddr {
+	addrs := all
+	if len(a.announce) > 0 {
+		addrs = a.expand(all)
+	}
+
+	// addresses of the preferred family come first
+	out := make([]ma.Multiaddr, 0, len(addrs))
+	var others []ma.Multiaddr
+	seen := make(map[string]bool)
+	for _, maddr := range addrs {
+		s := maddr.String()
+		if seen[s] || a.noAnnounce[s] || a.filtered(maddr) {
+			continue
+		}
+		seen[s] = true
+
+		family, ip := addrIP(maddr)
+		if !a.family.allows(family) {
+			continue
+		}
+		if family == familyIP6 && ip != nil && ip.IsLinkLocalUnicast() {
+			// peers can't reach link-local addresses, zone or not
+			continue
+		}
+		if a.family.rank(family) == 0 {
+			out = append(out, maddr)
+		} else {
+			others = append(others, maddr)
+		}
+	}
+	return append(out, others...)
+}
+
+// expand turns the Announce templates into addresses, filling the
+// placeholders in and resolving the unspecified addresses.
+func (a *announcer) expand(all []ma.Multiaddr) []ma.Multiaddr {
+	var ip4s, ip6s []string
+	for _, maddr := range all {
+		proto, ip := addrIP(maddr)
+		if ip == nil || ipScope(ip) != scopePublic {
+			continue
+		}
+		if proto == "ip4" {
+			ip4s = appendNew(ip4s, ip.String())
+		} else {
+			ip6s = appendNew(ip6s, ip.String())
+		}
+	}
+
+	var ifaces []ma.Multiaddr
+	var out []ma.Multiaddr
+	for _, tmpl := range a.announce {
+		var strs []string
+		switch {
+		case strings.Contains(tmpl, placeholderPublicIP4):
+			for _, ip := range ip4s {
+				strs = append(strs, strings.Replace(tmpl, placeholderPublicIP4, ip, -1))
+			}
+		case strings.Contains(tmpl, placeholderPublicIP6):
+			for _, ip := range ip6s {
+				strs = append(strs, strings.Replace(tmpl, placeholderPublicIP6, ip, -1))
+			}
+		default:
+			strs = []string{tmpl}
+		}
+
+		for _, s := range strs {
+			maddr, err := ma.NewMultiaddr(s)
+			if err != nil {
+				log.Warningf("invalid announced address %s: %s", s, err)
+				continue
+			}
+
+			if _, ip := addrIP(maddr); ip == nil || !ip.IsUnspecified() {
+				out = append(out, maddr)
+				continue
+			}
+
+			if ifaces == nil {
+				var err error
+				ifaces, err = a.interfaceAddrs()
+				if err != nil {
+					log.Warningf("failed to list the interface addresses: %s", err)
+					continue
+				}
+			}
+			resolved, err := addrutil.ResolveUnspecifiedAddress(maddr, ifaces)
+			if err != nil {
+				log.Warningf("failed to resolve announced address %s: %s", maddr, err)
+				continue
+			}
+			out = append(out, resolved...)
+		}
+	}
+	return out
+}
+
+func (a *announcer) filtered(maddr ma.Multiaddr) bool {
+	_, ip := addrIP(maddr)
+	if ip == nil {
+		return false
+	}
+	for _, f := range a.filters {
+		if f.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// addrIP returns the IP protocol and address of maddr, if it has one. The
+// dns4 and dns6 names have the protocol they resolve to, and no address.
+func addrIP(maddr ma.Multiaddr) (string, net.IP) {
+	parts := strings.Split(maddr.String(), "/")
+	for i := 1; i+1 < len(parts); i++ {
+		if parts[i] == "ip4" || parts[i] == "ip6" {
+			return parts[i], net.ParseIP(parts[i+1])
+		}
+		if family, ok := dnsFamilies[parts[i]]; ok {
+			return family, nil
+		}
+	}
+	return "", nil
+}
+
+func appendNew(list []string, s string) []string {
+	for _, o := range list {
+		if o == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
+
+func sameAddrs(a, b []ma.Multiaddr) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// announceHost announces the addresses of its announcer, to the services
+// asking the host for its addresses and to peers identifying the node.
+type announceHost struct {
+	p2phost.Host
+	a *announcer
+}
+
+func wrapAnnounceHost(h p2phost.Host, a *announcer) p2phost.Host {
+	ah := &announceHost{Host: h, a: a}
+	// replaces the identify handler of the host, which would send all the
+	// addresses of the host
+	h.SetStreamHandler(identify.ID, ah.handleIdentify)
+	return ah
+}
+
+func (h *announceHost) Addrs() []ma.Multiaddr {
+	return h.a.addrs(h.Host.Addrs())
+}
+
+func (h *announceHost) handleIdentify(s inet.Stream) {
+	defer s.Close()
+
+	c := s.Conn()
+	mes := &identifypb.Identify{}
+
+	mes.Protocols = h.Mux().Protocols()
+
+	// the remote address helps the peer find out its public address
+	mes.ObservedAddr = c.RemoteMultiaddr().Bytes()
+
+	for _, addr := range h.Addrs() {
+		mes.ListenAddrs = append(mes.ListenAddrs, addr.Bytes())
+	}
+
+	if pk := h.Peerstore().PubKey(h.ID()); pk != nil {
+		kb, err := pk.Bytes()
+		if err != nil {
+			log.Warningf("failed to marshal the public key for identify: %s", err)
+		} else {
+			mes.PublicKey = kb
+		}
+	}
+
+	pv := identify.LibP2PVersion
+	av := identify.ClientVersion
+	mes.ProtocolVersion = &pv
+	mes.AgentVersion = &av
+
+	w := ggio.NewDelimitedWriter(s)
+	if err := w.WriteMsg(mes); err != nil {
+		log.Debugf("failed to send identify to %s: %s", c.RemotePeer(), err)
+	}
+}
@@ -0,0 +1,87 @@
+package core
+
+import (
+	"testing"
+
+	config "github.com/ipfs/go-ipfs/repo/config"
+
+	ma "gx/ipfs/QmcyqRMCAXVtYPS4DiBrA7sezL9rRGfW8Ctx7cywL4TXJj/go-multiaddr"
+)
+
+func addrStrings(addrs []ma.Multiaddr) []string {
+	out := make([]string, len(addrs))
+	for i, a := range addrs {
+		out[i] = a.String()
+	}
+	return out
+}
+
+func TestAnnouncer(t *testing.T) {
+	all := []ma.Multiaddr{
+		ma.StringCast("/ip4/127.0.0.1/tcp/4001"),
+		ma.StringCast("/ip4/10.0.0.5/tcp/4001"),
+		ma.StringCast("/ip4/1.2.3.4/tcp/4001"),
+		ma.StringCast("/ip4/1.2.3.4/tcp/4002"),
+		ma.StringCast("/ip6/::1/tcp/4001"),
+		ma.StringCast("/ip6/fe80::1/tcp/4001"),
+	}
+
+	for _, test := range []struct {
+		cfg      config.Addresses
+		family   addressFamily
+		expected []string
+	}{
+		{
+			cfg: config.Addresses{NoAnnounce: []string{"/ip4/10.0.0.0/ipcidr/8", "/ip6/::1/tcp/4001"}},
+			expected: []string{
+				"/ip4/127.0.0.1/tcp/4001",
+				"/ip4/1.2.3.4/tcp/4001",
+				"/ip4/1.2.3.4/tcp/4002",
+			},
+		},
+		{
+			cfg: config.Addresses{Announce: []string{"/ip4/{public-ip4}/tcp/5001", "/ip6/{public-ip6}/tcp/5001", "/dns4/example.com/tcp/5001"}},
+			expected: []string{
+				"/ip4/1.2.3.4/tcp/5001",
+				"/dns4/example.com/tcp/5001",
+			},
+		},
+		{
+			family: addressFamily{prefer: familyIP6},
+			expected: []string{
+				"/ip6/::1/tcp/4001",
+				"/ip4/127.0.0.1/tcp/4001",
+				"/ip4/10.0.0.5/tcp/4001",
+				"/ip4/1.2.3.4/tcp/4001",
+				"/ip4/1.2.3.4/tcp/4002",
+			},
+		},
+		{
+			family:   addressFamily{prefer: familyIP6, only: true},
+			expected: []string{"/ip6/::1/tcp/4001"},
+		},
+		{
+			cfg:      config.Addresses{Announce: []string{"/dns4/example.com/tcp/5001", "/dns6/example.com/tcp/5001"}},
+			family:   addressFamily{prefer: familyIP6, only: true},
+			expected: []string{"/dns6/example.com/tcp/5001"},
+		},
+	} {
+		a, err := newAnnouncer(test.cfg, test.family)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := addrStrings(a.addrs(all))
+		if len(got) != len(test.expected) {
+			t.Fatalf("expected %v, got %v", test.expected, got)
+		}
+		for i := range got {
+			if got[i] != test.expected[i] {
+				t.Fatalf("expected %v, got %v", test.expected, got)
+			}
+		}
+	}
+
+	if _, err := newAnnouncer(config.Addresses{Announce: []string{"/ip4/{nope}/tcp/1"}}, addressFamily{}); err == nil {
+		t.Fatal("expected an invalid template to be refused")
+	}
+}
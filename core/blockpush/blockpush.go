@@ -0,0 +1,344 @@
+// Package blockpush implements a protocol through which trusted peers push
+// blocks to a node, rather than waiting for it to find and fetch them, such
+// as a build machine sending a new release to gateways.
+package blockpush
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	blocks "github.com/ipfs/go-ipfs/blocks"
+	bstore "github.com/ipfs/go-ipfs/blocks/blockstore"
+	bserv "github.com/ipfs/go-ipfs/blockservice"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	pin "github.com/ipfs/go-ipfs/pin"
+	config "github.com/ipfs/go-ipfs/repo/config"
+
+	humanize "gx/ipfs/QmPSBJL4momYnE7DcUyk2DVhD6rH488ZmHBGLbxNdhU44K/go-humanize"
+	inet "gx/ipfs/QmRscs8KxrSmSv4iuevHv8JfuUzHBMoqiaHzxfDRiksd6e/go-libp2p-net"
+	logging "gx/ipfs/QmSpJByNKFX1sCsHBEp3R73FL4NF6FnQTEGyNAXHm2GS52/go-log"
+	p2phost "gx/ipfs/QmUywuGNZoUKV8B9iyvup9bPkLiMrhTsyVMkeSXW5VxAfC/go-libp2p-host"
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+	protocol "gx/ipfs/QmZNkThpqfVXs9GNbexPrfBbXSLNYeKrE7jwFM2oqHbyqN/go-libp2p-protocol"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+var log = logging.Logger("blockpush")
+
+// ID is the protocol of block pushes.
+const ID protocol.ID = "/ipfs/blockpush/1.0.0"
+
+// MaxBlockSize is the size of the largest block accepted, as by bitswap.
+const MaxBlockSize = 2 << 20
+
+// quotaWindow is the period the quota of each peer is counted over.
+const quotaWindow = time.Hour
+
+// sessionIdle is how long the blocks of a peer are kept from garbage
+// collection after its last request.
+const sessionIdle = time.Minute
+
+// pinTimeout bounds the pins of the dags pushed.
+const pinTimeout = 10 * time.Minute
+
+// ErrNotAllowed is returned to the peers not allowed to push blocks.
+var ErrNotAllowed = errors.New("not allowed to push blocks")
+
+// request asks whether the node has the block Cid, or stores it if Data is
+// set, or pins the dag under it recursively if Pin is, once it is pushed.
+// Each request is sent on a stream of its own.
+type request struct {
+	Cid  string
+	Data []byte `json:",omitempty"`
+	Pin  bool   `json:",omitempty"`
+}
+
+type response struct {
+	Has   bool
+	Error string `json:",omitempty"`
+}
+
+// RemoteError is an error returned by the peer blocks are pushed to.
+type RemoteError struct {
+	Peer peer.ID
+	Msg  string
+}
+
+func (e *RemoteError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Peer.Pretty(), e.Msg)
+}
+
+// usage is what a peer pushed during the current window.
+type usage struct {
+	start time.Time
+	bytes uint64
+}
+
+// session keeps the garbage collection from removing the blocks a peer
+// pushes before it pins them, until it stops sending requests.
+type session struct {
+	unlocker bstore.Unlocker
+	timer    *time.Timer
+}
+
+// Service stores the blocks pushed by the allowed peers.
+type Service struct {
+	bs      bserv.BlockService
+	gcbs    bstore.GCBlockstore
+	pinning pin.Pinner
+	allowed map[peer.ID]bool
+	quota   uint64
+
+	mu       sync.Mutex
+	usage    map[peer.ID]*usage
+	sessions map[peer.ID]*session
+}
+
+// NewService accepts the blocks pushed to h by the peers cfg allows, and
+// stores them in bs, which announces them. gcbs is the blockstore of bs, whose
+// garbage collection waits for the peers pushing blocks, and pinning pins
+// the dags they ask to.
+func NewService(h p2phost.Host, bs bserv.BlockService, gcbs bstore.GCBlockstore, pinning pin.Pinner, cfg config.BlockPushConfig) (*Service, error) {
+	s := &Service{
+		bs:       bs,
+		gcbs:     gcbs,
+		pinning:  pinning,
+		allowed:  make(map[peer.ID]bool),
+		usage:    make(map[peer.ID]*usage),
+		sessions: make(map[peer.ID]*session),
+	}
+	for _, a := range cfg.AllowedPeers {
+		p, err := peer.IDB58Decode(a)
+		if err != nil {
+			return nil, fmt.Errorf("invalid allowed peer %q: %s", a, err)
+		}
+		s.allowed[p] = true
+	}
+	if cfg.Quota != "" {
+		quota, err := humanize.ParseBytes(cfg.Quota)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Swarm.BlockPush.Quota: %s", err)
+		}
+		s.quota = quota
+	}
+
+	h.SetStreamHandler(ID, s.handle)
+	return s, nil
+}
+
+func (s *Service) handle(st inet.Stream) {
+	p := st.Conn().RemotePeer()
+	if !s.allowed[p] {
+		// nothing is read from the other peers
+		log.Debugf("refused the block push of %s: %s", p, ErrNotAllowed)
+		st.Reset()
+		return
+	}
+	defer st.Close()
+
+	var req request
+	if err := json.NewDecoder(io.LimitReader(st, 2*MaxBlockSize)).Decode(&req); err != nil {
+		log.Debugf("invalid block push from %s: %s", p, err)
+		return
+	}
+
+	var resp response
+	has, err := s.serve(p, &req)
+	if err != nil {
+		log.Infof("refused a block from %s: %s", p, err)
+		resp.Error = err.Error()
+	}
+	resp.Has = has
+	if err := json.NewEncoder(st).Encode(&resp); err != nil {
+		log.Debugf("failed to answer the block push of %s: %s", p, err)
+	}
+}
+
+// serve answers the request of p, telling whether the node has the block,
+// which is stored if it is sent, or pins the dag under it.
+func (s *Service) serve(p peer.ID, req *request) (bool, error) {
+	if !s.allowed[p] {
+		return false, ErrNotAllowed
+	}
+
+	c, err := cid.Decode(req.Cid)
+	if err != nil {
+		return false, err
+	}
+
+	// the blocks p has pushed, or found present, are kept until it pins them
+	s.hold(p)
+	if req.Pin {
+		return true, s.pin(c)
+	}
+
+	has, err := s.bs.Blockstore().Has(c)
+	if err != nil || has || req.Data == nil {
+		return has, err
+	}
+
+	if len(req.Data) > MaxBlockSize {
+		return false, fmt.Errorf("block of %d bytes over the limit of %d", len(req.Data), MaxBlockSize)
+	}
+	// the hash of the data is checked whatever the build
+	sum, err := c.Prefix().Sum(req.Data)
+	if err != nil {
+		return false, err
+	}
+	if !sum.Equals(c) {
+		return false, fmt.Errorf("the data of %s doesn't match its hash", c)
+	}
+	if err := s.charge(p, uint64(len(req.Data))); err != nil {
+		return false, err
+	}
+
+	b, err := blocks.NewBlockWithCid(req.Data, c)
+	if err != nil {
+		return false, err
+	}
+	if _, err := s.bs.AddBlock(b); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// pin pins the dag under c recursively.
+func (s *Service) pin(c *cid.Cid) error {
+	ctx, cancel := context.WithTimeout(context.Background(), pinTimeout)
+	defer cancel()
+
+	nd, err := dag.NewDAGService(s.bs).Get(ctx, c)
+	if err != nil {
+		return err
+	}
+	if err := s.pinning.Pin(ctx, nd, true); err != nil {
+		return err
+	}
+	return s.pinning.Flush()
+}
+
+// hold keeps the garbage collection from running until p has sent no
+// request for sessionIdle, starting a session for p if it has none.
+func (s *Service) hold(p peer.ID) {
+	s.mu.Lock()
+	if ss := s.sessions[p]; ss != nil {
+		if ss.timer.Reset(sessionIdle) {
+			s.mu.Unlock()
+			return
+		}
+		// it expired in between
+		s.end(p, ss)
+	}
+	s.mu.Unlock()
+
+	// waits for a garbage collection running to complete
+	unlocker := s.gcbs.PinLock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ss := s.sessions[p]; ss != nil {
+		// another request of p started one meanwhile
+		if ss.timer.Reset(sessionIdle) {
+			unlocker.Unlock()
+			return
+		}
+		s.end(p, ss)
+	}
+	ss := &session{unlocker: unlocker}
+	ss.timer = time.AfterFunc(sessionIdle, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.sessions[p] == ss {
+			s.end(p, ss)
+		}
+	})
+	s.sessions[p] = ss
+}
+
+// end ends the session ss of p. s.mu must be held.
+func (s *Service) end(p peer.ID, ss *session) {
+	delete(s.sessions, p)
+	ss.timer.Stop()
+	ss.unlocker.Unlock()
+}
+
+// charge counts n bytes against the quota of p.
+func (s *Service) charge(p peer.ID, n uint64) error {
+	if s.quota == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u := s.usage[p]
+	if u == nil || time.Since(u.start) >= quotaWindow {
+		u = &usage{start: time.Now()}
+		s.usage[p] = u
+	}
+	if u.bytes+n > s.quota {
+		return fmt.Errorf("quota of %d bytes an hour exceeded", s.quota)
+	}
+	u.bytes += n
+	return nil
+}
+
+// Has asks p whether it has the block c.
+func Has(ctx context.Context, h p2phost.Host, p peer.ID, c *cid.Cid) (bool, error) {
+	resp, err := call(ctx, h, p, &request{Cid: c.String()})
+	if err != nil {
+		return false, err
+	}
+	return resp.Has, nil
+}
+
+// Put pushes the block b to p, which checks it and stores it.
+func Put(ctx context.Context, h p2phost.Host, p peer.ID, b blocks.Block) error {
+	_, err := call(ctx, h, p, &request{Cid: b.Cid().String(), Data: b.RawData()})
+	return err
+}
+
+// Pin asks p to pin the dag under c recursively, once its blocks are pushed.
+func Pin(ctx context.Context, h p2phost.Host, p peer.ID, c *cid.Cid) error {
+	_, err := call(ctx, h, p, &request{Cid: c.String(), Pin: true})
+	return err
+}
+
+func call(ctx context.Context, h p2phost.Host, p peer.ID, req *request) (*response, error) {
+	st, err := h.NewStream(ctx, p, ID)
+	if err != nil {
+		return nil, err
+	}
+	defer st.Close()
+
+	// the stream doesn't follow ctx by itself
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			st.Close()
+		case <-done:
+		}
+	}()
+
+	if err := json.NewEncoder(st).Encode(req); err != nil {
+		return nil, err
+	}
+	var resp response
+	if err := json.NewDecoder(st).Decode(&resp); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("invalid answer to a block push: %s", err)
+	}
+	if resp.Error != "" {
+		return nil, &RemoteError{Peer: p, Msg: resp.Error}
+	}
+	return &resp, nil
+}
@@ -0,0 +1,92 @@
+package blockpush
+
+import (
+	"testing"
+	"time"
+
+	blocks "github.com/ipfs/go-ipfs/blocks"
+	blockstore "github.com/ipfs/go-ipfs/blocks/blockstore"
+	bserv "github.com/ipfs/go-ipfs/blockservice"
+	offline "github.com/ipfs/go-ipfs/exchange/offline"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	pin "github.com/ipfs/go-ipfs/pin"
+
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+	dssync "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore/sync"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+func TestServe(t *testing.T) {
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	bs := blockstore.NewGCBlockstore(blockstore.NewBlockstore(dstore), blockstore.NewGCLocker())
+	dserv := dag.NewDAGService(bserv.New(bs, offline.Exchange(bs)))
+	trusted, stranger := peer.ID("trusted"), peer.ID("stranger")
+	s := &Service{
+		bs:       bserv.New(bs, offline.Exchange(bs)),
+		gcbs:     bs,
+		pinning:  pin.NewPinner(dstore, dserv, dserv),
+		allowed:  map[peer.ID]bool{trusted: true},
+		quota:    10,
+		usage:    make(map[peer.ID]*usage),
+		sessions: make(map[peer.ID]*session),
+	}
+
+	b := dag.NodeWithData([]byte("release"))
+	push := &request{Cid: b.Cid().String(), Data: b.RawData()}
+
+	if _, err := s.serve(stranger, push); err != ErrNotAllowed {
+		t.Fatalf("expected the block of a stranger to be refused, got %v", err)
+	}
+
+	forged := &request{Cid: b.Cid().String(), Data: []byte("malware")}
+	if _, err := s.serve(trusted, forged); err == nil {
+		t.Fatal("expected a block not matching its hash to be refused")
+	}
+
+	has, err := s.serve(trusted, &request{Cid: b.Cid().String()})
+	if err != nil || has {
+		t.Fatalf("expected the block to be missing, got %v, %v", has, err)
+	}
+	if has, err := s.serve(trusted, push); err != nil || !has {
+		t.Fatalf("expected the block to be stored, got %v, %v", has, err)
+	}
+	if ok, _ := bs.Has(b.Cid()); !ok {
+		t.Fatal("the block was not stored")
+	}
+
+	// the blocks the node has don't count against the quota
+	if _, err := s.serve(trusted, push); err != nil {
+		t.Fatal(err)
+	}
+	other := blocks.NewBlock([]byte("next release"))
+	if _, err := s.serve(trusted, &request{Cid: other.Cid().String(), Data: other.RawData()}); err == nil {
+		t.Fatal("expected the quota to be exceeded")
+	}
+
+	if _, err := s.serve(trusted, &request{Cid: b.Cid().String(), Pin: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, pinned, err := s.pinning.IsPinned(b.Cid()); err != nil || !pinned {
+		t.Fatalf("expected the block to be pinned, got %v, %v", pinned, err)
+	}
+
+	// the garbage collection waits for the session of the peer to end
+	gcDone := make(chan struct{})
+	go func() {
+		bs.GCLock().Unlock()
+		close(gcDone)
+	}()
+	select {
+	case <-gcDone:
+		t.Fatal("expected the garbage collection to wait for the pushes")
+	case <-time.After(50 * time.Millisecond):
+	}
+	s.mu.Lock()
+	s.end(trusted, s.sessions[trusted])
+	s.mu.Unlock()
+	select {
+	case <-gcDone:
+	case <-time.After(time.Second):
+		t.Fatal("expected the garbage collection to run once the session ended")
+	}
+}
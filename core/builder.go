@@ -5,16 +5,18 @@ import (
 	"crypto/rand"
 	"encoding/base64"
 	"errors"
+	"fmt"
 	"os"
 	"syscall"
 	"time"
 
 	bstore "github.com/ipfs/go-ipfs/blocks/blockstore"
 	bserv "github.com/ipfs/go-ipfs/blockservice"
+	blockpush "github.com/ipfs/go-ipfs/core/blockpush"
+	events "github.com/ipfs/go-ipfs/core/events"
 	offline "github.com/ipfs/go-ipfs/exchange/offline"
 	filestore "github.com/ipfs/go-ipfs/filestore"
 	dag "github.com/ipfs/go-ipfs/merkledag"
-	path "github.com/ipfs/go-ipfs/path"
 	pin "github.com/ipfs/go-ipfs/pin"
 	repo "github.com/ipfs/go-ipfs/repo"
 	cfg "github.com/ipfs/go-ipfs/repo/config"
@@ -131,6 +133,7 @@ func NewNode(ctx context.Context, cfg *BuildCfg) (*IpfsNode, error) {
 		Repo:      cfg.Repo,
 		ctx:       ctx,
 		Peerstore: pstore.NewPeerstore(),
+		Events:    events.NewBus(),
 	}
 	if cfg.Online {
 		n.mode = onlineMode
@@ -192,13 +195,42 @@ func setupNode(ctx context.Context, n *IpfsNode, cfg *BuildCfg) error {
 
 	n.BaseBlocks = cbs
 	n.GCLocker = bstore.NewGCLocker()
-	n.Blockstore = bstore.NewGCBlockstore(cbs, n.GCLocker)
 
+	var base bstore.Blockstore = cbs
 	if conf.Experimental.FilestoreEnabled {
 		n.Filestore = filestore.NewFilestore(bs, n.Repo.FileManager())
-		n.Blockstore = bstore.NewGCBlockstore(n.Filestore, n.GCLocker)
+		base = n.Filestore
 	}
 
+	// the accesses are only needed by the daemon, which evicts blocks
+	if conf.Datastore.Eviction.Policy != "" && cfg.Permament {
+		n.Accesses = bstore.NewAccessBlockstore(ctx, base, n.Repo.Datastore())
+		base = n.Accesses
+	}
+	if len(conf.Datastore.DeniedHashes) > 0 {
+		policy, err := bstore.NewHashPolicy(conf.Datastore.DeniedHashes)
+		if err != nil {
+			return fmt.Errorf("invalid Datastore.DeniedHashes: %s", err)
+		}
+		base = bstore.NewHashPolicyBlockstore(base, policy)
+	}
+	// every process adding blocks journals them, not only the daemon
+	if conf.Datastore.Journal.Enabled {
+		retention := bstore.DefaultJournalRetention
+		if r := conf.Datastore.Journal.Retention; r != "" {
+			retention, err = time.ParseDuration(r)
+			if err != nil {
+				return fmt.Errorf("invalid Datastore.Journal.Retention: %s", err)
+			}
+		}
+		n.Journal, err = bstore.NewJournalBlockstore(ctx, base, n.Repo.Datastore(), retention)
+		if err != nil {
+			return err
+		}
+		base = n.Journal
+	}
+	n.Blockstore = bstore.NewGCBlockstore(base, n.GCLocker)
+
 	rcfg, err := n.Repo.Config()
 	if err != nil {
 		return err
@@ -210,7 +242,7 @@ func setupNode(ctx context.Context, n *IpfsNode, cfg *BuildCfg) error {
 
 	if cfg.Online {
 		do := setupDiscoveryOption(rcfg.Discovery)
-		if err := n.startOnlineServices(ctx, cfg.Routing, cfg.Host, do, cfg.getOpt("pubsub"), cfg.getOpt("mplex")); err != nil {
+		if err := n.startOnlineServices(ctx, cfg.Routing, cfg.Host, do, cfg.getOpt("pubsub"), cfg.getOpt("ipnsps"), cfg.getOpt("mplex")); err != nil {
 			return err
 		}
 	} else {
@@ -229,7 +261,18 @@ func setupNode(ctx context.Context, n *IpfsNode, cfg *BuildCfg) error {
 		// this is kinda sketchy and could cause data loss
 		n.Pinning = pin.NewPinner(n.Repo.Datastore(), n.DAG, internalDag)
 	}
-	n.Resolver = path.NewBasicResolver(n.DAG)
+	n.PinJobs = pin.NewJobTracker()
+
+	if cfg.Online && len(rcfg.Swarm.BlockPush.AllowedPeers) > 0 {
+		// the dags pushed are pinned with n.Pinning, only set now
+		n.BlockPush, err = blockpush.NewService(n.PeerHost, n.Blocks, n.Blockstore, n.Pinning, rcfg.Swarm.BlockPush)
+		if err != nil {
+			return err
+		}
+	}
+	if err := n.setupResolver(); err != nil {
+		return err
+	}
 
 	return n.loadFilesRoot()
 }
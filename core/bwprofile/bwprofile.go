@@ -0,0 +1,356 @@
+// Package bwprofile applies the bandwidth and connection limits of the
+// Swarm.BandwidthProfiles config, switching between profiles as their time
+// windows begin and end, and the stream and bandwidth caps of protocols of
+// Swarm.ProtocolLimits.
+package bwprofile
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	config "github.com/ipfs/go-ipfs/repo/config"
+
+	inet "gx/ipfs/QmRscs8KxrSmSv4iuevHv8JfuUzHBMoqiaHzxfDRiksd6e/go-libp2p-net"
+	logging "gx/ipfs/QmSpJByNKFX1sCsHBEp3R73FL4NF6FnQTEGyNAXHm2GS52/go-log"
+	p2phost "gx/ipfs/QmUywuGNZoUKV8B9iyvup9bPkLiMrhTsyVMkeSXW5VxAfC/go-libp2p-host"
+	protocol "gx/ipfs/QmZNkThpqfVXs9GNbexPrfBbXSLNYeKrE7jwFM2oqHbyqN/go-libp2p-protocol"
+	ma "gx/ipfs/QmcyqRMCAXVtYPS4DiBrA7sezL9rRGfW8Ctx7cywL4TXJj/go-multiaddr"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+var log = logging.Logger("bwprofile")
+
+// CheckInterval is how often the active profile is looked up.
+var CheckInterval = 30 * time.Second
+
+var days = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// rateUnits are the units of rates, in bytes per second.
+var rateUnits = []struct {
+	suffix string
+	bytes  float64
+}{
+	// longest suffixes first, as "bps" ends "Mbps"
+	{"kib/s", 1 << 10},
+	{"mib/s", 1 << 20},
+	{"gib/s", 1 << 30},
+	{"kbps", 1e3 / 8},
+	{"mbps", 1e6 / 8},
+	{"gbps", 1e9 / 8},
+	{"kb/s", 1e3},
+	{"mb/s", 1e6},
+	{"gb/s", 1e9},
+	{"bps", 1.0 / 8},
+	{"b/s", 1},
+}
+
+// ParseRate parses a rate such as "5Mbps" or "500KB/s" into bytes per
+// second. Units ending in "bps" count bits.
+func ParseRate(s string) (float64, error) {
+	ls := strings.ToLower(strings.TrimSpace(s))
+	for _, u := range rateUnits {
+		if !strings.HasSuffix(ls, u.suffix) {
+			continue
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(ls[:len(ls)-len(u.suffix)]), 64)
+		if err != nil || v <= 0 {
+			break
+		}
+		return v * u.bytes, nil
+	}
+	return 0, fmt.Errorf("invalid rate %q, expected a rate like 5Mbps or 500KB/s", s)
+}
+
+// parseClock parses a "15:04" time into minutes since midnight.
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected a time like 22:30", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// profile is a parsed config.BandwidthProfile.
+type profile struct {
+	name     string
+	days     map[time.Weekday]bool // nil for every day
+	start    int
+	end      int
+	rateIn   float64
+	rateOut  float64
+	maxConns int
+}
+
+func parseProfile(i int, cfg config.BandwidthProfile) (*profile, error) {
+	p := &profile{
+		name:     cfg.Name,
+		maxConns: cfg.MaxConns,
+	}
+	if p.name == "" {
+		p.name = fmt.Sprintf("#%d", i)
+	}
+
+	if len(cfg.Days) > 0 {
+		p.days = make(map[time.Weekday]bool)
+		for _, d := range cfg.Days {
+			wd, ok := days[strings.ToLower(d)]
+			if !ok {
+				return nil, fmt.Errorf("profile %s: invalid day %q", p.name, d)
+			}
+			p.days[wd] = true
+		}
+	}
+
+	var err error
+	switch {
+	case cfg.Start == "" && cfg.End == "":
+		p.start, p.end = 0, 24*60
+	case cfg.Start == "" || cfg.End == "":
+		return nil, fmt.Errorf("profile %s: Start and End go together", p.name)
+	default:
+		if p.start, err = parseClock(cfg.Start); err != nil {
+			return nil, fmt.Errorf("profile %s: %s", p.name, err)
+		}
+		if p.end, err = parseClock(cfg.End); err != nil {
+			return nil, fmt.Errorf("profile %s: %s", p.name, err)
+		}
+	}
+
+	if cfg.RateIn != "" {
+		if p.rateIn, err = ParseRate(cfg.RateIn); err != nil {
+			return nil, fmt.Errorf("profile %s: %s", p.name, err)
+		}
+	}
+	if cfg.RateOut != "" {
+		if p.rateOut, err = ParseRate(cfg.RateOut); err != nil {
+			return nil, fmt.Errorf("profile %s: %s", p.name, err)
+		}
+	}
+	return p, nil
+}
+
+func (p *profile) onDay(d time.Weekday) bool {
+	return p.days == nil || p.days[d]
+}
+
+// matches tells whether the profile applies at t.
+func (p *profile) matches(t time.Time) bool {
+	min := t.Hour()*60 + t.Minute()
+	if p.start <= p.end {
+		return p.onDay(t.Weekday()) && p.start <= min && min < p.end
+	}
+	// the window goes over midnight, and belongs to the day it starts on
+	if min >= p.start {
+		return p.onDay(t.Weekday())
+	}
+	return min < p.end && p.onDay(t.AddDate(0, 0, -1).Weekday())
+}
+
+// Scheduler applies the limits of the profile active at the time.
+type Scheduler struct {
+	profiles []*profile
+	in       *limiter
+	out      *limiter
+
+	lk     sync.Mutex
+	active *profile
+
+	// now is overridden in tests.
+	now func() time.Time
+}
+
+// New returns a Scheduler for the given profiles, with the limits of the
+// profile active now.
+func New(cfgs []config.BandwidthProfile) (*Scheduler, error) {
+	s := &Scheduler{
+		in:  newLimiter(),
+		out: newLimiter(),
+		now: time.Now,
+	}
+	for i, cfg := range cfgs {
+		p, err := parseProfile(i, cfg)
+		if err != nil {
+			return nil, err
+		}
+		s.profiles = append(s.profiles, p)
+	}
+	s.update()
+	return s, nil
+}
+
+// Active returns the name of the active profile, or "" if none is.
+func (s *Scheduler) Active() string {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	if s.active == nil {
+		return ""
+	}
+	return s.active.name
+}
+
+// update switches to the profile active now, and tells whether it changed.
+func (s *Scheduler) update() bool {
+	var next *profile
+	now := s.now()
+	for _, p := range s.profiles {
+		if p.matches(now) {
+			next = p
+			break
+		}
+	}
+
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	if next == s.active {
+		return false
+	}
+	s.active = next
+
+	if next == nil {
+		log.Info("no bandwidth profile applies, lifting the limits")
+		s.in.setRate(0)
+		s.out.setRate(0)
+		return true
+	}
+	log.Infof("switching to bandwidth profile %s", next.name)
+	s.in.setRate(next.rateIn)
+	s.out.setRate(next.rateOut)
+	return true
+}
+
+func (s *Scheduler) maxConns() int {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	if s.active == nil {
+		return 0
+	}
+	return s.active.maxConns
+}
+
+// Run switches profiles as time goes, and caps the connections of h, until
+// ctx is done.
+func (s *Scheduler) Run(ctx context.Context, h p2phost.Host) {
+	h.Network().Notify((*notifiee)(s))
+
+	t := time.NewTicker(CheckInterval)
+	defer t.Stop()
+	for {
+		if s.update() {
+			s.trim(h.Network(), nil)
+		}
+
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// trim closes connections above the cap of the active profile, the newest
+// first, after c if given.
+func (s *Scheduler) trim(n inet.Network, c inet.Conn) {
+	max := s.maxConns()
+	if max <= 0 {
+		return
+	}
+
+	conns := n.Conns()
+	excess := len(conns) - max
+	if excess <= 0 {
+		return
+	}
+
+	if c != nil {
+		log.Debugf("closing connection to %s, above the cap of %d", c.RemotePeer(), max)
+		c.Close()
+		return
+	}
+
+	log.Infof("closing %d connections above the cap of %d", excess, max)
+	for i := len(conns) - 1; i >= 0 && excess > 0; i-- {
+		conns[i].Close()
+		excess--
+	}
+}
+
+// WrapHost returns h with the bandwidth of its streams limited.
+func (s *Scheduler) WrapHost(h p2phost.Host) p2phost.Host {
+	return &host{Host: h, s: s}
+}
+
+type host struct {
+	p2phost.Host
+	s *Scheduler
+}
+
+func (h *host) NewStream(ctx context.Context, p peer.ID, pids ...protocol.ID) (inet.Stream, error) {
+	st, err := h.Host.NewStream(ctx, p, pids...)
+	if err != nil {
+		return nil, err
+	}
+	return &stream{Stream: st, s: h.s}, nil
+}
+
+func (h *host) SetStreamHandler(pid protocol.ID, handler inet.StreamHandler) {
+	h.Host.SetStreamHandler(pid, func(st inet.Stream) {
+		handler(&stream{Stream: st, s: h.s})
+	})
+}
+
+// writeChunk bounds the bytes written at once, so that large writes are
+// spread over time rather than sent in a burst after a long wait.
+const writeChunk = 16 << 10
+
+type stream struct {
+	inet.Stream
+	s *Scheduler
+}
+
+func (st *stream) Read(b []byte) (int, error) {
+	n, err := st.Stream.Read(b)
+	st.s.in.wait(n)
+	return n, err
+}
+
+func (st *stream) Write(b []byte) (int, error) {
+	written := 0
+	for len(b) > 0 {
+		chunk := b
+		if len(chunk) > writeChunk {
+			chunk = chunk[:writeChunk]
+		}
+		st.s.out.wait(len(chunk))
+		n, err := st.Stream.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		b = b[n:]
+	}
+	return written, nil
+}
+
+type notifiee Scheduler
+
+func (n *notifiee) Connected(net inet.Network, c inet.Conn) {
+	go (*Scheduler)(n).trim(net, c)
+}
+
+func (n *notifiee) Disconnected(net inet.Network, c inet.Conn)   {}
+func (n *notifiee) OpenedStream(net inet.Network, s inet.Stream) {}
+func (n *notifiee) ClosedStream(net inet.Network, s inet.Stream) {}
+func (n *notifiee) Listen(net inet.Network, a ma.Multiaddr)      {}
+func (n *notifiee) ListenClose(net inet.Network, a ma.Multiaddr) {}
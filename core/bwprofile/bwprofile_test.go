@@ -0,0 +1,112 @@
+package bwprofile
+
+import (
+	"testing"
+	"time"
+
+	config "github.com/ipfs/go-ipfs/repo/config"
+)
+
+func TestParseRate(t *testing.T) {
+	cases := map[string]float64{
+		"5Mbps":    625000,
+		"8bps":     1,
+		"500KB/s":  500000,
+		"1 MiB/s":  1 << 20,
+		"1.5GB/s":  1.5e9,
+		"100kbps":  12500,
+		"2 B/s":    2,
+		"":         -1,
+		"5":        -1,
+		"fastMbps": -1,
+		"-1MB/s":   -1,
+	}
+	for s, expected := range cases {
+		v, err := ParseRate(s)
+		if expected < 0 {
+			if err == nil {
+				t.Errorf("%q: expected an error, got %f", s, v)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %s", s, err)
+			continue
+		}
+		if v != expected {
+			t.Errorf("%q: expected %f, got %f", s, expected, v)
+		}
+	}
+}
+
+func TestActiveProfile(t *testing.T) {
+	s, err := New([]config.BandwidthProfile{
+		{Name: "night", Days: []string{"fri"}, Start: "22:00", End: "06:00"},
+		{Name: "workday", Days: []string{"mon", "tue", "wed", "thu", "fri"}, Start: "09:00", End: "18:00", RateIn: "5Mbps", MaxConns: 100},
+		{Name: "weekend", Days: []string{"sat", "sun"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 2017-06-05 is a monday
+	cases := map[string]string{
+		"2017-06-05 08:59": "",
+		"2017-06-05 09:00": "workday",
+		"2017-06-05 17:59": "workday",
+		"2017-06-05 18:00": "",
+		"2017-06-09 23:00": "night",
+		"2017-06-10 05:59": "night",
+		"2017-06-10 06:00": "weekend",
+		"2017-06-11 03:00": "weekend",
+		"2017-06-06 03:00": "",
+	}
+	for at, expected := range cases {
+		now, err := time.ParseInLocation("2006-01-02 15:04", at, time.Local)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.now = func() time.Time { return now }
+		s.update()
+		if s.Active() != expected {
+			t.Errorf("%s: expected profile %q, got %q", at, expected, s.Active())
+		}
+	}
+}
+
+func TestInvalidProfiles(t *testing.T) {
+	for _, p := range []config.BandwidthProfile{
+		{Days: []string{"monday"}},
+		{Start: "09:00"},
+		{Start: "9am", End: "18:00"},
+		{RateOut: "a lot"},
+	} {
+		if _, err := New([]config.BandwidthProfile{p}); err == nil {
+			t.Errorf("expected an error for %+v", p)
+		}
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	l := newLimiter()
+	var slept time.Duration
+	l.sleep = func(d time.Duration) { slept += d }
+
+	l.wait(1 << 20)
+	if slept != 0 {
+		t.Fatalf("expected no wait without a rate, waited %s", slept)
+	}
+
+	l.setRate(1000)
+	l.wait(3000)
+	if slept < 2900*time.Millisecond || slept > 3100*time.Millisecond {
+		t.Fatalf("expected to wait about 3s, waited %s", slept)
+	}
+
+	l.setRate(0)
+	slept = 0
+	l.wait(1 << 20)
+	if slept != 0 {
+		t.Fatalf("expected no wait once the rate is lifted, waited %s", slept)
+	}
+}
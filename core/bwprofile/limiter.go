@@ -0,0 +1,74 @@
+package bwprofile
+
+import (
+	"sync"
+	"time"
+)
+
+// maxBurst bounds the bytes a limiter lets through at once after being idle,
+// in seconds of its rate.
+const maxBurst = 1.0
+
+// limiter is a token bucket shared by all the streams of one direction. It
+// goes into debt rather than splitting transfers: the bytes of a transfer go
+// through at once, and the next transfers wait for the debt to be paid off.
+type limiter struct {
+	mu     sync.Mutex
+	rate   float64 // bytes per second, 0 for no limit
+	tokens float64
+	last   time.Time
+
+	// sleep is overridden in tests.
+	sleep func(time.Duration)
+}
+
+func newLimiter() *limiter {
+	return &limiter{
+		last:  time.Now(),
+		sleep: time.Sleep,
+	}
+}
+
+func (l *limiter) setRate(rate float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill(time.Now())
+	l.rate = rate
+	if l.tokens > rate*maxBurst {
+		l.tokens = rate * maxBurst
+	}
+}
+
+func (l *limiter) refill(now time.Time) {
+	if l.rate > 0 {
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		if l.tokens > l.rate*maxBurst {
+			l.tokens = l.rate * maxBurst
+		}
+	}
+	l.last = now
+}
+
+// wait charges n bytes, and blocks until the debt they leave is paid off.
+func (l *limiter) wait(n int) {
+	if n <= 0 {
+		return
+	}
+
+	l.mu.Lock()
+	if l.rate <= 0 {
+		l.mu.Unlock()
+		return
+	}
+	l.refill(time.Now())
+	l.tokens -= float64(n)
+	var d time.Duration
+	if l.tokens < 0 {
+		d = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	if d > 0 {
+		l.sleep(d)
+	}
+}
@@ -0,0 +1,304 @@
+package bwprofile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	config "github.com/ipfs/go-ipfs/repo/config"
+
+	inet "gx/ipfs/QmRscs8KxrSmSv4iuevHv8JfuUzHBMoqiaHzxfDRiksd6e/go-libp2p-net"
+	p2phost "gx/ipfs/QmUywuGNZoUKV8B9iyvup9bPkLiMrhTsyVMkeSXW5VxAfC/go-libp2p-host"
+	protocol "gx/ipfs/QmZNkThpqfVXs9GNbexPrfBbXSLNYeKrE7jwFM2oqHbyqN/go-libp2p-protocol"
+	ma "gx/ipfs/QmcyqRMCAXVtYPS4DiBrA7sezL9rRGfW8Ctx7cywL4TXJj/go-multiaddr"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+// protocolAliases are the prefixes of the protocols of the subsystems.
+var protocolAliases = map[string]string{
+	"bitswap": "/ipfs/bitswap",
+	"dht":     "/ipfs/kad/",
+	"pubsub":  "/floodsub/",
+}
+
+// ErrTooManyStreams is returned when opening a stream above the cap of its
+// protocol.
+var ErrTooManyStreams = errors.New("too many streams of the protocol")
+
+// ProtocolStat is the use of a protocol limit.
+type ProtocolStat struct {
+	Protocol   string
+	Streams    int
+	MaxStreams int    `json:",omitempty"`
+	Refused    uint64 // streams refused since the start
+
+	TotalIn  uint64
+	TotalOut uint64
+	RateIn   float64 // bytes per second, over the last second
+	RateOut  float64
+	LimitIn  float64 `json:",omitempty"`
+	LimitOut float64 `json:",omitempty"`
+}
+
+// protoLimit is a parsed config.ProtocolLimit and its use.
+type protoLimit struct {
+	name       string
+	prefix     string
+	maxStreams int
+	rateIn     float64
+	rateOut    float64
+	in         *limiter
+	out        *limiter
+
+	totalIn  uint64 // atomic
+	totalOut uint64 // atomic
+
+	// under ProtocolLimits.lk
+	open            int
+	refused         uint64
+	sampleIn        float64
+	sampleOut       float64
+	lastIn, lastOut uint64
+}
+
+// ProtocolLimits caps the streams and bandwidth of protocols.
+type ProtocolLimits struct {
+	limits []*protoLimit
+
+	lk      sync.Mutex
+	streams map[inet.Stream]*protoStream // by the stream wrapped
+	sampled time.Time
+}
+
+// NewProtocolLimits returns the limits of the given config.
+func NewProtocolLimits(cfgs []config.ProtocolLimit) (*ProtocolLimits, error) {
+	l := &ProtocolLimits{
+		streams: make(map[inet.Stream]*protoStream),
+		sampled: time.Now(),
+	}
+	for _, cfg := range cfgs {
+		pl := &protoLimit{
+			name:       cfg.Protocol,
+			prefix:     cfg.Protocol,
+			maxStreams: cfg.MaxStreams,
+			in:         newLimiter(),
+			out:        newLimiter(),
+		}
+		if alias, ok := protocolAliases[cfg.Protocol]; ok {
+			pl.prefix = alias
+		}
+		if !strings.HasPrefix(pl.prefix, "/") {
+			return nil, fmt.Errorf("protocol limit %q: expected a protocol ID prefix, or one of bitswap, dht and pubsub", cfg.Protocol)
+		}
+		if cfg.MaxStreams < 0 {
+			return nil, fmt.Errorf("protocol limit %s: MaxStreams can't be negative", cfg.Protocol)
+		}
+
+		var err error
+		if cfg.RateIn != "" {
+			if pl.rateIn, err = ParseRate(cfg.RateIn); err != nil {
+				return nil, fmt.Errorf("protocol limit %s: %s", cfg.Protocol, err)
+			}
+			pl.in.setRate(pl.rateIn)
+		}
+		if cfg.RateOut != "" {
+			if pl.rateOut, err = ParseRate(cfg.RateOut); err != nil {
+				return nil, fmt.Errorf("protocol limit %s: %s", cfg.Protocol, err)
+			}
+			pl.out.setRate(pl.rateOut)
+		}
+		l.limits = append(l.limits, pl)
+	}
+	return l, nil
+}
+
+// match returns the first limit of pid, or nil if none applies.
+func (l *ProtocolLimits) match(pid protocol.ID) *protoLimit {
+	for _, pl := range l.limits {
+		if strings.HasPrefix(string(pid), pl.prefix) {
+			return pl
+		}
+	}
+	return nil
+}
+
+// open counts a new stream of pl, and wraps it, or returns nil if it is
+// above the cap.
+func (l *ProtocolLimits) open(pl *protoLimit, st inet.Stream) *protoStream {
+	l.lk.Lock()
+	defer l.lk.Unlock()
+	if pl.maxStreams > 0 && pl.open >= pl.maxStreams {
+		pl.refused++
+		return nil
+	}
+	pl.open++
+	ps := &protoStream{Stream: st, l: l, pl: pl}
+	l.streams[st] = ps
+	return ps
+}
+
+// release uncounts the stream st, once.
+func (l *ProtocolLimits) release(st inet.Stream) {
+	l.lk.Lock()
+	defer l.lk.Unlock()
+	ps, ok := l.streams[st]
+	if !ok {
+		return
+	}
+	delete(l.streams, st)
+	ps.pl.open--
+}
+
+// Stats returns the use of the limits, in the order of the config.
+func (l *ProtocolLimits) Stats() []ProtocolStat {
+	l.lk.Lock()
+	defer l.lk.Unlock()
+	stats := make([]ProtocolStat, 0, len(l.limits))
+	for _, pl := range l.limits {
+		stats = append(stats, ProtocolStat{
+			Protocol:   pl.name,
+			Streams:    pl.open,
+			MaxStreams: pl.maxStreams,
+			Refused:    pl.refused,
+			TotalIn:    atomic.LoadUint64(&pl.totalIn),
+			TotalOut:   atomic.LoadUint64(&pl.totalOut),
+			RateIn:     pl.sampleIn,
+			RateOut:    pl.sampleOut,
+			LimitIn:    pl.rateIn,
+			LimitOut:   pl.rateOut,
+		})
+	}
+	return stats
+}
+
+// sample updates the rates of the limits.
+func (l *ProtocolLimits) sample() {
+	l.lk.Lock()
+	defer l.lk.Unlock()
+	now := time.Now()
+	secs := now.Sub(l.sampled).Seconds()
+	if secs <= 0 {
+		return
+	}
+	l.sampled = now
+	for _, pl := range l.limits {
+		in, out := atomic.LoadUint64(&pl.totalIn), atomic.LoadUint64(&pl.totalOut)
+		pl.sampleIn = float64(in-pl.lastIn) / secs
+		pl.sampleOut = float64(out-pl.lastOut) / secs
+		pl.lastIn, pl.lastOut = in, out
+	}
+}
+
+// Run measures the rates of the limits, and forgets the streams closed with
+// their connections, until ctx is done.
+func (l *ProtocolLimits) Run(ctx context.Context, h p2phost.Host) {
+	h.Network().Notify((*protoNotifiee)(l))
+
+	t := time.NewTicker(time.Second)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			l.sample()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// WrapHost returns h with the streams of its protocols limited.
+func (l *ProtocolLimits) WrapHost(h p2phost.Host) p2phost.Host {
+	return &protoHost{Host: h, l: l}
+}
+
+type protoHost struct {
+	p2phost.Host
+	l *ProtocolLimits
+}
+
+func (h *protoHost) NewStream(ctx context.Context, p peer.ID, pids ...protocol.ID) (inet.Stream, error) {
+	st, err := h.Host.NewStream(ctx, p, pids...)
+	if err != nil {
+		return nil, err
+	}
+	pl := h.l.match(st.Protocol())
+	if pl == nil {
+		return st, nil
+	}
+	ps := h.l.open(pl, st)
+	if ps == nil {
+		st.Close()
+		return nil, fmt.Errorf("%s: %s", ErrTooManyStreams, st.Protocol())
+	}
+	return ps, nil
+}
+
+func (h *protoHost) SetStreamHandler(pid protocol.ID, handler inet.StreamHandler) {
+	pl := h.l.match(pid)
+	if pl == nil {
+		h.Host.SetStreamHandler(pid, handler)
+		return
+	}
+	h.Host.SetStreamHandler(pid, func(st inet.Stream) {
+		ps := h.l.open(pl, st)
+		if ps == nil {
+			log.Debugf("refusing a %s stream from %s, above the cap of %d", pid, st.Conn().RemotePeer(), pl.maxStreams)
+			st.Close()
+			return
+		}
+		handler(ps)
+	})
+}
+
+type protoStream struct {
+	inet.Stream
+	l  *ProtocolLimits
+	pl *protoLimit
+}
+
+func (st *protoStream) Read(b []byte) (int, error) {
+	n, err := st.Stream.Read(b)
+	atomic.AddUint64(&st.pl.totalIn, uint64(n))
+	st.pl.in.wait(n)
+	return n, err
+}
+
+func (st *protoStream) Write(b []byte) (int, error) {
+	written := 0
+	for len(b) > 0 {
+		chunk := b
+		if len(chunk) > writeChunk {
+			chunk = chunk[:writeChunk]
+		}
+		st.pl.out.wait(len(chunk))
+		n, err := st.Stream.Write(chunk)
+		written += n
+		atomic.AddUint64(&st.pl.totalOut, uint64(n))
+		if err != nil {
+			return written, err
+		}
+		b = b[n:]
+	}
+	return written, nil
+}
+
+func (st *protoStream) Close() error {
+	st.l.release(st.Stream)
+	return st.Stream.Close()
+}
+
+type protoNotifiee ProtocolLimits
+
+func (n *protoNotifiee) ClosedStream(net inet.Network, s inet.Stream) {
+	(*ProtocolLimits)(n).release(s)
+}
+
+func (n *protoNotifiee) Connected(net inet.Network, c inet.Conn)      {}
+func (n *protoNotifiee) Disconnected(net inet.Network, c inet.Conn)   {}
+func (n *protoNotifiee) OpenedStream(net inet.Network, s inet.Stream) {}
+func (n *protoNotifiee) Listen(net inet.Network, a ma.Multiaddr)      {}
+func (n *protoNotifiee) ListenClose(net inet.Network, a ma.Multiaddr) {}
@@ -0,0 +1,74 @@
+package bwprofile
+
+import (
+	"testing"
+
+	config "github.com/ipfs/go-ipfs/repo/config"
+
+	inet "gx/ipfs/QmRscs8KxrSmSv4iuevHv8JfuUzHBMoqiaHzxfDRiksd6e/go-libp2p-net"
+	protocol "gx/ipfs/QmZNkThpqfVXs9GNbexPrfBbXSLNYeKrE7jwFM2oqHbyqN/go-libp2p-protocol"
+)
+
+type fakeStream struct {
+	inet.Stream
+}
+
+func TestProtocolLimits(t *testing.T) {
+	for _, cfg := range [][]config.ProtocolLimit{
+		{{Protocol: "kad"}},
+		{{Protocol: "dht", MaxStreams: -1}},
+		{{Protocol: "dht", RateIn: "fast"}},
+	} {
+		if _, err := NewProtocolLimits(cfg); err == nil {
+			t.Errorf("expected %+v to be refused", cfg)
+		}
+	}
+
+	l, err := NewProtocolLimits([]config.ProtocolLimit{
+		{Protocol: "dht", MaxStreams: 2, RateOut: "1Mbps"},
+		{Protocol: "/ipfs/"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]string{
+		"/ipfs/kad/1.0.0":     "dht",
+		"/ipfs/bitswap/1.1.0": "/ipfs/",
+		"/floodsub/1.0.0":     "",
+	}
+	for pid, expected := range cases {
+		pl := l.match(protocol.ID(pid))
+		switch {
+		case pl == nil && expected != "":
+			t.Errorf("%s: expected the limit %s, got none", pid, expected)
+		case pl != nil && pl.name != expected:
+			t.Errorf("%s: expected the limit %q, got %s", pid, expected, pl.name)
+		}
+	}
+
+	dht := l.match("/ipfs/kad/1.0.0")
+	a, b, c := &fakeStream{}, &fakeStream{}, &fakeStream{}
+	if l.open(dht, a) == nil || l.open(dht, b) == nil {
+		t.Fatal("expected the streams under the cap to be opened")
+	}
+	if l.open(dht, c) != nil {
+		t.Fatal("expected the stream above the cap to be refused")
+	}
+
+	// a stream closed and then reported closed by the network counts once
+	l.release(a)
+	l.release(a)
+	if l.open(dht, c) == nil {
+		t.Fatal("expected a stream to be opened once another is closed")
+	}
+
+	stats := l.Stats()
+	if len(stats) != 2 {
+		t.Fatalf("expected the stats of 2 limits, got %d", len(stats))
+	}
+	s := stats[0]
+	if s.Protocol != "dht" || s.Streams != 2 || s.MaxStreams != 2 || s.Refused != 1 || s.LimitOut != 125000 {
+		t.Fatalf("unexpected stats %+v", s)
+	}
+}
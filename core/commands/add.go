@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"path/filepath"
 	"strings"
 
 	bstore "github.com/ipfs/go-ipfs/blocks/blockstore"
@@ -20,6 +21,7 @@ import (
 
 	mh "gx/ipfs/QmVGtdTZdTFaLsaj2RwdVG8jcjNNcp1DE914DKZ2kHmXHw/go-multihash"
 	u "gx/ipfs/QmWbjfz3u6HkAdPh34dgPchGbQjob6LXLhAeCGii2TX69n/go-ipfs-util"
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
 	"gx/ipfs/QmeWjRodbcZFKe5tMN7poEx3izym6osrLSnTLf9UjJZBbs/pb"
 )
 
@@ -27,21 +29,27 @@ import (
 var ErrDepthLimitExceeded = fmt.Errorf("depth limit exceeded")
 
 const (
-	quietOptionName       = "quiet"
-	quieterOptionName     = "quieter"
-	silentOptionName      = "silent"
-	progressOptionName    = "progress"
-	trickleOptionName     = "trickle"
-	wrapOptionName        = "wrap-with-directory"
-	hiddenOptionName      = "hidden"
-	onlyHashOptionName    = "only-hash"
-	chunkerOptionName     = "chunker"
-	pinOptionName         = "pin"
-	rawLeavesOptionName   = "raw-leaves"
-	noCopyOptionName      = "nocopy"
-	fstoreCacheOptionName = "fscache"
-	cidVersionOptionName  = "cid-version"
-	hashOptionName        = "hash"
+	quietOptionName        = "quiet"
+	quieterOptionName      = "quieter"
+	silentOptionName       = "silent"
+	progressOptionName     = "progress"
+	trickleOptionName      = "trickle"
+	wrapOptionName         = "wrap-with-directory"
+	hiddenOptionName       = "hidden"
+	onlyHashOptionName     = "only-hash"
+	chunkerOptionName      = "chunker"
+	pinOptionName          = "pin"
+	rawLeavesOptionName    = "raw-leaves"
+	noCopyOptionName       = "nocopy"
+	fstoreCacheOptionName  = "fscache"
+	cidVersionOptionName   = "cid-version"
+	hashOptionName         = "hash"
+	reproducibleOptionName = "reproducible"
+	profileOptionName      = "profile"
+	expectOptionName       = "expect"
+	fromURLOptionName      = "from-url"
+	linkModeOptionName     = "link-mode"
+	followLinksOptionName  = "follow-symlinks"
 )
 
 const adderOutChanSize = 8
@@ -71,11 +79,57 @@ its filename. For example:
 You can now refer to the added file in a gateway, like so:
 
   /ipfs/QmaG4FuMqEBnQNn3C8XJ5bpW8kLs7zq2ZXgHptJHbKDDVx/example.jpg
+
+The '--reproducible' option records every parameter which affects the
+resulting hashes (chunker, layout, cid version, raw leaves, hash function,
+wrapping and hidden files) into a profile, printed on stderr. Passing it
+back with '--profile' on another node or a later version reproduces the
+same hashes, and '--expect' fails the add unless the root hash matches:
+
+  > ipfs add --reproducible example.jpg
+  reproducible profile: v=1,chunker=size-262144,layout=balanced,cid-version=0,raw-leaves=false,hash=sha2-256,wrap=false,hidden=false
+  added QmbFMke1KXqnYyBBWxB74N4c5SBnJMVAiMNRcGu6x1AwQH example.jpg
+  > ipfs add --profile=<profile> --expect=QmbFMke1KXqnYyBBWxB74N4c5SBnJMVAiMNRcGu6x1AwQH example.jpg
+
+The '--from-url' option adds the content of a URL instead of files. The
+daemon fetches the URL itself and adds it as it comes, so the content never
+goes through the client. The file is named after the last element of the
+path of the URL. The schemes of the URLs fetched, their maximal size, the
+timeout of the fetches and whether private addresses are denied are set by
+the Import section of the config. Files, or content piped to stdin, cannot
+be added along with a URL:
+
+  > ipfs add --from-url=https://example.com/example.jpg
+  added QmbFMke1KXqnYyBBWxB74N4c5SBnJMVAiMNRcGu6x1AwQH example.jpg
+
+The '--link-mode' option tells how the data of the files gets to the repo:
+
+  copy     the data is copied into the blockstore (default).
+  nocopy   the data is referenced in the filestore, as with '--nocopy'.
+  reflink  the files are cloned into the repo and the clones are referenced
+           in the filestore. On filesystems where clones share their data
+           until it is modified, such as btrfs and XFS, no data is copied,
+           and later changes of the files don't affect the repo. The repo
+           and the files must be on the same filesystem.
+  auto     as reflink for the files which can be cloned, as copy for the
+           others.
+
+The modes other than copy need the filestore to be enabled, and raw leaves.
+Cloning files is only supported on Linux.
+
+Symlinks are added as links, keeping their targets, which 'ipfs get'
+recreates. With '--follow-symlinks', the files and directories they point to
+are added in their place; a link pointing back to one of the directories it
+is in fails the add.
+
+The runs of zeros of the files, such as the holes of sparse files and the
+free space of the images of disks, are stored once whatever their length:
+their chunks share a single block. 'ipfs get --sparse' leaves them as holes.
 `,
 	},
 
 	Arguments: []cmds.Argument{
-		cmds.FileArg("path", true, true, "The path to a file to be added to ipfs.").EnableRecursive().EnableStdin(),
+		cmds.FileArg("path", true, true, "The path to a file to be added to ipfs.").EnableRecursive().EnableStdin().MakeOptionalWith(fromURLOptionName),
 	},
 	Options: []cmds.Option{
 		cmds.OptionRecursivePath, // a builtin option that allows recursive paths (-r, --recursive)
@@ -87,6 +141,7 @@ You can now refer to the added file in a gateway, like so:
 		cmds.BoolOption(onlyHashOptionName, "n", "Only chunk and hash - do not write to disk."),
 		cmds.BoolOption(wrapOptionName, "w", "Wrap files with a directory object."),
 		cmds.BoolOption(hiddenOptionName, "H", "Include files that are hidden. Only takes effect on recursive add."),
+		cmds.BoolOption(followLinksOptionName, "Add the files symlinks point to instead of the links."),
 		cmds.StringOption(chunkerOptionName, "s", "Chunking algorithm to use."),
 		cmds.BoolOption(pinOptionName, "Pin this object when adding.").Default(true),
 		cmds.BoolOption(rawLeavesOptionName, "Use raw blocks for leaf nodes. (experimental)"),
@@ -94,6 +149,11 @@ You can now refer to the added file in a gateway, like so:
 		cmds.BoolOption(fstoreCacheOptionName, "Check the filestore for pre-existing blocks. (experimental)"),
 		cmds.IntOption(cidVersionOptionName, "Cid version. Non-zero value will change default of 'raw-leaves' to true. (experimental)").Default(0),
 		cmds.StringOption(hashOptionName, "Hash function to use. Will set Cid version to 1 if used. (experimental)").Default("sha2-256"),
+		cmds.BoolOption(reproducibleOptionName, "Print the profile of all parameters affecting the resulting hashes."),
+		cmds.StringOption(profileOptionName, "Add with the parameters of a recorded profile. Implies --reproducible."),
+		cmds.StringOption(expectOptionName, "Fail unless the root hash matches. Implies --reproducible."),
+		cmds.StringOption(fromURLOptionName, "Add the content of a URL, fetched by the daemon, instead of files."),
+		cmds.StringOption(linkModeOptionName, "How the data of the files gets to the repo: copy, nocopy, reflink or auto. (experimental)"),
 	},
 	PreRun: func(req cmds.Request) error {
 		quiet, _, _ := req.Option(quietOptionName).Bool()
@@ -112,6 +172,11 @@ You can now refer to the added file in a gateway, like so:
 			req.SetOption(progressOptionName, true)
 		}
 
+		// the daemon sends the size of the URLs it fetches
+		if req.Option(fromURLOptionName).Found() {
+			return nil
+		}
+
 		sizeFile, ok := req.Files().(files.SizeFile)
 		if !ok {
 			// we don't need to error, the progress bar just won't know how big the files are
@@ -169,6 +234,64 @@ You can now refer to the added file in a gateway, like so:
 		fscache, _, _ := req.Option(fstoreCacheOptionName).Bool()
 		cidVer, _, _ := req.Option(cidVersionOptionName).Int()
 		hashFunStr, hfset, _ := req.Option(hashOptionName).String()
+		reproducible, _, _ := req.Option(reproducibleOptionName).Bool()
+		profileStr, profileSet, _ := req.Option(profileOptionName).String()
+		expectStr, expectSet, _ := req.Option(expectOptionName).String()
+		fromURL, fromURLSet, _ := req.Option(fromURLOptionName).String()
+		linkMode, linkModeSet, _ := req.Option(linkModeOptionName).String()
+		reproducible = reproducible || profileSet || expectSet
+
+		if profileSet {
+			for _, opt := range []string{chunkerOptionName, trickleOptionName, rawLeavesOptionName,
+				cidVersionOptionName, hashOptionName, wrapOptionName, hiddenOptionName} {
+				if req.Option(opt).Found() {
+					res.SetError(fmt.Errorf("option '%s' cannot be combined with '%s'", opt, profileOptionName), cmds.ErrClient)
+					return
+				}
+			}
+
+			profile, err := coreunix.ParseAddProfile(profileStr)
+			if err != nil {
+				res.SetError(err, cmds.ErrClient)
+				return
+			}
+
+			chunker = profile.Chunker
+			trickle = profile.Layout == coreunix.LayoutTrickle
+			rawblks, rbset = profile.RawLeaves, true
+			cidVer = profile.CidVersion
+			hashFunStr, hfset = profile.Hash, false
+			wrap = profile.Wrap
+			hidden = profile.Hidden
+		}
+
+		var expect *cid.Cid
+		if expectSet {
+			expect, err = cid.Decode(expectStr)
+			if err != nil {
+				res.SetError(fmt.Errorf("invalid expected hash: %s", err), cmds.ErrClient)
+				return
+			}
+		}
+
+		if !linkModeSet {
+			linkMode = coreunix.LinkCopy
+			if nocopy {
+				linkMode = coreunix.LinkNoCopy
+			}
+		} else if nocopy && linkMode != coreunix.LinkNoCopy {
+			res.SetError(fmt.Errorf("option '%s' cannot be combined with '%s=%s'", noCopyOptionName, linkModeOptionName, linkMode), cmds.ErrClient)
+			return
+		}
+		switch linkMode {
+		case coreunix.LinkCopy:
+		case coreunix.LinkNoCopy, coreunix.LinkReflink, coreunix.LinkAuto:
+			// the clones are referenced in the filestore as well
+			nocopy = true
+		default:
+			res.SetError(fmt.Errorf("unknown link mode %q, expected copy, nocopy, reflink or auto", linkMode), cmds.ErrClient)
+			return
+		}
 
 		if nocopy && !cfg.Experimental.FilestoreEnabled {
 			res.SetError(errors.New("filestore is not enabled, see https://git.io/vy4XN"),
@@ -176,6 +299,28 @@ You can now refer to the added file in a gateway, like so:
 			return
 		}
 
+		if nocopy && fromURLSet {
+			res.SetError(fmt.Errorf("link mode %s cannot be combined with '%s'", linkMode, fromURLOptionName), cmds.ErrClient)
+			return
+		}
+
+		var fetcher *coreunix.URLFetcher
+		if fromURLSet {
+			if req.Files() != nil {
+				res.SetError(fmt.Errorf("files cannot be added along with '%s'", fromURLOptionName), cmds.ErrClient)
+				return
+			}
+
+			fetcher, err = coreunix.NewURLFetcher(cfg.Import)
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+		} else if req.Files() == nil {
+			res.SetError(errors.New("no files to add"), cmds.ErrClient)
+			return
+		}
+
 		if nocopy && !rbset {
 			rawblks = true
 		}
@@ -208,6 +353,27 @@ You can now refer to the added file in a gateway, like so:
 		prefix.MhType = hashFunCode
 		prefix.MhLength = -1
 
+		var profile coreunix.AddProfile
+		if reproducible {
+			profile = coreunix.AddProfile{
+				Chunker:    chunker,
+				Layout:     coreunix.LayoutBalanced,
+				CidVersion: cidVer,
+				RawLeaves:  rawblks,
+				Hash:       hashFunStr,
+				Wrap:       wrap,
+				Hidden:     hidden,
+			}
+			if trickle {
+				profile.Layout = coreunix.LayoutTrickle
+			}
+			if err := profile.Normalize(); err != nil {
+				res.SetError(err, cmds.ErrClient)
+				return
+			}
+			chunker = profile.Chunker
+		}
+
 		if hash {
 			nilnode, err := core.NewNode(n.Context(), &core.BuildCfg{
 				//TODO: need this to be true or all files
@@ -241,6 +407,26 @@ You can now refer to the added file in a gateway, like so:
 			return
 		}
 
+		input := req.Files()
+		var fetched files.File
+		var inputSize int64 = -1
+		if fromURLSet {
+			// the URL is fetched as it is added
+			fetched, inputSize, err = fetcher.Fetch(req.Context(), fromURL)
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+			input = files.NewSliceFile("", "", []files.File{fetched})
+		}
+		if (linkMode == coreunix.LinkReflink || linkMode == coreunix.LinkAuto) && !hash {
+			reflinker := &coreunix.Reflinker{
+				Dir:      filepath.Join(req.InvocContext().ConfigRoot, coreunix.ReflinkDir),
+				Fallback: linkMode == coreunix.LinkAuto,
+			}
+			input = reflinker.Wrap(input)
+		}
+
 		outChan := make(chan interface{}, adderOutChanSize)
 		res.SetOutput((<-chan interface{})(outChan))
 
@@ -252,6 +438,10 @@ You can now refer to the added file in a gateway, like so:
 		fileAdder.Wrap = wrap
 		fileAdder.Pin = dopin
 		fileAdder.Silent = silent
+		if !hash {
+			fileAdder.Repo = n.Repo
+			fileAdder.Events = n.Events
+		}
 		fileAdder.RawLeaves = rawblks
 		fileAdder.NoCopy = nocopy
 		fileAdder.Prefix = &prefix
@@ -285,11 +475,15 @@ You can now refer to the added file in a gateway, like so:
 			}
 
 			// copy intermediary nodes from editor to our actual dagservice
-			_, err := fileAdder.Finalize()
+			root, err := fileAdder.Finalize()
 			if err != nil {
 				return err
 			}
 
+			if expect != nil && !root.Cid().Equals(expect) {
+				return fmt.Errorf("root hash %s does not match the expected %s", root.Cid(), expect)
+			}
+
 			if hash {
 				return nil
 			}
@@ -299,7 +493,16 @@ You can now refer to the added file in a gateway, like so:
 
 		go func() {
 			defer close(outChan)
-			if err := addAllAndPin(req.Files()); err != nil {
+			if fetched != nil {
+				defer fetched.Close()
+			}
+			if reproducible {
+				outChan <- &coreunix.AddedObject{Profile: profile.String()}
+			}
+			if progress && inputSize >= 0 {
+				outChan <- &coreunix.AddedObject{Total: inputSize}
+			}
+			if err := addAllAndPin(input); err != nil {
 				res.SetError(err, cmds.ErrNormal)
 				return
 			}
@@ -354,6 +557,19 @@ You can now refer to the added file in a gateway, like so:
 					break LOOP
 				}
 				output := out.(*coreunix.AddedObject)
+				if len(output.Profile) > 0 {
+					fmt.Fprintf(res.Stderr(), "reproducible profile: %s\n", output.Profile)
+					continue
+				}
+				if output.Total > 0 {
+					if progress {
+						bar.Total = output.Total
+						bar.ShowPercent = true
+						bar.ShowBar = true
+						bar.ShowTimeLeft = true
+					}
+					continue
+				}
 				if len(output.Hash) > 0 {
 					lastHash = output.Hash
 					if quieter {
@@ -0,0 +1,134 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	core "github.com/ipfs/go-ipfs/core"
+	corerepo "github.com/ipfs/go-ipfs/core/corerepo"
+	path "github.com/ipfs/go-ipfs/path"
+)
+
+var AvailabilityCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Check whether other peers can fetch content.",
+		ShortDescription: `
+'ipfs availability' finds the providers of the root of <ipfs-path> in the
+routing system, and asks each of them alone for the root, and for blocks
+picked at random at each depth under it, down to --depth, --samples blocks a
+level. It lists the providers which sent the blocks, and the score of the
+content: 1 when each block sampled was sent by 3 providers or more, 0 when
+none was sent at all.
+
+The dag is read locally, as published, where the blocks are; the providers
+are asked one after the other, and have --timeout to send the blocks.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("ipfs-path", true, false, "The path to the content to probe.").EnableStdin(),
+	},
+	Options: []cmds.Option{
+		cmds.IntOption("num-providers", "n", "The number of providers to probe.").Default(5),
+		cmds.IntOption("depth", "Depth of the blocks sampled under the root.").Default(2),
+		cmds.IntOption("samples", "Number of blocks sampled at each depth.").Default(3),
+		cmds.StringOption("timeout", "Time a provider has to send the blocks.").Default("10s"),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		if !n.OnlineMode() {
+			res.SetError(errNotOnline, cmds.ErrClient)
+			return
+		}
+
+		var opts corerepo.ProbeOptions
+		opts.Providers, _, err = req.Option("num-providers").Int()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		if opts.Providers < 1 {
+			res.SetError(fmt.Errorf("the number of providers must be greater than 0"), cmds.ErrClient)
+			return
+		}
+		opts.Depth, _, err = req.Option("depth").Int()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		opts.Samples, _, err = req.Option("samples").Int()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		if opts.Depth < 0 || opts.Samples < 1 {
+			res.SetError(fmt.Errorf("--depth must not be negative, and --samples must be greater than 0"), cmds.ErrClient)
+			return
+		}
+		timeout, _, err := req.Option("timeout").String()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		opts.Timeout, err = time.ParseDuration(timeout)
+		if err != nil {
+			res.SetError(fmt.Errorf("invalid --timeout: %s", err), cmds.ErrClient)
+			return
+		}
+
+		p, err := path.ParsePath(req.Arguments()[0])
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		nd, err := core.Resolve(req.Context(), n.Namesys, n.Resolver, p)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		a, err := corerepo.ProbeAvailability(req.Context(), n, nd.Cid(), opts)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		res.SetOutput(a)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			a, ok := res.Output().(*corerepo.Availability)
+			if !ok {
+				return nil, fmt.Errorf("expected an Availability as command result")
+			}
+
+			buf := new(bytes.Buffer)
+			fmt.Fprintf(buf, "score: %.2f\n", a.Score)
+			fmt.Fprintf(buf, "providers: %d responsive of %d found\n", a.Responsive(), len(a.Providers))
+			w := tabwriter.NewWriter(buf, 1, 2, 1, ' ', 0)
+			for _, p := range a.Providers {
+				if p.Fetched == 0 {
+					fmt.Fprintf(w, "  %s\t0/%d blocks\tno answer\n", p.ID, len(a.Samples))
+					continue
+				}
+				fmt.Fprintf(w, "  %s\t%d/%d blocks\t%s\n", p.ID, p.Fetched, len(a.Samples), p.Latency)
+			}
+			w.Flush()
+			fmt.Fprintln(buf, "blocks:")
+			w = tabwriter.NewWriter(buf, 1, 2, 1, ' ', 0)
+			for _, s := range a.Samples {
+				fmt.Fprintf(w, "  %s\tdepth %d\tsent by %d\n", s.Cid, s.Depth, s.Providers)
+			}
+			w.Flush()
+			return buf, nil
+		},
+	},
+	Type: corerepo.Availability{},
+}
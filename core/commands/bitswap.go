@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"sort"
 
 	cmds "github.com/ipfs/go-ipfs/commands"
 	bitswap "github.com/ipfs/go-ipfs/exchange/bitswap"
@@ -158,6 +159,7 @@ var bitswapStatCmd = &cmds.Command{
 			buf := new(bytes.Buffer)
 			fmt.Fprintln(buf, "bitswap status")
 			fmt.Fprintf(buf, "\tprovides buffer: %d / %d\n", out.ProvideBufLen, bitswap.HasBlockBufferSize)
+			fmt.Fprintf(buf, "\tprovides pending: %d\n", out.ProvideQueueLen)
 			fmt.Fprintf(buf, "\tblocks received: %d\n", out.BlocksReceived)
 			fmt.Fprintf(buf, "\tblocks sent: %d\n", out.BlocksSent)
 			fmt.Fprintf(buf, "\tdata received: %d\n", out.DataReceived)
@@ -172,6 +174,17 @@ var bitswapStatCmd = &cmds.Command{
 			for _, p := range out.Peers {
 				fmt.Fprintf(buf, "\t\t%s\n", p)
 			}
+			if len(out.VerifyFailures) > 0 {
+				fmt.Fprintf(buf, "\tblocks refused by the verification, by peer\n")
+				peers := make([]string, 0, len(out.VerifyFailures))
+				for p := range out.VerifyFailures {
+					peers = append(peers, p)
+				}
+				sort.Strings(peers)
+				for _, p := range peers {
+					fmt.Fprintf(buf, "\t\t%s %d\n", p, out.VerifyFailures[p])
+				}
+			}
 			return buf, nil
 		},
 	},
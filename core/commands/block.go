@@ -59,6 +59,9 @@ on raw IPFS blocks. It outputs the following to stdout:
 	Arguments: []cmds.Argument{
 		cmds.StringArg("key", true, false, "The base58 multihash of an existing block to stat.").EnableStdin(),
 	},
+	Options: []cmds.Option{
+		offlineOption,
+	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		b, err := getBlockForKey(req, req.Arguments()[0])
 		if err != nil {
@@ -92,6 +95,9 @@ It outputs to stdout, and <key> is a base58 encoded multihash.
 	Arguments: []cmds.Argument{
 		cmds.StringArg("key", true, false, "The base58 multihash of an existing block to get.").EnableStdin(),
 	},
+	Options: []cmds.Option{
+		offlineOption,
+	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		b, err := getBlockForKey(req, req.Arguments()[0])
 		if err != nil {
@@ -217,7 +223,7 @@ func getBlockForKey(req cmds.Request, skey string) (blocks.Block, error) {
 		return nil, fmt.Errorf("zero length cid invalid")
 	}
 
-	n, err := req.InvocContext().GetNode()
+	n, err := requestNode(req)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,179 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	core "github.com/ipfs/go-ipfs/core"
+	corerepo "github.com/ipfs/go-ipfs/core/corerepo"
+	path "github.com/ipfs/go-ipfs/path"
+
+	humanize "gx/ipfs/QmPSBJL4momYnE7DcUyk2DVhD6rH488ZmHBGLbxNdhU44K/go-humanize"
+	u "gx/ipfs/QmWbjfz3u6HkAdPh34dgPchGbQjob6LXLhAeCGii2TX69n/go-ipfs-util"
+)
+
+var CacheCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Manage the blocks cached by this node.",
+	},
+
+	Subcommands: map[string]*cmds.Command{
+		"warm": cacheWarmCmd,
+	},
+}
+
+// CacheWarmOutput is sent as the blocks of a dag are fetched, and once
+// more, with Done set, when they all are.
+type CacheWarmOutput struct {
+	Root string
+	corerepo.WarmStats
+	Done bool `json:",omitempty"`
+}
+
+var cacheWarmCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Fetch a dag into the local blockstore without pinning it.",
+		ShortDescription: `
+'ipfs cache warm' fetches the blocks of the given dags from the network, so
+that they are served from the local blockstore afterwards, such as ahead of
+publishing a link to them. The blocks are not pinned: the next garbage
+collection removes them.
+
+The dag is walked a level at a time, and the blocks of each level are asked
+for from peers in batches of --batch blocks. --depth limits the levels
+walked below the root, -1 for the whole dag.
+`,
+	},
+
+	Arguments: []cmds.Argument{
+		cmds.StringArg("ipfs-path", true, true, "Path to the dags to fetch.").EnableStdin(),
+	},
+	Options: []cmds.Option{
+		cmds.IntOption("depth", "d", "Levels of links to follow below the root, -1 for all.").Default(-1),
+		cmds.IntOption("batch", "Number of blocks to request at once.").Default(corerepo.DefaultWarmBatch),
+		cmds.BoolOption("progress", "Show progress.").Default(false),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		if !n.OnlineMode() {
+			res.SetError(errNotOnline, cmds.ErrClient)
+			return
+		}
+
+		depth, _, err := req.Option("depth").Int()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		batch, _, err := req.Option("batch").Int()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		showProgress, _, _ := req.Option("progress").Bool()
+
+		out := make(chan interface{})
+		res.SetOutput((<-chan interface{})(out))
+
+		go func() {
+			defer close(out)
+			ctx := req.Context()
+
+			for _, arg := range req.Arguments() {
+				p, err := path.ParsePath(arg)
+				if err != nil {
+					res.SetError(err, cmds.ErrNormal)
+					return
+				}
+
+				// resolve the root without fetching it, for Warm to count it.
+				if strings.HasPrefix(p.String(), "/ipns/") {
+					if n.Namesys == nil {
+						res.SetError(core.ErrNoNamesys, cmds.ErrNormal)
+						return
+					}
+					p, err = n.Namesys.Resolve(ctx, p.String())
+					if err != nil {
+						res.SetError(err, cmds.ErrNormal)
+						return
+					}
+				}
+				c, err := core.ResolveToCid(ctx, n.Namesys, n.Resolver, p)
+				if err != nil {
+					res.SetError(err, cmds.ErrNormal)
+					return
+				}
+				root := c.String()
+
+				var progress func(corerepo.WarmStats)
+				if showProgress {
+					progress = func(stats corerepo.WarmStats) {
+						select {
+						case out <- &CacheWarmOutput{Root: root, WarmStats: stats}:
+						case <-ctx.Done():
+						}
+					}
+				}
+
+				stats, err := corerepo.Warm(ctx, n, c, depth, batch, progress)
+				if err != nil {
+					res.SetError(err, cmds.ErrNormal)
+					return
+				}
+
+				select {
+				case out <- &CacheWarmOutput{Root: root, WarmStats: stats, Done: true}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			outChan, ok := res.Output().(<-chan interface{})
+			if !ok {
+				return nil, u.ErrCast()
+			}
+
+			buf := new(bytes.Buffer)
+			progressLine := false
+			for r0 := range outChan {
+				r, ok := r0.(*CacheWarmOutput)
+				if !ok {
+					return nil, u.ErrCast()
+				}
+
+				if !r.Done {
+					if progressLine {
+						fmt.Fprintf(res.Stderr(), "\r")
+					}
+					fmt.Fprintf(res.Stderr(), "%s: %d blocks, %d fetched (%s)",
+						r.Root, r.Blocks, r.Fetched, humanize.Bytes(r.Bytes))
+					progressLine = true
+					continue
+				}
+
+				if progressLine {
+					fmt.Fprintf(res.Stderr(), "\n")
+					progressLine = false
+				}
+				fmt.Fprintf(buf, "warmed %s: %d blocks, %d already local, %d fetched (%s)\n",
+					r.Root, r.Blocks, r.Local, r.Fetched, humanize.Bytes(r.Bytes))
+			}
+			if progressLine {
+				fmt.Fprintf(res.Stderr(), "\n")
+			}
+			return buf, nil
+		},
+	},
+	Type: CacheWarmOutput{},
+}
@@ -16,13 +16,30 @@ var CatCmd = &cmds.Command{
 	Helptext: cmds.HelpText{
 		Tagline:          "Show IPFS object data.",
 		ShortDescription: "Displays the data contained by an IPFS or IPNS object(s) at the given path.",
+		LongDescription: `
+Displays the data contained by an IPFS or IPNS object(s) at the given path.
+
+With --from, the blocks are fetched from the given peers only, which are
+connected to, without searching for other providers; with --prefer-from too,
+from them first.
+
+	$ ipfs cat --from=QmSomePeerID,/ip4/10.0.0.2/tcp/4001/ipfs/QmOtherPeerID QmSomeHash
+
+With --offline, the command fails if the data is not in the local repo,
+rather than fetching it from the network.
+`,
 	},
 
 	Arguments: []cmds.Argument{
 		cmds.StringArg("ipfs-path", true, true, "The path to the IPFS object(s) to be outputted.").EnableStdin(),
 	},
+	Options: []cmds.Option{
+		fromOption,
+		preferFromOption,
+		offlineOption,
+	},
 	Run: func(req cmds.Request, res cmds.Response) {
-		node, err := req.InvocContext().GetNode()
+		node, err := requestNode(req)
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
@@ -35,7 +52,13 @@ var CatCmd = &cmds.Command{
 			}
 		}
 
-		readers, length, err := cat(req.Context(), node, req.Arguments())
+		ctx, err := fromPeersContext(req, node)
+		if err != nil {
+			res.SetError(err, cmds.ErrClient)
+			return
+		}
+
+		readers, length, err := cat(ctx, node, req.Arguments())
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
@@ -7,11 +7,14 @@ package commands
 
 import (
 	"bytes"
+	"encoding/json"
 	"io"
 	"sort"
 	"strings"
 
 	cmds "github.com/ipfs/go-ipfs/commands"
+
+	u "gx/ipfs/QmWbjfz3u6HkAdPh34dgPchGbQjob6LXLhAeCGii2TX69n/go-ipfs-util"
 )
 
 type Command struct {
@@ -33,8 +36,13 @@ const (
 func CommandsCmd(root *cmds.Command) *cmds.Command {
 	return &cmds.Command{
 		Helptext: cmds.HelpText{
-			Tagline:          "List all available commands.",
-			ShortDescription: `Lists all available commands (and subcommands) and exits.`,
+			Tagline: "List all available commands.",
+			ShortDescription: `
+Lists all available commands (and subcommands) and exits.
+
+'ipfs commands inspect' describes them in JSON, with their arguments and
+options.
+`,
 		},
 		Options: []cmds.Option{
 			cmds.BoolOption(flagsOptionName, "f", "Show command flags").Default(false),
@@ -55,9 +63,134 @@ func CommandsCmd(root *cmds.Command) *cmds.Command {
 			},
 		},
 		Type: Command{},
+		Subcommands: map[string]*cmds.Command{
+			"inspect": commandsInspectCmd(root),
+		},
 	}
 }
 
+// CommandSchema describes a command, its arguments and options, for the
+// programs calling it.
+type CommandSchema struct {
+	Name        string
+	Tagline     string
+	Callable    bool // false for the commands only grouping subcommands
+	Arguments   []ArgumentSchema
+	Options     []OptionSchema
+	Subcommands []CommandSchema
+}
+
+// ArgumentSchema describes an argument of a command.
+type ArgumentSchema struct {
+	Name          string
+	Type          string // "string" or "file"
+	Required      bool
+	Variadic      bool
+	SupportsStdin bool
+	Recursive     bool
+	Description   string
+}
+
+// OptionSchema describes an option of a command.
+type OptionSchema struct {
+	Names       []string
+	Type        string // "bool", "int", "uint", "float64" or "string"
+	Description string
+	Default     interface{} `json:",omitempty"`
+}
+
+func commandsInspectCmd(root *cmds.Command) *cmds.Command {
+	return &cmds.Command{
+		Helptext: cmds.HelpText{
+			Tagline: "Describe commands, their arguments and options, in JSON.",
+			ShortDescription: `
+'ipfs commands inspect' describes in JSON the commands under the given one,
+or all of them, with the types of their arguments and the types and defaults
+of their options, so that client libraries can generate their bindings:
+
+  > ipfs commands inspect pin add
+`,
+		},
+		Arguments: []cmds.Argument{
+			cmds.StringArg("command", false, true, "The words of the command to describe, all commands by default."),
+		},
+		Run: func(req cmds.Request, res cmds.Response) {
+			path := req.Arguments()
+			cmd, err := root.Get(path)
+			if err != nil {
+				res.SetError(err, cmds.ErrClient)
+				return
+			}
+
+			name := strings.Join(append([]string{"ipfs"}, path...), " ")
+			schema := cmd2schema(name, cmd)
+			res.SetOutput(&schema)
+		},
+		Marshalers: cmds.MarshalerMap{
+			cmds.Text: func(res cmds.Response) (io.Reader, error) {
+				schema, ok := res.Output().(*CommandSchema)
+				if !ok {
+					return nil, u.ErrCast()
+				}
+
+				b, err := json.MarshalIndent(schema, "", "  ")
+				if err != nil {
+					return nil, err
+				}
+				return bytes.NewReader(append(b, '\n')), nil
+			},
+		},
+		Type: CommandSchema{},
+	}
+}
+
+func cmd2schema(name string, cmd *cmds.Command) CommandSchema {
+	schema := CommandSchema{
+		Name:        name,
+		Tagline:     cmd.Helptext.Tagline,
+		Callable:    cmd.Run != nil,
+		Arguments:   make([]ArgumentSchema, len(cmd.Arguments)),
+		Options:     make([]OptionSchema, len(cmd.Options)),
+		Subcommands: make([]CommandSchema, 0, len(cmd.Subcommands)),
+	}
+
+	for i, arg := range cmd.Arguments {
+		typ := "string"
+		if arg.Type == cmds.ArgFile {
+			typ = "file"
+		}
+		schema.Arguments[i] = ArgumentSchema{
+			Name:          arg.Name,
+			Type:          typ,
+			Required:      arg.Required,
+			Variadic:      arg.Variadic,
+			SupportsStdin: arg.SupportsStdin,
+			Recursive:     arg.Recursive,
+			Description:   arg.Description,
+		}
+	}
+
+	for i, opt := range cmd.Options {
+		schema.Options[i] = OptionSchema{
+			Names:       opt.Names(),
+			Type:        opt.Type().String(),
+			Description: opt.Description(),
+			Default:     opt.DefaultVal(),
+		}
+	}
+
+	names := make([]string, 0, len(cmd.Subcommands))
+	for sub := range cmd.Subcommands {
+		names = append(names, sub)
+	}
+	sort.Strings(names)
+	for _, sub := range names {
+		schema.Subcommands = append(schema.Subcommands, cmd2schema(name+" "+sub, cmd.Subcommands[sub]))
+	}
+
+	return schema
+}
+
 func cmd2outputCmd(name string, cmd *cmds.Command) Command {
 	opts := make([]Option, len(cmd.Options))
 	for i, opt := range cmd.Options {
@@ -0,0 +1,48 @@
+package commands
+
+import (
+	"testing"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+)
+
+func TestCommandSchema(t *testing.T) {
+	root := &cmds.Command{
+		Subcommands: map[string]*cmds.Command{
+			"b": {Run: func(cmds.Request, cmds.Response) {}},
+			"a": {
+				Helptext: cmds.HelpText{Tagline: "Do a."},
+				Arguments: []cmds.Argument{
+					cmds.FileArg("path", true, true, "The files.").EnableStdin(),
+				},
+				Options: []cmds.Option{
+					cmds.IntOption("count", "c", "How many.").Default(3),
+				},
+				Run: func(cmds.Request, cmds.Response) {},
+			},
+		},
+	}
+
+	schema := cmd2schema("ipfs", root)
+	if schema.Callable || len(schema.Subcommands) != 2 {
+		t.Fatalf("unexpected root schema %+v", schema)
+	}
+
+	a := schema.Subcommands[0]
+	if a.Name != "ipfs a" || a.Tagline != "Do a." || !a.Callable {
+		t.Fatalf("unexpected schema %+v", a)
+	}
+	if schema.Subcommands[1].Name != "ipfs b" {
+		t.Fatalf("expected the subcommands to be sorted, got %s", schema.Subcommands[1].Name)
+	}
+
+	arg := a.Arguments[0]
+	if arg.Type != "file" || !arg.Required || !arg.Variadic || !arg.SupportsStdin {
+		t.Fatalf("unexpected argument schema %+v", arg)
+	}
+
+	opt := a.Options[0]
+	if len(opt.Names) != 2 || opt.Type != "int" || opt.Default != 3 {
+		t.Fatalf("unexpected option schema %+v", opt)
+	}
+}
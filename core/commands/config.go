@@ -138,12 +138,28 @@ var configShowCmd = &cmds.Command{
 	Helptext: cmds.HelpText{
 		Tagline: "Output config file contents.",
 		ShortDescription: `
-WARNING: Your private key is stored in the config file, and it will be
-included in the output of this command.
+The secrets of the config, the private key and the credentials of the remotes,
+are redacted: they are left out of the output. The output may replace the
+config with 'ipfs config replace', which keeps the current secrets.
+
+--include-secrets, or --redacted=false, outputs them as well, for a full export
+of the config. It is refused through the API, so that the secrets don't leave
+the machine.
 `,
 	},
-
+	Options: []cmds.Option{
+		cmds.BoolOption("redacted", "Leave out the private key and the other secrets.").Default(true),
+		cmds.BoolOption("include-secrets", "Include the private key and the other secrets.").Default(false),
+	},
 	Run: func(req cmds.Request, res cmds.Response) {
+		redacted, _, _ := req.Option("redacted").Bool()
+		include, _, _ := req.Option("include-secrets").Bool()
+		redacted = redacted && !include
+		if n := req.InvocContext().NodeWithoutConstructing(); !redacted && n != nil && !n.LocalMode() {
+			res.SetError(errors.New("cannot show the secrets of the config through API"), cmds.ErrClient)
+			return
+		}
+
 		fname, err := config.Filename(req.InvocContext().ConfigRoot)
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
@@ -163,10 +179,8 @@ included in the output of this command.
 			return
 		}
 
-		err = scrubValue(cfg, []string{config.IdentityTag, config.PrivKeyTag})
-		if err != nil {
-			res.SetError(err, cmds.ErrNormal)
-			return
+		if redacted {
+			config.SplitSecrets(cfg)
 		}
 
 		output, err := config.HumanOutput(cfg)
@@ -179,47 +193,6 @@ included in the output of this command.
 	},
 }
 
-func scrubValue(m map[string]interface{}, key []string) error {
-	find := func(m map[string]interface{}, k string) (string, interface{}, bool) {
-		lckey := strings.ToLower(k)
-		for mkey, val := range m {
-			lcmkey := strings.ToLower(mkey)
-			if lckey == lcmkey {
-				return mkey, val, true
-			}
-		}
-		return "", nil, false
-	}
-
-	cur := m
-	for _, k := range key[:len(key)-1] {
-		foundk, val, ok := find(cur, k)
-		if !ok {
-			return fmt.Errorf("failed to find specified key")
-		}
-
-		if foundk != k {
-			// case mismatch, calling this an error
-			return fmt.Errorf("case mismatch in config, expected %q but got %q", k, foundk)
-		}
-
-		mval, mok := val.(map[string]interface{})
-		if !mok {
-			return fmt.Errorf("%s was not a map", foundk)
-		}
-
-		cur = mval
-	}
-
-	todel, _, ok := find(cur, key[len(key)-1])
-	if !ok {
-		return fmt.Errorf("%s, not found", strings.Join(key, "."))
-	}
-
-	delete(cur, todel)
-	return nil
-}
-
 var configEditCmd = &cmds.Command{
 	Helptext: cmds.HelpText{
 		Tagline: "Open the config file for editing in $EDITOR.",
@@ -317,17 +290,14 @@ func replaceConfig(r repo.Repo, file io.Reader) error {
 		return errors.New("setting private key with API is not supported")
 	}
 
-	keyF, err := getConfig(r, config.PrivKeySelector)
+	cur, err := r.Config()
 	if err != nil {
-		return fmt.Errorf("Failed to get PrivKey")
+		return err
 	}
-
-	pkstr, ok := keyF.Value.(string)
-	if !ok {
-		return fmt.Errorf("private key in config was not a string")
+	updated, err := config.KeepSecrets(&cfg, cur)
+	if err != nil {
+		return err
 	}
 
-	cfg.Identity.PrivKey = pkstr
-
-	return r.SetConfig(&cfg)
+	return r.SetConfig(updated)
 }
@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	cmds "github.com/ipfs/go-ipfs/commands"
+	core "github.com/ipfs/go-ipfs/core"
 	path "github.com/ipfs/go-ipfs/path"
 
 	ipldcbor "gx/ipfs/QmNrbCt8j9DT5W9Pmjy2SdudT9k8GpaDr4sRuFix3BXhgR/go-ipld-cbor"
@@ -124,8 +125,11 @@ var DagGetCmd = &cmds.Command{
 	Arguments: []cmds.Argument{
 		cmds.StringArg("ref", true, false, "The object to get").EnableStdin(),
 	},
+	Options: []cmds.Option{
+		offlineOption,
+	},
 	Run: func(req cmds.Request, res cmds.Response) {
-		n, err := req.InvocContext().GetNode()
+		n, err := requestNode(req)
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
@@ -181,3 +185,19 @@ func convertRawToType(r io.Reader, format string) (node.Node, error) {
 		return nil, fmt.Errorf("unsupported target format for raw input: %s", format)
 	}
 }
+
+var offlineOption = cmds.BoolOption("offline", "Only read the local blocks and IPNS records, failing rather than fetching them from the network.").Default(false)
+
+// requestNode returns the node of req, or its offline view if --offline is
+// given.
+func requestNode(req cmds.Request) (*core.IpfsNode, error) {
+	n, err := req.InvocContext().GetNode()
+	if err != nil {
+		return nil, err
+	}
+	offline, _, err := req.Option("offline").Bool()
+	if err != nil || !offline {
+		return n, err
+	}
+	return n.Offline()
+}
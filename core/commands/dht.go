@@ -14,7 +14,6 @@ import (
 
 	routing "gx/ipfs/QmNdaQ8itUU9jEZUwTsG4gHMaPmRfi6FEe89QjQAFbep3M/go-libp2p-routing"
 	notif "gx/ipfs/QmNdaQ8itUU9jEZUwTsG4gHMaPmRfi6FEe89QjQAFbep3M/go-libp2p-routing/notifications"
-	ipdht "gx/ipfs/QmRmroYSdievxnjiuy99C8BzShNstdEWcEF3LQHF7fUbez/go-libp2p-kad-dht"
 	b58 "gx/ipfs/QmT8rehPR3F6bmwL6zjUN8XpiDBFFpMP2myPdC6ApsWfJf/go-base58"
 	u "gx/ipfs/QmWbjfz3u6HkAdPh34dgPchGbQjob6LXLhAeCGii2TX69n/go-ipfs-util"
 	pstore "gx/ipfs/QmXZSd1qR5BxZkPyuwfT5jpqQFScZccoZvDneXsKzCNHWX/go-libp2p-peerstore"
@@ -59,8 +58,8 @@ var queryDhtCmd = &cmds.Command{
 			return
 		}
 
-		dht, ok := n.Routing.(*ipdht.IpfsDHT)
-		if !ok {
+		dht := n.DHT()
+		if dht == nil {
 			res.SetError(ErrNotDHT, cmds.ErrNormal)
 			return
 		}
@@ -154,8 +153,8 @@ var findProvidersDhtCmd = &cmds.Command{
 			return
 		}
 
-		dht, ok := n.Routing.(*ipdht.IpfsDHT)
-		if !ok {
+		dht := n.DHT()
+		if dht == nil {
 			res.SetError(ErrNotDHT, cmds.ErrNormal)
 			return
 		}
@@ -419,8 +418,8 @@ var findPeerDhtCmd = &cmds.Command{
 			return
 		}
 
-		dht, ok := n.Routing.(*ipdht.IpfsDHT)
-		if !ok {
+		dht := n.DHT()
+		if dht == nil {
 			res.SetError(ErrNotDHT, cmds.ErrNormal)
 			return
 		}
@@ -526,8 +525,8 @@ Different key types can specify other 'best' rules.
 			return
 		}
 
-		dht, ok := n.Routing.(*ipdht.IpfsDHT)
-		if !ok {
+		dht := n.DHT()
+		if dht == nil {
 			res.SetError(ErrNotDHT, cmds.ErrNormal)
 			return
 		}
@@ -644,8 +643,8 @@ NOTE: A value may not exceed 2048 bytes.
 			return
 		}
 
-		dht, ok := n.Routing.(*ipdht.IpfsDHT)
-		if !ok {
+		dht := n.DHT()
+		if dht == nil {
 			res.SetError(ErrNotDHT, cmds.ErrNormal)
 			return
 		}
@@ -8,7 +8,9 @@ var DiagCmd = &cmds.Command{
 	},
 
 	Subcommands: map[string]*cmds.Command{
-		"sys":  sysDiagCmd,
-		"cmds": ActiveReqsCmd,
+		"sys":       sysDiagCmd,
+		"cmds":      ActiveReqsCmd,
+		"protocols": diagProtocolsCmd,
+		"mem":       diagMemCmd,
 	},
 }
@@ -0,0 +1,103 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"runtime"
+	"text/tabwriter"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	memtune "github.com/ipfs/go-ipfs/core/memtune"
+
+	humanize "gx/ipfs/QmPSBJL4momYnE7DcUyk2DVhD6rH488ZmHBGLbxNdhU44K/go-humanize"
+)
+
+var diagMemCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Show the memory use of the daemon by subsystem.",
+		ShortDescription: `
+'ipfs diag mem' prints the heap and garbage collector stats of the daemon,
+followed by the live heap allocated by each subsystem, the largest first.
+
+The heap of subsystems is estimated from the samples of the memory profile
+as of the last garbage collection; --gc runs one first for current numbers.
+A subsystem is the innermost package allocating the memory outside of the
+standard library, so the buffers of a connection may be accounted to the
+muxer rather than to the protocol using them.
+
+The garbage collector and heap ballast are tuned by the Memory section of
+the config.
+`,
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption("gc", "Run a garbage collection first.").Default(false),
+		cmds.IntOption("limit", "n", "Number of subsystems to list, 0 for all.").Default(20),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		if !n.OnlineMode() {
+			res.SetError(errNotOnline, cmds.ErrClient)
+			return
+		}
+
+		gc, _, _ := req.Option("gc").Bool()
+		limit, _, err := req.Option("limit").Int()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		if gc {
+			runtime.GC()
+		}
+
+		r := memtune.Stats()
+		if limit > 0 && len(r.Subsystems) > limit {
+			r.Subsystems = r.Subsystems[:limit]
+		}
+		if r.Subsystems == nil {
+			r.Subsystems = []memtune.Subsystem{}
+		}
+		res.SetOutput(&r)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			r, ok := res.Output().(*memtune.Report)
+			if !ok {
+				return nil, fmt.Errorf("expected a memtune.Report as command result")
+			}
+
+			buf := new(bytes.Buffer)
+			fmt.Fprintf(buf, "Heap allocated: %s\n", humanize.Bytes(r.HeapAlloc))
+			fmt.Fprintf(buf, "Heap in use:    %s\n", humanize.Bytes(r.HeapInuse))
+			fmt.Fprintf(buf, "Heap reserved:  %s\n", humanize.Bytes(r.HeapSys))
+			fmt.Fprintf(buf, "Total reserved: %s\n", humanize.Bytes(r.Sys))
+			fmt.Fprintf(buf, "Next GC at:     %s\n", humanize.Bytes(r.NextGC))
+			fmt.Fprintf(buf, "GC runs:        %d\n", r.NumGC)
+			if r.GCPercent < 0 {
+				fmt.Fprintf(buf, "GC percent:     off\n")
+			} else {
+				fmt.Fprintf(buf, "GC percent:     %d\n", r.GCPercent)
+			}
+			fmt.Fprintf(buf, "Ballast:        %s\n", humanize.Bytes(r.Ballast))
+
+			if len(r.Subsystems) > 0 {
+				fmt.Fprintln(buf)
+				w := tabwriter.NewWriter(buf, 1, 2, 1, ' ', 0)
+				fmt.Fprintf(w, "SUBSYSTEM\tLIVE\tOBJECTS\n")
+				for _, s := range r.Subsystems {
+					fmt.Fprintf(w, "%s\t%s\t%d\n", s.Name, humanize.Bytes(s.Bytes), s.Objects)
+				}
+				w.Flush()
+			}
+			return buf, nil
+		},
+	},
+	Type: memtune.Report{},
+}
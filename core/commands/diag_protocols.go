@@ -0,0 +1,99 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	protodiag "github.com/ipfs/go-ipfs/core/protodiag"
+)
+
+type ProtocolFailuresOutput struct {
+	Peers   []protodiag.PeerFailures
+	Samples []protodiag.Failure
+}
+
+type peerFailuresByPeer []protodiag.PeerFailures
+
+func (s peerFailuresByPeer) Len() int           { return len(s) }
+func (s peerFailuresByPeer) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s peerFailuresByPeer) Less(i, j int) bool { return s[i].Peer < s[j].Peer }
+
+var diagProtocolsCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Show failed protocol negotiations and handshakes.",
+		ShortDescription: `
+'ipfs diag protocols' lists, for every peer, how many protocol negotiations,
+identify exchanges and security handshakes with it failed, followed by the
+most recent failures. Such failures make peers unreachable while the usual
+commands only report that they are.
+`,
+	},
+	Options: []cmds.Option{
+		cmds.StringOption("peer", "p", "Only show the failures of this peer."),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		if !n.OnlineMode() || n.ProtocolFailures == nil {
+			res.SetError(errNotOnline, cmds.ErrClient)
+			return
+		}
+
+		only, _, _ := req.Option("peer").String()
+
+		out := &ProtocolFailuresOutput{
+			Peers:   []protodiag.PeerFailures{},
+			Samples: []protodiag.Failure{},
+		}
+		for _, p := range n.ProtocolFailures.Peers() {
+			if only == "" || p.Peer == only {
+				out.Peers = append(out.Peers, p)
+			}
+		}
+		sort.Sort(peerFailuresByPeer(out.Peers))
+
+		for _, f := range n.ProtocolFailures.Samples() {
+			if only == "" || f.Peer == only {
+				out.Samples = append(out.Samples, f)
+			}
+		}
+
+		res.SetOutput(out)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out, ok := res.Output().(*ProtocolFailuresOutput)
+			if !ok {
+				return nil, fmt.Errorf("unexpected output type %T", res.Output())
+			}
+
+			buf := new(bytes.Buffer)
+			for _, p := range out.Peers {
+				fmt.Fprintf(buf, "%s\t%s %d\t%s %d\t%s %d\n", p.Peer,
+					protodiag.KindNegotiation, p.Counts[protodiag.KindNegotiation],
+					protodiag.KindIdentify, p.Counts[protodiag.KindIdentify],
+					protodiag.KindHandshake, p.Counts[protodiag.KindHandshake])
+			}
+			if len(out.Samples) > 0 {
+				fmt.Fprintln(buf, "\nrecent failures:")
+			}
+			for _, f := range out.Samples {
+				fmt.Fprintf(buf, "%s %s %s", f.Time.Format(time.RFC3339), f.Peer, f.Kind)
+				if f.Protocol != "" {
+					fmt.Fprintf(buf, " %s", f.Protocol)
+				}
+				fmt.Fprintf(buf, ": %s\n", f.Error)
+			}
+			return buf, nil
+		},
+	},
+	Type: ProtocolFailuresOutput{},
+}
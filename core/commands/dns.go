@@ -1,14 +1,26 @@
 package commands
 
 import (
+	"bytes"
+	"fmt"
 	"io"
 	"strings"
 
 	cmds "github.com/ipfs/go-ipfs/commands"
 	namesys "github.com/ipfs/go-ipfs/namesys"
+	path "github.com/ipfs/go-ipfs/path"
 	util "gx/ipfs/QmWbjfz3u6HkAdPh34dgPchGbQjob6LXLhAeCGii2TX69n/go-ipfs-util"
 )
 
+// DNSResult is the path a domain resolves to and, with --verbose, the
+// lookups done to resolve it.
+type DNSResult struct {
+	Path  path.Path
+	Trace []namesys.DNSStep `json:",omitempty"`
+	// Error is why the resolution failed, when a trace is output anyway.
+	Error string `json:",omitempty"`
+}
+
 var DNSCmd = &cmds.Command{
 	Helptext: cmds.HelpText{
 		Tagline: "Resolve DNS links.",
@@ -40,6 +52,22 @@ The resolver can recursively resolve:
 	dnslink=/ipns/ipfs.io
 	> ipfs dns -r recursive.ipfs.io
 	/ipfs/QmRzTuh2Lpuz7Gr39stNr6mTFdqAghsZec1JoUnfySUzcy
+
+To diagnose a DNSLink setup, --verbose shows each TXT lookup made, of the
+_dnslink subdomain first and of the domain itself as the fallback, the
+records found, whether each is a valid link, and which one was used. The
+lookups are shown even when the resolution fails:
+
+	> ipfs dns --verbose ipfs.io
+	ipfs.io:
+	  TXT _dnslink.ipfs.io: 1 record
+	    * "dnslink=/ipfs/QmRzTuh2Lpuz7Gr39stNr6mTFdqAghsZec1JoUnfySUzcy"
+	  TXT ipfs.io: 1 record
+	      "v=spf1 -all": not a valid dnslink entry
+	  resolved to /ipfs/QmRzTuh2Lpuz7Gr39stNr6mTFdqAghsZec1JoUnfySUzcy
+	/ipfs/QmRzTuh2Lpuz7Gr39stNr6mTFdqAghsZec1JoUnfySUzcy
+
+The record used is marked with a '*'.
 `,
 	},
 
@@ -48,17 +76,42 @@ The resolver can recursively resolve:
 	},
 	Options: []cmds.Option{
 		cmds.BoolOption("recursive", "r", "Resolve until the result is not a DNS link.").Default(false),
+		cmds.BoolOption("verbose", "v", "Show the lookups made and the records found.").Default(false),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
 
+		cfg, err := req.InvocContext().GetConfig()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		lookup, err := namesys.NewLookupTXT(cfg.DNS.Resolvers)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
 		recursive, _, _ := req.Option("recursive").Bool()
+		verbose, _, _ := req.Option("verbose").Bool()
 		name := req.Arguments()[0]
-		resolver := namesys.NewDNSResolver()
+		resolver := namesys.NewDNSResolverWithLookup(lookup)
 
 		depth := 1
 		if recursive {
 			depth = namesys.DefaultDepthLimit
 		}
+
+		if verbose {
+			trace, output, err := resolver.(*namesys.DNSResolver).Trace(req.Context(), name, depth)
+			result := &DNSResult{Path: output, Trace: trace}
+			if err != nil {
+				result.Error = err.Error()
+			}
+			res.SetOutput(result)
+			return
+		}
+
 		output, err := resolver.ResolveN(req.Context(), name, depth)
 		if err == namesys.ErrResolveFailed {
 			res.SetError(err, cmds.ErrNotFound)
@@ -68,16 +121,56 @@ The resolver can recursively resolve:
 			res.SetError(err, cmds.ErrNormal)
 			return
 		}
-		res.SetOutput(&ResolvedPath{output})
+		res.SetOutput(&DNSResult{Path: output})
 	},
 	Marshalers: cmds.MarshalerMap{
 		cmds.Text: func(res cmds.Response) (io.Reader, error) {
-			output, ok := res.Output().(*ResolvedPath)
+			output, ok := res.Output().(*DNSResult)
 			if !ok {
 				return nil, util.ErrCast()
 			}
-			return strings.NewReader(output.Path.String() + "\n"), nil
+			if output.Trace == nil && output.Error == "" {
+				return strings.NewReader(output.Path.String() + "\n"), nil
+			}
+
+			buf := new(bytes.Buffer)
+			for _, step := range output.Trace {
+				fmt.Fprintf(buf, "%s:\n", step.Domain)
+				for _, l := range step.Lookups {
+					if l.Error != "" {
+						fmt.Fprintf(buf, "  TXT %s: lookup failed: %s\n", l.Name, l.Error)
+						continue
+					}
+					noun := "records"
+					if len(l.Records) == 1 {
+						noun = "record"
+					}
+					fmt.Fprintf(buf, "  TXT %s: %d %s\n", l.Name, len(l.Records), noun)
+					for i, rec := range l.Records {
+						mark := " "
+						if i == l.Used {
+							mark = "*"
+						}
+						if rec.Error != "" {
+							fmt.Fprintf(buf, "    %s %q: %s\n", mark, rec.Text, rec.Error)
+						} else {
+							fmt.Fprintf(buf, "    %s %q\n", mark, rec.Text)
+						}
+					}
+				}
+				if step.Error != "" {
+					fmt.Fprintf(buf, "  failed: %s\n", step.Error)
+				} else {
+					fmt.Fprintf(buf, "  resolved to %s\n", step.Path)
+				}
+			}
+			if output.Error != "" {
+				fmt.Fprintf(buf, "Error: %s\n", output.Error)
+			} else {
+				fmt.Fprintln(buf, output.Path)
+			}
+			return buf, nil
 		},
 	},
-	Type: ResolvedPath{},
+	Type: DNSResult{},
 }
@@ -12,6 +12,8 @@ import (
 
 	cmds "github.com/ipfs/go-ipfs/commands"
 	core "github.com/ipfs/go-ipfs/core"
+	corerepo "github.com/ipfs/go-ipfs/core/corerepo"
+	coreunix "github.com/ipfs/go-ipfs/core/coreunix"
 	dag "github.com/ipfs/go-ipfs/merkledag"
 	mfs "github.com/ipfs/go-ipfs/mfs"
 	path "github.com/ipfs/go-ipfs/path"
@@ -19,6 +21,7 @@ import (
 	uio "github.com/ipfs/go-ipfs/unixfs/io"
 
 	logging "gx/ipfs/QmSpJByNKFX1sCsHBEp3R73FL4NF6FnQTEGyNAXHm2GS52/go-log"
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
 	node "gx/ipfs/Qmb3Hm9QDFmfYuET4pu7Kyg8JV78jFa1nvZx5vnCZsK4ck/go-ipld-format"
 )
 
@@ -39,21 +42,28 @@ of consistency guarantees. If the daemon is unexpectedly killed before running
 'ipfs files flush' on the files in question, then data may be lost. This also
 applies to running 'ipfs repo gc' concurrently with '--flush=false'
 operations.
+
+Changes reach the blockstore once flushed, but the files root, which is what
+survives a restart, is only recorded shortly after the last change. To apply
+several changes to the root at once, wrap them in 'ipfs files begin' and
+'ipfs files commit': should the daemon stop in between, none of them is kept.
 `,
 	},
 	Options: []cmds.Option{
 		cmds.BoolOption("f", "flush", "Flush target and ancestors after write.").Default(true),
 	},
 	Subcommands: map[string]*cmds.Command{
-		"read":  FilesReadCmd,
-		"write": FilesWriteCmd,
-		"mv":    FilesMvCmd,
-		"cp":    FilesCpCmd,
-		"ls":    FilesLsCmd,
-		"mkdir": FilesMkdirCmd,
-		"stat":  FilesStatCmd,
-		"rm":    FilesRmCmd,
-		"flush": FilesFlushCmd,
+		"read":   FilesReadCmd,
+		"write":  FilesWriteCmd,
+		"mv":     FilesMvCmd,
+		"cp":     FilesCpCmd,
+		"ls":     FilesLsCmd,
+		"mkdir":  FilesMkdirCmd,
+		"stat":   FilesStatCmd,
+		"rm":     FilesRmCmd,
+		"flush":  FilesFlushCmd,
+		"begin":  FilesBeginCmd,
+		"commit": FilesCommitCmd,
 	},
 }
 
@@ -62,6 +72,15 @@ var formatError = errors.New("Format was set by multiple options. Only one forma
 var FilesStatCmd = &cmds.Command{
 	Helptext: cmds.HelpText{
 		Tagline: "Display file status.",
+		ShortDescription: `
+'ipfs files stat' shows the hash, sizes and type of a file or directory.
+
+With --with-locality, it also tells how much of the dag of the file is in the
+local blockstore, and how much has to be fetched from the network, so as to
+predict whether reading, exporting or pinning it is instant or slow. Only the
+local blocks are walked: a missing block may stand for a whole missing
+subtree, whose size is taken from the link to it.
+`,
 	},
 
 	Arguments: []cmds.Argument{
@@ -77,6 +96,7 @@ ChildBlocks: <childs>
 Type: <type>`),
 		cmds.BoolOption("hash", "Print only hash. Implies '--format=<hash>'. Conflicts with other format options.").Default(false),
 		cmds.BoolOption("size", "Print only size. Implies '--format=<cumulsize>'. Conflicts with other format options.").Default(false),
+		cmds.BoolOption("with-locality", "Also show how much of the dag is in the local blockstore, and how much is missing.").Default(false),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
 
@@ -109,6 +129,26 @@ Type: <type>`),
 			return
 		}
 
+		withLocality, _, _ := req.Option("with-locality").Bool()
+		if withLocality {
+			c, err := cid.Decode(o.Hash)
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+			l, err := corerepo.DagLocality(req.Context(), node, c)
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+			o.WithLocality = true
+			o.LocalBlocks = l.LocalBlocks
+			o.LocalBytes = l.LocalBytes
+			o.MissingBlocks = l.MissingBlocks
+			o.MissingBytes = l.MissingBytes
+			o.LocalFraction = l.LocalFraction()
+		}
+
 		res.SetOutput(o)
 	},
 	Marshalers: cmds.MarshalerMap{
@@ -125,6 +165,10 @@ Type: <type>`),
 			s = strings.Replace(s, "<type>", out.Type, -1)
 
 			fmt.Fprintln(buf, s)
+			if out.WithLocality {
+				fmt.Fprintf(buf, "Local: %d blocks, %d bytes (%.2f%%)\n", out.LocalBlocks, out.LocalBytes, out.LocalFraction*100)
+				fmt.Fprintf(buf, "Missing: %d blocks, %d bytes\n", out.MissingBlocks, out.MissingBytes)
+			}
 			return buf, nil
 		},
 	},
@@ -282,6 +326,14 @@ type Object struct {
 	CumulativeSize uint64
 	Blocks         int
 	Type           string
+
+	// the amount of the dag in the blockstore, with --with-locality
+	WithLocality  bool    `json:",omitempty"`
+	LocalBlocks   int     `json:",omitempty"`
+	LocalBytes    uint64  `json:",omitempty"`
+	MissingBlocks int     `json:",omitempty"`
+	MissingBytes  uint64  `json:",omitempty"`
+	LocalFraction float64 `json:",omitempty"`
 }
 
 type FilesLsOutput struct {
@@ -566,6 +618,15 @@ If the '--flush' option is set to false, changes will not be propogated to the
 merkledag root. This can make operations much faster when doing a large number
 of writes to a deeper directory structure.
 
+The '--flush-level' option gives finer control, and takes precedence over
+'--flush':
+  none   - the file is written, its directory and ancestors are not updated
+           (same as --flush=false)
+  parent - the file and its directory are written, the ancestors are not
+           updated
+  full   - the file and all its ancestors, up to the root, are written
+           (same as --flush=true, the default)
+
 EXAMPLE:
 
     echo "hello world" | ipfs files write --create /myfs/a/b/file
@@ -587,6 +648,7 @@ stat' on the file or any of its ancestors.
 		cmds.BoolOption("create", "e", "Create the file if it does not exist."),
 		cmds.BoolOption("truncate", "t", "Truncate the file to size zero before writing."),
 		cmds.IntOption("count", "n", "Maximum number of bytes to read."),
+		cmds.StringOption("flush-level", "How far to flush the write: none, parent or full."),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		path, err := checkPath(req.Arguments()[0])
@@ -597,7 +659,11 @@ stat' on the file or any of its ancestors.
 
 		create, _, _ := req.Option("create").Bool()
 		trunc, _, _ := req.Option("truncate").Bool()
-		flush, _, _ := req.Option("flush").Bool()
+		level, err := flushLevel(req)
+		if err != nil {
+			res.SetError(err, cmds.ErrClient)
+			return
+		}
 
 		nd, err := req.InvocContext().GetNode()
 		if err != nil {
@@ -621,16 +687,26 @@ stat' on the file or any of its ancestors.
 			return
 		}
 
-		wfd, err := fi.Open(mfs.OpenWriteOnly, flush)
+		wfd, err := fi.Open(mfs.OpenWriteOnly, level == flushFull)
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
 		}
 
+		written := false
 		defer func() {
 			err := wfd.Close()
+			if err == nil && level == flushParent {
+				err = mfs.FlushParent(nd.FilesRoot, path)
+			}
 			if err != nil {
 				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+			if written {
+				if err := coreunix.IndexMFSFile(req.Context(), nd, path, fi); err != nil {
+					log.Warningf("failed to index %s: %s", path, err)
+				}
 			}
 		}()
 
@@ -674,6 +750,7 @@ stat' on the file or any of its ancestors.
 			res.SetError(err, cmds.ErrNormal)
 			return
 		}
+		written = true
 
 		log.Debugf("wrote %d bytes to %s", n, path)
 	},
@@ -756,6 +833,83 @@ are run with the '--flush=false'.
 	},
 }
 
+// Levels of the '--flush-level' option of 'ipfs files write'.
+const (
+	flushNone   = "none"
+	flushParent = "parent"
+	flushFull   = "full"
+)
+
+// flushLevel reads the '--flush-level' option, falling back on '--flush'.
+func flushLevel(req cmds.Request) (string, error) {
+	level, found, err := req.Option("flush-level").String()
+	if err != nil {
+		return "", err
+	}
+	if found {
+		switch level {
+		case flushNone, flushParent, flushFull:
+			return level, nil
+		}
+		return "", fmt.Errorf("invalid flush level %q, must be one of {none, parent, full}", level)
+	}
+
+	flush, _, _ := req.Option("flush").Bool()
+	if flush {
+		return flushFull, nil
+	}
+	return flushNone, nil
+}
+
+var FilesBeginCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Start a batch of changes to the files root.",
+		ShortDescription: `
+'ipfs files begin' starts a batch: the changes made by the following 'ipfs
+files' commands are only recorded in the files root, which is what survives
+a restart, by 'ipfs files commit', all at once. If the daemon stops before,
+the root is left as it was before the batch.
+
+The changes are visible to all 'ipfs files' commands during the batch. Only
+one batch may be in progress at a time.
+`,
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		nd, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		if err := nd.FilesRoot.Begin(); err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+	},
+}
+
+var FilesCommitCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Apply the batch of changes started by 'ipfs files begin'.",
+		ShortDescription: `
+'ipfs files commit' records the changes made since 'ipfs files begin' in the
+files root, flushing every pending change, and ends the batch.
+`,
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		nd, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		if err := nd.FilesRoot.Commit(); err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+	},
+}
+
 var FilesRmCmd = &cmds.Command{
 	Helptext: cmds.HelpText{
 		Tagline: "Remove a file.",
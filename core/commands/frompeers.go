@@ -0,0 +1,68 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	core "github.com/ipfs/go-ipfs/core"
+	bitswap "github.com/ipfs/go-ipfs/exchange/bitswap"
+	iaddr "github.com/ipfs/go-ipfs/thirdparty/ipfsaddr"
+
+	pstore "gx/ipfs/QmXZSd1qR5BxZkPyuwfT5jpqQFScZccoZvDneXsKzCNHWX/go-libp2p-peerstore"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+// The options of the commands fetching blocks from the given peers.
+const (
+	fromOptionName       = "from"
+	preferFromOptionName = "prefer-from"
+)
+
+var fromOption = cmds.StringOption(fromOptionName, "Fetch the blocks from these peers only, without searching for providers: peer IDs, or multiaddrs ending in /ipfs/<peer ID>, separated by commas.")
+var preferFromOption = cmds.BoolOption(preferFromOptionName, "Fetch the blocks from the --from peers first, and from any peer if they don't have them.").Default(false)
+
+// fromPeersContext returns the context of req, under which the blocks are
+// fetched from the peers of --from, if given.
+func fromPeersContext(req cmds.Request, n *core.IpfsNode) (context.Context, error) {
+	from, found, err := req.Option(fromOptionName).String()
+	if err != nil {
+		return nil, err
+	}
+	prefer, _, _ := req.Option(preferFromOptionName).Bool()
+	if !found || from == "" {
+		if prefer {
+			return nil, errors.New("--prefer-from needs the peers of --from")
+		}
+		return req.Context(), nil
+	}
+	if !n.OnlineMode() {
+		return nil, errors.New("--from needs the node to be online")
+	}
+	if _, ok := n.Exchange.(*bitswap.Bitswap); !ok {
+		return nil, errors.New("--from needs the node to fetch the blocks with bitswap")
+	}
+
+	var peers []peer.ID
+	for _, s := range strings.Split(from, ",") {
+		s = strings.TrimSpace(s)
+		if !strings.HasPrefix(s, "/") {
+			p, err := peer.IDB58Decode(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid peer ID %q: %s", s, err)
+			}
+			peers = append(peers, p)
+			continue
+		}
+
+		a, err := iaddr.ParseString(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid peer address %q: %s", s, err)
+		}
+		n.Peerstore.AddAddr(a.ID(), a.Transport(), pstore.TempAddrTTL)
+		peers = append(peers, a.ID())
+	}
+	return bitswap.WithPeers(req.Context(), peers, !prefer), nil
+}
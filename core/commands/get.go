@@ -34,6 +34,14 @@ To output a TAR archive instead of unpacked files, use '--archive' or '-a'.
 
 To compress the output with GZIP compression, use '--compress' or '-C'. You
 may also specify the level of compression by specifying '-l=<1-9>'.
+
+With '--sparse', the runs of zeros in the files, such as the free space of
+the images of disks, are left as holes rather than written, on the
+filesystems supporting them. It doesn't apply to archives.
+
+To fetch the blocks from some peers only, without searching for other
+providers, give their peer IDs or addresses with '--from=<peer>,<peer>', and
+'--prefer-from' to fetch them from any peer if these don't have them.
 `,
 	},
 
@@ -45,10 +53,22 @@ may also specify the level of compression by specifying '-l=<1-9>'.
 		cmds.BoolOption("archive", "a", "Output a TAR archive.").Default(false),
 		cmds.BoolOption("compress", "C", "Compress the output with GZIP compression.").Default(false),
 		cmds.IntOption("compression-level", "l", "The level of compression (1-9).").Default(-1),
+		cmds.BoolOption("sparse", "Leave the runs of zeros of the files as holes.").Default(false),
+		fromOption,
+		preferFromOption,
+		offlineOption,
 	},
 	PreRun: func(req cmds.Request) error {
-		_, err := getCompressOptions(req)
-		return err
+		cmplvl, err := getCompressOptions(req)
+		if err != nil {
+			return err
+		}
+		archive, _, _ := req.Option("archive").Bool()
+		sparse, _, _ := req.Option("sparse").Bool()
+		if sparse && (archive || cmplvl != gzip.NoCompression) {
+			return errors.New("--sparse doesn't apply to archives")
+		}
+		return nil
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		if len(req.Arguments()) == 0 {
@@ -62,13 +82,17 @@ may also specify the level of compression by specifying '-l=<1-9>'.
 			return
 		}
 
-		node, err := req.InvocContext().GetNode()
+		node, err := requestNode(req)
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
 		}
 		p := path.Path(req.Arguments()[0])
-		ctx := req.Context()
+		ctx, err := fromPeersContext(req, node)
+		if err != nil {
+			res.SetError(err, cmds.ErrClient)
+			return
+		}
 		dn, err := core.Resolve(ctx, node.Namesys, node.Resolver, p)
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
@@ -119,12 +143,14 @@ may also specify the level of compression by specifying '-l=<1-9>'.
 		}
 
 		archive, _, _ := req.Option("archive").Bool()
+		sparse, _, _ := req.Option("sparse").Bool()
 
 		gw := getWriter{
 			Out:         os.Stdout,
 			Err:         os.Stderr,
 			Archive:     archive,
 			Compression: cmplvl,
+			Sparse:      sparse,
 			Size:        int64(res.Length()),
 		}
 
@@ -177,6 +203,7 @@ type getWriter struct {
 
 	Archive     bool
 	Compression int
+	Sparse      bool // leave holes for the zeros of the files extracted
 	Size        int64
 }
 
@@ -225,7 +252,7 @@ func (gw *getWriter) writeExtracted(r io.Reader, fpath string) error {
 	defer bar.Finish()
 	defer bar.Set64(gw.Size)
 
-	extractor := &tar.Extractor{fpath, bar.Add64}
+	extractor := &tar.Extractor{Path: fpath, Progress: bar.Add64, Sparse: gw.Sparse}
 	return extractor.Extract(r)
 }
 
@@ -1,9 +1,12 @@
 package commands
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"io"
 	"strings"
+	"time"
 
 	cmds "github.com/ipfs/go-ipfs/commands"
 	namesys "github.com/ipfs/go-ipfs/namesys"
@@ -45,6 +48,29 @@ Resolve the value of a dnslink:
   > ipfs name resolve ipfs.io
   /ipfs/QmaBvfZooxWkrv7D3r8LS9moNjzD2o525XMZze69hhoxf5
 
+Stream progressively better answers for a name, starting with the cached
+value and ending with the best of 16 records found in the DHT:
+
+  > ipfs name resolve --stream --dht-record-count=16 QmaCpDMGvV2BGHeYERUEnRQAwe3N8SzbUtfsmvsqQLuvuJ
+  /ipfs/QmSiTko9JZyabH56y2fussEt1A5oDqsFXB3CkvAqraFryz
+  /ipfs/QmaBvfZooxWkrv7D3r8LS9moNjzD2o525XMZze69hhoxf5
+
+Resolve a name quickly, taking the first record found in the DHT, or taking
+the best of the records found within 5 seconds:
+
+  > ipfs name resolve --dht-record-count=1 QmaCpDMGvV2BGHeYERUEnRQAwe3N8SzbUtfsmvsqQLuvuJ
+  > ipfs name resolve --dht-timeout=5s QmaCpDMGvV2BGHeYERUEnRQAwe3N8SzbUtfsmvsqQLuvuJ
+
+Watch a name, keeping the request open and printing its value, then each
+new value it is published or set to, as it is resolved again every
+--watch-interval:
+
+  > ipfs name resolve --watch --watch-interval=30s QmaCpDMGvV2BGHeYERUEnRQAwe3N8SzbUtfsmvsqQLuvuJ
+  /ipfs/QmSiTko9JZyabH56y2fussEt1A5oDqsFXB3CkvAqraFryz
+  /ipfs/QmaBvfZooxWkrv7D3r8LS9moNjzD2o525XMZze69hhoxf5
+
+With --enc=json, each value is a JSON object on its own line.
+
 `,
 	},
 
@@ -54,6 +80,11 @@ Resolve the value of a dnslink:
 	Options: []cmds.Option{
 		cmds.BoolOption("recursive", "r", "Resolve until the result is not an IPNS name.").Default(false),
 		cmds.BoolOption("nocache", "n", "Do not use cached entries.").Default(false),
+		cmds.BoolOption("stream", "s", "Stream progressively better answers as they are found.").Default(false),
+		cmds.IntOption("dht-record-count", "dhtrc", "Number of records to request for DHT resolution. Default: Ipns.ResolveRecordCount."),
+		cmds.StringOption("dht-timeout", "dhtt", "Max time to collect records during DHT resolution, such as \"30s\". 0 waits for all. Default: Ipns.ResolveTimeout."),
+		cmds.BoolOption("watch", "w", "Keep resolving the name, printing each new value.").Default(false),
+		cmds.StringOption("watch-interval", "How often the name is resolved again with --watch.").Default("1m"),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
 
@@ -113,7 +144,125 @@ Resolve the value of a dnslink:
 			name = "/ipns/" + name
 		}
 
-		output, err := resolver.ResolveN(req.Context(), name, depth)
+		ctx := req.Context()
+		rc, rcfound, err := req.Option("dht-record-count").Int()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		if rcfound {
+			if rc < 1 {
+				res.SetError(fmt.Errorf("dht-record-count must be positive, got %d", rc), cmds.ErrClient)
+				return
+			}
+			ctx = context.WithValue(ctx, "ipns-dht-record-count", rc)
+		}
+		if timeout, found, _ := req.Option("dht-timeout").String(); found {
+			d, err := time.ParseDuration(timeout)
+			if err != nil {
+				res.SetError(fmt.Errorf("invalid --dht-timeout: %s", err), cmds.ErrClient)
+				return
+			}
+			if d < 0 {
+				res.SetError(errors.New("--dht-timeout cannot be negative"), cmds.ErrClient)
+				return
+			}
+			ctx = namesys.WithDHTTimeout(ctx, d)
+		}
+
+		stream, _, _ := req.Option("stream").Bool()
+		watch, _, _ := req.Option("watch").Bool()
+		if stream && watch {
+			res.SetError(errors.New("--stream and --watch can't be given together"), cmds.ErrClient)
+			return
+		}
+
+		if watch {
+			ivalStr, _, _ := req.Option("watch-interval").String()
+			interval, err := time.ParseDuration(ivalStr)
+			if err != nil {
+				res.SetError(fmt.Errorf("invalid --watch-interval: %s", err), cmds.ErrClient)
+				return
+			}
+			if interval <= 0 {
+				res.SetError(errors.New("--watch-interval must be positive"), cmds.ErrClient)
+				return
+			}
+
+			ctx, cancel := context.WithCancel(ctx)
+			results := namesys.Watch(ctx, resolver, name, depth, rc, interval)
+			first, ok := <-results
+			if !ok {
+				cancel()
+				res.SetError(req.Context().Err(), cmds.ErrNormal)
+				return
+			}
+			if first.Err != nil {
+				cancel()
+				res.SetError(first.Err, cmds.ErrNormal)
+				return
+			}
+
+			outChan := make(chan interface{})
+			res.SetOutput((<-chan interface{})(outChan))
+
+			go func() {
+				defer close(outChan)
+				defer cancel()
+				for r := first; ok; r, ok = <-results {
+					select {
+					case outChan <- &ResolvedPath{r.Path}:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}()
+			return
+		}
+
+		if stream {
+			sr, ok := resolver.(namesys.StreamResolver)
+			if !ok {
+				res.SetError(errors.New("resolver does not support streaming"), cmds.ErrNormal)
+				return
+			}
+
+			results := sr.ResolveStream(ctx, name, depth, rc)
+
+			// surface a failure to find any answer as a command error.
+			first, ok := <-results
+			if !ok {
+				res.SetError(namesys.ErrResolveFailed, cmds.ErrNormal)
+				return
+			}
+			if first.Err != nil {
+				res.SetError(first.Err, cmds.ErrNormal)
+				return
+			}
+
+			outChan := make(chan interface{})
+			res.SetOutput((<-chan interface{})(outChan))
+
+			go func() {
+				defer close(outChan)
+
+				for r := first; ok; r, ok = <-results {
+					if r.Err != nil {
+						log.Debugf("name resolve: dropping failed intermediate result: %s", r.Err)
+						continue
+					}
+
+					select {
+					case outChan <- &ResolvedPath{r.Path}:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}()
+			return
+		}
+
+		output, err := resolver.ResolveN(ctx, name, depth)
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
@@ -125,11 +274,23 @@ Resolve the value of a dnslink:
 	},
 	Marshalers: cmds.MarshalerMap{
 		cmds.Text: func(res cmds.Response) (io.Reader, error) {
-			output, ok := res.Output().(*ResolvedPath)
-			if !ok {
-				return nil, u.ErrCast()
+			marshal := func(v interface{}) (io.Reader, error) {
+				output, ok := v.(*ResolvedPath)
+				if !ok {
+					return nil, u.ErrCast()
+				}
+				return strings.NewReader(output.Path.String() + "\n"), nil
+			}
+
+			if outChan, ok := res.Output().(<-chan interface{}); ok {
+				return &cmds.ChannelMarshaler{
+					Channel:   outChan,
+					Marshaler: marshal,
+					Res:       res,
+				}, nil
 			}
-			return strings.NewReader(output.Path.String() + "\n"), nil
+
+			return marshal(res.Output())
 		},
 	},
 	Type: ResolvedPath{},
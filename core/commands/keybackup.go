@@ -0,0 +1,324 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	files "github.com/ipfs/go-ipfs/commands/files"
+	keystore "github.com/ipfs/go-ipfs/keystore"
+
+	ci "gx/ipfs/QmP1DfoUjiWH2ZBo1PBH6FupdBucbDepx3HpWmEY6JMUpY/go-libp2p-crypto"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+var keyBackupCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Write the keys to an encrypted backup.",
+		ShortDescription: `
+'ipfs key backup' writes the keys named, or all of them with --all, to a
+single file: a tar archive of the keys, encrypted with a key derived from a
+passphrase by scrypt, as 'ipfs key encrypt' does. The key of the node,
+'self', is only included with --include-self.
+
+The passphrase is read from the IPFS_KEY_PASSPHRASE environment variable, or
+else typed on the terminal, twice, and sent to the daemon with --passphrase,
+which encrypts the keys: they never leave it in cleartext. Callers of the API
+must give --passphrase.
+
+  > ipfs key backup --all -o keys.tar.age
+  > ipfs key restore keys.tar.age
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("name", false, true, "Names of the keys to back up."),
+	},
+	Options: []cmds.Option{
+		cmds.StringOption("output", "o", "The path where the backup should be written.").Default("keys.backup"),
+		cmds.BoolOption("all", "a", "Back up all the keys of the keystore.").Default(false),
+		cmds.BoolOption("include-self", "Back up the key of the node, 'self', too.").Default(false),
+		cmds.StringOption(keyPassphraseOptionName, "The passphrase to encrypt the backup with. Read by the client if not given."),
+	},
+	PreRun: func(req cmds.Request) error {
+		outPath, _, _ := req.Option("output").String()
+		if _, err := os.Stat(outPath); err == nil {
+			return fmt.Errorf("%s already exists", outPath)
+		}
+		return setKeyPassphrase(req, outPath)
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		ks, err := req.InvocContext().GetKeystore()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		passphrase, _, _ := req.Option(keyPassphraseOptionName).String()
+		if passphrase == "" {
+			res.SetError(errKeyPassphraseMissing, cmds.ErrClient)
+			return
+		}
+
+		all, _, _ := req.Option("all").Bool()
+		includeSelf, _, _ := req.Option("include-self").Bool()
+
+		names := req.Arguments()
+		if all {
+			listed, err := ks.List()
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+			sort.Strings(listed)
+			names = append(names, listed...)
+		}
+		if includeSelf {
+			names = append([]string{"self"}, names...)
+		}
+		if len(names) == 0 {
+			res.SetError(errors.New("name the keys to back up, or give --all"), cmds.ErrClient)
+			return
+		}
+
+		var keys []keystore.BackupKey
+		seen := make(map[string]bool)
+		for _, name := range names {
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+
+			var sk ci.PrivKey
+			if name == "self" {
+				if !includeSelf {
+					res.SetError(errors.New("the key of the node is only backed up with --include-self"), cmds.ErrClient)
+					return
+				}
+				sk, err = selfKey(req)
+			} else if err = keystore.CheckUse(ks, name, keystore.UseExport); err == nil {
+				sk, err = ks.Get(name)
+			}
+			if err == keystore.ErrNoSuchKey {
+				res.SetError(fmt.Errorf("no key named %s was found", name), cmds.ErrNormal)
+				return
+			}
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+			keys = append(keys, keystore.BackupKey{Name: name, Key: sk})
+		}
+
+		archive, err := keystore.MarshalBackup(keys)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		b, err := keystore.EncryptBackup(archive, []byte(passphrase))
+		if err != nil {
+			res.SetError(err, cmds.ErrClient)
+			return
+		}
+		res.SetOutput(bytes.NewReader(b))
+	},
+	PostRun: func(req cmds.Request, res cmds.Response) {
+		if res.Error() != nil || res.Output() == nil {
+			return
+		}
+		outReader := res.Output().(io.Reader)
+		res.SetOutput(nil)
+
+		outPath, _, _ := req.Option("output").String()
+
+		b, err := ioutil.ReadAll(outReader)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		if err := ioutil.WriteFile(outPath, b, 0600); err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		// the keys backed up are listed from the backup written
+		passphrase, _, _ := req.Option(keyPassphraseOptionName).String()
+		archive, err := keystore.DecryptBackup(b, []byte(passphrase))
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		keys, err := keystore.UnmarshalBackup(archive)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		out := &KeyOutputList{Keys: make([]KeyOutput, 0, len(keys)), Path: outPath}
+		for _, k := range keys {
+			pid, err := peer.IDFromPrivateKey(k.Key)
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+			out.Keys = append(out.Keys, KeyOutput{Name: k.Name, Id: pid.Pretty()})
+		}
+		res.SetOutput(out)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			list, err := keyOutputList(res)
+			if err != nil {
+				return nil, err
+			}
+
+			buf := new(bytes.Buffer)
+			for _, k := range list.Keys {
+				fmt.Fprintf(buf, "backed up %s\n", k.Name)
+			}
+			fmt.Fprintf(buf, "Wrote %d keys to %s\n", len(list.Keys), list.Path)
+			return buf, nil
+		},
+	},
+}
+
+var keyRestoreCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Add the keys of a backup to the keystore.",
+		ShortDescription: `
+'ipfs key restore' adds the keys of a backup of 'ipfs key backup' to the
+keystore. The keys whose names are already taken are skipped. The key of the
+node, 'self', is only restored with --self-as, as a key of the keystore under
+that name, to publish the names of the old node with; the node keeps its own
+key.
+
+The passphrase is read from the IPFS_KEY_PASSPHRASE environment variable, or
+else typed on the terminal. The backup is decrypted by the client, and sent
+to the daemon as a tar archive.
+
+  > ipfs key restore keys.tar.age --self-as=old-self
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.FileArg("backup", true, false, "The backup to restore.").EnableStdin(),
+	},
+	Options: []cmds.Option{
+		cmds.StringOption("self-as", "Restore the key of the node, 'self', under this name."),
+	},
+	PreRun: func(req cmds.Request) error {
+		file, err := req.Files().NextFile()
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		b, err := ioutil.ReadAll(file)
+		if err != nil {
+			return err
+		}
+		if !keystore.IsBackup(b) {
+			return fmt.Errorf("%s is not a backup of keys", file.FileName())
+		}
+
+		passphrase, err := KeyPassphrase(file.FileName(), false)
+		if err != nil {
+			return err
+		}
+		archive, err := keystore.DecryptBackup(b, passphrase)
+		if err != nil {
+			return err
+		}
+
+		f := files.NewReaderFile(file.FileName(), file.FullPath(), ioutil.NopCloser(bytes.NewReader(archive)), nil)
+		req.SetFiles(files.NewSliceFile("", "", []files.File{f}))
+		return nil
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		ks, err := req.InvocContext().GetKeystore()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		selfAs, _, _ := req.Option("self-as").String()
+		if selfAs == "self" {
+			res.SetError(errors.New("cannot restore a key with name 'self'"), cmds.ErrClient)
+			return
+		}
+
+		file, err := req.Files().NextFile()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		defer file.Close()
+		archive, err := ioutil.ReadAll(file)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		keys, err := keystore.UnmarshalBackup(archive)
+		if err != nil {
+			res.SetError(fmt.Errorf("cannot read the backup: %s", err), cmds.ErrClient)
+			return
+		}
+
+		// the keys skipped are the ones whose names are taken, and 'self'
+		// without --self-as
+		out := &KeyOutputList{Keys: []KeyOutput{}}
+		for _, k := range keys {
+			name := k.Name
+			if name == "self" {
+				if selfAs == "" {
+					out.Skipped = append(out.Skipped, name)
+					continue
+				}
+				name = selfAs
+			}
+
+			exist, err := ks.Has(name)
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+			if exist {
+				out.Skipped = append(out.Skipped, name)
+				continue
+			}
+
+			pid, err := peer.IDFromPrivateKey(k.Key)
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+			if err := ks.Put(name, k.Key); err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+			out.Keys = append(out.Keys, KeyOutput{Name: name, Id: pid.Pretty()})
+		}
+
+		res.SetOutput(out)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out, err := keyOutputList(res)
+			if err != nil {
+				return nil, err
+			}
+
+			buf := new(bytes.Buffer)
+			for _, k := range out.Keys {
+				fmt.Fprintf(buf, "restored %s %s\n", k.Id, k.Name)
+			}
+			for _, name := range out.Skipped {
+				fmt.Fprintf(buf, "skipped %s\n", name)
+			}
+			return buf, nil
+		},
+	},
+	Type: KeyOutputList{},
+}
@@ -0,0 +1,173 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	namesys "github.com/ipfs/go-ipfs/namesys"
+	pb "github.com/ipfs/go-ipfs/namesys/pb"
+
+	ci "gx/ipfs/QmP1DfoUjiWH2ZBo1PBH6FupdBucbDepx3HpWmEY6JMUpY/go-libp2p-crypto"
+	u "gx/ipfs/QmWbjfz3u6HkAdPh34dgPchGbQjob6LXLhAeCGii2TX69n/go-ipfs-util"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+// KeyInfoOutput is the output of 'ipfs key info'.
+type KeyInfoOutput struct {
+	KeyOutput
+	// PublicKey is the public key, marshalled as in the peer ID, in base64
+	// or in multibase.
+	PublicKey string
+	// Published is the IPNS record of the key, if one was published.
+	Published *KeyPublished `json:",omitempty"`
+}
+
+// KeyPublished is the IPNS record published with a key.
+type KeyPublished struct {
+	Value    string
+	Sequence uint64
+	Expires  time.Time
+	Expired  bool
+}
+
+var keyInfoCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Show the details of a key.",
+		ShortDescription: `
+'ipfs key info' shows the peer ID of a key, its public key, type, size,
+creation time and expiry, if any, and the IPNS record last published with it, if any, as kept
+by the node. The record is only said to be expired past its lifetime: the
+peers of the network may have dropped it earlier.
+
+The public key is written in base64, or in multibase base64 with
+--multibase.
+
+  > ipfs key info mykey
+  name:       mykey
+  id:         QmKeyID1
+  type:       ed25519
+  size:       256
+  created:    2017-06-01T12:00:00Z
+  public key: CAESIJ...
+  published:  /ipfs/QmSomeHash, sequence 3, expires 2017-06-02T12:00:00Z
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("name", true, false, "Name of the key, or 'self'."),
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption("multibase", "Write the public key in multibase base64.").Default(false),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		name := req.Arguments()[0]
+		sk, err := namedKey(n, name)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		info, err := keyInfo(n.Repo.Keystore(), name, sk)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		out := &KeyInfoOutput{KeyOutput: info}
+
+		pkb, err := ci.MarshalPublicKey(sk.GetPublic())
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		if mb, _, _ := req.Option("multibase").Bool(); mb {
+			// 'm' is the multibase prefix of base64 without padding
+			out.PublicKey = "m" + base64.RawStdEncoding.EncodeToString(pkb)
+		} else {
+			out.PublicKey = base64.StdEncoding.EncodeToString(pkb)
+		}
+
+		id, err := peer.IDB58Decode(info.Id)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		rec, err := namesys.LocalRecord(n.Repo.Datastore(), id)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		if rec != nil {
+			out.Published, err = keyPublished(rec)
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+		}
+
+		res.SetOutput(out)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out, ok := unwrapOutput(res.Output()).(*KeyInfoOutput)
+			if !ok {
+				return nil, u.ErrCast()
+			}
+
+			created := "-"
+			if out.CreatedAt != nil {
+				created = out.CreatedAt.Format(time.RFC3339)
+			}
+			published := "no"
+			if p := out.Published; p != nil {
+				state := "expires"
+				if p.Expired {
+					state = "expired"
+				}
+				published = fmt.Sprintf("%s, sequence %d, %s %s", p.Value, p.Sequence, state, p.Expires.Format(time.RFC3339))
+			}
+
+			buf := new(bytes.Buffer)
+			w := tabwriter.NewWriter(buf, 1, 2, 1, ' ', 0)
+			fmt.Fprintf(w, "name:\t%s\n", out.Name)
+			fmt.Fprintf(w, "id:\t%s\n", out.Id)
+			fmt.Fprintf(w, "type:\t%s\n", out.Type)
+			fmt.Fprintf(w, "size:\t%d\n", out.Size)
+			fmt.Fprintf(w, "created:\t%s\n", created)
+			if out.ExpiresAt != nil {
+				fmt.Fprintf(w, "expiry:\t%s\n", expiry(out.ExpiresAt, time.Now()))
+			}
+			fmt.Fprintf(w, "public key:\t%s\n", out.PublicKey)
+			fmt.Fprintf(w, "published:\t%s\n", published)
+			w.Flush()
+			return buf, nil
+		},
+	},
+	Type: KeyInfoOutput{},
+}
+
+// keyPublished describes the IPNS record rec.
+func keyPublished(rec *pb.IpnsEntry) (*KeyPublished, error) {
+	p := &KeyPublished{
+		Value:    string(rec.GetValue()),
+		Sequence: rec.GetSequence(),
+	}
+	if rec.GetValidityType() == pb.IpnsEntry_EOL {
+		eol, err := u.ParseRFC3339(string(rec.GetValidity()))
+		if err != nil {
+			return nil, err
+		}
+		p.Expires = eol
+		p.Expired = time.Now().After(eol)
+	}
+	return p, nil
+}
@@ -0,0 +1,215 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	keystore "github.com/ipfs/go-ipfs/keystore"
+	namesys "github.com/ipfs/go-ipfs/namesys"
+)
+
+// keyProofTXTPrefix is prepended to the domain of DNS identities to find the
+// TXT records confirming them.
+const keyProofTXTPrefix = "_ipfs-key."
+
+// maxKeyProofSize bounds the size of the proofs fetched from URLs.
+const maxKeyProofSize = 64 << 10
+
+type KeyProofCheck struct {
+	Key      string
+	Identity string
+	// Confirmed is whether the owner of the identity confirmed the proof.
+	Confirmed bool
+}
+
+var keyProveCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Sign a proof that a key belongs to a domain name or URL.",
+		ShortDescription: `
+'ipfs key prove' outputs a statement, signed by the given key, that the key
+belongs to an identity, so that others can trust the IPNS names published
+with it as much as they trust the identity.
+
+The identity is either a domain name or an http(s) URL, and its owner has to
+confirm the statement for 'ipfs key verify-proof' to accept it:
+
+  - for a domain name, such as example.com, with a TXT record of
+    _ipfs-key.example.com set to "ipfs-key=<key id>".
+  - for a URL, such as https://example.com/.well-known/ipfs-key, by serving
+    the proof at that URL.
+
+  > ipfs key prove mykey https://example.com/.well-known/ipfs-key > proof.json
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("name", true, false, "Name of the key to prove."),
+		cmds.StringArg("identity", true, false, "Domain name or URL the key belongs to."),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		sk, err := usableKey(n, req.Arguments()[0], keystore.UseSign)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		proof, err := keystore.NewProof(sk, req.Arguments()[1], time.Now())
+		if err != nil {
+			res.SetError(err, cmds.ErrClient)
+			return
+		}
+
+		res.SetOutput(proof)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			proof, ok := unwrapOutput(res.Output()).(*keystore.Proof)
+			if !ok {
+				return nil, fmt.Errorf("expected a key proof as command result")
+			}
+
+			// the text output is the proof as it is to be served
+			b, err := json.MarshalIndent(proof, "", "  ")
+			if err != nil {
+				return nil, err
+			}
+			return bytes.NewReader(append(b, '\n')), nil
+		},
+	},
+	Type: keystore.Proof{},
+}
+
+var keyVerifyProofCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Verify a proof made by 'ipfs key prove'.",
+		ShortDescription: `
+'ipfs key verify-proof' checks the signature of a key proof, then that the
+owner of its identity confirmed it, see 'ipfs key prove --help'. With
+--signature-only, the identity is not checked, which only shows that the
+holder of the key claims it.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.FileArg("proof", true, false, "The proof to verify.").EnableStdin(),
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption("signature-only", "Only check the signature of the proof.").Default(false),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		file, err := req.Files().NextFile()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		defer file.Close()
+
+		var proof keystore.Proof
+		if err := json.NewDecoder(file).Decode(&proof); err != nil {
+			res.SetError(fmt.Errorf("invalid key proof: %s", err), cmds.ErrClient)
+			return
+		}
+
+		kind, err := proof.Verify()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		out := &KeyProofCheck{Key: proof.Key, Identity: proof.Identity}
+
+		sigOnly, _, _ := req.Option("signature-only").Bool()
+		if !sigOnly {
+			switch kind {
+			case keystore.IdentityDNS:
+				err = confirmDNSKeyProof(req, &proof)
+			case keystore.IdentityURL:
+				err = confirmURLKeyProof(&proof)
+			}
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+			out.Confirmed = true
+		}
+
+		res.SetOutput(out)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out, ok := unwrapOutput(res.Output()).(*KeyProofCheck)
+			if !ok {
+				return nil, fmt.Errorf("expected a KeyProofCheck as command result")
+			}
+
+			if out.Confirmed {
+				return strings.NewReader(fmt.Sprintf("%s belongs to %s\n", out.Key, out.Identity)), nil
+			}
+			return strings.NewReader(fmt.Sprintf("%s claims to belong to %s, unconfirmed\n", out.Key, out.Identity)), nil
+		},
+	},
+	Type: KeyProofCheck{},
+}
+
+func confirmDNSKeyProof(req cmds.Request, proof *keystore.Proof) error {
+	cfg, err := req.InvocContext().GetConfig()
+	if err != nil {
+		return err
+	}
+
+	lookup, err := namesys.NewLookupTXT(cfg.DNS.Resolvers)
+	if err != nil {
+		return err
+	}
+
+	name := keyProofTXTPrefix + proof.Identity
+	txts, err := lookup(name)
+	if err != nil {
+		return fmt.Errorf("could not look up %s: %s", name, err)
+	}
+
+	for _, txt := range txts {
+		if strings.TrimSpace(txt) == "ipfs-key="+proof.Key {
+			return nil
+		}
+	}
+	return fmt.Errorf("no TXT record of %s confirms the proof", name)
+}
+
+func confirmURLKeyProof(proof *keystore.Proof) error {
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(proof.Identity)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetching %s: %s", proof.Identity, resp.Status)
+	}
+
+	var served keystore.Proof
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxKeyProofSize)).Decode(&served); err != nil {
+		return fmt.Errorf("%s does not serve a key proof: %s", proof.Identity, err)
+	}
+
+	// the served proof may be a newer one, as long as it is valid and for
+	// the same key and identity
+	if _, err := served.Verify(); err != nil {
+		return fmt.Errorf("proof served by %s: %s", proof.Identity, err)
+	}
+	if served.Key != proof.Key || served.Identity != proof.Identity {
+		return fmt.Errorf("%s serves a proof for %s instead", proof.Identity, served.Key)
+	}
+	return nil
+}
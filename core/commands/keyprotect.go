@@ -0,0 +1,152 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	keystore "github.com/ipfs/go-ipfs/keystore"
+
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+var keyProtectCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Restrict what a key is used for.",
+		ShortDescription: `
+'ipfs key protect' sets the policy of a key: the uses allowed, among
+'publish' (IPNS records), 'sign' ('ipfs key sign', 'ipfs key prove' and
+'ipfs swarm cert issue') and 'export' ('ipfs key export' and 'ipfs key
+backup'), and how many records it publishes in an hour at most. The other
+uses are refused, whoever asks, so that a caller of the API can't use every
+key for every purpose. Without options, the policy of the key is shown.
+
+The key of the node, 'self', has no policy.
+
+  > ipfs key protect site --allow=publish --publishes-per-hour=10
+  > ipfs key protect release-signer --allow=sign
+  > ipfs key protect site --remove
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("name", true, false, "Name of the key."),
+	},
+	Options: []cmds.Option{
+		cmds.StringOption("allow", "The uses allowed, separated by commas: publish, sign, export."),
+		cmds.IntOption("publishes-per-hour", "The most records published in an hour, 0 for no limit."),
+		cmds.BoolOption("remove", "Remove the policy, allowing every use.").Default(false),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		ks, err := req.InvocContext().GetKeystore()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		name := req.Arguments()[0]
+		if name == "self" {
+			res.SetError(errors.New("the key of the node has no policy"), cmds.ErrClient)
+			return
+		}
+		mks, ok := ks.(keystore.MetadataKeystore)
+		if !ok {
+			res.SetError(errors.New("the keystore doesn't record the policies of keys"), cmds.ErrNormal)
+			return
+		}
+
+		sk, err := ks.Get(name)
+		if err == keystore.ErrNoSuchKey {
+			res.SetError(fmt.Errorf("no key named %s was found", name), cmds.ErrNormal)
+			return
+		}
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		md, err := mks.Metadata(name)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		if md == nil {
+			md = &keystore.Metadata{}
+		}
+
+		allow, allowFound, _ := req.Option("allow").String()
+		rate, rateFound, _ := req.Option("publishes-per-hour").Int()
+		remove, _, _ := req.Option("remove").Bool()
+
+		switch {
+		case remove && (allowFound || rateFound):
+			res.SetError(errors.New("--remove can't be given with --allow or --publishes-per-hour"), cmds.ErrClient)
+			return
+		case remove:
+			md.Policy = nil
+		case allowFound || rateFound:
+			p := md.Policy
+			if p == nil {
+				// a new policy allows what it isn't told to forbid
+				p = &keystore.Policy{Uses: keystore.Uses}
+			}
+			if allowFound {
+				p.Uses = nil
+				for _, use := range strings.Split(allow, ",") {
+					if use = strings.TrimSpace(use); use != "" {
+						p.Uses = append(p.Uses, use)
+					}
+				}
+			}
+			if rateFound {
+				p.PublishesPerHour = rate
+			}
+			if err := p.Validate(); err != nil {
+				res.SetError(err, cmds.ErrClient)
+				return
+			}
+			md.Policy = p
+		}
+
+		if remove || allowFound || rateFound {
+			if err := mks.SetMetadata(name, md); err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+		}
+
+		pid, err := peer.IDFromPrivateKey(sk)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		res.SetOutput(&KeyOutputList{Keys: []KeyOutput{{Name: name, Id: pid.Pretty(), Policy: md.Policy}}})
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			list, err := keyOutputList(res)
+			if err != nil {
+				return nil, err
+			}
+
+			buf := new(bytes.Buffer)
+			for _, k := range list.Keys {
+				p := k.Policy
+				switch {
+				case p == nil:
+					fmt.Fprintf(buf, "%s: no policy, every use is allowed\n", k.Name)
+				case len(p.Uses) == 0:
+					fmt.Fprintf(buf, "%s: no use is allowed\n", k.Name)
+				default:
+					fmt.Fprintf(buf, "%s: allowed to %s\n", k.Name, strings.Join(p.Uses, ", "))
+				}
+				if p != nil && p.PublishesPerHour > 0 {
+					fmt.Fprintf(buf, "%s: at most %d records published an hour\n", k.Name, p.PublishesPerHour)
+				}
+			}
+			return buf, nil
+		},
+	},
+	Type: KeyOutputList{},
+}
@@ -0,0 +1,185 @@
+package commands
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	files "github.com/ipfs/go-ipfs/commands/files"
+	corerepo "github.com/ipfs/go-ipfs/core/corerepo"
+	keystore "github.com/ipfs/go-ipfs/keystore"
+
+	ci "gx/ipfs/QmP1DfoUjiWH2ZBo1PBH6FupdBucbDepx3HpWmEY6JMUpY/go-libp2p-crypto"
+	u "gx/ipfs/QmWbjfz3u6HkAdPh34dgPchGbQjob6LXLhAeCGii2TX69n/go-ipfs-util"
+)
+
+var keyRotateCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Replace the key of the node, and its peer ID.",
+		ShortDescription: `
+'ipfs key rotate' generates a new key for the node, the 'self' key, and
+saves it in the config with the new peer ID. The node is known by the new
+peer ID from its next start; the peers and names it is known to by the old
+one won't follow.
+
+The old key is lost unless it is kept in the keystore under a name, with
+--oldkey, to keep publishing the names published with it:
+
+  > ipfs key rotate --oldkey=old-self
+  > ipfs name publish --key=old-self /ipfs/QmSomeHash
+
+The new key is read from a file if one is given, such as the key of another
+node written by 'ipfs key export self', to take over its identity. Encrypted
+exports are decrypted by the client, with the passphrase read from the
+IPFS_KEY_PASSPHRASE environment variable, or else typed on the terminal.
+
+  > ipfs key rotate primary.backup
+
+The daemon must not be running. If the rotation is interrupted, the daemon
+refuses to start until 'ipfs key rotate' is run again to complete it.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.FileArg("key", false, false, "A file of the new key, rather than generating one."),
+	},
+	Options: []cmds.Option{
+		cmds.StringOption("oldkey", "o", "Keep the old key in the keystore under this name."),
+		cmds.StringOption("type", "t", "The type of the new key [rsa, ed25519].").Default("rsa"),
+		cmds.IntOption("size", "s", "The size of the new key, for rsa.").Default(defaultRSAKeySize),
+	},
+	PreRun: func(req cmds.Request) error {
+		file, err := rotationKeyFile(req)
+		if file == nil || err != nil {
+			return err
+		}
+		defer file.Close()
+		b, err := ioutil.ReadAll(file)
+		if err != nil {
+			return err
+		}
+
+		// the exports of the key of a node are backups holding it
+		if keystore.IsBackup(b) {
+			passphrase, err := KeyPassphrase(file.FileName(), false)
+			if err != nil {
+				return err
+			}
+			archive, err := keystore.DecryptBackup(b, passphrase)
+			if err != nil {
+				return err
+			}
+			keys, err := keystore.UnmarshalBackup(archive)
+			if err != nil {
+				return err
+			}
+			if len(keys) != 1 {
+				return fmt.Errorf("%s holds %d keys, expected the key of a node", file.FileName(), len(keys))
+			}
+			b, err = keys[0].Key.Bytes()
+			if err != nil {
+				return err
+			}
+		}
+
+		f := files.NewReaderFile(file.FileName(), file.FullPath(), ioutil.NopCloser(bytes.NewReader(b)), nil)
+		req.SetFiles(files.NewSliceFile("", "", []files.File{f}))
+		return nil
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		oldKey, _, _ := req.Option("oldkey").String()
+		if oldKey == "self" {
+			res.SetError(fmt.Errorf("cannot keep the old key under the name 'self'"), cmds.ErrClient)
+			return
+		}
+
+		typ, _, _ := req.Option("type").String()
+		size, _, _ := req.Option("size").Int()
+
+		var sk ci.PrivKey
+		file, err := rotationKeyFile(req)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		if file != nil {
+			defer file.Close()
+			b, err := ioutil.ReadAll(file)
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+			sk, err = keystore.UnmarshalKey(b, "")
+			if err == keystore.ErrPassphraseRequired {
+				err = errors.New("the key is encrypted, import it with 'ipfs key import' first")
+			}
+			if err != nil {
+				res.SetError(err, cmds.ErrClient)
+				return
+			}
+		} else {
+			switch typ {
+			case "rsa":
+				sk, _, err = ci.GenerateKeyPairWithReader(ci.RSA, size, rand.Reader)
+			case "ed25519":
+				sk, _, err = ci.GenerateEd25519Key(rand.Reader)
+			default:
+				res.SetError(fmt.Errorf("unrecognized key type: %s", typ), cmds.ErrClient)
+				return
+			}
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+		}
+
+		rot, err := corerepo.RotateIdentity(n.Repo, sk, oldKey)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		res.SetOutput(rot)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			rot, ok := unwrapOutput(res.Output()).(*corerepo.IdentityRotation)
+			if !ok {
+				return nil, u.ErrCast()
+			}
+
+			buf := new(bytes.Buffer)
+			if rot.Resumed {
+				fmt.Fprintln(buf, "completed the interrupted rotation of the identity")
+			}
+			fmt.Fprintf(buf, "rotated the identity from %s to %s\n", rot.Old, rot.New)
+			if rot.OldKey != "" {
+				fmt.Fprintf(buf, "the old key is kept as %s\n", rot.OldKey)
+			}
+			return buf, nil
+		},
+	},
+	Type: corerepo.IdentityRotation{},
+}
+
+// rotationKeyFile returns the file of the new key given to 'key rotate', or
+// nil if there is none.
+func rotationKeyFile(req cmds.Request) (files.File, error) {
+	if req.Files() == nil {
+		return nil, nil
+	}
+	file, err := req.Files().NextFile()
+	if err == io.EOF {
+		return nil, nil
+	}
+	return file, err
+}
@@ -0,0 +1,145 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	keystore "github.com/ipfs/go-ipfs/keystore"
+
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+type KeySignOutput struct {
+	Key       string
+	Signature string
+}
+
+type KeyVerifyOutput struct {
+	Key string
+}
+
+var keySignCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Sign data with a key.",
+		ShortDescription: `
+'ipfs key sign' signs the data of a file, or of stdin, with a key of the
+keystore, or with the key of the node by default. It outputs the signature
+envelope, which holds the public key of the signer, to be checked with
+'ipfs key verify' against the ID of the key.
+
+  > ipfs key sign --key=mykey release.tar.gz > release.tar.gz.sig
+  > ipfs key verify QmSignerID $(cat release.tar.gz.sig) release.tar.gz
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.FileArg("data", true, false, "The data to sign.").EnableStdin(),
+	},
+	Options: []cmds.Option{
+		cmds.StringOption("key", "k", "Name of the key to sign with.").Default("self"),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		name, _, _ := req.Option("key").String()
+		sk, err := usableKey(n, name, keystore.UseSign)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		file, err := req.Files().NextFile()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		defer file.Close()
+
+		sig, err := keystore.Sign(sk, file)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		pid, err := peer.IDFromPrivateKey(sk)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		res.SetOutput(&KeySignOutput{
+			Key:       pid.Pretty(),
+			Signature: sig.String(),
+		})
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out, ok := unwrapOutput(res.Output()).(*KeySignOutput)
+			if !ok {
+				return nil, fmt.Errorf("expected a KeySignOutput as command result")
+			}
+
+			return strings.NewReader(out.Signature + "\n"), nil
+		},
+	},
+	Type: KeySignOutput{},
+}
+
+var keyVerifyCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Verify the signature of data.",
+		ShortDescription: `
+'ipfs key verify' checks that a signature envelope, as output by 'ipfs key
+sign', is the signature of the data of a file, or of stdin, by the key of the
+given ID. It fails if it is not.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("key", true, false, "ID of the key the data was signed with."),
+		cmds.StringArg("signature", true, false, "The signature envelope."),
+		cmds.FileArg("data", true, false, "The signed data.").EnableStdin(),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		pid, err := peer.IDB58Decode(req.Arguments()[0])
+		if err != nil {
+			res.SetError(fmt.Errorf("invalid key ID: %s", err), cmds.ErrClient)
+			return
+		}
+
+		sig, err := keystore.ParseSignature(req.Arguments()[1])
+		if err != nil {
+			res.SetError(err, cmds.ErrClient)
+			return
+		}
+
+		file, err := req.Files().NextFile()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		defer file.Close()
+
+		if err := sig.Verify(pid, file); err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		res.SetOutput(&KeyVerifyOutput{Key: pid.Pretty()})
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out, ok := unwrapOutput(res.Output()).(*KeyVerifyOutput)
+			if !ok {
+				return nil, fmt.Errorf("expected a KeyVerifyOutput as command result")
+			}
+
+			return strings.NewReader(fmt.Sprintf("signature by %s is valid\n", out.Key)), nil
+		},
+	},
+	Type: KeyVerifyOutput{},
+}
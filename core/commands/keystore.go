@@ -6,11 +6,19 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"os"
 	"sort"
 	"strings"
 	"text/tabwriter"
+	"time"
 
 	cmds "github.com/ipfs/go-ipfs/commands"
+	files "github.com/ipfs/go-ipfs/commands/files"
+	core "github.com/ipfs/go-ipfs/core"
+	keystore "github.com/ipfs/go-ipfs/keystore"
+	config "github.com/ipfs/go-ipfs/repo/config"
+	fsrepo "github.com/ipfs/go-ipfs/repo/fsrepo"
 
 	ci "gx/ipfs/QmP1DfoUjiWH2ZBo1PBH6FupdBucbDepx3HpWmEY6JMUpY/go-libp2p-crypto"
 	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
@@ -30,46 +38,159 @@ var KeyCmd = &cmds.Command{
   > ipfs key list
   self
   mykey
+
+'ipfs key info' shows the details of a key, and the IPNS record published
+with it.
+
+'ipfs key export' and 'ipfs key import' move keys between nodes, or to and
+from other tools with PEM files.
+
+  > ipfs key export mykey -o mykey.key
+  > ipfs key import mykey mykey.key
+
+'ipfs key sign' and 'ipfs key verify' sign data with a key and check the
+signatures against the ID of the key.
+
+  > ipfs key sign --key=mykey file > file.sig
+  > ipfs key verify <key id> $(cat file.sig) file
+
+'ipfs key backup' writes keys to a file encrypted with a passphrase, which
+'ipfs key restore' adds back to a keystore.
+
+  > ipfs key backup --all -o keys.tar.age
+  > ipfs key restore keys.tar.age
+
+'ipfs key protect' restricts what a key is used for, such as only publishing
+IPNS records.
+
+'ipfs key update' sets when a key expires. 'ipfs key list -l' flags the keys
+expired or expiring soon, and 'ipfs name publish' warns when publishing with
+an expired key, or refuses to, as set by Ipns.ExpiredKeys in the config.
+
+'ipfs key encrypt' encrypts the keys with a passphrase.
+
+'ipfs key rotate' replaces the key of the node, and its peer ID.
 		`,
 	},
 	Subcommands: map[string]*cmds.Command{
-		"gen":    keyGenCmd,
-		"list":   keyListCmd,
-		"rename": keyRenameCmd,
-		"rm":     keyRmCmd,
+		"backup":       keyBackupCmd,
+		"encrypt":      keyEncryptCmd,
+		"export":       keyExportCmd,
+		"gen":          keyGenCmd,
+		"import":       keyImportCmd,
+		"info":         keyInfoCmd,
+		"list":         keyListCmd,
+		"protect":      keyProtectCmd,
+		"prove":        keyProveCmd,
+		"rename":       keyRenameCmd,
+		"restore":      keyRestoreCmd,
+		"rm":           keyRmCmd,
+		"rotate":       keyRotateCmd,
+		"sign":         keySignCmd,
+		"update":       keyUpdateCmd,
+		"verify":       keyVerifyCmd,
+		"verify-proof": keyVerifyProofCmd,
 	},
 }
 
+// KeyOutput is a key in the output of the key commands.
 type KeyOutput struct {
 	Name string
 	Id   string
+	// Was is the name a key had before 'key rename', and Overwrite tells
+	// whether it replaced a key of its new name.
+	Was       string `json:",omitempty"`
+	Overwrite bool   `json:",omitempty"`
+	// Type, Size, CreatedAt and ExpiresAt are given by 'key list'. Keys
+	// stored by older versions have no creation time.
+	Type      string     `json:",omitempty"`
+	Size      int        `json:",omitempty"`
+	CreatedAt *time.Time `json:",omitempty"`
+	ExpiresAt *time.Time `json:",omitempty"`
+	// PrivateKey is the key made by 'key gen --output', in the format asked
+	// for, which the client writes out.
+	PrivateKey []byte `json:",omitempty"`
+	// Policy is given by 'key protect'.
+	Policy *keystore.Policy `json:",omitempty"`
 }
 
+// KeyOutputList is the output of all the commands making, listing, moving
+// or removing keys, so that scripts read them alike with --enc=json.
 type KeyOutputList struct {
 	Keys []KeyOutput
+	// Skipped are the keys 'key restore' left out.
+	Skipped []string `json:",omitempty"`
+	// Path is the file 'key export' or 'key backup' wrote the keys to.
+	Path string `json:",omitempty"`
 }
 
-// KeyRenameOutput define the output type of keyRenameCmd
-type KeyRenameOutput struct {
-	Was       string
-	Now       string
-	Id        string
-	Overwrite bool
-}
+// defaultRSAKeySize is the size of the RSA keys generated when no size is
+// given.
+const defaultRSAKeySize = 2048
 
 var keyGenCmd = &cmds.Command{
 	Helptext: cmds.HelpText{
 		Tagline: "Create a new keypair",
+		ShortDescription: `
+'ipfs key gen' creates a keypair of the given type, and stores it in the
+keystore under the given name.
+
+When the keystore is the transit engine of Vault (Keystore.Type "vault" in
+the config), the keypair is created in Vault and its private key never
+leaves it.
+
+With --derive-from-mnemonic, an ed25519 key is derived from a BIP 39
+mnemonic, such as 'ipfs init --new-mnemonic' prints, rather than generated,
+so that it can be recovered from the mnemonic. Keys derived from the same
+mnemonic are told apart by the last index of their derivation paths:
+
+  > ipfs key gen --derive-from-mnemonic="$(cat phrase)" --derivation-path="m/44'/4001'/2'" mykey
+
+With --output, the private key is also written to a file, or to stdout with
+'-', in the format of --format as for 'ipfs key export', such as to set up
+another node; with --no-store, it is only written there, and not kept in the
+keystore. The key is not encrypted: keep the file secret.
+
+  > ipfs key gen --type=ed25519 --no-store --format=pem-pkcs8-cleartext -o node2.pem node2
+
+With --expires-in, the key expires after a duration, such as 90d, or at a
+date, as for 'ipfs key update --expires'.
+
+  > ipfs key gen --type=ed25519 --expires-in=90d release-2017
+`,
 	},
 	Options: []cmds.Option{
-		cmds.StringOption("type", "t", "type of the key to create [rsa, ed25519]"),
-		cmds.IntOption("size", "s", "size of the key to generate"),
+		cmds.StringOption("type", "t", "type of the key to create [rsa, ed25519, secp256k1]"),
+		cmds.IntOption("size", "s", "size of the key to generate, 2048 bits by default for rsa"),
+		cmds.StringOption("derive-from-mnemonic", "Derive an ed25519 key from this BIP 39 mnemonic."),
+		cmds.StringOption("derivation-path", "The path of the key derived from the mnemonic.").Default(keystore.KeyDerivationPath),
+		cmds.StringOption("output", "o", "Also write the private key to this file, or to stdout with '-'."),
+		cmds.StringOption(keyFormatOptionName, "f", "The format of the key written: libp2p-protobuf-cleartext or pem-pkcs8-cleartext.").Default(keystore.FormatLibp2p),
+		cmds.BoolOption("no-store", "Don't keep the key in the keystore, only write it to --output.").Default(false),
+		cmds.StringOption("expires-in", "The key expires after this duration, such as 90d, or at this date."),
+	},
+	PreRun: func(req cmds.Request) error {
+		outPath, _, _ := req.Option("output").String()
+		noStore, _, _ := req.Option("no-store").Bool()
+		if noStore && outPath == "" {
+			return errors.New("--no-store needs --output, or the key would be lost")
+		}
+		if _, found, _ := req.Option("expires-in").String(); found && noStore {
+			return errors.New("the keys not stored have no expiry")
+		}
+		if outPath == "" || outPath == "-" {
+			return nil
+		}
+		if _, err := os.Stat(outPath); err == nil {
+			return fmt.Errorf("%s already exists", outPath)
+		}
+		return nil
 	},
 	Arguments: []cmds.Argument{
 		cmds.StringArg("name", true, false, "name of key to create"),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
-		n, err := req.InvocContext().GetNode()
+		ks, err := req.InvocContext().GetKeystore()
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
@@ -81,7 +202,18 @@ var keyGenCmd = &cmds.Command{
 			return
 		}
 
-		if !f {
+		mnemonic, _, err := req.Option("derive-from-mnemonic").String()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		if mnemonic != "" && f && typ != "ed25519" {
+			res.SetError(fmt.Errorf("only ed25519 keys are derived from mnemonics"), cmds.ErrClient)
+			return
+		}
+
+		if !f && mnemonic == "" {
 			res.SetError(fmt.Errorf("please specify a key type with --type"), cmds.ErrNormal)
 			return
 		}
@@ -98,83 +230,669 @@ var keyGenCmd = &cmds.Command{
 			return
 		}
 
+		outPath, _, _ := req.Option("output").String()
+		format, _, _ := req.Option(keyFormatOptionName).String()
+		noStore, _, _ := req.Option("no-store").Bool()
+
+		var expires *time.Time
+		if s, found, _ := req.Option("expires-in").String(); found {
+			expires, err = keystore.ParseExpiry(s, time.Now())
+			if err != nil {
+				res.SetError(err, cmds.ErrClient)
+				return
+			}
+			if _, ok := ks.(keystore.MetadataKeystore); !ok {
+				res.SetError(errors.New("the keystore doesn't record the expiry of keys"), cmds.ErrNormal)
+				return
+			}
+		}
+
+		// the key is written out and stored once made
+		keep := func(sk ci.PrivKey) {
+			var b []byte
+			if outPath != "" {
+				b, err = keystore.MarshalKey(sk, format)
+				if err != nil {
+					res.SetError(err, cmds.ErrClient)
+					return
+				}
+			}
+			if !noStore {
+				if err := ks.Put(name, sk); err != nil {
+					res.SetError(err, cmds.ErrNormal)
+					return
+				}
+				if expires != nil {
+					if err := setKeyExpiry(ks, name, expires); err != nil {
+						res.SetError(err, cmds.ErrNormal)
+						return
+					}
+				}
+			}
+
+			setKeyGenOutput(res, name, sk.GetPublic())
+			if list, ok := res.Output().(*KeyOutputList); ok {
+				list.Keys[0].PrivateKey = b
+				list.Keys[0].ExpiresAt = expires
+			}
+		}
+
+		if mnemonic != "" {
+			path, _, _ := req.Option("derivation-path").String()
+			sk, err := keystore.KeyFromMnemonic(mnemonic, path)
+			if err != nil {
+				res.SetError(err, cmds.ErrClient)
+				return
+			}
+
+			keep(sk)
+			return
+		}
+
+		if gks, ok := ks.(keystore.GeneratingKeystore); ok {
+			if outPath != "" {
+				res.SetError(errors.New("the keys created in the keystore never leave it, and cannot be written out"), cmds.ErrClient)
+				return
+			}
+			// the key is created in the keystore, and never leaves it
+			if typ == "rsa" && !sizefound {
+				size = defaultRSAKeySize
+			}
+
+			sk, err := gks.Generate(name, typ, size)
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+			if expires != nil {
+				if err := setKeyExpiry(ks, name, expires); err != nil {
+					res.SetError(err, cmds.ErrNormal)
+					return
+				}
+			}
+
+			setKeyGenOutput(res, name, sk.GetPublic())
+			return
+		}
+
 		var sk ci.PrivKey
-		var pk ci.PubKey
 
 		switch typ {
 		case "rsa":
 			if !sizefound {
-				res.SetError(fmt.Errorf("please specify a key size with --size"), cmds.ErrNormal)
-				return
+				size = defaultRSAKeySize
 			}
 
-			priv, pub, err := ci.GenerateKeyPairWithReader(ci.RSA, size, rand.Reader)
+			priv, _, err := ci.GenerateKeyPairWithReader(ci.RSA, size, rand.Reader)
 			if err != nil {
 				res.SetError(err, cmds.ErrNormal)
 				return
 			}
 
 			sk = priv
-			pk = pub
 		case "ed25519":
-			priv, pub, err := ci.GenerateEd25519Key(rand.Reader)
+			priv, _, err := ci.GenerateEd25519Key(rand.Reader)
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+
+			sk = priv
+		case "secp256k1":
+			if sizefound && size != 256 {
+				res.SetError(fmt.Errorf("secp256k1 keys are 256 bits, not %d", size), cmds.ErrClient)
+				return
+			}
+
+			priv, _, err := ci.GenerateSecp256k1Key(rand.Reader)
 			if err != nil {
 				res.SetError(err, cmds.ErrNormal)
 				return
 			}
 
 			sk = priv
-			pk = pub
 		default:
 			res.SetError(fmt.Errorf("unrecognized key type: %s", typ), cmds.ErrNormal)
 			return
 		}
 
-		err = n.Repo.Keystore().Put(name, sk)
+		keep(sk)
+	},
+	PostRun: func(req cmds.Request, res cmds.Response) {
+		if res.Error() != nil || res.Output() == nil {
+			return
+		}
+		list, err := keyOutputList(res)
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
 		}
+		res.SetOutput(list)
+		if len(list.Keys) != 1 || list.Keys[0].PrivateKey == nil {
+			return
+		}
+		k := &list.Keys[0]
+		b := k.PrivateKey
+		k.PrivateKey = nil
 
-		pid, err := peer.IDFromPublicKey(pk)
+		outPath, _, _ := req.Option("output").String()
+		if outPath == "-" {
+			// the key takes stdout, the ID goes to stderr
+			os.Stdout.Write(b)
+			fmt.Fprintf(os.Stderr, "Generated key %s\n", k.Id)
+			res.SetOutput(nil)
+			return
+		}
+
+		if err := ioutil.WriteFile(outPath, b, 0600); err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: keyIDMarshaler,
+	},
+	Type: KeyOutputList{},
+}
+
+var keyEncryptCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Encrypt the keystore with a passphrase",
+		ShortDescription: `
+'ipfs key encrypt' encrypts the keys of the keystore, and the ones created
+after, with a key derived from a passphrase. The key of the node, 'self', is
+not in the keystore and stays in the config.
+
+The passphrase is read from the IPFS_KEYSTORE_PASSPHRASE environment
+variable, or else typed on the terminal. It is asked for again when a key is
+first needed, and by 'ipfs daemon' on start. It cannot be recovered: the keys
+are lost with it.
+
+The daemon must not be running.
+`,
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		var ks *keystore.FSKeystore
+		switch k := n.Repo.Keystore().(type) {
+		case *keystore.FSKeystore:
+			ks = k
+		case *keystore.EncryptedKeystore:
+			res.SetError(errors.New("the keystore is already encrypted"), cmds.ErrNormal)
+			return
+		default:
+			res.SetError(errors.New("the keystore cannot be encrypted"), cmds.ErrNormal)
+			return
+		}
+
+		passphrase, err := fsrepo.KeystorePassphrase(true)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		enc, err := keystore.NewEncryption(passphrase)
 		if err != nil {
+			res.SetError(err, cmds.ErrClient)
+			return
+		}
+
+		// the config is saved first: the keys are read whether they are
+		// encrypted yet or not
+		cfg, err := n.Repo.Config()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		cfg.Keystore.Encryption = &config.KeystoreEncryption{
+			KDF:   enc.KDF,
+			Salt:  enc.Salt,
+			N:     enc.N,
+			R:     enc.R,
+			P:     enc.P,
+			Check: enc.Check,
+		}
+		if err := n.Repo.SetConfig(cfg); err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
 		}
 
-		res.SetOutput(&KeyOutput{
-			Name: name,
-			Id:   pid.Pretty(),
+		eks := keystore.NewEncryptedKeystore(ks, enc, func() ([]byte, error) {
+			return passphrase, nil
 		})
+		names, err := eks.EncryptAll()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		sort.Strings(names)
+		list := make([]KeyOutput, 0, len(names))
+		for _, name := range names {
+			sk, err := eks.Get(name)
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+			pid, err := peer.IDFromPrivateKey(sk)
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+			list = append(list, KeyOutput{Name: name, Id: pid.Pretty()})
+		}
+
+		res.SetOutput(&KeyOutputList{Keys: list})
 	},
 	Marshalers: cmds.MarshalerMap{
 		cmds.Text: func(res cmds.Response) (io.Reader, error) {
-			k, ok := res.Output().(*KeyOutput)
-			if !ok {
-				return nil, fmt.Errorf("expected a KeyOutput as command result")
+			list, err := keyOutputList(res)
+			if err != nil {
+				return nil, err
 			}
 
-			return strings.NewReader(k.Id + "\n"), nil
+			buf := new(bytes.Buffer)
+			for _, k := range list.Keys {
+				fmt.Fprintf(buf, "encrypted %s %s\n", k.Id, k.Name)
+			}
+			return buf, nil
 		},
 	},
-	Type: KeyOutput{},
+	Type: KeyOutputList{},
+}
+
+const keyFormatOptionName = "format"
+
+var keyExportCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Export a keypair",
+		ShortDescription: `
+'ipfs key export' writes the private key of a keypair to a file, <name>.key
+by default. The key is not encrypted: keep the file secret.
+
+The formats are 'libp2p-protobuf-cleartext', the one of the keystore, and
+'pem-pkcs8-cleartext', a PEM file such as OpenSSL reads and writes.
+
+  > ipfs key export mykey --format=pem-pkcs8-cleartext -o mykey.pem
+
+The key of the node, 'self', is only exported with --i-know-what-im-doing:
+whoever has it can take the identity of the node. It is written to
+self.backup by default, encrypted by the daemon with a passphrase as by 'ipfs
+key backup', unless --cleartext is given. 'ipfs key rotate' makes it the key of another
+node, such as a standby one:
+
+  > ipfs key export self --i-know-what-im-doing
+  > ipfs key rotate self.backup
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("name", true, false, "name of key to export"),
+	},
+	Options: []cmds.Option{
+		cmds.StringOption("output", "o", "The path where the key should be written."),
+		cmds.StringOption(keyFormatOptionName, "f", "The format of the key: libp2p-protobuf-cleartext or pem-pkcs8-cleartext.").Default(keystore.FormatLibp2p),
+		cmds.BoolOption("i-know-what-im-doing", "Export the key of the node, 'self'.").Default(false),
+		cmds.BoolOption("cleartext", "Write the key of the node unencrypted, in --format.").Default(false),
+		cmds.StringOption(keyPassphraseOptionName, "The passphrase to encrypt the key of the node with. Read by the client if not given."),
+	},
+	PreRun: func(req cmds.Request) error {
+		if !exportsEncrypted(req) {
+			return nil
+		}
+		return setKeyPassphrase(req, keyExportPath(req))
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		name := req.Arguments()[0]
+		format, _, _ := req.Option(keyFormatOptionName).String()
+		confirmed, _, _ := req.Option("i-know-what-im-doing").Bool()
+		if name == "self" && !confirmed {
+			res.SetError(errors.New("the key of the node gives its identity to whoever has it, export it with --i-know-what-im-doing"), cmds.ErrClient)
+			return
+		}
+
+		sk, err := usableKey(n, name, keystore.UseExport)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		var b []byte
+		if exportsEncrypted(req) {
+			passphrase, _, _ := req.Option(keyPassphraseOptionName).String()
+			if passphrase == "" {
+				res.SetError(errKeyPassphraseMissing, cmds.ErrClient)
+				return
+			}
+			b, err = keystore.MarshalBackup([]keystore.BackupKey{{Name: name, Key: sk}})
+			if err == nil {
+				b, err = keystore.EncryptBackup(b, []byte(passphrase))
+			}
+		} else {
+			b, err = keystore.MarshalKey(sk, format)
+		}
+		if err != nil {
+			res.SetError(err, cmds.ErrClient)
+			return
+		}
+
+		res.SetOutput(bytes.NewReader(b))
+	},
+	PostRun: func(req cmds.Request, res cmds.Response) {
+		if res.Error() != nil || res.Output() == nil {
+			return
+		}
+		outReader := res.Output().(io.Reader)
+		res.SetOutput(nil)
+
+		encrypted := exportsEncrypted(req)
+		outPath := keyExportPath(req)
+
+		if _, err := os.Stat(outPath); err == nil {
+			res.SetError(fmt.Errorf("%s already exists", outPath), cmds.ErrNormal)
+			return
+		}
+
+		b, err := ioutil.ReadAll(outReader)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		k := KeyOutput{Name: req.Arguments()[0]}
+		var sk ci.PrivKey
+		if encrypted {
+			passphrase, _, _ := req.Option(keyPassphraseOptionName).String()
+			archive, err := keystore.DecryptBackup(b, []byte(passphrase))
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+			keys, err := keystore.UnmarshalBackup(archive)
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+			if len(keys) != 1 {
+				res.SetError(fmt.Errorf("expected 1 key, got %d", len(keys)), cmds.ErrNormal)
+				return
+			}
+			sk = keys[0].Key
+		} else {
+			format, _, _ := req.Option(keyFormatOptionName).String()
+			sk, _ = keystore.UnmarshalKey(b, format)
+		}
+
+		if err := ioutil.WriteFile(outPath, b, 0600); err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		if sk != nil {
+			if pid, err := peer.IDFromPrivateKey(sk); err == nil {
+				k.Id = pid.Pretty()
+			}
+		}
+		res.SetOutput(&KeyOutputList{Keys: []KeyOutput{k}, Path: outPath})
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			list, err := keyOutputList(res)
+			if err != nil {
+				return nil, err
+			}
+
+			buf := new(bytes.Buffer)
+			for _, k := range list.Keys {
+				fmt.Fprintf(buf, "Exported key %s to %s\n", k.Name, list.Path)
+			}
+			return buf, nil
+		},
+	},
+}
+
+// exportsEncrypted tells whether 'key export' encrypts the key, as it does
+// the key of the node unless told not to.
+func exportsEncrypted(req cmds.Request) bool {
+	cleartext, _, _ := req.Option("cleartext").Bool()
+	return req.Arguments()[0] == "self" && !cleartext
+}
+
+// keyExportPath returns the path 'key export' writes the key to.
+func keyExportPath(req cmds.Request) string {
+	outPath, _, _ := req.Option("output").String()
+	if outPath != "" {
+		return outPath
+	}
+	if exportsEncrypted(req) {
+		return req.Arguments()[0] + ".backup"
+	}
+	return req.Arguments()[0] + ".key"
+}
+
+// keyPassphraseOptionName is the option of the passphrase the daemon
+// encrypts the keys it sends with, so that they never leave it in
+// cleartext.
+const keyPassphraseOptionName = "passphrase"
+
+var errKeyPassphraseMissing = errors.New("the keys are encrypted with --passphrase, which is missing")
+
+// setKeyPassphrase sets the passphrase option of req, unless given, to a new
+// passphrase for file, read by KeyPassphrase.
+func setKeyPassphrase(req cmds.Request, file string) error {
+	if p, _, _ := req.Option(keyPassphraseOptionName).String(); p != "" {
+		return nil
+	}
+	passphrase, err := KeyPassphrase(file, true)
+	if err != nil {
+		return err
+	}
+	req.SetOption(keyPassphraseOptionName, string(passphrase))
+	return nil
+}
+
+// EnvKeyPassphrase is the environment variable of the passphrase of the
+// encrypted keys imported by 'ipfs key import', and of the backups of 'ipfs
+// key backup' and 'ipfs key restore'.
+const EnvKeyPassphrase = "IPFS_KEY_PASSPHRASE"
+
+// KeyPassphrase returns the passphrase of the encrypted key file, which is
+// read, or written if confirm is true, a new passphrase being confirmed. It
+// is KeyPassphraseFromEnv by default; programs with a terminal can replace
+// it to prompt for it.
+var KeyPassphrase = KeyPassphraseFromEnv
+
+// KeyPassphraseFromEnv returns the passphrase of a key file set in the
+// environment.
+func KeyPassphraseFromEnv(file string, confirm bool) ([]byte, error) {
+	p := os.Getenv(EnvKeyPassphrase)
+	if p == "" {
+		if confirm {
+			return nil, fmt.Errorf("set %s to the passphrase to encrypt %s with", EnvKeyPassphrase, file)
+		}
+		return nil, fmt.Errorf("%s is encrypted, set %s to its passphrase", file, EnvKeyPassphrase)
+	}
+	return []byte(p), nil
+}
+
+var keyImportCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Import a keypair",
+		ShortDescription: `
+'ipfs key import' adds the private key of a file to the keystore, as <name>.
+The format of the file is found out unless it is given with --format, as for
+'ipfs key export'.
+
+  > ipfs key import mykey mykey.pem
+
+The ed25519 keys of OpenSSH can be imported too, in the openssh format. The
+passphrase of an encrypted key is read from the IPFS_KEY_PASSPHRASE
+environment variable, or else typed on the terminal; the key is decrypted
+by the client, and sent to the daemon in the format of libp2p.
+
+  > ipfs key import --format=openssh mykey ~/.ssh/id_ed25519
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("name", true, false, "name of key to import"),
+		cmds.FileArg("key", true, false, "The file of the key to import.").EnableStdin(),
+	},
+	Options: []cmds.Option{
+		cmds.StringOption(keyFormatOptionName, "f", "The format of the key: libp2p-protobuf-cleartext, pem-pkcs8-cleartext or openssh."),
+	},
+	PreRun: func(req cmds.Request) error {
+		format, _, _ := req.Option(keyFormatOptionName).String()
+		if format != "" && format != keystore.FormatOpenSSH {
+			return nil
+		}
+
+		file, err := req.Files().NextFile()
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		b, err := ioutil.ReadAll(file)
+		if err != nil {
+			return err
+		}
+
+		if format == keystore.FormatOpenSSH || keystore.IsOpenSSHKey(b) {
+			sk, err := keystore.UnmarshalOpenSSHKey(b, func() ([]byte, error) {
+				return KeyPassphrase(file.FileName(), false)
+			})
+			if err != nil {
+				return fmt.Errorf("cannot read the key: %s", err)
+			}
+			b, err = sk.Bytes()
+			if err != nil {
+				return err
+			}
+			req.SetOption(keyFormatOptionName, keystore.FormatLibp2p)
+		}
+
+		// the file was read, it is sent from memory
+		f := files.NewReaderFile(file.FileName(), file.FullPath(), ioutil.NopCloser(bytes.NewReader(b)), nil)
+		req.SetFiles(files.NewSliceFile("", "", []files.File{f}))
+		return nil
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		name := req.Arguments()[0]
+		if name == "self" {
+			res.SetError(fmt.Errorf("cannot import key with name 'self'"), cmds.ErrNormal)
+			return
+		}
+
+		format, _, _ := req.Option(keyFormatOptionName).String()
+
+		file, err := req.Files().NextFile()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		defer file.Close()
+
+		b, err := ioutil.ReadAll(file)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		sk, err := keystore.UnmarshalKey(b, format)
+		if err != nil {
+			res.SetError(fmt.Errorf("cannot read the key: %s", err), cmds.ErrClient)
+			return
+		}
+
+		ks := n.Repo.Keystore()
+		exist, err := ks.Has(name)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		if exist {
+			res.SetError(fmt.Errorf("key with name '%s' already exists", name), cmds.ErrNormal)
+			return
+		}
+
+		pid, err := peer.IDFromPrivateKey(sk)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		if err := ks.Put(name, sk); err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		res.SetOutput(&KeyOutputList{Keys: []KeyOutput{{Name: name, Id: pid.Pretty()}}})
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: keyIDMarshaler,
+	},
+	Type: KeyOutputList{},
 }
 
 var keyListCmd = &cmds.Command{
 	Helptext: cmds.HelpText{
 		Tagline: "List all local keypairs",
+		ShortDescription: `
+'ipfs key list' lists the names of the keys. With -l, it also shows their IDs,
+types, sizes in bits and creation times, unknown for 'self' and the keys
+created by older versions.
+
+  > ipfs key list -l
+  QmNodeID self  rsa     2048 -
+  QmKeyID1 mykey ed25519 256  2017-06-01T12:00:00Z
+
+The keys with an expiry show it last, flagged EXPIRED past it, or 'expires
+soon' two weeks before.
+
+The keys are listed by name, after 'self'. --prefix only lists the keys whose
+names start with the prefix, and --offset and --limit list a page of them.
+The IDs, types and sizes of the keys are kept in an index of the keystore, so
+that listing a page of many keys doesn't read them all.
+
+  > ipfs key list --prefix=site- --offset=100 --limit=100
+`,
 	},
 	Options: []cmds.Option{
 		cmds.BoolOption("l", "Show extra information about keys."),
+		cmds.StringOption("prefix", "Only list the keys whose names start with this prefix."),
+		cmds.IntOption("offset", "Skip this many keys.").Default(0),
+		cmds.IntOption("limit", "List at most this many keys, 0 for all.").Default(0),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
-		n, err := req.InvocContext().GetNode()
+		ks, err := req.InvocContext().GetKeystore()
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
 		}
 
-		keys, err := n.Repo.Keystore().List()
+		prefix, _, _ := req.Option("prefix").String()
+		offset, _, _ := req.Option("offset").Int()
+		limit, _, _ := req.Option("limit").Int()
+		if offset < 0 || limit < 0 {
+			res.SetError(errors.New("--offset and --limit can't be negative"), cmds.ErrClient)
+			return
+		}
+
+		keys, err := ks.List()
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
@@ -182,29 +900,47 @@ var keyListCmd = &cmds.Command{
 
 		sort.Strings(keys)
 
-		list := make([]KeyOutput, 0, len(keys)+1)
-
-		list = append(list, KeyOutput{Name: "self", Id: n.Identity.Pretty()})
+		// self is listed first
+		names := make([]string, 0, len(keys)+1)
+		for _, key := range append([]string{"self"}, keys...) {
+			if strings.HasPrefix(key, prefix) {
+				names = append(names, key)
+			}
+		}
+		if offset > len(names) {
+			offset = len(names)
+		}
+		names = names[offset:]
+		if limit > 0 && limit < len(names) {
+			names = names[:limit]
+		}
 
-		for _, key := range keys {
-			privKey, err := n.Repo.Keystore().Get(key)
+		var ix *keystore.KeyIndex
+		if iks, ok := ks.(keystore.IndexedKeystore); ok {
+			ix, err = iks.Index()
 			if err != nil {
 				res.SetError(err, cmds.ErrNormal)
 				return
 			}
+		}
 
-			pubKey := privKey.GetPublic()
-
-			pid, err := peer.IDFromPublicKey(pubKey)
+		list := make([]KeyOutput, 0, len(names))
+		for _, name := range names {
+			out, err := listedKeyInfo(req, ks, ix, name)
 			if err != nil {
 				res.SetError(err, cmds.ErrNormal)
 				return
 			}
+			list = append(list, out)
+		}
 
-			list = append(list, KeyOutput{Name: key, Id: pid.Pretty()})
+		if ix != nil {
+			if err := ix.Save(); err != nil {
+				log.Warning("could not save the index of the keystore: ", err)
+			}
 		}
 
-		res.SetOutput(&KeyOutputList{list})
+		res.SetOutput(&KeyOutputList{Keys: list})
 	},
 	Marshalers: cmds.MarshalerMap{
 		cmds.Text: keyOutputListMarshaler,
@@ -224,14 +960,12 @@ var keyRenameCmd = &cmds.Command{
 		cmds.BoolOption("force", "f", "Allow to overwrite an existing key."),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
-		n, err := req.InvocContext().GetNode()
+		ks, err := req.InvocContext().GetKeystore()
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
 		}
 
-		ks := n.Repo.Keystore()
-
 		name := req.Arguments()[0]
 		newName := req.Arguments()[1]
 
@@ -245,6 +979,11 @@ var keyRenameCmd = &cmds.Command{
 			return
 		}
 
+		if _, ok := ks.(keystore.GeneratingKeystore); ok {
+			res.SetError(errors.New("the keys of a token cannot be renamed"), cmds.ErrNormal)
+			return
+		}
+
 		oldKey, err := ks.Get(name)
 		if err != nil {
 			res.SetError(fmt.Errorf("no key named %s was found", name), cmds.ErrNormal)
@@ -284,37 +1023,50 @@ var keyRenameCmd = &cmds.Command{
 			return
 		}
 
+		// the key keeps its creation time
+		if mks, ok := ks.(keystore.MetadataKeystore); ok {
+			md, err := mks.Metadata(name)
+			if err == nil && md != nil {
+				err = mks.SetMetadata(newName, md)
+			}
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+		}
+
 		err = ks.Delete(name)
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
 		}
 
-		res.SetOutput(&KeyRenameOutput{
-			Was:       name,
-			Now:       newName,
+		res.SetOutput(&KeyOutputList{Keys: []KeyOutput{{
+			Name:      newName,
 			Id:        pid.Pretty(),
+			Was:       name,
 			Overwrite: overwrite,
-		})
+		}}})
 	},
 	Marshalers: cmds.MarshalerMap{
 		cmds.Text: func(res cmds.Response) (io.Reader, error) {
-			k, ok := res.Output().(*KeyRenameOutput)
-			if !ok {
-				return nil, fmt.Errorf("expected a KeyRenameOutput as command result")
+			list, err := keyOutputList(res)
+			if err != nil {
+				return nil, err
 			}
 
 			buf := new(bytes.Buffer)
-
-			if k.Overwrite {
-				fmt.Fprintf(buf, "Key %s renamed to %s with overwriting\n", k.Id, k.Now)
-			} else {
-				fmt.Fprintf(buf, "Key %s renamed to %s\n", k.Id, k.Now)
+			for _, k := range list.Keys {
+				if k.Overwrite {
+					fmt.Fprintf(buf, "Key %s renamed to %s with overwriting\n", k.Id, k.Name)
+				} else {
+					fmt.Fprintf(buf, "Key %s renamed to %s\n", k.Id, k.Name)
+				}
 			}
 			return buf, nil
 		},
 	},
-	Type: KeyRenameOutput{},
+	Type: KeyOutputList{},
 }
 
 var keyRmCmd = &cmds.Command{
@@ -328,7 +1080,7 @@ var keyRmCmd = &cmds.Command{
 		cmds.BoolOption("l", "Show extra information about keys."),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
-		n, err := req.InvocContext().GetNode()
+		ks, err := req.InvocContext().GetKeystore()
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
@@ -343,7 +1095,7 @@ var keyRmCmd = &cmds.Command{
 				return
 			}
 
-			removed, err := n.Repo.Keystore().Get(name)
+			removed, err := ks.Get(name)
 			if err != nil {
 				res.SetError(fmt.Errorf("no key named %s was found", name), cmds.ErrNormal)
 				return
@@ -361,14 +1113,14 @@ var keyRmCmd = &cmds.Command{
 		}
 
 		for _, name := range names {
-			err = n.Repo.Keystore().Delete(name)
+			err = ks.Delete(name)
 			if err != nil {
 				res.SetError(err, cmds.ErrNormal)
 				return
 			}
 		}
 
-		res.SetOutput(&KeyOutputList{list})
+		res.SetOutput(&KeyOutputList{Keys: list})
 	},
 	Marshalers: cmds.MarshalerMap{
 		cmds.Text: keyOutputListMarshaler,
@@ -376,18 +1128,56 @@ var keyRmCmd = &cmds.Command{
 	Type: KeyOutputList{},
 }
 
-func keyOutputListMarshaler(res cmds.Response) (io.Reader, error) {
-	withId, _, _ := res.Request().Option("l").Bool()
+// unwrapOutput returns the output of a command, which the client gets in a
+// channel when the daemon streams it.
+func unwrapOutput(out interface{}) interface{} {
+	if ch, ok := out.(<-chan interface{}); ok {
+		return <-ch
+	}
+	return out
+}
 
-	list, ok := res.Output().(*KeyOutputList)
+// keyOutputList returns the output of a key command.
+func keyOutputList(res cmds.Response) (*KeyOutputList, error) {
+	list, ok := unwrapOutput(res.Output()).(*KeyOutputList)
 	if !ok {
 		return nil, errors.New("failed to cast []KeyOutput")
 	}
+	return list, nil
+}
+
+// keyIDMarshaler writes the IDs of the keys made by 'key gen' or 'key import'.
+func keyIDMarshaler(res cmds.Response) (io.Reader, error) {
+	list, err := keyOutputList(res)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := new(bytes.Buffer)
+	for _, k := range list.Keys {
+		fmt.Fprintln(buf, k.Id)
+	}
+	return buf, nil
+}
+
+func keyOutputListMarshaler(res cmds.Response) (io.Reader, error) {
+	withId, _, _ := res.Request().Option("l").Bool()
+
+	list, err := keyOutputList(res)
+	if err != nil {
+		return nil, err
+	}
 
 	buf := new(bytes.Buffer)
 	w := tabwriter.NewWriter(buf, 1, 2, 1, ' ', 0)
 	for _, s := range list.Keys {
-		if withId {
+		if withId && s.Type != "" {
+			created := "-"
+			if s.CreatedAt != nil {
+				created = s.CreatedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\n", s.Id, s.Name, s.Type, s.Size, created, expiry(s.ExpiresAt, time.Now()))
+		} else if withId {
 			fmt.Fprintf(w, "%s\t%s\t\n", s.Id, s.Name)
 		} else {
 			fmt.Fprintf(w, "%s\n", s.Name)
@@ -396,3 +1186,144 @@ func keyOutputListMarshaler(res cmds.Response) (io.Reader, error) {
 	w.Flush()
 	return buf, nil
 }
+
+// expiry describes when a key expires, flagging the keys expired or expiring
+// soon at now.
+func expiry(exp *time.Time, now time.Time) string {
+	md := &keystore.Metadata{Expires: exp}
+	switch {
+	case exp == nil:
+		return ""
+	case md.Expired(now):
+		return "EXPIRED " + exp.Format(time.RFC3339)
+	case md.ExpiringSoon(now):
+		return "expires soon " + exp.Format(time.RFC3339)
+	default:
+		return "expires " + exp.Format(time.RFC3339)
+	}
+}
+
+// keyInfo describes the key sk, stored in ks as name.
+func keyInfo(ks keystore.Keystore, name string, sk ci.PrivKey) (KeyOutput, error) {
+	pid, err := peer.IDFromPrivateKey(sk)
+	if err != nil {
+		return KeyOutput{}, err
+	}
+	typ, size, err := keystore.KeyType(sk)
+	if err != nil {
+		return KeyOutput{}, err
+	}
+	out := KeyOutput{Name: name, Id: pid.Pretty(), Type: typ, Size: size}
+
+	// self is not in the keystore
+	if mks, ok := ks.(keystore.MetadataKeystore); ok && name != "self" {
+		md, err := mks.Metadata(name)
+		if err != nil {
+			return KeyOutput{}, err
+		}
+		describeMetadata(&out, md)
+	}
+	return out, nil
+}
+
+// describeMetadata sets the times of md in out.
+func describeMetadata(out *KeyOutput, md *keystore.Metadata) {
+	if md == nil {
+		return
+	}
+	// keys given a policy before they had metadata have no creation time
+	if !md.Created.IsZero() {
+		out.CreatedAt = &md.Created
+	}
+	out.ExpiresAt = md.Expires
+}
+
+// listedKeyInfo describes the key name for 'ipfs key list', from the index
+// of the keystore if it has it, and records it there otherwise.
+func listedKeyInfo(req cmds.Request, ks keystore.Keystore, ix *keystore.KeyIndex, name string) (KeyOutput, error) {
+	if name != "self" && ix != nil {
+		if e := ix.Get(name); e != nil {
+			out := KeyOutput{Name: name, Id: e.ID, Type: e.Type, Size: e.Size}
+			if mks, ok := ks.(keystore.MetadataKeystore); ok {
+				md, err := mks.Metadata(name)
+				if err != nil {
+					return KeyOutput{}, err
+				}
+				describeMetadata(&out, md)
+			}
+			return out, nil
+		}
+	}
+
+	var sk ci.PrivKey
+	var err error
+	if name == "self" {
+		sk, err = selfKey(req)
+	} else {
+		sk, err = ks.Get(name)
+	}
+	if err != nil {
+		return KeyOutput{}, err
+	}
+	out, err := keyInfo(ks, name, sk)
+	if err != nil {
+		return KeyOutput{}, err
+	}
+
+	if name != "self" && ix != nil {
+		err := ix.Set(name, &keystore.IndexEntry{ID: out.Id, Type: out.Type, Size: out.Size})
+		if err != nil {
+			return KeyOutput{}, err
+		}
+	}
+	return out, nil
+}
+
+// setKeyGenOutput sets the output of 'ipfs key gen', the new key name of
+// public key pk.
+func setKeyGenOutput(res cmds.Response, name string, pk ci.PubKey) {
+	pid, err := peer.IDFromPublicKey(pk)
+	if err != nil {
+		res.SetError(err, cmds.ErrNormal)
+		return
+	}
+
+	res.SetOutput(&KeyOutputList{Keys: []KeyOutput{{Name: name, Id: pid.Pretty()}}})
+}
+
+// selfKey returns the key of the node from the config, for the commands
+// which only open the keystore.
+func selfKey(req cmds.Request) (ci.PrivKey, error) {
+	cfg, err := req.InvocContext().GetConfig()
+	if err != nil {
+		return nil, err
+	}
+	return cfg.Identity.DecodePrivateKey("")
+}
+
+// usableKey returns the key called name, as namedKey, if its policy allows
+// use. The key of the node has no policy.
+func usableKey(n *core.IpfsNode, name, use string) (ci.PrivKey, error) {
+	if name != "self" {
+		if err := keystore.CheckUse(n.Repo.Keystore(), name, use); err != nil {
+			return nil, err
+		}
+	}
+	return namedKey(n, name)
+}
+
+// namedKey returns the key called name, loading the one of the node if need
+// be.
+func namedKey(n *core.IpfsNode, name string) (ci.PrivKey, error) {
+	if name == "self" && n.PrivateKey == nil {
+		if err := n.LoadPrivateKey(); err != nil {
+			return nil, err
+		}
+	}
+
+	k, err := n.GetKey(name)
+	if err == keystore.ErrNoSuchKey {
+		return nil, fmt.Errorf("no key named %s was found", name)
+	}
+	return k, err
+}
@@ -0,0 +1,116 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	keystore "github.com/ipfs/go-ipfs/keystore"
+)
+
+var keyUpdateCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Change when a key expires.",
+		ShortDescription: `
+'ipfs key update' sets the expiry of a key, given with --expires as a date,
+2006-01-02 or in RFC 3339, a duration from now, such as 90d or 12h, or
+'never'. The key stays usable past its expiry: 'ipfs key list -l' flags it,
+and 'ipfs name publish' warns when publishing with it, or refuses to, as set
+by Ipns.ExpiredKeys in the config.
+
+The key of the node, 'self', has no expiry.
+
+  > ipfs key update release-2017 --expires=2018-01-01
+  > ipfs key update release-2017 --expires=never
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("name", true, false, "Name of the key."),
+	},
+	Options: []cmds.Option{
+		cmds.StringOption("expires", "When the key expires: a date, a duration from now, or never."),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		ks, err := req.InvocContext().GetKeystore()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		name := req.Arguments()[0]
+		if name == "self" {
+			res.SetError(errors.New("the key of the node has no expiry"), cmds.ErrClient)
+			return
+		}
+		s, found, _ := req.Option("expires").String()
+		if !found {
+			res.SetError(errors.New("give the expiry of the key with --expires"), cmds.ErrClient)
+			return
+		}
+		expires, err := keystore.ParseExpiry(s, time.Now())
+		if err != nil {
+			res.SetError(err, cmds.ErrClient)
+			return
+		}
+
+		sk, err := ks.Get(name)
+		if err == keystore.ErrNoSuchKey {
+			res.SetError(fmt.Errorf("no key named %s was found", name), cmds.ErrNormal)
+			return
+		}
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		if err := setKeyExpiry(ks, name, expires); err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		out, err := keyInfo(ks, name, sk)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		res.SetOutput(&KeyOutputList{Keys: []KeyOutput{out}})
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			list, err := keyOutputList(res)
+			if err != nil {
+				return nil, err
+			}
+
+			buf := new(bytes.Buffer)
+			for _, k := range list.Keys {
+				if k.ExpiresAt == nil {
+					fmt.Fprintf(buf, "%s: never expires\n", k.Name)
+				} else {
+					fmt.Fprintf(buf, "%s: %s\n", k.Name, expiry(k.ExpiresAt, time.Now()))
+				}
+			}
+			return buf, nil
+		},
+	},
+	Type: KeyOutputList{},
+}
+
+// setKeyExpiry sets when the key name of ks expires, never if expires is nil.
+func setKeyExpiry(ks keystore.Keystore, name string, expires *time.Time) error {
+	mks, ok := ks.(keystore.MetadataKeystore)
+	if !ok {
+		return errors.New("the keystore doesn't record the expiry of keys")
+	}
+	md, err := mks.Metadata(name)
+	if err != nil {
+		return err
+	}
+	if md == nil {
+		md = &keystore.Metadata{}
+	}
+	md.Expires = expires
+	return mks.SetMetadata(name, md)
+}
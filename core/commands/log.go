@@ -1,12 +1,15 @@
 package commands
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
+	"strings"
 
 	logging "gx/ipfs/QmSpJByNKFX1sCsHBEp3R73FL4NF6FnQTEGyNAXHm2GS52/go-log"
 
 	cmds "github.com/ipfs/go-ipfs/commands"
+	events "github.com/ipfs/go-ipfs/core/events"
 )
 
 // Golang os.Args overrides * and replaces the character argument with
@@ -93,11 +96,56 @@ var logTailCmd = &cmds.Command{
 		Tagline: "Read the event log.",
 		ShortDescription: `
 Outputs event log messages (not other log messages) as they are generated.
+`,
+		LongDescription: `
+Outputs event log messages (not other log messages) as they are generated.
+
+With --event-types, outputs the lifecycle events of content instead, one
+JSON object per line, for external systems to track when a CID was added,
+pinned, unpinned, announced to the network, garbage collected or found
+corrupt by the scrubber (see Datastore.Scrub in the config):
+
+  {"Type":"pinned","Cid":"QmHash","Time":"2017-06-01T12:00:00Z"}
+
+--event-types takes a comma-separated list of: added, pinned, unpinned,
+provided, gced, corrupt; or 'all'. The same stream is served by the API at /events,
+over a WebSocket or as plain HTTP.
 `,
 	},
 
+	Options: []cmds.Option{
+		cmds.StringOption("event-types", "Output the lifecycle events of content of these types, comma-separated, or 'all'."),
+	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		ctx := req.Context()
+
+		if typesStr, found, _ := req.Option("event-types").String(); found {
+			types, err := parseEventTypes(typesStr)
+			if err != nil {
+				res.SetError(err, cmds.ErrClient)
+				return
+			}
+
+			n, err := req.InvocContext().GetNode()
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+
+			r, w := io.Pipe()
+			go func() {
+				defer w.Close()
+				enc := json.NewEncoder(w)
+				for ev := range n.Events.Subscribe(ctx, types) {
+					if err := enc.Encode(ev); err != nil {
+						return
+					}
+				}
+			}()
+			res.SetOutput(r)
+			return
+		}
+
 		r, w := io.Pipe()
 		go func() {
 			defer w.Close()
@@ -107,3 +155,18 @@ Outputs event log messages (not other log messages) as they are generated.
 		res.SetOutput(r)
 	},
 }
+
+// parseEventTypes parses a comma-separated list of event types, where 'all'
+// or an empty list stand for all of them.
+func parseEventTypes(s string) ([]string, error) {
+	if s == "" || s == logAllKeyword {
+		return events.Types, nil
+	}
+	var types []string
+	for _, t := range strings.Split(s, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			types = append(types, t)
+		}
+	}
+	return events.ParseTypes(types)
+}
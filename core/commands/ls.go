@@ -23,6 +23,7 @@ type LsLink struct {
 	Name, Hash string
 	Size       uint64
 	Type       unixfspb.Data_DataType
+	Target     string `json:",omitempty"` // of symlinks
 }
 
 type LsObject struct {
@@ -53,9 +54,10 @@ The JSON output contains type information.
 	Options: []cmds.Option{
 		cmds.BoolOption("headers", "v", "Print table headers (Hash, Size, Name).").Default(false),
 		cmds.BoolOption("resolve-type", "Resolve linked objects to find out their types.").Default(true),
+		offlineOption,
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
-		nd, err := req.InvocContext().GetNode()
+		nd, err := requestNode(req)
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
@@ -124,6 +126,7 @@ The JSON output contains type information.
 
 			for j, link := range links {
 				t := unixfspb.Data_DataType(-1)
+				target := ""
 
 				linkNode, err := link.GetNode(req.Context(), dserv)
 				if err == merkledag.ErrNotFound && !resolve {
@@ -142,12 +145,16 @@ The JSON output contains type information.
 					}
 
 					t = d.GetType()
+					if t == unixfspb.Data_Symlink {
+						target = string(d.GetData())
+					}
 				}
 				output[i].Links[j] = LsLink{
-					Name: link.Name,
-					Hash: link.Cid.String(),
-					Size: link.Size,
-					Type: t,
+					Name:   link.Name,
+					Hash:   link.Cid.String(),
+					Size:   link.Size,
+					Type:   t,
+					Target: target,
 				}
 			}
 		}
@@ -169,8 +176,11 @@ The JSON output contains type information.
 					fmt.Fprintln(w, "Hash\tSize\tName")
 				}
 				for _, link := range object.Links {
-					if link.Type == unixfspb.Data_Directory {
+					switch link.Type {
+					case unixfspb.Data_Directory:
 						link.Name += "/"
+					case unixfspb.Data_Symlink:
+						link.Name += " -> " + link.Target
 					}
 					fmt.Fprintf(w, "%s\t%v\t%s\n", link.Hash, link.Size, link.Name)
 				}
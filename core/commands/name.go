@@ -1,10 +1,23 @@
 package commands
 
-import cmds "github.com/ipfs/go-ipfs/commands"
+import (
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+)
 
 type IpnsEntry struct {
 	Name  string
 	Value string
+	// EOL is when the record published by 'name publish' expires, and TTL,
+	// if set, how long resolvers may cache it.
+	EOL time.Time
+	TTL string `json:",omitempty"`
+	// Warning is set by 'name publish' when the key published with expired.
+	Warning string `json:",omitempty"`
+	// Pending is set by 'name publish --allow-offline' when the record was
+	// only stored, to be sent once the node is online.
+	Pending bool `json:",omitempty"`
 }
 
 var NameCmd = &cmds.Command{
@@ -52,11 +65,19 @@ Resolve the value of a dnslink:
   > ipfs name resolve ipfs.io
   /ipfs/QmaBvfZooxWkrv7D3r8LS9moNjzD2o525XMZze69hhoxf5
 
+List the records published with your keys:
+
+  > ipfs name list
+  Key  Name                                           Value                                           Sequence EOL
+  self QmbCMUZw6JFeZ7Wp9jkzbye3Fzp2GGcPgC3nmeUjfVF87n /ipfs/QmatmE9msSfkKxoffpHwNLNKgwZG8eT9Bud6YoPab52vpy 0        2017-06-02T12:00:00Z
+
 `,
 	},
 
 	Subcommands: map[string]*cmds.Command{
 		"publish": PublishCmd,
 		"resolve": IpnsCmd,
+		"list":    nameListCmd,
+		"pubsub":  namePubsubCmd,
 	},
 }
@@ -0,0 +1,162 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	keystore "github.com/ipfs/go-ipfs/keystore"
+	namesys "github.com/ipfs/go-ipfs/namesys"
+
+	u "gx/ipfs/QmWbjfz3u6HkAdPh34dgPchGbQjob6LXLhAeCGii2TX69n/go-ipfs-util"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+// NameRecord is the IPNS record last published with a key.
+type NameRecord struct {
+	Key string
+	Id  string
+	KeyPublished
+	// Pending is set when the record was published offline, and is yet to
+	// be sent to the network.
+	Pending bool `json:",omitempty"`
+}
+
+// NameListOutput is the output of 'ipfs name list'.
+type NameListOutput struct {
+	Records []NameRecord
+}
+
+var nameListCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "List the IPNS records published with the local keys.",
+		ShortDescription: `
+'ipfs name list' shows, for each key with an IPNS record, 'self' first and
+then by name, the value, sequence number and expiry of the record it last
+published, as kept by the node. The records published offline, yet to be sent
+to the network, are flagged 'pending'. The keys without records are left out.
+
+--key only shows the record of a key, given by name or peer ID.
+
+  > ipfs name list
+  Key   Name     Value                 Sequence EOL
+  self  QmNodeID /ipfs/QmSomeHash      4        2017-06-02T12:00:00Z
+  mykey QmKeyID1 /ipfs/QmOtherHash     1        2017-05-30T09:00:00Z (expired)
+`,
+	},
+	Options: []cmds.Option{
+		cmds.StringOption("key", "k", "Only show the record of this key, by name or peer ID."),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		ks := n.Repo.Keystore()
+
+		names, err := ks.List()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		sort.Strings(names)
+
+		var ix *keystore.KeyIndex
+		if iks, ok := ks.(keystore.IndexedKeystore); ok {
+			ix, err = iks.Index()
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+		}
+
+		pending := make(map[peer.ID]bool)
+		ids, err := namesys.PendingNames(n.Repo.Datastore())
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		for _, id := range ids {
+			pending[id] = true
+		}
+
+		only, filtered, _ := req.Option("key").String()
+		found := false
+		out := &NameListOutput{Records: []NameRecord{}}
+		for _, name := range append([]string{"self"}, names...) {
+			info, err := listedKeyInfo(req, ks, ix, name)
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+			if filtered && only != name && only != info.Id {
+				continue
+			}
+			found = true
+
+			id, err := peer.IDB58Decode(info.Id)
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+			rec, err := namesys.LocalRecord(n.Repo.Datastore(), id)
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+			if rec == nil {
+				continue
+			}
+			p, err := keyPublished(rec)
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+			out.Records = append(out.Records, NameRecord{
+				Key:          name,
+				Id:           info.Id,
+				KeyPublished: *p,
+				Pending:      pending[id],
+			})
+		}
+		if filtered && !found {
+			res.SetError(fmt.Errorf("no key by the name or peer ID %s was found", only), cmds.ErrClient)
+			return
+		}
+
+		res.SetOutput(out)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out, ok := res.Output().(*NameListOutput)
+			if !ok {
+				return nil, u.ErrCast()
+			}
+
+			buf := new(bytes.Buffer)
+			w := tabwriter.NewWriter(buf, 1, 2, 1, ' ', 0)
+			fmt.Fprintln(w, "Key\tName\tValue\tSequence\tEOL")
+			for _, r := range out.Records {
+				eol := "-"
+				if !r.Expires.IsZero() {
+					eol = r.Expires.Format(time.RFC3339)
+				}
+				if r.Expired {
+					eol += " (expired)"
+				}
+				if r.Pending {
+					eol += " (pending)"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n", r.Key, r.Id, r.Value, r.Sequence, eol)
+			}
+			w.Flush()
+			return buf, nil
+		},
+	},
+	Type: NameListOutput{},
+}
@@ -0,0 +1,172 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	core "github.com/ipfs/go-ipfs/core"
+	namesys "github.com/ipfs/go-ipfs/namesys"
+
+	u "gx/ipfs/QmWbjfz3u6HkAdPh34dgPchGbQjob6LXLhAeCGii2TX69n/go-ipfs-util"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+var errPubsubDisabled = errors.New("IPNS over pubsub is not enabled, see 'ipfs daemon --enable-namesys-pubsub'")
+
+// IpnsPubsubState is the output of 'ipfs name pubsub state'.
+type IpnsPubsubState struct {
+	Enabled bool
+}
+
+// IpnsPubsubCancel is the output of 'ipfs name pubsub cancel'.
+type IpnsPubsubCancel struct {
+	Canceled bool
+}
+
+var namePubsubCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "IPNS over pubsub commands.",
+		ShortDescription: `
+With IPNS over pubsub, enabled by 'ipfs daemon --enable-namesys-pubsub', the
+node subscribes to the topic of each name it resolves, and answers the name
+from the records received on it. These commands show and manage the
+subscriptions.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"state":  namePubsubStateCmd,
+		"subs":   namePubsubSubsCmd,
+		"cancel": namePubsubCancelCmd,
+	},
+}
+
+var namePubsubStateCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Query the state of IPNS over pubsub.",
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		ps, ok := n.Namesys.(namesys.PubsubSubscriptions)
+		res.SetOutput(&IpnsPubsubState{Enabled: ok && ps.PubsubEnabled()})
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out, ok := res.Output().(*IpnsPubsubState)
+			if !ok {
+				return nil, u.ErrCast()
+			}
+			state := "disabled"
+			if out.Enabled {
+				state = "enabled"
+			}
+			return strings.NewReader(state + "\n"), nil
+		},
+	},
+	Type: IpnsPubsubState{},
+}
+
+var namePubsubSubsCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Show the names whose topics are subscribed to.",
+		ShortDescription: `
+'ipfs name pubsub subs' lists the names, as /ipns/<peer ID>, whose topics the
+node subscribed to when it resolved them.
+`,
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		ps, err := namePubsub(n)
+		if err != nil {
+			res.SetError(err, cmds.ErrClient)
+			return
+		}
+
+		names := []string{}
+		for _, id := range ps.PubsubNames() {
+			names = append(names, "/ipns/"+id.Pretty())
+		}
+		sort.Strings(names)
+		res.SetOutput(&stringList{names})
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: stringListMarshaler,
+	},
+	Type: stringList{},
+}
+
+var namePubsubCancelCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Cancel the subscription to the topic of a name.",
+		ShortDescription: `
+'ipfs name pubsub cancel' drops the subscription to the topic of <name>, and
+the last record received on it. The next resolution of <name> goes through
+the routing system, and subscribes again.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("name", true, false, "Name to cancel the subscription of, as /ipns/<peer ID> or <peer ID>."),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		ps, err := namePubsub(n)
+		if err != nil {
+			res.SetError(err, cmds.ErrClient)
+			return
+		}
+
+		name := strings.TrimPrefix(req.Arguments()[0], "/ipns/")
+		id, err := peer.IDB58Decode(name)
+		if err != nil {
+			res.SetError(fmt.Errorf("invalid name %s: %s", name, err), cmds.ErrClient)
+			return
+		}
+		res.SetOutput(&IpnsPubsubCancel{Canceled: ps.CancelPubsub(id)})
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out, ok := res.Output().(*IpnsPubsubCancel)
+			if !ok {
+				return nil, u.ErrCast()
+			}
+			buf := new(bytes.Buffer)
+			if out.Canceled {
+				fmt.Fprintln(buf, "canceled")
+			} else {
+				fmt.Fprintln(buf, "no subscription")
+			}
+			return buf, nil
+		},
+	},
+	Type: IpnsPubsubCancel{},
+}
+
+// namePubsub returns the subscriptions of the names of n, or an error if
+// IPNS over pubsub is not enabled on it.
+func namePubsub(n *core.IpfsNode) (namesys.PubsubSubscriptions, error) {
+	if !n.OnlineMode() {
+		return nil, errNotOnline
+	}
+	ps, ok := n.Namesys.(namesys.PubsubSubscriptions)
+	if !ok || !ps.PubsubEnabled() {
+		return nil, errPubsubDisabled
+	}
+	return ps, nil
+}
@@ -27,6 +27,22 @@ var ErrObjectTooLarge = errors.New("input object was too large. limit is 2mbytes
 
 const inputLimit = 2 << 20
 
+var offlineOption = cmds.BoolOption("offline", "Only read the local blocks and IPNS records, failing rather than fetching them from the network.").Default(false)
+
+// requestNode returns the node of req, or its offline view if --offline is
+// given.
+func requestNode(req cmds.Request) (*core.IpfsNode, error) {
+	n, err := req.InvocContext().GetNode()
+	if err != nil {
+		return nil, err
+	}
+	offline, _, err := req.Option("offline").Bool()
+	if err != nil || !offline {
+		return n, err
+	}
+	return n.Offline()
+}
+
 type Node struct {
 	Links []Link
 	Data  string
@@ -81,8 +97,11 @@ is the raw data of the object.
 	Arguments: []cmds.Argument{
 		cmds.StringArg("key", true, false, "Key of the object to retrieve, in base58-encoded multihash format.").EnableStdin(),
 	},
+	Options: []cmds.Option{
+		offlineOption,
+	},
 	Run: func(req cmds.Request, res cmds.Response) {
-		n, err := req.InvocContext().GetNode()
+		n, err := requestNode(req)
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
@@ -125,9 +144,10 @@ multihash.
 	},
 	Options: []cmds.Option{
 		cmds.BoolOption("headers", "v", "Print table headers (Hash, Size, Name).").Default(false),
+		offlineOption,
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
-		n, err := req.InvocContext().GetNode()
+		n, err := requestNode(req)
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
@@ -195,8 +215,11 @@ This command outputs data in the following encodings:
 	Arguments: []cmds.Argument{
 		cmds.StringArg("key", true, false, "Key of the object to retrieve, in base58-encoded multihash format.").EnableStdin(),
 	},
+	Options: []cmds.Option{
+		offlineOption,
+	},
 	Run: func(req cmds.Request, res cmds.Response) {
-		n, err := req.InvocContext().GetNode()
+		n, err := requestNode(req)
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
@@ -268,8 +291,11 @@ var ObjectStatCmd = &cmds.Command{
 	Arguments: []cmds.Argument{
 		cmds.StringArg("key", true, false, "Key of the object to retrieve, in base58-encoded multihash format.").EnableStdin(),
 	},
+	Options: []cmds.Option{
+		offlineOption,
+	},
 	Run: func(req cmds.Request, res cmds.Response) {
-		n, err := req.InvocContext().GetNode()
+		n, err := requestNode(req)
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
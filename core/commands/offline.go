@@ -0,0 +1,24 @@
+package commands
+
+import (
+	cmds "github.com/ipfs/go-ipfs/commands"
+	core "github.com/ipfs/go-ipfs/core"
+)
+
+const offlineOptionName = "offline"
+
+var offlineOption = cmds.BoolOption(offlineOptionName, "Only read the local blocks and IPNS records, failing rather than fetching them from the network.").Default(false)
+
+// requestNode returns the node of req, or its offline view if --offline is
+// given.
+func requestNode(req cmds.Request) (*core.IpfsNode, error) {
+	n, err := req.InvocContext().GetNode()
+	if err != nil {
+		return nil, err
+	}
+	offline, _, err := req.Option(offlineOptionName).Bool()
+	if err != nil || !offline {
+		return n, err
+	}
+	return n.Offline()
+}
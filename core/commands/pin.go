@@ -4,17 +4,20 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"strings"
 	"time"
 
 	cmds "github.com/ipfs/go-ipfs/commands"
 	core "github.com/ipfs/go-ipfs/core"
 	corerepo "github.com/ipfs/go-ipfs/core/corerepo"
+	events "github.com/ipfs/go-ipfs/core/events"
 	dag "github.com/ipfs/go-ipfs/merkledag"
 	path "github.com/ipfs/go-ipfs/path"
 	pin "github.com/ipfs/go-ipfs/pin"
 	uio "github.com/ipfs/go-ipfs/unixfs/io"
 
 	context "context"
+	mh "gx/ipfs/QmVGtdTZdTFaLsaj2RwdVG8jcjNNcp1DE914DKZ2kHmXHw/go-multihash"
 	u "gx/ipfs/QmWbjfz3u6HkAdPh34dgPchGbQjob6LXLhAeCGii2TX69n/go-ipfs-util"
 	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
 )
@@ -30,6 +33,8 @@ var PinCmd = &cmds.Command{
 		"ls":     listPinCmd,
 		"verify": verifyPinCmd,
 		"update": updatePinCmd,
+		"jobs":   pinJobsCmd,
+		"follow": pinFollowCmd,
 	},
 }
 
@@ -46,6 +51,24 @@ var addPinCmd = &cmds.Command{
 	Helptext: cmds.HelpText{
 		Tagline:          "Pin objects to local storage.",
 		ShortDescription: "Stores an IPFS object(s) from a given path locally to disk.",
+		LongDescription: `
+Stores an IPFS object(s) from a given path locally to disk.
+
+With --follow, the paths are followed: /ipns/ paths, or paths in the files of
+the node as in 'ipfs files', are pinned as they resolve now, and the daemon
+resolves them again every Pinning.FollowInterval, pinning their new versions
+and unpinning the older ones, unless --keep-old is given. This mirrors a name
+or a directory without pinning every version of it by hand.
+
+	$ ipfs pin add --follow /ipns/QmSomePeerID
+	pinned QmSomeHash recursively
+	$ ipfs pin follow ls
+	/ipns/QmSomePeerID QmSomeHash recursive
+
+With --from, the blocks are fetched from the given peers only, peer IDs or
+multiaddrs ending in /ipfs/<peer ID>, without searching for other providers;
+with --prefer-from too, from them first.
+`,
 	},
 
 	Arguments: []cmds.Argument{
@@ -54,6 +77,10 @@ var addPinCmd = &cmds.Command{
 	Options: []cmds.Option{
 		cmds.BoolOption("recursive", "r", "Recursively pin the object linked to by the specified object(s).").Default(true),
 		cmds.BoolOption("progress", "Show progress"),
+		cmds.BoolOption("follow", "Pin the new versions of the /ipns/ paths or paths in the files of the node, as they change.").Default(false),
+		cmds.BoolOption("keep-old", "Keep the older versions of the followed paths pinned.").Default(false),
+		fromOption,
+		preferFromOption,
 	},
 	Type: AddPinOutput{},
 	Run: func(req cmds.Request, res cmds.Response) {
@@ -72,9 +99,35 @@ var addPinCmd = &cmds.Command{
 			return
 		}
 		showProgress, _, _ := req.Option("progress").Bool()
+		follow, _, _ := req.Option("follow").Bool()
+		keepOld, _, _ := req.Option("keep-old").Bool()
+		if keepOld && !follow {
+			res.SetError(fmt.Errorf("--keep-old only applies to the paths followed with --follow"), cmds.ErrClient)
+			return
+		}
+		fctx, err := fromPeersContext(req, n)
+		if err != nil {
+			res.SetError(err, cmds.ErrClient)
+			return
+		}
+
+		pinPaths := func(ctx context.Context) ([]*cid.Cid, error) {
+			if !follow {
+				return corerepo.Pin(n, ctx, req.Arguments(), recursive)
+			}
+			var added []*cid.Cid
+			for _, p := range req.Arguments() {
+				c, err := corerepo.Follow(n, ctx, p, recursive, keepOld)
+				if err != nil {
+					return nil, err
+				}
+				added = append(added, c)
+			}
+			return added, nil
+		}
 
 		if !showProgress {
-			added, err := corerepo.Pin(n, req.Context(), req.Arguments(), recursive)
+			added, err := pinPaths(fctx)
 			if err != nil {
 				res.SetError(err, cmds.ErrNormal)
 				return
@@ -84,12 +137,12 @@ var addPinCmd = &cmds.Command{
 		}
 
 		v := new(dag.ProgressTracker)
-		ctx := v.DeriveContext(req.Context())
+		ctx := v.DeriveContext(fctx)
 
 		ch := make(chan []*cid.Cid)
 		go func() {
 			defer close(ch)
-			added, err := corerepo.Pin(n, ctx, req.Arguments(), recursive)
+			added, err := pinPaths(ctx)
 			if err != nil {
 				res.SetError(err, cmds.ErrNormal)
 				return
@@ -251,6 +304,18 @@ With arguments, the command fails if any of the arguments is not a pinned
 object. And if --type=<type> is additionally used, the command will also fail
 if any of the arguments is not of the specified type.
 
+To compare the pins of nodes which use both CID versions, --cid-version=<n>
+writes the CIDs in version 0 or 1; a pin in both versions is then listed
+once. CIDs which can't be written in version 0, for instance of raw blocks,
+stay in version 1. --prefix=<prefix> and --codec=<codec> only list the CIDs
+which start with the prefix, once in the requested version, or which are of
+the codec: protobuf, cbor or raw.
+
+With --in-progress, the pins being added are listed instead, with those
+whose adding was interrupted by the shutdown of the node. The daemon resumes
+these when it starts, fetching what is missing of their blocks, and says how
+many times it did. 'ipfs pin rm' drops an interrupted pin.
+
 Example:
 	$ echo "hello" | ipfs add -q
 	QmZULkCELmmk5XNfCgTnCyFgAVxBRBXyDHGGMVoLFLiXEN
@@ -274,6 +339,10 @@ Example:
 	Options: []cmds.Option{
 		cmds.StringOption("type", "t", "The type of pinned keys to list. Can be \"direct\", \"indirect\", \"recursive\", or \"all\".").Default("all"),
 		cmds.BoolOption("quiet", "q", "Write just hashes of objects.").Default(false),
+		cmds.IntOption("cid-version", "Write the CIDs in this version, 0 or 1. CIDs with no version 0 are written in version 1."),
+		cmds.StringOption("prefix", "Only list the CIDs starting with this prefix, in the version of --cid-version."),
+		cmds.StringOption("codec", "Only list the CIDs of this codec: protobuf, cbor or raw."),
+		cmds.BoolOption("in-progress", "List the pins being added, or interrupted, instead.").Default(false),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		n, err := req.InvocContext().GetNode()
@@ -282,6 +351,18 @@ Example:
 			return
 		}
 
+		inProgress, _, _ := req.Option("in-progress").Bool()
+		if inProgress && len(req.Arguments()) > 0 {
+			res.SetError(fmt.Errorf("--in-progress lists all the pins in progress, and takes no arguments"), cmds.ErrClient)
+			return
+		}
+
+		filter, err := pinLsFilterFromRequest(req)
+		if err != nil {
+			res.SetError(err, cmds.ErrClient)
+			return
+		}
+
 		typeStr, _, err := req.Option("type").String()
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
@@ -298,17 +379,26 @@ Example:
 
 		var keys map[string]RefKeyObject
 
-		if len(req.Arguments()) > 0 {
+		switch {
+		case inProgress:
+			keys, err = pinLsInProgress(typeStr, n)
+		case len(req.Arguments()) > 0:
 			keys, err = pinLsKeys(req.Arguments(), typeStr, req.Context(), n)
-		} else {
+		default:
 			keys, err = pinLsAll(typeStr, req.Context(), n)
 		}
 
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
-		} else {
-			res.SetOutput(&RefKeyList{Keys: keys})
+			return
 		}
+
+		keys, err = filter.apply(keys)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		res.SetOutput(&RefKeyList{Keys: keys})
 	},
 	Type: RefKeyList{},
 	Marshalers: cmds.MarshalerMap{
@@ -324,9 +414,12 @@ Example:
 			}
 			out := new(bytes.Buffer)
 			for k, v := range keys.Keys {
-				if quiet {
+				switch {
+				case quiet:
 					fmt.Fprintf(out, "%s\n", k)
-				} else {
+				case v.Resumes > 0:
+					fmt.Fprintf(out, "%s %s resumed %d times\n", k, v.Type, v.Resumes)
+				default:
 					fmt.Fprintf(out, "%s %s\n", k, v.Type)
 				}
 			}
@@ -401,6 +494,11 @@ new pin and removing the old one.
 			return
 		}
 
+		n.Events.Publish(events.Pinned, toc)
+		if unpin {
+			n.Events.Publish(events.Unpinned, fromc)
+		}
+
 		res.SetOutput(&PinOutput{Pins: []string{from.String(), to.String()}})
 	},
 	Marshalers: cmds.MarshalerMap{
@@ -477,12 +575,113 @@ var verifyPinCmd = &cmds.Command{
 
 type RefKeyObject struct {
 	Type string
+	// Resumes counts the daemons which resumed a pin in progress.
+	Resumes int `json:",omitempty"`
 }
 
 type RefKeyList struct {
 	Keys map[string]RefKeyObject
 }
 
+// pinLsCodecs are the codecs pins can be listed by, named as the formats of
+// 'ipfs block put'.
+var pinLsCodecs = map[string]uint64{
+	"protobuf": cid.DagProtobuf,
+	"cbor":     cid.DagCBOR,
+	"raw":      cid.Raw,
+}
+
+// pinTypeRanks order the types of pins, for the pins listed twice once
+// their CIDs are in the same version. Other types rank lowest.
+var pinTypeRanks = map[string]int{
+	"indirect":  1,
+	"direct":    2,
+	"recursive": 3,
+}
+
+// pinLsFilter normalizes the CIDs listed by 'pin ls' and selects them.
+type pinLsFilter struct {
+	version  int // -1 to keep the versions
+	prefix   string
+	codec    uint64
+	hasCodec bool
+}
+
+func pinLsFilterFromRequest(req cmds.Request) (*pinLsFilter, error) {
+	f := &pinLsFilter{version: -1}
+
+	version, found, err := req.Option("cid-version").Int()
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		if version != 0 && version != 1 {
+			return nil, fmt.Errorf("unknown CID version %d, expected 0 or 1", version)
+		}
+		f.version = version
+	}
+
+	f.prefix, _, err = req.Option("prefix").String()
+	if err != nil {
+		return nil, err
+	}
+
+	codec, found, err := req.Option("codec").String()
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		f.codec, f.hasCodec = pinLsCodecs[codec]
+		if !f.hasCodec {
+			return nil, fmt.Errorf("unknown codec %q, expected protobuf, cbor or raw", codec)
+		}
+	}
+	return f, nil
+}
+
+// normalize returns c in the version of the filter, if it can be written
+// in it.
+func (f *pinLsFilter) normalize(c *cid.Cid) *cid.Cid {
+	switch {
+	case f.version == 1 && c.Version() == 0:
+		return cid.NewCidV1(cid.DagProtobuf, c.Hash())
+	case f.version == 0 && c.Version() == 1 && c.Type() == cid.DagProtobuf:
+		// version 0 CIDs are sha2-256 multihashes only
+		dh, err := mh.Decode(c.Hash())
+		if err == nil && dh.Code == mh.SHA2_256 && dh.Length == 32 {
+			return cid.NewCidV0(c.Hash())
+		}
+	}
+	return c
+}
+
+func (f *pinLsFilter) apply(keys map[string]RefKeyObject) (map[string]RefKeyObject, error) {
+	if f.version < 0 && f.prefix == "" && !f.hasCodec {
+		return keys, nil
+	}
+
+	out := make(map[string]RefKeyObject, len(keys))
+	for k, v := range keys {
+		c, err := cid.Decode(k)
+		if err != nil {
+			return nil, err
+		}
+		if f.hasCodec && c.Type() != f.codec {
+			continue
+		}
+
+		k = f.normalize(c).String()
+		if !strings.HasPrefix(k, f.prefix) {
+			continue
+		}
+		if prev, ok := out[k]; ok && pinTypeRanks[prev.Type] >= pinTypeRanks[v.Type] {
+			continue
+		}
+		out[k] = v
+	}
+	return out, nil
+}
+
 func pinLsKeys(args []string, typeStr string, ctx context.Context, n *core.IpfsNode) (map[string]RefKeyObject, error) {
 
 	mode, ok := pin.StringToPinMode(typeStr)
@@ -562,6 +761,28 @@ func pinLsAll(typeStr string, ctx context.Context, n *core.IpfsNode) (map[string
 	return keys, nil
 }
 
+// pinLsInProgress lists the pins being added, or interrupted, of the type
+// typeStr.
+func pinLsInProgress(typeStr string, n *core.IpfsNode) (map[string]RefKeyObject, error) {
+	jobs, err := corerepo.PinJobs(n.Repo.Datastore())
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]RefKeyObject)
+	for _, job := range jobs {
+		t := "direct"
+		if job.Recursive {
+			t = "recursive"
+		}
+		if typeStr != "all" && typeStr != t {
+			continue
+		}
+		keys[job.Cid] = RefKeyObject{Type: t, Resumes: job.Resumes}
+	}
+	return keys, nil
+}
+
 // PinVerifyRes is the result returned for each pin checked in "pin verify"
 type PinVerifyRes struct {
 	Cid string
@@ -0,0 +1,162 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	corerepo "github.com/ipfs/go-ipfs/core/corerepo"
+
+	u "gx/ipfs/QmWbjfz3u6HkAdPh34dgPchGbQjob6LXLhAeCGii2TX69n/go-ipfs-util"
+)
+
+// FollowedPinOutput is a path followed by 'ipfs pin add --follow'.
+type FollowedPinOutput struct {
+	Path    string
+	Cid     string
+	Type    string
+	KeepOld bool `json:",omitempty"`
+	Updated time.Time
+}
+
+type FollowedPinsOutput struct {
+	Followed []FollowedPinOutput
+}
+
+var pinFollowCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Manage the paths whose versions are pinned.",
+		ShortDescription: `
+'ipfs pin follow' lists the paths followed by 'ipfs pin add --follow', and
+stops following them. The daemon pins the new versions of the followed
+paths as they change.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"ls": pinFollowLsCmd,
+		"rm": pinFollowRmCmd,
+	},
+}
+
+var pinFollowLsCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "List the followed paths.",
+		ShortDescription: `
+'ipfs pin follow ls' lists the followed paths, with the versions they
+resolved to last, which are pinned, and when they were.
+`,
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		list, err := corerepo.FollowedPins(n.Repo.Datastore())
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		out := make([]FollowedPinOutput, 0, len(list))
+		for i := range list {
+			out = append(out, followedPinOutput(&list[i]))
+		}
+		res.SetOutput(&FollowedPinsOutput{Followed: out})
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			list, ok := res.Output().(*FollowedPinsOutput)
+			if !ok {
+				return nil, u.ErrCast()
+			}
+
+			buf := new(bytes.Buffer)
+			w := tabwriter.NewWriter(buf, 1, 2, 1, ' ', 0)
+			for _, f := range list.Followed {
+				keepOld := ""
+				if f.KeepOld {
+					keepOld = "\tkeeping the old versions"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s%s\n", f.Path, f.Cid, f.Type, keepOld)
+			}
+			w.Flush()
+			return buf, nil
+		},
+	},
+	Type: FollowedPinsOutput{},
+}
+
+var pinFollowRmCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Stop following paths.",
+		ShortDescription: `
+'ipfs pin follow rm' stops following the paths, and unpins the versions
+they resolved to last, unless --unpin=false is given. The older versions
+kept with --keep-old stay pinned.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("path", true, true, "Followed path(s) to stop following."),
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption("unpin", "Unpin the versions the paths resolved to last.").Default(true),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		unpin, _, _ := req.Option("unpin").Bool()
+
+		defer n.Blockstore.PinLock().Unlock()
+
+		out := make([]FollowedPinOutput, 0, len(req.Arguments()))
+		for _, p := range req.Arguments() {
+			f, err := corerepo.Unfollow(n, req.Context(), p, unpin)
+			if err == corerepo.ErrNotFollowed {
+				res.SetError(fmt.Errorf("%s is not followed", p), cmds.ErrNormal)
+				return
+			}
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+			out = append(out, followedPinOutput(f))
+		}
+		res.SetOutput(&FollowedPinsOutput{Followed: out})
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			list, ok := res.Output().(*FollowedPinsOutput)
+			if !ok {
+				return nil, u.ErrCast()
+			}
+
+			buf := new(bytes.Buffer)
+			for _, f := range list.Followed {
+				fmt.Fprintf(buf, "stopped following %s\n", f.Path)
+			}
+			return buf, nil
+		},
+	},
+	Type: FollowedPinsOutput{},
+}
+
+func followedPinOutput(f *corerepo.FollowedPin) FollowedPinOutput {
+	t := "direct"
+	if f.Recursive {
+		t = "recursive"
+	}
+	return FollowedPinOutput{
+		Path:    f.Path,
+		Cid:     f.Cid,
+		Type:    t,
+		KeepOld: f.KeepOld,
+		Updated: f.Updated,
+	}
+}
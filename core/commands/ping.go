@@ -5,11 +5,14 @@ import (
 	"fmt"
 	"io"
 	"reflect"
+	"sort"
 	"strings"
 	"time"
 
 	cmds "github.com/ipfs/go-ipfs/commands"
 	core "github.com/ipfs/go-ipfs/core"
+	health "github.com/ipfs/go-ipfs/core/health"
+
 	u "gx/ipfs/QmWbjfz3u6HkAdPh34dgPchGbQjob6LXLhAeCGii2TX69n/go-ipfs-util"
 	pstore "gx/ipfs/QmXZSd1qR5BxZkPyuwfT5jpqQFScZccoZvDneXsKzCNHWX/go-libp2p-peerstore"
 	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
@@ -33,6 +36,14 @@ var PingCmd = &cmds.Command{
 'ipfs ping' is a tool to test sending data to other nodes. It finds nodes
 via the routing system, sends pings, waits for pongs, and prints out round-
 trip latency information.
+
+With --transport or --address, the peer is pinged over the given transport or
+address only: the other connections to it are closed, and one over a matching
+address is opened if needed.
+
+With --health, the peer is asked whether its subsystems, bitswap and the DHT,
+are responsive, instead of being pinged. Peers answer unless they set
+Swarm.DisableHealthCheck.
 		`,
 	},
 	Arguments: []cmds.Argument{
@@ -40,6 +51,9 @@ trip latency information.
 	},
 	Options: []cmds.Option{
 		cmds.IntOption("count", "n", "Number of ping messages to send.").Default(10),
+		cmds.StringOption("transport", "t", "Ping over addresses of this transport only, e.g. tcp or quic."),
+		cmds.StringOption("address", "Ping over this address of the peer only."),
+		cmds.BoolOption("health", "Check the health of the subsystems of the peer instead.").Default(false),
 	},
 	Marshalers: cmds.MarshalerMap{
 		cmds.Text: func(res cmds.Response) (io.Reader, error) {
@@ -103,13 +117,65 @@ trip latency information.
 			return
 		}
 
-		outChan := pingPeer(ctx, n, peerID, numPings)
+		via, err := pingVia(req, n, peerID)
+		if err != nil {
+			res.SetError(err, cmds.ErrClient)
+			return
+		}
+
+		checkHealth, _, _ := req.Option("health").Bool()
+
+		outChan := pingPeer(ctx, n, peerID, numPings, via, checkHealth)
 		res.SetOutput(outChan)
 	},
 	Type: PingResult{},
 }
 
-func pingPeer(ctx context.Context, n *core.IpfsNode, pid peer.ID, numPings int) <-chan interface{} {
+// pingVia returns the function matching the addresses the options restrict
+// pings to, or nil if they don't.
+func pingVia(req cmds.Request, n *core.IpfsNode, pid peer.ID) (func(ma.Multiaddr) bool, error) {
+	transport, _, err := req.Option("transport").String()
+	if err != nil {
+		return nil, err
+	}
+	addrS, _, err := req.Option("address").String()
+	if err != nil {
+		return nil, err
+	}
+	if transport == "" && addrS == "" {
+		return nil, nil
+	}
+
+	if transport != "" && ma.ProtocolWithName(transport).Code == 0 {
+		return nil, fmt.Errorf("unknown transport %q", transport)
+	}
+
+	var addr ma.Multiaddr
+	if addrS != "" {
+		addr, err = ma.NewMultiaddr(addrS)
+		if err != nil {
+			return nil, err
+		}
+		n.Peerstore.AddAddr(pid, addr, pstore.TempAddrTTL)
+	}
+
+	return func(a ma.Multiaddr) bool {
+		if addr != nil && !a.Equal(addr) {
+			return false
+		}
+		if transport != "" {
+			for _, p := range a.Protocols() {
+				if p.Name == transport {
+					return true
+				}
+			}
+			return false
+		}
+		return true
+	}, nil
+}
+
+func pingPeer(ctx context.Context, n *core.IpfsNode, pid peer.ID, numPings int, via func(ma.Multiaddr) bool, checkHealth bool) <-chan interface{} {
 	outChan := make(chan interface{})
 	go func() {
 		defer close(outChan)
@@ -131,6 +197,25 @@ func pingPeer(ctx context.Context, n *core.IpfsNode, pid peer.ID, numPings int)
 			n.Peerstore.AddAddrs(p.ID, p.Addrs, pstore.TempAddrTTL)
 		}
 
+		if via != nil {
+			ctx, cancel := context.WithTimeout(ctx, kPingTimeout)
+			defer cancel()
+			addr, err := n.ConnectVia(ctx, pid, via)
+			if err != nil {
+				outChan <- &PingResult{Text: fmt.Sprintf("Connect error: %s", err)}
+				return
+			}
+			outChan <- &PingResult{
+				Text:    fmt.Sprintf("Connected to %s over %s.", pid.Pretty(), addr),
+				Success: true,
+			}
+		}
+
+		if checkHealth {
+			healthPeer(ctx, n, pid, outChan)
+			return
+		}
+
 		outChan <- &PingResult{
 			Text:    fmt.Sprintf("PING %s.", pid.Pretty()),
 			Success: true,
@@ -178,6 +263,44 @@ func pingPeer(ctx context.Context, n *core.IpfsNode, pid peer.ID, numPings int)
 	return outChan
 }
 
+func healthPeer(ctx context.Context, n *core.IpfsNode, pid peer.ID, outChan chan<- interface{}) {
+	outChan <- &PingResult{
+		Text:    fmt.Sprintf("HEALTH %s.", pid.Pretty()),
+		Success: true,
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, kPingTimeout)
+	defer cancel()
+	report, err := health.Request(ctx, n.PeerHost, pid)
+	if err != nil {
+		outChan <- &PingResult{Text: fmt.Sprintf("Health check error: %s", err)}
+		return
+	}
+
+	names := make([]string, 0, len(report.Checks))
+	for name := range report.Checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		c := report.Checks[name]
+		ms := c.Latency.Seconds() * 1000
+		if c.OK {
+			outChan <- &PingResult{
+				Success: true,
+				Time:    c.Latency,
+				Text:    fmt.Sprintf("%s: ok, time=%.2f ms", name, ms),
+			}
+		} else {
+			outChan <- &PingResult{
+				Time: c.Latency,
+				Text: fmt.Sprintf("%s: failed after %.2f ms: %s", name, ms, c.Error),
+			}
+		}
+	}
+}
+
 func ParsePeerParam(text string) (ma.Multiaddr, peer.ID, error) {
 	// to be replaced with just multiaddr parsing, once ptp is a multiaddr protocol
 	idx := strings.LastIndex(text, "/")
@@ -0,0 +1,155 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	pin "github.com/ipfs/go-ipfs/pin"
+
+	u "gx/ipfs/QmWbjfz3u6HkAdPh34dgPchGbQjob6LXLhAeCGii2TX69n/go-ipfs-util"
+)
+
+// PinJobOutput is a pin being added, as listed by 'ipfs pin jobs ls'.
+type PinJobOutput struct {
+	ID      int
+	Cid     string
+	Type    string
+	Fetched int
+	Started time.Time
+	Resumed bool `json:",omitempty"`
+}
+
+type PinJobsOutput struct {
+	Jobs []PinJobOutput
+}
+
+var pinJobsCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Manage the pins being added.",
+		ShortDescription: `
+'ipfs pin jobs' lists and cancels the pins being added by the daemon, whose
+blocks may take long to fetch.
+
+  > ipfs pin jobs ls
+  1 QmSomeHash recursive 1204 fetched 2017-06-01T12:00:00Z
+  > ipfs pin jobs cancel 1
+  canceled the pin of QmSomeHash
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"ls":     pinJobsLsCmd,
+		"cancel": pinJobsCancelCmd,
+	},
+}
+
+var pinJobsLsCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "List the pins being added.",
+		ShortDescription: `
+'ipfs pin jobs ls' lists the pins being added, with their IDs, the number of
+nodes they fetched or found locally so far, and when they started. The pins
+interrupted by the shutdown of the node, and resumed by the daemon, are
+marked as resumed.
+`,
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		jobs := n.PinJobs.Jobs()
+		out := make([]PinJobOutput, 0, len(jobs))
+		for _, j := range jobs {
+			out = append(out, pinJobOutput(j))
+		}
+		res.SetOutput(&PinJobsOutput{Jobs: out})
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			list, ok := res.Output().(*PinJobsOutput)
+			if !ok {
+				return nil, u.ErrCast()
+			}
+
+			buf := new(bytes.Buffer)
+			w := tabwriter.NewWriter(buf, 1, 2, 1, ' ', 0)
+			for _, j := range list.Jobs {
+				resumed := ""
+				if j.Resumed {
+					resumed = " resumed"
+				}
+				fmt.Fprintf(w, "%d\t%s\t%s\t%d fetched\t%s%s\n", j.ID, j.Cid, j.Type, j.Fetched, j.Started.Format(time.RFC3339), resumed)
+			}
+			w.Flush()
+			return buf, nil
+		},
+	},
+	Type: PinJobsOutput{},
+}
+
+var pinJobsCancelCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Cancel the adding of a pin.",
+		ShortDescription: `
+'ipfs pin jobs cancel' cancels the pin being added with the given ID, as
+listed by 'ipfs pin jobs ls'. The 'ipfs pin add' adding it fails, and the
+blocks it fetched are left unpinned, for the garbage collector.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("id", true, false, "ID of the pin job to cancel."),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		id, err := strconv.Atoi(req.Arguments()[0])
+		if err != nil {
+			res.SetError(fmt.Errorf("invalid pin job ID %q", req.Arguments()[0]), cmds.ErrClient)
+			return
+		}
+
+		j := n.PinJobs.Cancel(id)
+		if j == nil {
+			res.SetError(fmt.Errorf("no pin job with the ID %d", id), cmds.ErrNormal)
+			return
+		}
+		out := pinJobOutput(j)
+		res.SetOutput(&out)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			j, ok := res.Output().(*PinJobOutput)
+			if !ok {
+				return nil, u.ErrCast()
+			}
+			return bytes.NewBufferString(fmt.Sprintf("canceled the pin of %s\n", j.Cid)), nil
+		},
+	},
+	Type: PinJobOutput{},
+}
+
+func pinJobOutput(j *pin.Job) PinJobOutput {
+	t := "direct"
+	if j.Recursive {
+		t = "recursive"
+	}
+	return PinJobOutput{
+		ID:      j.ID,
+		Cid:     j.Cid.String(),
+		Type:    t,
+		Fetched: j.Fetched(),
+		Started: j.Started,
+		Resumed: j.Resumed,
+	}
+}
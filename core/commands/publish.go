@@ -11,7 +11,10 @@ import (
 	cmds "github.com/ipfs/go-ipfs/commands"
 	core "github.com/ipfs/go-ipfs/core"
 	keystore "github.com/ipfs/go-ipfs/keystore"
+	namesys "github.com/ipfs/go-ipfs/namesys"
+	ipnsrp "github.com/ipfs/go-ipfs/namesys/republisher"
 	path "github.com/ipfs/go-ipfs/path"
+	offline "github.com/ipfs/go-ipfs/routing/offline"
 
 	crypto "gx/ipfs/QmP1DfoUjiWH2ZBo1PBH6FupdBucbDepx3HpWmEY6JMUpY/go-libp2p-crypto"
 	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
@@ -35,6 +38,8 @@ and resolve, the default name used is the node's own PeerID,
 which is the hash of its public key.
 
 You can use the 'ipfs key' commands to list and generate more names and their respective keys.
+Publishing with a key past its expiry, set by 'ipfs key update', warns, or fails
+if Ipns.ExpiredKeys is "refuse" in the config.
 
 Examples:
 
@@ -54,6 +59,19 @@ Alternatively, publish an <ipfs-path> using a valid PeerID(as listed by 'ipfs ke
  > ipfs name publish --key=QmbCMUZw6JFeZ7Wp9jkzbye3Fzp2GGcPgC3nmeUjfVF87n /ipfs/QmatmE9msSfkKxoffpHwNLNKgwZG8eT9Bud6YoPab52vpy
   Published to QmbCMUZw6JFeZ7Wp9jkzbye3Fzp2GGcPgC3nmeUjfVF87n: /ipfs/QmatmE9msSfkKxoffpHwNLNKgwZG8eT9Bud6YoPab52vpy
 
+The record is valid for --lifetime, and resolvers may cache it for --ttl,
+or the default of their cache if it isn't given. With --enc=json, the output
+gives when the record expires, as EOL, and its TTL.
+
+ > ipfs name publish --lifetime=48h --ttl=5m /ipfs/QmatmE9msSfkKxoffpHwNLNKgwZG8eT9Bud6YoPab52vpy
+
+With --allow-offline, a record the network can't take, as the node is
+offline or has no peers, is signed and stored in the repo, and the daemon
+sends it once it is online, trying every minute. With --enc=json, the output
+has Pending set then.
+
+ > ipfs name publish --allow-offline /ipfs/QmatmE9msSfkKxoffpHwNLNKgwZG8eT9Bud6YoPab52vpy
+
 `,
 	},
 
@@ -63,11 +81,12 @@ Alternatively, publish an <ipfs-path> using a valid PeerID(as listed by 'ipfs ke
 	Options: []cmds.Option{
 		cmds.BoolOption("resolve", "Resolve given path before publishing.").Default(true),
 		cmds.StringOption("lifetime", "t",
-			`Time duration that the record will be valid for. <<default>>
+			`Time duration that the record will be valid for. Default: Ipns.RecordLifetime, or 24h.
     This accepts durations such as "300s", "1.5h" or "2h45m". Valid time units are
-    "ns", "us" (or "µs"), "ms", "s", "m", "h".`).Default("24h"),
-		cmds.StringOption("ttl", "Time duration this record should be cached for (caution: experimental)."),
+    "ns", "us" (or "µs"), "ms", "s", "m", "h".`),
+		cmds.StringOption("ttl", "Time duration resolvers may cache this record for, such as \"5m\"."),
 		cmds.StringOption("key", "k", "Name of the key to be used or a valid PeerID, as listed by 'ipfs key list -l'. Default: <<default>>.").Default("self"),
+		cmds.BoolOption("allow-offline", "Store the record to send it later if the network can't take it.").Default(false),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		log.Debug("begin publish")
@@ -100,25 +119,46 @@ Alternatively, publish an <ipfs-path> using a valid PeerID(as listed by 'ipfs ke
 		popts := new(publishOpts)
 
 		popts.verifyExists, _, _ = req.Option("resolve").Bool()
+		popts.allowOffline, _, _ = req.Option("allow-offline").Bool()
 
-		validtime, _, _ := req.Option("lifetime").String()
-		d, err := time.ParseDuration(validtime)
-		if err != nil {
-			res.SetError(fmt.Errorf("error parsing lifetime option: %s", err), cmds.ErrNormal)
-			return
+		if validtime, found, _ := req.Option("lifetime").String(); found {
+			d, err := time.ParseDuration(validtime)
+			if err != nil {
+				res.SetError(fmt.Errorf("error parsing lifetime option: %s", err), cmds.ErrNormal)
+				return
+			}
+			if d <= 0 {
+				res.SetError(errors.New("the lifetime of records must be positive"), cmds.ErrClient)
+				return
+			}
+			popts.pubValidTime = d
+		} else {
+			cfg, err := n.Repo.Config()
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+			_, popts.pubValidTime, err = ipnsrp.ParseConfig(cfg.Ipns)
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
 		}
 
-		popts.pubValidTime = d
-
 		ctx := req.Context()
 		if ttl, found, _ := req.Option("ttl").String(); found {
 			d, err := time.ParseDuration(ttl)
 			if err != nil {
-				res.SetError(err, cmds.ErrNormal)
+				res.SetError(fmt.Errorf("error parsing ttl option: %s", err), cmds.ErrNormal)
+				return
+			}
+			if d < 0 {
+				res.SetError(errors.New("the ttl of records can't be negative"), cmds.ErrClient)
 				return
 			}
 
-			ctx = context.WithValue(ctx, "ipns-publish-ttl", d)
+			popts.ttl = d.String()
+			ctx = namesys.WithPublishTTL(ctx, d)
 		}
 
 		kname, _, _ := req.Option("key").String()
@@ -127,6 +167,11 @@ Alternatively, publish an <ipfs-path> using a valid PeerID(as listed by 'ipfs ke
 			res.SetError(err, cmds.ErrNormal)
 			return
 		}
+		warning, err := checkKeyExpiry(n, k)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
 
 		pth, err := path.ParsePath(pstr)
 		if err != nil {
@@ -139,11 +184,18 @@ Alternatively, publish an <ipfs-path> using a valid PeerID(as listed by 'ipfs ke
 			res.SetError(err, cmds.ErrNormal)
 			return
 		}
+		output.Warning = warning
 		res.SetOutput(output)
 	},
 	Marshalers: cmds.MarshalerMap{
 		cmds.Text: func(res cmds.Response) (io.Reader, error) {
 			v := res.Output().(*IpnsEntry)
+			if v.Warning != "" {
+				fmt.Fprintf(res.Stderr(), "warning: %s\n", v.Warning)
+			}
+			if v.Pending {
+				fmt.Fprintf(res.Stderr(), "the node is offline, the record is stored and will be sent once it is online\n")
+			}
 			s := fmt.Sprintf("Published to %s: %s\n", v.Name, v.Value)
 			return strings.NewReader(s), nil
 		},
@@ -154,6 +206,8 @@ Alternatively, publish an <ipfs-path> using a valid PeerID(as listed by 'ipfs ke
 type publishOpts struct {
 	verifyExists bool
 	pubValidTime time.Duration
+	ttl          string // the TTL of the record, if set
+	allowOffline bool
 }
 
 func publish(ctx context.Context, n *core.IpfsNode, k crypto.PrivKey, ref path.Path, opts *publishOpts) (*IpnsEntry, error) {
@@ -166,21 +220,83 @@ func publish(ctx context.Context, n *core.IpfsNode, k crypto.PrivKey, ref path.P
 		}
 	}
 
-	eol := time.Now().Add(opts.pubValidTime)
-	err := n.Namesys.PublishWithEOL(ctx, k, ref, eol)
+	pid, err := peer.IDFromPrivateKey(k)
 	if err != nil {
 		return nil, err
 	}
 
-	pid, err := peer.IDFromPrivateKey(k)
+	eol := time.Now().Add(opts.pubValidTime)
+	pending := false
+	switch {
+	case opts.allowOffline && (!n.OnlineMode() || len(n.PeerHost.Network().Peers()) == 0):
+		pending = true
+		err = publishLocally(ctx, n, k, ref, eol)
+	case opts.allowOffline:
+		err = n.Namesys.PublishWithEOL(ctx, k, ref, eol)
+		if err != nil {
+			log.Warningf("publishing to the network failed, storing the record of %s to send it later: %s", pid.Pretty(), err)
+			pending = true
+			err = publishLocally(ctx, n, k, ref, eol)
+		}
+	default:
+		err = n.Namesys.PublishWithEOL(ctx, k, ref, eol)
+	}
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case pending:
+		err = namesys.MarkPending(n.Repo.Datastore(), pid)
+	case n.OnlineMode():
+		// an older record stored offline is superseded
+		err = namesys.ClearPending(n.Repo.Datastore(), pid)
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	return &IpnsEntry{
-		Name:  pid.Pretty(),
-		Value: ref.String(),
-	}, nil
+	out := &IpnsEntry{
+		Name:    pid.Pretty(),
+		Value:   ref.String(),
+		EOL:     eol.UTC(),
+		TTL:     opts.ttl,
+		Pending: pending,
+	}
+	return out, nil
+}
+
+// publishLocally signs the record of ref and stores it in the repo only, for
+// the republisher to send it to the network later.
+func publishLocally(ctx context.Context, n *core.IpfsNode, k crypto.PrivKey, ref path.Path, eol time.Time) error {
+	offroute := offline.NewOfflineRouter(n.Repo.Datastore(), n.PrivateKey)
+	return namesys.NewRoutingPublisher(offroute, n.Repo.Datastore()).PublishWithEOL(ctx, k, ref, eol)
+}
+
+// checkKeyExpiry returns a warning if the key k expired, or an error if
+// Ipns.ExpiredKeys refuses to publish with expired keys.
+func checkKeyExpiry(n *core.IpfsNode, k crypto.PrivKey) (string, error) {
+	id, err := peer.IDFromPrivateKey(k)
+	if err != nil {
+		return "", err
+	}
+	name, md, err := keystore.MetadataByID(n.Repo.Keystore(), id)
+	if err != nil || !md.Expired(time.Now()) {
+		return "", err
+	}
+
+	cfg, err := n.Repo.Config()
+	if err != nil {
+		return "", err
+	}
+	msg := fmt.Sprintf("the key %s expired on %s", name, md.Expires.Format(time.RFC3339))
+	switch cfg.Ipns.ExpiredKeys {
+	case "", "warn":
+		return msg, nil
+	case "refuse":
+		return "", fmt.Errorf("%s, and Ipns.ExpiredKeys refuses to publish with it", msg)
+	default:
+		return "", fmt.Errorf("invalid Ipns.ExpiredKeys %q: expected warn or refuse", cfg.Ipns.ExpiredKeys)
+	}
 }
 
 func keylookup(n *core.IpfsNode, k string) (crypto.PrivKey, error) {
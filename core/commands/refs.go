@@ -55,10 +55,11 @@ NOTE: List all references recursively by using the flag '-r'.
 		cmds.BoolOption("edges", "e", "Emit edge format: `<from> -> <to>`.").Default(false),
 		cmds.BoolOption("unique", "u", "Omit duplicate refs from output.").Default(false),
 		cmds.BoolOption("recursive", "r", "Recursively list links of child nodes.").Default(false),
+		offlineOption,
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		ctx := req.Context()
-		n, err := req.InvocContext().GetNode()
+		n, err := requestNode(req)
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
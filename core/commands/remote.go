@@ -0,0 +1,231 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	config "github.com/ipfs/go-ipfs/repo/config"
+	"github.com/ipfs/go-ipfs/repo/fsrepo"
+
+	u "gx/ipfs/QmWbjfz3u6HkAdPh34dgPchGbQjob6LXLhAeCGii2TX69n/go-ipfs-util"
+	ma "gx/ipfs/QmcyqRMCAXVtYPS4DiBrA7sezL9rRGfW8Ctx7cywL4TXJj/go-multiaddr"
+)
+
+type RemoteInfo struct {
+	Name string
+	API  string
+	Auth bool // whether credentials are stored, never the credentials
+}
+
+type RemoteList struct {
+	Remotes []RemoteInfo
+}
+
+var RemoteCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Manage the daemons known by name to --api.",
+		ShortDescription: `
+Remotes are daemons, and the credentials of their API, stored under a name in
+the config, so that commands run on them with --api=<name>:
+
+  > ipfs remote add gw1 /dns4/gw1.example.com/tcp/5001 --auth=admin:secret
+  > ipfs --api=gw1 swarm peers
+
+Credentials are sent to the API with each request, user:password as basic
+credentials, anything else as a bearer token, for APIs behind a proxy checking
+them. They can also be given with --api-auth, or $IPFS_API_AUTH, which take
+precedence over stored ones.
+
+The API of a remote may be an https URL, for a proxy serving it over TLS:
+
+  > ipfs remote add gw2 https://gw2.example.com --auth=admin:secret
+
+Credentials are only sent over plain http to loopback addresses, so that they
+cannot be read on their way.
+`,
+	},
+
+	Subcommands: map[string]*cmds.Command{
+		"add": remoteAddCmd,
+		"rm":  remoteRmCmd,
+		"ls":  remoteLsCmd,
+	},
+}
+
+var remoteAddCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Add a remote, or change it.",
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("name", true, false, "Name of the remote."),
+		cmds.StringArg("api", true, false, "Multiaddr or http(s) URL of the API of the remote."),
+	},
+	Options: []cmds.Option{
+		cmds.StringOption("auth", "Credentials for the API, user:password or a token."),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		name, api := req.Arguments()[0], req.Arguments()[1]
+		if err := validateRemoteName(name); err != nil {
+			res.SetError(err, cmds.ErrClient)
+			return
+		}
+		if strings.HasPrefix(api, "http://") || strings.HasPrefix(api, "https://") {
+			if u, err := url.Parse(api); err != nil || u.Host == "" {
+				res.SetError(fmt.Errorf("invalid API URL: %s", api), cmds.ErrClient)
+				return
+			}
+		} else if _, err := ma.NewMultiaddr(api); err != nil {
+			res.SetError(fmt.Errorf("invalid API address: %s", err), cmds.ErrClient)
+			return
+		}
+		auth, _, err := req.Option("auth").String()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		r, err := fsrepo.Open(req.InvocContext().ConfigRoot)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		defer r.Close()
+		cfg, err := r.Config()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		if cfg.Remotes == nil {
+			cfg.Remotes = make(map[string]config.Remote)
+		}
+		cfg.Remotes[name] = config.Remote{API: api, Auth: auth}
+		if err := r.SetConfig(cfg); err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		res.SetOutput(&RemoteList{[]RemoteInfo{{Name: name, API: api, Auth: auth != ""}}})
+	},
+	Type: RemoteList{},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: remoteListMarshaler("added "),
+	},
+}
+
+var remoteRmCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Remove a remote.",
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("name", true, false, "Name of the remote."),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		name := req.Arguments()[0]
+
+		r, err := fsrepo.Open(req.InvocContext().ConfigRoot)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		defer r.Close()
+		cfg, err := r.Config()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		rem, ok := cfg.Remotes[name]
+		if !ok {
+			res.SetError(fmt.Errorf("no remote named %q", name), cmds.ErrClient)
+			return
+		}
+		delete(cfg.Remotes, name)
+		if err := r.SetConfig(cfg); err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		res.SetOutput(&RemoteList{[]RemoteInfo{{Name: name, API: rem.API, Auth: rem.Auth != ""}}})
+	},
+	Type: RemoteList{},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: remoteListMarshaler("removed "),
+	},
+}
+
+var remoteLsCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "List the remotes.",
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		r, err := fsrepo.Open(req.InvocContext().ConfigRoot)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		defer r.Close()
+		cfg, err := r.Config()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		out := &RemoteList{Remotes: []RemoteInfo{}}
+		for name, rem := range cfg.Remotes {
+			out.Remotes = append(out.Remotes, RemoteInfo{Name: name, API: rem.API, Auth: rem.Auth != ""})
+		}
+		sort.Sort(remotesByName(out.Remotes))
+		res.SetOutput(out)
+	},
+	Type: RemoteList{},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: remoteListMarshaler(""),
+	},
+}
+
+type remotesByName []RemoteInfo
+
+func (s remotesByName) Len() int           { return len(s) }
+func (s remotesByName) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s remotesByName) Less(i, j int) bool { return s[i].Name < s[j].Name }
+
+func remoteListMarshaler(prefix string) func(cmds.Response) (io.Reader, error) {
+	return func(res cmds.Response) (io.Reader, error) {
+		v, ok := res.Output().(*RemoteList)
+		if !ok {
+			return nil, u.ErrCast()
+		}
+
+		buf := new(bytes.Buffer)
+		w := tabwriter.NewWriter(buf, 1, 2, 1, ' ', 0)
+		for _, rem := range v.Remotes {
+			auth := ""
+			if rem.Auth {
+				auth = "(auth)"
+			}
+			fmt.Fprintf(w, "%s%s\t%s\t%s\n", prefix, rem.Name, rem.API, auth)
+		}
+		w.Flush()
+		return buf, nil
+	}
+}
+
+func validateRemoteName(name string) error {
+	switch {
+	case name == "":
+		return errors.New("remote name is empty")
+	case strings.HasPrefix(name, "/"):
+		return errors.New("remote names cannot start with '/', which --api takes for a multiaddr")
+	case strings.ContainsAny(name, " \t\n"):
+		return errors.New("remote names cannot contain spaces")
+	}
+	return nil
+}
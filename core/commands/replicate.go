@@ -0,0 +1,241 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	core "github.com/ipfs/go-ipfs/core"
+	corerepo "github.com/ipfs/go-ipfs/core/corerepo"
+	path "github.com/ipfs/go-ipfs/path"
+
+	humanize "gx/ipfs/QmPSBJL4momYnE7DcUyk2DVhD6rH488ZmHBGLbxNdhU44K/go-humanize"
+	u "gx/ipfs/QmWbjfz3u6HkAdPh34dgPchGbQjob6LXLhAeCGii2TX69n/go-ipfs-util"
+	ma "gx/ipfs/QmcyqRMCAXVtYPS4DiBrA7sezL9rRGfW8Ctx7cywL4TXJj/go-multiaddr"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+	manet "gx/ipfs/Qmf1Gq7N45Rpuw7ev47uWgH6dLPtdnvcMRNPkVBwqjLJg2/go-multiaddr-net"
+)
+
+// ReplicateOutput is sent as the blocks of a dag are pushed, and once more,
+// with Done set, when they all are.
+type ReplicateOutput struct {
+	Root string
+	corerepo.ReplicateStats
+	Pinned bool `json:",omitempty"`
+	Done   bool `json:",omitempty"`
+}
+
+var ReplicateCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Push a dag to another node.",
+		ShortDescription: `
+'ipfs replicate' sends the blocks of a dag to the node whose API is given
+with --to, a multiaddr or the name of a remote of 'ipfs remote', rather than
+waiting for it to fetch them, such as before announcing new content. The
+blocks are read from this node, and put on the target with 'ipfs block put';
+the ones it has already are skipped. With --pin, the dag is pinned on the
+target once all its blocks are there.
+
+With --peer, the blocks are pushed to a peer over libp2p instead, which must
+allow this node to in its Swarm.BlockPush config; the peer checks and stores
+them, and keeps them from garbage collection until they are pinned, or until
+no more are pushed.
+
+The requests to the target failing are retried --retries times, after a
+delay doubled at each retry.
+
+  > ipfs replicate --to=/ip4/10.0.0.2/tcp/5001 --pin QmSomeHash
+  > ipfs replicate --to=gw1 --progress /ipns/example.com
+  > ipfs replicate --peer=QmGatewayID QmSomeHash
+`,
+	},
+
+	Arguments: []cmds.Argument{
+		cmds.StringArg("ipfs-path", true, false, "Path to the dag to push.").EnableStdin(),
+	},
+	Options: []cmds.Option{
+		cmds.StringOption("to", "t", "Multiaddr of the API of the target, or the name of a remote."),
+		cmds.StringOption("peer", "Push the blocks to this peer over libp2p."),
+		cmds.BoolOption("pin", "Pin the dag on the target.").Default(false),
+		cmds.IntOption("retries", "Number of times a failed request is retried.").Default(corerepo.DefaultReplicateRetries),
+		cmds.BoolOption("progress", "Show progress.").Default(false),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		to, _, err := req.Option("to").String()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		toPeer, _, err := req.Option("peer").String()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		pin, _, _ := req.Option("pin").Bool()
+
+		var target corerepo.Replica
+		switch {
+		case to != "" && toPeer != "":
+			res.SetError(fmt.Errorf("give either --to or --peer"), cmds.ErrClient)
+			return
+		case to != "":
+			target, err = replicaTarget(n, to)
+			if err != nil {
+				res.SetError(err, cmds.ErrClient)
+				return
+			}
+		case toPeer != "":
+			if !n.OnlineMode() {
+				res.SetError(errNotOnline, cmds.ErrClient)
+				return
+			}
+			pid, err := peer.IDB58Decode(strings.TrimPrefix(toPeer, "/ipfs/"))
+			if err != nil {
+				res.SetError(fmt.Errorf("invalid peer ID: %s", err), cmds.ErrClient)
+				return
+			}
+			target = &corerepo.PeerReplica{Node: n, Peer: pid}
+		default:
+			res.SetError(fmt.Errorf("give the API of the target with --to, or a peer with --peer"), cmds.ErrClient)
+			return
+		}
+
+		retries, _, err := req.Option("retries").Int()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		if retries < 0 {
+			res.SetError(fmt.Errorf("--retries must not be negative"), cmds.ErrClient)
+			return
+		}
+		showProgress, _, _ := req.Option("progress").Bool()
+
+		p, err := path.ParsePath(req.Arguments()[0])
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		nd, err := core.Resolve(req.Context(), n.Namesys, n.Resolver, p)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		root := nd.Cid().String()
+
+		out := make(chan interface{})
+		res.SetOutput((<-chan interface{})(out))
+
+		go func() {
+			defer close(out)
+			ctx := req.Context()
+
+			var progress func(corerepo.ReplicateStats)
+			if showProgress {
+				progress = func(stats corerepo.ReplicateStats) {
+					select {
+					case out <- &ReplicateOutput{Root: root, ReplicateStats: stats}:
+					case <-ctx.Done():
+					}
+				}
+			}
+
+			stats, err := corerepo.Replicate(ctx, n, nd.Cid(), target, retries, progress)
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+			if pin {
+				if err := target.Pin(ctx, nd.Cid()); err != nil {
+					res.SetError(fmt.Errorf("the blocks were pushed, but not pinned: %s", err), cmds.ErrNormal)
+					return
+				}
+			}
+
+			select {
+			case out <- &ReplicateOutput{Root: root, ReplicateStats: stats, Pinned: pin, Done: true}:
+			case <-ctx.Done():
+			}
+		}()
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			outChan, ok := res.Output().(<-chan interface{})
+			if !ok {
+				return nil, u.ErrCast()
+			}
+
+			buf := new(bytes.Buffer)
+			progressLine := false
+			for r0 := range outChan {
+				r, ok := r0.(*ReplicateOutput)
+				if !ok {
+					return nil, u.ErrCast()
+				}
+
+				if !r.Done {
+					if progressLine {
+						fmt.Fprintf(res.Stderr(), "\r")
+					}
+					fmt.Fprintf(res.Stderr(), "%s: %d blocks, %d pushed (%s)",
+						r.Root, r.Blocks, r.Pushed, humanize.Bytes(r.Bytes))
+					progressLine = true
+					continue
+				}
+
+				if progressLine {
+					fmt.Fprintf(res.Stderr(), "\n")
+					progressLine = false
+				}
+				fmt.Fprintf(buf, "replicated %s: %d blocks, %d already present, %d pushed (%s)\n",
+					r.Root, r.Blocks, r.Present, r.Pushed, humanize.Bytes(r.Bytes))
+				if r.Retries > 0 {
+					fmt.Fprintf(buf, "%d requests retried\n", r.Retries)
+				}
+				if r.Pinned {
+					fmt.Fprintf(buf, "pinned %s on the target\n", r.Root)
+				}
+			}
+			if progressLine {
+				fmt.Fprintf(res.Stderr(), "\n")
+			}
+			return buf, nil
+		},
+	},
+	Type: ReplicateOutput{},
+}
+
+// replicaTarget returns the target of 'ipfs replicate' at to, the multiaddr
+// of its API or the name of a remote.
+func replicaTarget(n *core.IpfsNode, to string) (*corerepo.ReplicaTarget, error) {
+	auth := ""
+	if !strings.HasPrefix(to, "/") {
+		cfg, err := n.Repo.Config()
+		if err != nil {
+			return nil, err
+		}
+		rem, ok := cfg.Remotes[to]
+		if !ok {
+			return nil, fmt.Errorf("no remote named %q, see 'ipfs remote ls'", to)
+		}
+		to, auth = rem.API, rem.Auth
+	}
+
+	addr, err := ma.NewMultiaddr(to)
+	if err != nil {
+		return nil, fmt.Errorf("invalid API address: %s", err)
+	}
+	_, host, err := manet.DialArgs(addr)
+	if err != nil {
+		return nil, err
+	}
+	return &corerepo.ReplicaTarget{Endpoint: "http://" + host, Auth: auth}, nil
+}
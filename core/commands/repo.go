@@ -2,12 +2,14 @@ package commands
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
 	"text/tabwriter"
+	"time"
 
 	bstore "github.com/ipfs/go-ipfs/blocks/blockstore"
 	cmds "github.com/ipfs/go-ipfs/commands"
@@ -16,6 +18,7 @@ import (
 	fsrepo "github.com/ipfs/go-ipfs/repo/fsrepo"
 	lockfile "github.com/ipfs/go-ipfs/repo/fsrepo/lock"
 
+	humanize "gx/ipfs/QmPSBJL4momYnE7DcUyk2DVhD6rH488ZmHBGLbxNdhU44K/go-humanize"
 	u "gx/ipfs/QmWbjfz3u6HkAdPh34dgPchGbQjob6LXLhAeCGii2TX69n/go-ipfs-util"
 	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
 )
@@ -33,11 +36,15 @@ var RepoCmd = &cmds.Command{
 	},
 
 	Subcommands: map[string]*cmds.Command{
-		"gc":      repoGcCmd,
-		"stat":    repoStatCmd,
-		"fsck":    RepoFsckCmd,
-		"version": repoVersionCmd,
-		"verify":  repoVerifyCmd,
+		"gc":              repoGcCmd,
+		"stat":            repoStatCmd,
+		"fsck":            RepoFsckCmd,
+		"version":         repoVersionCmd,
+		"verify":          repoVerifyCmd,
+		"reshard":         RepoReshardCmd,
+		"blocks":          repoBlocksCmd,
+		"journal":         repoJournalCmd,
+		"recovery-report": repoRecoveryReportCmd,
 	},
 }
 
@@ -54,11 +61,16 @@ var repoGcCmd = &cmds.Command{
 'ipfs repo gc' is a plumbing command that will sweep the local
 set of stored objects and remove ones that are not pinned in
 order to reclaim hard disk space.
+
+With '--last-runs=<n>', it does not collect garbage, and instead shows the
+start, duration, blocks removed, bytes freed and errors of the last <n>
+garbage collections, most recent first.
 `,
 	},
 	Options: []cmds.Option{
 		cmds.BoolOption("quiet", "q", "Write minimal output.").Default(false),
 		cmds.BoolOption("stream-errors", "Stream errors.").Default(false),
+		cmds.IntOption("last-runs", "Show the records of the last <n> runs instead of running."),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		n, err := req.InvocContext().GetNode()
@@ -67,6 +79,32 @@ order to reclaim hard disk space.
 			return
 		}
 
+		lastRuns, found, err := req.Option("last-runs").Int()
+		if err != nil {
+			res.SetError(err, cmds.ErrClient)
+			return
+		}
+		if found {
+			if lastRuns < 1 {
+				res.SetError(fmt.Errorf("--last-runs must be positive"), cmds.ErrClient)
+				return
+			}
+
+			runs, err := corerepo.LastGCRuns(n, lastRuns)
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+
+			outChan := make(chan interface{}, len(runs))
+			for i := range runs {
+				outChan <- &runs[i]
+			}
+			close(outChan)
+			res.SetOutput((<-chan interface{})(outChan))
+			return
+		}
+
 		streamErrors, _, _ := res.Request().Option("stream-errors").Bool()
 
 		gcOutChan := corerepo.GarbageCollectAsync(n, req.Context())
@@ -113,6 +151,10 @@ order to reclaim hard disk space.
 			}
 
 			marshal := func(v interface{}) (io.Reader, error) {
+				if run, ok := v.(*corerepo.GCRun); ok {
+					return bytes.NewBufferString(gcRunText(run)), nil
+				}
+
 				obj, ok := v.(*GcResult)
 				if !ok {
 					return nil, u.ErrCast()
@@ -139,6 +181,16 @@ order to reclaim hard disk space.
 	},
 }
 
+func gcRunText(run *corerepo.GCRun) string {
+	s := fmt.Sprintf("%s\ttook %s\tremoved %d blocks\tfreed %s\t%d errors\n",
+		run.Start.Format(time.RFC3339), run.Duration, run.BlocksRemoved,
+		humanize.Bytes(run.BytesFreed), run.ErrorCount)
+	for _, e := range run.Errors {
+		s += "\terror: " + e + "\n"
+	}
+	return s
+}
+
 var repoStatCmd = &cmds.Command{
 	Helptext: cmds.HelpText{
 		Tagline: "Get stats for the currently used repo.",
@@ -213,8 +265,18 @@ var RepoFsckCmd = &cmds.Command{
 'ipfs repo fsck' is a plumbing command that will remove repo and level db
 lockfiles, as well as the api file. This command can only run when no ipfs
 daemons are running.
+
+With --deep, it also checks the layout of the blocks directory: blocks
+stored outside of the directory their shard function assigns them to are
+moved into place, and temporary files left by interrupted writes are
+removed. Use --dry-run to only report these problems.
 `,
 	},
+	Options: []cmds.Option{
+		cmds.BoolOption("deep", "Check and repair the blocks directory layout.").Default(false),
+		cmds.BoolOption("dry-run", "With --deep, report problems without repairing them.").Default(false),
+		cmds.IntOption("workers", "Number of directories checked in parallel.").Default(8),
+	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		configRoot := req.InvocContext().ConfigRoot
 
@@ -248,7 +310,92 @@ daemons are running.
 			return
 		}
 
-		res.SetOutput(&MessageOutput{"Lockfiles have been removed.\n"})
+		msg := "Lockfiles have been removed.\n"
+
+		deep, _, _ := req.Option("deep").Bool()
+		if deep {
+			dryRun, _, _ := req.Option("dry-run").Bool()
+			workers, _, _ := req.Option("workers").Int()
+
+			problems, err := fsrepo.FsckFlatfs(fsrepo.FlatfsPath(configRoot), dryRun, workers)
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+
+			buf := new(bytes.Buffer)
+			buf.WriteString(msg)
+			for _, p := range problems {
+				switch {
+				case p.Error != "":
+					fmt.Fprintf(buf, "%s: %s, repair failed: %s\n", p.Path, p.Problem, p.Error)
+				case p.Repaired:
+					fmt.Fprintf(buf, "%s: %s, repaired\n", p.Path, p.Problem)
+				default:
+					fmt.Fprintf(buf, "%s: %s\n", p.Path, p.Problem)
+				}
+			}
+			fmt.Fprintf(buf, "Blocks directory checked, %d problems found.\n", len(problems))
+			msg = buf.String()
+		}
+
+		res.SetOutput(&MessageOutput{msg})
+	},
+	Type: MessageOutput{},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: MessageTextMarshaler,
+	},
+}
+
+var RepoReshardCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Change the sharding of the blocks directory.",
+		ShortDescription: `
+'ipfs repo reshard' moves the blocks of the repo to the directory layout of
+another flatfs shard function, in place. This command can only run when no
+ipfs daemons are running.
+`,
+		LongDescription: `
+'ipfs repo reshard' moves the blocks of the repo to the directory layout of
+another flatfs shard function, in place. Large repos may benefit from more
+shards than the default of /repo/flatfs/shard/v1/next-to-last/2. This command
+can only run when no ipfs daemons are running.
+
+The new shard function is recorded before any block is moved: if resharding
+is interrupted, 'ipfs repo fsck --deep' completes it.
+
+Example:
+
+  $ ipfs repo reshard /repo/flatfs/shard/v1/next-to-last/3
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("shard-func", true, false, "The new shard function."),
+	},
+	Options: []cmds.Option{
+		cmds.IntOption("workers", "Number of directories moved in parallel.").Default(8),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		configRoot := req.InvocContext().ConfigRoot
+
+		locked, err := fsrepo.LockedByOtherProcess(configRoot)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		if locked {
+			res.SetError(errors.New("repo is in use, stop the daemon before resharding"), cmds.ErrNormal)
+			return
+		}
+
+		workers, _, _ := req.Option("workers").Int()
+		moved, err := fsrepo.ReshardFlatfs(fsrepo.FlatfsPath(configRoot), req.Arguments()[0], workers)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		res.SetOutput(&MessageOutput{fmt.Sprintf("Moved %d blocks.\n", moved)})
 	},
 	Type: MessageOutput{},
 	Marshalers: cmds.MarshalerMap{
@@ -0,0 +1,115 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"time"
+
+	bstore "github.com/ipfs/go-ipfs/blocks/blockstore"
+	cmds "github.com/ipfs/go-ipfs/commands"
+	corerepo "github.com/ipfs/go-ipfs/core/corerepo"
+
+	u "gx/ipfs/QmWbjfz3u6HkAdPh34dgPchGbQjob6LXLhAeCGii2TX69n/go-ipfs-util"
+)
+
+var repoJournalCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Show the blocks added to and removed from the repo.",
+		ShortDescription: `
+'ipfs repo journal' shows the entries of the block journal, oldest first:
+their number, time, operation, source, CID and size. It needs the blocks to
+be journaled with Datastore.Journal.Enabled.
+`,
+		LongDescription: `
+'ipfs repo journal' shows the entries of the block journal, oldest first:
+their number, time, operation, source, CID and size. It needs the blocks to
+be journaled with Datastore.Journal.Enabled.
+
+The operations are 'put' and 'delete'. The sources are the operations of the
+node the blocks came from: 'bitswap' for the blocks fetched from other
+peers, 'gc' and 'evict' for the blocks removed by garbage collection and
+eviction, and 'local' for the others, such as the ones of 'ipfs add'.
+
+The entries older than Datastore.Journal.Retention are pruned. The entries
+after one are selected with --since, the last ones with --limit:
+
+  $ ipfs repo journal --source=bitswap --limit=2
+  1873  2017-06-02T10:41:12Z  put  bitswap  QmY3hE8xgFCjGcz6PHgnvJz5HZi1BaKRfPkn1ghZUcYMjD  262158
+  1874  2017-06-02T10:41:12Z  put  bitswap  QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn  4
+`,
+	},
+	Options: []cmds.Option{
+		cmds.UintOption("since", "Show the entries after this one.").Default(0),
+		cmds.StringOption("op", "Show the entries of this operation: put or delete."),
+		cmds.StringOption("source", "Show the entries of this source."),
+		cmds.StringOption("prefix", "Show the entries of CIDs starting with this prefix."),
+		cmds.IntOption("limit", "Show the last <n> entries only.").Default(0),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		since, _, _ := req.Option("since").Uint()
+		op, _, _ := req.Option("op").String()
+		source, _, _ := req.Option("source").String()
+		prefix, _, _ := req.Option("prefix").String()
+		limit, _, _ := req.Option("limit").Int()
+		switch op {
+		case "", bstore.JournalPut, bstore.JournalDelete:
+		default:
+			res.SetError(fmt.Errorf("unknown operation %q, expected %q or %q", op, bstore.JournalPut, bstore.JournalDelete), cmds.ErrClient)
+			return
+		}
+		if limit < 0 {
+			res.SetError(fmt.Errorf("--limit must not be negative"), cmds.ErrClient)
+			return
+		}
+
+		entries, err := corerepo.QueryJournal(n, corerepo.JournalQuery{
+			Since:  uint64(since),
+			Op:     op,
+			Source: source,
+			Prefix: prefix,
+			Limit:  limit,
+		})
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		outChan := make(chan interface{}, len(entries))
+		for i := range entries {
+			outChan <- &entries[i]
+		}
+		close(outChan)
+		res.SetOutput((<-chan interface{})(outChan))
+	},
+	Type: bstore.JournalEntry{},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			outChan, ok := res.Output().(<-chan interface{})
+			if !ok {
+				return nil, u.ErrCast()
+			}
+
+			marshal := func(v interface{}) (io.Reader, error) {
+				e, ok := v.(*bstore.JournalEntry)
+				if !ok {
+					return nil, u.ErrCast()
+				}
+				return bytes.NewBufferString(fmt.Sprintf("%d\t%s\t%s\t%s\t%s\t%d\n",
+					e.Seq, e.Time.UTC().Format(time.RFC3339), e.Op, e.Source, e.Cid, e.Size)), nil
+			}
+
+			return &cmds.ChannelMarshaler{
+				Channel:   outChan,
+				Marshaler: marshal,
+				Res:       res,
+			}, nil
+		},
+	},
+}
@@ -0,0 +1,87 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	corerepo "github.com/ipfs/go-ipfs/core/corerepo"
+
+	u "gx/ipfs/QmWbjfz3u6HkAdPh34dgPchGbQjob6LXLhAeCGii2TX69n/go-ipfs-util"
+)
+
+var repoRecoveryReportCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Show the report of the last recovery from an unclean shutdown.",
+		ShortDescription: `
+When the daemon did not shut down cleanly, after a crash or a kill, it checks
+the repo on its next start and fixes what it safely can:
+
+  - the api file of the crashed daemon is removed,
+  - the temporary files of interrupted writes of blocks and keys are removed,
+    and the blocks left out of place are moved,
+  - an MFS root whose block was lost is reset to an empty directory,
+  - the blocks waiting to be announced which were removed are dropped from
+    the provide queue.
+
+'ipfs repo recovery-report' shows what the last of these recoveries found.
+`,
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		report, err := corerepo.LastRecoveryReport(n.Repo.Datastore())
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		if report == nil {
+			report = &corerepo.RecoveryReport{}
+		}
+		res.SetOutput(report)
+	},
+	Type: corerepo.RecoveryReport{},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			report, ok := res.Output().(*corerepo.RecoveryReport)
+			if !ok {
+				return nil, u.ErrCast()
+			}
+
+			buf := new(bytes.Buffer)
+			if report.Time.IsZero() {
+				fmt.Fprintln(buf, "No unclean shutdown was recovered from.")
+				return buf, nil
+			}
+
+			fmt.Fprintf(buf, "Recovered at %s from the unclean shutdown of the daemon started at %s (pid %d).\n",
+				report.Time.Format(time.RFC3339), report.Daemon.Started.Format(time.RFC3339), report.Daemon.Pid)
+			for _, c := range report.Checks {
+				fmt.Fprintf(buf, "%s:\n", c.Check)
+				for _, note := range c.Notes {
+					fmt.Fprintf(buf, "  %s\n", note)
+				}
+				for _, p := range c.Problems {
+					switch {
+					case p.Error != "":
+						fmt.Fprintf(buf, "  %s, fix failed: %s\n", p.Problem, p.Error)
+					case p.Fixed:
+						fmt.Fprintf(buf, "  %s, fixed\n", p.Problem)
+					default:
+						fmt.Fprintf(buf, "  %s\n", p.Problem)
+					}
+				}
+				if len(c.Notes) == 0 && len(c.Problems) == 0 {
+					fmt.Fprintln(buf, "  no problem found")
+				}
+			}
+			return buf, nil
+		},
+	},
+}
@@ -52,6 +52,13 @@ Resolve the value of an IPFS DAG path:
   $ ipfs resolve /ipfs/QmeZy1fGbwgVSrqbfh9fKQrAWgeyRnj7h8fsHS1oy3k99x/beep/boop
   /ipfs/QmYRMjyvAiHKN9UTi8Bzt1HUspmSRD8T8DwxfSMzLgBon1
 
+Resolve the current value of a path in the files API (MFS):
+
+  $ ipfs resolve /mfs/photos/cat.jpg
+  /ipfs/QmYRMjyvAiHKN9UTi8Bzt1HUspmSRD8T8DwxfSMzLgBon1
+
+Plugins may add other namespaces. Resolutions are cached by the node.
+
 `,
 	},
 
@@ -60,10 +67,11 @@ Resolve the value of an IPFS DAG path:
 	},
 	Options: []cmds.Option{
 		cmds.BoolOption("recursive", "r", "Resolve until the result is an IPFS name.").Default(false),
+		offlineOption,
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
 
-		n, err := req.InvocContext().GetNode()
+		n, err := requestNode(req)
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
@@ -15,13 +15,14 @@ import (
 var log = logging.Logger("core/commands")
 
 const (
-	ApiOption = "api"
+	ApiOption     = "api"
+	ApiAuthOption = "api-auth"
 )
 
 var Root = &cmds.Command{
 	Helptext: cmds.HelpText{
 		Tagline:  "Global p2p merkle-dag filesystem.",
-		Synopsis: "ipfs [--config=<config> | -c] [--debug=<debug> | -D] [--help=<help>] [-h=<h>] [--local=<local> | -L] [--api=<api>] <command> ...",
+		Synopsis: "ipfs [--config=<config> | -c] [--debug=<debug> | -D] [--help=<help>] [-h=<h>] [--local=<local> | -L] [--api=<api>] [--api-auth=<api-auth>] <command> ...",
 		Subcommands: `
 BASIC COMMANDS
   init          Initialize ipfs local configuration
@@ -30,6 +31,7 @@ BASIC COMMANDS
   get <ref>     Download IPFS objects
   ls <ref>      List links from an object
   refs <ref>    List hashes of links from an object
+  search        Search the files added to this node (experimental)
 
 DATA STRUCTURE COMMANDS
   block         Interact with raw blocks in the datastore
@@ -46,6 +48,8 @@ ADVANCED COMMANDS
   dns           Resolve DNS links
   pin           Pin objects to local storage
   repo          Manipulate the IPFS repository
+  cache         Fetch dags into the local blockstore
+  replicate     Push dags to another node
   stats         Various operational stats
   ptp           Libp2p stream mounting
   filestore     Manage the filestore (experimental)
@@ -56,10 +60,12 @@ NETWORK COMMANDS
   swarm         Manage connections to the p2p network
   dht           Query the DHT for values or peers
   ping          Measure the latency of a connection
+  availability  Check whether other peers can fetch content
   diag          Print diagnostics
 
 TOOL COMMANDS
   config        Manage configuration
+  remote        Manage the daemons known by name to --api
   version       Show ipfs version information
   update        Download and apply go-ipfs updates
   commands      List all available commands
@@ -85,7 +91,8 @@ The CLI will exit with one of the following values:
 		cmds.BoolOption("help", "Show the full command help text.").Default(false),
 		cmds.BoolOption("h", "Show a short version of the command help text.").Default(false),
 		cmds.BoolOption("local", "L", "Run the command locally, instead of using the daemon.").Default(false),
-		cmds.StringOption(ApiOption, "Use a specific API instance (defaults to /ip4/127.0.0.1/tcp/5001)"),
+		cmds.StringOption(ApiOption, "Use a specific API instance, by multiaddr, http(s) URL or remote name (defaults to /ip4/127.0.0.1/tcp/5001)"),
+		cmds.StringOption(ApiAuthOption, "Credentials for the API, user:password or a token (defaults to $IPFS_API_AUTH)"),
 	},
 }
 
@@ -93,42 +100,47 @@ The CLI will exit with one of the following values:
 var CommandsDaemonCmd = CommandsCmd(Root)
 
 var rootSubcommands = map[string]*cmds.Command{
-	"add":       AddCmd,
-	"block":     BlockCmd,
-	"bootstrap": BootstrapCmd,
-	"cat":       CatCmd,
-	"commands":  CommandsDaemonCmd,
-	"config":    ConfigCmd,
-	"dag":       dag.DagCmd,
-	"dht":       DhtCmd,
-	"diag":      DiagCmd,
-	"dns":       DNSCmd,
-	"files":     files.FilesCmd,
-	"get":       GetCmd,
-	"id":        IDCmd,
-	"key":       KeyCmd,
-	"log":       LogCmd,
-	"ls":        LsCmd,
-	"mount":     MountCmd,
-	"name":      NameCmd,
-	"object":    ocmd.ObjectCmd,
-	"pin":       PinCmd,
-	"ping":      PingCmd,
-	"ptp":       PTPCmd,
-	"pubsub":    PubsubCmd,
-	"refs":      RefsCmd,
-	"repo":      RepoCmd,
-	"resolve":   ResolveCmd,
-	"stats":     StatsCmd,
-	"swarm":     SwarmCmd,
-	"tar":       TarCmd,
-	"tour":      tourCmd,
-	"file":      unixfs.UnixFSCmd,
-	"update":    ExternalBinary(),
-	"version":   VersionCmd,
-	"bitswap":   BitswapCmd,
-	"filestore": FileStoreCmd,
-	"shutdown":  daemonShutdownCmd,
+	"add":          AddCmd,
+	"availability": AvailabilityCmd,
+	"block":        BlockCmd,
+	"bootstrap":    BootstrapCmd,
+	"cache":        CacheCmd,
+	"cat":          CatCmd,
+	"commands":     CommandsDaemonCmd,
+	"config":       ConfigCmd,
+	"dag":          dag.DagCmd,
+	"dht":          DhtCmd,
+	"diag":         DiagCmd,
+	"dns":          DNSCmd,
+	"files":        files.FilesCmd,
+	"get":          GetCmd,
+	"id":           IDCmd,
+	"key":          KeyCmd,
+	"log":          LogCmd,
+	"ls":           LsCmd,
+	"mount":        MountCmd,
+	"name":         NameCmd,
+	"object":       ocmd.ObjectCmd,
+	"pin":          PinCmd,
+	"ping":         PingCmd,
+	"ptp":          PTPCmd,
+	"pubsub":       PubsubCmd,
+	"refs":         RefsCmd,
+	"replicate":    ReplicateCmd,
+	"remote":       RemoteCmd,
+	"repo":         RepoCmd,
+	"resolve":      ResolveCmd,
+	"search":       SearchCmd,
+	"stats":        StatsCmd,
+	"swarm":        SwarmCmd,
+	"tar":          TarCmd,
+	"tour":         tourCmd,
+	"file":         unixfs.UnixFSCmd,
+	"update":       UpdateCmd,
+	"version":      VersionCmd,
+	"bitswap":      BitswapCmd,
+	"filestore":    FileStoreCmd,
+	"shutdown":     daemonShutdownCmd,
 }
 
 // RootRO is the readonly version of Root
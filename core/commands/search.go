@@ -0,0 +1,91 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	search "github.com/ipfs/go-ipfs/core/search"
+)
+
+type SearchOutput struct {
+	Entries []search.Entry
+}
+
+var SearchCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Search the files added to this node (experimental).",
+		ShortDescription: `
+'ipfs search' lists the files imported with 'ipfs add' or written with
+'ipfs files write' whose name has words starting with every given term,
+ignoring case.
+
+Files are only indexed while the index is enabled with:
+
+  ipfs config --json Experimental.LocalSearchIndex true
+
+Files are listed at their path: /ipfs/<root>/<path> for the files added,
+<root> being the top of the tree they were added in, and /mfs/<path> for
+the files written to MFS. Files no longer pinned through their root, or no
+longer in MFS, are not listed.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("term", false, true, "Terms the names must have words starting with."),
+	},
+	Options: []cmds.Option{
+		cmds.StringOption("type", "t", "Only list files whose mime type starts with this, such as 'image/'."),
+		cmds.StringOption("path", "p", "Only list files under this path, such as '/mfs/docs'."),
+		cmds.IntOption("limit", "n", "Maximum number of files to list, 0 for no limit.").Default(100),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		mimeType, _, _ := req.Option("type").String()
+		pth, _, _ := req.Option("path").String()
+		limit, _, err := req.Option("limit").Int()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		entries, err := search.Search(n, search.Query{
+			Terms:    req.Arguments(),
+			MimeType: mimeType,
+			Path:     pth,
+			Limit:    limit,
+		})
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		if entries == nil {
+			entries = []search.Entry{}
+		}
+
+		res.SetOutput(&SearchOutput{entries})
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out, ok := res.Output().(*SearchOutput)
+			if !ok {
+				return nil, fmt.Errorf("expected a SearchOutput as command result")
+			}
+
+			buf := new(bytes.Buffer)
+			w := tabwriter.NewWriter(buf, 1, 2, 1, ' ', 0)
+			for _, e := range out.Entries {
+				fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", e.Cid, e.Size, e.MimeType, e.Path)
+			}
+			w.Flush()
+			return buf, nil
+		},
+	},
+	Type: SearchOutput{},
+}
@@ -27,9 +27,13 @@ for your IPFS node.`,
 	},
 
 	Subcommands: map[string]*cmds.Command{
-		"bw":      statBwCmd,
-		"repo":    repoStatCmd,
-		"bitswap": bitswapStatCmd,
+		"bw":        statBwCmd,
+		"repo":      repoStatCmd,
+		"bitswap":   bitswapStatCmd,
+		"net":       statNetCmd,
+		"provide":   statProvideCmd,
+		"want":      statWantCmd,
+		"protocols": statProtocolsCmd,
 	},
 }
 
@@ -0,0 +1,109 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+
+	ma "gx/ipfs/QmcyqRMCAXVtYPS4DiBrA7sezL9rRGfW8Ctx7cywL4TXJj/go-multiaddr"
+)
+
+// addressFamilies lists the families of NetStats, in display order.
+var addressFamilies = []string{"ip4", "ip6", "other"}
+
+// FamilyStats counts the connections and addresses of an address family.
+type FamilyStats struct {
+	Peers          int
+	ListenAddrs    int
+	AnnouncedAddrs int
+}
+
+// NetStats is the output of 'ipfs stats net', by address family.
+type NetStats struct {
+	Families map[string]*FamilyStats
+}
+
+func addrFamily(a ma.Multiaddr) string {
+	for _, p := range strings.Split(a.String(), "/") {
+		if p == "ip4" || p == "ip6" {
+			return p
+		}
+	}
+	return "other"
+}
+
+var statNetCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Print IPv4 and IPv6 connectivity information.",
+		ShortDescription: `
+'ipfs stats net' counts, for IPv4, IPv6 and other addresses, the peers the
+node is connected to, the addresses it listens on, and the addresses it
+announces to its peers.
+
+Which family is preferred, or used at all, is set by Swarm.AddressFamily.
+`,
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		if !n.OnlineMode() {
+			res.SetError(errNotOnline, cmds.ErrClient)
+			return
+		}
+
+		out := &NetStats{Families: make(map[string]*FamilyStats)}
+		for _, f := range addressFamilies {
+			out.Families[f] = &FamilyStats{}
+		}
+
+		network := n.PeerHost.Network()
+		for _, c := range network.Conns() {
+			out.Families[addrFamily(c.RemoteMultiaddr())].Peers++
+		}
+
+		listen, err := network.InterfaceListenAddresses()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		for _, a := range listen {
+			out.Families[addrFamily(a)].ListenAddrs++
+		}
+
+		for _, a := range n.PeerHost.Addrs() {
+			out.Families[addrFamily(a)].AnnouncedAddrs++
+		}
+
+		res.SetOutput(out)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out, ok := res.Output().(*NetStats)
+			if !ok {
+				return nil, fmt.Errorf("expected a NetStats as command result")
+			}
+
+			buf := new(bytes.Buffer)
+			w := tabwriter.NewWriter(buf, 1, 2, 1, ' ', 0)
+			fmt.Fprintln(w, "Family\tPeers\tListening\tAnnounced")
+			for _, f := range addressFamilies {
+				s, ok := out.Families[f]
+				if !ok {
+					continue
+				}
+				fmt.Fprintf(w, "%s\t%d\t%d\t%d\n", f, s.Peers, s.ListenAddrs, s.AnnouncedAddrs)
+			}
+			w.Flush()
+			return buf, nil
+		},
+	},
+	Type: NetStats{},
+}
@@ -0,0 +1,85 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	bwprofile "github.com/ipfs/go-ipfs/core/bwprofile"
+
+	humanize "gx/ipfs/QmPSBJL4momYnE7DcUyk2DVhD6rH488ZmHBGLbxNdhU44K/go-humanize"
+)
+
+// ProtocolStats is the output of 'ipfs stats protocols', by limit.
+type ProtocolStats struct {
+	Protocols []bwprofile.ProtocolStat
+}
+
+var statProtocolsCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Print the use of the limits of protocols.",
+		ShortDescription: `
+'ipfs stats protocols' shows, for each limit of Swarm.ProtocolLimits, the
+streams of its protocols open and refused since the daemon started, and their
+bandwidth over the last second, next to the caps.
+`,
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		if !n.OnlineMode() {
+			res.SetError(errNotOnline, cmds.ErrClient)
+			return
+		}
+
+		out := &ProtocolStats{Protocols: []bwprofile.ProtocolStat{}}
+		if n.ProtocolLimits != nil {
+			out.Protocols = n.ProtocolLimits.Stats()
+		}
+		res.SetOutput(out)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out, ok := res.Output().(*ProtocolStats)
+			if !ok {
+				return nil, fmt.Errorf("expected a ProtocolStats as command result")
+			}
+
+			buf := new(bytes.Buffer)
+			if len(out.Protocols) == 0 {
+				fmt.Fprintln(buf, "no protocol is limited, see Swarm.ProtocolLimits")
+				return buf, nil
+			}
+
+			rate := func(used, limit float64) string {
+				s := humanize.Bytes(uint64(used)) + "/s"
+				if limit > 0 {
+					s += " of " + humanize.Bytes(uint64(limit)) + "/s"
+				}
+				return s
+			}
+
+			w := tabwriter.NewWriter(buf, 1, 2, 1, ' ', 0)
+			fmt.Fprintln(w, "Protocol\tStreams\tRefused\tRateIn\tRateOut\tTotalIn\tTotalOut")
+			for _, p := range out.Protocols {
+				streams := strconv.Itoa(p.Streams)
+				if p.MaxStreams > 0 {
+					streams += "/" + strconv.Itoa(p.MaxStreams)
+				}
+				fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%s\t%s\n", p.Protocol, streams, p.Refused,
+					rate(p.RateIn, p.LimitIn), rate(p.RateOut, p.LimitOut),
+					humanize.Bytes(p.TotalIn), humanize.Bytes(p.TotalOut))
+			}
+			w.Flush()
+			return buf, nil
+		},
+	},
+	Type: ProtocolStats{},
+}
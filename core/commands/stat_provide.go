@@ -0,0 +1,70 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	rp "github.com/ipfs/go-ipfs/exchange/reprovide"
+)
+
+// ProvideStats is the output of 'ipfs stats provide', by lane.
+type ProvideStats struct {
+	Lanes []rp.LaneStat
+}
+
+var statProvideCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Print the state of the announcements of content.",
+		ShortDescription: `
+'ipfs stats provide' shows the announcements to the routing system waiting
+and being sent, and the ones sent and failed since the daemon started, in
+each lane: 'fresh' for the blocks just added or fetched, and 'reprovide' for
+the periodic reprovides.
+
+The fresh lane is served first, so that a large reprovide doesn't delay the
+announcement of new content. The announcements sent at once, and the rate of
+each lane, are limited by Reprovider.Concurrency, Reprovider.ProvideRate and
+Reprovider.ReprovideRate.
+`,
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		if !n.OnlineMode() || n.Provides == nil {
+			res.SetError(errNotOnline, cmds.ErrClient)
+			return
+		}
+
+		res.SetOutput(&ProvideStats{Lanes: n.Provides.Stat()})
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out, ok := res.Output().(*ProvideStats)
+			if !ok {
+				return nil, fmt.Errorf("expected a ProvideStats as command result")
+			}
+
+			buf := new(bytes.Buffer)
+			w := tabwriter.NewWriter(buf, 1, 2, 1, ' ', 0)
+			fmt.Fprintln(w, "Lane\tRate\tPending\tActive\tProvided\tFailed")
+			for _, l := range out.Lanes {
+				rate := "-"
+				if l.Rate > 0 {
+					rate = strconv.Itoa(l.Rate) + "/s"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%d\n", l.Lane, rate, l.Pending, l.Active, l.Provided, l.Failed)
+			}
+			w.Flush()
+			return buf, nil
+		},
+	},
+	Type: ProvideStats{},
+}
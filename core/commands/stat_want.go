@@ -0,0 +1,88 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	bitswap "github.com/ipfs/go-ipfs/exchange/bitswap"
+
+	u "gx/ipfs/QmWbjfz3u6HkAdPh34dgPchGbQjob6LXLhAeCGii2TX69n/go-ipfs-util"
+)
+
+// WantStats is the output of 'ipfs stats want'.
+type WantStats struct {
+	Wants []bitswap.WantStat
+}
+
+var statWantCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "List the blocks wanted for long, and who wants them.",
+		ShortDescription: `
+'ipfs stats want' lists the blocks the node has been fetching for longer
+than --older-than, oldest first, with the commands or gateway requests
+waiting for them, and the providers found by the searches of the routing
+system.
+
+A block still without providers after a few searches is likely not
+available on the network, or its CID mistyped.
+`,
+	},
+	Options: []cmds.Option{
+		cmds.StringOption("older-than", "List the blocks wanted for longer than this duration.").Default("1m"),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		if !n.OnlineMode() {
+			res.SetError(errNotOnline, cmds.ErrClient)
+			return
+		}
+
+		bs, ok := n.Exchange.(*bitswap.Bitswap)
+		if !ok {
+			res.SetError(u.ErrCast(), cmds.ErrNormal)
+			return
+		}
+
+		olderThanS, _, err := req.Option("older-than").String()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		olderThan, err := time.ParseDuration(olderThanS)
+		if err != nil {
+			res.SetError(fmt.Errorf("invalid --older-than: %s", err), cmds.ErrClient)
+			return
+		}
+
+		res.SetOutput(&WantStats{Wants: bs.WantStats(olderThan)})
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out, ok := res.Output().(*WantStats)
+			if !ok {
+				return nil, fmt.Errorf("expected a WantStats as command result")
+			}
+
+			buf := new(bytes.Buffer)
+			w := tabwriter.NewWriter(buf, 1, 2, 1, ' ', 0)
+			fmt.Fprintln(w, "CID\tWanted for\tProviders\tSearches\tRequested by")
+			for _, st := range out.Wants {
+				wanted := time.Since(st.Since) / time.Second * time.Second
+				fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\n", st.Cid, wanted, st.Providers, st.Searches, strings.Join(st.Requesters, ", "))
+			}
+			w.Flush()
+			return buf, nil
+		},
+	},
+	Type: WantStats{},
+}
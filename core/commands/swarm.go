@@ -5,16 +5,24 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"path"
 	"sort"
+	"strings"
+	"time"
 
 	cmds "github.com/ipfs/go-ipfs/commands"
+	core "github.com/ipfs/go-ipfs/core"
+	gater "github.com/ipfs/go-ipfs/core/gater"
 	repo "github.com/ipfs/go-ipfs/repo"
 	config "github.com/ipfs/go-ipfs/repo/config"
 	"github.com/ipfs/go-ipfs/repo/fsrepo"
 	iaddr "github.com/ipfs/go-ipfs/thirdparty/ipfsaddr"
+	inet "gx/ipfs/QmRscs8KxrSmSv4iuevHv8JfuUzHBMoqiaHzxfDRiksd6e/go-libp2p-net"
 	swarm "gx/ipfs/QmVkDnNm71vYyY6s6rXwtmyDYis3WkKyrEhMECwT6R12uJ/go-libp2p-swarm"
+	u "gx/ipfs/QmWbjfz3u6HkAdPh34dgPchGbQjob6LXLhAeCGii2TX69n/go-ipfs-util"
 	pstore "gx/ipfs/QmXZSd1qR5BxZkPyuwfT5jpqQFScZccoZvDneXsKzCNHWX/go-libp2p-peerstore"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
 
 	mafilter "gx/ipfs/QmSMZwvs3n4GBikZ7hKzT17c3bk65FmyZo2JqtJ16swqCv/multiaddr-filter"
 	ma "gx/ipfs/QmcyqRMCAXVtYPS4DiBrA7sezL9rRGfW8Ctx7cywL4TXJj/go-multiaddr"
@@ -39,6 +47,7 @@ ipfs peers in the internet.
 	},
 	Subcommands: map[string]*cmds.Command{
 		"addrs":      swarmAddrsCmd,
+		"cert":       swarmCertCmd,
 		"connect":    swarmConnectCmd,
 		"disconnect": swarmDisconnectCmd,
 		"filters":    swarmFiltersCmd,
@@ -305,6 +314,18 @@ var swarmConnectCmd = &cmds.Command{
 The address format is an IPFS multiaddr:
 
 ipfs swarm connect /ip4/104.131.131.82/tcp/4001/ipfs/QmaCpDMGvV2BGHeYERUEnRQAwe3N8SzbUtfsmvsqQLuvuJ
+`,
+		LongDescription: `
+'ipfs swarm connect' opens a new direct connection to a peer address.
+
+The address format is an IPFS multiaddr:
+
+ipfs swarm connect /ip4/104.131.131.82/tcp/4001/ipfs/QmaCpDMGvV2BGHeYERUEnRQAwe3N8SzbUtfsmvsqQLuvuJ
+
+Several addresses of the same peer may be given, they are all dialed for
+that peer. The failure of each peer is reported, after the other peers were
+dialed. The dial backoff of the peers is cleared, so that they are dialed even
+if they recently failed.
 `,
 	},
 	Arguments: []cmds.Argument{
@@ -339,21 +360,29 @@ ipfs swarm connect /ip4/104.131.131.82/tcp/4001/ipfs/QmaCpDMGvV2BGHeYERUEnRQAwe3
 			res.SetError(err, cmds.ErrNormal)
 			return
 		}
+		pis = groupPeerAddrs(pis)
 
 		output := make([]string, len(pis))
+		var failures []string
 		for i, pi := range pis {
 			swrm.Backoff().Clear(pi.ID)
 
 			output[i] = "connect " + pi.ID.Pretty()
 
+			// the swarm dials all the addresses of the peer at once, and
+			// reports a single error
 			err := n.PeerHost.Connect(ctx, pi)
 			if err != nil {
-				res.SetError(fmt.Errorf("%s failure: %s", output[i], err), cmds.ErrNormal)
-				return
+				failures = append(failures, fmt.Sprintf("%s failure: %s", output[i], err))
+				continue
 			}
 			output[i] += " success"
 		}
 
+		if len(failures) > 0 {
+			res.SetError(errors.New(strings.Join(failures, "\n")), cmds.ErrNormal)
+			return
+		}
 		res.SetOutput(&stringList{output})
 	},
 	Marshalers: cmds.MarshalerMap{
@@ -362,21 +391,55 @@ ipfs swarm connect /ip4/104.131.131.82/tcp/4001/ipfs/QmaCpDMGvV2BGHeYERUEnRQAwe3
 	Type: stringList{},
 }
 
+// groupPeerAddrs merges the addresses of the same peer, keeping the order in
+// which peers and addresses were given.
+func groupPeerAddrs(pis []pstore.PeerInfo) []pstore.PeerInfo {
+	var out []pstore.PeerInfo
+	index := make(map[peer.ID]int)
+	for _, pi := range pis {
+		i, ok := index[pi.ID]
+		if !ok {
+			index[pi.ID] = len(out)
+			out = append(out, pstore.PeerInfo{ID: pi.ID})
+			i = len(out) - 1
+		}
+		out[i].Addrs = append(out[i].Addrs, pi.Addrs...)
+	}
+	return out
+}
+
+type SwarmDisconnectResult struct {
+	Target string
+	Peer   string `json:",omitempty"`
+	Closed int    // connections closed
+	Banned string `json:",omitempty"` // end of the ban, if any
+	Error  string `json:",omitempty"`
+}
+
 var swarmDisconnectCmd = &cmds.Command{
 	Helptext: cmds.HelpText{
-		Tagline: "Close connection to a given address.",
+		Tagline: "Close connections to peers, addresses or subnets.",
 		ShortDescription: `
-'ipfs swarm disconnect' closes a connection to a peer address. The address
-format is an IPFS multiaddr:
+'ipfs swarm disconnect' closes connections. Each target is one of:
 
-ipfs swarm disconnect /ip4/104.131.131.82/tcp/4001/ipfs/QmaCpDMGvV2BGHeYERUEnRQAwe3N8SzbUtfsmvsqQLuvuJ
+- an IPFS multiaddr, to close the connection to that peer address:
+  ipfs swarm disconnect /ip4/104.131.131.82/tcp/4001/ipfs/QmaCpDMGvV2BGHeYERUEnRQAwe3N8SzbUtfsmvsqQLuvuJ
+- a peer ID, to close all the connections to that peer:
+  ipfs swarm disconnect QmaCpDMGvV2BGHeYERUEnRQAwe3N8SzbUtfsmvsqQLuvuJ
+- a CIDR range, to close all the connections to addresses in it:
+  ipfs swarm disconnect 104.131.0.0/16
 
 The disconnect is not permanent; if ipfs needs to talk to that address later,
-it will reconnect.
+it will reconnect. With --ban, the peer, or the range, is also denied any
+connection for the given duration, e.g. --ban=1h. Bans end when the daemon
+stops.
 `,
 	},
 	Arguments: []cmds.Argument{
-		cmds.StringArg("address", true, true, "Address of peer to disconnect from.").EnableStdin(),
+		cmds.StringArg("target", true, true, "Address, ID or CIDR range of the peers to disconnect from.").EnableStdin(),
+	},
+	Options: []cmds.Option{
+		cmds.StringOption("ban", "Deny reconnections for this duration, e.g. 10m."),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		n, err := req.InvocContext().GetNode()
@@ -385,51 +448,186 @@ it will reconnect.
 			return
 		}
 
-		addrs := req.Arguments()
-
 		if n.PeerHost == nil {
 			res.SetError(errNotOnline, cmds.ErrClient)
 			return
 		}
 
-		iaddrs, err := parseAddresses(addrs)
+		targets, err := parseDisconnectTargets(req.Arguments())
+		if err != nil {
+			res.SetError(err, cmds.ErrClient)
+			return
+		}
+
+		var banUntil time.Time
+		banS, found, err := req.Option("ban").String()
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
 		}
+		if found {
+			d, err := time.ParseDuration(banS)
+			if err != nil {
+				res.SetError(fmt.Errorf("invalid ban duration: %s", err), cmds.ErrClient)
+				return
+			}
+			if d <= 0 {
+				res.SetError(errors.New("ban duration must be positive"), cmds.ErrClient)
+				return
+			}
+			if n.Gater == nil {
+				res.SetError(errors.New("the node cannot ban peers"), cmds.ErrNormal)
+				return
+			}
+			banUntil = time.Now().Add(d)
+		}
 
-		output := make([]string, len(iaddrs))
-		for i, addr := range iaddrs {
-			taddr := addr.Transport()
-			output[i] = "disconnect " + addr.ID().Pretty()
+		out := make(chan interface{})
+		res.SetOutput((<-chan interface{})(out))
 
-			found := false
-			conns := n.PeerHost.Network().ConnsToPeer(addr.ID())
-			for _, conn := range conns {
-				if !conn.RemoteMultiaddr().Equal(taddr) {
-					log.Debug("it's not", conn.RemoteMultiaddr(), taddr)
-					continue
+		go func() {
+			defer close(out)
+			for _, t := range targets {
+				select {
+				case out <- t.disconnect(n, banUntil):
+				case <-req.Context().Done():
+					return
 				}
+			}
+		}()
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			outChan, ok := res.Output().(<-chan interface{})
+			if !ok {
+				return nil, u.ErrCast()
+			}
 
-				if err := conn.Close(); err != nil {
-					output[i] += " failure: " + err.Error()
-				} else {
-					output[i] += " success"
+			marshal := func(v interface{}) (io.Reader, error) {
+				r, ok := v.(*SwarmDisconnectResult)
+				if !ok {
+					return nil, u.ErrCast()
 				}
-				found = true
-				break
+
+				buf := new(bytes.Buffer)
+				name := r.Target
+				if r.Peer != "" {
+					name = r.Peer
+				}
+				if r.Error != "" {
+					fmt.Fprintf(buf, "disconnect %s failure: %s\n", name, r.Error)
+					return buf, nil
+				}
+				fmt.Fprintf(buf, "disconnect %s success", name)
+				if !strings.HasPrefix(r.Target, "/") {
+					fmt.Fprintf(buf, " (%d connections)", r.Closed)
+				}
+				if r.Banned != "" {
+					fmt.Fprintf(buf, ", banned until %s", r.Banned)
+				}
+				buf.WriteString("\n")
+				return buf, nil
 			}
 
-			if !found {
-				output[i] += " failure: conn not found"
+			return &cmds.ChannelMarshaler{
+				Channel:   outChan,
+				Marshaler: marshal,
+				Res:       res,
+			}, nil
+		},
+	},
+	Type: SwarmDisconnectResult{},
+}
+
+// disconnectTarget is what 'ipfs swarm disconnect' closes the connections
+// to: an address of a peer, all of its addresses, or a subnet.
+type disconnectTarget struct {
+	arg    string
+	addr   iaddr.IPFSAddr
+	peer   peer.ID
+	subnet *net.IPNet
+}
+
+func parseDisconnectTargets(args []string) ([]disconnectTarget, error) {
+	targets := make([]disconnectTarget, len(args))
+	for i, arg := range args {
+		t := disconnectTarget{arg: arg}
+		switch {
+		case strings.HasPrefix(arg, "/"):
+			a, err := iaddr.ParseString(arg)
+			if err != nil {
+				return nil, fmt.Errorf("invalid peer address: %s", err)
+			}
+			t.addr, t.peer = a, a.ID()
+		case strings.Contains(arg, "/"):
+			_, subnet, err := net.ParseCIDR(arg)
+			if err != nil {
+				return nil, fmt.Errorf("invalid CIDR range: %s", err)
+			}
+			t.subnet = subnet
+		default:
+			p, err := peer.IDB58Decode(arg)
+			if err != nil {
+				return nil, fmt.Errorf("invalid peer ID %q: %s", arg, err)
 			}
+			t.peer = p
 		}
-		res.SetOutput(&stringList{output})
-	},
-	Marshalers: cmds.MarshalerMap{
-		cmds.Text: stringListMarshaler,
-	},
-	Type: stringList{},
+		targets[i] = t
+	}
+	return targets, nil
+}
+
+// disconnect bans t until banUntil, if not zero, and closes its connections.
+func (t disconnectTarget) disconnect(n *core.IpfsNode, banUntil time.Time) *SwarmDisconnectResult {
+	r := &SwarmDisconnectResult{Target: t.arg}
+	if t.peer != "" {
+		r.Peer = t.peer.Pretty()
+	}
+
+	// ban first, so that the peers cannot reconnect in between
+	if !banUntil.IsZero() {
+		if t.subnet != nil {
+			n.Gater.BanNet(t.subnet, banUntil)
+		} else {
+			n.Gater.BanPeer(t.peer, banUntil)
+		}
+		r.Banned = banUntil.Format(time.RFC3339)
+	}
+
+	network := n.PeerHost.Network()
+	var conns []inet.Conn
+	if t.subnet != nil {
+		for _, c := range network.Conns() {
+			ip := gater.AddrIP(c.RemoteMultiaddr())
+			if ip != nil && t.subnet.Contains(ip) {
+				conns = append(conns, c)
+			}
+		}
+	} else {
+		for _, c := range network.ConnsToPeer(t.peer) {
+			if t.addr != nil && !c.RemoteMultiaddr().Equal(t.addr.Transport()) {
+				continue
+			}
+			conns = append(conns, c)
+		}
+	}
+
+	var errs []string
+	for _, c := range conns {
+		if err := c.Close(); err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		r.Closed++
+	}
+
+	switch {
+	case len(errs) > 0:
+		r.Error = strings.Join(errs, ", ")
+	case len(conns) == 0 && r.Banned == "":
+		r.Error = "conn not found"
+	}
+	return r
 }
 
 func stringListMarshaler(res cmds.Response) (io.Reader, error) {
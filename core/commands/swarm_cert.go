@@ -0,0 +1,149 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	peerauth "github.com/ipfs/go-ipfs/core/peerauth"
+	keystore "github.com/ipfs/go-ipfs/keystore"
+
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+type SwarmCertOutput struct {
+	Certificate string
+}
+
+type SwarmCAKeyOutput struct {
+	PublicKey string
+}
+
+var swarmCertCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Manage peer certificates.",
+		ShortDescription: `
+With Swarm.PeerAuth enabled, a node only talks to the peers on its allowlist,
+and to the ones presenting a certificate signed by one of its CA keys.
+
+'ipfs swarm cert pubkey' outputs the public key of a keystore key, for the
+Swarm.PeerAuth.CAKeys of the nodes trusting it. 'ipfs swarm cert issue'
+signs, with that key, the certificate of a peer, for the
+Swarm.PeerAuth.Certificate of that peer:
+
+  > ipfs key gen --type=ed25519 ca
+  > ipfs config --json Swarm.PeerAuth.CAKeys "[\"$(ipfs swarm cert pubkey --key=ca)\"]"
+  > ipfs swarm cert issue --key=ca <peer-id>
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"issue":  swarmCertIssueCmd,
+		"pubkey": swarmCertPubkeyCmd,
+	},
+}
+
+var swarmCertIssueCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Sign the certificate of a peer.",
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("peer", true, false, "Peer id to certify."),
+	},
+	Options: []cmds.Option{
+		cmds.StringOption("key", "k", "Name of the key signing the certificate.").Default("self"),
+		cmds.StringOption("lifetime", "Time the certificate is valid for.").Default("8760h"),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		p, err := peer.IDB58Decode(req.Arguments()[0])
+		if err != nil {
+			res.SetError(fmt.Errorf("invalid peer id: %s", err), cmds.ErrClient)
+			return
+		}
+
+		lifetime, _, _ := req.Option("lifetime").String()
+		d, err := time.ParseDuration(lifetime)
+		if err != nil {
+			res.SetError(fmt.Errorf("error parsing lifetime option: %s", err), cmds.ErrClient)
+			return
+		}
+
+		name, _, _ := req.Option("key").String()
+		ca, err := usableKey(n, name, keystore.UseSign)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		c, err := peerauth.IssueCertificate(ca, p, time.Now().Add(d))
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		enc, err := c.Encode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		res.SetOutput(&SwarmCertOutput{enc})
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out, ok := res.Output().(*SwarmCertOutput)
+			if !ok {
+				return nil, fmt.Errorf("expected a SwarmCertOutput as command result")
+			}
+			return strings.NewReader(out.Certificate + "\n"), nil
+		},
+	},
+	Type: SwarmCertOutput{},
+}
+
+var swarmCertPubkeyCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Output the public key of a key signing certificates.",
+	},
+	Options: []cmds.Option{
+		cmds.StringOption("key", "k", "Name of the key.").Default("self"),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		name, _, _ := req.Option("key").String()
+		ca, err := namedKey(n, name)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		enc, err := peerauth.EncodePublicKey(ca.GetPublic())
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		res.SetOutput(&SwarmCAKeyOutput{enc})
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out, ok := res.Output().(*SwarmCAKeyOutput)
+			if !ok {
+				return nil, fmt.Errorf("expected a SwarmCAKeyOutput as command result")
+			}
+			return strings.NewReader(out.PublicKey + "\n"), nil
+		},
+	},
+	Type: SwarmCAKeyOutput{},
+}
@@ -20,13 +20,15 @@ type LsLink struct {
 	Name, Hash string
 	Size       uint64
 	Type       string
+	Target     string `json:",omitempty"` // of symlinks
 }
 
 type LsObject struct {
-	Hash  string
-	Size  uint64
-	Type  string
-	Links []LsLink
+	Hash   string
+	Size   uint64
+	Type   string
+	Target string `json:",omitempty"` // of symlinks
+	Links  []LsLink
 }
 
 type LsOutput struct {
@@ -159,16 +161,19 @@ possible, please use 'ipfs ls' instead.
 						Hash: link.Cid.String(),
 						Type: t.String(),
 					}
-					if t == unixfspb.Data_File {
+					switch t {
+					case unixfspb.Data_File:
 						lsLink.Size = d.GetFilesize()
-					} else {
+					case unixfspb.Data_Symlink:
+						lsLink.Size = link.Size
+						lsLink.Target = string(d.GetData())
+					default:
 						lsLink.Size = link.Size
 					}
 					links[i] = lsLink
 				}
 			case unixfspb.Data_Symlink:
-				res.SetError(fmt.Errorf("cannot list symlinks yet"), cmds.ErrNormal)
-				return
+				output.Objects[hash].Target = string(unixFSNode.GetData())
 			default:
 				res.SetError(fmt.Errorf("unrecognized type: %s", t), cmds.ErrImplementation)
 				return
@@ -202,6 +207,11 @@ possible, please use 'ipfs ls' instead.
 			sort.Strings(directories)
 
 			for _, argument := range nonDirectories {
+				object := output.Objects[output.Arguments[argument]]
+				if object.Type == "Symlink" {
+					fmt.Fprintf(w, "%s -> %s\n", argument, object.Target)
+					continue
+				}
 				fmt.Fprintf(w, "%s\n", argument)
 			}
 
@@ -225,6 +235,10 @@ possible, please use 'ipfs ls' instead.
 					}
 				}
 				for _, link := range object.Links {
+					if link.Type == "Symlink" {
+						fmt.Fprintf(w, "%s -> %s\n", link.Name, link.Target)
+						continue
+					}
 					fmt.Fprintf(w, "%s\n", link.Name)
 				}
 			}
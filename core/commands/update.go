@@ -0,0 +1,382 @@
+package commands
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	core "github.com/ipfs/go-ipfs/core"
+	coreunix "github.com/ipfs/go-ipfs/core/coreunix"
+	config "github.com/ipfs/go-ipfs/repo/config"
+	fsrepo "github.com/ipfs/go-ipfs/repo/fsrepo"
+
+	ci "gx/ipfs/QmP1DfoUjiWH2ZBo1PBH6FupdBucbDepx3HpWmEY6JMUpY/go-libp2p-crypto"
+)
+
+const updateFetchTimeout = 10 * time.Minute
+
+// Suffixes of the binary being replaced and of the one being installed.
+const (
+	updateBackupSuffix = ".old"
+	updateNewSuffix    = ".new"
+)
+
+// UpdateCmd passes its arguments on to the separate ipfs-update tool, like
+// any external command, unless they start with one of its subcommands.
+var UpdateCmd = func() *cmds.Command {
+	cmd := ExternalBinary()
+	cmd.Subcommands = map[string]*cmds.Command{
+		"apply":    updateApplyCmd,
+		"rollback": updateRollbackCmd,
+	}
+	return cmd
+}()
+
+type UpdateOutput struct {
+	Version string
+	Path    string
+	Backup  string
+}
+
+var updateApplyCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Install a release of go-ipfs.",
+		ShortDescription: `
+'ipfs update apply' fetches the go-ipfs release <version> for this platform
+from the release source, verifies its signature, and atomically replaces
+the running binary, keeping the previous one for 'ipfs update rollback'.
+It checks that the new binary reports <version>, and rolls back otherwise.
+
+A release consists of the archive
+  <source>/go-ipfs/<version>/go-ipfs_<version>_<os>-<arch>.tar.gz
+and its signature, in the same path with a '.sig' suffix. The signature is
+the base64 encoded signature, by one of the maintainer keys built into
+go-ipfs, of the line
+  go-ipfs <version> <os>-<arch> <hex sha256 of the archive>
+so that an archive cannot be passed off as another version or platform.
+
+The daemon must be stopped, 'ipfs update apply' runs a node of its own to
+fetch the release. Start the daemon again to run the new version; repo
+migrations, if any, are applied on start.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("version", true, false, "Version to install, e.g. v0.4.10."),
+	},
+	Options: []cmds.Option{
+		cmds.StringOption("source", "IPFS path releases are published under.").Default(DefaultReleaseSource),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		keys, err := releaseKeys()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		version := req.Arguments()[0]
+		if !strings.HasPrefix(version, "v") {
+			version = "v" + version
+		}
+		if _, _, _, err := parseVersion(version); err != nil {
+			res.SetError(err, cmds.ErrClient)
+			return
+		}
+
+		source, _, _ := req.Option("source").String()
+
+		ctx, cancel := context.WithTimeout(req.Context(), updateFetchTimeout)
+		defer cancel()
+
+		// the command does not run on the daemon, and the node of local
+		// commands is offline, so fetch the release with a node of our own.
+		r, err := fsrepo.Open(req.InvocContext().ConfigRoot)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		n, err := core.NewNode(ctx, &core.BuildCfg{Online: true, Repo: r})
+		if err != nil {
+			r.Close()
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		defer n.Close()
+
+		path, err := currentBinary()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		if err := applyRelease(ctx, n, keys, source, version, path); err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		log.Infof("installed go-ipfs %s in %s, replacing %s", version, path, config.CurrentVersionNumber)
+		res.SetOutput(&UpdateOutput{
+			Version: version,
+			Path:    path,
+			Backup:  path + updateBackupSuffix,
+		})
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out, ok := res.Output().(*UpdateOutput)
+			if !ok {
+				return nil, fmt.Errorf("unexpected output type %T", res.Output())
+			}
+			return strings.NewReader(fmt.Sprintf("installed go-ipfs %s in %s, restart the daemon to use it\n", out.Version, out.Path)), nil
+		},
+	},
+	Type: UpdateOutput{},
+}
+
+var updateRollbackCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Restore the binary replaced by the last update.",
+		ShortDescription: `
+'ipfs update rollback' swaps the running binary with the one replaced by the
+last 'ipfs update apply'. Running it again undoes the rollback.
+`,
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		path, err := currentBinary()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		if _, err := os.Stat(path + updateBackupSuffix); err != nil {
+			res.SetError(fmt.Errorf("no previous binary to roll back to: %s", err), cmds.ErrNormal)
+			return
+		}
+
+		if err := swapBinaries(path); err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		res.SetOutput(&MessageOutput{fmt.Sprintf("restored the previous binary in %s\n", path)})
+	},
+	Type: MessageOutput{},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: MessageTextMarshaler,
+	},
+}
+
+func catAll(ctx context.Context, n *core.IpfsNode, p string) ([]byte, error) {
+	r, err := coreunix.Cat(ctx, n, p)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// applyRelease fetches the release version for this platform published
+// under source, verifies it was signed by one of keys, and installs its
+// binary at path, rolling back unless the binary installed runs as version.
+func applyRelease(ctx context.Context, n *core.IpfsNode, keys []ci.PubKey, source, version, path string) error {
+	platform := runtime.GOOS + "-" + runtime.GOARCH
+	archive := fmt.Sprintf("%s/go-ipfs/%s/go-ipfs_%s_%s.tar.gz",
+		strings.TrimRight(source, "/"), version, version, platform)
+
+	data, err := catAll(ctx, n, archive)
+	if err != nil {
+		return fmt.Errorf("failed to fetch %s: %s", archive, err)
+	}
+	sig, err := catAll(ctx, n, archive+".sig")
+	if err != nil {
+		return fmt.Errorf("failed to fetch the release signature: %s", err)
+	}
+
+	if err := verifyRelease(keys, version, platform, data, sig); err != nil {
+		return err
+	}
+
+	bin, err := releaseBinary(data)
+	if err != nil {
+		return err
+	}
+
+	if err := installBinary(path, bin); err != nil {
+		return err
+	}
+
+	// make sure the new binary runs and is the version asked for,
+	// rolling back otherwise.
+	if err := checkInstalledVersion(path, version); err != nil {
+		if rerr := swapBinaries(path); rerr != nil {
+			log.Errorf("failed to roll back %s: %s", path, rerr)
+		}
+		return fmt.Errorf("%s, rolled back", err)
+	}
+	return nil
+}
+
+// releaseKeys decodes the maintainer keys built into this binary.
+func releaseKeys() ([]ci.PubKey, error) {
+	var keys []ci.PubKey
+	for _, s := range config.ReleaseKeys {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+
+		b, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid release key %q: %s", s, err)
+		}
+		k, err := ci.UnmarshalPublicKey(b)
+		if err != nil {
+			return nil, fmt.Errorf("invalid release key %q: %s", s, err)
+		}
+		keys = append(keys, k)
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("this build of go-ipfs has no release keys, it cannot verify updates")
+	}
+	return keys, nil
+}
+
+// releaseSigned returns the statement the maintainers sign for the release
+// archive data of version for platform.
+func releaseSigned(version, platform string, data []byte) []byte {
+	return []byte(fmt.Sprintf("go-ipfs %s %s %x", version, platform, sha256.Sum256(data)))
+}
+
+// verifyRelease checks that sig, the base64 encoding of a signature of the
+// release archive data of version for platform, was made by one of keys.
+func verifyRelease(keys []ci.PubKey, version, platform string, data, sig []byte) error {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+	if err != nil {
+		return fmt.Errorf("invalid release signature: %s", err)
+	}
+
+	signed := releaseSigned(version, platform, data)
+	for _, k := range keys {
+		if ok, err := k.Verify(signed, raw); err == nil && ok {
+			return nil
+		}
+	}
+	return errors.New("release signature does not match any maintainer key, refusing to install")
+}
+
+// releaseBinary extracts the ipfs binary from a release archive.
+func releaseBinary(archive []byte) ([]byte, error) {
+	name := "go-ipfs/ipfs"
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, err
+	}
+
+	tr := tar.NewReader(gz)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("release archive has no %s", name)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if h.Name == name || h.Name == "./"+name {
+			return ioutil.ReadAll(tr)
+		}
+	}
+}
+
+// currentBinary returns the path of the running ipfs binary.
+func currentBinary() (string, error) {
+	path, err := exec.LookPath(os.Args[0])
+	if err != nil {
+		return "", fmt.Errorf("failed to locate the running binary: %s", err)
+	}
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(path)
+}
+
+// checkInstalledVersion runs the binary at path and checks that it is
+// version.
+func checkInstalledVersion(path, version string) error {
+	out, err := exec.Command(path, "version", "-n").Output()
+	if err != nil {
+		return fmt.Errorf("new binary failed to run: %s", err)
+	}
+	got := strings.TrimSpace(string(out))
+	if got != strings.TrimPrefix(version, "v") {
+		return fmt.Errorf("new binary is version %s, not %s", got, version)
+	}
+	return nil
+}
+
+// installBinary replaces the binary at path with bin, keeping the current
+// one for rollbacks. The current binary is hard linked to the backup and the
+// new one, written next to it, is renamed over it, so there is a binary at
+// path at all times.
+func installBinary(path string, bin []byte) error {
+	tmp := path + updateNewSuffix
+	if err := ioutil.WriteFile(tmp, bin, 0755); err != nil {
+		return fmt.Errorf("failed to write the new binary: %s", err)
+	}
+
+	if err := relink(path, path+updateBackupSuffix); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to back up the current binary: %s", err)
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to install the new binary: %s", err)
+	}
+	return nil
+}
+
+// swapBinaries exchanges the binary at path with its backup, without
+// removing the one at path at any point.
+func swapBinaries(path string) error {
+	backup := path + updateBackupSuffix
+	tmp := path + updateNewSuffix
+
+	if err := relink(backup, tmp); err != nil {
+		return err
+	}
+	if err := relink(path, backup); err != nil {
+		// the backup was unlinked, put it back
+		os.Rename(tmp, backup)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// relink makes newname a hard link of oldname, replacing any file at
+// newname.
+func relink(oldname, newname string) error {
+	if err := os.Remove(newname); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.Link(oldname, newname)
+}
@@ -0,0 +1,240 @@
+package commands
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	coreunix "github.com/ipfs/go-ipfs/core/coreunix"
+	coremock "github.com/ipfs/go-ipfs/core/mock"
+
+	ci "gx/ipfs/QmP1DfoUjiWH2ZBo1PBH6FupdBucbDepx3HpWmEY6JMUpY/go-libp2p-crypto"
+)
+
+func makeReleaseArchive(t *testing.T, name string, content []byte) []byte {
+	buf := new(bytes.Buffer)
+	gz := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gz)
+	err := tw.WriteHeader(&tar.Header{
+		Name: name,
+		Mode: 0755,
+		Size: int64(len(content)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReleaseBinary(t *testing.T) {
+	name := "go-ipfs/ipfs"
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+
+	bin, err := releaseBinary(makeReleaseArchive(t, name, []byte("new")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bin) != "new" {
+		t.Fatalf("extracted %q", bin)
+	}
+
+	if _, err := releaseBinary(makeReleaseArchive(t, "go-ipfs/README.md", []byte("doc"))); err == nil {
+		t.Fatal("expected an error for an archive without binary")
+	}
+}
+
+func TestVerifyRelease(t *testing.T) {
+	priv, pub, err := ci.GenerateKeyPair(ci.RSA, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := []ci.PubKey{pub}
+
+	data := []byte("release")
+	raw, err := priv.Sign(releaseSigned("v0.4.10", "linux-amd64", data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := []byte(base64.StdEncoding.EncodeToString(raw))
+
+	if err := verifyRelease(keys, "v0.4.10", "linux-amd64", data, sig); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyRelease(keys, "v0.4.9", "linux-amd64", data, sig); err == nil {
+		t.Fatal("signature accepted for another version")
+	}
+	if err := verifyRelease(keys, "v0.4.10", "darwin-amd64", data, sig); err == nil {
+		t.Fatal("signature accepted for another platform")
+	}
+	if err := verifyRelease(keys, "v0.4.10", "linux-amd64", []byte("other"), sig); err == nil {
+		t.Fatal("signature accepted for another archive")
+	}
+}
+
+func TestInstallAndRollback(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ipfs-update")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "ipfs")
+	if err := ioutil.WriteFile(path, []byte("old"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(cur, backup string) {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != cur {
+			t.Fatalf("expected binary %q, got %q", cur, b)
+		}
+		b, err = ioutil.ReadFile(path + updateBackupSuffix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != backup {
+			t.Fatalf("expected backup %q, got %q", backup, b)
+		}
+	}
+
+	if err := installBinary(path, []byte("new")); err != nil {
+		t.Fatal(err)
+	}
+	check("new", "old")
+
+	if err := swapBinaries(path); err != nil {
+		t.Fatal(err)
+	}
+	check("old", "new")
+
+	if err := installBinary(path, []byte("newer")); err != nil {
+		t.Fatal(err)
+	}
+	check("newer", "old")
+
+	if _, err := os.Stat(path + updateNewSuffix); !os.IsNotExist(err) {
+		t.Fatal("temporary binary left behind")
+	}
+}
+
+func TestApplyRelease(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the binary of the release fixture is a shell script")
+	}
+
+	n, err := coremock.NewMockNode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer n.Close()
+	ctx := context.Background()
+
+	// the release fixtures are signed by a test key, in place of the ones
+	// of the maintainers
+	priv, pub, err := ci.GenerateKeyPair(ci.RSA, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _, err := ci.GenerateKeyPair(ci.RSA, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := []ci.PubKey{pub}
+
+	platform := runtime.GOOS + "-" + runtime.GOARCH
+	publish := func(signer ci.PrivKey, version, reported string) string {
+		dir, err := ioutil.TempDir("", "ipfs-release")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		bin := fmt.Sprintf("#!/bin/sh\necho %s\n", reported)
+		data := makeReleaseArchive(t, "go-ipfs/ipfs", []byte(bin))
+		raw, err := signer.Sign(releaseSigned(version, platform, data))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rdir := filepath.Join(dir, "go-ipfs", version)
+		if err := os.MkdirAll(rdir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		archive := filepath.Join(rdir, fmt.Sprintf("go-ipfs_%s_%s.tar.gz", version, platform))
+		if err := ioutil.WriteFile(archive, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(archive+".sig", []byte(base64.StdEncoding.EncodeToString(raw)), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		key, err := coreunix.AddR(n, dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return "/ipfs/" + key
+	}
+
+	dir, err := ioutil.TempDir("", "ipfs-update")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "ipfs")
+	if err := ioutil.WriteFile(path, []byte("old"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	current := func() string {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+
+	// signed by a key which isn't a maintainer's
+	err = applyRelease(ctx, n, keys, publish(other, "v0.4.10", "0.4.10"), "v0.4.10", path)
+	if err == nil || current() != "old" {
+		t.Fatalf("expected a release signed by another key to be refused, got %v", err)
+	}
+
+	// the binary isn't the version of the release
+	err = applyRelease(ctx, n, keys, publish(priv, "v0.4.10", "0.4.9"), "v0.4.10", path)
+	if err == nil || !strings.Contains(err.Error(), "rolled back") || current() != "old" {
+		t.Fatalf("expected a release of another version to be rolled back, got %v", err)
+	}
+
+	if err := applyRelease(ctx, n, keys, publish(priv, "v0.4.10", "0.4.10"), "v0.4.10", path); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(current(), "echo 0.4.10") {
+		t.Fatalf("expected the release to be installed, got %q", current())
+	}
+	b, err := ioutil.ReadFile(path + updateBackupSuffix)
+	if err != nil || string(b) != "old" {
+		t.Fatalf("expected the previous binary to be kept, got %q: %v", b, err)
+	}
+}
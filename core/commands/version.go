@@ -25,6 +25,10 @@ var VersionCmd = &cmds.Command{
 		ShortDescription: "Returns the current version of ipfs and exits.",
 	},
 
+	Subcommands: map[string]*cmds.Command{
+		"check": versionCheckCmd,
+	},
+
 	Options: []cmds.Option{
 		cmds.BoolOption("number", "n", "Only show the version number.").Default(false),
 		cmds.BoolOption("commit", "Show the commit hash.").Default(false),
@@ -0,0 +1,269 @@
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	core "github.com/ipfs/go-ipfs/core"
+	coreunix "github.com/ipfs/go-ipfs/core/coreunix"
+	config "github.com/ipfs/go-ipfs/repo/config"
+)
+
+// DefaultReleaseSource is the IPNS path under which go-ipfs releases are
+// published. Its go-ipfs/versions file lists one released version per line,
+// and the optional go-ipfs/deprecations file lists one deprecated protocol
+// per line, followed by a tab and a notice.
+const DefaultReleaseSource = "/ipns/dist.ipfs.io"
+
+const versionCheckTimeout = time.Minute
+
+// Update channels of 'ipfs version check'.
+const (
+	ChannelStable = "stable"
+	ChannelRC     = "rc"
+)
+
+// securityProtocols are negotiated on every connection, outside of the
+// stream muxer of the host.
+var securityProtocols = []string{"/secio/1.0.0"}
+
+type VersionCheckOutput struct {
+	Current         string
+	Latest          string
+	Channel         string
+	UpdateAvailable bool
+	Deprecations    []string
+}
+
+var versionCheckCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Check for a newer release of go-ipfs.",
+		ShortDescription: `
+'ipfs version check' compares the running version with the latest release
+published under the release source, and reports the deprecated protocols
+this node still uses. It never updates go-ipfs.
+`,
+		LongDescription: `
+'ipfs version check' compares the running version with the latest release
+published under the release source, and reports the deprecated protocols
+this node still uses. It never updates go-ipfs. It runs on the daemon.
+
+The 'stable' channel only considers final releases, the 'rc' channel also
+considers release candidates.
+`,
+	},
+	Options: []cmds.Option{
+		cmds.StringOption("channel", "Update channel: stable or rc.").Default(ChannelStable),
+		cmds.StringOption("source", "IPFS path releases are published under.").Default(DefaultReleaseSource),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		// the releases are fetched from the network, and the deprecations are
+		// those of the protocols the daemon speaks
+		if !n.OnlineMode() {
+			res.SetError(errNotOnline, cmds.ErrClient)
+			return
+		}
+
+		channel, _, _ := req.Option("channel").String()
+		if channel != ChannelStable && channel != ChannelRC {
+			res.SetError(fmt.Errorf("unknown channel %q, must be one of {stable, rc}", channel), cmds.ErrClient)
+			return
+		}
+		source, _, _ := req.Option("source").String()
+		source = strings.TrimRight(source, "/")
+
+		ctx, cancel := context.WithTimeout(req.Context(), versionCheckTimeout)
+		defer cancel()
+
+		versions, err := readReleaseLines(ctx, n, source+"/go-ipfs/versions")
+		if err != nil {
+			res.SetError(fmt.Errorf("failed to fetch the release list: %s", err), cmds.ErrNormal)
+			return
+		}
+
+		out := &VersionCheckOutput{
+			Current: config.CurrentVersionNumber,
+			Channel: channel,
+		}
+
+		for _, v := range versions {
+			if _, _, rc, err := parseVersion(v); err != nil || (rc && channel == ChannelStable) {
+				continue
+			}
+			if out.Latest == "" || compareVersions(v, out.Latest) > 0 {
+				out.Latest = v
+			}
+		}
+		if out.Latest == "" {
+			res.SetError(errors.New("no release found on the channel"), cmds.ErrNormal)
+			return
+		}
+		out.UpdateAvailable = compareVersions(out.Latest, out.Current) > 0
+
+		// the deprecation list is optional, skip it if unavailable
+		deprecations, err := readReleaseLines(ctx, n, source+"/go-ipfs/deprecations")
+		if err != nil {
+			log.Debugf("version check: no deprecation list: %s", err)
+		}
+		out.Deprecations = usedDeprecations(n, deprecations)
+
+		res.SetOutput(out)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			v, ok := res.Output().(*VersionCheckOutput)
+			if !ok {
+				return nil, fmt.Errorf("unexpected output type %T", res.Output())
+			}
+
+			buf := new(bytes.Buffer)
+			if v.UpdateAvailable {
+				fmt.Fprintf(buf, "go-ipfs %s is available (running %s, %s channel)\n", v.Latest, v.Current, v.Channel)
+			} else {
+				fmt.Fprintf(buf, "go-ipfs %s is up to date (%s channel)\n", v.Current, v.Channel)
+			}
+			for _, d := range v.Deprecations {
+				fmt.Fprintf(buf, "deprecated: %s\n", d)
+			}
+			return buf, nil
+		},
+	},
+	Type: VersionCheckOutput{},
+}
+
+// readReleaseLines returns the non-empty, non-comment lines of the file at p.
+func readReleaseLines(ctx context.Context, n *core.IpfsNode, p string) ([]string, error) {
+	r, err := coreunix.Cat(ctx, n, p)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var lines []string
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, s.Err()
+}
+
+// usedDeprecations returns the notices of the deprecated protocols n speaks.
+// Each entry of list is a protocol id, a tab, and a notice.
+func usedDeprecations(n *core.IpfsNode, list []string) []string {
+	used := make(map[string]bool)
+	for _, p := range securityProtocols {
+		used[p] = true
+	}
+	if n.PeerHost != nil {
+		for _, p := range n.PeerHost.Mux().Protocols() {
+			used[p] = true
+		}
+	}
+
+	var out []string
+	for _, entry := range list {
+		parts := strings.SplitN(entry, "\t", 2)
+		proto := strings.TrimSpace(parts[0])
+		if !used[proto] {
+			continue
+		}
+
+		notice := proto + " is deprecated"
+		if len(parts) == 2 {
+			notice = proto + ": " + strings.TrimSpace(parts[1])
+		}
+		out = append(out, notice)
+	}
+	return out
+}
+
+// parseVersion parses versions such as v0.4.10, 0.4.10-rc1 or 0.4.10-dev,
+// returning the release numbers, the release candidate number, which is
+// MaxInt32 for final releases so that they sort after their candidates, and
+// whether the version is a pre-release.
+func parseVersion(v string) ([3]int, int, bool, error) {
+	var nums [3]int
+
+	v = strings.TrimPrefix(v, "v")
+	rcNum := int(^uint32(0) >> 1)
+	isRC := false
+	if i := strings.Index(v, "-"); i >= 0 {
+		suffix := v[i+1:]
+		v = v[:i]
+		switch {
+		case suffix == "dev":
+			// development builds precede all candidates of their release
+			rcNum, isRC = 0, true
+		case strings.HasPrefix(suffix, "rc"):
+			n, err := strconv.Atoi(strings.TrimPrefix(suffix, "rc"))
+			if err != nil {
+				return nums, 0, false, fmt.Errorf("invalid release candidate: %s", suffix)
+			}
+			rcNum, isRC = n, true
+		default:
+			return nums, 0, false, fmt.Errorf("unknown version suffix: %s", suffix)
+		}
+	}
+
+	parts := strings.Split(v, ".")
+	if len(parts) != 3 {
+		return nums, 0, false, fmt.Errorf("invalid version: %s", v)
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return nums, 0, false, fmt.Errorf("invalid version: %s", v)
+		}
+		nums[i] = n
+	}
+	return nums, rcNum, isRC, nil
+}
+
+// compareVersions returns -1, 0 or 1 if a is older, equal or newer than b.
+// Unparseable versions are older than any other.
+func compareVersions(a, b string) int {
+	an, arc, _, aerr := parseVersion(a)
+	bn, brc, _, berr := parseVersion(b)
+	switch {
+	case aerr != nil && berr != nil:
+		return 0
+	case aerr != nil:
+		return -1
+	case berr != nil:
+		return 1
+	}
+
+	for i := range an {
+		if an[i] != bn[i] {
+			if an[i] < bn[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case arc < brc:
+		return -1
+	case arc > brc:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,118 @@
+package commands
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	core "github.com/ipfs/go-ipfs/core"
+	coreunix "github.com/ipfs/go-ipfs/core/coreunix"
+	coremock "github.com/ipfs/go-ipfs/core/mock"
+)
+
+func TestCompareVersions(t *testing.T) {
+	cases := []struct {
+		a, b string
+		cmp  int
+	}{
+		{"v0.4.10", "0.4.9", 1},
+		{"v0.4.10", "v0.4.10", 0},
+		{"v0.4.10-rc1", "v0.4.10", -1},
+		{"v0.4.10-rc2", "v0.4.10-rc1", 1},
+		{"0.4.10-dev", "v0.4.10-rc1", -1},
+		{"0.4.10-dev", "v0.4.9", 1},
+		{"v1.0.0", "v0.10.0", 1},
+		{"garbage", "v0.1.0", -1},
+	}
+
+	for _, c := range cases {
+		if cmp := compareVersions(c.a, c.b); cmp != c.cmp {
+			t.Errorf("compareVersions(%s, %s) = %d, expected %d", c.a, c.b, cmp, c.cmp)
+		}
+	}
+}
+
+// runVersionCheck runs 'ipfs version check' on n, with the releases
+// published under source.
+func runVersionCheck(t *testing.T, n *core.IpfsNode, source string) cmds.Response {
+	optDefs, err := VersionCmd.GetOptions([]string{"check"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := cmds.NewRequest([]string{"version", "check"}, cmds.OptMap{"source": source}, nil, nil, versionCheckCmd, optDefs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetInvocContext(cmds.Context{
+		Online: n.OnlineMode(),
+		ConstructNode: func() (*core.IpfsNode, error) {
+			return n, nil
+		},
+	})
+	if err := req.SetRootContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	res := cmds.NewResponse(req)
+	versionCheckCmd.Run(req, res)
+	return res
+}
+
+func TestVersionCheckCmd(t *testing.T) {
+	n, err := coremock.NewMockNode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer n.Close()
+
+	dir, err := ioutil.TempDir("", "releases")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.Mkdir(filepath.Join(dir, "go-ipfs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"versions":     "# releases\nv0.1.0\nv99.0.0\nv100.0.0-rc1\n",
+		"deprecations": "/secio/1.0.0\tuse TLS\n/unused/1.0.0\n",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, "go-ipfs", name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	key, err := coreunix.AddR(n, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := runVersionCheck(t, n, "/ipfs/"+key)
+	if res.Error() != nil {
+		t.Fatal(res.Error())
+	}
+	out, ok := res.Output().(*VersionCheckOutput)
+	if !ok {
+		t.Fatalf("unexpected output type %T", res.Output())
+	}
+	if out.Latest != "v99.0.0" || !out.UpdateAvailable || out.Channel != ChannelStable {
+		t.Fatalf("expected v99.0.0 to be available on the stable channel, got %+v", out)
+	}
+	if len(out.Deprecations) != 1 || out.Deprecations[0] != "/secio/1.0.0: use TLS" {
+		t.Fatalf("expected secio alone to be deprecated, got %v", out.Deprecations)
+	}
+
+	// the releases are fetched from the network
+	offline, err := core.NewNode(context.Background(), &core.BuildCfg{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer offline.Close()
+	res = runVersionCheck(t, offline, "/ipfs/"+key)
+	if res.Error() == nil || res.Error().Message != errNotOnline.Error() {
+		t.Fatalf("expected the check to require online mode, got %v", res.Error())
+	}
+}
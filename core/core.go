@@ -16,6 +16,7 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math"
 	"net"
 	"os"
 	"strings"
@@ -23,12 +24,21 @@ import (
 
 	bstore "github.com/ipfs/go-ipfs/blocks/blockstore"
 	bserv "github.com/ipfs/go-ipfs/blockservice"
+	blockpush "github.com/ipfs/go-ipfs/core/blockpush"
+	bwprofile "github.com/ipfs/go-ipfs/core/bwprofile"
+	events "github.com/ipfs/go-ipfs/core/events"
+	gater "github.com/ipfs/go-ipfs/core/gater"
+	health "github.com/ipfs/go-ipfs/core/health"
+	peerauth "github.com/ipfs/go-ipfs/core/peerauth"
+	protodiag "github.com/ipfs/go-ipfs/core/protodiag"
+	pstoreds "github.com/ipfs/go-ipfs/core/pstoreds"
 	exchange "github.com/ipfs/go-ipfs/exchange"
 	bitswap "github.com/ipfs/go-ipfs/exchange/bitswap"
 	bsnet "github.com/ipfs/go-ipfs/exchange/bitswap/network"
 	rp "github.com/ipfs/go-ipfs/exchange/reprovide"
 	filestore "github.com/ipfs/go-ipfs/filestore"
 	mount "github.com/ipfs/go-ipfs/fuse/mount"
+	keystore "github.com/ipfs/go-ipfs/keystore"
 	merkledag "github.com/ipfs/go-ipfs/merkledag"
 	mfs "github.com/ipfs/go-ipfs/mfs"
 	namesys "github.com/ipfs/go-ipfs/namesys"
@@ -44,6 +54,7 @@ import (
 
 	routing "gx/ipfs/QmNdaQ8itUU9jEZUwTsG4gHMaPmRfi6FEe89QjQAFbep3M/go-libp2p-routing"
 	ic "gx/ipfs/QmP1DfoUjiWH2ZBo1PBH6FupdBucbDepx3HpWmEY6JMUpY/go-libp2p-crypto"
+	humanize "gx/ipfs/QmPSBJL4momYnE7DcUyk2DVhD6rH488ZmHBGLbxNdhU44K/go-humanize"
 	ipnet "gx/ipfs/QmPsBptED6X43GYg3347TAUruN3UfsAhaGTP9xbinYX7uf/go-libp2p-interface-pnet"
 	mplex "gx/ipfs/QmQ3UABWTgK78utKeiVXaH9BrjC7Ydn1pRuwqnWHT3p4zh/go-smux-multiplex"
 	discovery "gx/ipfs/QmQA5mdxru8Bh6dpC9PJfSkumqnmHgJX7knxSgBo5Lpime/go-libp2p/p2p/discovery"
@@ -62,7 +73,6 @@ import (
 	floodsub "gx/ipfs/QmUpeULWfmtsgCnfuRN3BHsfhHvBxNphoYh4La4CMxGt2Z/floodsub"
 	p2phost "gx/ipfs/QmUywuGNZoUKV8B9iyvup9bPkLiMrhTsyVMkeSXW5VxAfC/go-libp2p-host"
 	swarm "gx/ipfs/QmVkDnNm71vYyY6s6rXwtmyDYis3WkKyrEhMECwT6R12uJ/go-libp2p-swarm"
-	u "gx/ipfs/QmWbjfz3u6HkAdPh34dgPchGbQjob6LXLhAeCGii2TX69n/go-ipfs-util"
 	pstore "gx/ipfs/QmXZSd1qR5BxZkPyuwfT5jpqQFScZccoZvDneXsKzCNHWX/go-libp2p-peerstore"
 	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
 	addrutil "gx/ipfs/QmbH3urJHTrZSUETgvQRriWM6mMFqyNSwCqnhknxfSGVWv/go-addr-util"
@@ -102,10 +112,11 @@ type IpfsNode struct {
 	Repo repo.Repo
 
 	// Local node
-	Pinning        pin.Pinner // the pinning manager
-	Mounts         Mounts     // current mount state, if any.
-	PrivateKey     ic.PrivKey // the local node's private Key
-	PNetFingerpint []byte     // fingerprint of private network
+	Pinning        pin.Pinner      // the pinning manager
+	PinJobs        *pin.JobTracker // the pins being added
+	Mounts         Mounts          // current mount state, if any.
+	PrivateKey     ic.PrivKey      // the local node's private Key
+	PNetFingerpint []byte          // fingerprint of private network
 
 	// Services
 	Peerstore  pstore.Peerstore     // storage for other Peer instances
@@ -119,6 +130,9 @@ type IpfsNode struct {
 	Reporter   metrics.Reporter
 	Discovery  discovery.Service
 	FilesRoot  *mfs.Root
+	Accesses   *bstore.AccessBlockstore
+	Journal    *bstore.JournalBlockstore // the additions of blocks, if journaled
+	Events     *events.Bus               // lifecycle events of content
 
 	// Online
 	PeerHost     p2phost.Host        // the network host (server+client)
@@ -128,11 +142,21 @@ type IpfsNode struct {
 	Namesys      namesys.NameSystem  // the name system, resolves paths to hashes
 	Ping         *ping.PingService
 	Reprovider   *rp.Reprovider // the value reprovider system
+	Provides     *rp.Scheduler  // the priority lanes of the announcements
 	IpnsRepub    *ipnsrp.Republisher
 
 	Floodsub *floodsub.PubSub
 	PTP      *ptp.PTP
 
+	ProtocolFailures *protodiag.Recorder       // failed negotiations and handshakes
+	ProtocolLimits   *bwprofile.ProtocolLimits // caps on the streams of protocols
+	Health           *health.Service           // answers the health checks of peers
+	Gater            *gater.Gater              // decides which connections are allowed
+	BlockPush        *blockpush.Service        // stores the blocks pushed by trusted peers
+
+	dialPins    *dialPins
+	peerRecords *pstoreds.Store
+
 	proc goprocess.Process
 	ctx  context.Context
 
@@ -148,7 +172,7 @@ type Mounts struct {
 	Ipns mount.Mount
 }
 
-func (n *IpfsNode) startOnlineServices(ctx context.Context, routingOption RoutingOption, hostOption HostOption, do DiscoveryOption, pubsub, mplex bool) error {
+func (n *IpfsNode) startOnlineServices(ctx context.Context, routingOption RoutingOption, hostOption HostOption, do DiscoveryOption, pubsub, ipnsps, mplex bool) error {
 
 	if n.PeerHost != nil { // already online.
 		return errors.New("node already online")
@@ -178,7 +202,26 @@ func (n *IpfsNode) startOnlineServices(ctx context.Context, routingOption Routin
 		n.Reporter = metrics.NewBandwidthCounter()
 	}
 
-	tpt := makeSmuxTransport(mplex)
+	tpt, err := makeSmuxTransport(mplex, cfg.Memory)
+	if err != nil {
+		return err
+	}
+
+	// the peers are authorized before their connections are multiplexed
+	if cfg.Swarm.PeerAuth.Enabled {
+		auth, err := peerauth.New(cfg.Swarm.PeerAuth, n.Identity)
+		if err != nil {
+			return err
+		}
+		tpt = auth.Transport(tpt)
+	}
+
+	gate, err := gater.New(cfg.Swarm.Gater)
+	if err != nil {
+		return err
+	}
+	n.Gater = gate
+	tpt = gate.Transport(tpt)
 
 	swarmkey, err := n.Repo.SwarmKey()
 	if err != nil {
@@ -212,22 +255,80 @@ func (n *IpfsNode) startOnlineServices(ctx context.Context, routingOption Routin
 		}()
 	}
 
-	peerhost, err := hostOption(ctx, n.Identity, n.Peerstore, n.Reporter,
+	if !cfg.Swarm.Peerstore.DisablePersistence {
+		if err := n.loadPeerRecords(cfg.Swarm.Peerstore); err != nil {
+			return err
+		}
+	}
+
+	family, err := parseAddressFamily(cfg.Swarm.AddressFamily)
+	if err != nil {
+		return err
+	}
+
+	ps := gate.Peerstore(n.Peerstore)
+	if !cfg.Swarm.DisableDialRanking {
+		ps = newRankedPeerstore(ps, cfg.Swarm.DialTransports, family)
+	}
+	n.dialPins = newDialPins()
+	ps = n.dialPins.peerstore(ps)
+
+	peerhost, err := hostOption(ctx, n.Identity, ps, n.Reporter,
 		addrfilter, tpt, protec, &ConstructPeerHostOpts{DisableNatPortMap: cfg.Swarm.DisableNatPortMap})
 	if err != nil {
 		return err
 	}
 
-	if err := n.startOnlineServicesWithHost(ctx, peerhost, routingOption); err != nil {
+	// beneath the other wrappers, to see the streams of the network itself
+	if len(cfg.Swarm.ProtocolLimits) > 0 {
+		n.ProtocolLimits, err = bwprofile.NewProtocolLimits(cfg.Swarm.ProtocolLimits)
+		if err != nil {
+			return err
+		}
+		peerhost = n.ProtocolLimits.WrapHost(peerhost)
+		go n.ProtocolLimits.Run(ctx, peerhost)
+	}
+
+	announcer, err := newAnnouncer(cfg.Addresses, family)
+	if err != nil {
+		return err
+	}
+	if announcer.enabled() {
+		peerhost = wrapAnnounceHost(peerhost, announcer)
+	}
+
+	if len(cfg.Swarm.BandwidthProfiles) > 0 {
+		sched, err := bwprofile.New(cfg.Swarm.BandwidthProfiles)
+		if err != nil {
+			return err
+		}
+		peerhost = sched.WrapHost(peerhost)
+		go sched.Run(ctx, peerhost)
+	}
+
+	n.ProtocolFailures = protodiag.NewRecorder()
+	peerhost = protodiag.WrapHost(peerhost, n.ProtocolFailures)
+	peerhost.Network().Notify(n.ProtocolFailures.Notifiee(ctx, peerhost))
+
+	// IPNS over pubsub needs pubsub before the name system is set up
+	if pubsub || ipnsps {
+		n.Floodsub = floodsub.NewFloodSub(ctx, peerhost)
+	}
+
+	if err := n.startOnlineServicesWithHost(ctx, peerhost, routingOption, ipnsps); err != nil {
 		return err
 	}
 
+	if !cfg.Swarm.DisableHealthCheck {
+		n.Health = health.NewService(n.PeerHost, n.healthChecks())
+	}
+
 	// Ok, now we're ready to listen.
 	if err := startListening(ctx, n.PeerHost, cfg); err != nil {
 		return err
 	}
 
-	n.Reprovider = rp.NewReprovider(n.Routing, n.Blockstore)
+	n.Reprovider = rp.NewReprovider(n.Provides.Lane(rp.LaneReprovide), n.Blockstore)
 
 	if cfg.Reprovider.Interval != "0" {
 		interval := kReprovideFrequency
@@ -243,10 +344,6 @@ func (n *IpfsNode) startOnlineServices(ctx context.Context, routingOption Routin
 		go n.Reprovider.ProvideEvery(ctx, interval)
 	}
 
-	if pubsub {
-		n.Floodsub = floodsub.NewFloodSub(ctx, peerhost)
-	}
-
 	n.PTP = ptp.NewPTP(n.Identity, n.PeerHost, n.Peerstore)
 
 	// setup local discovery
@@ -263,7 +360,7 @@ func (n *IpfsNode) startOnlineServices(ctx context.Context, routingOption Routin
 	return n.Bootstrap(DefaultBootstrapConfig)
 }
 
-func makeSmuxTransport(mplexExp bool) smux.Transport {
+func makeSmuxTransport(mplexExp bool, mem config.Memory) (smux.Transport, error) {
 	mstpt := mssmux.NewBlankTransport()
 
 	ymxtpt := &yamux.Transport{
@@ -275,6 +372,21 @@ func makeSmuxTransport(mplexExp bool) smux.Transport {
 		LogOutput:              ioutil.Discard,
 	}
 
+	if mem.MuxerWindowSize != "" {
+		size, err := humanize.ParseBytes(mem.MuxerWindowSize)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Memory.MuxerWindowSize: %s", err)
+		}
+		// yamux requires at least its initial window of 256KiB
+		if size < 256<<10 || size > math.MaxUint32 {
+			return nil, fmt.Errorf("Memory.MuxerWindowSize must be between 256KiB and 4GiB")
+		}
+		ymxtpt.MaxStreamWindowSize = uint32(size)
+	}
+	if mem.MuxerAcceptBacklog > 0 {
+		ymxtpt.AcceptBacklog = mem.MuxerAcceptBacklog
+	}
+
 	if os.Getenv("YAMUX_DEBUG") != "" {
 		ymxtpt.LogOutput = os.Stderr
 	}
@@ -290,7 +402,7 @@ func makeSmuxTransport(mplexExp bool) smux.Transport {
 		mstpt.OrderPreference = strings.Fields(prefs)
 	}
 
-	return mstpt
+	return mstpt, nil
 }
 
 func setupDiscoveryOption(d config.Discovery) DiscoveryOption {
@@ -316,7 +428,7 @@ func (n *IpfsNode) HandlePeerFound(p pstore.PeerInfo) {
 
 // startOnlineServicesWithHost  is the set of services which need to be
 // initialized with the host and _before_ we start listening.
-func (n *IpfsNode) startOnlineServicesWithHost(ctx context.Context, host p2phost.Host, routingOption RoutingOption) error {
+func (n *IpfsNode) startOnlineServicesWithHost(ctx context.Context, host p2phost.Host, routingOption RoutingOption, ipnsps bool) error {
 	// setup diagnostics service
 	n.Ping = ping.NewPingService(host)
 
@@ -330,70 +442,152 @@ func (n *IpfsNode) startOnlineServicesWithHost(ctx context.Context, host p2phost
 	// Wrap standard peer host with routing system to allow unknown peer lookups
 	n.PeerHost = rhost.Wrap(host, n.Routing)
 
+	// the announcements of new blocks go before the reprovides
+	var limits rp.LaneLimits
+	if cfg, err := n.Repo.Config(); err == nil {
+		limits.Concurrency = cfg.Reprovider.Concurrency
+		limits.Rates[rp.LaneFresh] = cfg.Reprovider.ProvideRate
+		limits.Rates[rp.LaneReprovide] = cfg.Reprovider.ReprovideRate
+	}
+	n.Provides = rp.NewScheduler(n.Routing, limits)
+	go n.Provides.Run(ctx)
+
 	// setup exchange service
 	const alwaysSendToPeer = true // use YesManStrategy
-	bitswapNetwork := bsnet.NewFromIpfsHost(n.PeerHost, n.Routing)
-	n.Exchange = bitswap.New(ctx, n.Identity, bitswapNetwork, n.Blockstore, alwaysSendToPeer)
+	bitswapNetwork := bsnet.NewFromIpfsHost(n.PeerHost, n.Provides.Lane(rp.LaneFresh))
+	if cfg, err := n.Repo.Config(); err == nil {
+		bitswap.SetBufferSizes(cfg.Memory.BitswapHasBlockBuffer, cfg.Memory.BitswapProvideBuffer)
+	}
+	// the blocks fetched are journaled as coming from bitswap
+	var exchangeBlocks bstore.Blockstore = n.Blockstore
+	if n.Journal != nil {
+		exchangeBlocks = bstore.NewGCBlockstore(n.Journal.Source("bitswap"), n.GCLocker)
+	}
+	n.Exchange = bitswap.NewWithProvideQueue(ctx, n.Identity, bitswapNetwork, exchangeBlocks, alwaysSendToPeer, n.Repo.Datastore())
+	if bs, ok := n.Exchange.(*bitswap.Bitswap); ok {
+		bs.OnProvided(func(c *cid.Cid) {
+			n.Events.Publish(events.Provided, c)
+		})
+
+		cfg, err := n.Repo.Config()
+		if err != nil {
+			return err
+		}
+		if len(cfg.Routing.ProviderHints) > 0 {
+			hints, err := newProviderHints(cfg.Routing.ProviderHints, n.Peerstore)
+			if err != nil {
+				return err
+			}
+			bs.SetProviderHints(hints.providers)
+		}
+		if cfg.Datastore.VerifyOnReceive {
+			// the blockstore refuses the denied hashes already, the
+			// verification counts them
+			policy, err := bstore.NewHashPolicy(cfg.Datastore.DeniedHashes)
+			if err != nil {
+				return err
+			}
+			bs.VerifyReceived(policy.Check)
+		}
+	}
 
-	size, err := n.getCacheSize()
+	nsopts, err := n.getNamesysOptions()
 	if err != nil {
 		return err
 	}
+	if ipnsps {
+		nsopts.Pubsub = &namesys.PubsubOptions{
+			Context:        ctx,
+			PubSub:         n.Floodsub,
+			Host:           n.PeerHost,
+			ContentRouting: n.Routing,
+		}
+	}
 
 	// setup name system
-	n.Namesys = namesys.NewNameSystem(n.Routing, n.Repo.Datastore(), size)
+	n.Namesys = namesys.NewNameSystemWithOptions(n.Routing, n.Repo.Datastore(), nsopts)
 
 	// setup ipns republishing
 	return n.setupIpnsRepublisher()
 }
 
-// getCacheSize returns cache life and cache size
-func (n *IpfsNode) getCacheSize() (int, error) {
+// getNamesysOptions returns the name system settings from the config
+func (n *IpfsNode) getNamesysOptions() (namesys.Options, error) {
+	var opts namesys.Options
+
 	cfg, err := n.Repo.Config()
 	if err != nil {
-		return 0, err
+		return opts, err
 	}
 
-	cs := cfg.Ipns.ResolveCacheSize
-	if cs == 0 {
-		cs = 128
+	opts.Cache.Size = cfg.Ipns.ResolveCacheSize
+	if opts.Cache.Size == 0 {
+		opts.Cache.Size = 128
 	}
-	if cs < 0 {
-		return 0, fmt.Errorf("cannot specify negative resolve cache size")
+	if opts.Cache.Size < 0 {
+		return opts, fmt.Errorf("cannot specify negative resolve cache size")
 	}
-	return cs, nil
-}
 
-func (n *IpfsNode) setupIpnsRepublisher() error {
-	cfg, err := n.Repo.Config()
-	if err != nil {
-		return err
+	durations := []struct {
+		name string
+		val  string
+		dst  *time.Duration
+	}{
+		{"IPNS.ResolveCacheTTL", cfg.Ipns.ResolveCacheTTL, &opts.Cache.TTL},
+		{"IPNS.ResolveCacheStaleTTL", cfg.Ipns.ResolveCacheStaleTTL, &opts.Cache.StaleTTL},
+		{"IPNS.ResolveCacheNegativeTTL", cfg.Ipns.ResolveCacheNegativeTTL, &opts.Cache.NegativeTTL},
+		{"IPNS.ResolveTimeout", cfg.Ipns.ResolveTimeout, &opts.Timeout},
 	}
+	for _, d := range durations {
+		if d.val == "" {
+			continue
+		}
 
-	n.IpnsRepub = ipnsrp.NewRepublisher(n.Routing, n.Repo.Datastore(), n.PrivateKey, n.Repo.Keystore())
-
-	if cfg.Ipns.RepublishPeriod != "" {
-		d, err := time.ParseDuration(cfg.Ipns.RepublishPeriod)
+		dur, err := time.ParseDuration(d.val)
 		if err != nil {
-			return fmt.Errorf("failure to parse config setting IPNS.RepublishPeriod: %s", err)
+			return opts, fmt.Errorf("failure to parse config setting %s: %s", d.name, err)
 		}
-
-		if !u.Debug && (d < time.Minute || d > (time.Hour*24)) {
-			return fmt.Errorf("config setting IPNS.RepublishPeriod is not between 1min and 1day: %s", d)
+		if dur < 0 {
+			return opts, fmt.Errorf("config setting %s cannot be negative: %s", d.name, dur)
 		}
+		*d.dst = dur
+	}
 
-		n.IpnsRepub.Interval = d
+	opts.LookupTXT, err = namesys.NewLookupTXT(cfg.DNS.Resolvers)
+	if err != nil {
+		return opts, fmt.Errorf("failure to parse config setting DNS.Resolvers: %s", err)
 	}
 
-	if cfg.Ipns.RecordLifetime != "" {
-		d, err := time.ParseDuration(cfg.Ipns.RepublishPeriod)
-		if err != nil {
-			return fmt.Errorf("failure to parse config setting IPNS.RecordLifetime: %s", err)
-		}
+	// the policies are read at each publish, as 'ipfs key protect' may set
+	// them beside the daemon
+	opts.KeyPolicy = func(id peer.ID) (string, *keystore.Policy, error) {
+		return keystore.PolicyByID(n.Repo.Keystore(), id)
+	}
+
+	opts.KeepResolved = cfg.Ipns.RepublishResolved
+
+	opts.RecordCount = cfg.Ipns.ResolveRecordCount
+	if opts.RecordCount < 0 {
+		return opts, fmt.Errorf("config setting IPNS.ResolveRecordCount cannot be negative: %d", opts.RecordCount)
+	}
 
-		n.IpnsRepub.RecordLifetime = d
+	return opts, nil
+}
+
+func (n *IpfsNode) setupIpnsRepublisher() error {
+	cfg, err := n.Repo.Config()
+	if err != nil {
+		return err
 	}
 
+	n.IpnsRepub = ipnsrp.NewRepublisher(n.Routing, n.Repo.Datastore(), n.PrivateKey, n.Repo.Keystore())
+
+	n.IpnsRepub.Interval, n.IpnsRepub.RecordLifetime, err = ipnsrp.ParseConfig(cfg.Ipns)
+	if err != nil {
+		return err
+	}
+	n.IpnsRepub.Followed = cfg.Ipns.RepublishResolved
+
 	n.Process().Go(n.IpnsRepub.Run)
 
 	return nil
@@ -425,6 +619,8 @@ func (n *IpfsNode) teardown() error {
 	// regardless of which constructor was used to add them to the node.
 	var closers []io.Closer
 
+	n.drainProvides()
+
 	// NOTE: The order that objects are added(closed) matters, if an object
 	// needs to use another during its shutdown/cleanup process, it should be
 	// closed before that other object
@@ -444,7 +640,7 @@ func (n *IpfsNode) teardown() error {
 		closers = append(closers, mount.Closer(n.Mounts.Ipns))
 	}
 
-	if dht, ok := n.Routing.(*dht.IpfsDHT); ok {
+	if dht := n.DHT(); dht != nil {
 		closers = append(closers, dht.Process())
 	}
 
@@ -456,6 +652,12 @@ func (n *IpfsNode) teardown() error {
 		closers = append(closers, n.Bootstrapper)
 	}
 
+	// before the connections close, to record the addresses of the peers
+	// connected to
+	if n.peerRecords != nil {
+		closers = append(closers, n.peerRecords)
+	}
+
 	if n.PeerHost != nil {
 		closers = append(closers, n.PeerHost)
 	}
@@ -475,6 +677,33 @@ func (n *IpfsNode) teardown() error {
 	return nil
 }
 
+// drainProvides gives pending provide announcements up to the configured
+// Reprovider.DrainTimeout to be sent before the node shuts down. They are
+// otherwise announced on the next start.
+func (n *IpfsNode) drainProvides() {
+	bs, ok := n.Exchange.(*bitswap.Bitswap)
+	if !ok || n.Repo == nil {
+		return
+	}
+
+	cfg, err := n.Repo.Config()
+	if err != nil || cfg.Reprovider.DrainTimeout == "" {
+		return
+	}
+
+	timeout, err := time.ParseDuration(cfg.Reprovider.DrainTimeout)
+	if err != nil {
+		log.Warningf("invalid Reprovider.DrainTimeout: %s", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := bs.DrainProvides(ctx); err != nil {
+		log.Warningf("shutting down with pending provides: %s", err)
+	}
+}
+
 func (n *IpfsNode) OnlineMode() bool {
 	switch n.mode {
 	case onlineMode:
@@ -524,6 +753,10 @@ func (n *IpfsNode) Bootstrap(cfg BootstrapConfig) error {
 				log.Warning("failed to parse bootstrap peers from config")
 				return nil
 			}
+			// the peers known before the restart help as much
+			if n.peerRecords != nil {
+				ps = append(ps, n.peerRecords.Restored()...)
+			}
 			return ps
 		}
 	}
@@ -533,6 +766,26 @@ func (n *IpfsNode) Bootstrap(cfg BootstrapConfig) error {
 	return err
 }
 
+// loadPeerRecords restores the peers recorded in the datastore, and records
+// the peerstore from now on.
+func (n *IpfsNode) loadPeerRecords(cfg config.PeerstoreConfig) error {
+	maxTTL := pstoreds.DefaultMaxAddrTTL
+	if cfg.MaxAddrTTL != "" {
+		var err error
+		maxTTL, err = time.ParseDuration(cfg.MaxAddrTTL)
+		if err != nil {
+			return fmt.Errorf("invalid Swarm.Peerstore.MaxAddrTTL: %s", err)
+		}
+	}
+
+	n.peerRecords = pstoreds.New(n.Peerstore, n.Repo.Datastore(), n.Identity, maxTTL)
+	if err := n.peerRecords.Load(); err != nil {
+		log.Warningf("failed to restore the recorded peers: %s", err)
+	}
+	n.Peerstore = n.peerRecords
+	return nil
+}
+
 func (n *IpfsNode) loadID() error {
 	if n.Identity != "" {
 		return errors.New("identity already loaded")
@@ -601,8 +854,11 @@ func (n *IpfsNode) loadBootstrapPeers() ([]pstore.PeerInfo, error) {
 	return toPeerInfos(parsed), nil
 }
 
+// FilesRootKey is the datastore key of the CID of the MFS root.
+var FilesRootKey = ds.NewKey("/local/filesroot")
+
 func (n *IpfsNode) loadFilesRoot() error {
-	dsk := ds.NewKey("/local/filesroot")
+	dsk := FilesRootKey
 	pf := func(ctx context.Context, c *cid.Cid) error {
 		return n.Repo.Datastore().Put(dsk, c.Bytes())
 	}
@@ -642,6 +898,12 @@ func (n *IpfsNode) loadFilesRoot() error {
 	if err != nil {
 		return err
 	}
+	// resolutions of namespaces like /mfs may have changed
+	mr.OnChange = func(*cid.Cid) {
+		if n.Resolver != nil && n.Resolver.Cache != nil {
+			n.Resolver.Cache.Invalidate("")
+		}
+	}
 
 	n.FilesRoot = mr
 	return nil
@@ -662,12 +924,12 @@ func (n *IpfsNode) SetupOfflineRouting() error {
 
 	n.Routing = offroute.NewOfflineRouter(n.Repo.Datastore(), n.PrivateKey)
 
-	size, err := n.getCacheSize()
+	nsopts, err := n.getNamesysOptions()
 	if err != nil {
 		return err
 	}
 
-	n.Namesys = namesys.NewNameSystem(n.Routing, n.Repo.Datastore(), size)
+	n.Namesys = namesys.NewNameSystemWithOptions(n.Routing, n.Repo.Datastore(), nsopts)
 
 	return nil
 }
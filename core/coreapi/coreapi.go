@@ -24,6 +24,10 @@ func (api *CoreAPI) Unixfs() coreiface.UnixfsAPI {
 	return (*UnixfsAPI)(api)
 }
 
+func (api *CoreAPI) Dag() coreiface.DagAPI {
+	return (*DagAPI)(api)
+}
+
 func (api *CoreAPI) ResolveNode(ctx context.Context, p coreiface.Path) (coreiface.Node, error) {
 	p, err := api.ResolvePath(ctx, p)
 	if err != nil {
@@ -47,6 +51,12 @@ func (api *CoreAPI) ResolvePath(ctx context.Context, p coreiface.Path) (coreifac
 		DAG:         api.node.DAG,
 		ResolveOnce: uio.ResolveUnixfsOnce,
 	}
+	if api.node.Resolver != nil {
+		// resolutions do not depend on the ResolveOnce used when both
+		// succeed, so the cache can be shared
+		r.Cache = api.node.Resolver.Cache
+		r.Namespaces = api.node.Resolver.Namespaces
+	}
 
 	p2 := ipfspath.FromString(p.String())
 	node, err := core.Resolve(ctx, api.node.Namesys, r, p2)
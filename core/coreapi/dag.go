@@ -0,0 +1,110 @@
+package coreapi
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+
+	bserv "github.com/ipfs/go-ipfs/blockservice"
+	coreiface "github.com/ipfs/go-ipfs/core/coreapi/interface"
+	offline "github.com/ipfs/go-ipfs/exchange/offline"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+	node "gx/ipfs/Qmb3Hm9QDFmfYuET4pu7Kyg8JV78jFa1nvZx5vnCZsK4ck/go-ipld-format"
+)
+
+type DagAPI CoreAPI
+
+type nodeGetter interface {
+	Get(context.Context, *cid.Cid) (node.Node, error)
+}
+
+// Walk walks the dag at root with opts.Concurrency workers, which take the
+// nodes to visit from a shared stack: with a single worker, nodes are
+// visited depth first, in the order of their links. The root itself is
+// resolved the way the node resolves paths, whatever the source.
+func (api *DagAPI) Walk(ctx context.Context, root coreiface.Path, visit coreiface.WalkFunc, opts coreiface.WalkOptions) error {
+	var getter nodeGetter
+	switch opts.Source {
+	case coreiface.SourceNode:
+		getter = api.node.DAG
+	case coreiface.SourceLocal:
+		bs := api.node.Blockstore
+		getter = dag.NewDAGService(bserv.New(bs, offline.Exchange(bs)))
+	default:
+		return fmt.Errorf("unknown block source: %d", opts.Source)
+	}
+
+	rp, err := api.core().ResolvePath(ctx, root)
+	if err != nil {
+		return err
+	}
+
+	w := &dagWalker{
+		getter: getter,
+		visit:  visit,
+		opts:   opts,
+	}
+	if !opts.Revisit {
+		w.seen = cid.NewSet()
+		w.seen.Add(rp.Cid())
+	}
+
+	start := dag.WalkItem{Cid: rp.Cid(), Path: rp.String()}
+	return dag.WalkConcurrent(ctx, []dag.WalkItem{start}, opts.Concurrency, w.step)
+}
+
+func (api *DagAPI) core() coreiface.CoreAPI {
+	return (*CoreAPI)(api)
+}
+
+type dagWalker struct {
+	getter nodeGetter
+	visit  coreiface.WalkFunc
+	opts   coreiface.WalkOptions
+
+	// serializes the calls to visit
+	visitLk sync.Mutex
+
+	seenLk sync.Mutex
+	seen   *cid.Set
+}
+
+// step visits the node of it, and returns the links to walk next.
+func (w *dagWalker) step(ctx context.Context, it dag.WalkItem) ([]dag.WalkItem, error) {
+	nd, err := w.getter.Get(ctx, it.Cid)
+	if err == dag.ErrNotFound && w.opts.SkipMissing {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	w.visitLk.Lock()
+	descend, err := w.visit(it.Path, nd)
+	w.visitLk.Unlock()
+	if err != nil || !descend {
+		return nil, err
+	}
+
+	if w.opts.MaxDepth > 0 && it.Depth >= w.opts.MaxDepth {
+		return nil, nil
+	}
+
+	w.seenLk.Lock()
+	defer w.seenLk.Unlock()
+	var next []dag.WalkItem
+	for i, l := range nd.Links() {
+		if w.seen != nil && !w.seen.Visit(l.Cid) {
+			continue
+		}
+		name := l.Name
+		if name == "" {
+			name = strconv.Itoa(i)
+		}
+		next = append(next, dag.WalkItem{Cid: l.Cid, Path: it.Path + "/" + name, Depth: it.Depth + 1})
+	}
+	return next, nil
+}
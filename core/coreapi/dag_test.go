@@ -0,0 +1,140 @@
+package coreapi_test
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	core "github.com/ipfs/go-ipfs/core"
+	coreapi "github.com/ipfs/go-ipfs/core/coreapi"
+	coreiface "github.com/ipfs/go-ipfs/core/coreapi/interface"
+	mdag "github.com/ipfs/go-ipfs/merkledag"
+)
+
+// makeDag adds the dag root -> (a -> d, b -> (c, d)), and returns its root.
+func makeDag(t *testing.T, node *core.IpfsNode, missing bool) coreiface.Path {
+	add := func(data string, links map[string]*mdag.ProtoNode) *mdag.ProtoNode {
+		nd := mdag.NodeWithData([]byte(data))
+		for _, name := range []string{"a", "b", "c", "d"} {
+			if l, ok := links[name]; ok {
+				if err := nd.AddNodeLink(name, l); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		return nd
+	}
+
+	d := add("d", nil)
+	c := add("c", nil)
+	a := add("a", map[string]*mdag.ProtoNode{"d": d})
+	b := add("b", map[string]*mdag.ProtoNode{"c": c, "d": d})
+	root := add("root", map[string]*mdag.ProtoNode{"a": a, "b": b})
+
+	nds := []*mdag.ProtoNode{d, a, b, root}
+	if !missing {
+		nds = append(nds, c)
+	}
+	for _, nd := range nds {
+		if _, err := node.DAG.Add(nd); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return coreapi.ParseCid(root.Cid())
+}
+
+func walkPaths(t *testing.T, api coreiface.CoreAPI, root coreiface.Path, opts coreiface.WalkOptions) []string {
+	var paths []string
+	err := api.Dag().Walk(context.Background(), root, func(p string, nd coreiface.Node) (bool, error) {
+		paths = append(paths, p[len(root.String()):])
+		return true, nil
+	}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return paths
+}
+
+func TestDagWalk(t *testing.T) {
+	ctx := context.Background()
+	node, _, err := makeAPI(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := coreapi.NewCoreAPI(node)
+	root := makeDag(t, node, false)
+
+	paths := walkPaths(t, api, root, coreiface.WalkOptions{})
+	expected := []string{"", "/a", "/a/d", "/b", "/b/c"}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Fatalf("expected %v, got %v", expected, paths)
+	}
+
+	paths = walkPaths(t, api, root, coreiface.WalkOptions{Revisit: true})
+	expected = []string{"", "/a", "/a/d", "/b", "/b/c", "/b/d"}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Fatalf("expected %v, got %v", expected, paths)
+	}
+
+	paths = walkPaths(t, api, root, coreiface.WalkOptions{MaxDepth: 1})
+	expected = []string{"", "/a", "/b"}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Fatalf("expected %v, got %v", expected, paths)
+	}
+
+	paths = walkPaths(t, api, root, coreiface.WalkOptions{Concurrency: 4, Revisit: true})
+	sort.Strings(paths)
+	expected = []string{"", "/a", "/a/d", "/b", "/b/c", "/b/d"}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Fatalf("expected %v, got %v", expected, paths)
+	}
+}
+
+func TestDagWalkSkip(t *testing.T) {
+	ctx := context.Background()
+	node, _, err := makeAPI(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := coreapi.NewCoreAPI(node)
+	root := makeDag(t, node, false)
+
+	var paths []string
+	err = api.Dag().Walk(ctx, root, func(p string, nd coreiface.Node) (bool, error) {
+		paths = append(paths, p[len(root.String()):])
+		return p == root.String(), nil
+	}, coreiface.WalkOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"", "/a", "/b"}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Fatalf("expected %v, got %v", expected, paths)
+	}
+}
+
+func TestDagWalkMissing(t *testing.T) {
+	ctx := context.Background()
+	node, _, err := makeAPI(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := coreapi.NewCoreAPI(node)
+	root := makeDag(t, node, true)
+
+	opts := coreiface.WalkOptions{Source: coreiface.SourceLocal}
+	err = api.Dag().Walk(ctx, root, func(p string, nd coreiface.Node) (bool, error) {
+		return true, nil
+	}, opts)
+	if err != mdag.ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	opts.SkipMissing = true
+	paths := walkPaths(t, api, root, opts)
+	expected := []string{"", "/a", "/a/d", "/b"}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Fatalf("expected %v, got %v", expected, paths)
+	}
+}
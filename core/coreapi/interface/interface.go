@@ -28,6 +28,7 @@ type Reader interface {
 
 type CoreAPI interface {
 	Unixfs() UnixfsAPI
+	Dag() DagAPI
 	ResolvePath(context.Context, Path) (Path, error)
 	ResolveNode(context.Context, Path) (Node, error)
 }
@@ -38,6 +39,45 @@ type UnixfsAPI interface {
 	Ls(context.Context, Path) ([]*Link, error)
 }
 
+// WalkFunc is called by DagAPI.Walk for every node it visits, with the path
+// of link names leading to it from the root. Returning false skips the links
+// of the node, returning an error stops the walk with it. It is never called
+// concurrently, but with a Concurrency above 1 the order of the calls is not
+// defined.
+type WalkFunc func(path string, nd Node) (bool, error)
+
+// BlockSource selects where DagAPI.Walk gets the nodes it visits from.
+type BlockSource int
+
+const (
+	// SourceNode gets nodes the way the node does: from the local
+	// blockstore, or from the network when online.
+	SourceNode BlockSource = iota
+	// SourceLocal only gets nodes from the local blockstore.
+	SourceLocal
+)
+
+// WalkOptions configures DagAPI.Walk. The zero value walks every node once,
+// in order, getting nodes the way the node does.
+type WalkOptions struct {
+	// Concurrency is the number of nodes fetched at once.
+	Concurrency int
+	Source      BlockSource
+	// SkipMissing skips, instead of failing on, the nodes the source does
+	// not have.
+	SkipMissing bool
+	// MaxDepth stops the walk at nodes this many links away from the root,
+	// if positive.
+	MaxDepth int
+	// Revisit visits nodes linked several times once per link.
+	Revisit bool
+}
+
+type DagAPI interface {
+	// Walk visits the node at root and its descendants.
+	Walk(ctx context.Context, root Path, visit WalkFunc, opts WalkOptions) error
+}
+
 // type ObjectAPI interface {
 // 	New() (cid.Cid, Object)
 // 	Get(string) (Object, error)
@@ -0,0 +1,203 @@
+package corehttp
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	cmdsHttp "github.com/ipfs/go-ipfs/commands/http"
+	core "github.com/ipfs/go-ipfs/core"
+
+	prometheus "gx/ipfs/QmX3QZ5jHEPidwUrymXV1iSCSUhdGxj15sm2gP4jKMef7B/client_golang/prometheus"
+)
+
+var (
+	cmdRunningMetric = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "ipfs",
+		Subsystem: "http",
+		Name:      "command_running",
+		Help:      "Requests of the limited API commands running",
+	}, []string{"command"})
+	cmdQueuedMetric = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "ipfs",
+		Subsystem: "http",
+		Name:      "command_queued",
+		Help:      "Requests of the limited API commands waiting their turn",
+	}, []string{"command"})
+)
+
+func init() {
+	prometheus.MustRegister(cmdRunningMetric, cmdQueuedMetric)
+}
+
+// CommandLimitsOption caps the requests of the API commands run at once, as
+// set in API.CommandLimits. The requests over the limit of their command
+// wait their turn, until their client goes away.
+func CommandLimitsOption() ServeOption {
+	return func(n *core.IpfsNode, _ net.Listener, mux *http.ServeMux) (*http.ServeMux, error) {
+		cfg, err := n.Repo.Config()
+		if err != nil {
+			return nil, err
+		}
+
+		limits := make(map[string]*cmdLimit)
+		for path, max := range cfg.API.CommandLimits {
+			if max < 0 {
+				return nil, fmt.Errorf("API.CommandLimits: the limit of %s must not be negative, not %d", path, max)
+			}
+			if max > 0 {
+				path = strings.Trim(path, "/")
+				limits[path] = newCmdLimit(path, max)
+			}
+		}
+		if len(limits) == 0 {
+			return mux, nil
+		}
+
+		childMux := http.NewServeMux()
+		mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			l, ok := limits[requestCommand(r)]
+			if !ok {
+				childMux.ServeHTTP(w, r)
+				return
+			}
+			if err := l.acquire(r.Context(), requestCaller(r)); err != nil {
+				// the client went away while waiting its turn
+				return
+			}
+			defer l.release()
+			childMux.ServeHTTP(w, r)
+		})
+		return childMux, nil
+	}
+}
+
+// requestCommand returns the path of the API command of r, such as
+// "repo/gc", or "" if r isn't a command.
+func requestCommand(r *http.Request) string {
+	p := r.URL.Path
+	if !strings.HasPrefix(p, cmdsHttp.ApiPath+"/") {
+		return ""
+	}
+	return strings.Trim(strings.TrimPrefix(p, cmdsHttp.ApiPath), "/")
+}
+
+// requestCaller identifies the client of r for the limits: the host it
+// connects from, all its connections sharing its turn.
+func requestCaller(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// cmdLimit is the limit of a command. The requests over it wait in a queue
+// by caller, and the callers are served in turn, so that one sending many
+// requests only delays the others by its share.
+type cmdLimit struct {
+	lk      sync.Mutex
+	max     int
+	running int
+	// callers are the callers with requests waiting, in their order of turn
+	callers []string
+	waiting map[string][]chan struct{}
+
+	runningMetric prometheus.Gauge
+	queuedMetric  prometheus.Gauge
+}
+
+func newCmdLimit(path string, max int) *cmdLimit {
+	return &cmdLimit{
+		max:           max,
+		waiting:       make(map[string][]chan struct{}),
+		runningMetric: cmdRunningMetric.WithLabelValues(path),
+		queuedMetric:  cmdQueuedMetric.WithLabelValues(path),
+	}
+}
+
+// acquire waits for the turn of caller, and returns an error if ctx is done
+// first. The slot is freed by release.
+func (l *cmdLimit) acquire(ctx context.Context, caller string) error {
+	l.lk.Lock()
+	// requests only wait while the slots are all taken
+	if l.running < l.max {
+		l.running++
+		l.runningMetric.Inc()
+		l.lk.Unlock()
+		return nil
+	}
+	turn := make(chan struct{})
+	if len(l.waiting[caller]) == 0 {
+		l.callers = append(l.callers, caller)
+	}
+	l.waiting[caller] = append(l.waiting[caller], turn)
+	l.queuedMetric.Inc()
+	l.lk.Unlock()
+
+	select {
+	case <-turn:
+		return nil
+	case <-ctx.Done():
+	}
+
+	l.lk.Lock()
+	defer l.lk.Unlock()
+	select {
+	case <-turn:
+		// the turn came along with the cancellation, pass it on
+		l.next()
+		return ctx.Err()
+	default:
+	}
+	l.queuedMetric.Dec()
+	q := l.waiting[caller]
+	for i, t := range q {
+		if t == turn {
+			q = append(q[:i], q[i+1:]...)
+			break
+		}
+	}
+	if len(q) > 0 {
+		l.waiting[caller] = q
+		return ctx.Err()
+	}
+	delete(l.waiting, caller)
+	for i, c := range l.callers {
+		if c == caller {
+			l.callers = append(l.callers[:i], l.callers[i+1:]...)
+			break
+		}
+	}
+	return ctx.Err()
+}
+
+func (l *cmdLimit) release() {
+	l.lk.Lock()
+	defer l.lk.Unlock()
+	l.next()
+}
+
+// next hands the slot of a finished request to the first request of the
+// caller whose turn it is, or frees it.
+func (l *cmdLimit) next() {
+	if len(l.callers) == 0 {
+		l.running--
+		l.runningMetric.Dec()
+		return
+	}
+	caller := l.callers[0]
+	l.callers = l.callers[1:]
+	q := l.waiting[caller]
+	close(q[0])
+	l.queuedMetric.Dec()
+	if len(q) > 1 {
+		l.waiting[caller] = q[1:]
+		l.callers = append(l.callers, caller)
+	} else {
+		delete(l.waiting, caller)
+	}
+}
@@ -0,0 +1,67 @@
+package corehttp
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCmdLimitFairness(t *testing.T) {
+	l := newCmdLimit("test", 1)
+	ctx := context.Background()
+	if err := l.acquire(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	served := make(chan string, 4)
+	wait := func(caller string) {
+		go func() {
+			if err := l.acquire(ctx, caller); err != nil {
+				t.Error(err)
+				return
+			}
+			served <- caller
+		}()
+		// queue the requests in order
+		time.Sleep(time.Millisecond * 10)
+	}
+	wait("a")
+	wait("a")
+	wait("a")
+	wait("b")
+
+	var order []string
+	for i := 0; i < 4; i++ {
+		l.release()
+		select {
+		case c := <-served:
+			order = append(order, c)
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for a request to be served")
+		}
+	}
+	if order[0] != "a" || order[1] != "b" {
+		t.Fatalf("expected b to be served after the first request of a, got %v", order)
+	}
+}
+
+func TestCmdLimitCancel(t *testing.T) {
+	l := newCmdLimit("test", 1)
+	if err := l.acquire(context.Background(), "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+	if err := l.acquire(ctx, "b"); err != context.DeadlineExceeded {
+		t.Fatalf("expected the request to give up, got %v", err)
+	}
+	if len(l.callers) != 0 || len(l.waiting) != 0 {
+		t.Fatal("expected the request to be removed from the queue")
+	}
+
+	l.release()
+	if err := l.acquire(context.Background(), "b"); err != nil {
+		t.Fatal(err)
+	}
+}
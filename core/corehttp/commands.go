@@ -99,20 +99,30 @@ func patchCORSVars(c *cmdsHttp.ServerConfig, addr net.Addr) {
 	c.SetAllowedOrigins(origins...)
 }
 
+// apiServerConfig returns the CORS settings of the API served on l.
+func apiServerConfig(n *core.IpfsNode, l net.Listener) (*cmdsHttp.ServerConfig, error) {
+	cfg := cmdsHttp.NewServerConfig()
+	cfg.SetAllowedMethods("GET", "POST", "PUT")
+	rcfg, err := n.Repo.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	addHeadersFromConfig(cfg, rcfg)
+	addCORSFromEnv(cfg)
+	addCORSDefaults(cfg)
+	patchCORSVars(cfg, l.Addr())
+	return cfg, nil
+}
+
 func commandsOption(cctx commands.Context, command *commands.Command) ServeOption {
 	return func(n *core.IpfsNode, l net.Listener, mux *http.ServeMux) (*http.ServeMux, error) {
-
-		cfg := cmdsHttp.NewServerConfig()
-		cfg.SetAllowedMethods("GET", "POST", "PUT")
-		rcfg, err := n.Repo.Config()
+		cfg, err := apiServerConfig(n, l)
 		if err != nil {
 			return nil, err
 		}
 
-		addHeadersFromConfig(cfg, rcfg)
-		addCORSFromEnv(cfg)
-		addCORSDefaults(cfg)
-		patchCORSVars(cfg, l.Addr())
+		cfg.SetAllowedHeaders("Origin", "Accept", "Content-Type", IdempotencyKeyHeader)
 
 		cmdHandler := cmdsHttp.NewHandler(cctx, command, cfg)
 		mux.Handle(cmdsHttp.ApiPath+"/", cmdHandler)
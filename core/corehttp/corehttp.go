@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"strings"
 	"time"
 
 	core "github.com/ipfs/go-ipfs/core"
@@ -32,7 +33,7 @@ func makeHandler(n *core.IpfsNode, l net.Listener, options ...ServeOption) (http
 	mux := topMux
 	for _, option := range options {
 		var err error
-		mux, err = option(n, l, mux)
+		mux, err = applyOption(option, n, l, mux)
 		if err != nil {
 			return nil, err
 		}
@@ -40,6 +41,21 @@ func makeHandler(n *core.IpfsNode, l net.Listener, options ...ServeOption) (http
 	return topMux, nil
 }
 
+// applyOption runs option, turning the panic http.ServeMux raises when two
+// handlers are registered for the same path into an error.
+func applyOption(option ServeOption, n *core.IpfsNode, l net.Listener, mux *http.ServeMux) (out *http.ServeMux, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			msg := fmt.Sprint(r)
+			if !strings.Contains(msg, "multiple registrations") && !strings.Contains(msg, "conflicts with") {
+				panic(r)
+			}
+			err = fmt.Errorf("conflicting HTTP handlers: %s", msg)
+		}
+	}()
+	return option(n, l, mux)
+}
+
 // ListenAndServe runs an HTTP server listening at |listeningMultiAddr| with
 // the given serve options. The address must be provided in multiaddr format.
 //
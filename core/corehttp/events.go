@@ -0,0 +1,245 @@
+package corehttp
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	cmdsHttp "github.com/ipfs/go-ipfs/commands/http"
+	core "github.com/ipfs/go-ipfs/core"
+	events "github.com/ipfs/go-ipfs/core/events"
+)
+
+// websocketGUID is appended to the key of a WebSocket handshake (RFC 6455).
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// WebSocket opcodes
+const (
+	wsText  = 0x1
+	wsClose = 0x8
+	wsPing  = 0x9
+	wsPong  = 0xA
+)
+
+// EventsOption serves the lifecycle events of content at /events, as JSON
+// messages over a WebSocket, or as a stream of JSON lines to plain HTTP
+// clients. The 'types' query parameter restricts the types of events, as a
+// comma-separated list. As the API, it refuses the requests, WebSocket
+// upgrades included, from origins the API doesn't allow.
+func EventsOption() ServeOption {
+	return func(n *core.IpfsNode, l net.Listener, mux *http.ServeMux) (*http.ServeMux, error) {
+		cfg, err := apiServerConfig(n, l)
+		if err != nil {
+			return nil, err
+		}
+		cfg.SetAllowedMethods("GET")
+
+		mux.Handle("/events", cmdsHttp.Guard(cfg, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var types []string
+			if t := r.URL.Query().Get("types"); t != "" && t != "all" {
+				types = strings.Split(t, ",")
+			}
+			types, err := events.ParseTypes(types)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+				serveEventsWebSocket(n, w, r, types)
+				return
+			}
+
+			ctx, cancel := context.WithCancel(n.Context())
+			defer cancel()
+			if cn, ok := w.(http.CloseNotifier); ok {
+				go func() {
+					select {
+					case <-cn.CloseNotify():
+						cancel()
+					case <-ctx.Done():
+					}
+				}()
+			}
+
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.WriteHeader(http.StatusOK)
+			if f, ok := w.(http.Flusher); ok {
+				f.Flush()
+			}
+			// the notifier flushes each event to the client
+			wnf, _ := newWriteErrNotifier(w)
+			enc := json.NewEncoder(wnf)
+			for ev := range n.Events.Subscribe(ctx, types) {
+				if err := enc.Encode(ev); err != nil {
+					return
+				}
+			}
+		})))
+		return mux, nil
+	}
+}
+
+// serveEventsWebSocket upgrades the connection to a WebSocket, and pushes
+// the events to it until the client goes away. Messages from the client are
+// only read for close and ping frames.
+func serveEventsWebSocket(n *core.IpfsNode, w http.ResponseWriter, r *http.Request, types []string) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" || r.Method != "GET" {
+		http.Error(w, "invalid WebSocket handshake", http.StatusBadRequest)
+		return
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "WebSockets are not supported by this server", http.StatusInternalServerError)
+		return
+	}
+	conn, brw, err := hj.Hijack()
+	if err != nil {
+		log.Debugf("failed to upgrade to a WebSocket: %s", err)
+		return
+	}
+	defer conn.Close()
+
+	h := sha1.New()
+	io.WriteString(h, key+websocketGUID)
+	accept := base64.StdEncoding.EncodeToString(h.Sum(nil))
+
+	ws := &wsConn{bw: brw.Writer}
+	ws.mu.Lock()
+	brw.WriteString("HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + accept + "\r\n\r\n")
+	err = brw.Flush()
+	ws.mu.Unlock()
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(n.Context())
+	defer cancel()
+	go func() {
+		defer cancel()
+		ws.readLoop(brw.Reader)
+	}()
+
+	for ev := range n.Events.Subscribe(ctx, types) {
+		b, err := json.Marshal(ev)
+		if err != nil {
+			continue
+		}
+		if err := ws.writeFrame(wsText, b); err != nil {
+			return
+		}
+	}
+	ws.writeFrame(wsClose, nil)
+}
+
+type wsConn struct {
+	mu     sync.Mutex
+	bw     *bufio.Writer
+	closed bool // a close frame was sent, nothing may follow it
+}
+
+// writeFrame writes an unmasked, unfragmented frame, as servers do.
+func (ws *wsConn) writeFrame(opcode byte, payload []byte) error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if ws.closed {
+		return io.ErrClosedPipe
+	}
+	if opcode == wsClose {
+		ws.closed = true
+	}
+
+	hdr := []byte{0x80 | opcode}
+	switch l := len(payload); {
+	case l < 126:
+		hdr = append(hdr, byte(l))
+	case l <= 0xFFFF:
+		hdr = append(hdr, 126, 0, 0)
+		binary.BigEndian.PutUint16(hdr[2:], uint16(l))
+	default:
+		hdr = append(hdr, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(hdr[2:], uint64(l))
+	}
+	if _, err := ws.bw.Write(hdr); err != nil {
+		return err
+	}
+	if _, err := ws.bw.Write(payload); err != nil {
+		return err
+	}
+	return ws.bw.Flush()
+}
+
+// readLoop reads the frames of the client, answering pings, until it
+// closes the WebSocket or the connection fails.
+func (ws *wsConn) readLoop(r *bufio.Reader) {
+	var hdr [2]byte
+	for {
+		if _, err := io.ReadFull(r, hdr[:]); err != nil {
+			return
+		}
+		opcode := hdr[0] & 0x0F
+		masked := hdr[1]&0x80 != 0
+		length := uint64(hdr[1] & 0x7F)
+		switch length {
+		case 126:
+			var ext [2]byte
+			if _, err := io.ReadFull(r, ext[:]); err != nil {
+				return
+			}
+			length = uint64(binary.BigEndian.Uint16(ext[:]))
+		case 127:
+			var ext [8]byte
+			if _, err := io.ReadFull(r, ext[:]); err != nil {
+				return
+			}
+			length = binary.BigEndian.Uint64(ext[:])
+		}
+
+		var mask [4]byte
+		if masked {
+			if _, err := io.ReadFull(r, mask[:]); err != nil {
+				return
+			}
+		}
+
+		// control frames carry at most 125 bytes; data frames are ignored
+		if opcode != wsPing {
+			if _, err := io.CopyN(ioutil.Discard, r, int64(length)); err != nil {
+				return
+			}
+			if opcode == wsClose {
+				ws.writeFrame(wsClose, nil)
+				return
+			}
+			continue
+		}
+		if length > 125 {
+			return
+		}
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return
+		}
+		if masked {
+			for i := range payload {
+				payload[i] ^= mask[i%4]
+			}
+		}
+		if err := ws.writeFrame(wsPong, payload); err != nil {
+			return
+		}
+	}
+}
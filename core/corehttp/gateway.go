@@ -7,6 +7,7 @@ import (
 
 	core "github.com/ipfs/go-ipfs/core"
 	coreapi "github.com/ipfs/go-ipfs/core/coreapi"
+	path "github.com/ipfs/go-ipfs/path"
 	config "github.com/ipfs/go-ipfs/repo/config"
 	id "gx/ipfs/QmQA5mdxru8Bh6dpC9PJfSkumqnmHgJX7knxSgBo5Lpime/go-libp2p/p2p/protocol/identify"
 )
@@ -15,6 +16,9 @@ type GatewayConfig struct {
 	Headers      map[string][]string
 	Writable     bool
 	PathPrefixes []string
+	Previews     config.GatewayPreviews
+	MimeTypes    map[string]string
+	Limits       path.Limits
 }
 
 func GatewayOption(writable bool, paths ...string) ServeOption {
@@ -28,6 +32,13 @@ func GatewayOption(writable bool, paths ...string) ServeOption {
 			Headers:      cfg.Gateway.HTTPHeaders,
 			Writable:     writable,
 			PathPrefixes: cfg.Gateway.PathPrefixes,
+			Previews:     cfg.Gateway.Previews,
+			MimeTypes:    cfg.Gateway.MimeTypes,
+			Limits: path.Limits{
+				MaxSegments:         cfg.Gateway.MaxPathSegments,
+				MaxLinkDepth:        cfg.Gateway.MaxLinkDepth,
+				MaxDirectoryEntries: cfg.Gateway.MaxDirectoryEntries,
+			},
 		}, coreapi.NewCoreAPI(n))
 
 		for _, p := range paths {
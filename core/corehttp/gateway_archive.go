@@ -0,0 +1,71 @@
+package corehttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	tar "github.com/ipfs/go-ipfs/unixfs/archive/tar"
+	zip "github.com/ipfs/go-ipfs/unixfs/archive/zip"
+
+	node "gx/ipfs/Qmb3Hm9QDFmfYuET4pu7Kyg8JV78jFa1nvZx5vnCZsK4ck/go-ipld-format"
+)
+
+// archiveTypes are the content types of the archives of directories, by
+// their ?format=.
+var archiveTypes = map[string]string{
+	"tar": "application/x-tar",
+	"zip": "application/zip",
+}
+
+var errNotDir = errors.New("only directories can be downloaded as archives")
+
+// archiveWriter writes the nodes of a directory to an archive.
+type archiveWriter interface {
+	WriteNode(nd node.Node, fpath string) error
+	Close() error
+}
+
+// serveArchive streams the directory nd as an archive of format, its files
+// written as they are fetched.
+func (i *gatewayHandler) serveArchive(ctx context.Context, w http.ResponseWriter, r *http.Request, nd node.Node, name, format string) {
+	ctype, ok := archiveTypes[format]
+	if !ok {
+		webError(w, "unsupported archive format", fmt.Errorf("unknown format %q, expected tar or zip", format), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", ctype)
+	w.Header().Set("Content-Disposition", contentDisposition("attachment", name+"."+format))
+	if r.Method == "HEAD" {
+		return
+	}
+
+	if shedLoad(w, r) {
+		return
+	}
+
+	var aw archiveWriter
+	switch format {
+	case "tar":
+		tw, err := tar.NewWriter(ctx, i.node.DAG, true, 0, w)
+		if err != nil {
+			internalWebError(w, err)
+			return
+		}
+		aw = tw
+	case "zip":
+		aw = zip.NewWriter(ctx, i.node.DAG, w)
+	}
+
+	// the response is sent already once an error occurs: the archive is
+	// left unterminated, which clients tell from a complete one
+	if err := aw.WriteNode(nd, name); err != nil {
+		log.Warningf("writing the %s archive of %s: %s", format, r.URL.Path, err)
+		return
+	}
+	if err := aw.Close(); err != nil {
+		log.Warningf("writing the %s archive of %s: %s", format, r.URL.Path, err)
+	}
+}
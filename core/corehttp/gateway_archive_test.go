@@ -0,0 +1,95 @@
+package corehttp
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	coreunix "github.com/ipfs/go-ipfs/core/coreunix"
+)
+
+func TestGatewayArchive(t *testing.T) {
+	ts, n := newTestServerAndNode(t, mockNamesys{})
+	defer ts.Close()
+
+	k, _, err := coreunix.AddWrapped(n, strings.NewReader("fnord"), "file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(query string) []byte {
+		res, err := http.Get(ts.URL + "/ipfs/" + k + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d", query, res.StatusCode)
+		}
+		if !strings.HasPrefix(res.Header.Get("Content-Disposition"), "attachment") {
+			t.Fatalf("%s: expected an attachment, got %q", query, res.Header.Get("Content-Disposition"))
+		}
+		b, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+
+	tr := tar.NewReader(bytes.NewReader(get("?format=tar")))
+	files := map[string]string{}
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ioutil.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[h.Name] = string(b)
+	}
+	if files[k+"/file.txt"] != "fnord" {
+		t.Fatalf("expected the file in the tar archive, got %v", files)
+	}
+
+	b := get("?format=zip")
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files = map[string]string{}
+	for _, f := range zr.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ioutil.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[f.Name] = string(b)
+	}
+	if files[k+"/file.txt"] != "fnord" {
+		t.Fatalf("expected the file in the zip archive, got %v", files)
+	}
+
+	for _, query := range []string{"?format=rar", "/file.txt?format=zip"} {
+		res, err := http.Get(ts.URL + "/ipfs/" + k + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", query, res.StatusCode)
+		}
+	}
+}
@@ -15,6 +15,7 @@ import (
 	core "github.com/ipfs/go-ipfs/core"
 	coreapi "github.com/ipfs/go-ipfs/core/coreapi"
 	coreiface "github.com/ipfs/go-ipfs/core/coreapi/interface"
+	bitswap "github.com/ipfs/go-ipfs/exchange/bitswap"
 	"github.com/ipfs/go-ipfs/importer"
 	chunk "github.com/ipfs/go-ipfs/importer/chunk"
 	dag "github.com/ipfs/go-ipfs/merkledag"
@@ -35,19 +36,25 @@ const (
 	ipnsPathPrefix = "/ipns/"
 )
 
+// errListingTruncated stops the walk of the directories listed past
+// Gateway.MaxDirectoryEntries.
+var errListingTruncated = errors.New("directory listing truncated")
+
 // gatewayHandler is a HTTP handler that serves IPFS objects (accessible by default at /ipfs/<path>)
 // (it serves requests like GET /ipfs/QmVRzPKPzNtSrEzBFm2UZfxmPAgnaLke4DMcerbsGGSaFe/link)
 type gatewayHandler struct {
-	node   *core.IpfsNode
-	config GatewayConfig
-	api    coreiface.CoreAPI
+	node     *core.IpfsNode
+	config   GatewayConfig
+	api      coreiface.CoreAPI
+	previews *previewer // nil if previews are disabled
 }
 
 func newGatewayHandler(n *core.IpfsNode, c GatewayConfig, api coreiface.CoreAPI) *gatewayHandler {
 	i := &gatewayHandler{
-		node:   n,
-		config: c,
-		api:    api,
+		node:     n,
+		config:   c,
+		api:      api,
+		previews: newPreviewer(n, c.Previews),
 	}
 	return i
 }
@@ -66,6 +73,9 @@ func (i *gatewayHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(i.node.Context(), time.Hour)
 	// the hour is a hard fallback, we don't expect it to happen, but just in case
 	defer cancel()
+	ctx = bitswap.WithRequester(ctx, "gateway "+r.Method+" "+r.URL.Path)
+	// the paths of the requests are resolved within the limits of the config
+	ctx = path.WithLimits(ctx, i.config.Limits)
 
 	if cn, ok := w.(http.CloseNotifier); ok {
 		clientGone := cn.CloseNotify()
@@ -169,6 +179,10 @@ func (i *gatewayHandler) getOrHeadHandler(ctx context.Context, w http.ResponseWr
 
 	// Resolve path to the final DAG node for the ETag
 	resolvedPath, err := i.api.ResolvePath(ctx, parsedPath)
+	if _, ok := err.(*path.LimitError); ok {
+		webError(w, "ipfs resolve -r "+urlPath, err, http.StatusBadRequest)
+		return
+	}
 	switch err {
 	case nil:
 	case coreiface.ErrOffline:
@@ -195,8 +209,16 @@ func (i *gatewayHandler) getOrHeadHandler(ctx context.Context, w http.ResponseWr
 		return
 	}
 
+	preview := i.previews != nil && r.URL.Query().Get("preview") == "1"
+	format := r.URL.Query().Get("format")
+
 	// Check etag send back to us
 	etag := "\"" + resolvedPath.Cid().String() + "\""
+	if preview {
+		etag = "\"" + resolvedPath.Cid().String() + "-preview\""
+	} else if format != "" {
+		etag = "\"" + resolvedPath.Cid().String() + "-" + format + "\""
+	}
 	if r.Header.Get("If-None-Match") == etag {
 		w.WriteHeader(http.StatusNotModified)
 		return
@@ -250,15 +272,65 @@ func (i *gatewayHandler) getOrHeadHandler(ctx context.Context, w http.ResponseWr
 	// TODO: break this out when we split /ipfs /ipns routes.
 	modtime := time.Now()
 
-	if strings.HasPrefix(urlPath, ipfsPathPrefix) && !dir {
+	if strings.HasPrefix(urlPath, ipfsPathPrefix) && (!dir || format != "") {
 		w.Header().Set("Cache-Control", "public, max-age=29030400, immutable")
 
 		// set modtime to a really long time ago, since files are immutable and should stay cached
 		modtime = time.Unix(1, 0)
 	}
 
+	if preview {
+		if dir {
+			webError(w, "no preview for "+urlPath, errNoPreview, http.StatusNotFound)
+			return
+		}
+		i.previews.serve(ctx, w, r, modtime, resolvedPath.Cid(), gopath.Base(urlPath), dr)
+		return
+	}
+
+	// ?format=tar or ?format=zip downloads a directory as an archive
+	if format != "" {
+		if !dir {
+			webError(w, "no archive of "+urlPath, errNotDir, http.StatusBadRequest)
+			return
+		}
+
+		nd, err := i.api.ResolveNode(ctx, resolvedPath)
+		if err != nil {
+			internalWebError(w, err)
+			return
+		}
+		name := gopath.Base(urlPath)
+		if name == "" || name == "/" || name == "." {
+			name = resolvedPath.Cid().String()
+		}
+		i.serveArchive(ctx, w, r, nd, name, format)
+		return
+	}
+
 	if !dir {
 		name := gopath.Base(urlPath)
+
+		// ?filename= names the file for browsers, and ?download=true
+		// makes them save it instead of showing it.
+		query := r.URL.Query()
+		filename := query.Get("filename")
+		if filename != "" {
+			name = filename
+		}
+		if query.Get("download") == "true" {
+			w.Header().Set("Content-Disposition", contentDisposition("attachment", name))
+		} else if filename != "" {
+			w.Header().Set("Content-Disposition", contentDisposition("inline", name))
+		}
+
+		ctype, err := i.contentType(name, dr)
+		if err != nil {
+			internalWebError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", ctype)
+
 		http.ServeContent(w, r, name, modtime, dr)
 		return
 	}
@@ -309,11 +381,25 @@ func (i *gatewayHandler) getOrHeadHandler(ctx context.Context, w http.ResponseWr
 		return
 	}
 
+	if shedLoad(w, r) {
+		return
+	}
+
 	// storage for directory listing
 	var dirListing []directoryItem
+	maxEntries := i.config.Limits.MaxDirectoryEntries
+	truncated := false
 	dirr.ForEachLink(ctx, func(link *node.Link) error {
+		if maxEntries > 0 && len(dirListing) >= maxEntries {
+			// the rest of the directory is not walked
+			truncated = true
+			return errListingTruncated
+		}
 		// See comment above where originalUrlPath is declared.
-		di := directoryItem{humanize.Bytes(link.Size), link.Name, gopath.Join(originalUrlPath, link.Name)}
+		di := directoryItem{humanize.Bytes(link.Size), link.Name, gopath.Join(originalUrlPath, link.Name), false}
+		if i.previews != nil {
+			di.Preview = i.previews.previewable(link.Name)
+		}
 		dirListing = append(dirListing, di)
 		return nil
 	})
@@ -352,9 +438,10 @@ func (i *gatewayHandler) getOrHeadHandler(ctx context.Context, w http.ResponseWr
 
 	// See comment above where originalUrlPath is declared.
 	tplData := listingTemplateData{
-		Listing:  dirListing,
-		Path:     originalUrlPath,
-		BackLink: backLink,
+		Listing:   dirListing,
+		Path:      originalUrlPath,
+		BackLink:  backLink,
+		Truncated: truncated,
 	}
 	err = listingTemplate.Execute(w, tplData)
 	if err != nil {
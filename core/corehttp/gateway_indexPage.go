@@ -14,14 +14,28 @@ type listingTemplateData struct {
 	Listing  []directoryItem
 	Path     string
 	BackLink string
+	// Truncated tells whether entries were left out of Listing, past
+	// Gateway.MaxDirectoryEntries.
+	Truncated bool
 }
 
 type directoryItem struct {
-	Size string
-	Name string
-	Path string
+	Size    string
+	Name    string
+	Path    string
+	Preview bool // whether the gateway serves a thumbnail of the item
 }
 
+// listingIcon is the icon of items in the directory listing template, shown
+// in place of thumbnails when items have one.
+const listingIcon = `<div class="ipfs-icon {{iconFromExt .Name}}">&nbsp;</div>`
+
+const listingPreview = `{{if .Preview}}<img src="{{ .Path | urlEscape }}?preview=1" alt="" style="max-width:64px;max-height:64px">{{else}}` + listingIcon + `{{end}}`
+
+// listingEnd closes the table of the listing, noting when it is truncated.
+const listingEnd = `{{if .Truncated}}<tr><td></td><td>More entries are not listed.</td><td></td></tr>{{end}}
+      </table>`
+
 var listingTemplate *template.Template
 
 func init() {
@@ -60,5 +74,5 @@ func init() {
 	listingTemplate = template.Must(template.New("dir").Funcs(template.FuncMap{
 		"iconFromExt": iconFromExt,
 		"urlEscape":   urlEscape,
-	}).Parse(string(dirIndexBytes)))
+	}).Parse(strings.Replace(strings.Replace(string(dirIndexBytes), listingIcon, listingPreview, 1), "</table>", listingEnd, 1)))
 }
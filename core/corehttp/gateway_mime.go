@@ -0,0 +1,161 @@
+package corehttp
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	gopath "path"
+	"strings"
+)
+
+// sniffLen is the number of bytes looked at to guess the type of files.
+const sniffLen = 512
+
+// knownTypes maps the extensions missing from, or inconsistent across, the
+// mime tables of the systems the gateway runs on.
+var knownTypes = map[string]string{
+	".css":   "text/css; charset=utf-8",
+	".csv":   "text/csv; charset=utf-8",
+	".flac":  "audio/flac",
+	".gz":    "application/gzip",
+	".ico":   "image/x-icon",
+	".js":    "application/javascript",
+	".json":  "application/json",
+	".m4a":   "audio/mp4",
+	".md":    "text/markdown; charset=utf-8",
+	".mkv":   "video/x-matroska",
+	".mp3":   "audio/mpeg",
+	".mp4":   "video/mp4",
+	".oga":   "audio/ogg",
+	".ogg":   "audio/ogg",
+	".ogv":   "video/ogg",
+	".opus":  "audio/ogg",
+	".svg":   "image/svg+xml",
+	".tar":   "application/x-tar",
+	".txt":   "text/plain; charset=utf-8",
+	".wasm":  "application/wasm",
+	".wav":   "audio/wav",
+	".webm":  "video/webm",
+	".webp":  "image/webp",
+	".woff":  "font/woff",
+	".woff2": "font/woff2",
+}
+
+// magic is a signature found at offset in files of type typ.
+type magic struct {
+	offset int
+	sig    []byte
+	typ    string
+}
+
+// magics lists the signatures of the types http.DetectContentType does not
+// recognize.
+var magics = []magic{
+	{4, []byte("ftypqt"), "video/quicktime"},
+	{4, []byte("ftypM4A"), "audio/mp4"},
+	{4, []byte("ftyp"), "video/mp4"},
+	{0, []byte("\x1a\x45\xdf\xa3"), "video/webm"},
+	{0, []byte("fLaC"), "audio/flac"},
+	{0, []byte("ID3"), "audio/mpeg"},
+	{0, []byte("OggS"), "audio/ogg"},
+	{0, []byte("\x00asm"), "application/wasm"},
+	{0, []byte("7z\xbc\xaf\x27\x1c"), "application/x-7z-compressed"},
+	{0, []byte("\x28\xb5\x2f\xfd"), "application/zstd"},
+	{0, []byte("\xfd7zXZ\x00"), "application/x-xz"},
+	{0, []byte("BZh"), "application/x-bzip2"},
+	{257, []byte("ustar"), "application/x-tar"},
+}
+
+// sniffType guesses the type of the content starting with head.
+func sniffType(head []byte) string {
+	for _, m := range magics {
+		if len(head) >= m.offset+len(m.sig) && bytes.Equal(head[m.offset:m.offset+len(m.sig)], m.sig) {
+			return m.typ
+		}
+	}
+
+	typ := http.DetectContentType(head)
+	if strings.HasPrefix(typ, "text/xml") && bytes.Contains(head, []byte("<svg")) {
+		return "image/svg+xml"
+	}
+	return typ
+}
+
+// contentType returns the type of the file called name: the one configured
+// for its extension if any, the one known for it, or else the one guessed
+// from its first bytes. content is left at its start.
+func (i *gatewayHandler) contentType(name string, content io.ReadSeeker) (string, error) {
+	ext := strings.ToLower(gopath.Ext(name))
+	if ext != "" {
+		if typ, ok := i.config.MimeTypes[ext]; ok {
+			return typ, nil
+		}
+		if typ, ok := knownTypes[ext]; ok {
+			return typ, nil
+		}
+		if typ := mime.TypeByExtension(ext); typ != "" {
+			return typ, nil
+		}
+	}
+
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(content, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return sniffType(head[:n]), nil
+}
+
+// contentDisposition returns the Content-Disposition header value of the
+// given disposition type for filename, with an ASCII fallback and the
+// RFC 5987 encoding of the name when it needs one.
+func contentDisposition(disposition, filename string) string {
+	// names are not paths, and quotes would end the value early
+	filename = strings.Map(func(r rune) rune {
+		switch {
+		case r == '/' || r == '\\':
+			return '_'
+		case r < ' ' || r == 0x7f:
+			return -1
+		default:
+			return r
+		}
+	}, filename)
+
+	fallback := strings.Map(func(r rune) rune {
+		if r > 0x7e || r == '"' {
+			return '_'
+		}
+		return r
+	}, filename)
+
+	v := fmt.Sprintf("%s; filename=\"%s\"", disposition, fallback)
+	if fallback != filename {
+		v += "; filename*=UTF-8''" + encodeExtValue(filename)
+	}
+	return v
+}
+
+// encodeExtValue percent-encodes s as the value of an RFC 5987 extended
+// parameter.
+func encodeExtValue(s string) string {
+	const attrChars = "!#$&+-.^_`|~"
+
+	var buf bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9',
+			strings.IndexByte(attrChars, c) >= 0:
+			buf.WriteByte(c)
+		default:
+			fmt.Fprintf(&buf, "%%%02X", c)
+		}
+	}
+	return buf.String()
+}
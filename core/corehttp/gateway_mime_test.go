@@ -0,0 +1,71 @@
+package corehttp
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSniffType(t *testing.T) {
+	tar := make([]byte, 512)
+	copy(tar[257:], "ustar")
+
+	for _, test := range []struct {
+		head []byte
+		typ  string
+	}{
+		{[]byte("\x00\x00\x00\x18ftypmp42"), "video/mp4"},
+		{[]byte("\x1a\x45\xdf\xa3\x01"), "video/webm"},
+		{[]byte("ID3\x03"), "audio/mpeg"},
+		{tar, "application/x-tar"},
+		{[]byte("<?xml version=\"1.0\"?>\n<svg xmlns=\"http://www.w3.org/2000/svg\"/>"), "image/svg+xml"},
+		{[]byte("\x89PNG\x0d\x0a\x1a\x0a"), "image/png"},
+		{[]byte("hello"), "text/plain; charset=utf-8"},
+	} {
+		if typ := sniffType(test.head); typ != test.typ {
+			t.Errorf("expected %s for %q, got %s", test.typ, test.head, typ)
+		}
+	}
+}
+
+func TestContentType(t *testing.T) {
+	i := &gatewayHandler{config: GatewayConfig{
+		MimeTypes: map[string]string{".md": "text/plain"},
+	}}
+
+	for _, test := range []struct {
+		name string
+		typ  string
+	}{
+		{"README.MD", "text/plain"},
+		{"clip.webm", "video/webm"},
+		{"Qmfoo", "audio/flac"},
+	} {
+		r := bytes.NewReader([]byte("fLaC\x00\x00\x00\x22"))
+		typ, err := i.contentType(test.name, r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if typ != test.typ {
+			t.Errorf("expected %s for %s, got %s", test.typ, test.name, typ)
+		}
+		if r.Len() != 8 {
+			t.Errorf("content of %s was not rewound", test.name)
+		}
+	}
+}
+
+func TestContentDisposition(t *testing.T) {
+	for _, test := range []struct {
+		disposition string
+		name        string
+		header      string
+	}{
+		{"attachment", "report.pdf", `attachment; filename="report.pdf"`},
+		{"inline", `a "b"/c`, `inline; filename="a _b__c"; filename*=UTF-8''a%20%22b%22_c`},
+		{"attachment", "été.txt", `attachment; filename="_t_.txt"; filename*=UTF-8''%C3%A9t%C3%A9.txt`},
+	} {
+		if h := contentDisposition(test.disposition, test.name); h != test.header {
+			t.Errorf("expected %s, got %s", test.header, h)
+		}
+	}
+}
@@ -0,0 +1,319 @@
+package corehttp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif" // registers the decoders used by image.Decode
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"os/exec"
+	gopath "path"
+	"strings"
+	"time"
+
+	core "github.com/ipfs/go-ipfs/core"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	config "github.com/ipfs/go-ipfs/repo/config"
+
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+)
+
+const (
+	defaultPreviewSize          = 128
+	defaultPreviewMaxSourceSize = 32 << 20
+
+	// previewMaxPixels bounds the images decoded, so that a small file
+	// can't make the gateway allocate gigabytes.
+	previewMaxPixels = 64 << 20
+
+	// previewConcurrency bounds the thumbnails generated at once.
+	previewConcurrency = 4
+
+	previewQuality = 80
+)
+
+// previewPrefix is the datastore namespace mapping the cid of files to the
+// cid of their thumbnail.
+var previewPrefix = ds.NewKey("/local/previews")
+
+var (
+	errNoPreview    = errors.New("file type not supported")
+	errPreviewLarge = errors.New("file too large")
+)
+
+// previewer generates thumbnails of images and videos, and caches them in
+// the repo keyed by the cid of the file they show. Thumbnails are not
+// pinned: the next garbage collection drops them, and they are generated
+// again when asked for.
+type previewer struct {
+	node          *core.IpfsNode
+	size          int
+	maxSourceSize int64
+	ffmpeg        string
+	sem           chan struct{}
+}
+
+// newPreviewer returns nil if previews are disabled.
+func newPreviewer(n *core.IpfsNode, cfg config.GatewayPreviews) *previewer {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	p := &previewer{
+		node:          n,
+		size:          cfg.Size,
+		maxSourceSize: cfg.MaxSourceSize,
+		ffmpeg:        cfg.FFmpeg,
+		sem:           make(chan struct{}, previewConcurrency),
+	}
+	if p.size <= 0 {
+		p.size = defaultPreviewSize
+	}
+	if p.maxSourceSize <= 0 {
+		p.maxSourceSize = defaultPreviewMaxSourceSize
+	}
+	return p
+}
+
+// kind returns "image" or "video" if files of mime type typ can have a
+// thumbnail, and "" otherwise.
+func (p *previewer) kind(typ string) string {
+	switch {
+	case strings.HasPrefix(typ, "image/jpeg"),
+		strings.HasPrefix(typ, "image/png"),
+		strings.HasPrefix(typ, "image/gif"):
+		return "image"
+	case strings.HasPrefix(typ, "video/") && p.ffmpeg != "":
+		return "video"
+	default:
+		return ""
+	}
+}
+
+// previewable tells whether the file called name is worth a thumbnail in
+// directory listings, going by its extension.
+func (p *previewer) previewable(name string) bool {
+	return p.kind(mime.TypeByExtension(gopath.Ext(name))) != ""
+}
+
+// serve writes the thumbnail of the file c called name.
+func (p *previewer) serve(ctx context.Context, w http.ResponseWriter, r *http.Request, modtime time.Time, c *cid.Cid, name string, content io.ReadSeeker) {
+	data, err := p.get(ctx, c, name, content)
+	switch err {
+	case nil:
+	case errNoPreview, errPreviewLarge:
+		webError(w, "no preview for "+name, err, http.StatusNotFound)
+		return
+	default:
+		internalWebError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/jpeg")
+	http.ServeContent(w, r, "", modtime, bytes.NewReader(data))
+}
+
+// get returns the cached thumbnail of c if it is still in the blockstore,
+// and generates it from content otherwise.
+func (p *previewer) get(ctx context.Context, c *cid.Cid, name string, content io.ReadSeeker) ([]byte, error) {
+	key := previewPrefix.ChildString(c.String())
+	d := p.node.Repo.Datastore()
+
+	if v, err := d.Get(key); err == nil {
+		if b, ok := v.([]byte); ok {
+			tc, err := cid.Cast(b)
+			if err == nil {
+				blk, err := p.node.Blockstore.Get(tc)
+				if err == nil {
+					return blk.RawData(), nil
+				}
+			}
+		}
+	}
+
+	select {
+	case p.sem <- struct{}{}:
+		defer func() { <-p.sem }()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	data, err := p.generate(ctx, name, content)
+	if err != nil {
+		return nil, err
+	}
+
+	nd := dag.NewRawNode(data)
+	if _, err := p.node.DAG.Add(nd); err != nil {
+		return nil, err
+	}
+	if err := d.Put(key, nd.Cid().Bytes()); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (p *previewer) generate(ctx context.Context, name string, content io.ReadSeeker) ([]byte, error) {
+	size, err := content.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	if size > p.maxSourceSize {
+		return nil, errPreviewLarge
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	typ := mime.TypeByExtension(gopath.Ext(name))
+	if typ == "" {
+		head := make([]byte, 512)
+		n, err := io.ReadFull(content, head)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return nil, err
+		}
+		typ = http.DetectContentType(head[:n])
+		if _, err := content.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+	}
+
+	var src []byte
+	switch p.kind(typ) {
+	case "image":
+		src, err = ioutil.ReadAll(content)
+	case "video":
+		src, err = p.videoFrame(ctx, content)
+	default:
+		return nil, errNoPreview
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > previewMaxPixels {
+		return nil, fmt.Errorf("image of %dx%d pixels is too large", cfg.Width, cfg.Height)
+	}
+	img, _, err := image.Decode(bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	err = jpeg.Encode(&buf, thumbnail(img, p.size), &jpeg.Options{Quality: previewQuality})
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// videoFrame returns the first frame of the video read from content, as a
+// JPEG image.
+func (p *previewer) videoFrame(ctx context.Context, content io.Reader) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, p.ffmpeg,
+		"-loglevel", "error",
+		"-i", "pipe:0",
+		"-frames:v", "1",
+		"-f", "image2pipe",
+		"-vcodec", "mjpeg",
+		"pipe:1")
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	// ffmpeg exits as soon as it has a frame, without reading the rest of
+	// the video, so write errors are expected here.
+	copied := make(chan struct{})
+	go func() {
+		defer close(copied)
+		io.Copy(stdin, content)
+		stdin.Close()
+	}()
+
+	err = cmd.Wait()
+	<-copied
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg: %s: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	if stdout.Len() == 0 {
+		return nil, errors.New("ffmpeg found no video frame")
+	}
+	return stdout.Bytes(), nil
+}
+
+// thumbnail scales img down, keeping its aspect ratio, so that its longest
+// side is size pixels. Each pixel is the average of a grid of samples of the
+// area of img it covers, which is much faster than averaging every pixel of
+// large images, and smooth enough at thumbnail sizes.
+func thumbnail(img image.Image, size int) *image.RGBA {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w > size || h > size {
+		if w >= h {
+			w, h = size, h*size/w
+		} else {
+			w, h = w*size/h, size
+		}
+	}
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+
+	const samples = 4
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+
+	for y := 0; y < h; y++ {
+		y0, y1 := b.Min.Y+y*b.Dy()/h, b.Min.Y+(y+1)*b.Dy()/h
+		for x := 0; x < w; x++ {
+			x0, x1 := b.Min.X+x*b.Dx()/w, b.Min.X+(x+1)*b.Dx()/w
+
+			var r, g, bl, a, n uint32
+			for sy := 0; sy < samples; sy++ {
+				py := y0 + (y1-y0)*sy/samples
+				for sx := 0; sx < samples; sx++ {
+					px := x0 + (x1-x0)*sx/samples
+					pr, pg, pb, pa := img.At(px, py).RGBA()
+					r, g, bl, a = r+pr, g+pg, bl+pb, a+pa
+					n++
+				}
+			}
+
+			// transparent images are shown on white: blend the
+			// premultiplied average over it
+			r, g, bl, a = r/n, g/n, bl/n, a/n
+			white := 0xffff - a
+			dst.Set(x, y, color.RGBA64{
+				R: uint16(r + white),
+				G: uint16(g + white),
+				B: uint16(bl + white),
+				A: 0xffff,
+			})
+		}
+	}
+	return dst
+}
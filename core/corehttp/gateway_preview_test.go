@@ -0,0 +1,104 @@
+package corehttp
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	coreunix "github.com/ipfs/go-ipfs/core/coreunix"
+)
+
+func TestThumbnail(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 400, 100))
+	for x := 0; x < 400; x++ {
+		for y := 0; y < 100; y++ {
+			src.Set(x, y, color.RGBA{R: 0xff, A: 0xff})
+		}
+	}
+
+	thumb := thumbnail(src, 128)
+	if b := thumb.Bounds(); b.Dx() != 128 || b.Dy() != 32 {
+		t.Fatalf("expected a 128x32 thumbnail, got %dx%d", b.Dx(), b.Dy())
+	}
+	if c := thumb.RGBAAt(64, 16); c.R != 0xff || c.G != 0 || c.B != 0 {
+		t.Fatalf("expected red, got %v", c)
+	}
+
+	// transparent pixels are shown on white
+	thumb = thumbnail(image.NewRGBA(image.Rect(0, 0, 10, 20)), 128)
+	if b := thumb.Bounds(); b.Dx() != 10 || b.Dy() != 20 {
+		t.Fatalf("small images should keep their size, got %dx%d", b.Dx(), b.Dy())
+	}
+	if c := thumb.RGBAAt(5, 5); c.R != 0xff || c.G != 0xff || c.B != 0xff {
+		t.Fatalf("expected white, got %v", c)
+	}
+}
+
+func TestGatewayPreview(t *testing.T) {
+	n, err := newNodeWithMockNamesys(mockNamesys{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := n.Repo.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Gateway.Previews.Enabled = true
+
+	dh := &delegatedHandler{}
+	ts := httptest.NewServer(dh)
+	defer ts.Close()
+	dh.Handler, err = makeHandler(n, ts.Listener, GatewayOption(false, "/ipfs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 300, 600))); err != nil {
+		t.Fatal(err)
+	}
+	k, err := coreunix.Add(n, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		res, err := http.Get(ts.URL + "/ipfs/" + k + "?preview=1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d", res.StatusCode)
+		}
+		img, err := jpeg.Decode(res.Body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b := img.Bounds(); b.Dx() != 64 || b.Dy() != 128 {
+			t.Fatalf("expected a 64x128 preview, got %dx%d", b.Dx(), b.Dy())
+		}
+	}
+
+	if has, err := n.Repo.Datastore().Has(previewPrefix.ChildString(k)); err != nil || !has {
+		t.Fatal("expected the preview to be cached", err)
+	}
+
+	txt, err := coreunix.Add(n, bytes.NewReader([]byte("not an image")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := http.Get(ts.URL + "/ipfs/" + txt + "?preview=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", res.StatusCode)
+	}
+}
@@ -0,0 +1,309 @@
+package corehttp
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	cmdsHttp "github.com/ipfs/go-ipfs/commands/http"
+	core "github.com/ipfs/go-ipfs/core"
+)
+
+// IdempotencyKeyHeader carries the key of a request to run once.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// idempotentReplayedHeader marks the answers replayed from the cache.
+const idempotentReplayedHeader = "Idempotent-Replayed"
+
+const (
+	defaultIdempotencyEntries = 1000
+	defaultIdempotencyTTL     = 24 * time.Hour
+
+	// idempotencyMaxBody bounds the answers kept.
+	idempotencyMaxBody = 1 << 20
+)
+
+// idempotentCommands are the commands whose requests may carry a key.
+var idempotentCommands = map[string]bool{
+	"add":          true,
+	"pin/add":      true,
+	"pin/rm":       true,
+	"pin/update":   true,
+	"name/publish": true,
+}
+
+// IdempotencyOption runs the requests of the mutating commands carrying an
+// Idempotency-Key header once, answering their retries with the answer
+// kept, as set in API.Idempotency.
+func IdempotencyOption() ServeOption {
+	return func(n *core.IpfsNode, _ net.Listener, mux *http.ServeMux) (*http.ServeMux, error) {
+		cfg, err := n.Repo.Config()
+		if err != nil {
+			return nil, err
+		}
+
+		max := cfg.API.Idempotency.MaxEntries
+		if max < 0 {
+			return mux, nil
+		}
+		if max == 0 {
+			max = defaultIdempotencyEntries
+		}
+		ttl := defaultIdempotencyTTL
+		if cfg.API.Idempotency.TTL != "" {
+			ttl, err = time.ParseDuration(cfg.API.Idempotency.TTL)
+			if err != nil || ttl <= 0 {
+				return nil, fmt.Errorf("API.Idempotency.TTL: invalid duration %q", cfg.API.Idempotency.TTL)
+			}
+		}
+		c := newIdemCache(max, ttl)
+
+		childMux := http.NewServeMux()
+		mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			if key == "" || !idempotentCommands[requestCommand(r)] {
+				childMux.ServeHTTP(w, r)
+				return
+			}
+			c.serve(w, r, key, childMux)
+		})
+		return childMux, nil
+	}
+}
+
+// idemEntry is a request run with a key, and its answer once it is done.
+type idemEntry struct {
+	key     string
+	request string // the method, command and arguments of the request
+	sum     []byte // the bodySum of the body of the request, once done
+	done    bool
+	expires time.Time
+	elem    *list.Element // in idemCache.order, once done
+
+	status int
+	header http.Header
+	body   []byte
+}
+
+// idemCache keeps the answers of the requests run with a key, the oldest
+// going first above the cap.
+type idemCache struct {
+	max int
+	ttl time.Duration
+
+	lk      sync.Mutex
+	entries map[string]*idemEntry
+	order   *list.List // of the done entries, oldest first
+
+	// now is overridden in tests.
+	now func() time.Time
+}
+
+func newIdemCache(max int, ttl time.Duration) *idemCache {
+	return &idemCache{
+		max:     max,
+		ttl:     ttl,
+		entries: make(map[string]*idemEntry),
+		order:   list.New(),
+		now:     time.Now,
+	}
+}
+
+// begin returns the entry of key, which the caller runs if it is new, or
+// an error status for the request if it can't be run or replayed.
+func (c *idemCache) begin(key, request string) (e *idemEntry, fresh bool, status int) {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+
+	if e, ok := c.entries[key]; ok {
+		switch {
+		case e.done && c.now().After(e.expires):
+			c.remove(e)
+		case e.request != request:
+			return nil, false, http.StatusUnprocessableEntity
+		case !e.done:
+			return nil, false, http.StatusConflict
+		default:
+			return e, false, 0
+		}
+	}
+
+	e = &idemEntry{key: key, request: request}
+	c.entries[key] = e
+	return e, true, 0
+}
+
+// finish keeps the answer of e, or forgets e if the answer isn't kept.
+func (c *idemCache) finish(e *idemEntry, keep bool) {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	if !keep {
+		delete(c.entries, e.key)
+		return
+	}
+
+	e.done = true
+	e.expires = c.now().Add(c.ttl)
+	e.elem = c.order.PushBack(e)
+	for c.order.Len() > c.max {
+		c.remove(c.order.Front().Value.(*idemEntry))
+	}
+}
+
+// remove forgets the done entry e. c.lk must be held.
+func (c *idemCache) remove(e *idemEntry) {
+	delete(c.entries, e.key)
+	c.order.Remove(e.elem)
+}
+
+func (c *idemCache) serve(w http.ResponseWriter, r *http.Request, key string, h http.Handler) {
+	request := r.Method + " " + r.URL.Path + "?" + r.URL.RawQuery
+	e, fresh, status := c.begin(key, request)
+	switch {
+	case status == http.StatusConflict:
+		http.Error(w, "a request with this Idempotency-Key is running", status)
+		return
+	case status != 0:
+		http.Error(w, "the Idempotency-Key was used for another request", status)
+		return
+	case !fresh:
+		// the arguments of add are in the body, the retry must send the
+		// same one.
+		sum := newBodySum(r)
+		if _, err := io.Copy(sum, r.Body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !bytes.Equal(sum.Sum(), e.sum) {
+			http.Error(w, "the Idempotency-Key was used for another request", http.StatusUnprocessableEntity)
+			return
+		}
+		for k, v := range e.header {
+			w.Header()[k] = v
+		}
+		w.Header().Set(idempotentReplayedHeader, "true")
+		w.WriteHeader(e.status)
+		w.Write(e.body)
+		return
+	}
+
+	sum := newBodySum(r)
+	body := r.Body
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.TeeReader(body, sum), body}
+
+	rec := &idemRecorder{w: w, status: http.StatusOK}
+	h.ServeHTTP(rec, r)
+
+	// hash what the handler left of the body too
+	_, err := io.Copy(ioutil.Discard, r.Body)
+
+	failed := rec.status < 200 || rec.status >= 300 ||
+		w.Header().Get(cmdsHttp.StreamErrHeader) != "" || rec.overflow || err != nil
+	if !failed {
+		e.sum = sum.Sum()
+		e.status = rec.status
+		e.header = make(http.Header)
+		for k, v := range w.Header() {
+			if k != "Trailer" {
+				e.header[k] = v
+			}
+		}
+		e.body = rec.body.Bytes()
+	}
+	c.finish(e, !failed)
+}
+
+// bodySum hashes the body of a request with sha256. The boundary of a
+// multipart body, which clients pick at random for each request, is left
+// out, so that a retry sending the same parts has the same sum.
+type bodySum struct {
+	h        hash.Hash
+	boundary []byte
+	pending  []byte // the end of the body, which may start a boundary
+}
+
+func newBodySum(r *http.Request) *bodySum {
+	s := &bodySum{h: sha256.New()}
+	mt, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err == nil && strings.HasPrefix(mt, "multipart/") && params["boundary"] != "" {
+		s.boundary = []byte(params["boundary"])
+	}
+	return s
+}
+
+func (s *bodySum) Write(p []byte) (int, error) {
+	if s.boundary == nil {
+		return s.h.Write(p)
+	}
+
+	s.pending = bytes.Replace(append(s.pending, p...), s.boundary, nil, -1)
+	if keep := len(s.boundary) - 1; len(s.pending) > keep {
+		s.h.Write(s.pending[:len(s.pending)-keep])
+		s.pending = append(s.pending[:0], s.pending[len(s.pending)-keep:]...)
+	}
+	return len(p), nil
+}
+
+// Sum returns the sum of the body written.
+func (s *bodySum) Sum() []byte {
+	s.h.Write(s.pending)
+	s.pending = nil
+	return s.h.Sum(nil)
+}
+
+// idemRecorder records an answer while sending it. The answer is recorded
+// in full even if the client goes away, and it doesn't notify the handler
+// of that, so that the request runs to its end and its retry is answered.
+type idemRecorder struct {
+	w        http.ResponseWriter
+	gone     bool
+	status   int
+	body     bytes.Buffer
+	overflow bool
+}
+
+func (rec *idemRecorder) Header() http.Header {
+	return rec.w.Header()
+}
+
+func (rec *idemRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.w.WriteHeader(status)
+}
+
+func (rec *idemRecorder) Write(b []byte) (int, error) {
+	if !rec.overflow {
+		if rec.body.Len()+len(b) > idempotencyMaxBody {
+			rec.overflow = true
+			rec.body.Reset()
+		} else {
+			rec.body.Write(b)
+		}
+	}
+	if !rec.gone {
+		if _, err := rec.w.Write(b); err != nil {
+			log.Debugf("the client of an idempotent request went away: %s", err)
+			rec.gone = true
+		}
+	}
+	return len(b), nil
+}
+
+func (rec *idemRecorder) Flush() {
+	if f, ok := rec.w.(http.Flusher); ok && !rec.gone {
+		f.Flush()
+	}
+}
@@ -0,0 +1,149 @@
+package corehttp
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyCache(t *testing.T) {
+	c := newIdemCache(2, time.Hour)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	runs := 0
+	fail := false
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		runs++
+		if fail {
+			http.Error(w, "failed", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, "run %d", runs)
+	})
+	doBody := func(key, url string, body io.Reader) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c.serve(w, httptest.NewRequest("POST", url, body), key, h)
+		return w
+	}
+	do := func(key, url string) *httptest.ResponseRecorder {
+		return doBody(key, url, nil)
+	}
+
+	w := do("a", "/api/v0/pin/add?arg=x")
+	if runs != 1 || w.Body.String() != "run 1" || w.Header().Get(idempotentReplayedHeader) != "" {
+		t.Fatalf("expected the request to run, got %q", w.Body.String())
+	}
+	w = do("a", "/api/v0/pin/add?arg=x")
+	if runs != 1 || w.Body.String() != "run 1" || w.Header().Get(idempotentReplayedHeader) != "true" {
+		t.Fatalf("expected the answer to be replayed, got %q", w.Body.String())
+	}
+	if w.Header().Get("Content-Type") != "application/json" {
+		t.Fatal("expected the headers of the answer to be replayed")
+	}
+	if w = do("a", "/api/v0/pin/add?arg=y"); w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected a key reused for another request to be refused, got %d", w.Code)
+	}
+
+	// the body is part of the request
+	if w = doBody("a", "/api/v0/pin/add?arg=x", strings.NewReader("other")); w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected a key reused with another body to be refused, got %d", w.Code)
+	}
+
+	// failed requests run again
+	fail = true
+	do("b", "/api/v0/add")
+	fail = false
+	if w = do("b", "/api/v0/add"); runs != 3 || w.Body.String() != "run 3" {
+		t.Fatalf("expected a failed request to run again, got %q", w.Body.String())
+	}
+
+	// the oldest answer goes first
+	do("c", "/api/v0/add")
+	if do("a", "/api/v0/pin/add?arg=x"); runs != 5 {
+		t.Fatal("expected the oldest answer to be evicted")
+	}
+
+	// and the answers expire
+	now = now.Add(2 * time.Hour)
+	if do("c", "/api/v0/add"); runs != 6 {
+		t.Fatal("expected the expired answer to run again")
+	}
+}
+
+func TestIdempotencyMultipart(t *testing.T) {
+	c := newIdemCache(10, time.Hour)
+	runs := 0
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		runs++
+		fmt.Fprintf(w, "run %d", runs)
+	})
+
+	// each body has a boundary of its own, as when the client retries
+	do := func(content string) *httptest.ResponseRecorder {
+		body := new(bytes.Buffer)
+		mw := multipart.NewWriter(body)
+		fw, err := mw.CreateFormFile("file", "hello.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(fw, content)
+		mw.Close()
+
+		r := httptest.NewRequest("POST", "/api/v0/add", body)
+		r.Header.Set("Content-Type", mw.FormDataContentType())
+		w := httptest.NewRecorder()
+		c.serve(w, r, "a", h)
+		return w
+	}
+
+	do("hello")
+	if w := do("hello"); runs != 1 || w.Header().Get(idempotentReplayedHeader) != "true" {
+		t.Fatalf("expected the answer to be replayed despite another boundary, got %q", w.Body.String())
+	}
+	if w := do("other"); w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected a key reused with other parts to be refused, got %d", w.Code)
+	}
+}
+
+func TestBodySum(t *testing.T) {
+	r := httptest.NewRequest("POST", "/api/v0/add", nil)
+	r.Header.Set("Content-Type", "multipart/form-data; boundary=xyz")
+	body := []byte("--xyz\r\nhello\r\n--xyz--\r\n")
+
+	whole := newBodySum(r)
+	whole.Write(body)
+	expected := whole.Sum()
+
+	// the boundaries are left out whatever the writes they are split across
+	chunked := newBodySum(r)
+	for i := range body {
+		chunked.Write(body[i : i+1])
+	}
+	if !bytes.Equal(chunked.Sum(), expected) {
+		t.Fatal("expected the sum not to depend on the writes")
+	}
+
+	other := newBodySum(r)
+	other.Write(bytes.Replace(body, []byte("xyz"), []byte("abc"), -1))
+	if bytes.Equal(other.Sum(), expected) {
+		t.Fatal("expected the sum to change with the content outside of the boundary")
+	}
+}
+
+func TestIdempotencyRunning(t *testing.T) {
+	c := newIdemCache(10, time.Hour)
+	if _, fresh, status := c.begin("a", "POST /api/v0/add?"); !fresh || status != 0 {
+		t.Fatal("expected the request to run")
+	}
+	if _, _, status := c.begin("a", "POST /api/v0/add?"); status != http.StatusConflict {
+		t.Fatalf("expected a retry while the request runs to be refused, got %d", status)
+	}
+}
@@ -0,0 +1,213 @@
+package corehttp
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	cmdsHttp "github.com/ipfs/go-ipfs/commands/http"
+	core "github.com/ipfs/go-ipfs/core"
+	memtune "github.com/ipfs/go-ipfs/core/memtune"
+
+	humanize "gx/ipfs/QmPSBJL4momYnE7DcUyk2DVhD6rH488ZmHBGLbxNdhU44K/go-humanize"
+	prometheus "gx/ipfs/QmX3QZ5jHEPidwUrymXV1iSCSUhdGxj15sm2gP4jKMef7B/client_golang/prometheus"
+)
+
+// loadSampleInterval is how often the heap and the file descriptors are
+// checked. Reading the heap stops the world for a moment.
+const loadSampleInterval = time.Second
+
+// loadShedRetryAfter is the delay, in seconds, after which the clients of
+// shed requests are told to try again.
+const loadShedRetryAfter = 10
+
+// shedPathDepth is the number of path components past which resolving a
+// gateway path is expensive.
+const shedPathDepth = 16
+
+// Reasons of shedding load.
+const (
+	shedHeap = "heap"
+	shedFDs  = "fds"
+)
+
+// expensiveCommands are the API commands shed under load: the listings and
+// the recursive resolutions, whose memory grows with the DAG.
+var expensiveCommands = map[string]bool{
+	"dag/get":      true,
+	"dag/resolve":  true,
+	"file/ls":      true,
+	"files/ls":     true,
+	"get":          true,
+	"ls":           true,
+	"name/resolve": true,
+	"object/links": true,
+	"pin/ls":       true,
+	"refs":         true,
+	"refs/local":   true,
+	"resolve":      true,
+}
+
+var (
+	shedRequestsMetric = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "ipfs",
+		Subsystem: "http",
+		Name:      "shed_requests_total",
+		Help:      "Requests rejected while shedding load",
+	}, []string{"server", "reason"})
+	sheddingMetric = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "ipfs",
+		Subsystem: "http",
+		Name:      "load_shedding",
+		Help:      "Whether the server sheds load",
+	}, []string{"server"})
+)
+
+func init() {
+	prometheus.MustRegister(shedRequestsMetric, sheddingMetric)
+}
+
+// loadShedder watches the heap and the file descriptors of the daemon, and
+// rejects the expensive requests while either is over its limit.
+type loadShedder struct {
+	server    string
+	heapMax   uint64
+	fdPercent uint64
+
+	// reason is why load is shed, "" while it isn't
+	reason atomic.Value
+}
+
+type shedderKey struct{}
+
+// LoadSheddingOption rejects the expensive requests with a 503 while the
+// daemon is over the limits of the Memory config, and counts them under the
+// name of the server. The handlers of the following options may shed more
+// requests with shedLoad.
+func LoadSheddingOption(server string) ServeOption {
+	return func(n *core.IpfsNode, _ net.Listener, mux *http.ServeMux) (*http.ServeMux, error) {
+		cfg, err := n.Repo.Config()
+		if err != nil {
+			return nil, err
+		}
+
+		s := &loadShedder{server: server}
+		s.reason.Store("")
+		if cfg.Memory.ShedHeapAbove != "" {
+			s.heapMax, err = humanize.ParseBytes(cfg.Memory.ShedHeapAbove)
+			if err != nil {
+				return nil, fmt.Errorf("invalid Memory.ShedHeapAbove: %s", err)
+			}
+		}
+		if p := cfg.Memory.ShedFDPercent; p < 0 || p > 100 {
+			return nil, fmt.Errorf("Memory.ShedFDPercent must be between 0 and 100, not %d", p)
+		}
+		s.fdPercent = uint64(cfg.Memory.ShedFDPercent)
+		if s.heapMax == 0 && s.fdPercent == 0 {
+			return mux, nil
+		}
+		go s.run(n.Context())
+
+		childMux := http.NewServeMux()
+		mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			if reason := s.shedding(); reason != "" && expensiveRequest(r) {
+				s.shed(w, reason)
+				return
+			}
+			ctx := context.WithValue(r.Context(), shedderKey{}, s)
+			childMux.ServeHTTP(w, r.WithContext(ctx))
+		})
+		return childMux, nil
+	}
+}
+
+// shedLoad rejects the request and returns true if the server of r sheds
+// load. Handlers call it before doing expensive work.
+func shedLoad(w http.ResponseWriter, r *http.Request) bool {
+	s, ok := r.Context().Value(shedderKey{}).(*loadShedder)
+	if !ok {
+		return false
+	}
+	reason := s.shedding()
+	if reason == "" {
+		return false
+	}
+	s.shed(w, reason)
+	return true
+}
+
+func (s *loadShedder) shedding() string {
+	return s.reason.Load().(string)
+}
+
+func (s *loadShedder) shed(w http.ResponseWriter, reason string) {
+	shedRequestsMetric.WithLabelValues(s.server, reason).Inc()
+	w.Header().Set("Retry-After", fmt.Sprint(loadShedRetryAfter))
+	http.Error(w, "the node is overloaded ("+reason+"), try again later", http.StatusServiceUnavailable)
+}
+
+func (s *loadShedder) run(ctx context.Context) {
+	t := time.NewTicker(loadSampleInterval)
+	defer t.Stop()
+	for {
+		s.sample()
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// sample checks the limits, and updates the reason of shedding load.
+func (s *loadShedder) sample() {
+	reason := ""
+	if s.heapMax > 0 {
+		var ms runtime.MemStats
+		runtime.ReadMemStats(&ms)
+		heap := ms.HeapAlloc
+		if b := memtune.BallastSize(); heap > b {
+			heap -= b
+		}
+		if heap > s.heapMax {
+			reason = shedHeap
+		}
+	}
+	if reason == "" && s.fdPercent > 0 {
+		if open, limit, ok := openFDs(); ok && limit > 0 && open*100 > limit*s.fdPercent {
+			reason = shedFDs
+		}
+	}
+
+	prev := s.shedding()
+	if reason == prev {
+		return
+	}
+	s.reason.Store(reason)
+	if reason != "" {
+		log.Warningf("%s server: over the %s limit, shedding load", s.server, reason)
+		sheddingMetric.WithLabelValues(s.server).Set(1)
+	} else {
+		log.Infof("%s server: back under the limits, no longer shedding load", s.server)
+		sheddingMetric.WithLabelValues(s.server).Set(0)
+	}
+}
+
+// expensiveRequest tells whether r is expensive enough to be rejected
+// outright under load: listing and recursive commands of the API, and
+// gateway paths of many components.
+func expensiveRequest(r *http.Request) bool {
+	p := r.URL.Path
+	if strings.HasPrefix(p, cmdsHttp.ApiPath+"/") {
+		return expensiveCommands[strings.Trim(strings.TrimPrefix(p, cmdsHttp.ApiPath), "/")]
+	}
+	if strings.HasPrefix(p, ipfsPathPrefix) || strings.HasPrefix(p, ipnsPathPrefix) {
+		return strings.Count(strings.Trim(p, "/"), "/") > shedPathDepth
+	}
+	return false
+}
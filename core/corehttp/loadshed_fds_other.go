@@ -0,0 +1,7 @@
+// +build !darwin,!freebsd,!linux,!netbsd,!openbsd
+
+package corehttp
+
+func openFDs() (open, limit uint64, ok bool) {
+	return 0, 0, false
+}
@@ -0,0 +1,29 @@
+// +build darwin freebsd linux netbsd openbsd
+
+package corehttp
+
+import (
+	"os"
+	"syscall"
+)
+
+// openFDs returns the number of open file descriptors of the process, and
+// its limit.
+func openFDs() (open, limit uint64, ok bool) {
+	var rlim syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rlim); err != nil {
+		return 0, 0, false
+	}
+
+	d, err := os.Open("/dev/fd")
+	if err != nil {
+		return 0, 0, false
+	}
+	defer d.Close()
+	names, err := d.Readdirnames(-1)
+	if err != nil {
+		return 0, 0, false
+	}
+	// not counting the descriptor of the listing itself
+	return uint64(len(names) - 1), uint64(rlim.Cur), true
+}
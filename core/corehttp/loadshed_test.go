@@ -0,0 +1,68 @@
+package corehttp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestExpensiveRequest(t *testing.T) {
+	deep := "/ipfs/QmHash" + strings.Repeat("/a", shedPathDepth+1)
+	cases := map[string]bool{
+		"/api/v0/ls":                true,
+		"/api/v0/refs/local":        true,
+		"/api/v0/name/resolve/":     true,
+		"/api/v0/id":                false,
+		"/api/v0/cat":               false,
+		"/ipfs/QmHash/a/b":          false,
+		deep:                        true,
+		"/debug/metrics/prometheus": false,
+	}
+	for p, expensive := range cases {
+		r := httptest.NewRequest("GET", p, nil)
+		if expensiveRequest(r) != expensive {
+			t.Errorf("expected %s to be expensive: %t", p, expensive)
+		}
+	}
+}
+
+func TestShedLoad(t *testing.T) {
+	s := &loadShedder{server: "test"}
+	s.reason.Store("")
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if shedLoad(w, r) {
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	serve := func() *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/ipfs/QmHash/", nil)
+		r = r.WithContext(context.WithValue(r.Context(), shedderKey{}, s))
+		w := httptest.NewRecorder()
+		handler(w, r)
+		return w
+	}
+
+	if w := serve(); w.Code != http.StatusOK {
+		t.Fatalf("expected the request to be served, got %d", w.Code)
+	}
+
+	s.reason.Store(shedHeap)
+	w := serve()
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected the request to be shed, got %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Fatal("expected a Retry-After header")
+	}
+
+	// requests of servers not shedding load are always served
+	w = httptest.NewRecorder()
+	handler(w, httptest.NewRequest("GET", "/ipfs/QmHash/", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected the request to be served, got %d", w.Code)
+	}
+}
@@ -3,8 +3,11 @@ package corehttp
 import (
 	"net"
 	"net/http"
+	"time"
 
 	core "github.com/ipfs/go-ipfs/core"
+	corerepo "github.com/ipfs/go-ipfs/core/corerepo"
+	ds2 "github.com/ipfs/go-ipfs/thirdparty/datastore2"
 
 	prometheus "gx/ipfs/QmX3QZ5jHEPidwUrymXV1iSCSUhdGxj15sm2gP4jKMef7B/client_golang/prometheus"
 )
@@ -30,14 +33,45 @@ var (
 	peersTotalMetric = prometheus.NewDesc(
 		prometheus.BuildFQName("ipfs", "p2p", "peers_total"),
 		"Number of connected peers", []string{"transport"}, nil)
+
+	datastoreLatencyMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("ipfs", "fsrepo", "datastore_latency_seconds"),
+		"Latency of recent datastore operations", []string{"datastore", "op", "quantile"}, nil)
+
+	gcLastRunMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("ipfs", "repo", "gc_last_run_timestamp_seconds"),
+		"Start time of the last garbage collection", nil, nil)
+	gcLastDurationMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("ipfs", "repo", "gc_last_duration_seconds"),
+		"Duration of the last garbage collection", nil, nil)
+	gcLastBlocksMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("ipfs", "repo", "gc_last_blocks_removed"),
+		"Blocks removed by the last garbage collection", nil, nil)
+	gcLastBytesMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("ipfs", "repo", "gc_last_bytes_freed"),
+		"Bytes freed by the last garbage collection", nil, nil)
+	gcLastErrorsMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("ipfs", "repo", "gc_last_errors"),
+		"Errors of the last garbage collection", nil, nil)
 )
 
+// latencyRepo is implemented by repos recording datastore latencies.
+type latencyRepo interface {
+	DatastoreLatency() map[string]map[string]ds2.LatencyStats
+}
+
 type IpfsNodeCollector struct {
 	Node *core.IpfsNode
 }
 
 func (_ IpfsNodeCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- peersTotalMetric
+	ch <- datastoreLatencyMetric
+	ch <- gcLastRunMetric
+	ch <- gcLastDurationMetric
+	ch <- gcLastBlocksMetric
+	ch <- gcLastBytesMetric
+	ch <- gcLastErrorsMetric
 }
 
 func (c IpfsNodeCollector) Collect(ch chan<- prometheus.Metric) {
@@ -49,6 +83,54 @@ func (c IpfsNodeCollector) Collect(ch chan<- prometheus.Metric) {
 			tr,
 		)
 	}
+
+	c.collectGC(ch)
+
+	lr, ok := c.Node.Repo.(latencyRepo)
+	if !ok {
+		return
+	}
+	for name, ops := range lr.DatastoreLatency() {
+		for op, st := range ops {
+			quantiles := map[string]time.Duration{
+				"0.5":  st.P50,
+				"0.9":  st.P90,
+				"0.99": st.P99,
+				"1":    st.Max,
+			}
+			for q, d := range quantiles {
+				ch <- prometheus.MustNewConstMetric(
+					datastoreLatencyMetric,
+					prometheus.GaugeValue,
+					d.Seconds(),
+					name, op, q,
+				)
+			}
+		}
+	}
+}
+
+func (c IpfsNodeCollector) collectGC(ch chan<- prometheus.Metric) {
+	runs, err := corerepo.LastGCRuns(c.Node, 1)
+	if err != nil {
+		log.Debugf("failed to read the gc runs: %s", err)
+		return
+	}
+	if len(runs) == 0 {
+		return
+	}
+
+	run := runs[0]
+	values := map[*prometheus.Desc]float64{
+		gcLastRunMetric:      float64(run.Start.UnixNano()) / 1e9,
+		gcLastDurationMetric: run.Duration.Seconds(),
+		gcLastBlocksMetric:   float64(run.BlocksRemoved),
+		gcLastBytesMetric:    float64(run.BytesFreed),
+		gcLastErrorsMetric:   float64(run.ErrorCount),
+	}
+	for desc, v := range values {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, v)
+	}
 }
 
 func (c IpfsNodeCollector) PeersTotalValues() map[string]float64 {
@@ -0,0 +1,157 @@
+package corehttp
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	core "github.com/ipfs/go-ipfs/core"
+)
+
+// Names of the HTTP servers a plugin may extend.
+const (
+	PluginServerAPI     = "api"
+	PluginServerGateway = "gateway"
+)
+
+// HTTPPlugin extends the HTTP servers of the daemon with additional handlers
+// and middleware, such as authentication filters or custom routes.
+type HTTPPlugin struct {
+	// Name identifies the plugin in errors and logs; it must be unique.
+	Name string
+
+	// Servers lists the servers the plugin applies to.
+	Servers []string
+
+	// Order sorts the middleware of all plugins: the middleware with the
+	// lowest Order sees requests first. Plugins with the same Order are
+	// sorted by name.
+	Order int
+
+	// Handlers maps the paths to serve, as understood by http.ServeMux, to
+	// their handlers. A path may only be registered once per server, by a
+	// plugin or by go-ipfs itself.
+	Handlers map[string]http.Handler
+
+	// Middleware, if set, wraps every handler of the server, including
+	// the ones of go-ipfs and other plugins.
+	Middleware func(*core.IpfsNode, http.Handler) http.Handler
+}
+
+var plugins = struct {
+	sync.Mutex
+	list []HTTPPlugin
+}{}
+
+// RegisterHTTPPlugin registers p with the HTTP servers it applies to. It must
+// be called before the servers start, and fails if p conflicts with a
+// previously registered plugin.
+func RegisterHTTPPlugin(p HTTPPlugin) error {
+	if p.Name == "" {
+		return fmt.Errorf("http plugin has no name")
+	}
+	if len(p.Servers) == 0 {
+		return fmt.Errorf("http plugin %s does not apply to any server", p.Name)
+	}
+	for _, s := range p.Servers {
+		if s != PluginServerAPI && s != PluginServerGateway {
+			return fmt.Errorf("http plugin %s: unknown server %q", p.Name, s)
+		}
+	}
+	for path := range p.Handlers {
+		if !strings.HasPrefix(path, "/") {
+			return fmt.Errorf("http plugin %s: path %q must start with a slash", p.Name, path)
+		}
+	}
+
+	plugins.Lock()
+	defer plugins.Unlock()
+
+	for _, other := range plugins.list {
+		if other.Name == p.Name {
+			return fmt.Errorf("http plugin %s is already registered", p.Name)
+		}
+		if !sharesServer(p, other) {
+			continue
+		}
+		for path := range p.Handlers {
+			if _, ok := other.Handlers[path]; ok {
+				return fmt.Errorf("http plugin %s: path %s is already served by plugin %s", p.Name, path, other.Name)
+			}
+		}
+	}
+
+	plugins.list = append(plugins.list, p)
+	return nil
+}
+
+func sharesServer(a, b HTTPPlugin) bool {
+	for _, s := range a.Servers {
+		if appliesTo(b, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func appliesTo(p HTTPPlugin, server string) bool {
+	for _, s := range p.Servers {
+		if s == server {
+			return true
+		}
+	}
+	return false
+}
+
+type byOrder []HTTPPlugin
+
+func (p byOrder) Len() int      { return len(p) }
+func (p byOrder) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+func (p byOrder) Less(i, j int) bool {
+	if p[i].Order != p[j].Order {
+		return p[i].Order < p[j].Order
+	}
+	return p[i].Name < p[j].Name
+}
+
+// PluginOption adds the handlers and middleware of the plugins registered
+// for server. Options following it are wrapped by the middleware, and fail
+// if they register a path already served by a plugin.
+func PluginOption(server string) ServeOption {
+	return func(n *core.IpfsNode, _ net.Listener, mux *http.ServeMux) (*http.ServeMux, error) {
+		plugins.Lock()
+		var active []HTTPPlugin
+		for _, p := range plugins.list {
+			if appliesTo(p, server) {
+				active = append(active, p)
+			}
+		}
+		plugins.Unlock()
+
+		if len(active) == 0 {
+			return mux, nil
+		}
+		sort.Sort(byOrder(active))
+
+		childMux := http.NewServeMux()
+		for _, p := range active {
+			for path, h := range p.Handlers {
+				log.Debugf("http plugin %s: serving %s on %s", p.Name, path, server)
+				childMux.Handle(path, h)
+			}
+		}
+
+		var handler http.Handler = childMux
+		for i := len(active) - 1; i >= 0; i-- {
+			if active[i].Middleware != nil {
+				handler = active[i].Middleware(n, handler)
+			}
+		}
+
+		mux.Handle("/", handler)
+		return childMux, nil
+	}
+}
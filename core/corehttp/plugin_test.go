@@ -0,0 +1,97 @@
+package corehttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	core "github.com/ipfs/go-ipfs/core"
+)
+
+func resetPlugins() {
+	plugins.Lock()
+	plugins.list = nil
+	plugins.Unlock()
+}
+
+func TestRegisterHTTPPluginConflicts(t *testing.T) {
+	defer resetPlugins()
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	err := RegisterHTTPPlugin(HTTPPlugin{
+		Name:     "a",
+		Servers:  []string{PluginServerAPI},
+		Handlers: map[string]http.Handler{"/custom/": ok},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = RegisterHTTPPlugin(HTTPPlugin{
+		Name:     "b",
+		Servers:  []string{PluginServerAPI, PluginServerGateway},
+		Handlers: map[string]http.Handler{"/custom/": ok},
+	})
+	if err == nil {
+		t.Fatal("expected a path conflict on the api server")
+	}
+
+	err = RegisterHTTPPlugin(HTTPPlugin{
+		Name:     "b",
+		Servers:  []string{PluginServerGateway},
+		Handlers: map[string]http.Handler{"/custom/": ok},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RegisterHTTPPlugin(HTTPPlugin{Name: "a", Servers: []string{PluginServerGateway}}); err == nil {
+		t.Fatal("expected a duplicate name to be rejected")
+	}
+
+	// conflicts with handlers of go-ipfs surface when building the server
+	_, err = makeHandler(nil, nil, PluginOption(PluginServerAPI), RedirectOption("custom", "/"))
+	if err == nil {
+		t.Fatal("expected conflicting handlers to fail")
+	}
+}
+
+func TestHTTPPluginMiddlewareOrder(t *testing.T) {
+	defer resetPlugins()
+
+	var order []string
+	middleware := func(name string) func(*core.IpfsNode, http.Handler) http.Handler {
+		return func(_ *core.IpfsNode, next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	for i, name := range []string{"second", "first"} {
+		err := RegisterHTTPPlugin(HTTPPlugin{
+			Name:       name,
+			Servers:    []string{PluginServerAPI},
+			Order:      1 - i,
+			Middleware: middleware(name),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h, err := makeHandler(nil, nil, PluginOption(PluginServerAPI), RedirectOption("webui", "/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/webui/", nil))
+	if rec.Code != http.StatusFound {
+		t.Fatalf("expected the request to reach the redirect, got %d", rec.Code)
+	}
+	if len(order) != 2 || order[0] != "first" || order[1] != "second" {
+		t.Fatalf("unexpected middleware order: %v", order)
+	}
+}
@@ -0,0 +1,498 @@
+package corehttp
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	commands "github.com/ipfs/go-ipfs/commands"
+	files "github.com/ipfs/go-ipfs/commands/files"
+	cmdsHttp "github.com/ipfs/go-ipfs/commands/http"
+	core "github.com/ipfs/go-ipfs/core"
+	coreunix "github.com/ipfs/go-ipfs/core/coreunix"
+	chunk "github.com/ipfs/go-ipfs/importer/chunk"
+)
+
+// UploadPath is the endpoint of the resumable uploads of the API.
+const UploadPath = cmdsHttp.ApiPath + "/upload"
+
+// UploadsDir is the directory of the repo holding the uploads in progress.
+const UploadsDir = "uploads"
+
+// uploadOffsetHeader is the number of bytes of an upload received so far.
+const uploadOffsetHeader = "Upload-Offset"
+
+// uploadExpiry is how long an upload no chunk was written to is kept.
+const uploadExpiry = 24 * time.Hour
+
+// uploadExpireInterval is how often expired uploads are removed.
+const uploadExpireInterval = time.Hour
+
+// Upload is the state of a resumable upload. Length is -1 until a chunk
+// gives the total size.
+type Upload struct {
+	ID        string
+	Name      string
+	Offset    int64
+	Length    int64
+	Pin       bool
+	RawLeaves bool
+	Chunker   string `json:",omitempty"`
+	Updated   time.Time
+}
+
+// UploadOption serves resumable uploads of files to add at UploadPath, for
+// the clients whose connections drop to resume where they stopped instead of
+// sending a whole file again. The chunks in progress are kept in the uploads
+// directory of the repo:
+//
+//	POST   /api/v0/upload?name=<name>&pin=<bool>&raw-leaves=<bool>&chunker=<chunker>
+//	       starts an upload, answering 201 Created with its Location
+//	PUT    /api/v0/upload/<id> with a Content-Range of 'bytes <first>-<last>/<total>'
+//	       writes the chunk starting at the offset of the upload. Once the
+//	       total is received, the file is added and described as 'ipfs add'
+//	       does. The total may be '*' until the last chunk.
+//	GET    /api/v0/upload/<id>
+//	       describes the upload, with its offset
+//	DELETE /api/v0/upload/<id>
+//	       abandons the upload
+//
+// The offset of an upload is also given in the Upload-Offset header of the
+// responses. Chunks not starting at it are refused with 409 Conflict.
+func UploadOption(cctx commands.Context) ServeOption {
+	return func(n *core.IpfsNode, l net.Listener, mux *http.ServeMux) (*http.ServeMux, error) {
+		cfg, err := apiServerConfig(n, l)
+		if err != nil {
+			return nil, err
+		}
+		cfg.SetAllowedMethods("GET", "POST", "PUT", "DELETE")
+		cfg.SetAllowedHeaders("Content-Type", "Content-Range")
+
+		h, err := newUploadHandler(n, filepath.Join(cctx.ConfigRoot, UploadsDir))
+		if err != nil {
+			return nil, err
+		}
+		go h.expireLoop(n.Context())
+
+		guarded := cmdsHttp.Guard(cfg, h)
+		mux.Handle(UploadPath, guarded)
+		mux.Handle(UploadPath+"/", guarded)
+		return mux, nil
+	}
+}
+
+type uploadHandler struct {
+	node *core.IpfsNode
+	dir  string
+
+	mu sync.Mutex
+	// busy are the uploads a request is writing to
+	busy map[string]bool
+}
+
+func newUploadHandler(n *core.IpfsNode, dir string) (*uploadHandler, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &uploadHandler{
+		node: n,
+		dir:  dir,
+		busy: make(map[string]bool),
+	}, nil
+}
+
+func (h *uploadHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Expose-Headers", "Location, "+uploadOffsetHeader)
+
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, UploadPath), "/")
+	if id == "" {
+		if r.Method != "POST" {
+			uploadError(w, errors.New("uploads are started with POST"), http.StatusMethodNotAllowed)
+			return
+		}
+		h.start(w, r)
+		return
+	}
+
+	if !validUploadID(id) {
+		uploadError(w, errors.New("no such upload"), http.StatusNotFound)
+		return
+	}
+	switch r.Method {
+	case "GET", "HEAD":
+		u, err := h.load(id)
+		if err != nil {
+			uploadError(w, err, uploadErrorStatus(err))
+			return
+		}
+		uploadJSON(w, u, http.StatusOK)
+	case "PUT":
+		h.write(w, r, id)
+	case "DELETE":
+		if !h.acquire(id) {
+			uploadError(w, errors.New("a chunk of the upload is being written"), http.StatusConflict)
+			return
+		}
+		defer h.release(id)
+		if _, err := h.load(id); err != nil {
+			uploadError(w, err, uploadErrorStatus(err))
+			return
+		}
+		h.remove(id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		uploadError(w, fmt.Errorf("method %s not allowed", r.Method), http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *uploadHandler) start(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	u := &Upload{
+		Name:    q.Get("name"),
+		Length:  -1,
+		Pin:     q.Get("pin") != "false",
+		Chunker: q.Get("chunker"),
+		Updated: time.Now(),
+	}
+	if u.Name == "" {
+		u.Name = "upload"
+	}
+	if u.Name != filepath.Base(u.Name) {
+		uploadError(w, fmt.Errorf("invalid name %q", u.Name), http.StatusBadRequest)
+		return
+	}
+	if s := q.Get("raw-leaves"); s != "" {
+		v, err := strconv.ParseBool(s)
+		if err != nil {
+			uploadError(w, fmt.Errorf("invalid raw-leaves %q", s), http.StatusBadRequest)
+			return
+		}
+		u.RawLeaves = v
+	}
+	if _, err := chunk.FromString(strings.NewReader(""), u.Chunker); err != nil {
+		uploadError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		uploadError(w, err, http.StatusInternalServerError)
+		return
+	}
+	u.ID = hex.EncodeToString(b)
+
+	f, err := os.OpenFile(h.partPath(u.ID), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		uploadError(w, err, http.StatusInternalServerError)
+		return
+	}
+	f.Close()
+	if err := h.save(u); err != nil {
+		h.remove(u.ID)
+		uploadError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Location", UploadPath+"/"+u.ID)
+	uploadJSON(w, u, http.StatusCreated)
+}
+
+// write appends a chunk to the upload, and adds the file once it is
+// complete. Bytes of a chunk received before the connection dropped are
+// kept.
+func (h *uploadHandler) write(w http.ResponseWriter, r *http.Request, id string) {
+	if !h.acquire(id) {
+		uploadError(w, errors.New("a chunk of the upload is being written"), http.StatusConflict)
+		return
+	}
+	defer h.release(id)
+
+	u, err := h.load(id)
+	if err != nil {
+		uploadError(w, err, uploadErrorStatus(err))
+		return
+	}
+
+	first, last, total, err := parseContentRange(r.Header.Get("Content-Range"))
+	if err != nil {
+		uploadError(w, err, http.StatusBadRequest)
+		return
+	}
+	if total >= 0 {
+		if u.Length >= 0 && total != u.Length {
+			uploadError(w, fmt.Errorf("the total size of the upload is %d, not %d", u.Length, total), http.StatusBadRequest)
+			return
+		}
+		if last >= total || total < u.Offset {
+			uploadError(w, fmt.Errorf("the chunk ends past the total size %d", total), http.StatusBadRequest)
+			return
+		}
+		u.Length = total
+	}
+
+	if first >= 0 {
+		if first != u.Offset {
+			w.Header().Set(uploadOffsetHeader, strconv.FormatInt(u.Offset, 10))
+			uploadError(w, fmt.Errorf("the upload continues at offset %d, not %d", u.Offset, first), http.StatusConflict)
+			return
+		}
+
+		f, err := os.OpenFile(h.partPath(id), os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			uploadError(w, err, http.StatusInternalServerError)
+			return
+		}
+		written, copyErr := io.CopyN(f, r.Body, last-first+1)
+		err = f.Sync()
+		f.Close()
+		u.Offset += written
+		u.Updated = time.Now()
+		if err == nil {
+			err = h.save(u)
+		}
+		if err != nil {
+			uploadError(w, err, http.StatusInternalServerError)
+			return
+		}
+		if copyErr != nil {
+			w.Header().Set(uploadOffsetHeader, strconv.FormatInt(u.Offset, 10))
+			uploadError(w, fmt.Errorf("the chunk was cut short: %s", copyErr), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if u.Length < 0 || u.Offset < u.Length {
+		// a chunk of no bytes may give the total size
+		if first < 0 {
+			if err := h.save(u); err != nil {
+				uploadError(w, err, http.StatusInternalServerError)
+				return
+			}
+		}
+		uploadJSON(w, u, http.StatusAccepted)
+		return
+	}
+
+	// an add interrupted is done again by a chunk of no bytes
+	added, err := h.add(r.Context(), u)
+	if err != nil {
+		uploadError(w, fmt.Errorf("failed to add the upload: %s", err), http.StatusInternalServerError)
+		return
+	}
+	h.remove(id)
+	w.Header().Set(uploadOffsetHeader, strconv.FormatInt(u.Offset, 10))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(added)
+}
+
+// add adds the file of a complete upload, as 'ipfs add' does.
+func (h *uploadHandler) add(ctx context.Context, u *Upload) (*coreunix.AddedObject, error) {
+	n := h.node
+	f, err := os.Open(h.partPath(u.ID))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	defer n.Blockstore.PinLock().Unlock()
+
+	adder, err := coreunix.NewAdder(ctx, n.Pinning, n.Blockstore, n.DAG)
+	if err != nil {
+		return nil, err
+	}
+	adder.Pin = u.Pin
+	adder.RawLeaves = u.RawLeaves
+	adder.Chunker = u.Chunker
+	adder.Repo = n.Repo
+	adder.Events = n.Events
+
+	if err := adder.AddFile(files.NewReaderFile(u.Name, u.Name, f, nil)); err != nil {
+		return nil, err
+	}
+	root, err := adder.Finalize()
+	if err != nil {
+		return nil, err
+	}
+	if u.Pin {
+		if err := adder.PinRoot(); err != nil {
+			return nil, err
+		}
+	}
+	return &coreunix.AddedObject{
+		Name:  u.Name,
+		Hash:  root.Cid().String(),
+		Bytes: u.Length,
+	}, nil
+}
+
+func (h *uploadHandler) acquire(id string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.busy[id] {
+		return false
+	}
+	h.busy[id] = true
+	return true
+}
+
+func (h *uploadHandler) release(id string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.busy, id)
+}
+
+func (h *uploadHandler) partPath(id string) string {
+	return filepath.Join(h.dir, id+".part")
+}
+
+func (h *uploadHandler) metaPath(id string) string {
+	return filepath.Join(h.dir, id+".json")
+}
+
+// load reads the state of an upload. The offset is the size of the data
+// received, which was synced before the state was saved.
+func (h *uploadHandler) load(id string) (*Upload, error) {
+	b, err := ioutil.ReadFile(h.metaPath(id))
+	if err != nil {
+		return nil, err
+	}
+	u := new(Upload)
+	if err := json.Unmarshal(b, u); err != nil {
+		return nil, err
+	}
+	st, err := os.Stat(h.partPath(id))
+	if err != nil {
+		return nil, err
+	}
+	u.Offset = st.Size()
+	return u, nil
+}
+
+func (h *uploadHandler) save(u *Upload) error {
+	b, err := json.Marshal(u)
+	if err != nil {
+		return err
+	}
+	tmp := h.metaPath(u.ID) + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, h.metaPath(u.ID))
+}
+
+func (h *uploadHandler) remove(id string) {
+	os.Remove(h.partPath(id))
+	os.Remove(h.metaPath(id))
+}
+
+func (h *uploadHandler) expireLoop(ctx context.Context) {
+	t := time.NewTicker(uploadExpireInterval)
+	defer t.Stop()
+	for {
+		h.expire(time.Now().Add(-uploadExpiry))
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// expire removes the uploads not written to since before.
+func (h *uploadHandler) expire(before time.Time) {
+	metas, err := filepath.Glob(filepath.Join(h.dir, "*.json"))
+	if err != nil {
+		return
+	}
+	for _, m := range metas {
+		id := strings.TrimSuffix(filepath.Base(m), ".json")
+		if !h.acquire(id) {
+			continue
+		}
+		if u, err := h.load(id); err != nil || u.Updated.Before(before) {
+			log.Infof("removing expired upload %s", id)
+			h.remove(id)
+		}
+		h.release(id)
+	}
+}
+
+func validUploadID(id string) bool {
+	if len(id) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
+
+// parseContentRange parses 'bytes <first>-<last>/<total>', where total may
+// be '*', and 'bytes */<total>'. Unknown values are -1.
+func parseContentRange(s string) (first, last, total int64, err error) {
+	first, last, total = -1, -1, -1
+	if !strings.HasPrefix(s, "bytes ") {
+		return first, last, total, fmt.Errorf("invalid Content-Range %q, expected 'bytes <first>-<last>/<total>'", s)
+	}
+	parts := strings.SplitN(strings.TrimPrefix(s, "bytes "), "/", 2)
+	if len(parts) != 2 {
+		return first, last, total, fmt.Errorf("invalid Content-Range %q, expected 'bytes <first>-<last>/<total>'", s)
+	}
+	if parts[1] != "*" {
+		total, err = strconv.ParseInt(parts[1], 10, 64)
+		if err != nil || total < 0 {
+			return -1, -1, -1, fmt.Errorf("invalid total size in Content-Range %q", s)
+		}
+	}
+	if parts[0] == "*" {
+		if total < 0 {
+			return -1, -1, -1, fmt.Errorf("invalid Content-Range %q: no range and no total size", s)
+		}
+		return first, last, total, nil
+	}
+	bounds := strings.SplitN(parts[0], "-", 2)
+	if len(bounds) != 2 {
+		return -1, -1, -1, fmt.Errorf("invalid range in Content-Range %q", s)
+	}
+	first, err = strconv.ParseInt(bounds[0], 10, 64)
+	if err == nil {
+		last, err = strconv.ParseInt(bounds[1], 10, 64)
+	}
+	if err != nil || first < 0 || last < first {
+		return -1, -1, -1, fmt.Errorf("invalid range in Content-Range %q", s)
+	}
+	return first, last, total, nil
+}
+
+func uploadErrorStatus(err error) int {
+	if os.IsNotExist(err) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+func uploadError(w http.ResponseWriter, err error, status int) {
+	if os.IsNotExist(err) {
+		err = errors.New("no such upload")
+	}
+	http.Error(w, err.Error(), status)
+}
+
+func uploadJSON(w http.ResponseWriter, u *Upload, status int) {
+	w.Header().Set(uploadOffsetHeader, strconv.FormatInt(u.Offset, 10))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(u)
+}
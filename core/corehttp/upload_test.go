@@ -0,0 +1,127 @@
+package corehttp
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	commands "github.com/ipfs/go-ipfs/commands"
+	coreunix "github.com/ipfs/go-ipfs/core/coreunix"
+)
+
+func TestParseContentRange(t *testing.T) {
+	cases := map[string][3]int64{
+		"bytes 0-4/10":  {0, 4, 10},
+		"bytes 5-9/*":   {5, 9, -1},
+		"bytes */10":    {-1, -1, 10},
+		"bytes 0-0/1":   {0, 0, 1},
+		"bytes 100-1/*": {},
+		"bytes */*":     {},
+		"bytes 0-4":     {},
+		"items 0-4/10":  {},
+	}
+	for s, expected := range cases {
+		first, last, total, err := parseContentRange(s)
+		if expected == ([3]int64{}) {
+			if err == nil {
+				t.Errorf("expected %q to be invalid", s)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %s", s, err)
+			continue
+		}
+		if got := [3]int64{first, last, total}; got != expected {
+			t.Errorf("%q: expected %v, got %v", s, expected, got)
+		}
+	}
+}
+
+func TestResumableUpload(t *testing.T) {
+	n, err := newNodeWithMockNamesys(mockNamesys{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "uploads")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	dh := &delegatedHandler{}
+	ts := httptest.NewServer(dh)
+	defer ts.Close()
+	dh.Handler, err = makeHandler(n, ts.Listener, UploadOption(commands.Context{ConfigRoot: dir}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := http.Post(ts.URL+UploadPath+"?name=hello.txt", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("expected the upload to be created, got %d", res.StatusCode)
+	}
+	location := ts.URL + res.Header.Get("Location")
+
+	put := func(contentRange, body string) *http.Response {
+		req, err := http.NewRequest("PUT", location, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Range", contentRange)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	res = put("bytes 0-5/12", "hello ")
+	res.Body.Close()
+	if res.StatusCode != http.StatusAccepted || res.Header.Get(uploadOffsetHeader) != "6" {
+		t.Fatalf("expected the chunk to be accepted at offset 6, got %d at %s", res.StatusCode, res.Header.Get(uploadOffsetHeader))
+	}
+
+	// the chunk is sent again, as after a lost response
+	res = put("bytes 0-5/12", "hello ")
+	res.Body.Close()
+	if res.StatusCode != http.StatusConflict || res.Header.Get(uploadOffsetHeader) != "6" {
+		t.Fatalf("expected the chunk to conflict at offset 6, got %d at %s", res.StatusCode, res.Header.Get(uploadOffsetHeader))
+	}
+
+	res = put("bytes 6-11/12", "world\n")
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("expected the upload to be added, got %d", res.StatusCode)
+	}
+	var added coreunix.AddedObject
+	if err := json.NewDecoder(res.Body).Decode(&added); err != nil {
+		t.Fatal(err)
+	}
+
+	expected, err := coreunix.Add(n, strings.NewReader("hello world\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added.Hash != expected || added.Name != "hello.txt" || added.Bytes != 12 {
+		t.Fatalf("expected %s to be added, got %+v", expected, added)
+	}
+
+	// the upload is gone once added
+	res, err = http.Get(location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected the upload to be removed, got %d", res.StatusCode)
+	}
+}
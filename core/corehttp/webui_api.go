@@ -0,0 +1,445 @@
+package corehttp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	gopath "path"
+	"sort"
+	"strconv"
+	"strings"
+
+	core "github.com/ipfs/go-ipfs/core"
+	corerepo "github.com/ipfs/go-ipfs/core/corerepo"
+	mfs "github.com/ipfs/go-ipfs/mfs"
+	config "github.com/ipfs/go-ipfs/repo/config"
+
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+	ma "gx/ipfs/QmcyqRMCAXVtYPS4DiBrA7sezL9rRGfW8Ctx7cywL4TXJj/go-multiaddr"
+)
+
+// WebUIAPIPath is the prefix of the stable endpoints serving the WebUI. They
+// expose only what the WebUI needs, so that the general command API can be
+// locked down without breaking it.
+const WebUIAPIPath = "/api/webui/v0"
+
+// defaultWebUIPageSize is the number of pins returned when no limit is given.
+const defaultWebUIPageSize = 100
+
+var errWebUIReadOnly = errors.New("the WebUI API is read-only, set API.WebUIWritable to allow changes")
+
+// WebUIPeer describes a connected peer, with enough address information to
+// place it on a map.
+type WebUIPeer struct {
+	ID      string
+	Addr    string
+	IP      string
+	Latency string
+}
+
+// WebUIPin is a single entry of the pin list.
+type WebUIPin struct {
+	Cid  string
+	Type string
+}
+
+// WebUIPinPage is a page of the pin list.
+type WebUIPinPage struct {
+	Pins   []WebUIPin
+	Offset int
+	Total  int
+}
+
+// WebUIFile describes an entry of an MFS directory. MimeType and
+// Previewable let the WebUI decide whether to request a thumbnail.
+type WebUIFile struct {
+	Name        string
+	Type        string
+	Size        int64
+	Hash        string
+	MimeType    string
+	Previewable bool
+}
+
+// WebUIDirectory is the listing of an MFS directory.
+type WebUIDirectory struct {
+	Path    string
+	Entries []WebUIFile
+}
+
+// WebUIAPIOption serves the WebUI endpoints. Unless writable is set, requests
+// changing pins or the config are refused.
+func WebUIAPIOption(writable bool) ServeOption {
+	return func(n *core.IpfsNode, _ net.Listener, mux *http.ServeMux) (*http.ServeMux, error) {
+		h := &webuiAPIHandler{node: n, writable: writable}
+
+		mux.HandleFunc(WebUIAPIPath+"/peers", h.get(h.peers))
+		mux.HandleFunc(WebUIAPIPath+"/pins", h.get(h.pins))
+		mux.HandleFunc(WebUIAPIPath+"/pins/add", h.post(h.pinAdd))
+		mux.HandleFunc(WebUIAPIPath+"/pins/rm", h.post(h.pinRm))
+		mux.HandleFunc(WebUIAPIPath+"/files", h.get(h.files))
+		mux.HandleFunc(WebUIAPIPath+"/config", h.config)
+		return mux, nil
+	}
+}
+
+type webuiAPIHandler struct {
+	node     *core.IpfsNode
+	writable bool
+}
+
+type webuiHandlerFunc func(r *http.Request) (interface{}, int, error)
+
+func (h *webuiAPIHandler) get(f webuiHandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			webuiError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+			return
+		}
+		h.serve(w, r, f)
+	}
+}
+
+func (h *webuiAPIHandler) post(f webuiHandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			webuiError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+			return
+		}
+		if err := h.checkWrite(r); err != nil {
+			webuiError(w, http.StatusForbidden, err)
+			return
+		}
+		h.serve(w, r, f)
+	}
+}
+
+func (h *webuiAPIHandler) config(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		h.serve(w, r, h.getConfig)
+	case "POST", "PUT":
+		if err := h.checkWrite(r); err != nil {
+			webuiError(w, http.StatusForbidden, err)
+			return
+		}
+		h.serve(w, r, h.setConfig)
+	default:
+		webuiError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+	}
+}
+
+func (h *webuiAPIHandler) serve(w http.ResponseWriter, r *http.Request, f webuiHandlerFunc) {
+	out, code, err := f(r)
+	if err != nil {
+		webuiError(w, code, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(out); err != nil {
+		log.Debugf("webui api: failed to write response: %s", err)
+	}
+}
+
+// checkWrite refuses changes unless the write scope is enabled, and only
+// accepts them from the origin serving the API, as the WebUI does.
+func (h *webuiAPIHandler) checkWrite(r *http.Request) error {
+	if !h.writable {
+		return errWebUIReadOnly
+	}
+
+	if origin := r.Header.Get("Origin"); origin != "" {
+		u, err := url.Parse(origin)
+		if err != nil || u.Host != r.Host {
+			return fmt.Errorf("cross-origin request from %q refused", origin)
+		}
+	}
+	return nil
+}
+
+func (h *webuiAPIHandler) peers(r *http.Request) (interface{}, int, error) {
+	n := h.node
+	if n.PeerHost == nil {
+		return nil, http.StatusServiceUnavailable, errors.New("node is not online")
+	}
+
+	out := []WebUIPeer{}
+	for _, c := range n.PeerHost.Network().Conns() {
+		pid := c.RemotePeer()
+		addr := c.RemoteMultiaddr()
+
+		p := WebUIPeer{
+			ID:   pid.Pretty(),
+			Addr: addr.String(),
+			IP:   multiaddrIP(addr),
+		}
+		if lat := n.Peerstore.LatencyEWMA(pid); lat != 0 {
+			p.Latency = lat.String()
+		}
+		out = append(out, p)
+	}
+
+	sort.Sort(webuiPeers(out))
+	return out, 0, nil
+}
+
+// multiaddrIP returns the IP address found in addr, if any.
+func multiaddrIP(addr ma.Multiaddr) string {
+	parts := strings.Split(addr.String(), "/")
+	for i := 1; i+1 < len(parts); i++ {
+		if parts[i] == "ip4" || parts[i] == "ip6" {
+			return parts[i+1]
+		}
+	}
+	return ""
+}
+
+type webuiPeers []WebUIPeer
+
+func (p webuiPeers) Len() int           { return len(p) }
+func (p webuiPeers) Less(i, j int) bool { return p[i].ID < p[j].ID }
+func (p webuiPeers) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+
+func (h *webuiAPIHandler) pins(r *http.Request) (interface{}, int, error) {
+	q := r.URL.Query()
+
+	offset, err := queryInt(q, "offset", 0)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	limit, err := queryInt(q, "limit", defaultWebUIPageSize)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	typ := q.Get("type")
+	if typ == "" {
+		typ = "all"
+	}
+
+	var all []WebUIPin
+	add := func(keys []*cid.Cid, typ string) {
+		for _, k := range keys {
+			all = append(all, WebUIPin{Cid: k.String(), Type: typ})
+		}
+	}
+
+	switch typ {
+	case "all":
+		add(h.node.Pinning.RecursiveKeys(), "recursive")
+		add(h.node.Pinning.DirectKeys(), "direct")
+	case "recursive":
+		add(h.node.Pinning.RecursiveKeys(), "recursive")
+	case "direct":
+		add(h.node.Pinning.DirectKeys(), "direct")
+	default:
+		return nil, http.StatusBadRequest, fmt.Errorf("invalid pin type %q, must be one of {direct, recursive, all}", typ)
+	}
+
+	// sort so that pages are stable across requests
+	sort.Sort(webuiPins(all))
+
+	page := &WebUIPinPage{Pins: []WebUIPin{}, Offset: offset, Total: len(all)}
+	if offset < len(all) {
+		end := offset + limit
+		if end > len(all) {
+			end = len(all)
+		}
+		page.Pins = all[offset:end]
+	}
+	return page, 0, nil
+}
+
+type webuiPins []WebUIPin
+
+func (p webuiPins) Len() int           { return len(p) }
+func (p webuiPins) Less(i, j int) bool { return p[i].Cid < p[j].Cid }
+func (p webuiPins) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+
+func (h *webuiAPIHandler) pinAdd(r *http.Request) (interface{}, int, error) {
+	return h.changePin(r, corerepo.Pin)
+}
+
+func (h *webuiAPIHandler) pinRm(r *http.Request) (interface{}, int, error) {
+	return h.changePin(r, corerepo.Unpin)
+}
+
+type pinFunc func(*core.IpfsNode, context.Context, []string, bool) ([]*cid.Cid, error)
+
+func (h *webuiAPIHandler) changePin(r *http.Request, f pinFunc) (interface{}, int, error) {
+	q := r.URL.Query()
+	paths := q["arg"]
+	if len(paths) == 0 {
+		return nil, http.StatusBadRequest, errors.New("argument \"arg\" is required")
+	}
+
+	recursive := true
+	if v := q.Get("recursive"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, http.StatusBadRequest, fmt.Errorf("invalid value for recursive: %q", v)
+		}
+		recursive = b
+	}
+
+	cids, err := f(h.node, r.Context(), paths, recursive)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	typ := "direct"
+	if recursive {
+		typ = "recursive"
+	}
+
+	out := make([]WebUIPin, 0, len(cids))
+	for _, c := range cids {
+		out = append(out, WebUIPin{Cid: c.String(), Type: typ})
+	}
+	return out, 0, nil
+}
+
+func (h *webuiAPIHandler) files(r *http.Request) (interface{}, int, error) {
+	p := r.URL.Query().Get("path")
+	if p == "" {
+		p = "/"
+	}
+	if !strings.HasPrefix(p, "/") {
+		return nil, http.StatusBadRequest, fmt.Errorf("paths must start with a leading slash")
+	}
+
+	fsn, err := mfs.Lookup(h.node.FilesRoot, p)
+	if err != nil {
+		return nil, http.StatusNotFound, err
+	}
+
+	dir, ok := fsn.(*mfs.Directory)
+	if !ok {
+		return nil, http.StatusBadRequest, fmt.Errorf("%s is not a directory", p)
+	}
+
+	listing, err := dir.List(r.Context())
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	out := &WebUIDirectory{Path: p, Entries: []WebUIFile{}}
+	for _, l := range listing {
+		f := WebUIFile{
+			Name: l.Name,
+			Size: l.Size,
+			Hash: l.Hash,
+		}
+
+		if mfs.NodeType(l.Type) == mfs.TDir {
+			f.Type = "directory"
+		} else {
+			f.Type = "file"
+			f.MimeType = mime.TypeByExtension(gopath.Ext(l.Name))
+			f.Previewable = strings.HasPrefix(f.MimeType, "image/")
+		}
+		out.Entries = append(out.Entries, f)
+	}
+	return out, 0, nil
+}
+
+func (h *webuiAPIHandler) getConfig(r *http.Request) (interface{}, int, error) {
+	cfg, err := h.node.Repo.Config()
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	out, err := config.WithoutSecrets(cfg)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return out, 0, nil
+}
+
+func (h *webuiAPIHandler) setConfig(r *http.Request) (interface{}, int, error) {
+	var cfg config.Config
+	if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("failed to decode config: %s", err)
+	}
+
+	if cfg.Identity.PrivKey != "" {
+		return nil, http.StatusBadRequest, errors.New("setting private key with API is not supported")
+	}
+
+	cur, err := h.node.Repo.Config()
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	if cfg.Identity.PeerID != cur.Identity.PeerID {
+		return nil, http.StatusBadRequest, errors.New("changing the node identity with API is not supported")
+	}
+
+	if err := validateConfig(&cfg); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	updated, err := config.KeepSecrets(&cfg, cur)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	if err := h.node.Repo.SetConfig(updated); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	return h.getConfig(r)
+}
+
+// validateConfig checks the parts of cfg which would otherwise only fail on
+// the next daemon start.
+func validateConfig(cfg *config.Config) error {
+	addrs := append([]string{}, cfg.Addresses.Swarm...)
+	if cfg.Addresses.API != "" {
+		addrs = append(addrs, cfg.Addresses.API)
+	}
+	if cfg.Addresses.Gateway != "" {
+		addrs = append(addrs, cfg.Addresses.Gateway)
+	}
+
+	for _, a := range addrs {
+		if _, err := ma.NewMultiaddr(a); err != nil {
+			return fmt.Errorf("invalid address %q: %s", a, err)
+		}
+	}
+
+	if _, err := cfg.BootstrapPeers(); err != nil {
+		return fmt.Errorf("invalid bootstrap peers: %s", err)
+	}
+
+	return nil
+}
+
+func queryInt(q url.Values, key string, def int) (int, error) {
+	v := q.Get(key)
+	if v == "" {
+		return def, nil
+	}
+
+	i, err := strconv.Atoi(v)
+	if err != nil || i < 0 {
+		return 0, fmt.Errorf("invalid value for %s: %q", key, v)
+	}
+	return i, nil
+}
+
+func webuiError(w http.ResponseWriter, code int, err error) {
+	if code == 0 {
+		code = http.StatusInternalServerError
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(struct{ Message string }{err.Error()})
+}
@@ -0,0 +1,44 @@
+package corehttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWebUIAPIWriteScope(t *testing.T) {
+	mux := http.NewServeMux()
+	if _, err := WebUIAPIOption(false)(nil, nil, mux); err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest("POST", WebUIAPIPath+"/pins/add?arg=QmatmE9msSfkKxoffpHwNLNKgwZG8eT9Bud6YoPab52vpy", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected writes to be refused, got %d", rec.Code)
+	}
+}
+
+func TestWebUIAPICheckOrigin(t *testing.T) {
+	h := &webuiAPIHandler{writable: true}
+
+	req, err := http.NewRequest("POST", "http://127.0.0.1:5001"+WebUIAPIPath+"/pins/rm", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header.Set("Origin", "http://127.0.0.1:5001")
+	if err := h.checkWrite(req); err != nil {
+		t.Fatalf("same origin request refused: %s", err)
+	}
+
+	req.Header.Set("Origin", "http://evil.example.com")
+	if err := h.checkWrite(req); err == nil {
+		t.Fatal("expected cross-origin request to be refused")
+	}
+}
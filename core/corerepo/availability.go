@@ -0,0 +1,187 @@
+package corerepo
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/ipfs/go-ipfs/core"
+	bitswap "github.com/ipfs/go-ipfs/exchange/bitswap"
+
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+// availabilityReplicas is the number of providers sending each sampled block
+// for which content scores as fully available.
+const availabilityReplicas = 3
+
+// providerSearchTimeout bounds the search of the providers of the root.
+const providerSearchTimeout = time.Minute
+
+// ProbeOptions are the options of ProbeAvailability.
+type ProbeOptions struct {
+	Providers int           // providers probed at most
+	Depth     int           // depth of the blocks sampled under the root
+	Samples   int           // blocks sampled at each depth below the root
+	Timeout   time.Duration // time a provider has to send the sampled blocks
+}
+
+// ProbedProvider is the answer of a provider to the probe.
+type ProbedProvider struct {
+	ID      string
+	Fetched int // sampled blocks sent
+	// Latency is the time the first block took to arrive.
+	Latency time.Duration
+}
+
+// SampledBlock is a block fetched from the providers.
+type SampledBlock struct {
+	Cid       string
+	Depth     int
+	Providers int // providers which sent it
+}
+
+// Availability is the result of ProbeAvailability.
+type Availability struct {
+	Root      string
+	Providers []ProbedProvider
+	Samples   []SampledBlock
+	// Score is 1 when each sampled block was sent by at least
+	// availabilityReplicas providers, and 0 when none was sent at all.
+	Score float64
+}
+
+// Responsive returns the number of providers which sent at least a block.
+func (a *Availability) Responsive() int {
+	n := 0
+	for _, p := range a.Providers {
+		if p.Fetched > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// ProbeAvailability tells whether other peers can fetch the dag under root:
+// it finds the providers of root in the routing system, and asks each of
+// them alone for root and blocks sampled at random at each depth under it,
+// the links being followed in the local dag.
+func ProbeAvailability(ctx context.Context, n *core.IpfsNode, root *cid.Cid, opts ProbeOptions) (*Availability, error) {
+	bs, ok := n.Exchange.(*bitswap.Bitswap)
+	if !ok {
+		return nil, errors.New("probing the availability needs the node to fetch the blocks with bitswap")
+	}
+
+	samples, err := sampleDag(ctx, n, root, opts.Depth, opts.Samples)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]*cid.Cid, len(samples))
+	for i, s := range samples {
+		keys[i] = s.c
+	}
+
+	sctx, cancel := context.WithTimeout(ctx, providerSearchTimeout)
+	var providers []peer.ID
+	for pi := range n.Routing.FindProvidersAsync(sctx, root, opts.Providers+1) {
+		if pi.ID != n.Identity && len(providers) < opts.Providers {
+			providers = append(providers, pi.ID)
+		}
+	}
+	cancel()
+
+	a := &Availability{Root: root.String()}
+	senders := make(map[string]int)
+	// the providers are asked one after the other, as a block is taken from
+	// any peer sending it while it is wanted
+	for _, p := range providers {
+		pp := probeProvider(ctx, bs, p, keys, opts.Timeout)
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		for _, c := range pp.sent {
+			senders[c.KeyString()]++
+		}
+		a.Providers = append(a.Providers, pp.ProbedProvider)
+	}
+
+	for _, s := range samples {
+		sent := senders[s.c.KeyString()]
+		a.Samples = append(a.Samples, SampledBlock{Cid: s.c.String(), Depth: s.depth, Providers: sent})
+		if sent > availabilityReplicas {
+			sent = availabilityReplicas
+		}
+		a.Score += float64(sent) / availabilityReplicas / float64(len(samples))
+	}
+	return a, nil
+}
+
+type sample struct {
+	c     *cid.Cid
+	depth int
+}
+
+// sampleDag returns root, and up to count blocks picked at random among the
+// children of the blocks picked a level up, down to depth. A block linked
+// at several depths is only picked once.
+func sampleDag(ctx context.Context, n *core.IpfsNode, root *cid.Cid, depth, count int) ([]sample, error) {
+	samples := []sample{{c: root}}
+	seen := cid.NewSet()
+	seen.Add(root)
+	level := []*cid.Cid{root}
+	for d := 1; d <= depth && len(level) > 0; d++ {
+		var children []*cid.Cid
+		for opt := range n.DAG.GetMany(ctx, level) {
+			if opt.Err != nil {
+				return nil, opt.Err
+			}
+			for _, l := range opt.Node.Links() {
+				if seen.Visit(l.Cid) {
+					children = append(children, l.Cid)
+				}
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		level = nil
+		for _, i := range rand.Perm(len(children)) {
+			if len(level) == count {
+				break
+			}
+			level = append(level, children[i])
+			samples = append(samples, sample{c: children[i], depth: d})
+		}
+	}
+	return samples, nil
+}
+
+type probedProvider struct {
+	ProbedProvider
+	sent []*cid.Cid
+}
+
+// probeProvider asks p alone for keys, and returns the ones it sent within
+// timeout.
+func probeProvider(ctx context.Context, bs *bitswap.Bitswap, p peer.ID, keys []*cid.Cid, timeout time.Duration) probedProvider {
+	pp := probedProvider{ProbedProvider: ProbedProvider{ID: p.Pretty()}}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	start := time.Now()
+	blks, err := bs.GetBlocks(bitswap.WithPeers(ctx, []peer.ID{p}, true), keys)
+	if err != nil {
+		return pp
+	}
+	for b := range blks {
+		if pp.Fetched == 0 {
+			pp.Latency = time.Since(start)
+		}
+		pp.Fetched++
+		pp.sent = append(pp.sent, b.Cid())
+	}
+	return pp
+}
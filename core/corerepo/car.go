@@ -0,0 +1,91 @@
+package corerepo
+
+import (
+	"encoding/binary"
+	"io"
+
+	blocks "github.com/ipfs/go-ipfs/blocks"
+
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+)
+
+// CBOR major types
+const (
+	cborBytes = 2 << 5
+	cborText  = 3 << 5
+	cborArray = 4 << 5
+	cborMap   = 5 << 5
+	cborTag   = 6 << 5
+)
+
+// cborCIDTag is the CBOR tag of CIDs in DAG-CBOR.
+const cborCIDTag = 42
+
+func cborHead(major byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return []byte{major | byte(n)}
+	case n <= 0xFF:
+		return []byte{major | 24, byte(n)}
+	case n <= 0xFFFF:
+		b := []byte{major | 25, 0, 0}
+		binary.BigEndian.PutUint16(b[1:], uint16(n))
+		return b
+	case n <= 0xFFFFFFFF:
+		b := []byte{major | 26, 0, 0, 0, 0}
+		binary.BigEndian.PutUint32(b[1:], uint32(n))
+		return b
+	default:
+		b := []byte{major | 27, 0, 0, 0, 0, 0, 0, 0, 0}
+		binary.BigEndian.PutUint64(b[1:], n)
+		return b
+	}
+}
+
+func cborString(s string) []byte {
+	return append(cborHead(cborText, uint64(len(s))), s...)
+}
+
+func uvarint(n uint64) []byte {
+	b := make([]byte, binary.MaxVarintLen64)
+	return b[:binary.PutUvarint(b, n)]
+}
+
+// carHeader returns the header of a CAR (version 1) of roots: the length of
+// the DAG-CBOR map {roots, version}, and the map.
+func carHeader(roots []*cid.Cid) []byte {
+	h := []byte{cborMap | 2}
+	h = append(h, cborString("roots")...)
+	h = append(h, cborHead(cborArray, uint64(len(roots)))...)
+	for _, c := range roots {
+		// CIDs are tagged byte strings, after a multibase identity prefix
+		b := c.Bytes()
+		h = append(h, cborHead(cborTag, cborCIDTag)...)
+		h = append(h, cborHead(cborBytes, uint64(len(b)+1))...)
+		h = append(h, 0)
+		h = append(h, b...)
+	}
+	h = append(h, cborString("version")...)
+	h = append(h, 1)
+	return append(uvarint(uint64(len(h))), h...)
+}
+
+// carBlockLen returns the length of the section of a block of a CAR.
+func carBlockLen(c *cid.Cid, size int) uint64 {
+	l := uint64(len(c.Bytes()) + size)
+	return uint64(len(uvarint(l))) + l
+}
+
+// writeCarBlock writes the section of b: the length of the CID and the
+// data, the CID and the data.
+func writeCarBlock(w io.Writer, b blocks.Block) error {
+	c := b.Cid().Bytes()
+	if _, err := w.Write(uvarint(uint64(len(c) + len(b.RawData())))); err != nil {
+		return err
+	}
+	if _, err := w.Write(c); err != nil {
+		return err
+	}
+	_, err := w.Write(b.RawData())
+	return err
+}
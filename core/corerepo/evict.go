@@ -0,0 +1,233 @@
+package corerepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	bstore "github.com/ipfs/go-ipfs/blocks/blockstore"
+	"github.com/ipfs/go-ipfs/core"
+	events "github.com/ipfs/go-ipfs/core/events"
+	gc "github.com/ipfs/go-ipfs/pin/gc"
+
+	humanize "gx/ipfs/QmPSBJL4momYnE7DcUyk2DVhD6rH488ZmHBGLbxNdhU44K/go-humanize"
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+)
+
+const (
+	EvictLRU = "lru"
+	EvictLFU = "lfu"
+)
+
+const defaultEvictInterval = time.Minute
+
+// evictLowWater is the fraction of the budget eviction brings the repo down
+// to, so that it doesn't run again as soon as a few blocks are added.
+const evictLowWater = 0.9
+
+// Evictor removes the unpinned blocks least worth keeping whenever the repo
+// grows over its budget: the least recently used ones, or the least
+// frequently used ones. Blocks never accessed since eviction was enabled go
+// first.
+type Evictor struct {
+	Node     *core.IpfsNode
+	Policy   string
+	Budget   uint64
+	Interval time.Duration
+}
+
+// EvictStats is the outcome of an eviction run.
+type EvictStats struct {
+	Usage   uint64 // repo size before the run
+	Evicted int    // blocks removed
+	Freed   uint64 // size of the blocks removed
+}
+
+func NewEvictor(n *core.IpfsNode) (*Evictor, error) {
+	cfg, err := n.Repo.Config()
+	if err != nil {
+		return nil, err
+	}
+	ecfg := cfg.Datastore.Eviction
+
+	e := &Evictor{
+		Node:     n,
+		Policy:   ecfg.Policy,
+		Interval: defaultEvictInterval,
+	}
+
+	switch e.Policy {
+	case EvictLRU, EvictLFU:
+	default:
+		return nil, fmt.Errorf("unknown eviction policy %q, expected %q or %q", e.Policy, EvictLRU, EvictLFU)
+	}
+
+	if n.Accesses == nil {
+		return nil, errors.New("block accesses are not recorded, eviction needs a daemon with Datastore.Eviction.Policy set at startup")
+	}
+
+	budget := ecfg.Budget
+	if budget == "" {
+		budget = cfg.Datastore.StorageMax
+	}
+	if budget == "" {
+		return nil, errors.New("Datastore.Eviction.Budget is not set")
+	}
+	e.Budget, err = humanize.ParseBytes(budget)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Datastore.Eviction.Budget: %s", err)
+	}
+
+	if ecfg.Interval != "" {
+		e.Interval, err = time.ParseDuration(ecfg.Interval)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Datastore.Eviction.Interval: %s", err)
+		}
+		if e.Interval <= 0 {
+			return nil, errors.New("Datastore.Eviction.Interval must be positive")
+		}
+	}
+	return e, nil
+}
+
+// Run evicts blocks every interval until ctx is done.
+func (e *Evictor) Run(ctx context.Context) error {
+	t := time.NewTicker(e.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return nil
+		}
+
+		stats, err := e.Evict(ctx)
+		if err != nil {
+			log.Errorf("eviction failed: %s", err)
+			continue
+		}
+		if stats.Evicted > 0 {
+			log.Infof("evicted %d blocks, freeing %s", stats.Evicted, humanize.Bytes(stats.Freed))
+		}
+	}
+}
+
+type evictCandidate struct {
+	c   *cid.Cid
+	acc bstore.Access
+}
+
+type byLRU []evictCandidate
+
+func (s byLRU) Len() int      { return len(s) }
+func (s byLRU) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byLRU) Less(i, j int) bool {
+	return s[i].acc.Last.Before(s[j].acc.Last)
+}
+
+type byLFU []evictCandidate
+
+func (s byLFU) Len() int      { return len(s) }
+func (s byLFU) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byLFU) Less(i, j int) bool {
+	if s[i].acc.Count != s[j].acc.Count {
+		return s[i].acc.Count < s[j].acc.Count
+	}
+	return s[i].acc.Last.Before(s[j].acc.Last)
+}
+
+// Evict removes unpinned blocks, in the order of the policy, until the repo
+// is back under evictLowWater of the budget. It does nothing while the repo
+// is within the budget.
+func (e *Evictor) Evict(ctx context.Context) (EvictStats, error) {
+	var stats EvictStats
+	n := e.Node
+
+	usage, err := n.Repo.GetStorageUsage()
+	if err != nil {
+		return stats, err
+	}
+	stats.Usage = usage
+	if usage <= e.Budget {
+		return stats, nil
+	}
+	target := usage - uint64(float64(e.Budget)*evictLowWater)
+
+	unlocker := n.Blockstore.GCLock()
+	defer unlocker.Unlock()
+
+	roots, err := BestEffortRoots(n.FilesRoot)
+	if err != nil {
+		return stats, err
+	}
+
+	// errors of the marking are reported as results, and abort it
+	errs := make(chan gc.Result, 128)
+	go func() {
+		for r := range errs {
+			log.Warning(r.Error)
+		}
+	}()
+	keep, err := gc.ColoredSet(ctx, n.Pinning, n.DAG.GetOfflineLinkService(), roots, errs)
+	close(errs)
+	if err != nil {
+		return stats, err
+	}
+
+	// only the blocks stored in the repo take space, not the filestore ones
+	keys, err := n.BaseBlocks.AllKeysChan(ctx)
+	if err != nil {
+		return stats, err
+	}
+	var candidates []evictCandidate
+	for c := range keys {
+		if keep.Has(c) {
+			continue
+		}
+		acc, _ := n.Accesses.Access(c)
+		candidates = append(candidates, evictCandidate{c, acc})
+	}
+	if err := ctx.Err(); err != nil {
+		return stats, err
+	}
+
+	if e.Policy == EvictLFU {
+		sort.Sort(byLFU(candidates))
+	} else {
+		sort.Sort(byLRU(candidates))
+	}
+
+	for _, cand := range candidates {
+		if stats.Freed >= target {
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			return stats, err
+		}
+
+		size := uint64(cand.acc.Size)
+		if size == 0 {
+			// blocks with no record yet
+			blk, err := n.BaseBlocks.Get(cand.c)
+			if err != nil {
+				continue
+			}
+			size = uint64(len(blk.RawData()))
+		}
+
+		if err := journaled(n, "evict").DeleteBlock(cand.c); err != nil {
+			log.Warningf("failed to evict %s: %s", cand.c, err)
+			continue
+		}
+		stats.Evicted++
+		stats.Freed += size
+		n.Events.Publish(events.GCed, cand.c)
+	}
+
+	if stats.Freed < target {
+		log.Warningf("the repo is still over the eviction budget of %s, with no unpinned block left", humanize.Bytes(e.Budget))
+	}
+	return stats, nil
+}
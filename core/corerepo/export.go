@@ -0,0 +1,197 @@
+package corerepo
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	bstore "github.com/ipfs/go-ipfs/blocks/blockstore"
+	core "github.com/ipfs/go-ipfs/core"
+
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+)
+
+// Names of the files of an export, in its directory.
+const (
+	ExportManifestName = "manifest.json"
+	ExportCarName      = "blocks.car"
+)
+
+// ExportOptions select the journaled blocks to export.
+type ExportOptions struct {
+	// Since is the checkpoint of a previous export, 0 to start from the
+	// beginning of the journal.
+	Since uint64
+	// Prefix restricts the export to the CIDs starting with it.
+	Prefix string
+	// AddedAfter restricts the export to the blocks added after it.
+	AddedAfter time.Time
+}
+
+// ExportedBlock is a block of an export.
+type ExportedBlock struct {
+	Cid  *cid.Cid
+	Size int
+}
+
+// BlocksExport is the manifest of an export. Checkpoint is the Since of the
+// next incremental export.
+type BlocksExport struct {
+	Since      uint64
+	Checkpoint uint64
+	Prefix     string     `json:",omitempty"`
+	AddedAfter *time.Time `json:",omitempty"`
+	Created    time.Time
+	CarSize    uint64
+	Blocks     []ExportedBlock
+}
+
+// Name is the name of the directory of the export.
+func (e *BlocksExport) Name() string {
+	return fmt.Sprintf("blocks-%d-%d", e.Since, e.Checkpoint)
+}
+
+// ExportBlocks returns a tar archive of a directory holding a CAR of the
+// blocks journaled after opts.Since, which match opts, and its manifest. The
+// blocks of the export are kept from garbage collection until the archive is
+// read or ctx is done. Blocks removed since they were journaled are left
+// out.
+func ExportBlocks(ctx context.Context, n *core.IpfsNode, opts ExportOptions) (io.Reader, *BlocksExport, error) {
+	if n.Journal == nil {
+		return nil, nil, ErrNoJournal
+	}
+
+	// entries journaled meanwhile are left to the next export
+	checkpoint := n.Journal.Seq()
+	if opts.Since > checkpoint {
+		return nil, nil, fmt.Errorf("checkpoint %d is past the end of the journal (%d)", opts.Since, checkpoint)
+	}
+	if pruned := n.Journal.Pruned(); opts.Since < pruned {
+		return nil, nil, fmt.Errorf("the journal entries after checkpoint %d are pruned, up to %d: the export would miss blocks", opts.Since, pruned)
+	}
+	entries, err := n.Journal.Entries(opts.Since)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	export := &BlocksExport{
+		Since:      opts.Since,
+		Checkpoint: checkpoint,
+		Prefix:     opts.Prefix,
+		Created:    time.Now(),
+	}
+	if !opts.AddedAfter.IsZero() {
+		export.AddedAfter = &opts.AddedAfter
+	}
+
+	unlocker := n.Blockstore.PinLock()
+
+	header := carHeader(nil)
+	export.CarSize = uint64(len(header))
+	seen := make(map[string]bool)
+	for _, e := range entries {
+		if e.Seq > checkpoint {
+			break
+		}
+		if e.Op != bstore.JournalPut {
+			continue
+		}
+		k := e.Cid.KeyString()
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		if opts.Prefix != "" && !strings.HasPrefix(e.Cid.String(), opts.Prefix) {
+			continue
+		}
+		if !opts.AddedAfter.IsZero() && !e.Time.After(opts.AddedAfter) {
+			continue
+		}
+		if has, err := n.Blockstore.Has(e.Cid); err != nil || !has {
+			continue
+		}
+		export.Blocks = append(export.Blocks, ExportedBlock{e.Cid, e.Size})
+		export.CarSize += carBlockLen(e.Cid, e.Size)
+	}
+
+	manifest, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		unlocker.Unlock()
+		return nil, nil, err
+	}
+
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			pr.CloseWithError(ctx.Err())
+		case <-done:
+		}
+	}()
+	go func() {
+		defer close(done)
+		defer unlocker.Unlock()
+		pw.CloseWithError(writeExport(pw, n, export, header, manifest))
+	}()
+	return pr, export, nil
+}
+
+func writeExport(w io.Writer, n *core.IpfsNode, export *BlocksExport, header, manifest []byte) error {
+	tw := tar.NewWriter(w)
+	dir := export.Name()
+	err := tw.WriteHeader(&tar.Header{
+		Name:     dir,
+		Typeflag: tar.TypeDir,
+		Mode:     0755,
+		ModTime:  export.Created,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = tw.WriteHeader(&tar.Header{
+		Name:     dir + "/" + ExportManifestName,
+		Typeflag: tar.TypeReg,
+		Mode:     0644,
+		Size:     int64(len(manifest)),
+		ModTime:  export.Created,
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := tw.Write(manifest); err != nil {
+		return err
+	}
+
+	err = tw.WriteHeader(&tar.Header{
+		Name:     dir + "/" + ExportCarName,
+		Typeflag: tar.TypeReg,
+		Mode:     0644,
+		Size:     int64(export.CarSize),
+		ModTime:  export.Created,
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := tw.Write(header); err != nil {
+		return err
+	}
+	for _, eb := range export.Blocks {
+		b, err := n.Blockstore.Get(eb.Cid)
+		if err != nil {
+			return fmt.Errorf("failed to export %s: %s", eb.Cid, err)
+		}
+		if len(b.RawData()) != eb.Size {
+			return fmt.Errorf("failed to export %s: journaled with %d bytes, has %d", eb.Cid, eb.Size, len(b.RawData()))
+		}
+		if err := writeCarBlock(tw, b); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
@@ -0,0 +1,272 @@
+package corerepo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	core "github.com/ipfs/go-ipfs/core"
+	mfs "github.com/ipfs/go-ipfs/mfs"
+	path "github.com/ipfs/go-ipfs/path"
+	pin "github.com/ipfs/go-ipfs/pin"
+	dshelp "github.com/ipfs/go-ipfs/thirdparty/ds-help"
+	uio "github.com/ipfs/go-ipfs/unixfs/io"
+
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+	dsq "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore/query"
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+)
+
+// DefaultFollowInterval is how often the daemon resolves the followed paths
+// by default.
+const DefaultFollowInterval = time.Minute
+
+// followPrefix keeps the followed paths, under their base32 encoding.
+var followPrefix = ds.NewKey("/local/pins/follow")
+
+// ErrNotFollowed is returned for the paths which are not followed.
+var ErrNotFollowed = errors.New("the path is not followed")
+
+// FollowedPin is a path whose versions are pinned as it changes: an IPNS
+// path, or a path in the files of the node.
+type FollowedPin struct {
+	Path string
+	// Cid is the version the path resolved to last, and which is pinned.
+	Cid       string
+	Recursive bool
+	// KeepOld keeps the older versions pinned, instead of unpinning them
+	// as the path changes.
+	KeepOld bool `json:",omitempty"`
+	Updated time.Time
+}
+
+type followedByPath []FollowedPin
+
+func (f followedByPath) Len() int           { return len(f) }
+func (f followedByPath) Swap(a, b int)      { f[a], f[b] = f[b], f[a] }
+func (f followedByPath) Less(a, b int) bool { return f[a].Path < f[b].Path }
+
+func followKey(p string) ds.Key {
+	return followPrefix.Child(dshelp.NewKeyFromBinary([]byte(p)))
+}
+
+func putFollowed(d ds.Datastore, f *FollowedPin) error {
+	b, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	return d.Put(followKey(f.Path), b)
+}
+
+// FollowedPins returns the followed paths, by path.
+func FollowedPins(d ds.Datastore) ([]FollowedPin, error) {
+	res, err := d.Query(dsq.Query{
+		Prefix: followPrefix.String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]FollowedPin, 0, len(entries))
+	for _, e := range entries {
+		b, ok := e.Value.([]byte)
+		if !ok {
+			return nil, fmt.Errorf("invalid followed path %s", e.Key)
+		}
+		var f FollowedPin
+		if err := json.Unmarshal(b, &f); err != nil {
+			return nil, fmt.Errorf("invalid followed path %s: %s", e.Key, err)
+		}
+		list = append(list, f)
+	}
+	sort.Sort(followedByPath(list))
+	return list, nil
+}
+
+// checkFollowable returns an error unless p may change: an /ipns/ path, or
+// a path in the files of the node.
+func checkFollowable(p string) error {
+	switch {
+	case strings.HasPrefix(p, "/ipns/"):
+		return nil
+	case strings.HasPrefix(p, "/ipfs/"):
+		return fmt.Errorf("%s never changes, only /ipns/ paths and paths in the files of the node can be followed", p)
+	case strings.HasPrefix(p, "/"):
+		return nil
+	default:
+		return fmt.Errorf("%s is not an /ipns/ path or a path in the files of the node", p)
+	}
+}
+
+// resolveFollowed returns the version p currently resolves to.
+func resolveFollowed(ctx context.Context, n *core.IpfsNode, p string) (*cid.Cid, error) {
+	if !strings.HasPrefix(p, "/ipns/") {
+		fsn, err := mfs.Lookup(n.FilesRoot, p)
+		if err != nil {
+			return nil, err
+		}
+		nd, err := fsn.GetNode()
+		if err != nil {
+			return nil, err
+		}
+		return nd.Cid(), nil
+	}
+
+	pp, err := path.ParsePath(p)
+	if err != nil {
+		return nil, err
+	}
+	r := &path.Resolver{
+		DAG:         n.DAG,
+		ResolveOnce: uio.ResolveUnixfsOnce,
+	}
+	return core.ResolveToCid(ctx, n.Namesys, r, pp)
+}
+
+// Follow pins the version p resolves to, and records p for the daemon to pin
+// its next versions, unpinning the older ones unless keepOld is true. The
+// caller holds the pin lock.
+func Follow(n *core.IpfsNode, ctx context.Context, p string, recursive, keepOld bool) (*cid.Cid, error) {
+	if err := checkFollowable(p); err != nil {
+		return nil, err
+	}
+	c, err := resolveFollowed(ctx, n, p)
+	if err != nil {
+		return nil, fmt.Errorf("pin: %s", err)
+	}
+	if _, err := Pin(n, ctx, []string{"/ipfs/" + c.String()}, recursive); err != nil {
+		return nil, err
+	}
+
+	err = putFollowed(n.Repo.Datastore(), &FollowedPin{
+		Path:      p,
+		Cid:       c.String(),
+		Recursive: recursive,
+		KeepOld:   keepOld,
+		Updated:   time.Now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Unfollow stops following p, and unpins the version pinned last, unless
+// unpin is false. The caller holds the pin lock.
+func Unfollow(n *core.IpfsNode, ctx context.Context, p string, unpin bool) (*FollowedPin, error) {
+	d := n.Repo.Datastore()
+	b, err := d.Get(followKey(p))
+	if err == ds.ErrNotFound {
+		return nil, ErrNotFollowed
+	}
+	if err != nil {
+		return nil, err
+	}
+	var f FollowedPin
+	if err := json.Unmarshal(b.([]byte), &f); err != nil {
+		return nil, fmt.Errorf("invalid followed path %s: %s", p, err)
+	}
+
+	if err := d.Delete(followKey(p)); err != nil {
+		return nil, err
+	}
+	if unpin && !pinnedByOtherFollowed(d, &f) {
+		_, err := Unpin(n, ctx, []string{"/ipfs/" + f.Cid}, f.Recursive)
+		if err != nil && err != pin.ErrNotPinned {
+			return &f, err
+		}
+	}
+	return &f, nil
+}
+
+// pinnedByOtherFollowed returns whether another followed path resolved to
+// the version pinned last for f.
+func pinnedByOtherFollowed(d ds.Datastore, f *FollowedPin) bool {
+	list, err := FollowedPins(d)
+	if err != nil {
+		return true
+	}
+	for _, o := range list {
+		if o.Path != f.Path && o.Cid == f.Cid {
+			return true
+		}
+	}
+	return false
+}
+
+// FollowPins pins the new versions of the followed paths every interval,
+// until ctx is done.
+func FollowPins(ctx context.Context, n *core.IpfsNode, interval time.Duration) {
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-tick.C:
+			if err := UpdateFollowed(ctx, n); err != nil {
+				log.Error("error updating the followed pins: ", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// UpdateFollowed resolves the followed paths, and pins their new versions.
+func UpdateFollowed(ctx context.Context, n *core.IpfsNode) error {
+	list, err := FollowedPins(n.Repo.Datastore())
+	if err != nil {
+		return err
+	}
+
+	for i := range list {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err := updateFollowed(ctx, n, &list[i]); err != nil {
+			log.Errorf("updating the pin of %s: %s", list[i].Path, err)
+		}
+	}
+	return nil
+}
+
+func updateFollowed(ctx context.Context, n *core.IpfsNode, f *FollowedPin) error {
+	c, err := resolveFollowed(ctx, n, f.Path)
+	if err != nil {
+		return err
+	}
+	if c.String() == f.Cid {
+		return nil
+	}
+
+	defer n.Blockstore.PinLock().Unlock()
+
+	log.Infof("pinning %s, the new version of %s", c, f.Path)
+	if _, err := Pin(n, ctx, []string{"/ipfs/" + c.String()}, f.Recursive); err != nil {
+		return err
+	}
+
+	old := *f
+	f.Cid = c.String()
+	f.Updated = time.Now()
+	if err := putFollowed(n.Repo.Datastore(), f); err != nil {
+		return err
+	}
+
+	if f.KeepOld || pinnedByOtherFollowed(n.Repo.Datastore(), &old) {
+		return nil
+	}
+	_, err = Unpin(n, ctx, []string{"/ipfs/" + old.Cid}, old.Recursive)
+	if err == pin.ErrNotPinned {
+		// unpinned by hand
+		return nil
+	}
+	return err
+}
@@ -86,7 +86,9 @@ func GarbageCollect(n *core.IpfsNode, ctx context.Context) error {
 	if err != nil {
 		return err
 	}
-	rmed := gc.GC(ctx, n.Blockstore, n.DAG, n.Pinning, roots)
+	rmed := recordGC(ctx, n, func() <-chan gc.Result {
+		return gc.GC(ctx, journaled(n, "gc"), n.DAG, n.Pinning, roots)
+	})
 
 	return CollectResult(ctx, rmed, nil)
 }
@@ -154,7 +156,9 @@ func GarbageCollectAsync(n *core.IpfsNode, ctx context.Context) <-chan gc.Result
 		return out
 	}
 
-	return gc.GC(ctx, n.Blockstore, n.DAG, n.Pinning, roots)
+	return recordGC(ctx, n, func() <-chan gc.Result {
+		return gc.GC(ctx, journaled(n, "gc"), n.DAG, n.Pinning, roots)
+	})
 }
 
 func PeriodicGC(ctx context.Context, node *core.IpfsNode) error {
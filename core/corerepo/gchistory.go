@@ -0,0 +1,149 @@
+package corerepo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	core "github.com/ipfs/go-ipfs/core"
+	events "github.com/ipfs/go-ipfs/core/events"
+	gc "github.com/ipfs/go-ipfs/pin/gc"
+
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+	dsq "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore/query"
+)
+
+// gcRunsPrefix is the datastore namespace of the GC run records.
+var gcRunsPrefix = ds.NewKey("/local/gc/runs")
+
+// MaxGCRuns is the number of GC run records kept.
+const MaxGCRuns = 100
+
+// maxGCRunErrors bounds the errors kept in a record.
+const maxGCRunErrors = 10
+
+// GCRun records a garbage collection run.
+type GCRun struct {
+	Start         time.Time
+	Duration      time.Duration
+	BlocksRemoved int
+	// BytesFreed is the sum of the sizes of the removed blocks.
+	BytesFreed uint64
+	ErrorCount int
+	Errors     []string `json:",omitempty"`
+}
+
+type gcRunsByStart []GCRun
+
+func (r gcRunsByStart) Len() int           { return len(r) }
+func (r gcRunsByStart) Swap(i, j int)      { r[i], r[j] = r[j], r[i] }
+func (r gcRunsByStart) Less(i, j int) bool { return r[i].Start.Before(r[j].Start) }
+
+func gcRunKey(start time.Time) ds.Key {
+	// zero padded so that keys sort by time
+	return gcRunsPrefix.ChildString(fmt.Sprintf("%020d", start.UnixNano()))
+}
+
+// recordGC starts a GC run with start, passes its results through, and records
+// it once it is done. Results are dropped once ctx is done, but still
+// recorded.
+func recordGC(ctx context.Context, n *core.IpfsNode, start func() <-chan gc.Result) <-chan gc.Result {
+	run := GCRun{Start: time.Now()}
+	out := start()
+
+	rec := make(chan gc.Result, cap(out))
+	go func() {
+		defer close(rec)
+		for res := range out {
+			if res.Error != nil {
+				run.ErrorCount++
+				if len(run.Errors) < maxGCRunErrors {
+					run.Errors = append(run.Errors, res.Error.Error())
+				}
+			} else if res.KeyRemoved != nil {
+				run.BlocksRemoved++
+				run.BytesFreed += res.Size
+				n.Events.Publish(events.GCed, res.KeyRemoved)
+			}
+			select {
+			case rec <- res:
+			case <-ctx.Done():
+			}
+		}
+
+		run.Duration = time.Since(run.Start)
+
+		if err := saveGCRun(n.Repo.Datastore(), run); err != nil {
+			log.Warningf("failed to record gc run: %s", err)
+		}
+	}()
+	return rec
+}
+
+func saveGCRun(d ds.Datastore, run GCRun) error {
+	b, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+	if err := d.Put(gcRunKey(run.Start), b); err != nil {
+		return err
+	}
+
+	// drop the oldest records
+	runs, err := loadGCRuns(d)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(runs)-MaxGCRuns; i++ {
+		if err := d.Delete(gcRunKey(runs[i].Start)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadGCRuns returns every recorded GC run, oldest first.
+func loadGCRuns(d ds.Datastore) ([]GCRun, error) {
+	res, err := d.Query(dsq.Query{Prefix: gcRunsPrefix.String()})
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := res.Rest()
+	if err != nil {
+		return nil, err
+	}
+
+	var runs []GCRun
+	for _, e := range entries {
+		b, ok := e.Value.([]byte)
+		if !ok {
+			continue
+		}
+		var run GCRun
+		if err := json.Unmarshal(b, &run); err != nil {
+			log.Warningf("invalid gc run record %s: %s", e.Key, err)
+			continue
+		}
+		runs = append(runs, run)
+	}
+	sort.Sort(gcRunsByStart(runs))
+	return runs, nil
+}
+
+// LastGCRuns returns up to count of the most recent GC runs, most recent
+// first.
+func LastGCRuns(n *core.IpfsNode, count int) ([]GCRun, error) {
+	runs, err := loadGCRuns(n.Repo.Datastore())
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]GCRun, 0, count)
+	for i := len(runs) - 1; i >= 0 && len(out) < count; i-- {
+		out = append(out, runs[i])
+	}
+	return out, nil
+}
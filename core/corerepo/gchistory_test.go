@@ -0,0 +1,59 @@
+package corerepo
+
+import (
+	"context"
+	"testing"
+
+	blocks "github.com/ipfs/go-ipfs/blocks"
+	core "github.com/ipfs/go-ipfs/core"
+	"github.com/ipfs/go-ipfs/repo"
+	config "github.com/ipfs/go-ipfs/repo/config"
+	ds2 "github.com/ipfs/go-ipfs/thirdparty/datastore2"
+	testutil "github.com/ipfs/go-ipfs/thirdparty/testutil"
+
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+	dssync "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore/sync"
+)
+
+func TestGCRunBytesFreed(t *testing.T) {
+	ctx := context.Background()
+	ident, err := testutil.RandIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var conf config.Config
+	conf.Identity.PeerID = ident.ID().Pretty()
+
+	r := &repo.Mock{D: ds2.CloserWrap(dssync.MutexWrap(ds.NewMapDatastore())), C: conf}
+	n, err := core.NewNode(ctx, &core.BuildCfg{Repo: r})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var size uint64
+	for _, data := range []string{"first unpinned block", "second", "third one"} {
+		b := blocks.NewBlock([]byte(data))
+		if _, err := n.Blocks.AddBlock(b); err != nil {
+			t.Fatal(err)
+		}
+		size += uint64(len(b.RawData()))
+	}
+
+	if err := GarbageCollect(n, ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	runs, err := LastGCRuns(n, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 1 {
+		t.Fatalf("expected a recorded run, got %d", len(runs))
+	}
+	if runs[0].BlocksRemoved != 3 {
+		t.Fatalf("expected 3 blocks removed, got %d", runs[0].BlocksRemoved)
+	}
+	if runs[0].BytesFreed != size {
+		t.Fatalf("expected %d bytes freed, got %d", size, runs[0].BytesFreed)
+	}
+}
@@ -0,0 +1,61 @@
+package corerepo
+
+import (
+	"errors"
+	"strings"
+
+	bstore "github.com/ipfs/go-ipfs/blocks/blockstore"
+	core "github.com/ipfs/go-ipfs/core"
+)
+
+var ErrNoJournal = errors.New("blocks are not journaled, set Datastore.Journal.Enabled")
+
+// journaled returns the blockstore of n, whose writes are journaled as
+// coming from source if blocks are journaled.
+func journaled(n *core.IpfsNode, source string) bstore.GCBlockstore {
+	if n.Journal == nil {
+		return n.Blockstore
+	}
+	return bstore.NewGCBlockstore(n.Journal.Source(source), n.GCLocker)
+}
+
+// JournalQuery selects entries of the block journal. Empty fields match any
+// entry.
+type JournalQuery struct {
+	Since  uint64
+	Op     string
+	Source string
+	Prefix string // of the CIDs
+	// Limit keeps the last entries matching, if positive.
+	Limit int
+}
+
+// QueryJournal returns the entries of the block journal matching q, oldest
+// first.
+func QueryJournal(n *core.IpfsNode, q JournalQuery) ([]bstore.JournalEntry, error) {
+	if n.Journal == nil {
+		return nil, ErrNoJournal
+	}
+	entries, err := n.Journal.Entries(q.Since)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []bstore.JournalEntry
+	for _, e := range entries {
+		if q.Op != "" && e.Op != q.Op {
+			continue
+		}
+		if q.Source != "" && e.Source != q.Source {
+			continue
+		}
+		if q.Prefix != "" && !strings.HasPrefix(e.Cid.String(), q.Prefix) {
+			continue
+		}
+		out = append(out, e)
+	}
+	if q.Limit > 0 && len(out) > q.Limit {
+		out = out[len(out)-q.Limit:]
+	}
+	return out, nil
+}
@@ -0,0 +1,93 @@
+package corerepo
+
+import (
+	"context"
+
+	bserv "github.com/ipfs/go-ipfs/blockservice"
+	"github.com/ipfs/go-ipfs/core"
+	offline "github.com/ipfs/go-ipfs/exchange/offline"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+)
+
+// Locality tells how much of a dag is in the blockstore.
+type Locality struct {
+	LocalBlocks int    // blocks in the blockstore
+	LocalBytes  uint64 // size of the blocks in the blockstore
+	// MissingBlocks counts the blocks missing from the blockstore which
+	// local blocks link to. The blocks under them are not known, so a
+	// missing block may stand for a whole missing subtree.
+	MissingBlocks int
+	// MissingBytes is the size of the missing subtrees, as recorded in the
+	// links to them.
+	MissingBytes uint64
+}
+
+// LocalFraction returns the fraction of the bytes of the dag which are in
+// the blockstore.
+func (l *Locality) LocalFraction() float64 {
+	total := l.LocalBytes + l.MissingBytes
+	if total == 0 {
+		if l.MissingBlocks > 0 {
+			return 0
+		}
+		return 1
+	}
+	return float64(l.LocalBytes) / float64(total)
+}
+
+// DagLocality walks the dag under root in the blockstore, without fetching
+// anything from the network, and tells how much of it is there, to predict
+// whether reading, exporting or pinning it is instant or waits on the
+// network.
+func DagLocality(ctx context.Context, n *core.IpfsNode, root *cid.Cid) (*Locality, error) {
+	bs := n.Blockstore
+	getter := dag.NewDAGService(bserv.New(bs, offline.Exchange(bs)))
+
+	var l Locality
+	has, err := bs.Has(root)
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		// the size of the dag is not known without its root
+		l.MissingBlocks++
+		return &l, nil
+	}
+
+	seen := cid.NewSet()
+	seen.Add(root)
+	stack := []*cid.Cid{root}
+	for len(stack) > 0 {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		c := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		nd, err := getter.Get(ctx, c)
+		if err != nil {
+			return nil, err
+		}
+		l.LocalBlocks++
+		l.LocalBytes += uint64(len(nd.RawData()))
+
+		for _, lnk := range nd.Links() {
+			if !seen.Visit(lnk.Cid) {
+				continue
+			}
+			has, err := bs.Has(lnk.Cid)
+			if err != nil {
+				return nil, err
+			}
+			if !has {
+				l.MissingBlocks++
+				l.MissingBytes += lnk.Size
+				continue
+			}
+			stack = append(stack, lnk.Cid)
+		}
+	}
+	return &l, nil
+}
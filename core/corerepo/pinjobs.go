@@ -0,0 +1,148 @@
+package corerepo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	core "github.com/ipfs/go-ipfs/core"
+	events "github.com/ipfs/go-ipfs/core/events"
+
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+	dsq "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore/query"
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+)
+
+// pinJobsPrefix keeps the pins being added, under their CIDs, until they
+// are. The jobs of the pins interrupted by the shutdown of the node are left
+// behind, and resumed by the next daemon.
+var pinJobsPrefix = ds.NewKey("/local/pins/inprogress")
+
+// PinJob is a pin being added, whose blocks may still be fetched.
+type PinJob struct {
+	Cid       string
+	Recursive bool
+	Started   time.Time
+	// Resumes counts the daemons which resumed the job.
+	Resumes int `json:",omitempty"`
+}
+
+type pinJobsByStart []PinJob
+
+func (j pinJobsByStart) Len() int           { return len(j) }
+func (j pinJobsByStart) Swap(a, b int)      { j[a], j[b] = j[b], j[a] }
+func (j pinJobsByStart) Less(a, b int) bool { return j[a].Started.Before(j[b].Started) }
+
+func putPinJob(d ds.Datastore, job *PinJob) error {
+	b, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return d.Put(pinJobsPrefix.ChildString(job.Cid), b)
+}
+
+func dropPinJob(d ds.Datastore, c string) error {
+	err := d.Delete(pinJobsPrefix.ChildString(c))
+	if err == ds.ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+// PinJobs returns the pins being added, and those interrupted by the
+// shutdown of the node, oldest first.
+func PinJobs(d ds.Datastore) ([]PinJob, error) {
+	res, err := d.Query(dsq.Query{
+		Prefix: pinJobsPrefix.String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := make([]PinJob, 0, len(entries))
+	for _, e := range entries {
+		b, ok := e.Value.([]byte)
+		if !ok {
+			return nil, fmt.Errorf("invalid pin job %s", e.Key)
+		}
+		var job PinJob
+		if err := json.Unmarshal(b, &job); err != nil {
+			return nil, fmt.Errorf("invalid pin job %s: %s", e.Key, err)
+		}
+		jobs = append(jobs, job)
+	}
+	sort.Sort(pinJobsByStart(jobs))
+	return jobs, nil
+}
+
+// ResumePins completes the pins whose adding was interrupted by the shutdown
+// of a node on the repo of n, one after the other, fetching what is missing
+// of their blocks. A job whose pin fails is dropped, unless ctx or the node
+// is done first, and left to the next daemon then.
+func ResumePins(ctx context.Context, n *core.IpfsNode) error {
+	jobs, err := PinJobs(n.Repo.Datastore())
+	if err != nil {
+		return err
+	}
+
+	for i := range jobs {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err := resumePin(ctx, n, &jobs[i]); err != nil {
+			log.Errorf("resuming the pin of %s: %s", jobs[i].Cid, err)
+		}
+	}
+	return nil
+}
+
+func resumePin(ctx context.Context, n *core.IpfsNode, job *PinJob) error {
+	d := n.Repo.Datastore()
+	c, err := cid.Decode(job.Cid)
+	if err != nil {
+		return dropPinJob(d, job.Cid)
+	}
+
+	defer n.Blockstore.PinLock().Unlock()
+
+	job.Resumes++
+	if err := putPinJob(d, job); err != nil {
+		return err
+	}
+	log.Infof("resuming the pin of %s", c)
+
+	tracked, jctx := n.PinJobs.Start(ctx, c, job.Recursive, true)
+	defer n.PinJobs.Done(tracked)
+
+	err = pinJob(jctx, n, c, job.Recursive)
+	if err != nil && (ctx.Err() != nil || n.Context().Err() != nil) {
+		// left to the next daemon
+		return err
+	}
+	if dropErr := dropPinJob(d, job.Cid); err == nil {
+		err = dropErr
+	}
+	if err != nil {
+		return err
+	}
+
+	n.Events.Publish(events.Pinned, c)
+	return nil
+}
+
+func pinJob(ctx context.Context, n *core.IpfsNode, c *cid.Cid, recursive bool) error {
+	nd, err := n.DAG.Get(ctx, c)
+	if err != nil {
+		return err
+	}
+	if err := n.Pinning.Pin(ctx, nd, recursive); err != nil {
+		return err
+	}
+	return n.Pinning.Flush()
+}
@@ -16,9 +16,12 @@ package corerepo
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/ipfs/go-ipfs/core"
+	events "github.com/ipfs/go-ipfs/core/events"
 	path "github.com/ipfs/go-ipfs/path"
+	pin "github.com/ipfs/go-ipfs/pin"
 	uio "github.com/ipfs/go-ipfs/unixfs/io"
 
 	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
@@ -46,17 +49,36 @@ func Pin(n *core.IpfsNode, ctx context.Context, paths []string, recursive bool)
 		dagnodes = append(dagnodes, dagnode)
 	}
 
+	// the pins are recorded as jobs until flushed, so that the next daemon
+	// resumes them if the node stops first
+	d := n.Repo.Datastore()
 	var out []*cid.Cid
+	defer func() {
+		if n.Context().Err() != nil {
+			return
+		}
+		for _, c := range out {
+			if err := dropPinJob(d, c.String()); err != nil {
+				log.Errorf("dropping the pin job of %s: %s", c, err)
+			}
+		}
+	}()
+
 	for _, dagnode := range dagnodes {
 		c := dagnode.Cid()
 
-		ctx, cancel := context.WithCancel(ctx)
-		defer cancel()
+		rec := &PinJob{Cid: c.String(), Recursive: recursive, Started: time.Now()}
+		if err := putPinJob(d, rec); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+
+		job, ctx := n.PinJobs.Start(ctx, c, recursive, false)
+		defer n.PinJobs.Done(job)
 		err := n.Pinning.Pin(ctx, dagnode, recursive)
 		if err != nil {
 			return nil, fmt.Errorf("pin: %s", err)
 		}
-		out = append(out, c)
 	}
 
 	err := n.Pinning.Flush()
@@ -64,6 +86,9 @@ func Pin(n *core.IpfsNode, ctx context.Context, paths []string, recursive bool)
 		return nil, err
 	}
 
+	for _, c := range out {
+		n.Events.Publish(events.Pinned, c)
+	}
 	return out, nil
 }
 
@@ -89,6 +114,12 @@ func Unpin(n *core.IpfsNode, ctx context.Context, paths []string, recursive bool
 		ctx, cancel := context.WithCancel(ctx)
 		defer cancel()
 		err = n.Pinning.Unpin(ctx, k, recursive)
+		if err == pin.ErrNotPinned {
+			// an interrupted pin is unpinned by dropping its job
+			if has, _ := n.Repo.Datastore().Has(pinJobsPrefix.ChildString(k.String())); has {
+				err = dropPinJob(n.Repo.Datastore(), k.String())
+			}
+		}
 		if err != nil {
 			return nil, err
 		}
@@ -99,5 +130,9 @@ func Unpin(n *core.IpfsNode, ctx context.Context, paths []string, recursive bool
 	if err != nil {
 		return nil, err
 	}
+
+	for _, c := range unpinned {
+		n.Events.Publish(events.Unpinned, c)
+	}
 	return unpinned, nil
 }
@@ -0,0 +1,339 @@
+package corerepo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	bstore "github.com/ipfs/go-ipfs/blocks/blockstore"
+	core "github.com/ipfs/go-ipfs/core"
+	bitswap "github.com/ipfs/go-ipfs/exchange/bitswap"
+	filestore "github.com/ipfs/go-ipfs/filestore"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	repo "github.com/ipfs/go-ipfs/repo"
+	fsrepo "github.com/ipfs/go-ipfs/repo/fsrepo"
+
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+	dsq "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore/query"
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+)
+
+// daemonRunKey records the run of the daemon using the repo. It is removed
+// on shutdown, and left behind by a daemon which did not shut down cleanly.
+var daemonRunKey = ds.NewKey("/local/daemon/run")
+
+// recoveryReportKey is the datastore key of the report of the last recovery.
+var recoveryReportKey = ds.NewKey("/local/recovery/report")
+
+// lostFilesRootKey keeps the CID of an MFS root reset by a recovery.
+var lostFilesRootKey = ds.NewKey("/local/recovery/filesroot")
+
+// recoveryFsckWorkers is the number of goroutines checking the blocks
+// directory during a recovery.
+const recoveryFsckWorkers = 4
+
+// Checks of the recovery
+const (
+	RecoveryCheckLock         = "repo lock"
+	RecoveryCheckWrites       = "partial writes"
+	RecoveryCheckFilesRoot    = "mfs root"
+	RecoveryCheckProvideQueue = "provide queue"
+)
+
+// DaemonRun identifies a run of the daemon.
+type DaemonRun struct {
+	Pid     int
+	Started time.Time
+}
+
+// RecoveryProblem is a problem found by a recovery, fixed or not.
+type RecoveryProblem struct {
+	Problem string
+	Fixed   bool
+	Error   string `json:",omitempty"`
+}
+
+// RecoveryCheck is a check of the recovery, and what it found.
+type RecoveryCheck struct {
+	Check    string
+	Problems []RecoveryProblem `json:",omitempty"`
+	// Notes tell what was found in order.
+	Notes []string `json:",omitempty"`
+}
+
+// RecoveryReport is the report of a recovery from an unclean shutdown.
+type RecoveryReport struct {
+	Time time.Time
+	// Daemon is the run which did not shut down cleanly.
+	Daemon DaemonRun
+	Checks []RecoveryCheck
+}
+
+// Summary returns a line per check, with the number of problems found and
+// fixed.
+func (r *RecoveryReport) Summary() []string {
+	var lines []string
+	for _, c := range r.Checks {
+		fixed := 0
+		for _, p := range c.Problems {
+			if p.Fixed {
+				fixed++
+			}
+		}
+		lines = append(lines, fmt.Sprintf("%s: %d problems found, %d fixed", c.Check, len(c.Problems), fixed))
+	}
+	return lines
+}
+
+func (c *RecoveryCheck) add(problem string, err error) {
+	p := RecoveryProblem{Problem: problem, Fixed: err == nil}
+	if err != nil {
+		p.Error = err.Error()
+	}
+	c.Problems = append(c.Problems, p)
+}
+
+func (c *RecoveryCheck) note(format string, args ...interface{}) {
+	c.Notes = append(c.Notes, fmt.Sprintf(format, args...))
+}
+
+// MarkDaemonRunning records that a daemon runs on r, until MarkDaemonStopped.
+func MarkDaemonRunning(r repo.Repo) error {
+	b, err := json.Marshal(DaemonRun{Pid: os.Getpid(), Started: time.Now()})
+	if err != nil {
+		return err
+	}
+	return r.Datastore().Put(daemonRunKey, b)
+}
+
+// MarkDaemonStopped records that the daemon running on r shut down cleanly.
+func MarkDaemonStopped(r repo.Repo) error {
+	err := r.Datastore().Delete(daemonRunKey)
+	if err == ds.ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+// Recover checks the repo at repoPath, opened as r, after the daemon using
+// it did not shut down cleanly, and fixes what can be safely fixed: the
+// files of the lock and of interrupted writes are removed, an MFS root
+// whose block was lost is reset to an empty directory, and the provide
+// queue is cleared of blocks which are gone. It returns nil if the last
+// daemon shut down cleanly, and saves the report otherwise. It must run
+// before a node is built on the repo.
+func Recover(r repo.Repo, repoPath string) (*RecoveryReport, error) {
+	d := r.Datastore()
+	val, err := d.Get(daemonRunKey)
+	switch err {
+	case nil:
+	case ds.ErrNotFound:
+		return nil, nil
+	default:
+		return nil, err
+	}
+
+	report := &RecoveryReport{Time: time.Now()}
+	if b, ok := val.([]byte); ok {
+		if err := json.Unmarshal(b, &report.Daemon); err != nil {
+			log.Warningf("invalid daemon run record: %s", err)
+		}
+	}
+
+	report.Checks = append(report.Checks,
+		recoverLock(repoPath),
+		recoverWrites(repoPath),
+	)
+
+	cfg, err := r.Config()
+	if err != nil {
+		return nil, err
+	}
+	var bs bstore.Blockstore = bstore.NewBlockstore(d)
+	if cfg.Experimental.FilestoreEnabled && r.FileManager() != nil {
+		bs = filestore.NewFilestore(bs, r.FileManager())
+	}
+
+	fr, err := recoverFilesRoot(d, bs)
+	if err != nil {
+		return nil, err
+	}
+	pq, err := recoverProvideQueue(d, bs)
+	if err != nil {
+		return nil, err
+	}
+	report.Checks = append(report.Checks, fr, pq)
+
+	b, err := json.Marshal(report)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.Put(recoveryReportKey, b); err != nil {
+		return nil, err
+	}
+	if err := d.Delete(daemonRunKey); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// recoverLock removes the api file of the crashed daemon, which clients
+// would otherwise try to reach. The repo lock itself was released with the
+// process, as it is held by the repo now.
+func recoverLock(repoPath string) RecoveryCheck {
+	c := RecoveryCheck{Check: RecoveryCheckLock}
+	c.note("the repo lock was released with the crashed daemon")
+
+	apiFile := fsrepo.APIFilePath(repoPath)
+	if _, err := os.Stat(apiFile); err == nil {
+		c.add("stale api file "+apiFile, os.Remove(apiFile))
+	}
+	return c
+}
+
+// recoverWrites removes the temporary files of the writes of blocks and
+// keys interrupted by the crash, and moves the blocks left out of place.
+func recoverWrites(repoPath string) RecoveryCheck {
+	c := RecoveryCheck{Check: RecoveryCheckWrites}
+
+	blocks := fsrepo.FlatfsPath(repoPath)
+	if _, err := os.Stat(blocks); err == nil {
+		problems, err := fsrepo.FsckFlatfs(blocks, false, recoveryFsckWorkers)
+		if err != nil {
+			c.add("cannot check the blocks directory", err)
+		}
+		for _, p := range problems {
+			if p.Problem == fsrepo.ProblemUnknown {
+				// not ours to remove, and not left by a crash
+				continue
+			}
+			c.Problems = append(c.Problems, RecoveryProblem{
+				Problem: p.Problem + " " + p.Path,
+				Fixed:   p.Repaired,
+				Error:   p.Error,
+			})
+		}
+	}
+
+	keys := filepath.Join(repoPath, "keystore")
+	files, err := ioutil.ReadDir(keys)
+	if err != nil && !os.IsNotExist(err) {
+		c.add("cannot check the keystore", err)
+	}
+	for _, f := range files {
+		if name := f.Name(); strings.HasPrefix(name, ".") && strings.HasSuffix(name, ".tmp") {
+			path := filepath.Join(keys, name)
+			c.add("orphaned temporary key "+path, os.Remove(path))
+		}
+	}
+	return c
+}
+
+// recoverFilesRoot checks that the block of the MFS root is stored and
+// valid. If it is not, the root is reset to an empty directory, which the
+// node would otherwise fail to load or block on fetching, and its CID kept
+// for the report.
+func recoverFilesRoot(d ds.Datastore, bs bstore.Blockstore) (RecoveryCheck, error) {
+	c := RecoveryCheck{Check: RecoveryCheckFilesRoot}
+
+	val, err := d.Get(core.FilesRootKey)
+	switch err {
+	case nil:
+	case ds.ErrNotFound:
+		return c, nil
+	default:
+		return c, err
+	}
+
+	b, _ := val.([]byte)
+	root, err := cid.Cast(b)
+	if err != nil {
+		c.add("invalid MFS root, reset to an empty directory", resetFilesRoot(d, b))
+		return c, nil
+	}
+
+	blk, err := bs.Get(root)
+	if err == nil && root.Type() == cid.DagProtobuf {
+		_, err = dag.DecodeProtobuf(blk.RawData())
+	}
+	if err != nil {
+		problem := fmt.Sprintf("MFS root %s cannot be loaded (%s), reset to an empty directory", root, err)
+		c.add(problem, resetFilesRoot(d, b))
+	}
+	return c, nil
+}
+
+func resetFilesRoot(d ds.Datastore, old []byte) error {
+	if err := d.Put(lostFilesRootKey, old); err != nil {
+		return err
+	}
+	return d.Delete(core.FilesRootKey)
+}
+
+// recoverProvideQueue drops the entries of the provide queue which are
+// invalid, or whose blocks were removed, and counts the others, which will
+// be announced.
+func recoverProvideQueue(d ds.Datastore, bs bstore.Blockstore) (RecoveryCheck, error) {
+	c := RecoveryCheck{Check: RecoveryCheckProvideQueue}
+
+	res, err := d.Query(dsq.Query{
+		Prefix:   bitswap.ProvideQueuePrefix.String(),
+		KeysOnly: true,
+	})
+	if err != nil {
+		return c, err
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return c, err
+	}
+
+	pending := 0
+	for _, e := range entries {
+		k := ds.RawKey(e.Key)
+		key, err := cid.Decode(k.BaseNamespace())
+		if err != nil {
+			c.add("invalid provide queue entry "+k.String(), d.Delete(k))
+			continue
+		}
+
+		has, err := bs.Has(key)
+		if err != nil {
+			return c, err
+		}
+		if !has {
+			c.add("provide queue entry of removed block "+key.String(), d.Delete(k))
+			continue
+		}
+		pending++
+	}
+	c.note("%d blocks left to announce", pending)
+	return c, nil
+}
+
+// LastRecoveryReport returns the report of the last recovery from an
+// unclean shutdown, or nil if there was none.
+func LastRecoveryReport(d ds.Datastore) (*RecoveryReport, error) {
+	val, err := d.Get(recoveryReportKey)
+	switch err {
+	case nil:
+	case ds.ErrNotFound:
+		return nil, nil
+	default:
+		return nil, err
+	}
+
+	b, ok := val.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("invalid recovery report")
+	}
+	var report RecoveryReport
+	if err := json.Unmarshal(b, &report); err != nil {
+		return nil, fmt.Errorf("invalid recovery report: %s", err)
+	}
+	return &report, nil
+}
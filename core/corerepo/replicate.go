@@ -0,0 +1,328 @@
+package corerepo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	blocks "github.com/ipfs/go-ipfs/blocks"
+	"github.com/ipfs/go-ipfs/core"
+	blockpush "github.com/ipfs/go-ipfs/core/blockpush"
+
+	mh "gx/ipfs/QmVGtdTZdTFaLsaj2RwdVG8jcjNNcp1DE914DKZ2kHmXHw/go-multihash"
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+// DefaultReplicateRetries is the number of times Replicate retries a failed
+// request to the target.
+const DefaultReplicateRetries = 3
+
+// replicateWorkers is the number of blocks pushed at once.
+const replicateWorkers = 8
+
+// replicateRetryDelay is the delay before the first retry of a request,
+// doubled at each retry.
+const replicateRetryDelay = time.Second
+
+// ReplicateStats counts the blocks walked by Replicate.
+type ReplicateStats struct {
+	Blocks  int    // blocks walked
+	Present int    // blocks the target already had
+	Pushed  int    // blocks sent to the target
+	Bytes   uint64 // size of the pushed blocks
+	Retries int    // requests to the target retried
+}
+
+// Replica is where Replicate pushes the blocks to.
+type Replica interface {
+	// Has tells whether the replica has the block c, without fetching it.
+	Has(ctx context.Context, c *cid.Cid) (bool, error)
+	// Put sends the block c to the replica.
+	Put(ctx context.Context, c *cid.Cid, data []byte) error
+	// Pin pins root recursively on the replica, which must have the blocks.
+	Pin(ctx context.Context, root *cid.Cid) error
+}
+
+// PeerReplica is a peer allowing the node to push blocks to it over the
+// block push protocol.
+type PeerReplica struct {
+	Node *core.IpfsNode
+	Peer peer.ID
+}
+
+// Has asks the peer whether it has the block c.
+func (r *PeerReplica) Has(ctx context.Context, c *cid.Cid) (bool, error) {
+	return blockpush.Has(ctx, r.Node.PeerHost, r.Peer, c)
+}
+
+// Put pushes the block c to the peer.
+func (r *PeerReplica) Put(ctx context.Context, c *cid.Cid, data []byte) error {
+	b, err := blocks.NewBlockWithCid(data, c)
+	if err != nil {
+		return err
+	}
+	return blockpush.Put(ctx, r.Node.PeerHost, r.Peer, b)
+}
+
+// Pin asks the peer to pin root recursively.
+func (r *PeerReplica) Pin(ctx context.Context, root *cid.Cid) error {
+	return blockpush.Pin(ctx, r.Node.PeerHost, r.Peer, root)
+}
+
+// ReplicaTarget is the API of a node Replicate pushes the blocks to.
+type ReplicaTarget struct {
+	// Endpoint is the URL of the API, such as "http://10.0.0.1:5001".
+	Endpoint string
+	// Auth, if set, is sent with the requests, as for the remotes of
+	// 'ipfs remote': user:password as basic credentials, anything else as a
+	// bearer token.
+	Auth string
+}
+
+// Replicate pushes the blocks of the dag under root to the replica t, such as
+// a node serving its API, rather than waiting for it to fetch them. The blocks
+// the replica has are skipped. The requests failing are retried retries
+// times, after a growing delay. progress, if not nil, is called after each
+// block.
+func Replicate(ctx context.Context, n *core.IpfsNode, root *cid.Cid, t Replica, retries int, progress func(ReplicateStats)) (ReplicateStats, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		lk       sync.Mutex
+		stats    ReplicateStats
+		firstErr error
+	)
+	fail := func(err error) {
+		lk.Lock()
+		if firstErr == nil {
+			firstErr = err
+		}
+		lk.Unlock()
+		cancel()
+	}
+	retry := func(f func() error) error {
+		delay := replicateRetryDelay
+		for i := 0; ; i++ {
+			err := f()
+			if err == nil || i >= retries || ctx.Err() != nil {
+				return err
+			}
+			lk.Lock()
+			stats.Retries++
+			lk.Unlock()
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			delay *= 2
+		}
+	}
+
+	type block struct {
+		c    *cid.Cid
+		data []byte
+	}
+	todo := make(chan block)
+	var wg sync.WaitGroup
+	for i := 0; i < replicateWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for b := range todo {
+				var has bool
+				err := retry(func() (err error) {
+					has, err = t.Has(ctx, b.c)
+					return err
+				})
+				if err == nil && !has {
+					err = retry(func() error {
+						return t.Put(ctx, b.c, b.data)
+					})
+				}
+				if err != nil {
+					fail(fmt.Errorf("cannot push %s: %s", b.c, err))
+					continue
+				}
+
+				lk.Lock()
+				stats.Blocks++
+				if has {
+					stats.Present++
+				} else {
+					stats.Pushed++
+					stats.Bytes += uint64(len(b.data))
+				}
+				if progress != nil {
+					progress(stats)
+				}
+				lk.Unlock()
+			}
+		}()
+	}
+
+	// the dag is walked a level at a time, as by Warm
+	seen := cid.NewSet()
+	seen.Add(root)
+	level := []*cid.Cid{root}
+walk:
+	for len(level) > 0 {
+		var next []*cid.Cid
+		for opt := range n.DAG.GetMany(ctx, level) {
+			if opt.Err != nil {
+				fail(opt.Err)
+				break walk
+			}
+			nd := opt.Node
+			select {
+			case todo <- block{c: nd.Cid(), data: nd.RawData()}:
+			case <-ctx.Done():
+				break walk
+			}
+			for _, l := range nd.Links() {
+				if seen.Visit(l.Cid) {
+					next = append(next, l.Cid)
+				}
+			}
+		}
+		level = next
+	}
+	close(todo)
+	wg.Wait()
+
+	if firstErr != nil {
+		return stats, firstErr
+	}
+	return stats, ctx.Err()
+}
+
+// Pin pins root recursively on the target, which must have the blocks.
+func (t *ReplicaTarget) Pin(ctx context.Context, root *cid.Cid) error {
+	resp, err := t.post(ctx, "pin/add", url.Values{"arg": {root.String()}}, "", nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Has tells whether the target has the block c, as 'ipfs block stat
+// --offline' does.
+func (t *ReplicaTarget) Has(ctx context.Context, c *cid.Cid) (bool, error) {
+	resp, err := t.post(ctx, "block/stat", url.Values{"arg": {c.String()}, "offline": {"true"}}, "", nil)
+	if _, ok := err.(*apiError); ok {
+		// the block is missing, or the target can't tell: it is pushed,
+		// which it ignores if it has the block
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	return true, nil
+}
+
+// Put sends the block c to the target, with 'ipfs block put'.
+func (t *ReplicaTarget) Put(ctx context.Context, c *cid.Cid, data []byte) error {
+	pref := c.Prefix()
+	var format string
+	switch {
+	case pref.Version == 0:
+		format = "v0"
+	case pref.Codec == cid.DagProtobuf:
+		format = "protobuf"
+	case pref.Codec == cid.DagCBOR:
+		format = "cbor"
+	case pref.Codec == cid.Raw:
+		format = "raw"
+	default:
+		return fmt.Errorf("the blocks of codec %d cannot be put", pref.Codec)
+	}
+	args := url.Values{
+		"format": {format},
+		"mhtype": {mh.Codes[pref.MhType]},
+		"mhlen":  {fmt.Sprint(pref.MhLength)},
+	}
+
+	body := new(bytes.Buffer)
+	w := multipart.NewWriter(body)
+	part, err := w.CreateFormFile("file", c.String())
+	if err != nil {
+		return err
+	}
+	part.Write(data)
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	resp, err := t.post(ctx, "block/put", args, w.FormDataContentType(), body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var out struct{ Key string }
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return err
+	}
+	if out.Key != c.String() {
+		return fmt.Errorf("the target stored the block as %s", out.Key)
+	}
+	return nil
+}
+
+// apiError is an error returned by a command of the target.
+type apiError struct {
+	cmd string
+	msg string
+}
+
+func (e *apiError) Error() string {
+	return e.cmd + ": " + e.msg
+}
+
+// post runs the API command cmd on the target. The body of the response
+// must be closed if no error is returned.
+func (t *ReplicaTarget) post(ctx context.Context, cmd string, args url.Values, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest("POST", strings.TrimRight(t.Endpoint, "/")+"/api/v0/"+cmd+"?"+args.Encode(), body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if t.Auth != "" {
+		if i := strings.Index(t.Auth, ":"); i >= 0 {
+			req.SetBasicAuth(t.Auth[:i], t.Auth[i+1:])
+		} else {
+			req.Header.Set("Authorization", "Bearer "+t.Auth)
+		}
+	}
+
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusOK {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	var e struct{ Message string }
+	b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+	if json.Unmarshal(b, &e) == nil && e.Message != "" {
+		return nil, &apiError{cmd: cmd, msg: e.Message}
+	}
+	return nil, fmt.Errorf("%s: %s", cmd, resp.Status)
+}
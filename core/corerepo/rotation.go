@@ -0,0 +1,171 @@
+package corerepo
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	keystore "github.com/ipfs/go-ipfs/keystore"
+	repo "github.com/ipfs/go-ipfs/repo"
+
+	ci "gx/ipfs/QmP1DfoUjiWH2ZBo1PBH6FupdBucbDepx3HpWmEY6JMUpY/go-libp2p-crypto"
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+// identityRotationKey records a rotation of the identity of the node while
+// it is under way. It is left behind by an interrupted rotation.
+var identityRotationKey = ds.NewKey("/local/identity/rotation")
+
+// IdentityRotation is a rotation of the identity of the node.
+type IdentityRotation struct {
+	Old     string
+	New     string
+	Started time.Time
+	// OldKey is the name the old key is kept under in the keystore, if it
+	// is kept.
+	OldKey string `json:",omitempty"`
+	// Resumed is true when the rotation completed was an interrupted one.
+	Resumed bool `json:",omitempty"`
+}
+
+// ErrRotationPending is returned by CheckIdentityRotation when a rotation of
+// the identity was interrupted.
+type ErrRotationPending struct {
+	Rotation *IdentityRotation
+}
+
+func (e ErrRotationPending) Error() string {
+	return fmt.Sprintf("the rotation of the identity of the node from %s to %s was interrupted, run 'ipfs key rotate' to complete it", e.Rotation.Old, e.Rotation.New)
+}
+
+// PendingIdentityRotation returns the rotation of the identity interrupted
+// on r, or nil if there is none.
+func PendingIdentityRotation(r repo.Repo) (*IdentityRotation, error) {
+	val, err := r.Datastore().Get(identityRotationKey)
+	switch err {
+	case nil:
+	case ds.ErrNotFound:
+		return nil, nil
+	default:
+		return nil, err
+	}
+
+	b, ok := val.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("invalid identity rotation record")
+	}
+	var rot IdentityRotation
+	if err := json.Unmarshal(b, &rot); err != nil {
+		return nil, fmt.Errorf("invalid identity rotation record: %s", err)
+	}
+	return &rot, nil
+}
+
+// CheckIdentityRotation returns an ErrRotationPending if a rotation of the
+// identity was interrupted on r. The node must not run on r until it is
+// completed.
+func CheckIdentityRotation(r repo.Repo) error {
+	rot, err := PendingIdentityRotation(r)
+	if err != nil {
+		return err
+	}
+	if rot != nil {
+		return ErrRotationPending{rot}
+	}
+	return nil
+}
+
+// RotateIdentity replaces the identity of the node of r with sk. The old key
+// is kept in the keystore under the name oldKey, unless it is empty, so that
+// the names published with it can still be republished.
+//
+// The rotation is recorded while under way. If it was interrupted,
+// RotateIdentity completes it instead, without rotating again if the new
+// identity was already saved.
+func RotateIdentity(r repo.Repo, sk ci.PrivKey, oldKey string) (*IdentityRotation, error) {
+	cfg, err := r.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	pending, err := PendingIdentityRotation(r)
+	if err != nil {
+		return nil, err
+	}
+	if pending != nil {
+		if cfg.Identity.PeerID == pending.New {
+			// only the record is left to remove
+			pending.Resumed = true
+			return pending, r.Datastore().Delete(identityRotationKey)
+		}
+		// the new identity was not saved: rotate again
+		if err := r.Datastore().Delete(identityRotationKey); err != nil {
+			return nil, err
+		}
+	}
+
+	old, err := cfg.Identity.DecodePrivateKey("passphrase todo!")
+	if err != nil {
+		return nil, err
+	}
+	id, err := peer.IDFromPrivateKey(sk)
+	if err != nil {
+		return nil, err
+	}
+	if id.Pretty() == cfg.Identity.PeerID {
+		return nil, fmt.Errorf("the new key is the key of the node")
+	}
+
+	if oldKey != "" {
+		if err := keepOldKey(r.Keystore(), oldKey, old); err != nil {
+			return nil, err
+		}
+	}
+
+	skb, err := sk.Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	rot := &IdentityRotation{
+		Old:     cfg.Identity.PeerID,
+		New:     id.Pretty(),
+		Started: time.Now(),
+		OldKey:  oldKey,
+	}
+	b, err := json.Marshal(rot)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.Datastore().Put(identityRotationKey, b); err != nil {
+		return nil, err
+	}
+
+	cfg.Identity.PeerID = rot.New
+	cfg.Identity.PrivKey = base64.StdEncoding.EncodeToString(skb)
+	if err := r.SetConfig(cfg); err != nil {
+		return nil, err
+	}
+
+	return rot, r.Datastore().Delete(identityRotationKey)
+}
+
+// keepOldKey stores the old key of the node under name, unless an
+// interrupted rotation already did.
+func keepOldKey(ks keystore.Keystore, name string, old ci.PrivKey) error {
+	err := ks.Put(name, old)
+	if err != keystore.ErrKeyExists {
+		return err
+	}
+
+	k, err := ks.Get(name)
+	if err != nil {
+		return err
+	}
+	if !k.Equals(old) {
+		return fmt.Errorf("a key named %s already exists", name)
+	}
+	return nil
+}
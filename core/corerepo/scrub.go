@@ -0,0 +1,204 @@
+package corerepo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	bstore "github.com/ipfs/go-ipfs/blocks/blockstore"
+	"github.com/ipfs/go-ipfs/core"
+	events "github.com/ipfs/go-ipfs/core/events"
+
+	prometheus "gx/ipfs/QmX3QZ5jHEPidwUrymXV1iSCSUhdGxj15sm2gP4jKMef7B/client_golang/prometheus"
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+)
+
+// scrubTick is how often the scrubber verifies its share of the blocks.
+const scrubTick = time.Minute
+
+// scrubRefetchTimeout bounds the fetch of a corrupt pinned block.
+const scrubRefetchTimeout = 5 * time.Minute
+
+var (
+	scrubVerifiedMetric = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "ipfs",
+		Subsystem: "scrub",
+		Name:      "blocks_verified_total",
+		Help:      "Blocks verified against their hashes by the scrubber",
+	})
+	scrubCorruptMetric = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "ipfs",
+		Subsystem: "scrub",
+		Name:      "blocks_corrupt_total",
+		Help:      "Blocks found not to match their hashes by the scrubber",
+	})
+	scrubRefetchedMetric = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "ipfs",
+		Subsystem: "scrub",
+		Name:      "blocks_refetched_total",
+		Help:      "Corrupt pinned blocks fetched again from the network",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(scrubVerifiedMetric, scrubCorruptMetric, scrubRefetchedMetric)
+}
+
+// Scrubber verifies the blocks of the repo against their hashes, a fraction
+// of them a day, to detect their corruption on disk. The corrupt blocks are
+// removed, and the pinned ones fetched again from the network.
+type Scrubber struct {
+	Node          *core.IpfsNode
+	DailyFraction float64
+
+	// bs reads the blocks of the repo, hashing them, without the filestore
+	bs bstore.Blockstore
+}
+
+// ScrubResult is the outcome of the verification of a block.
+type ScrubResult struct {
+	Corrupt   bool
+	Pinned    bool
+	Refetched bool
+}
+
+func NewScrubber(n *core.IpfsNode) (*Scrubber, error) {
+	cfg, err := n.Repo.Config()
+	if err != nil {
+		return nil, err
+	}
+	fraction := cfg.Datastore.Scrub.DailyFraction
+	if fraction <= 0 {
+		return nil, errors.New("Datastore.Scrub.DailyFraction must be positive")
+	}
+
+	bs := bstore.NewBlockstore(n.Repo.Datastore())
+	bs.HashOnRead(true)
+	return &Scrubber{Node: n, DailyFraction: fraction, bs: bs}, nil
+}
+
+// Run verifies the blocks until ctx is done. Each pass goes over all the
+// blocks of the repo at the pace of the daily fraction, counted on the
+// blocks there were at its start.
+func (s *Scrubber) Run(ctx context.Context) error {
+	t := time.NewTicker(scrubTick)
+	defer t.Stop()
+
+	var (
+		keys   <-chan *cid.Cid
+		cancel context.CancelFunc = func() {}
+		total  int
+		budget float64
+	)
+	defer func() { cancel() }()
+
+	for {
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return nil
+		}
+
+		if keys == nil {
+			var err error
+			total, err = s.count(ctx)
+			if err != nil {
+				log.Errorf("scrubbing failed: %s", err)
+				continue
+			}
+			var passCtx context.Context
+			passCtx, cancel = context.WithCancel(ctx)
+			keys, err = s.bs.AllKeysChan(passCtx)
+			if err != nil {
+				cancel()
+				log.Errorf("scrubbing failed: %s", err)
+				continue
+			}
+		}
+
+		budget += s.DailyFraction * float64(total) * float64(scrubTick) / float64(24*time.Hour)
+		for ; budget >= 1; budget-- {
+			c, ok := <-keys
+			if !ok {
+				// the pass is over, the next one starts at the next tick
+				cancel()
+				keys = nil
+				break
+			}
+			if _, err := s.Verify(ctx, c); err != nil {
+				log.Warningf("cannot verify the block %s: %s", c, err)
+			}
+		}
+	}
+}
+
+// count returns the number of blocks in the repo.
+func (s *Scrubber) count(ctx context.Context) (int, error) {
+	keys, err := s.bs.AllKeysChan(ctx)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for range keys {
+		n++
+	}
+	return n, ctx.Err()
+}
+
+// Verify reads the block c, checking its hash. A corrupt block is removed,
+// and fetched again from the network if it is pinned and the node online.
+func (s *Scrubber) Verify(ctx context.Context, c *cid.Cid) (ScrubResult, error) {
+	var res ScrubResult
+	n := s.Node
+
+	_, err := s.bs.Get(c)
+	switch err {
+	case nil:
+		scrubVerifiedMetric.Inc()
+		return res, nil
+	case bstore.ErrNotFound:
+		// removed since the pass started
+		return res, nil
+	case bstore.ErrHashMismatch:
+	default:
+		return res, err
+	}
+
+	res.Corrupt = true
+	scrubVerifiedMetric.Inc()
+	scrubCorruptMetric.Inc()
+	n.Events.Publish(events.Corrupt, c)
+
+	_, res.Pinned, err = n.Pinning.IsPinned(c)
+	if err != nil {
+		// the walk of the pins may go through the corrupt block itself
+		log.Warningf("cannot tell whether the corrupt block %s is pinned, fetching it again: %s", c, err)
+		res.Pinned = true
+	}
+	log.Errorf("the block %s is corrupt on disk (pinned: %t), removing it", c, res.Pinned)
+
+	unlocker := n.Blockstore.GCLock()
+	err = journaled(n, "scrub").DeleteBlock(c)
+	unlocker.Unlock()
+	if err != nil {
+		return res, err
+	}
+
+	if !res.Pinned || !n.OnlineMode() {
+		if res.Pinned {
+			log.Errorf("the corrupt pinned block %s can't be fetched again while offline", c)
+		}
+		return res, nil
+	}
+
+	fetchCtx, cancel := context.WithTimeout(ctx, scrubRefetchTimeout)
+	defer cancel()
+	if _, err := n.Blocks.GetBlock(fetchCtx, c); err != nil {
+		log.Errorf("cannot fetch the corrupt pinned block %s again: %s", c, err)
+		return res, nil
+	}
+	res.Refetched = true
+	scrubRefetchedMetric.Inc()
+	log.Infof("fetched the corrupt pinned block %s again", c)
+	return res, nil
+}
@@ -0,0 +1,71 @@
+package corerepo
+
+import (
+	"context"
+	"testing"
+
+	blocks "github.com/ipfs/go-ipfs/blocks"
+	bstore "github.com/ipfs/go-ipfs/blocks/blockstore"
+	core "github.com/ipfs/go-ipfs/core"
+	"github.com/ipfs/go-ipfs/repo"
+	config "github.com/ipfs/go-ipfs/repo/config"
+	ds2 "github.com/ipfs/go-ipfs/thirdparty/datastore2"
+	dshelp "github.com/ipfs/go-ipfs/thirdparty/ds-help"
+	testutil "github.com/ipfs/go-ipfs/thirdparty/testutil"
+
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+	dssync "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore/sync"
+)
+
+func TestScrubberVerify(t *testing.T) {
+	ctx := context.Background()
+	ident, err := testutil.RandIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var conf config.Config
+	conf.Identity.PeerID = ident.ID().Pretty()
+
+	r := &repo.Mock{D: ds2.CloserWrap(dssync.MutexWrap(ds.NewMapDatastore())), C: conf}
+	n, err := core.NewNode(ctx, &core.BuildCfg{Repo: r})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewScrubber(n); err == nil {
+		t.Fatal("expected the scrubber to need a daily fraction")
+	}
+	conf.Datastore.Scrub.DailyFraction = 0.5
+	if err := r.SetConfig(&conf); err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewScrubber(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	good := blocks.NewBlock([]byte("good"))
+	if _, err := n.Blocks.AddBlock(good); err != nil {
+		t.Fatal(err)
+	}
+	res, err := s.Verify(ctx, good.Cid())
+	if err != nil || res.Corrupt {
+		t.Fatalf("expected the block to be sound, got %+v, %v", res, err)
+	}
+
+	// the data of a block written on disk under the key of another
+	bad := blocks.NewBlock([]byte("bad"))
+	key := bstore.BlockPrefix.Child(dshelp.CidToDsKey(bad.Cid()))
+	if err := r.Datastore().Put(key, []byte("rotten")); err != nil {
+		t.Fatal(err)
+	}
+	res, err = s.Verify(ctx, bad.Cid())
+	if err != nil || !res.Corrupt || res.Pinned || res.Refetched {
+		t.Fatalf("expected the block to be found corrupt, got %+v, %v", res, err)
+	}
+	if has, _ := n.Blockstore.Has(bad.Cid()); has {
+		t.Fatal("expected the corrupt block to be removed")
+	}
+	if has, _ := n.Blockstore.Has(good.Cid()); !has {
+		t.Fatal("expected the sound block to be kept")
+	}
+}
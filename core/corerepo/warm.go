@@ -0,0 +1,99 @@
+package corerepo
+
+import (
+	"context"
+
+	"github.com/ipfs/go-ipfs/core"
+
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+)
+
+// DefaultWarmBatch is the number of blocks Warm requests at once.
+const DefaultWarmBatch = 64
+
+// WarmStats counts the blocks walked by Warm.
+type WarmStats struct {
+	Blocks  int    // blocks walked
+	Local   int    // blocks already in the blockstore
+	Fetched int    // blocks fetched from the network
+	Bytes   uint64 // size of the fetched blocks
+}
+
+// Warm fetches the dag under root into the blockstore, down to depth links
+// from root, or all of it if depth is negative. The blocks are not pinned,
+// so the next garbage collection removes them.
+//
+// The dag is walked a level at a time, and the blocks of a level are
+// requested in batches of batch blocks, so that peers are asked for many
+// blocks at once rather than one after the other. progress, if not nil, is
+// called after each batch.
+func Warm(ctx context.Context, n *core.IpfsNode, root *cid.Cid, depth, batch int, progress func(WarmStats)) (WarmStats, error) {
+	// stops the requests left when a batch fails
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var stats WarmStats
+	if batch <= 0 {
+		batch = DefaultWarmBatch
+	}
+
+	seen := cid.NewSet()
+	seen.Add(root)
+	level := []*cid.Cid{root}
+
+	for d := 0; len(level) > 0 && (depth < 0 || d <= depth); d++ {
+		var next []*cid.Cid
+		for len(level) > 0 {
+			keys := level
+			if len(keys) > batch {
+				keys = keys[:batch]
+			}
+			level = level[len(keys):]
+
+			local := make(map[string]bool)
+			for _, c := range keys {
+				has, err := n.Blockstore.Has(c)
+				if err != nil {
+					return stats, err
+				}
+				if has {
+					local[c.KeyString()] = true
+				}
+			}
+
+			for opt := range n.DAG.GetMany(ctx, keys) {
+				if opt.Err != nil {
+					return stats, opt.Err
+				}
+				nd := opt.Node
+
+				stats.Blocks++
+				if local[nd.Cid().KeyString()] {
+					stats.Local++
+				} else {
+					stats.Fetched++
+					stats.Bytes += uint64(len(nd.RawData()))
+				}
+
+				// links below depth are not followed
+				if depth >= 0 && d == depth {
+					continue
+				}
+				for _, l := range nd.Links() {
+					if seen.Visit(l.Cid) {
+						next = append(next, l.Cid)
+					}
+				}
+			}
+			if err := ctx.Err(); err != nil {
+				return stats, err
+			}
+
+			if progress != nil {
+				progress(stats)
+			}
+		}
+		level = next
+	}
+	return stats, nil
+}
@@ -0,0 +1,147 @@
+package corerouting
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	core "github.com/ipfs/go-ipfs/core"
+	repo "github.com/ipfs/go-ipfs/repo"
+	config "github.com/ipfs/go-ipfs/repo/config"
+	delegated "github.com/ipfs/go-ipfs/routing/delegated"
+	lan "github.com/ipfs/go-ipfs/routing/lan"
+	stack "github.com/ipfs/go-ipfs/routing/stack"
+	static "github.com/ipfs/go-ipfs/routing/static"
+
+	routing "gx/ipfs/QmNdaQ8itUU9jEZUwTsG4gHMaPmRfi6FEe89QjQAFbep3M/go-libp2p-routing"
+	"gx/ipfs/QmUywuGNZoUKV8B9iyvup9bPkLiMrhTsyVMkeSXW5VxAfC/go-libp2p-host"
+)
+
+// Types of the routers of the Routing config.
+const (
+	RouterDHT       = "dht"
+	RouterDHTClient = "dhtclient"
+	RouterHTTP      = "http"
+	RouterStatic    = "static"
+	RouterMDNS      = "mdns"
+)
+
+// routerRoles are the roles each type of router supports.
+var routerRoles = map[string][]string{
+	RouterDHT:       {stack.RoleFind, stack.RoleProvide},
+	RouterDHTClient: {stack.RoleFind, stack.RoleProvide},
+	RouterHTTP:      {stack.RoleFind},
+	RouterStatic:    {stack.RoleFind},
+	RouterMDNS:      {stack.RoleFind},
+}
+
+// routerSpec is a checked router of the Routing config.
+type routerSpec struct {
+	config.Router
+	name          string
+	find, provide bool
+	timeout       time.Duration
+}
+
+// Stack returns the routing option composing the routers of cfg, after
+// checking them. The mapping files of the static routers are relative to
+// repoRoot.
+func Stack(cfg config.Routing, repoRoot string) (core.RoutingOption, error) {
+	var specs []routerSpec
+	dhts := 0
+	for i, rc := range cfg.Routers {
+		spec := routerSpec{Router: rc, name: fmt.Sprintf("%s router %d", rc.Type, i)}
+
+		supported, ok := routerRoles[rc.Type]
+		if !ok {
+			return nil, fmt.Errorf("Routing.Routers[%d]: unknown type %q", i, rc.Type)
+		}
+		roles := rc.Roles
+		if len(roles) == 0 {
+			roles = supported
+		}
+		for _, role := range roles {
+			if !hasRole(supported, role) {
+				return nil, fmt.Errorf("Routing.Routers[%d]: a %s router can't have the role %q", i, rc.Type, role)
+			}
+			switch role {
+			case stack.RoleFind:
+				spec.find = true
+			case stack.RoleProvide:
+				spec.provide = true
+			}
+		}
+
+		if rc.Timeout != "" {
+			d, err := time.ParseDuration(rc.Timeout)
+			if err != nil {
+				return nil, fmt.Errorf("Routing.Routers[%d]: invalid timeout: %s", i, err)
+			}
+			spec.timeout = d
+		}
+
+		switch rc.Type {
+		case RouterDHT, RouterDHTClient:
+			if dhts++; dhts > 1 {
+				return nil, fmt.Errorf("Routing.Routers[%d]: a node has one DHT only", i)
+			}
+		case RouterHTTP:
+			if rc.Endpoint == "" {
+				return nil, fmt.Errorf("Routing.Routers[%d]: an http router needs an Endpoint", i)
+			}
+		case RouterStatic:
+			if rc.Path == "" {
+				return nil, fmt.Errorf("Routing.Routers[%d]: a static router needs a Path", i)
+			}
+			if !filepath.IsAbs(rc.Path) {
+				spec.Path = filepath.Join(repoRoot, rc.Path)
+			}
+		}
+		specs = append(specs, spec)
+	}
+
+	return func(ctx context.Context, ph host.Host, dstore repo.Datastore) (routing.IpfsRouting, error) {
+		var routers []*stack.Router
+		for _, spec := range specs {
+			r, err := newRouter(ctx, spec, ph, dstore)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s", spec.name, err)
+			}
+			routers = append(routers, &stack.Router{
+				IpfsRouting: r,
+				Name:        spec.name,
+				CanFind:     spec.find,
+				CanProvide:  spec.provide,
+				Timeout:     spec.timeout,
+			})
+		}
+		return stack.New(routers, cfg.Parallel), nil
+	}, nil
+}
+
+func hasRole(roles []string, role string) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+func newRouter(ctx context.Context, spec routerSpec, ph host.Host, dstore repo.Datastore) (routing.IpfsRouting, error) {
+	switch spec.Type {
+	case RouterDHT:
+		return core.DHTOption(ctx, ph, dstore)
+	case RouterDHTClient:
+		return core.DHTClientOption(ctx, ph, dstore)
+	case RouterHTTP:
+		return delegated.New(spec.Endpoint)
+	case RouterStatic:
+		return static.Load(spec.Path)
+	case RouterMDNS:
+		return lan.New(ph), nil
+	default:
+		return nil, fmt.Errorf("unknown type %q", spec.Type)
+	}
+}
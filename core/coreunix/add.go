@@ -7,12 +7,14 @@ import (
 	"io/ioutil"
 	"os"
 	gopath "path"
+	"strings"
 
 	bs "github.com/ipfs/go-ipfs/blocks/blockstore"
 	bstore "github.com/ipfs/go-ipfs/blocks/blockstore"
 	bserv "github.com/ipfs/go-ipfs/blockservice"
 	"github.com/ipfs/go-ipfs/commands/files"
 	core "github.com/ipfs/go-ipfs/core"
+	events "github.com/ipfs/go-ipfs/core/events"
 	"github.com/ipfs/go-ipfs/exchange/offline"
 	balanced "github.com/ipfs/go-ipfs/importer/balanced"
 	"github.com/ipfs/go-ipfs/importer/chunk"
@@ -21,6 +23,7 @@ import (
 	dag "github.com/ipfs/go-ipfs/merkledag"
 	mfs "github.com/ipfs/go-ipfs/mfs"
 	"github.com/ipfs/go-ipfs/pin"
+	repo "github.com/ipfs/go-ipfs/repo"
 	posinfo "github.com/ipfs/go-ipfs/thirdparty/posinfo"
 	unixfs "github.com/ipfs/go-ipfs/unixfs"
 
@@ -65,9 +68,13 @@ func (e *ignoreFileError) Error() string {
 }
 
 type AddedObject struct {
-	Name  string
-	Hash  string `json:",omitempty"`
-	Bytes int64  `json:",omitempty"`
+	Name    string
+	Hash    string `json:",omitempty"`
+	Bytes   int64  `json:",omitempty"`
+	Profile string `json:",omitempty"`
+	// Total is the size of the content to add, when the node fetches it
+	// and knows it ahead, for the progress of the client.
+	Total int64 `json:",omitempty"`
 }
 
 func NewAdder(ctx context.Context, p pin.Pinner, bs bstore.GCBlockstore, ds dag.DAGService) (*Adder, error) {
@@ -101,12 +108,15 @@ type Adder struct {
 	Wrap       bool
 	NoCopy     bool
 	Chunker    string
+	Repo       repo.Repo   // passed on to indexers
+	Events     *events.Bus // notified of the root added, and pinned
 	root       node.Node
 	mroot      *mfs.Root
 	unlocker   bs.Unlocker
 	tempRoot   *cid.Cid
 	Prefix     *cid.Prefix
 	liveNodes  uint64
+	imported   []*ImportedFile // for the indexers, once the tree is complete
 }
 
 func (adder *Adder) mfsRoot() (*mfs.Root, error) {
@@ -202,7 +212,11 @@ func (adder *Adder) PinRoot() error {
 	}
 
 	adder.pinning.PinWithMode(rnk, pin.Recursive)
-	return adder.pinning.Flush()
+	if err := adder.pinning.Flush(); err != nil {
+		return err
+	}
+	adder.Events.Publish(events.Pinned, rnk)
+	return nil
 }
 
 func (adder *Adder) Finalize() (node.Node, error) {
@@ -246,12 +260,58 @@ func (adder *Adder) Finalize() (node.Node, error) {
 		return nil, err
 	}
 
+	if len(adder.imported) > 0 {
+		adder.indexImported(mr.GetValue().(*mfs.Directory))
+	}
+
 	err = mr.Close()
 	if err != nil {
 		return nil, err
 	}
 
-	return root.GetNode()
+	nd, err := root.GetNode()
+	if err != nil {
+		return nil, err
+	}
+	adder.Events.Publish(events.Added, nd.Cid())
+	return nd, nil
+}
+
+// indexImported passes the files imported to the indexers, at their path
+// under the root of the tree they were added in: the wrapping directory top,
+// or else the child of top they were added under.
+func (adder *Adder) indexImported(top *mfs.Directory) {
+	var wrapper string
+	if adder.Wrap {
+		nd, err := top.GetNode()
+		if err != nil {
+			log.Warningf("cannot index the added files: %s", err)
+			return
+		}
+		wrapper = nd.Cid().String()
+	}
+
+	for _, f := range adder.imported {
+		if wrapper != "" {
+			f.Path = gopath.Join("/ipfs", wrapper, f.Name)
+		} else {
+			parts := strings.SplitN(f.Name, "/", 2)
+			child, err := top.Child(parts[0])
+			if err != nil {
+				log.Warningf("cannot index %s: %s", f.Name, err)
+				continue
+			}
+			nd, err := child.GetNode()
+			if err != nil {
+				log.Warningf("cannot index %s: %s", f.Name, err)
+				continue
+			}
+			parts[0] = nd.Cid().String()
+			f.Path = gopath.Join(append([]string{"/ipfs"}, parts...)...)
+		}
+		runIndexers(adder.ctx, f)
+	}
+	adder.imported = nil
 }
 
 func (adder *Adder) outputDirs(path string, fsn mfs.FSNode) error {
@@ -309,6 +369,7 @@ func AddWithContext(ctx context.Context, n *core.IpfsNode, r io.Reader) (string,
 	if err != nil {
 		return "", err
 	}
+	n.Events.Publish(events.Added, node.Cid())
 
 	return node.Cid().String(), nil
 }
@@ -332,6 +393,8 @@ func AddR(n *core.IpfsNode, root string) (key string, err error) {
 	if err != nil {
 		return "", err
 	}
+	fileAdder.Repo = n.Repo
+	fileAdder.Events = n.Events
 
 	err = fileAdder.addFile(f)
 	if err != nil {
@@ -357,6 +420,8 @@ func AddWrapped(n *core.IpfsNode, r io.Reader, filename string) (string, node.No
 		return "", nil, err
 	}
 	fileAdder.Wrap = true
+	fileAdder.Repo = n.Repo
+	fileAdder.Events = n.Events
 
 	defer n.Blockstore.PinLock().Unlock()
 
@@ -472,13 +537,37 @@ func (adder *Adder) addFile(file files.File) error {
 		}
 	}
 
+	var sniff *sniffReader
+	if len(registeredIndexers()) > 0 {
+		sniff = &sniffReader{r: reader}
+		reader = sniff
+	}
+
 	dagnode, err := adder.add(reader)
 	if err != nil {
 		return err
 	}
 
 	// patch it into the root
-	return adder.addNode(dagnode, file.FileName())
+	if err := adder.addNode(dagnode, file.FileName()); err != nil {
+		return err
+	}
+
+	if sniff != nil {
+		if pi, ok := dagnode.(*posinfo.FilestoreNode); ok {
+			dagnode = pi.Node
+		}
+		adder.imported = append(adder.imported, &ImportedFile{
+			Name:     file.FileName(),
+			Size:     sniff.n,
+			Cid:      dagnode.Cid(),
+			MimeType: mimeType(file.FileName(), sniff.head),
+			Repo:     adder.Repo,
+			nd:       dagnode,
+			dserv:    adder.dagService,
+		})
+	}
+	return nil
 }
 
 func (adder *Adder) addDir(dir files.File) error {
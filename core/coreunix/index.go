@@ -0,0 +1,173 @@
+package coreunix
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	gopath "path"
+	"sync"
+
+	core "github.com/ipfs/go-ipfs/core"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	mfs "github.com/ipfs/go-ipfs/mfs"
+	repo "github.com/ipfs/go-ipfs/repo"
+	uio "github.com/ipfs/go-ipfs/unixfs/io"
+
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+	node "gx/ipfs/Qmb3Hm9QDFmfYuET4pu7Kyg8JV78jFa1nvZx5vnCZsK4ck/go-ipld-format"
+)
+
+// sniffLen is the number of bytes http.DetectContentType looks at.
+const sniffLen = 512
+
+// ImportedFile describes a file imported by 'ipfs add' or written with
+// 'ipfs files write'.
+type ImportedFile struct {
+	// Path is the path of the file in IPFS: /ipfs/<root>/<path>, where
+	// root is the top of the added tree the file is part of, or
+	// /mfs/<path> for MFS writes.
+	Path string
+	// Name is the path of the file as imported: its path in the added
+	// tree, starting with the name of the tree, or its MFS path.
+	Name     string
+	Size     uint64
+	Cid      *cid.Cid
+	MimeType string
+	// Repo is the repo the file was imported into, if known.
+	Repo repo.Repo
+
+	nd    node.Node
+	dserv dag.DAGService
+}
+
+// Open returns the content of the file, read back from the dag service.
+func (f *ImportedFile) Open(ctx context.Context) (io.ReadCloser, error) {
+	return uio.NewDagReader(ctx, f.nd, f.dserv)
+}
+
+// Indexer receives the files imported by the node, to build search indexes
+// and the like.
+type Indexer struct {
+	// Name identifies the indexer in errors and logs; it must be unique.
+	Name string
+
+	// Index is called once the file is imported, and for adds, once the
+	// added tree is complete. Indexers with heavy work to do should queue it
+	// rather than slow the import down. Errors are logged, and do not fail
+	// the import.
+	Index func(ctx context.Context, f *ImportedFile) error
+}
+
+var indexers = struct {
+	sync.Mutex
+	list []Indexer
+}{}
+
+// RegisterIndexer adds ix to the indexers notified of imported files. It is
+// meant to be called from init functions.
+func RegisterIndexer(ix Indexer) error {
+	if ix.Name == "" {
+		return fmt.Errorf("indexer has no name")
+	}
+	if ix.Index == nil {
+		return fmt.Errorf("indexer %s has no Index function", ix.Name)
+	}
+
+	indexers.Lock()
+	defer indexers.Unlock()
+
+	for _, other := range indexers.list {
+		if other.Name == ix.Name {
+			return fmt.Errorf("indexer %s is already registered", ix.Name)
+		}
+	}
+	indexers.list = append(indexers.list, ix)
+	return nil
+}
+
+func registeredIndexers() []Indexer {
+	indexers.Lock()
+	defer indexers.Unlock()
+	return indexers.list
+}
+
+// mimeType guesses the type of the file at pth, starting with head, from its
+// extension and then from its content.
+func mimeType(pth string, head []byte) string {
+	if t := mime.TypeByExtension(gopath.Ext(pth)); t != "" {
+		return t
+	}
+	return http.DetectContentType(head)
+}
+
+func runIndexers(ctx context.Context, f *ImportedFile) {
+	for _, ix := range registeredIndexers() {
+		if err := ix.Index(ctx, f); err != nil {
+			log.Warningf("indexer %s failed on %s: %s", ix.Name, f.Path, err)
+		}
+	}
+}
+
+// sniffReader counts the bytes read through it, and keeps the first ones to
+// guess their type.
+type sniffReader struct {
+	r    io.Reader
+	n    uint64
+	head []byte
+}
+
+func (s *sniffReader) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	if len(s.head) < sniffLen {
+		rest := sniffLen - len(s.head)
+		if rest > n {
+			rest = n
+		}
+		s.head = append(s.head, p[:rest]...)
+	}
+	s.n += uint64(n)
+	return n, err
+}
+
+// IndexMFSFile passes the file at pth in MFS, just written, to the
+// registered indexers.
+func IndexMFSFile(ctx context.Context, n *core.IpfsNode, pth string, fi *mfs.File) error {
+	if len(registeredIndexers()) == 0 {
+		return nil
+	}
+
+	nd, err := fi.GetNode()
+	if err != nil {
+		return err
+	}
+	size, err := fi.Size()
+	if err != nil {
+		return err
+	}
+
+	r, err := uio.NewDagReader(ctx, nd, n.DAG)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	head := make([]byte, sniffLen)
+	read, err := io.ReadFull(r, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+
+	runIndexers(ctx, &ImportedFile{
+		Path:     gopath.Join("/mfs", pth),
+		Name:     pth,
+		Size:     uint64(size),
+		Cid:      nd.Cid(),
+		MimeType: mimeType(pth, head[:read]),
+		Repo:     n.Repo,
+		nd:       nd,
+		dserv:    n.DAG,
+	})
+	return nil
+}
@@ -0,0 +1,75 @@
+package coreunix
+
+import (
+	"context"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/go-ipfs/core"
+	"github.com/ipfs/go-ipfs/repo"
+	"github.com/ipfs/go-ipfs/repo/config"
+	"github.com/ipfs/go-ipfs/thirdparty/testutil"
+)
+
+func TestIndexerOnAdd(t *testing.T) {
+	defer func() { indexers.list = nil }()
+
+	var got []*ImportedFile
+	ix := Indexer{
+		Name: "test",
+		Index: func(ctx context.Context, f *ImportedFile) error {
+			got = append(got, f)
+			return nil
+		},
+	}
+	if err := RegisterIndexer(ix); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterIndexer(ix); err == nil {
+		t.Fatal("expected a duplicate indexer to be rejected")
+	}
+
+	r := &repo.Mock{
+		C: config.Config{
+			Identity: config.Identity{
+				PeerID: "Qmfoo", // required by offline node
+			},
+		},
+		D: testutil.ThreadSafeCloserMapDatastore(),
+	}
+	node, err := core.NewNode(context.Background(), &core.BuildCfg{Repo: r})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content := "<html><body>hello</body></html>"
+	added, _, err := AddWrapped(node, strings.NewReader(content), "page")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != 1 {
+		t.Fatalf("expected 1 indexed file, got %d", len(got))
+	}
+	f := got[0]
+	if f.Name != "page" || f.Path != "/ipfs/"+added || f.Size != uint64(len(content)) {
+		t.Fatalf("unexpected file: %s (%s) of %d bytes", f.Path, f.Name, f.Size)
+	}
+	if !strings.HasPrefix(f.MimeType, "text/html") {
+		t.Fatalf("expected text/html, got %s", f.MimeType)
+	}
+
+	rd, err := f.Open(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rd.Close()
+	b, err := ioutil.ReadAll(rd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != content {
+		t.Fatalf("expected %q, got %q", content, b)
+	}
+}
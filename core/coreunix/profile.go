@@ -0,0 +1,133 @@
+package coreunix
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	chunk "github.com/ipfs/go-ipfs/importer/chunk"
+
+	mh "gx/ipfs/QmVGtdTZdTFaLsaj2RwdVG8jcjNNcp1DE914DKZ2kHmXHw/go-multihash"
+)
+
+// addProfileVersion is bumped whenever the way an add is turned into a dag
+// changes for the same parameters.
+const addProfileVersion = "1"
+
+// AddProfile lists every parameter of an add which affects the resulting
+// hashes. Adding the same data with the same profile always yields the same
+// hashes.
+type AddProfile struct {
+	Chunker    string
+	Layout     string
+	CidVersion int
+	RawLeaves  bool
+	Hash       string
+	Wrap       bool
+	Hidden     bool
+}
+
+// Layouts of an AddProfile.
+const (
+	LayoutBalanced = "balanced"
+	LayoutTrickle  = "trickle"
+)
+
+// Normalize spells out the defaults of p, so that two profiles producing the
+// same hashes are equal.
+func (p *AddProfile) Normalize() error {
+	chunker, err := chunk.Canonical(p.Chunker)
+	if err != nil {
+		return err
+	}
+	p.Chunker = chunker
+
+	switch p.Layout {
+	case "":
+		p.Layout = LayoutBalanced
+	case LayoutBalanced, LayoutTrickle:
+	default:
+		return fmt.Errorf("unknown layout: %s", p.Layout)
+	}
+
+	if p.CidVersion != 0 && p.CidVersion != 1 {
+		return fmt.Errorf("unknown cid version: %d", p.CidVersion)
+	}
+
+	p.Hash = strings.ToLower(p.Hash)
+	if p.Hash == "" {
+		p.Hash = "sha2-256"
+	}
+	if _, ok := mh.Names[p.Hash]; !ok {
+		return fmt.Errorf("unrecognized hash function: %s", p.Hash)
+	}
+	if p.CidVersion == 0 && p.Hash != "sha2-256" {
+		return fmt.Errorf("cid version 0 only supports sha2-256")
+	}
+	return nil
+}
+
+// String returns the profile in the form accepted by ParseAddProfile.
+func (p AddProfile) String() string {
+	return fmt.Sprintf("v=%s,chunker=%s,layout=%s,cid-version=%d,raw-leaves=%t,hash=%s,wrap=%t,hidden=%t",
+		addProfileVersion, p.Chunker, p.Layout, p.CidVersion, p.RawLeaves, p.Hash, p.Wrap, p.Hidden)
+}
+
+// ParseAddProfile parses a profile printed by AddProfile.String. Every
+// parameter must be present.
+func ParseAddProfile(s string) (AddProfile, error) {
+	var p AddProfile
+
+	fields := make(map[string]string)
+	for _, f := range strings.Split(s, ",") {
+		kv := strings.SplitN(f, "=", 2)
+		if len(kv) != 2 {
+			return p, fmt.Errorf("invalid add profile field: %q", f)
+		}
+		fields[kv[0]] = kv[1]
+	}
+
+	if v := fields["v"]; v != addProfileVersion {
+		return p, fmt.Errorf("unsupported add profile version: %q", v)
+	}
+
+	var err error
+	get := func(key string) string {
+		v, ok := fields[key]
+		if !ok && err == nil {
+			err = fmt.Errorf("add profile is missing %s", key)
+		}
+		return v
+	}
+	getBool := func(key string) bool {
+		b, perr := strconv.ParseBool(get(key))
+		if perr != nil && err == nil {
+			err = fmt.Errorf("invalid %s in add profile", key)
+		}
+		return b
+	}
+
+	p.Chunker = get("chunker")
+	p.Layout = get("layout")
+	p.Hash = get("hash")
+	p.RawLeaves = getBool("raw-leaves")
+	p.Wrap = getBool("wrap")
+	p.Hidden = getBool("hidden")
+	cidVersion, perr := strconv.Atoi(get("cid-version"))
+	if perr != nil && err == nil {
+		err = fmt.Errorf("invalid cid-version in add profile")
+	}
+	p.CidVersion = cidVersion
+	if err != nil {
+		return p, err
+	}
+
+	if len(fields) != 8 {
+		return p, fmt.Errorf("add profile has unknown fields")
+	}
+
+	if err := p.Normalize(); err != nil {
+		return p, err
+	}
+	return p, nil
+}
@@ -0,0 +1,33 @@
+package coreunix
+
+import (
+	"testing"
+)
+
+func TestAddProfileRoundTrip(t *testing.T) {
+	p := AddProfile{Chunker: "rabin", Layout: LayoutTrickle, CidVersion: 1, RawLeaves: true, Hash: "SHA2-512"}
+	if err := p.Normalize(); err != nil {
+		t.Fatal(err)
+	}
+
+	if p.Chunker != "rabin-87381-262144-393216" {
+		t.Fatalf("chunker was not spelled out: %s", p.Chunker)
+	}
+
+	parsed, err := ParseAddProfile(p.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed != p {
+		t.Fatalf("expected %s, got %s", p, parsed)
+	}
+
+	if _, err := ParseAddProfile("v=1,chunker=size-262144"); err == nil {
+		t.Fatal("expected an incomplete profile to be rejected")
+	}
+
+	p = AddProfile{Hash: "sha2-512"}
+	if err := p.Normalize(); err == nil {
+		t.Fatal("expected cid version 0 with sha2-512 to be rejected")
+	}
+}
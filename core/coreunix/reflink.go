@@ -0,0 +1,140 @@
+package coreunix
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/ipfs/go-ipfs/commands/files"
+)
+
+// Link modes of the files added, which tell how their data gets to the repo.
+const (
+	// LinkCopy copies the data of the files into the blockstore.
+	LinkCopy = "copy"
+	// LinkNoCopy references the data of the files in the filestore.
+	LinkNoCopy = "nocopy"
+	// LinkReflink clones the files into the repo and references the clones
+	// in the filestore.
+	LinkReflink = "reflink"
+	// LinkAuto clones the files which can be cloned and copies the others.
+	LinkAuto = "auto"
+)
+
+// ReflinkDir is the directory of the repo the clones of the added files are
+// stored in.
+const ReflinkDir = "reflinks"
+
+// ErrReflinkUnsupported is returned when files can't be cloned on this system.
+var ErrReflinkUnsupported = errors.New("reflinks are not supported on this system")
+
+// Reflinker replaces the files added by their clones in a directory of the
+// repo, on filesystems where clones share their data until it is modified,
+// such as btrfs and XFS. The filestore references the clones, so adding the
+// files copies no data, and later changes of the files don't break their
+// blocks.
+type Reflinker struct {
+	Dir string
+	// Fallback has the files which can't be cloned copied, instead of
+	// failing the add.
+	Fallback bool
+}
+
+// Wrap returns f with the files it holds replaced by their clones.
+func (r *Reflinker) Wrap(f files.File) files.File {
+	return &reflinkDir{File: f, r: r}
+}
+
+func (r *Reflinker) clone(f files.File) (files.File, error) {
+	fi, ok := f.(files.FileInfo)
+	if !ok || fi.AbsPath() == "" {
+		return r.fallback(f, errors.New("its path is unknown"))
+	}
+
+	src, err := os.Open(fi.AbsPath())
+	if err != nil {
+		return r.fallback(f, err)
+	}
+	defer src.Close()
+
+	if err := os.MkdirAll(r.Dir, 0755); err != nil {
+		return nil, err
+	}
+	dst, err := ioutil.TempFile(r.Dir, "")
+	if err != nil {
+		return nil, err
+	}
+	if err := reflink(dst, src); err != nil {
+		dst.Close()
+		os.Remove(dst.Name())
+		return r.fallback(f, err)
+	}
+	stat, err := dst.Stat()
+	if err != nil {
+		dst.Close()
+		return nil, err
+	}
+
+	// the data is read from the clone, which can't change meanwhile
+	f.Close()
+	return &clonedFile{File: f, clone: dst, stat: stat}, nil
+}
+
+func (r *Reflinker) fallback(f files.File, err error) (files.File, error) {
+	if !r.Fallback {
+		return nil, fmt.Errorf("cannot clone %s: %s", f.FullPath(), err)
+	}
+	log.Debugf("copying %s, which cannot be cloned: %s", f.FullPath(), err)
+	// hide the path of the file, so that the data is copied
+	return copiedFile{f}, nil
+}
+
+// reflinkDir replaces the files of a directory by their clones.
+type reflinkDir struct {
+	files.File
+	r *Reflinker
+}
+
+func (d *reflinkDir) NextFile() (files.File, error) {
+	f, err := d.File.NextFile()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := f.(*files.Symlink); ok {
+		return f, nil
+	}
+	if f.IsDirectory() {
+		return d.r.Wrap(f), nil
+	}
+	return d.r.clone(f)
+}
+
+// clonedFile is a file read from its clone in the repo.
+type clonedFile struct {
+	files.File
+	clone *os.File
+	stat  os.FileInfo
+}
+
+func (f *clonedFile) Read(p []byte) (int, error) {
+	return f.clone.Read(p)
+}
+
+func (f *clonedFile) Close() error {
+	return f.clone.Close()
+}
+
+func (f *clonedFile) AbsPath() string {
+	return f.clone.Name()
+}
+
+func (f *clonedFile) Stat() os.FileInfo {
+	return f.stat
+}
+
+// copiedFile is a file with no path, of which the data is copied.
+type copiedFile struct {
+	files.File
+}
@@ -0,0 +1,11 @@
+// +build !linux
+
+package coreunix
+
+import (
+	"os"
+)
+
+func reflink(dst, src *os.File) error {
+	return ErrReflinkUnsupported
+}
@@ -0,0 +1,65 @@
+package coreunix
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/go-ipfs/commands/files"
+)
+
+func TestReflinker(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reflink")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(src, []byte("hello world\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	stat, err := os.Stat(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := &Reflinker{Dir: filepath.Join(dir, ReflinkDir), Fallback: true}
+	for i := 0; i < 2; i++ {
+		f, err := files.NewSerialFile("file", src, false, stat)
+		if err != nil {
+			t.Fatal(err)
+		}
+		input := r.Wrap(files.NewSliceFile("", "", []files.File{f}))
+
+		added, err := input.NextFile()
+		if err != nil {
+			// only without fallback, when files can't be cloned here
+			if r.Fallback || !strings.Contains(err.Error(), "cannot clone") {
+				t.Fatal(err)
+			}
+			continue
+		}
+		b, err := ioutil.ReadAll(added)
+		added.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != "hello world\n" || added.FileName() != "file" {
+			t.Fatalf("unexpected file %s: %q", added.FileName(), b)
+		}
+
+		// the clones are referenced from the repo, the copies not at all
+		if fi, ok := added.(files.FileInfo); ok {
+			if filepath.Dir(fi.AbsPath()) != r.Dir {
+				t.Fatalf("expected a clone in %s, got %s", r.Dir, fi.AbsPath())
+			}
+		} else if _, ok := added.(copiedFile); !ok {
+			t.Fatalf("expected a clone or a copy, got %T", added)
+		}
+
+		r.Fallback = false
+	}
+}
@@ -0,0 +1,240 @@
+package coreunix
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	gopath "path"
+	"strings"
+	"time"
+
+	"github.com/ipfs/go-ipfs/commands/files"
+	config "github.com/ipfs/go-ipfs/repo/config"
+
+	humanize "gx/ipfs/QmPSBJL4momYnE7DcUyk2DVhD6rH488ZmHBGLbxNdhU44K/go-humanize"
+)
+
+// Defaults of the Import config.
+var (
+	DefaultURLSchemes = []string{"http", "https"}
+	DefaultURLMaxSize = "1GiB"
+	DefaultURLTimeout = 10 * time.Minute
+)
+
+// privateNets are the ranges of the private, shared and loopback addresses,
+// which the fetches are denied unless Import.URLAllowPrivate is set.
+var privateNets = func() []*net.IPNet {
+	var nets []*net.IPNet
+	for _, s := range []string{"0.0.0.0/8", "10.0.0.0/8", "100.64.0.0/10", "127.0.0.0/8", "169.254.0.0/16", "172.16.0.0/12", "192.168.0.0/16", "::/128", "::1/128", "fc00::/7", "fe80::/10"} {
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			panic(err)
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}()
+
+// ErrURLTooLarge is returned when reading more than the maximal size of a
+// fetched URL.
+var ErrURLTooLarge = errors.New("the content of the URL exceeds Import.URLMaxSize")
+
+// URLFetcher fetches URLs to add them, within the limits of the Import
+// config.
+type URLFetcher struct {
+	Schemes []string
+	MaxSize uint64 // 0 for no limit
+	Timeout time.Duration
+	// AllowPrivate allows the hosts with private or loopback addresses,
+	// which are denied by default, including after redirects.
+	AllowPrivate bool
+
+	// Client is the client of the requests, http.DefaultClient if nil.
+	Client *http.Client
+}
+
+// NewURLFetcher returns a fetcher of the URLs cfg allows.
+func NewURLFetcher(cfg config.Import) (*URLFetcher, error) {
+	f := &URLFetcher{
+		Schemes:      cfg.URLSchemes,
+		Timeout:      DefaultURLTimeout,
+		AllowPrivate: cfg.URLAllowPrivate,
+	}
+	if len(f.Schemes) == 0 {
+		f.Schemes = DefaultURLSchemes
+	}
+
+	maxSize := cfg.URLMaxSize
+	if maxSize == "" {
+		maxSize = DefaultURLMaxSize
+	}
+	if maxSize != "0" {
+		size, err := humanize.ParseBytes(maxSize)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Import.URLMaxSize: %s", err)
+		}
+		f.MaxSize = size
+	}
+
+	if cfg.URLTimeout != "" {
+		timeout, err := time.ParseDuration(cfg.URLTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Import.URLTimeout: %s", err)
+		}
+		f.Timeout = timeout
+	}
+	return f, nil
+}
+
+func (f *URLFetcher) checkScheme(u *url.URL) error {
+	for _, s := range f.Schemes {
+		if strings.EqualFold(s, u.Scheme) {
+			return nil
+		}
+	}
+	return fmt.Errorf("fetching %s URLs is not allowed, see Import.URLSchemes", u.Scheme)
+}
+
+// Fetch requests rawurl and returns its content as a file to add, along with
+// its size, or -1 if the server didn't tell it. The file is named after the
+// last element of the path of the URL.
+func (f *URLFetcher) Fetch(ctx context.Context, rawurl string) (files.File, int64, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := f.checkScheme(u); err != nil {
+		return nil, 0, err
+	}
+
+	client := f.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	// redirects must not lead to schemes which are not allowed
+	c := *client
+	c.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		return f.checkScheme(req.URL)
+	}
+	if !f.AllowPrivate {
+		// without a proxy, which would fetch any address. Each connection,
+		// the ones of the redirects included, is checked as it is dialed.
+		c.Transport = &http.Transport{
+			DialContext:         dialPublic,
+			TLSHandshakeTimeout: 10 * time.Second,
+		}
+	}
+
+	var cancel context.CancelFunc
+	if f.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, f.Timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		cancel()
+		return nil, 0, err
+	}
+	res, err := c.Do(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, 0, err
+	}
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		cancel()
+		return nil, 0, fmt.Errorf("fetching %s: %s", u, res.Status)
+	}
+	if f.MaxSize > 0 && res.ContentLength > int64(f.MaxSize) {
+		res.Body.Close()
+		cancel()
+		return nil, 0, ErrURLTooLarge
+	}
+
+	body := &urlBody{
+		ReadCloser: res.Body,
+		cancel:     cancel,
+		left:       int64(f.MaxSize),
+		limited:    f.MaxSize > 0,
+	}
+	return files.NewReaderFile(urlFileName(u), "", body, nil), res.ContentLength, nil
+}
+
+// dialPublic dials addr unless its host has private or loopback addresses.
+// The address checked is the one dialed, so that the host cannot resolve to
+// another one in between.
+func dialPublic(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	for _, ip := range ips {
+		for _, n := range privateNets {
+			if n.Contains(ip.IP) {
+				return nil, fmt.Errorf("fetching the URLs of %s is denied, it has the private address %s, see Import.URLAllowPrivate", host, ip.IP)
+			}
+		}
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("no address for %s", host)
+	}
+
+	var d net.Dialer
+	return d.DialContext(ctx, network, net.JoinHostPort(ips[0].IP.String(), port))
+}
+
+// urlFileName returns the name of the file of u.
+func urlFileName(u *url.URL) string {
+	name := gopath.Base(u.Path)
+	if name == "." || name == "/" {
+		return u.Host
+	}
+	return name
+}
+
+// urlBody is the body of a fetched URL, which fails once it is larger than
+// the maximal size.
+type urlBody struct {
+	io.ReadCloser
+	cancel  context.CancelFunc
+	left    int64
+	limited bool
+}
+
+func (b *urlBody) Read(p []byte) (int, error) {
+	if !b.limited {
+		return b.ReadCloser.Read(p)
+	}
+	if b.left < 0 {
+		return 0, ErrURLTooLarge
+	}
+	// read one byte more than allowed, to tell a body of the maximal size
+	// from a larger one
+	if int64(len(p)) > b.left+1 {
+		p = p[:b.left+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.left -= int64(n)
+	if b.left < 0 {
+		return n, ErrURLTooLarge
+	}
+	return n, err
+}
+
+func (b *urlBody) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,79 @@
+package coreunix
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/go-ipfs/repo/config"
+)
+
+func TestURLFetcher(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/files/hello.txt":
+			w.Write([]byte("hello world\n"))
+		case "/large":
+			// no Content-Length, the size is only found while reading
+			w.Write([]byte(strings.Repeat("a", 100)))
+			w.(http.Flusher).Flush()
+		case "/ftp":
+			http.Redirect(w, r, "ftp://example.com/file", http.StatusFound)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+	_, tsPort, err := net.SplitHostPort(ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the test server listens on a loopback address
+	f, err := NewURLFetcher(config.Import{URLMaxSize: "50B", URLAllowPrivate: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	file, size, err := f.Fetch(ctx, ts.URL+"/files/hello.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(file)
+	file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.FileName() != "hello.txt" || string(b) != "hello world\n" || size != 12 {
+		t.Fatalf("unexpected file %q of %d bytes: %q", file.FileName(), size, b)
+	}
+
+	file, _, err = f.Fetch(ctx, ts.URL+"/large")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ioutil.ReadAll(file)
+	file.Close()
+	if err != ErrURLTooLarge {
+		t.Fatalf("expected the content to be too large, got %v", err)
+	}
+
+	for _, u := range []string{ts.URL + "/missing", ts.URL + "/ftp", "file:///etc/passwd"} {
+		if _, _, err := f.Fetch(ctx, u); err == nil {
+			t.Fatalf("expected fetching %s to fail", u)
+		}
+	}
+
+	f.AllowPrivate = false
+	if _, _, err := f.Fetch(ctx, ts.URL+"/files/hello.txt"); err == nil {
+		t.Fatal("expected fetching a loopback address to be denied")
+	}
+	if _, _, err := f.Fetch(ctx, "http://localhost:"+tsPort+"/files/hello.txt"); err == nil {
+		t.Fatal("expected fetching a name resolving to a loopback address to be denied")
+	}
+}
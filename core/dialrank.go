@@ -0,0 +1,306 @@
+package core
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	pstore "gx/ipfs/QmXZSd1qR5BxZkPyuwfT5jpqQFScZccoZvDneXsKzCNHWX/go-libp2p-peerstore"
+	ma "gx/ipfs/QmcyqRMCAXVtYPS4DiBrA7sezL9rRGfW8Ctx7cywL4TXJj/go-multiaddr"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+// DefaultDialTransports is the order in which transports are preferred when
+// dialing a peer, unless set by Swarm.DialTransports.
+var DefaultDialTransports = []string{"quic", "tcp", "ws", "utp", "udt"}
+
+// Scopes of an address, in the order they are dialed for the peers sharing a
+// network with the node. Closer networks are usually faster to connect to,
+// and relays are only a fallback.
+const (
+	scopeLoopback = iota
+	scopePrivate
+	scopePublic
+	scopeUnknown
+	scopeRelay
+)
+
+// remoteScopeOrder is the order in which the scopes are dialed for the other
+// peers, whose loopback and private addresses are those of their own
+// networks, rarely reachable from ours.
+var remoteScopeOrder = map[int]int{
+	scopePublic:   0,
+	scopeUnknown:  1,
+	scopePrivate:  2,
+	scopeLoopback: 3,
+	scopeRelay:    4,
+}
+
+var privateNets = func() []*net.IPNet {
+	var nets []*net.IPNet
+	for _, s := range []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "100.64.0.0/10", "169.254.0.0/16", "fc00::/7", "fe80::/10"} {
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			panic(err)
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}()
+
+// Values of Swarm.AddressFamily.
+const (
+	familyAny     = ""
+	familyIP4     = "ip4"
+	familyIP6     = "ip6"
+	familyIP4Only = "ip4-only"
+	familyIP6Only = "ip6-only"
+)
+
+// dnsFamilies are the families of the addresses the dns4 and dns6 names
+// resolve to.
+var dnsFamilies = map[string]string{
+	"dns4": familyIP4,
+	"dns6": familyIP6,
+}
+
+// addressFamily is a preference for one of IPv4 and IPv6.
+type addressFamily struct {
+	// prefer is "ip4" or "ip6", or empty for no preference.
+	prefer string
+	// only drops the addresses of the other family.
+	only bool
+}
+
+func parseAddressFamily(s string) (addressFamily, error) {
+	switch strings.ToLower(s) {
+	case familyAny:
+		return addressFamily{}, nil
+	case familyIP4:
+		return addressFamily{prefer: familyIP4}, nil
+	case familyIP6:
+		return addressFamily{prefer: familyIP6}, nil
+	case familyIP4Only:
+		return addressFamily{prefer: familyIP4, only: true}, nil
+	case familyIP6Only:
+		return addressFamily{prefer: familyIP6, only: true}, nil
+	default:
+		return addressFamily{}, fmt.Errorf("invalid Swarm.AddressFamily %q, expected one of ip4, ip6, ip4-only and ip6-only", s)
+	}
+}
+
+// rank is 0 for addresses of the preferred family or without IP, and 1 for
+// the others.
+func (f addressFamily) rank(family string) int {
+	if f.prefer == "" || family == "" || family == f.prefer {
+		return 0
+	}
+	return 1
+}
+
+// allows tells whether addresses of family may be used.
+func (f addressFamily) allows(family string) bool {
+	return !f.only || family == "" || family == f.prefer
+}
+
+// undialableIP tells whether ip cannot be dialed from its address alone:
+// IPv6 link-local addresses need the zone, that is the interface, they are
+// reachable on.
+func undialableIP(family string, ip net.IP, hasZone bool) bool {
+	return family == familyIP6 && ip.IsLinkLocalUnicast() && !hasZone
+}
+
+// rankedPeerstore returns the addresses of peers best first. The swarm dials
+// the addresses of a peer in parallel, in the order of the peerstore, and
+// cancels the remaining dials once one succeeds; with many addresses, the
+// dial limiter only lets the first few start right away.
+type rankedPeerstore struct {
+	pstore.Peerstore
+	transports map[string]int
+	family     addressFamily
+
+	// localNets returns the networks of the interfaces of the node
+	localNets func() []*net.IPNet
+}
+
+func newRankedPeerstore(ps pstore.Peerstore, transports []string, family addressFamily) *rankedPeerstore {
+	if len(transports) == 0 {
+		transports = DefaultDialTransports
+	}
+
+	rp := &rankedPeerstore{
+		Peerstore:  ps,
+		transports: make(map[string]int),
+		family:     family,
+		localNets:  interfaceNets,
+	}
+	for i, t := range transports {
+		rp.transports[strings.ToLower(t)] = i
+	}
+	return rp
+}
+
+func (rp *rankedPeerstore) Addrs(p peer.ID) []ma.Multiaddr {
+	return rp.rank(rp.Peerstore.Addrs(p))
+}
+
+func (rp *rankedPeerstore) PeerInfo(p peer.ID) pstore.PeerInfo {
+	return pstore.PeerInfo{ID: p, Addrs: rp.Addrs(p)}
+}
+
+type rankedAddr struct {
+	addr      ma.Multiaddr
+	ip        net.IP
+	scope     int
+	family    int
+	transport int
+	dialable  bool
+}
+
+type rankedAddrs []rankedAddr
+
+func (r rankedAddrs) Len() int      { return len(r) }
+func (r rankedAddrs) Swap(i, j int) { r[i], r[j] = r[j], r[i] }
+func (r rankedAddrs) Less(i, j int) bool {
+	if r[i].scope != r[j].scope {
+		return r[i].scope < r[j].scope
+	}
+	if r[i].family != r[j].family {
+		return r[i].family < r[j].family
+	}
+	return r[i].transport < r[j].transport
+}
+
+func (rp *rankedPeerstore) rank(addrs []ma.Multiaddr) []ma.Multiaddr {
+	ranked := make(rankedAddrs, 0, len(addrs))
+	for _, a := range addrs {
+		if r := rp.classify(a); r.dialable {
+			ranked = append(ranked, r)
+		}
+	}
+	if !rp.sharesNetwork(ranked) {
+		for i := range ranked {
+			ranked[i].scope = remoteScopeOrder[ranked[i].scope]
+		}
+	}
+	sort.Stable(ranked)
+
+	out := make([]ma.Multiaddr, len(ranked))
+	for i, r := range ranked {
+		out[i] = r.addr
+	}
+	return out
+}
+
+// classify finds the scope, family and transport rank of a, and whether it
+// can be dialed at all.
+func (rp *rankedPeerstore) classify(a ma.Multiaddr) rankedAddr {
+	r := rankedAddr{
+		addr:      a,
+		scope:     scopeUnknown,
+		transport: len(rp.transports),
+		dialable:  true,
+	}
+
+	hasZone := false
+	parts := strings.Split(a.String(), "/")
+	for i := 1; i < len(parts); i++ {
+		switch parts[i] {
+		case "p2p-circuit":
+			r.scope = scopeRelay
+			return r
+		case "ip6zone":
+			hasZone = true
+		case "ip4", "ip6":
+			if i+1 < len(parts) && r.scope == scopeUnknown {
+				ip := net.ParseIP(parts[i+1])
+				r.ip = ip
+				r.scope = ipScope(ip)
+				r.family = rp.family.rank(parts[i])
+				r.dialable = rp.family.allows(parts[i]) && !(ip != nil && undialableIP(parts[i], ip, hasZone))
+			}
+		case "dns4", "dns6":
+			if r.scope == scopeUnknown {
+				family := dnsFamilies[parts[i]]
+				r.family = rp.family.rank(family)
+				r.dialable = rp.family.allows(family)
+			}
+		default:
+			// the outermost transport counts, e.g. ws over tcp
+			if t, ok := rp.transports[parts[i]]; ok {
+				r.transport = t
+			}
+		}
+	}
+	return r
+}
+
+// sharesNetwork tells whether one of the private addresses of a peer is in a
+// network of the node, so that it is likely reachable there. Loopback
+// addresses tell nothing, every peer has them.
+func (rp *rankedPeerstore) sharesNetwork(addrs rankedAddrs) bool {
+	var nets []*net.IPNet
+	for _, r := range addrs {
+		if r.scope != scopePrivate || r.ip == nil {
+			continue
+		}
+		if nets == nil {
+			nets = rp.localNets()
+		}
+		for _, n := range nets {
+			if n.Contains(r.ip) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// interfaceNets returns the networks of the interfaces of the host, looked up
+// at most once a minute as addresses are ranked for every dial.
+var interfaceNets = func() func() []*net.IPNet {
+	var (
+		lk   sync.Mutex
+		nets []*net.IPNet
+		at   time.Time
+	)
+	return func() []*net.IPNet {
+		lk.Lock()
+		defer lk.Unlock()
+
+		if time.Since(at) < time.Minute {
+			return nets
+		}
+		addrs, err := net.InterfaceAddrs()
+		if err != nil {
+			log.Warningf("failed to list the interface addresses: %s", err)
+			return nets
+		}
+		var found []*net.IPNet
+		for _, a := range addrs {
+			if n, ok := a.(*net.IPNet); ok {
+				found = append(found, n)
+			}
+		}
+		nets, at = found, time.Now()
+		return nets
+	}
+}()
+
+func ipScope(ip net.IP) int {
+	if ip == nil {
+		return scopeUnknown
+	}
+	if ip.IsLoopback() {
+		return scopeLoopback
+	}
+	for _, n := range privateNets {
+		if n.Contains(ip) {
+			return scopePrivate
+		}
+	}
+	return scopePublic
+}
@@ -0,0 +1,138 @@
+package core
+
+import (
+	"net"
+	"testing"
+
+	pstore "gx/ipfs/QmXZSd1qR5BxZkPyuwfT5jpqQFScZccoZvDneXsKzCNHWX/go-libp2p-peerstore"
+	ma "gx/ipfs/QmcyqRMCAXVtYPS4DiBrA7sezL9rRGfW8Ctx7cywL4TXJj/go-multiaddr"
+)
+
+// lanNets makes the node a member of 192.168.1.0/24.
+func lanNets() []*net.IPNet {
+	_, n, _ := net.ParseCIDR("192.168.1.0/24")
+	return []*net.IPNet{n}
+}
+
+func noNets() []*net.IPNet {
+	return nil
+}
+
+func TestDialRanking(t *testing.T) {
+	in := []string{
+		"/ip4/1.2.3.4/tcp/4001",
+		"/ip4/1.2.3.4/udp/4001/utp",
+		"/ip4/192.168.1.2/tcp/4001",
+		"/ip4/1.2.3.4/tcp/4002/ws",
+		"/ip4/127.0.0.1/tcp/4001",
+	}
+	expected := []string{
+		"/ip4/127.0.0.1/tcp/4001",
+		"/ip4/192.168.1.2/tcp/4001",
+		"/ip4/1.2.3.4/tcp/4001",
+		"/ip4/1.2.3.4/tcp/4002/ws",
+		"/ip4/1.2.3.4/udp/4001/utp",
+	}
+
+	var addrs []ma.Multiaddr
+	for _, s := range in {
+		a, err := ma.NewMultiaddr(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		addrs = append(addrs, a)
+	}
+
+	rp := newRankedPeerstore(pstore.NewPeerstore(), nil, addressFamily{})
+	rp.localNets = lanNets
+	out := rp.rank(addrs)
+	for i, a := range out {
+		if a.String() != expected[i] {
+			t.Fatalf("expected %s at %d, got %s", expected[i], i, a)
+		}
+	}
+
+	// a peer on another network is dialed on its public addresses first
+	remote := []string{
+		"/ip4/1.2.3.4/tcp/4001",
+		"/ip4/1.2.3.4/tcp/4002/ws",
+		"/ip4/1.2.3.4/udp/4001/utp",
+		"/ip4/192.168.1.2/tcp/4001",
+		"/ip4/127.0.0.1/tcp/4001",
+	}
+	rp.localNets = noNets
+	out = rp.rank(addrs)
+	for i, a := range out {
+		if a.String() != remote[i] {
+			t.Fatalf("expected %s at %d, got %s", remote[i], i, a)
+		}
+	}
+
+	rp = newRankedPeerstore(pstore.NewPeerstore(), []string{"utp", "tcp"}, addressFamily{})
+	rp.localNets = lanNets
+	if out := rp.rank(addrs); out[2].String() != "/ip4/1.2.3.4/udp/4001/utp" {
+		t.Fatalf("expected utp to be preferred, got %s", out[2])
+	}
+}
+
+func TestDialRankingFamily(t *testing.T) {
+	var addrs []ma.Multiaddr
+	for _, s := range []string{
+		"/ip4/1.2.3.4/tcp/4001",
+		"/ip6/fe80::1/tcp/4001",
+		"/ip6/2001:db8::1/tcp/4001",
+		"/ip4/192.168.1.2/tcp/4001",
+		"/dns4/example.com/tcp/4001",
+		"/dns6/example.com/tcp/4001",
+	} {
+		a, err := ma.NewMultiaddr(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		addrs = append(addrs, a)
+	}
+
+	for _, test := range []struct {
+		family   string
+		expected []string
+	}{
+		{"", []string{
+			"/ip4/192.168.1.2/tcp/4001",
+			"/ip4/1.2.3.4/tcp/4001",
+			"/ip6/2001:db8::1/tcp/4001",
+			"/dns4/example.com/tcp/4001",
+			"/dns6/example.com/tcp/4001",
+		}},
+		{"ip6", []string{
+			"/ip4/192.168.1.2/tcp/4001",
+			"/ip6/2001:db8::1/tcp/4001",
+			"/ip4/1.2.3.4/tcp/4001",
+			"/dns6/example.com/tcp/4001",
+			"/dns4/example.com/tcp/4001",
+		}},
+		{"ip6-only", []string{
+			"/ip6/2001:db8::1/tcp/4001",
+			"/dns6/example.com/tcp/4001",
+		}},
+	} {
+		family, err := parseAddressFamily(test.family)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rp := newRankedPeerstore(pstore.NewPeerstore(), nil, family)
+		rp.localNets = lanNets
+		out := rp.rank(addrs)
+		if len(out) != len(test.expected) {
+			t.Fatalf("%q: expected %v, got %v", test.family, test.expected, out)
+		}
+		for i, a := range out {
+			if a.String() != test.expected[i] {
+				t.Fatalf("%q: expected %s at %d, got %s", test.family, test.expected[i], i, a)
+			}
+		}
+	}
+
+	if _, err := parseAddressFamily("ip5"); err == nil {
+		t.Fatal("expected an invalid family to be refused")
+	}
+}
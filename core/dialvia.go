@@ -0,0 +1,109 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	pstore "gx/ipfs/QmXZSd1qR5BxZkPyuwfT5jpqQFScZccoZvDneXsKzCNHWX/go-libp2p-peerstore"
+	ma "gx/ipfs/QmcyqRMCAXVtYPS4DiBrA7sezL9rRGfW8Ctx7cywL4TXJj/go-multiaddr"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+// dialPins restricts the addresses the swarm dials for some peers, while
+// ConnectVia reaches them over chosen addresses.
+type dialPins struct {
+	mu   sync.Mutex
+	pins map[peer.ID][]ma.Multiaddr
+}
+
+func newDialPins() *dialPins {
+	return &dialPins{pins: make(map[peer.ID][]ma.Multiaddr)}
+}
+
+func (d *dialPins) get(p peer.ID) ([]ma.Multiaddr, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	addrs, ok := d.pins[p]
+	return addrs, ok
+}
+
+func (d *dialPins) set(p peer.ID, addrs []ma.Multiaddr) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if addrs == nil {
+		delete(d.pins, p)
+	} else {
+		d.pins[p] = addrs
+	}
+}
+
+// peerstore returns ps, with the addresses of pinned peers replaced by
+// their pinned ones.
+func (d *dialPins) peerstore(ps pstore.Peerstore) pstore.Peerstore {
+	return &pinnedPeerstore{Peerstore: ps, pins: d}
+}
+
+type pinnedPeerstore struct {
+	pstore.Peerstore
+	pins *dialPins
+}
+
+func (ps *pinnedPeerstore) Addrs(p peer.ID) []ma.Multiaddr {
+	if addrs, ok := ps.pins.get(p); ok {
+		return addrs
+	}
+	return ps.Peerstore.Addrs(p)
+}
+
+func (ps *pinnedPeerstore) PeerInfo(p peer.ID) pstore.PeerInfo {
+	return pstore.PeerInfo{ID: p, Addrs: ps.Addrs(p)}
+}
+
+// ConnectVia makes sure the node is connected to p over an address for
+// which match is true, and only over such addresses, so that the streams
+// opened to p go over it. The other connections to p are closed, and one of
+// the matching known addresses of p is dialed if needed. It returns the
+// address connected to.
+func (n *IpfsNode) ConnectVia(ctx context.Context, p peer.ID, match func(ma.Multiaddr) bool) (ma.Multiaddr, error) {
+	if n.PeerHost == nil || n.dialPins == nil {
+		return nil, errors.New("the node is not online")
+	}
+	net := n.PeerHost.Network()
+
+	var via ma.Multiaddr
+	for _, c := range net.ConnsToPeer(p) {
+		if match(c.RemoteMultiaddr()) {
+			via = c.RemoteMultiaddr()
+		} else {
+			c.Close()
+		}
+	}
+	if via != nil {
+		return via, nil
+	}
+
+	var addrs []ma.Multiaddr
+	for _, a := range n.Peerstore.Addrs(p) {
+		if match(a) {
+			addrs = append(addrs, a)
+		}
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no known address of %s matches", p.Pretty())
+	}
+
+	n.dialPins.set(p, addrs)
+	defer n.dialPins.set(p, nil)
+
+	if err := n.PeerHost.Connect(ctx, pstore.PeerInfo{ID: p}); err != nil {
+		return nil, err
+	}
+	for _, c := range net.ConnsToPeer(p) {
+		if match(c.RemoteMultiaddr()) {
+			return c.RemoteMultiaddr(), nil
+		}
+	}
+	return nil, fmt.Errorf("connected to %s over another address", p.Pretty())
+}
@@ -0,0 +1,144 @@
+// Package events publishes the lifecycle events of content on a node: when
+// a CID was added, pinned, unpinned, announced to the network, garbage
+// collected or found corrupt, for external systems to track it.
+package events
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+)
+
+// Types of events.
+const (
+	Added    = "added"    // the root of an 'ipfs add'
+	Pinned   = "pinned"   // pinned recursively or directly
+	Unpinned = "unpinned" // unpinned
+	Provided = "provided" // announced to the routing system as new content
+	GCed     = "gced"     // removed by a garbage collection or eviction
+	Corrupt  = "corrupt"  // found not to match its hash by the scrubber
+)
+
+// Types are all the types of events.
+var Types = []string{Added, Pinned, Unpinned, Provided, GCed, Corrupt}
+
+// subscriberBuffer is the number of events a subscriber can lag behind;
+// the events it misses beyond are counted as dropped.
+const subscriberBuffer = 256
+
+// Event is something that happened to a CID.
+type Event struct {
+	Type string
+	Cid  string
+	Time time.Time
+}
+
+type subscriber struct {
+	types map[string]bool
+	ch    chan *Event
+}
+
+// Bus dispatches events to subscribers. A nil Bus drops the events, so that
+// the nodes built without one need no checks.
+type Bus struct {
+	mu      sync.Mutex
+	subs    map[*subscriber]struct{}
+	dropped uint64
+}
+
+func NewBus() *Bus {
+	return &Bus{subs: make(map[*subscriber]struct{})}
+}
+
+// ParseTypes checks a list of event types, and returns all of them if the
+// list is empty.
+func ParseTypes(types []string) ([]string, error) {
+	if len(types) == 0 {
+		return Types, nil
+	}
+	for _, t := range types {
+		found := false
+		for _, known := range Types {
+			if t == known {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown event type %q, expected one of %v", t, Types)
+		}
+	}
+	return types, nil
+}
+
+// Publish sends an event of type typ about c to the subscribers of that
+// type. It never blocks: the events a slow subscriber has no room for are
+// dropped.
+func (b *Bus) Publish(typ string, c *cid.Cid) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.subs) == 0 {
+		return
+	}
+
+	ev := &Event{Type: typ, Cid: c.String(), Time: time.Now()}
+	for s := range b.subs {
+		if !s.types[typ] {
+			continue
+		}
+		select {
+		case s.ch <- ev:
+		default:
+			b.dropped++
+		}
+	}
+}
+
+// Subscribe returns the events of the given types, all if none, until ctx
+// is done.
+func (b *Bus) Subscribe(ctx context.Context, types []string) <-chan *Event {
+	s := &subscriber{
+		types: make(map[string]bool),
+		ch:    make(chan *Event, subscriberBuffer),
+	}
+	if len(types) == 0 {
+		types = Types
+	}
+	for _, t := range types {
+		s.types[t] = true
+	}
+
+	if b == nil {
+		close(s.ch)
+		return s.ch
+	}
+
+	b.mu.Lock()
+	b.subs[s] = struct{}{}
+	b.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		b.mu.Lock()
+		delete(b.subs, s)
+		close(s.ch)
+		b.mu.Unlock()
+	}()
+	return s.ch
+}
+
+// Dropped returns the number of events dropped for slow subscribers.
+func (b *Bus) Dropped() uint64 {
+	if b == nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.dropped
+}
@@ -0,0 +1,62 @@
+package events
+
+import (
+	"context"
+	"testing"
+
+	u "gx/ipfs/QmWbjfz3u6HkAdPh34dgPchGbQjob6LXLhAeCGii2TX69n/go-ipfs-util"
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+)
+
+func TestBus(t *testing.T) {
+	b := NewBus()
+	c := cid.NewCidV0(u.Hash([]byte("events")))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	pins := b.Subscribe(ctx, []string{Pinned})
+	all := b.Subscribe(ctx, nil)
+
+	b.Publish(Added, c)
+	b.Publish(Pinned, c)
+
+	if ev := <-pins; ev.Type != Pinned || ev.Cid != c.String() {
+		t.Fatalf("unexpected event %+v", ev)
+	}
+	if ev := <-all; ev.Type != Added {
+		t.Fatalf("expected the added event first, got %+v", ev)
+	}
+	if ev := <-all; ev.Type != Pinned {
+		t.Fatalf("expected the pinned event, got %+v", ev)
+	}
+
+	// a subscriber not reading loses the events beyond its buffer
+	for i := 0; i < subscriberBuffer+10; i++ {
+		b.Publish(Pinned, c)
+	}
+	if d := b.Dropped(); d != 2*10 {
+		t.Fatalf("expected 20 dropped events, got %d", d)
+	}
+
+	cancel()
+	for range all {
+	}
+	for range pins {
+	}
+}
+
+func TestNilBus(t *testing.T) {
+	var b *Bus
+	b.Publish(Added, cid.NewCidV0(u.Hash([]byte("events"))))
+	if _, ok := <-b.Subscribe(context.Background(), nil); ok {
+		t.Fatal("expected the events of a nil bus to be closed")
+	}
+}
+
+func TestParseTypes(t *testing.T) {
+	if types, err := ParseTypes(nil); err != nil || len(types) != len(Types) {
+		t.Fatal("expected all the types by default")
+	}
+	if _, err := ParseTypes([]string{"pinned", "exploded"}); err == nil {
+		t.Fatal("expected an unknown type to be rejected")
+	}
+}
@@ -0,0 +1,57 @@
+package gater
+
+import (
+	"net"
+	"time"
+
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+// ban denies a peer, or a subnet, until a time.
+type ban struct {
+	peer  peer.ID
+	net   *net.IPNet
+	until time.Time
+}
+
+// BanPeer denies every connection with p, at all stages, until the given
+// time. Bans are not persisted, they end with the daemon.
+func (g *Gater) BanPeer(p peer.ID, until time.Time) {
+	g.addBan(ban{peer: p, until: until})
+}
+
+// BanNet denies every connection with an address in n, at all stages, until
+// the given time.
+func (g *Gater) BanNet(n *net.IPNet, until time.Time) {
+	g.addBan(ban{net: n, until: until})
+}
+
+func (g *Gater) addBan(b ban) {
+	g.bansLk.Lock()
+	defer g.bansLk.Unlock()
+	g.bans = append(g.bans, b)
+}
+
+// banned tells whether p or ip is banned at now, dropping the bans that
+// have ended.
+func (g *Gater) banned(p peer.ID, ip net.IP, now time.Time) bool {
+	g.bansLk.Lock()
+	defer g.bansLk.Unlock()
+
+	live := g.bans[:0]
+	found := false
+	for _, b := range g.bans {
+		if !now.Before(b.until) {
+			continue
+		}
+		live = append(live, b)
+		if b.peer != "" && b.peer == p {
+			found = true
+		}
+		if b.net != nil && ip != nil && b.net.Contains(ip) {
+			found = true
+		}
+	}
+	g.bans = live
+	return found
+}
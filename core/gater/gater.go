@@ -0,0 +1,350 @@
+// Package gater decides which connections a node is allowed to make and
+// accept, following the rules of the Swarm.Gater config and the registered
+// hooks.
+package gater
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	config "github.com/ipfs/go-ipfs/repo/config"
+
+	logging "gx/ipfs/QmSpJByNKFX1sCsHBEp3R73FL4NF6FnQTEGyNAXHm2GS52/go-log"
+	pstore "gx/ipfs/QmXZSd1qR5BxZkPyuwfT5jpqQFScZccoZvDneXsKzCNHWX/go-libp2p-peerstore"
+	ma "gx/ipfs/QmcyqRMCAXVtYPS4DiBrA7sezL9rRGfW8Ctx7cywL4TXJj/go-multiaddr"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+	smux "gx/ipfs/QmeZBgYBHvxMukGK5ojg28BCNLB9SeXqT7XXg6o7r2GbJy/go-stream-muxer"
+)
+
+var log = logging.Logger("gater")
+
+// ErrDenied is returned for the connections the gater denies.
+var ErrDenied = errors.New("connection denied by the gater")
+
+// Stage is the point of a connection's life at which it is gated.
+type Stage string
+
+const (
+	// StageDial is checked for every address before it is dialed.
+	StageDial Stage = "dial"
+	// StageAccept is checked for every new connection, knowing only the
+	// remote address.
+	StageAccept Stage = "accept"
+	// StageUpgrade is checked for every new connection once the remote peer
+	// is authenticated.
+	StageUpgrade Stage = "upgrade"
+)
+
+// Decision is the verdict of a rule or hook.
+type Decision int
+
+const (
+	// Abstain leaves the decision to the next hooks and rules.
+	Abstain Decision = iota
+	Allow
+	Deny
+)
+
+// Hook is custom gating logic, consulted before the configured rules. The
+// peer is empty at StageAccept.
+type Hook func(stage Stage, p peer.ID, addr ma.Multiaddr) Decision
+
+type namedHook struct {
+	name string
+	hook Hook
+}
+
+var (
+	hooksLk sync.Mutex
+	hooks   []namedHook
+)
+
+// RegisterHook adds h to the hooks of every gater, in registration order.
+// It is meant to be called from init functions.
+func RegisterHook(name string, h Hook) error {
+	hooksLk.Lock()
+	defer hooksLk.Unlock()
+
+	for _, nh := range hooks {
+		if nh.name == name {
+			return fmt.Errorf("gater hook %q already registered", name)
+		}
+	}
+	hooks = append(hooks, namedHook{name: name, hook: h})
+	return nil
+}
+
+type rule struct {
+	index  int
+	allow  bool
+	stages map[Stage]bool
+	peers  map[peer.ID]bool
+	nets   []*net.IPNet
+
+	// minutes after midnight, local time; from < 0 for any time
+	from, to int
+}
+
+// Gater applies the gating rules of a node.
+type Gater struct {
+	rules        []rule
+	defaultAllow bool
+
+	bansLk sync.Mutex
+	bans   []ban
+
+	// for tests
+	now func() time.Time
+}
+
+// New builds a gater from cfg.
+func New(cfg config.GaterConfig) (*Gater, error) {
+	g := &Gater{now: time.Now}
+
+	switch cfg.DefaultAction {
+	case "", config.GaterAllow:
+		g.defaultAllow = true
+	case config.GaterDeny:
+	default:
+		return nil, fmt.Errorf("invalid gater default action: %q", cfg.DefaultAction)
+	}
+
+	for i, rc := range cfg.Rules {
+		r, err := parseRule(i, rc)
+		if err != nil {
+			return nil, err
+		}
+		g.rules = append(g.rules, r)
+	}
+	return g, nil
+}
+
+func parseRule(i int, rc config.GaterRule) (rule, error) {
+	r := rule{index: i, from: -1}
+
+	switch rc.Action {
+	case config.GaterAllow:
+		r.allow = true
+	case config.GaterDeny:
+	default:
+		return r, fmt.Errorf("gater rule %d: invalid action: %q", i, rc.Action)
+	}
+
+	if len(rc.Stages) > 0 {
+		r.stages = make(map[Stage]bool)
+		for _, s := range rc.Stages {
+			switch st := Stage(s); st {
+			case StageDial, StageAccept, StageUpgrade:
+				r.stages[st] = true
+			default:
+				return r, fmt.Errorf("gater rule %d: invalid stage: %q", i, s)
+			}
+		}
+	}
+
+	if len(rc.Peers) > 0 {
+		r.peers = make(map[peer.ID]bool)
+		for _, s := range rc.Peers {
+			p, err := peer.IDB58Decode(s)
+			if err != nil {
+				return r, fmt.Errorf("gater rule %d: invalid peer id %q: %s", i, s, err)
+			}
+			r.peers[p] = true
+		}
+	}
+
+	for _, s := range rc.CIDRs {
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return r, fmt.Errorf("gater rule %d: %s", i, err)
+		}
+		r.nets = append(r.nets, n)
+	}
+
+	if rc.Hours != "" {
+		parts := strings.Split(rc.Hours, "-")
+		if len(parts) != 2 {
+			return r, fmt.Errorf("gater rule %d: invalid hours %q, expected HH:MM-HH:MM", i, rc.Hours)
+		}
+		from, err := parseClock(parts[0])
+		if err != nil {
+			return r, fmt.Errorf("gater rule %d: %s", i, err)
+		}
+		to, err := parseClock(parts[1])
+		if err != nil {
+			return r, fmt.Errorf("gater rule %d: %s", i, err)
+		}
+		r.from, r.to = from, to
+	}
+	return r, nil
+}
+
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day: %q", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// matches tells whether r applies. A rule with peers never applies at
+// StageAccept, where the peer is not known yet.
+func (r *rule) matches(stage Stage, p peer.ID, ip net.IP, now time.Time) bool {
+	if r.stages != nil && !r.stages[stage] {
+		return false
+	}
+	if r.peers != nil && !r.peers[p] {
+		return false
+	}
+	if r.nets != nil {
+		found := false
+		for _, n := range r.nets {
+			if ip != nil && n.Contains(ip) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if r.from >= 0 {
+		m := now.Hour()*60 + now.Minute()
+		if r.from <= r.to {
+			return r.from <= m && m < r.to
+		}
+		// the window spans midnight
+		return m >= r.from || m < r.to
+	}
+	return true
+}
+
+// Allow decides whether the connection to or from p at addr may proceed past
+// stage. Bans are checked first, then hooks are consulted, then the rules in
+// order; the first which does not abstain decides. Every decision made by a
+// ban, hook or rule is logged. The default action does not apply at
+// StageAccept: a connection nothing decides on there is left to StageUpgrade,
+// where its peer is known, so that rules allowing peers can still match it.
+func (g *Gater) Allow(stage Stage, p peer.ID, addr ma.Multiaddr) bool {
+	ip := AddrIP(addr)
+	now := g.now()
+	if g.banned(p, ip, now) {
+		log.Infof("gater: %s of %s at %s denied by ban", stage, p.Pretty(), addr)
+		return false
+	}
+
+	hooksLk.Lock()
+	hs := hooks
+	hooksLk.Unlock()
+
+	for _, h := range hs {
+		switch h.hook(stage, p, addr) {
+		case Allow:
+			log.Infof("gater: %s of %s at %s allowed by hook %s", stage, p.Pretty(), addr, h.name)
+			return true
+		case Deny:
+			log.Infof("gater: %s of %s at %s denied by hook %s", stage, p.Pretty(), addr, h.name)
+			return false
+		}
+	}
+
+	for i := range g.rules {
+		r := &g.rules[i]
+		if !r.matches(stage, p, ip, now) {
+			continue
+		}
+
+		verdict := "denied"
+		if r.allow {
+			verdict = "allowed"
+		}
+		log.Infof("gater: %s of %s at %s %s by rule %d", stage, p.Pretty(), addr, verdict, r.index)
+		return r.allow
+	}
+
+	if stage == StageAccept {
+		return true
+	}
+	if !g.defaultAllow {
+		log.Infof("gater: %s of %s at %s denied by default", stage, p.Pretty(), addr)
+	}
+	return g.defaultAllow
+}
+
+// AddrIP returns the IP address of a, or nil if it has none.
+func AddrIP(a ma.Multiaddr) net.IP {
+	if a == nil {
+		return nil
+	}
+	parts := strings.Split(a.String(), "/")
+	if len(parts) > 2 && (parts[1] == "ip4" || parts[1] == "ip6") {
+		return net.ParseIP(parts[2])
+	}
+	return nil
+}
+
+// Peerstore wraps ps so that the addresses denied at StageDial are hidden
+// from the swarm, which therefore never dials them.
+func (g *Gater) Peerstore(ps pstore.Peerstore) pstore.Peerstore {
+	return &gatedPeerstore{Peerstore: ps, g: g}
+}
+
+type gatedPeerstore struct {
+	pstore.Peerstore
+	g *Gater
+}
+
+func (gp *gatedPeerstore) Addrs(p peer.ID) []ma.Multiaddr {
+	addrs := gp.Peerstore.Addrs(p)
+	var out []ma.Multiaddr
+	for _, a := range addrs {
+		if gp.g.Allow(StageDial, p, a) {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+func (gp *gatedPeerstore) PeerInfo(p peer.ID) pstore.PeerInfo {
+	return pstore.PeerInfo{ID: p, Addrs: gp.Addrs(p)}
+}
+
+// Transport returns tpt closing the connections denied at StageAccept, for
+// the inbound ones, or at StageUpgrade. The swarm offers no earlier hook
+// than its stream muxer transport, so these stages are checked once the
+// connection is secured, before it is multiplexed and handed to the swarm.
+func (g *Gater) Transport(tpt smux.Transport) smux.Transport {
+	return &transport{Transport: tpt, g: g}
+}
+
+type transport struct {
+	smux.Transport
+	g *Gater
+}
+
+// secureConn is a connection secured by the swarm, which knows the peer at
+// the other end.
+type secureConn interface {
+	RemotePeer() peer.ID
+	RemoteMultiaddr() ma.Multiaddr
+}
+
+func (t *transport) NewConn(c net.Conn, isServer bool) (smux.Conn, error) {
+	sc, ok := c.(secureConn)
+	if !ok {
+		c.Close()
+		return nil, errors.New("gater: the connection is not authenticated")
+	}
+	addr := sc.RemoteMultiaddr()
+
+	// the accept stage is about the connections of others only
+	if (isServer && !t.g.Allow(StageAccept, "", addr)) || !t.g.Allow(StageUpgrade, sc.RemotePeer(), addr) {
+		c.Close()
+		return nil, ErrDenied
+	}
+	return t.Transport.NewConn(c, isServer)
+}
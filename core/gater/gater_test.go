@@ -0,0 +1,258 @@
+package gater
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	config "github.com/ipfs/go-ipfs/repo/config"
+
+	ma "gx/ipfs/QmcyqRMCAXVtYPS4DiBrA7sezL9rRGfW8Ctx7cywL4TXJj/go-multiaddr"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+	smux "gx/ipfs/QmeZBgYBHvxMukGK5ojg28BCNLB9SeXqT7XXg6o7r2GbJy/go-stream-muxer"
+)
+
+const testPeer = "QmaCpDMGvV2BGHeYERUEnRQAwe3N8SzbUtfsmvsqQLuvuJ"
+
+func mustAddr(t *testing.T, s string) ma.Multiaddr {
+	a, err := ma.NewMultiaddr(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func TestGaterRules(t *testing.T) {
+	g, err := New(config.GaterConfig{
+		DefaultAction: config.GaterDeny,
+		Rules: []config.GaterRule{
+			{Action: config.GaterDeny, CIDRs: []string{"10.0.0.0/8"}},
+			{Action: config.GaterAllow, Peers: []string{testPeer}},
+			{Action: config.GaterAllow, Stages: []string{"dial", "accept"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := peer.IDB58Decode(testPeer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	public := mustAddr(t, "/ip4/1.2.3.4/tcp/4001")
+	private := mustAddr(t, "/ip4/10.1.2.3/tcp/4001")
+
+	if g.Allow(StageDial, p, private) {
+		t.Fatal("dial of a denied subnet allowed")
+	}
+	if !g.Allow(StageUpgrade, p, public) {
+		t.Fatal("upgrade of an allowed peer denied")
+	}
+	if !g.Allow(StageAccept, "", public) {
+		t.Fatal("accept denied")
+	}
+	if g.Allow(StageUpgrade, "QmOther", public) {
+		t.Fatal("upgrade of an unknown peer allowed")
+	}
+}
+
+func TestGaterHours(t *testing.T) {
+	g, err := New(config.GaterConfig{
+		Rules: []config.GaterRule{
+			{Action: config.GaterDeny, Hours: "22:00-06:00"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := mustAddr(t, "/ip4/1.2.3.4/tcp/4001")
+
+	for hour, allowed := range map[int]bool{23: false, 3: false, 6: true, 12: true} {
+		h := hour
+		g.now = func() time.Time { return time.Date(2017, 1, 1, h, 0, 0, 0, time.Local) }
+		if g.Allow(StageDial, "", addr) != allowed {
+			t.Fatalf("expected allowed=%t at %d:00", allowed, hour)
+		}
+	}
+}
+
+func TestGaterHooks(t *testing.T) {
+	defer func() { hooks = nil }()
+
+	g, err := New(config.GaterConfig{DefaultAction: config.GaterDeny})
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := mustAddr(t, "/ip4/1.2.3.4/tcp/4001")
+
+	err = RegisterHook("test", func(stage Stage, p peer.ID, a ma.Multiaddr) Decision {
+		if stage == StageDial {
+			return Allow
+		}
+		return Abstain
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterHook("test", nil); err == nil {
+		t.Fatal("expected an error registering a hook twice")
+	}
+
+	if !g.Allow(StageDial, "", addr) {
+		t.Fatal("hook did not allow the dial")
+	}
+	if g.Allow(StageUpgrade, "", addr) {
+		t.Fatal("abstaining hook did not fall back to the default")
+	}
+	if !g.Allow(StageAccept, "", addr) {
+		t.Fatal("the default applied at accept")
+	}
+}
+
+func TestGaterInvalidConfig(t *testing.T) {
+	for _, r := range []config.GaterRule{
+		{Action: "maybe"},
+		{Action: config.GaterDeny, Stages: []string{"handshake"}},
+		{Action: config.GaterDeny, CIDRs: []string{"10.0.0.0"}},
+		{Action: config.GaterDeny, Hours: "22:00"},
+	} {
+		if _, err := New(config.GaterConfig{Rules: []config.GaterRule{r}}); err == nil {
+			t.Fatalf("expected an error for rule %+v", r)
+		}
+	}
+}
+
+func TestGaterBans(t *testing.T) {
+	g, err := New(config.GaterConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2017, 6, 1, 12, 0, 0, 0, time.Local)
+	g.now = func() time.Time { return now }
+
+	p, err := peer.IDB58Decode(testPeer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	public := mustAddr(t, "/ip4/1.2.3.4/tcp/4001")
+	private := mustAddr(t, "/ip4/10.1.2.3/tcp/4001")
+
+	_, subnet, err := net.ParseCIDR("10.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.BanPeer(p, now.Add(time.Minute))
+	g.BanNet(subnet, now.Add(time.Hour))
+
+	if g.Allow(StageDial, p, public) {
+		t.Fatal("dial of a banned peer allowed")
+	}
+	if g.Allow(StageAccept, "", private) {
+		t.Fatal("accept from a banned subnet allowed")
+	}
+	if !g.Allow(StageUpgrade, "QmOther", public) {
+		t.Fatal("upgrade of a peer not banned denied")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if !g.Allow(StageDial, p, public) {
+		t.Fatal("dial of a peer whose ban ended denied")
+	}
+	if g.Allow(StageDial, p, private) {
+		t.Fatal("dial of a banned subnet allowed")
+	}
+	if len(g.bans) != 1 {
+		t.Fatalf("expected the ended ban to be dropped, have %d bans", len(g.bans))
+	}
+}
+
+// secured is a connection secured with the peer remote at addr.
+type secured struct {
+	net.Conn
+	remote peer.ID
+	addr   ma.Multiaddr
+}
+
+func (c secured) RemotePeer() peer.ID           { return c.remote }
+func (c secured) RemoteMultiaddr() ma.Multiaddr { return c.addr }
+func (c secured) Close() error                  { return nil }
+
+// countTransport counts the connections it multiplexes.
+type countTransport struct {
+	conns int
+}
+
+func (t *countTransport) NewConn(c net.Conn, isServer bool) (smux.Conn, error) {
+	t.conns++
+	return nil, nil
+}
+
+func TestGaterTransport(t *testing.T) {
+	g, err := New(config.GaterConfig{
+		Rules: []config.GaterRule{
+			{Action: config.GaterDeny, Stages: []string{"accept"}, CIDRs: []string{"10.0.0.0/8"}},
+			{Action: config.GaterDeny, Stages: []string{"upgrade"}, Peers: []string{testPeer}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := peer.IDB58Decode(testPeer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := peer.ID("other")
+
+	inner := new(countTransport)
+	tpt := g.Transport(inner)
+
+	private := secured{remote: other, addr: mustAddr(t, "/ip4/10.0.0.1/tcp/4001")}
+	if _, err := tpt.NewConn(private, true); err != ErrDenied {
+		t.Fatalf("expected an inbound connection from a denied subnet to be denied, got %v", err)
+	}
+	if _, err := tpt.NewConn(private, false); err != nil {
+		t.Fatalf("expected an outbound connection not to be gated at accept, got %v", err)
+	}
+
+	denied := secured{remote: p, addr: mustAddr(t, "/ip4/1.2.3.4/tcp/4001")}
+	if _, err := tpt.NewConn(denied, false); err != ErrDenied {
+		t.Fatalf("expected an outbound connection to a denied peer to be denied, got %v", err)
+	}
+	if _, err := tpt.NewConn(denied, true); err != ErrDenied {
+		t.Fatalf("expected an inbound connection from a denied peer to be denied, got %v", err)
+	}
+
+	if inner.conns != 1 {
+		t.Fatalf("expected only the allowed connection to be multiplexed, got %d", inner.conns)
+	}
+}
+
+func TestGaterPeerAllowlist(t *testing.T) {
+	g, err := New(config.GaterConfig{
+		DefaultAction: config.GaterDeny,
+		Rules: []config.GaterRule{
+			{Action: config.GaterAllow, Peers: []string{testPeer}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := peer.IDB58Decode(testPeer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := mustAddr(t, "/ip4/1.2.3.4/tcp/4001")
+
+	inner := new(countTransport)
+	tpt := g.Transport(inner)
+
+	if _, err := tpt.NewConn(secured{remote: p, addr: addr}, true); err != nil {
+		t.Fatalf("expected an inbound connection from an allowed peer to be accepted, got %v", err)
+	}
+	if _, err := tpt.NewConn(secured{remote: peer.ID("other"), addr: addr}, true); err != ErrDenied {
+		t.Fatalf("expected an inbound connection from another peer to be denied, got %v", err)
+	}
+	if inner.conns != 1 {
+		t.Fatalf("expected only the allowed connection to be multiplexed, got %d", inner.conns)
+	}
+}
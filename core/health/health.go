@@ -0,0 +1,143 @@
+// Package health implements a protocol through which peers ask a node
+// whether its subsystems, such as bitswap and the DHT, are responsive, for
+// monitoring a mesh of nodes from any one of them.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	inet "gx/ipfs/QmRscs8KxrSmSv4iuevHv8JfuUzHBMoqiaHzxfDRiksd6e/go-libp2p-net"
+	logging "gx/ipfs/QmSpJByNKFX1sCsHBEp3R73FL4NF6FnQTEGyNAXHm2GS52/go-log"
+	p2phost "gx/ipfs/QmUywuGNZoUKV8B9iyvup9bPkLiMrhTsyVMkeSXW5VxAfC/go-libp2p-host"
+	protocol "gx/ipfs/QmZNkThpqfVXs9GNbexPrfBbXSLNYeKrE7jwFM2oqHbyqN/go-libp2p-protocol"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+var log = logging.Logger("health")
+
+// ID is the protocol of health checks.
+const ID protocol.ID = "/ipfs/health/1.0.0"
+
+// CheckTimeout bounds each check; a subsystem not answering in time is
+// reported unresponsive.
+var CheckTimeout = 5 * time.Second
+
+// reportTTL is how long a report is served before the checks run again, so
+// that peers asking often don't load the node.
+const reportTTL = time.Second
+
+// Check is the result of checking one subsystem.
+type Check struct {
+	OK      bool
+	Latency time.Duration
+	Error   string `json:",omitempty"`
+}
+
+// Report is the answer of a node to a health check.
+type Report struct {
+	Checks map[string]Check
+}
+
+// CheckFunc returns an error if its subsystem is unhealthy, or doesn't answer
+// before ctx is done.
+type CheckFunc func(ctx context.Context) error
+
+// Service answers the health checks of peers.
+type Service struct {
+	checks map[string]CheckFunc
+
+	mu     sync.Mutex
+	last   *Report
+	lastAt time.Time
+}
+
+// NewService runs checks for the peers asking h.
+func NewService(h p2phost.Host, checks map[string]CheckFunc) *Service {
+	s := &Service{checks: checks}
+	h.SetStreamHandler(ID, s.handle)
+	return s
+}
+
+// Report runs the checks, or returns the report of the last run if it is
+// recent.
+func (s *Service) Report(ctx context.Context) *Report {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.last != nil && time.Since(s.lastAt) < reportTTL {
+		return s.last
+	}
+
+	r := &Report{Checks: make(map[string]Check)}
+	var lk sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range s.checks {
+		wg.Add(1)
+		go func(name string, check CheckFunc) {
+			defer wg.Done()
+			c := run(ctx, check)
+			lk.Lock()
+			r.Checks[name] = c
+			lk.Unlock()
+		}(name, check)
+	}
+	wg.Wait()
+
+	s.last, s.lastAt = r, time.Now()
+	return r
+}
+
+func run(ctx context.Context, check CheckFunc) Check {
+	ctx, cancel := context.WithTimeout(ctx, CheckTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check(ctx)
+	c := Check{OK: err == nil, Latency: time.Since(start)}
+	if err != nil {
+		c.Error = err.Error()
+	}
+	return c
+}
+
+func (s *Service) handle(st inet.Stream) {
+	defer st.Close()
+
+	r := s.Report(context.Background())
+	if err := json.NewEncoder(st).Encode(r); err != nil {
+		log.Debugf("failed to send health report to %s: %s", st.Conn().RemotePeer(), err)
+	}
+}
+
+// Request asks p for its health report.
+func Request(ctx context.Context, h p2phost.Host, p peer.ID) (*Report, error) {
+	st, err := h.NewStream(ctx, p, ID)
+	if err != nil {
+		return nil, err
+	}
+	defer st.Close()
+
+	// the stream doesn't follow ctx by itself
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			st.Close()
+		case <-done:
+		}
+	}()
+
+	var r Report
+	if err := json.NewDecoder(st).Decode(&r); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("invalid health report: %s", err)
+	}
+	return &r, nil
+}
@@ -0,0 +1,44 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestReport(t *testing.T) {
+	old := CheckTimeout
+	CheckTimeout = 50 * time.Millisecond
+	defer func() { CheckTimeout = old }()
+
+	calls := 0
+	s := &Service{checks: map[string]CheckFunc{
+		"ok": func(ctx context.Context) error {
+			calls++
+			return nil
+		},
+		"broken": func(ctx context.Context) error {
+			return errors.New("broken")
+		},
+		"stuck": func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	}}
+
+	r := s.Report(context.Background())
+	if !r.Checks["ok"].OK {
+		t.Fatal("ok check reported failing")
+	}
+	if c := r.Checks["broken"]; c.OK || c.Error != "broken" {
+		t.Fatalf("broken check reported as %+v", c)
+	}
+	if c := r.Checks["stuck"]; c.OK || c.Latency < CheckTimeout {
+		t.Fatalf("stuck check reported as %+v", c)
+	}
+
+	if s.Report(context.Background()) != r || calls != 1 {
+		t.Fatal("a recent report should be served again")
+	}
+}
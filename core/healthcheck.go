@@ -0,0 +1,41 @@
+package core
+
+import (
+	"context"
+	"errors"
+
+	health "github.com/ipfs/go-ipfs/core/health"
+	bitswap "github.com/ipfs/go-ipfs/exchange/bitswap"
+)
+
+// healthChecks returns the checks of the subsystems of the node the health
+// service runs for peers.
+func (n *IpfsNode) healthChecks() map[string]health.CheckFunc {
+	checks := make(map[string]health.CheckFunc)
+
+	if bs, ok := n.Exchange.(*bitswap.Bitswap); ok {
+		checks["bitswap"] = bs.CheckHealth
+	}
+
+	if d := n.DHT(); d != nil {
+		// a query for our own key gets answers from the closest peers as
+		// long as the routing table and the query machinery work
+		checks["dht"] = func(ctx context.Context) error {
+			ctx, cancel := context.WithCancel(ctx)
+			defer cancel()
+
+			peers, err := d.GetClosestPeers(ctx, string(n.Identity))
+			if err != nil {
+				return err
+			}
+			if _, ok := <-peers; ok {
+				return nil
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			return errors.New("no peer answered")
+		}
+	}
+	return checks
+}
@@ -0,0 +1,224 @@
+// Package memtune applies the Memory config to the Go runtime, and reports
+// the live heap of the daemon by subsystem.
+package memtune
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+
+	config "github.com/ipfs/go-ipfs/repo/config"
+
+	humanize "gx/ipfs/QmPSBJL4momYnE7DcUyk2DVhD6rH488ZmHBGLbxNdhU44K/go-humanize"
+	logging "gx/ipfs/QmSpJByNKFX1sCsHBEp3R73FL4NF6FnQTEGyNAXHm2GS52/go-log"
+)
+
+var log = logging.Logger("memtune")
+
+var (
+	mu        sync.Mutex
+	gcPercent = readGCPercent()
+	ballast   []byte
+)
+
+// readGCPercent returns the current GC percent, which the runtime only
+// tells when it is changed.
+func readGCPercent() int {
+	p := debug.SetGCPercent(100)
+	debug.SetGCPercent(p)
+	return p
+}
+
+// Apply sets the GC percent and allocates the ballast of cfg.
+func Apply(cfg config.Memory) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if cfg.GCPercent != 0 {
+		if os.Getenv("GOGC") != "" {
+			log.Warningf("GOGC is set, ignoring Memory.GCPercent")
+		} else {
+			debug.SetGCPercent(cfg.GCPercent)
+			gcPercent = cfg.GCPercent
+		}
+	}
+
+	ballast = nil
+	if cfg.Ballast != "" {
+		size, err := humanize.ParseBytes(cfg.Ballast)
+		if err != nil {
+			return fmt.Errorf("invalid Memory.Ballast: %s", err)
+		}
+		if size > uint64(^uint(0)>>1) {
+			return fmt.Errorf("Memory.Ballast of %s is too large for this system", cfg.Ballast)
+		}
+		log.Infof("allocating a heap ballast of %s", humanize.Bytes(size))
+		// the pages of the ballast are never written to, so they are not
+		// backed by physical memory
+		ballast = make([]byte, size)
+	}
+	return nil
+}
+
+// BallastSize returns the size of the heap ballast, which counts in the heap
+// but uses no memory.
+func BallastSize() uint64 {
+	mu.Lock()
+	defer mu.Unlock()
+	return uint64(len(ballast))
+}
+
+// Subsystem is the live heap allocated by the code of one package.
+type Subsystem struct {
+	Name    string
+	Bytes   uint64
+	Objects uint64
+}
+
+// Report is the memory use of the daemon.
+type Report struct {
+	HeapAlloc  uint64
+	HeapInuse  uint64
+	HeapSys    uint64
+	Sys        uint64
+	NextGC     uint64
+	NumGC      uint32
+	GCPercent  int
+	Ballast    uint64
+	Subsystems []Subsystem
+}
+
+type bySize []Subsystem
+
+func (s bySize) Len() int           { return len(s) }
+func (s bySize) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s bySize) Less(i, j int) bool { return s[i].Bytes > s[j].Bytes }
+
+// Stats returns the memory use of the daemon. The heap of each subsystem is
+// estimated from the samples of the memory profile, as of the last garbage
+// collection.
+func Stats() Report {
+	mu.Lock()
+	r := Report{
+		GCPercent: gcPercent,
+		Ballast:   uint64(len(ballast)),
+	}
+	mu.Unlock()
+
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	r.HeapAlloc = ms.HeapAlloc
+	r.HeapInuse = ms.HeapInuse
+	r.HeapSys = ms.HeapSys
+	r.Sys = ms.Sys
+	r.NextGC = ms.NextGC
+	r.NumGC = ms.NumGC
+
+	r.Subsystems = subsystems(memProfile(), runtime.MemProfileRate)
+	return r
+}
+
+func memProfile() []runtime.MemProfileRecord {
+	n, _ := runtime.MemProfile(nil, false)
+	for {
+		// leave room for the records added in between
+		recs := make([]runtime.MemProfileRecord, n+50)
+		m, ok := runtime.MemProfile(recs, false)
+		if ok {
+			return recs[:m]
+		}
+		n = m
+	}
+}
+
+// subsystems sums the live heap of the profile records by subsystem,
+// scaling the samples up by the sampling rate as pprof does.
+func subsystems(recs []runtime.MemProfileRecord, rate int) []Subsystem {
+	sums := make(map[string]*Subsystem)
+	for i := range recs {
+		rec := &recs[i]
+		objects, bytes := rec.InUseObjects(), rec.InUseBytes()
+		if objects <= 0 || bytes <= 0 {
+			continue
+		}
+
+		scale := 1.0
+		if rate > 1 {
+			avg := float64(bytes) / float64(objects)
+			scale = 1 / (1 - math.Exp(-avg/float64(rate)))
+		}
+
+		name := stackSubsystem(rec.Stack())
+		s, ok := sums[name]
+		if !ok {
+			s = &Subsystem{Name: name}
+			sums[name] = s
+		}
+		s.Bytes += uint64(float64(bytes) * scale)
+		s.Objects += uint64(float64(objects) * scale)
+	}
+
+	out := make([]Subsystem, 0, len(sums))
+	for _, s := range sums {
+		out = append(out, *s)
+	}
+	sort.Sort(bySize(out))
+	return out
+}
+
+// stackSubsystem returns the subsystem of the innermost frame of stack
+// outside of the runtime and the standard library.
+func stackSubsystem(stack []uintptr) string {
+	frames := runtime.CallersFrames(stack)
+	for {
+		f, more := frames.Next()
+		if name := funcSubsystem(f.Function); name != "" {
+			return name
+		}
+		if !more {
+			return "other"
+		}
+	}
+}
+
+// funcSubsystem returns the package of the function called fn, as
+// "exchange/bitswap" for the packages of go-ipfs and "go-libp2p-kad-dht" for
+// the gx ones, or "" for the standard library.
+func funcSubsystem(fn string) string {
+	// the package ends at the first dot after the last slash
+	slash := strings.LastIndex(fn, "/")
+	dot := strings.Index(fn[slash+1:], ".")
+	if dot < 0 {
+		return ""
+	}
+	pkg := fn[:slash+1+dot]
+
+	if i := strings.LastIndex(pkg, "/vendor/"); i >= 0 {
+		pkg = pkg[i+len("/vendor/"):]
+	}
+
+	switch {
+	case pkg == "main":
+		return "cmd/ipfs"
+	case strings.HasPrefix(pkg, "github.com/ipfs/go-ipfs/"):
+		pkg = strings.TrimPrefix(pkg, "github.com/ipfs/go-ipfs/")
+		return strings.TrimPrefix(pkg, "Godeps/_workspace/src/")
+	case strings.HasPrefix(pkg, "gx/ipfs/"):
+		// drop the hash
+		parts := strings.SplitN(pkg, "/", 4)
+		if len(parts) == 4 {
+			return parts[3]
+		}
+		return pkg
+	case !strings.Contains(strings.SplitN(pkg, "/", 2)[0], "."):
+		// the standard library, the subsystem is the caller
+		return ""
+	default:
+		return pkg
+	}
+}
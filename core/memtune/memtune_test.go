@@ -0,0 +1,52 @@
+package memtune
+
+import (
+	"testing"
+
+	config "github.com/ipfs/go-ipfs/repo/config"
+)
+
+func TestFuncSubsystem(t *testing.T) {
+	cases := map[string]string{
+		"github.com/ipfs/go-ipfs/exchange/bitswap.(*Bitswap).ReceiveMessage":                  "exchange/bitswap",
+		"github.com/ipfs/go-ipfs/exchange/bitswap/decision.(*Engine).MessageReceived.func1":   "exchange/bitswap/decision",
+		"gx/ipfs/QmTHyAbD9KzGrseLNzmEoNkVxA8F2h7LQG2iV6uhBqs6kX/go-libp2p-kad-dht.New":        "go-libp2p-kad-dht",
+		"gx/ipfs/QmQA5mdxru8Bh6dpC9PJfSkumqnmHgJX7knxSgBo5Lpime/go-libp2p/p2p/host/basic.New": "go-libp2p/p2p/host/basic",
+		"main.daemonFunc":                           "cmd/ipfs",
+		"runtime.malg":                              "",
+		"net/http.(*conn).serve":                    "",
+		"github.com/whyrusleeping/yamux.newSession": "github.com/whyrusleeping/yamux",
+	}
+	for fn, expected := range cases {
+		if got := funcSubsystem(fn); got != expected {
+			t.Errorf("%s: expected %q, got %q", fn, expected, got)
+		}
+	}
+}
+
+func TestBallast(t *testing.T) {
+	defer Apply(config.Memory{})
+
+	if err := Apply(config.Memory{Ballast: "64MB"}); err != nil {
+		t.Fatal(err)
+	}
+	if r := Stats(); r.Ballast != 64e6 {
+		t.Fatalf("expected a ballast of 64MB, got %d bytes", r.Ballast)
+	}
+
+	if err := Apply(config.Memory{Ballast: "a lot"}); err == nil {
+		t.Fatal("expected an error for an invalid ballast")
+	}
+}
+
+func TestStatsSubsystems(t *testing.T) {
+	r := Stats()
+	if r.HeapAlloc == 0 || r.Sys == 0 {
+		t.Fatalf("expected memory stats, got %+v", r)
+	}
+	for i := 1; i < len(r.Subsystems); i++ {
+		if r.Subsystems[i].Bytes > r.Subsystems[i-1].Bytes {
+			t.Fatal("expected the subsystems sorted by size")
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	mfs "github.com/ipfs/go-ipfs/mfs"
+	path "github.com/ipfs/go-ipfs/path"
+)
+
+// pathCacheSize is the number of path resolutions each node caches.
+const pathCacheSize = 4096
+
+// Namespace resolves the paths of a custom namespace, such as /geo/..., to
+// paths of another namespace, usually /ipfs. Namespaces are registered by
+// plugins, and resolved by Resolve on every node.
+type Namespace struct {
+	// Name is the first segment of the paths of the namespace.
+	Name string
+
+	// TTL is how long resolutions are cached; zero disables caching. They
+	// are also dropped whenever the MFS root of the node changes, since
+	// namespaces may be backed by it.
+	TTL time.Duration
+
+	Resolve func(ctx context.Context, n *IpfsNode, p path.Path) (path.Path, error)
+}
+
+var namespaces = struct {
+	sync.Mutex
+	list []Namespace
+}{}
+
+// RegisterNamespace adds ns to the namespaces resolved by the nodes built
+// afterwards. It is meant to be called from init functions.
+func RegisterNamespace(ns Namespace) error {
+	if ns.Resolve == nil {
+		return fmt.Errorf("namespace %s has no Resolve function", ns.Name)
+	}
+	if err := path.RegisterNamespace(ns.Name); err != nil {
+		return err
+	}
+
+	namespaces.Lock()
+	defer namespaces.Unlock()
+	namespaces.list = append(namespaces.list, ns)
+	return nil
+}
+
+// setupResolver sets the path resolver of the node up, with a cache and the
+// registered namespaces.
+func (n *IpfsNode) setupResolver() error {
+	n.Resolver = path.NewBasicResolver(n.DAG)
+
+	cache, err := path.NewCache(pathCacheSize)
+	if err != nil {
+		return err
+	}
+	n.Resolver.Cache = cache
+
+	namespaces.Lock()
+	defer namespaces.Unlock()
+
+	n.Resolver.Namespaces = make(map[string]path.NamespaceResolver)
+	for _, ns := range namespaces.list {
+		resolve := ns.Resolve
+		n.Resolver.Namespaces[ns.Name] = path.NamespaceResolver{
+			TTL: ns.TTL,
+			Resolve: func(ctx context.Context, p path.Path) (path.Path, error) {
+				return resolve(ctx, n, p)
+			},
+		}
+	}
+	return nil
+}
+
+func init() {
+	err := RegisterNamespace(Namespace{
+		Name:    "mfs",
+		TTL:     time.Minute,
+		Resolve: resolveMFS,
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+// resolveMFS resolves /mfs/<path> to the current node of <path> in the MFS
+// root of n, as in 'ipfs files stat'.
+func resolveMFS(ctx context.Context, n *IpfsNode, p path.Path) (path.Path, error) {
+	if n.FilesRoot == nil {
+		return "", fmt.Errorf("node has no MFS root")
+	}
+
+	mp := "/" + strings.Join(p.Segments()[1:], "/")
+	fsn, err := mfs.Lookup(n.FilesRoot, mp)
+	if err != nil {
+		return "", err
+	}
+	nd, err := fsn.GetNode()
+	if err != nil {
+		return "", err
+	}
+	return path.FromCid(nd.Cid()), nil
+}
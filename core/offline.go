@@ -0,0 +1,36 @@
+package core
+
+import (
+	bserv "github.com/ipfs/go-ipfs/blockservice"
+	offline "github.com/ipfs/go-ipfs/exchange/offline"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	namesys "github.com/ipfs/go-ipfs/namesys"
+	offroute "github.com/ipfs/go-ipfs/routing/offline"
+)
+
+// Offline returns a view of the node which only reads the local blocks and
+// IPNS records, for the requests which must fail rather than wait on the
+// network. It shares the repo and the rest of the services of n, and must
+// not be closed. An offline node is returned as is.
+func (n *IpfsNode) Offline() (*IpfsNode, error) {
+	if !n.OnlineMode() {
+		return n, nil
+	}
+
+	nsopts, err := n.getNamesysOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	o := *n
+	o.mode = offlineMode
+	o.Exchange = offline.Exchange(n.Blockstore)
+	o.Blocks = bserv.New(n.Blockstore, o.Exchange)
+	o.DAG = dag.NewDAGService(o.Blocks)
+	o.Routing = offroute.NewOfflineRouter(n.Repo.Datastore(), n.PrivateKey)
+	o.Namesys = namesys.NewNameSystemWithOptions(o.Routing, n.Repo.Datastore(), nsopts)
+	if err := o.setupResolver(); err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
@@ -21,6 +21,12 @@ var ErrNoNamesys = errors.New(
 // entries (e.g. /ipns/<node-key>) and then going through the /ipfs/
 // entries and returning the final node.
 func Resolve(ctx context.Context, nsys namesys.NameSystem, r *path.Resolver, p path.Path) (node.Node, error) {
+	// resolve the paths of registered namespaces, like /mfs/<path>
+	p, err := r.ResolveNamespace(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+
 	if strings.HasPrefix(p.String(), "/ipns/") {
 		// resolve ipns paths
 
@@ -5,6 +5,7 @@ import (
 
 	core "github.com/ipfs/go-ipfs/core"
 	coremock "github.com/ipfs/go-ipfs/core/mock"
+	mfs "github.com/ipfs/go-ipfs/mfs"
 	path "github.com/ipfs/go-ipfs/path"
 )
 
@@ -29,3 +30,37 @@ func TestResolveNoComponents(t *testing.T) {
 		t.Fatal("Should error with invalid path.", err)
 	}
 }
+
+func TestResolveMFS(t *testing.T) {
+	n, err := coremock.NewMockNode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mfs.Mkdir(n.FilesRoot, "/foo", false, true); err != nil {
+		t.Fatal(err)
+	}
+	p, err := path.ParsePath("/mfs/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	before, err := core.Resolve(n.Context(), n.Namesys, n.Resolver, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the cached resolution is dropped as MFS changes
+	if err := mfs.Mkdir(n.FilesRoot, "/foo/bar", false, true); err != nil {
+		t.Fatal(err)
+	}
+	after, err := core.Resolve(n.Context(), n.Namesys, n.Resolver, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if before.Cid().Equals(after.Cid()) {
+		t.Fatal("expected /mfs/foo to resolve to its new node")
+	}
+	if _, _, err := after.ResolveLink([]string{"bar"}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,304 @@
+// Package peerauth restricts the peers a node talks to, to those on an
+// allowlist and to the holders of certificates signed by trusted keys, as
+// configured by Swarm.PeerAuth.
+package peerauth
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	config "github.com/ipfs/go-ipfs/repo/config"
+
+	ci "gx/ipfs/QmP1DfoUjiWH2ZBo1PBH6FupdBucbDepx3HpWmEY6JMUpY/go-libp2p-crypto"
+	logging "gx/ipfs/QmSpJByNKFX1sCsHBEp3R73FL4NF6FnQTEGyNAXHm2GS52/go-log"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+	smux "gx/ipfs/QmeZBgYBHvxMukGK5ojg28BCNLB9SeXqT7XXg6o7r2GbJy/go-stream-muxer"
+)
+
+var log = logging.Logger("peerauth")
+
+// AuthTimeout bounds the time a peer has to present its certificate.
+var AuthTimeout = 10 * time.Second
+
+// maxCertSize bounds the size of the certificates read from peers.
+const maxCertSize = 8 << 10
+
+var ErrNotAuthorized = errors.New("peer is not authorized")
+
+// Certificate is the statement, signed by a CA key, that a peer may be
+// talked to.
+type Certificate struct {
+	Peer string
+	// CA is the peer id of the signing key
+	CA        string
+	Expires   time.Time
+	Signature []byte
+}
+
+func (c *Certificate) signedData() []byte {
+	return []byte(fmt.Sprintf("ipfs peer certificate\npeer: %s\nca: %s\nexpires: %s\n",
+		c.Peer, c.CA, c.Expires.UTC().Format(time.RFC3339Nano)))
+}
+
+// IssueCertificate returns a certificate of p, signed by ca, valid until
+// expires.
+func IssueCertificate(ca ci.PrivKey, p peer.ID, expires time.Time) (*Certificate, error) {
+	caID, err := peer.IDFromPrivateKey(ca)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Certificate{
+		Peer:    p.Pretty(),
+		CA:      caID.Pretty(),
+		Expires: expires.UTC(),
+	}
+	c.Signature, err = ca.Sign(c.signedData())
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Encode returns the form of c found in the config.
+func (c *Certificate) Encode() (string, error) {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+// DecodeCertificate decodes a certificate encoded by Encode.
+func DecodeCertificate(s string) (*Certificate, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid certificate: %s", err)
+	}
+	c := new(Certificate)
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, fmt.Errorf("invalid certificate: %s", err)
+	}
+	return c, nil
+}
+
+// Verify checks that c is a certificate of p, signed by one of cas, and not
+// expired at now.
+func (c *Certificate) Verify(p peer.ID, cas map[string]ci.PubKey, now time.Time) error {
+	if c.Peer != p.Pretty() {
+		return fmt.Errorf("certificate of %s presented by %s", c.Peer, p.Pretty())
+	}
+	ca, ok := cas[c.CA]
+	if !ok {
+		return fmt.Errorf("certificate signed by untrusted key %s", c.CA)
+	}
+	if now.After(c.Expires) {
+		return fmt.Errorf("certificate expired on %s", c.Expires)
+	}
+	ok, err := ca.Verify(c.signedData(), c.Signature)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("invalid certificate signature")
+	}
+	return nil
+}
+
+// EncodePublicKey returns the form of pk found in the CAKeys of the config.
+func EncodePublicKey(pk ci.PubKey) (string, error) {
+	b, err := pk.Bytes()
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+// Authorizer decides which peers may be talked to.
+type Authorizer struct {
+	allowed map[peer.ID]bool
+	cas     map[string]ci.PubKey
+	// own certificate, presented to peers
+	cert []byte
+
+	// for tests
+	now func() time.Time
+}
+
+// New builds an Authorizer for the node self from cfg.
+func New(cfg config.PeerAuthConfig, self peer.ID) (*Authorizer, error) {
+	a := &Authorizer{
+		allowed: make(map[peer.ID]bool),
+		cas:     make(map[string]ci.PubKey),
+		now:     time.Now,
+	}
+
+	// we always talk to ourselves
+	a.allowed[self] = true
+	for _, s := range cfg.AllowedPeers {
+		p, err := peer.IDB58Decode(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid allowed peer %q: %s", s, err)
+		}
+		a.allowed[p] = true
+	}
+
+	for _, s := range cfg.CAKeys {
+		b, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CA key %q: %s", s, err)
+		}
+		pk, err := ci.UnmarshalPublicKey(b)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CA key %q: %s", s, err)
+		}
+		id, err := peer.IDFromPublicKey(pk)
+		if err != nil {
+			return nil, err
+		}
+		a.cas[id.Pretty()] = pk
+	}
+
+	if cfg.Certificate != "" {
+		c, err := DecodeCertificate(cfg.Certificate)
+		if err != nil {
+			return nil, err
+		}
+		if c.Peer != self.Pretty() {
+			return nil, fmt.Errorf("configured certificate is for %s, not for this node", c.Peer)
+		}
+		if a.now().After(c.Expires) {
+			log.Warningf("the certificate of this node expired on %s", c.Expires)
+		}
+		a.cert, err = json.Marshal(c)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+// Transport returns tpt restricted to the authorized peers. Each connection
+// is checked once it is secured, before its streams are multiplexed and it
+// is handed to the swarm: the connections of other peers are closed before
+// identify, the DHT or any protocol sees them.
+//
+// The certificate of the node, if any, is sent to every peer on the first
+// stream of the connection, and the one of a peer off the allowlist is read
+// from the first stream it opens.
+func (a *Authorizer) Transport(tpt smux.Transport) smux.Transport {
+	return &transport{Transport: tpt, a: a}
+}
+
+type transport struct {
+	smux.Transport
+	a *Authorizer
+}
+
+// secureConn is a connection secured by the swarm, which knows the peer at
+// the other end.
+type secureConn interface {
+	RemotePeer() peer.ID
+}
+
+func (t *transport) NewConn(c net.Conn, isServer bool) (smux.Conn, error) {
+	sc, ok := c.(secureConn)
+	if !ok {
+		c.Close()
+		return nil, errors.New("peerauth: the connection is not authenticated")
+	}
+	p := sc.RemotePeer()
+
+	mc, err := t.Transport.NewConn(c, isServer)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.a.authenticate(mc, p); err != nil {
+		log.Infof("peerauth: %s denied: %s", p.Pretty(), err)
+		mc.Close()
+		return nil, ErrNotAuthorized
+	}
+	return mc, nil
+}
+
+// authenticate presents the certificate of the node to p over mc, and
+// checks that p is authorized.
+func (a *Authorizer) authenticate(mc smux.Conn, p peer.ID) error {
+	if a.cert != nil {
+		// before any other stream, for the peer to find it first
+		if err := a.sendCert(mc); err != nil {
+			log.Debugf("failed to send certificate to %s: %s", p.Pretty(), err)
+		}
+	}
+
+	if a.allowed[p] {
+		return nil
+	}
+	if len(a.cas) == 0 {
+		return errors.New("not on the allowlist")
+	}
+
+	b, err := receiveCert(mc)
+	if err != nil {
+		return err
+	}
+	if len(b) == 0 {
+		return errors.New("no certificate presented")
+	}
+	var c Certificate
+	if err := json.Unmarshal(b, &c); err != nil {
+		return fmt.Errorf("invalid certificate: %s", err)
+	}
+	return c.Verify(p, a.cas, a.now())
+}
+
+func (a *Authorizer) sendCert(mc smux.Conn) error {
+	s, err := mc.OpenStream()
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	_, err = s.Write(a.cert)
+	return err
+}
+
+// receiveCert reads the certificate sent on the first stream of mc, within
+// AuthTimeout.
+func receiveCert(mc smux.Conn) ([]byte, error) {
+	type result struct {
+		b   []byte
+		err error
+	}
+	res := make(chan result, 1)
+	go func() {
+		s, err := mc.AcceptStream()
+		if err != nil {
+			res <- result{nil, err}
+			return
+		}
+		defer s.Close()
+		buf := new(bytes.Buffer)
+		_, err = io.Copy(buf, io.LimitReader(s, maxCertSize))
+		res <- result{buf.Bytes(), err}
+	}()
+
+	t := time.NewTimer(AuthTimeout)
+	defer t.Stop()
+	select {
+	case r := <-res:
+		if r.err != nil {
+			return nil, fmt.Errorf("could not get certificate: %s", r.err)
+		}
+		return r.b, nil
+	case <-t.C:
+		// unblocks the reader
+		mc.Close()
+		return nil, errors.New("timed out waiting for certificate")
+	}
+}
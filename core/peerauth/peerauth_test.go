@@ -0,0 +1,240 @@
+package peerauth
+
+import (
+	"crypto/rand"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	config "github.com/ipfs/go-ipfs/repo/config"
+
+	ci "gx/ipfs/QmP1DfoUjiWH2ZBo1PBH6FupdBucbDepx3HpWmEY6JMUpY/go-libp2p-crypto"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+	smux "gx/ipfs/QmeZBgYBHvxMukGK5ojg28BCNLB9SeXqT7XXg6o7r2GbJy/go-stream-muxer"
+)
+
+func newKey(t *testing.T) (ci.PrivKey, peer.ID) {
+	sk, _, err := ci.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := peer.IDFromPrivateKey(sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sk, id
+}
+
+func TestCertificate(t *testing.T) {
+	ca, _ := newKey(t)
+	other, _ := newKey(t)
+	_, p := newKey(t)
+	_, q := newKey(t)
+
+	caPub, err := EncodePublicKey(ca.GetPublic())
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := New(config.PeerAuthConfig{CAKeys: []string{caPub}}, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	c, err := IssueCertificate(ca, p, now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	enc, err := c.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err = DecodeCertificate(enc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Verify(p, a.cas, now); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Verify(q, a.cas, now); err == nil {
+		t.Fatal("certificate of another peer accepted")
+	}
+	if err := c.Verify(p, a.cas, now.Add(2*time.Hour)); err == nil {
+		t.Fatal("expired certificate accepted")
+	}
+
+	untrusted, err := IssueCertificate(other, p, now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := untrusted.Verify(p, a.cas, now); err == nil {
+		t.Fatal("certificate of an untrusted key accepted")
+	}
+
+	// a forged certificate claiming to come from the trusted key
+	untrusted.CA = c.CA
+	if err := untrusted.Verify(p, a.cas, now); err == nil {
+		t.Fatal("forged certificate accepted")
+	}
+}
+
+func TestNewRejectsForeignCertificate(t *testing.T) {
+	ca, _ := newKey(t)
+	_, p := newKey(t)
+	_, self := newKey(t)
+
+	c, err := IssueCertificate(ca, p, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, err := c.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := New(config.PeerAuthConfig{Certificate: enc}, self); err == nil {
+		t.Fatal("expected the certificate of another peer to be rejected")
+	}
+	if _, err := New(config.PeerAuthConfig{Certificate: enc}, p); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// pipeStream is a stream of pipeMux.
+type pipeStream struct {
+	net.Conn
+}
+
+func (s pipeStream) Reset() error { return s.Close() }
+
+// pipeMux is one end of an in-memory multiplexed connection.
+type pipeMux struct {
+	accept chan net.Conn
+	other  *pipeMux
+	closed chan struct{}
+}
+
+func newPipeMuxes() (*pipeMux, *pipeMux) {
+	a := &pipeMux{accept: make(chan net.Conn, 8), closed: make(chan struct{})}
+	b := &pipeMux{accept: make(chan net.Conn, 8), closed: make(chan struct{})}
+	a.other, b.other = b, a
+	return a, b
+}
+
+func (m *pipeMux) OpenStream() (smux.Stream, error) {
+	c1, c2 := net.Pipe()
+	m.other.accept <- c2
+	return pipeStream{c1}, nil
+}
+
+func (m *pipeMux) AcceptStream() (smux.Stream, error) {
+	select {
+	case c := <-m.accept:
+		return pipeStream{c}, nil
+	case <-m.closed:
+		return nil, errors.New("closed")
+	}
+}
+
+func (m *pipeMux) IsClosed() bool {
+	select {
+	case <-m.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+func (m *pipeMux) Close() error {
+	if !m.IsClosed() {
+		close(m.closed)
+	}
+	return nil
+}
+
+// secured is a connection secured with the remote peer, muxed by mux.
+type secured struct {
+	net.Conn
+	remote peer.ID
+	mux    *pipeMux
+}
+
+func (c secured) RemotePeer() peer.ID { return c.remote }
+
+type pipeTransport struct{}
+
+func (pipeTransport) NewConn(c net.Conn, isServer bool) (smux.Conn, error) {
+	return c.(secured).mux, nil
+}
+
+// connect runs the upgrades of the connection between the nodes a and b at
+// once, and returns their errors.
+func connect(ta, tb smux.Transport, a, b peer.ID) (error, error) {
+	ma, mb := newPipeMuxes()
+	errs := make(chan error, 1)
+	go func() {
+		_, err := tb.NewConn(secured{remote: a, mux: mb}, true)
+		errs <- err
+	}()
+	_, err := ta.NewConn(secured{remote: b, mux: ma}, false)
+	return err, <-errs
+}
+
+func TestTransport(t *testing.T) {
+	AuthTimeout = 200 * time.Millisecond
+
+	ca, _ := newKey(t)
+	_, self := newKey(t)
+	_, friend := newKey(t)
+	_, certified := newKey(t)
+	_, stranger := newKey(t)
+
+	caPub, err := EncodePublicKey(ca.GetPublic())
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := New(config.PeerAuthConfig{
+		AllowedPeers: []string{friend.Pretty()},
+		CAKeys:       []string{caPub},
+	}, self)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ta := a.Transport(pipeTransport{})
+
+	c, err := IssueCertificate(ca, certified, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, err := c.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	certAuth, err := New(config.PeerAuthConfig{
+		AllowedPeers: []string{self.Pretty()},
+		Certificate:  enc,
+	}, certified)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a peer on the allowlist, without peerauth
+	if err, _ := connect(ta, pipeTransport{}, self, friend); err != nil {
+		t.Fatalf("expected the allowed peer to connect, got %s", err)
+	}
+	// a peer presenting a certificate
+	if errA, errB := connect(ta, certAuth.Transport(pipeTransport{}), self, certified); errA != nil || errB != nil {
+		t.Fatalf("expected the certified peer to connect, got %v, %v", errA, errB)
+	}
+	// a peer with neither
+	if err, _ := connect(ta, pipeTransport{}, self, stranger); err != ErrNotAuthorized {
+		t.Fatalf("expected the stranger to be refused, got %v", err)
+	}
+	// nor may a connection without a peer identity be upgraded
+	if _, err := ta.NewConn(&net.TCPConn{}, false); err == nil {
+		t.Fatal("expected the connection without a peer identity to be refused")
+	}
+}
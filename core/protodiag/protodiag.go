@@ -0,0 +1,244 @@
+// Package protodiag records the failures that make peers unreachable without
+// a clear error: failed protocol negotiations, identify mismatches and
+// security handshake errors.
+package protodiag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	identify "gx/ipfs/QmQA5mdxru8Bh6dpC9PJfSkumqnmHgJX7knxSgBo5Lpime/go-libp2p/p2p/protocol/identify"
+	inet "gx/ipfs/QmRscs8KxrSmSv4iuevHv8JfuUzHBMoqiaHzxfDRiksd6e/go-libp2p-net"
+	p2phost "gx/ipfs/QmUywuGNZoUKV8B9iyvup9bPkLiMrhTsyVMkeSXW5VxAfC/go-libp2p-host"
+	pstore "gx/ipfs/QmXZSd1qR5BxZkPyuwfT5jpqQFScZccoZvDneXsKzCNHWX/go-libp2p-peerstore"
+	protocol "gx/ipfs/QmZNkThpqfVXs9GNbexPrfBbXSLNYeKrE7jwFM2oqHbyqN/go-libp2p-protocol"
+	ma "gx/ipfs/QmcyqRMCAXVtYPS4DiBrA7sezL9rRGfW8Ctx7cywL4TXJj/go-multiaddr"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+// Kinds of failures.
+const (
+	KindNegotiation = "negotiation"
+	KindIdentify    = "identify"
+	KindHandshake   = "handshake"
+)
+
+// MaxSamples is the number of most recent failures kept.
+const MaxSamples = 100
+
+// IdentifyTimeout is how long a new connection may go without the remote
+// peer identifying itself before it is recorded as a failure.
+var IdentifyTimeout = 30 * time.Second
+
+// identifyCheckInterval is how often the connections are checked.
+const identifyCheckInterval = time.Second
+
+// Failure is a recorded failure.
+type Failure struct {
+	Time     time.Time
+	Peer     string
+	Kind     string
+	Protocol string `json:",omitempty"`
+	Error    string
+}
+
+// PeerFailures counts the failures of a peer by kind.
+type PeerFailures struct {
+	Peer   string
+	Counts map[string]int
+}
+
+// Recorder collects failures.
+type Recorder struct {
+	lk      sync.Mutex
+	counts  map[peer.ID]map[string]int
+	samples []Failure
+	next    int
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{counts: make(map[peer.ID]map[string]int)}
+}
+
+// Record adds a failure of kind with p.
+func (r *Recorder) Record(p peer.ID, kind string, proto string, err error) {
+	f := Failure{
+		Time:     time.Now(),
+		Peer:     p.Pretty(),
+		Kind:     kind,
+		Protocol: proto,
+		Error:    err.Error(),
+	}
+
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	c, ok := r.counts[p]
+	if !ok {
+		c = make(map[string]int)
+		r.counts[p] = c
+	}
+	c[kind]++
+
+	if len(r.samples) < MaxSamples {
+		r.samples = append(r.samples, f)
+	} else {
+		r.samples[r.next] = f
+	}
+	r.next = (r.next + 1) % MaxSamples
+}
+
+// Peers returns the failure counts of every peer which failed.
+func (r *Recorder) Peers() []PeerFailures {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	out := make([]PeerFailures, 0, len(r.counts))
+	for p, c := range r.counts {
+		counts := make(map[string]int, len(c))
+		for k, v := range c {
+			counts[k] = v
+		}
+		out = append(out, PeerFailures{Peer: p.Pretty(), Counts: counts})
+	}
+	return out
+}
+
+// Samples returns the most recent failures, oldest first.
+func (r *Recorder) Samples() []Failure {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	if len(r.samples) < MaxSamples {
+		return append([]Failure(nil), r.samples...)
+	}
+	return append(append([]Failure(nil), r.samples[r.next:]...), r.samples[:r.next]...)
+}
+
+// classify tells which kind of failure err is, if any. Other errors, such as
+// unreachable addresses, are not recorded.
+func classify(err error) (string, bool) {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "protocol not supported"):
+		return KindNegotiation, true
+	case strings.Contains(msg, "secio"), strings.Contains(msg, "handshake"),
+		strings.Contains(msg, "private network"), strings.Contains(msg, "pnet"):
+		return KindHandshake, true
+	}
+	return "", false
+}
+
+// WrapHost returns h recording the failures of its dials and streams in r.
+func WrapHost(h p2phost.Host, r *Recorder) p2phost.Host {
+	return &host{Host: h, r: r}
+}
+
+type host struct {
+	p2phost.Host
+	r *Recorder
+}
+
+func (h *host) Connect(ctx context.Context, pi pstore.PeerInfo) error {
+	err := h.Host.Connect(ctx, pi)
+	if err != nil {
+		if kind, ok := classify(err); ok {
+			h.r.Record(pi.ID, kind, "", err)
+		}
+	}
+	return err
+}
+
+func (h *host) NewStream(ctx context.Context, p peer.ID, pids ...protocol.ID) (inet.Stream, error) {
+	s, err := h.Host.NewStream(ctx, p, pids...)
+	if err != nil {
+		if kind, ok := classify(err); ok {
+			protos := make([]string, len(pids))
+			for i, pid := range pids {
+				protos[i] = string(pid)
+			}
+			h.r.Record(p, kind, strings.Join(protos, ","), err)
+		}
+	}
+	return s, err
+}
+
+// Notifiee returns the network notifiee checking that the peers connecting to
+// h identify themselves with a compatible protocol version, until ctx is done.
+func (r *Recorder) Notifiee(ctx context.Context, h p2phost.Host) inet.Notifiee {
+	n := &notifiee{r: r, h: h, pending: make(map[inet.Conn]time.Time)}
+	go n.loop(ctx)
+	return n
+}
+
+type notifiee struct {
+	r *Recorder
+	h p2phost.Host
+
+	lk sync.Mutex
+	// pending are the connections whose peers have not identified
+	// themselves yet, with the time they have until
+	pending map[inet.Conn]time.Time
+}
+
+func (n *notifiee) Connected(net inet.Network, c inet.Conn) {
+	n.lk.Lock()
+	n.pending[c] = time.Now().Add(IdentifyTimeout)
+	n.lk.Unlock()
+}
+
+func (n *notifiee) Disconnected(net inet.Network, c inet.Conn) {
+	// a peer gone before identifying itself just had a short connection
+	n.lk.Lock()
+	delete(n.pending, c)
+	n.lk.Unlock()
+}
+
+// loop checks the pending connections every identifyCheckInterval, all at
+// once.
+func (n *notifiee) loop(ctx context.Context) {
+	t := time.NewTicker(identifyCheckInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			n.check(time.Now())
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// check records the peers of the pending connections which identified
+// themselves with another protocol version, or which did not in time.
+func (n *notifiee) check(now time.Time) {
+	n.lk.Lock()
+	defer n.lk.Unlock()
+
+	for c, deadline := range n.pending {
+		p := c.RemotePeer()
+		v, err := n.h.Peerstore().Get(p, "ProtocolVersion")
+		switch {
+		case err == nil:
+			if vs, ok := v.(string); ok && vs != identify.LibP2PVersion {
+				n.r.Record(p, KindIdentify, "", fmt.Errorf("peer speaks protocol version %s, expected %s", vs, identify.LibP2PVersion))
+			}
+		case now.After(deadline):
+			n.r.Record(p, KindIdentify, "", errNoIdentify)
+		default:
+			continue
+		}
+		delete(n.pending, c)
+	}
+}
+
+var errNoIdentify = errors.New("peer did not identify itself")
+
+func (n *notifiee) OpenedStream(net inet.Network, s inet.Stream) {}
+func (n *notifiee) ClosedStream(net inet.Network, s inet.Stream) {}
+func (n *notifiee) Listen(net inet.Network, a ma.Multiaddr)      {}
+func (n *notifiee) ListenClose(net inet.Network, a ma.Multiaddr) {}
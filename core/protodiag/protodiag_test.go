@@ -0,0 +1,47 @@
+package protodiag
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+func TestClassify(t *testing.T) {
+	cases := map[string]string{
+		"protocol not supported":                 KindNegotiation,
+		"secio: peer ID mismatch":                KindHandshake,
+		"failed to dial: connection refused":     "",
+		"private network protector: read failed": KindHandshake,
+	}
+	for msg, kind := range cases {
+		k, ok := classify(errors.New(msg))
+		if ok != (kind != "") || k != kind {
+			t.Fatalf("classified %q as %q, expected %q", msg, k, kind)
+		}
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	r := NewRecorder()
+	p := peer.ID("a")
+
+	for i := 0; i < MaxSamples+10; i++ {
+		r.Record(p, KindNegotiation, "/proto", fmt.Errorf("failure %d", i))
+	}
+	r.Record(p, KindHandshake, "", errors.New("last"))
+
+	peers := r.Peers()
+	if len(peers) != 1 || peers[0].Counts[KindNegotiation] != MaxSamples+10 || peers[0].Counts[KindHandshake] != 1 {
+		t.Fatalf("unexpected counts: %+v", peers)
+	}
+
+	samples := r.Samples()
+	if len(samples) != MaxSamples {
+		t.Fatalf("expected %d samples, got %d", MaxSamples, len(samples))
+	}
+	if samples[0].Error != "failure 11" || samples[len(samples)-1].Error != "last" {
+		t.Fatalf("samples out of order: first %q, last %q", samples[0].Error, samples[len(samples)-1].Error)
+	}
+}
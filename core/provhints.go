@@ -0,0 +1,83 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+
+	config "github.com/ipfs/go-ipfs/repo/config"
+	ipfsaddr "github.com/ipfs/go-ipfs/thirdparty/ipfsaddr"
+
+	pstore "gx/ipfs/QmXZSd1qR5BxZkPyuwfT5jpqQFScZccoZvDneXsKzCNHWX/go-libp2p-peerstore"
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+type prefixHint struct {
+	prefix string
+	peers  []peer.ID
+}
+
+// providerHints maps content to the peers of Routing.ProviderHints, for the
+// exchange to reach them without searching for providers.
+type providerHints struct {
+	roots    map[string][]peer.ID
+	prefixes []prefixHint
+}
+
+// newProviderHints checks the hints of the config, and adds the addresses
+// of their peers to ps for good.
+func newProviderHints(hints []config.ProviderHint, ps pstore.Peerstore) (*providerHints, error) {
+	h := &providerHints{roots: make(map[string][]peer.ID)}
+	for i, hint := range hints {
+		if len(hint.Peers) == 0 {
+			return nil, fmt.Errorf("Routing.ProviderHints[%d]: no peer", i)
+		}
+		if len(hint.Roots) == 0 && len(hint.Prefixes) == 0 {
+			return nil, fmt.Errorf("Routing.ProviderHints[%d]: no root or prefix", i)
+		}
+
+		var peers []peer.ID
+		for _, s := range hint.Peers {
+			addr, err := ipfsaddr.ParseString(s)
+			if err != nil {
+				return nil, fmt.Errorf("Routing.ProviderHints[%d]: invalid peer address %q: %s", i, s, err)
+			}
+			if t := addr.Transport(); t != nil {
+				ps.AddAddr(addr.ID(), t, pstore.PermanentAddrTTL)
+			}
+			peers = append(peers, addr.ID())
+		}
+
+		for _, s := range hint.Roots {
+			c, err := cid.Decode(s)
+			if err != nil {
+				return nil, fmt.Errorf("Routing.ProviderHints[%d]: invalid root %q: %s", i, s, err)
+			}
+			k := c.KeyString()
+			h.roots[k] = append(h.roots[k], peers...)
+		}
+		for _, p := range hint.Prefixes {
+			if p == "" {
+				return nil, fmt.Errorf("Routing.ProviderHints[%d]: empty prefix", i)
+			}
+			h.prefixes = append(h.prefixes, prefixHint{p, peers})
+		}
+	}
+	return h, nil
+}
+
+// providers returns the peers hinted to provide c.
+func (h *providerHints) providers(c *cid.Cid) []peer.ID {
+	// not appending to the slice of the map
+	peers := append([]peer.ID(nil), h.roots[c.KeyString()]...)
+	if len(h.prefixes) == 0 {
+		return peers
+	}
+	s := c.String()
+	for _, ph := range h.prefixes {
+		if strings.HasPrefix(s, ph.prefix) {
+			peers = append(peers, ph.peers...)
+		}
+	}
+	return peers
+}
@@ -0,0 +1,60 @@
+package core
+
+import (
+	"testing"
+
+	config "github.com/ipfs/go-ipfs/repo/config"
+
+	pstore "gx/ipfs/QmXZSd1qR5BxZkPyuwfT5jpqQFScZccoZvDneXsKzCNHWX/go-libp2p-peerstore"
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+)
+
+const (
+	hintRoot  = "QmY3hE8xgFCjGcz6PHgnvJz5HZi1BaKRfPkn1ghZUcYMjD"
+	hintPeer1 = "/ip4/10.0.0.1/tcp/4001/ipfs/QmSoLnSGccFuZQJzRadHn95W2CrSFmZuTdDWP8HXaHca9z"
+	hintPeer2 = "/ip4/10.0.0.2/tcp/4001/ipfs/QmSoLPppuBtQSGwKDZT2M73ULpjvfd3aZ6ha4oFGL1KrGM"
+)
+
+func TestProviderHints(t *testing.T) {
+	ps := pstore.NewPeerstore()
+	h, err := newProviderHints([]config.ProviderHint{
+		{Roots: []string{hintRoot}, Peers: []string{hintPeer1}},
+		{Prefixes: []string{"zb2rh"}, Peers: []string{hintPeer2}},
+	}, ps)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root, _ := cid.Decode(hintRoot)
+	peers := h.providers(root)
+	if len(peers) != 1 || peers[0].Pretty() != "QmSoLnSGccFuZQJzRadHn95W2CrSFmZuTdDWP8HXaHca9z" {
+		t.Fatalf("expected the peer of the root, got %v", peers)
+	}
+	if len(ps.Addrs(peers[0])) != 1 {
+		t.Fatal("expected the address of the hinted peer in the peerstore")
+	}
+
+	raw, _ := cid.Decode("zb2rhe5P4gXftAwvA4eXQ5HJwsER2owDyS9sKaQRRVQPn93bA")
+	peers = h.providers(raw)
+	if len(peers) != 1 || peers[0].Pretty() != "QmSoLPppuBtQSGwKDZT2M73ULpjvfd3aZ6ha4oFGL1KrGM" {
+		t.Fatalf("expected the peer of the prefix, got %v", peers)
+	}
+
+	other, _ := cid.Decode("QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn")
+	if peers := h.providers(other); len(peers) != 0 {
+		t.Fatalf("expected no hint, got %v", peers)
+	}
+}
+
+func TestProviderHintsInvalid(t *testing.T) {
+	for _, hint := range []config.ProviderHint{
+		{Roots: []string{hintRoot}},
+		{Peers: []string{hintPeer1}},
+		{Roots: []string{"notacid"}, Peers: []string{hintPeer1}},
+		{Roots: []string{hintRoot}, Peers: []string{"/ip4/10.0.0.1/tcp/4001"}},
+	} {
+		if _, err := newProviderHints([]config.ProviderHint{hint}, pstore.NewPeerstore()); err == nil {
+			t.Errorf("expected an error for %v", hint)
+		}
+	}
+}
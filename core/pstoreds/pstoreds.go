@@ -0,0 +1,374 @@
+// Package pstoreds keeps the peerstore of a node in its datastore, so that
+// the addresses, public keys and latencies of the peers it learned survive
+// restarts, and it reconnects to the network without waiting on its
+// bootstrap peers and the DHT.
+package pstoreds
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	ic "gx/ipfs/QmP1DfoUjiWH2ZBo1PBH6FupdBucbDepx3HpWmEY6JMUpY/go-libp2p-crypto"
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+	dsq "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore/query"
+	logging "gx/ipfs/QmSpJByNKFX1sCsHBEp3R73FL4NF6FnQTEGyNAXHm2GS52/go-log"
+	pstore "gx/ipfs/QmXZSd1qR5BxZkPyuwfT5jpqQFScZccoZvDneXsKzCNHWX/go-libp2p-peerstore"
+	ma "gx/ipfs/QmcyqRMCAXVtYPS4DiBrA7sezL9rRGfW8Ctx7cywL4TXJj/go-multiaddr"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+var log = logging.Logger("pstoreds")
+
+// Prefix namespaces the peer records in the datastore.
+var Prefix = ds.NewKey("/local/peers")
+
+// FlushInterval is how often the changed records are written.
+var FlushInterval = time.Minute
+
+// DefaultMaxAddrTTL caps how long an address is kept: the addresses of
+// connected peers never expire in the peerstore, but may well be stale a
+// day after.
+const DefaultMaxAddrTTL = 24 * time.Hour
+
+type record struct {
+	Addrs   []addrRecord
+	PubKey  []byte        `json:",omitempty"`
+	Latency time.Duration `json:",omitempty"`
+}
+
+type addrRecord struct {
+	Addr    string
+	Expires time.Time
+}
+
+// Store is a peerstore recording the peers added to it in a datastore. It
+// tracks the expiry of the addresses itself, as the peerstore doesn't tell
+// it, and prunes the expired ones from the datastore.
+type Store struct {
+	pstore.Peerstore
+	d      ds.Datastore
+	self   peer.ID
+	maxTTL time.Duration
+
+	lk       sync.Mutex
+	expiries map[peer.ID]map[string]time.Time
+	dirty    map[peer.ID]bool
+	restored []peer.ID
+	closed   bool
+
+	done chan struct{}
+	wg   sync.WaitGroup
+
+	// now is overridden in tests.
+	now func() time.Time
+}
+
+// New wraps ps, recording the peers other than self in d. Addresses are kept
+// at most maxTTL.
+func New(ps pstore.Peerstore, d ds.Datastore, self peer.ID, maxTTL time.Duration) *Store {
+	if maxTTL <= 0 {
+		maxTTL = DefaultMaxAddrTTL
+	}
+	return &Store{
+		Peerstore: ps,
+		d:         d,
+		self:      self,
+		maxTTL:    maxTTL,
+		expiries:  make(map[peer.ID]map[string]time.Time),
+		dirty:     make(map[peer.ID]bool),
+		done:      make(chan struct{}),
+		now:       time.Now,
+	}
+}
+
+// Load adds the recorded peers to the peerstore, with the time left on
+// their addresses, and starts writing the changes every FlushInterval.
+func (s *Store) Load() error {
+	defer s.start()
+
+	res, err := s.d.Query(dsq.Query{Prefix: Prefix.String()})
+	if err != nil {
+		return err
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return err
+	}
+
+	now := s.now()
+	for _, e := range entries {
+		k := ds.NewKey(e.Key)
+		p, err := peer.IDB58Decode(k.BaseNamespace())
+		if err != nil {
+			continue
+		}
+		b, ok := e.Value.([]byte)
+		if !ok {
+			continue
+		}
+		var rec record
+		if err := json.Unmarshal(b, &rec); err != nil {
+			log.Debugf("dropping invalid record of %s: %s", p.Pretty(), err)
+			s.d.Delete(k)
+			continue
+		}
+		if !s.restore(p, rec, now) {
+			// nothing left of it
+			s.d.Delete(k)
+		}
+	}
+	return nil
+}
+
+func (s *Store) restore(p peer.ID, rec record, now time.Time) bool {
+	exp := make(map[string]time.Time)
+	for _, ar := range rec.Addrs {
+		ttl := ar.Expires.Sub(now)
+		if ttl <= 0 {
+			continue
+		}
+		a, err := ma.NewMultiaddr(ar.Addr)
+		if err != nil {
+			continue
+		}
+		s.Peerstore.AddAddr(p, a, ttl)
+		exp[string(a.Bytes())] = ar.Expires
+	}
+	if len(exp) == 0 {
+		return false
+	}
+
+	if rec.PubKey != nil {
+		if pk, err := ic.UnmarshalPublicKey(rec.PubKey); err == nil {
+			s.Peerstore.AddPubKey(p, pk)
+		}
+	}
+	if rec.Latency > 0 {
+		s.Peerstore.RecordLatency(p, rec.Latency)
+	}
+
+	s.lk.Lock()
+	s.expiries[p] = exp
+	s.restored = append(s.restored, p)
+	s.lk.Unlock()
+	return true
+}
+
+// Restored returns the peers loaded from the datastore which still have
+// addresses.
+func (s *Store) Restored() []pstore.PeerInfo {
+	s.lk.Lock()
+	restored := s.restored
+	s.lk.Unlock()
+
+	var out []pstore.PeerInfo
+	for _, p := range restored {
+		if pi := s.Peerstore.PeerInfo(p); len(pi.Addrs) > 0 {
+			out = append(out, pi)
+		}
+	}
+	return out
+}
+
+func (s *Store) start() {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		t := time.NewTicker(FlushInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				if err := s.Flush(); err != nil {
+					log.Warningf("failed to record peers: %s", err)
+				}
+			case <-s.done:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops recording, and writes the last changes. It should be called
+// before the connections are closed, so that the peers are recorded with the
+// addresses they were connected on.
+func (s *Store) Close() error {
+	s.lk.Lock()
+	if s.closed {
+		s.lk.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.lk.Unlock()
+
+	close(s.done)
+	s.wg.Wait()
+	return s.flush()
+}
+
+// note records that p got addrs for ttl. With set, the addresses replace the
+// expiry of the recorded ones instead of extending it.
+func (s *Store) note(p peer.ID, addrs []ma.Multiaddr, ttl time.Duration, set bool) {
+	if p == s.self {
+		return
+	}
+	if ttl > s.maxTTL {
+		ttl = s.maxTTL
+	}
+	expires := s.now().Add(ttl)
+
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	if s.closed {
+		return
+	}
+
+	exp := s.expiries[p]
+	if exp == nil {
+		exp = make(map[string]time.Time)
+		s.expiries[p] = exp
+	}
+	for _, a := range addrs {
+		k := string(a.Bytes())
+		switch {
+		case ttl <= 0:
+			if set {
+				delete(exp, k)
+			}
+		case set || expires.After(exp[k]):
+			exp[k] = expires
+		}
+	}
+	s.dirty[p] = true
+}
+
+func (s *Store) markDirty(p peer.ID) {
+	if p == s.self {
+		return
+	}
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	if !s.closed {
+		s.dirty[p] = true
+	}
+}
+
+func (s *Store) AddAddr(p peer.ID, addr ma.Multiaddr, ttl time.Duration) {
+	s.Peerstore.AddAddr(p, addr, ttl)
+	s.note(p, []ma.Multiaddr{addr}, ttl, false)
+}
+
+func (s *Store) AddAddrs(p peer.ID, addrs []ma.Multiaddr, ttl time.Duration) {
+	s.Peerstore.AddAddrs(p, addrs, ttl)
+	s.note(p, addrs, ttl, false)
+}
+
+func (s *Store) SetAddr(p peer.ID, addr ma.Multiaddr, ttl time.Duration) {
+	s.Peerstore.SetAddr(p, addr, ttl)
+	s.note(p, []ma.Multiaddr{addr}, ttl, true)
+}
+
+func (s *Store) SetAddrs(p peer.ID, addrs []ma.Multiaddr, ttl time.Duration) {
+	s.Peerstore.SetAddrs(p, addrs, ttl)
+	s.note(p, addrs, ttl, true)
+}
+
+func (s *Store) AddPubKey(p peer.ID, pk ic.PubKey) error {
+	if err := s.Peerstore.AddPubKey(p, pk); err != nil {
+		return err
+	}
+	s.markDirty(p)
+	return nil
+}
+
+func (s *Store) RecordLatency(p peer.ID, d time.Duration) {
+	s.Peerstore.RecordLatency(p, d)
+	s.markDirty(p)
+}
+
+// Flush writes the records of the peers changed since the last flush, and
+// deletes the ones left with no address.
+func (s *Store) Flush() error {
+	s.lk.Lock()
+	closed := s.closed
+	s.lk.Unlock()
+	if closed {
+		return nil
+	}
+	return s.flush()
+}
+
+func (s *Store) flush() error {
+	now := s.now()
+
+	s.lk.Lock()
+	// the peers whose addresses all expired are pruned too
+	for p, exp := range s.expiries {
+		for k, t := range exp {
+			if !now.Before(t) {
+				delete(exp, k)
+				s.dirty[p] = true
+			}
+		}
+	}
+	dirty := s.dirty
+	s.dirty = make(map[peer.ID]bool)
+
+	recs := make(map[peer.ID]*record, len(dirty))
+	for p := range dirty {
+		exp := s.expiries[p]
+		if len(exp) == 0 {
+			delete(s.expiries, p)
+			recs[p] = nil
+			continue
+		}
+		rec := &record{}
+		for k, t := range exp {
+			a, err := ma.NewMultiaddrBytes([]byte(k))
+			if err != nil {
+				continue
+			}
+			rec.Addrs = append(rec.Addrs, addrRecord{Addr: a.String(), Expires: t})
+		}
+		recs[p] = rec
+	}
+	s.lk.Unlock()
+
+	var firstErr error
+	for p, rec := range recs {
+		if err := s.write(p, rec); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			// try again on the next flush
+			s.markDirty(p)
+		}
+	}
+	return firstErr
+}
+
+// write puts the record of p, or deletes it if rec is nil.
+func (s *Store) write(p peer.ID, rec *record) error {
+	k := Prefix.ChildString(p.Pretty())
+	if rec == nil {
+		err := s.d.Delete(k)
+		if err != nil && err != ds.ErrNotFound {
+			return err
+		}
+		return nil
+	}
+
+	if pk := s.Peerstore.PubKey(p); pk != nil {
+		if b, err := pk.Bytes(); err == nil {
+			rec.PubKey = b
+		}
+	}
+	rec.Latency = s.Peerstore.LatencyEWMA(p)
+
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return s.d.Put(k, b)
+}
@@ -0,0 +1,114 @@
+package pstoreds
+
+import (
+	"testing"
+	"time"
+
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+	dssync "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore/sync"
+	pstore "gx/ipfs/QmXZSd1qR5BxZkPyuwfT5jpqQFScZccoZvDneXsKzCNHWX/go-libp2p-peerstore"
+	ma "gx/ipfs/QmcyqRMCAXVtYPS4DiBrA7sezL9rRGfW8Ctx7cywL4TXJj/go-multiaddr"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+const (
+	testSelf = "QmUWKoHbjsqsSMesRC2Zoscs8edyFz6F77auBB1YBBhgpX"
+	testPeer = "QmaCpDMGvV2BGHeYERUEnRQAwe3N8SzbUtfsmvsqQLuvuJ"
+)
+
+func mustPeer(t *testing.T, s string) peer.ID {
+	p, err := peer.IDB58Decode(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func mustAddr(t *testing.T, s string) ma.Multiaddr {
+	a, err := ma.NewMultiaddr(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func newStore(d ds.Datastore, self peer.ID, now *time.Time) *Store {
+	s := New(pstore.NewPeerstore(), d, self, time.Hour)
+	s.now = func() time.Time { return *now }
+	return s
+}
+
+func TestStoreSurvivesRestart(t *testing.T) {
+	d := dssync.MutexWrap(ds.NewMapDatastore())
+	self, p := mustPeer(t, testSelf), mustPeer(t, testPeer)
+	short := mustAddr(t, "/ip4/1.2.3.4/tcp/4001")
+	long := mustAddr(t, "/ip4/1.2.3.4/tcp/4002")
+	now := time.Now()
+
+	s := newStore(d, self, &now)
+	if err := s.Load(); err != nil {
+		t.Fatal(err)
+	}
+	s.AddAddr(p, short, 10*time.Minute)
+	// capped to the max TTL of an hour
+	s.AddAddr(p, long, pstore.PermanentAddrTTL)
+	s.AddAddr(self, short, time.Hour)
+	s.RecordLatency(p, 20*time.Millisecond)
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := d.Get(Prefix.ChildString(self.Pretty())); err != ds.ErrNotFound {
+		t.Fatal("the node itself should not be recorded")
+	}
+
+	now = now.Add(30 * time.Minute)
+	s = newStore(d, self, &now)
+	if err := s.Load(); err != nil {
+		t.Fatal(err)
+	}
+	addrs := s.Addrs(p)
+	if len(addrs) != 1 || !addrs[0].Equal(long) {
+		t.Fatalf("expected only the unexpired address, got %s", addrs)
+	}
+	if s.LatencyEWMA(p) == 0 {
+		t.Fatal("latency not restored")
+	}
+	if len(s.Restored()) != 1 {
+		t.Fatal("expected the peer to be reported restored")
+	}
+	s.Close()
+
+	now = now.Add(time.Hour)
+	s = newStore(d, self, &now)
+	if err := s.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Addrs(p)) != 0 {
+		t.Fatal("expired addresses restored")
+	}
+	if _, err := d.Get(Prefix.ChildString(p.Pretty())); err != ds.ErrNotFound {
+		t.Fatal("the record of a peer with no address left should be pruned")
+	}
+	s.Close()
+}
+
+func TestStoreSetAddrsRemoves(t *testing.T) {
+	d := dssync.MutexWrap(ds.NewMapDatastore())
+	p := mustPeer(t, testPeer)
+	a := mustAddr(t, "/ip4/1.2.3.4/tcp/4001")
+	now := time.Now()
+
+	s := newStore(d, mustPeer(t, testSelf), &now)
+	s.AddAddr(p, a, time.Hour)
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	s.SetAddr(p, a, 0)
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Get(Prefix.ChildString(p.Pretty())); err != ds.ErrNotFound {
+		t.Fatal("the record of a peer whose addresses were cleared should be deleted")
+	}
+}
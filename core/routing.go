@@ -0,0 +1,23 @@
+package core
+
+import (
+	stack "github.com/ipfs/go-ipfs/routing/stack"
+
+	dht "gx/ipfs/QmRmroYSdievxnjiuy99C8BzShNstdEWcEF3LQHF7fUbez/go-libp2p-kad-dht"
+)
+
+// DHT returns the DHT of the node, its routing system or one of the
+// routers of its router stack, or nil if it has none.
+func (n *IpfsNode) DHT() *dht.IpfsDHT {
+	switch r := n.Routing.(type) {
+	case *dht.IpfsDHT:
+		return r
+	case *stack.Stack:
+		for _, sr := range r.Routers() {
+			if d, ok := sr.IpfsRouting.(*dht.IpfsDHT); ok {
+				return d
+			}
+		}
+	}
+	return nil
+}
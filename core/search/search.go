@@ -0,0 +1,280 @@
+// Package search keeps a local index of the files imported into the node,
+// when Experimental.LocalSearchIndex is enabled, and searches it.
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	core "github.com/ipfs/go-ipfs/core"
+	coreunix "github.com/ipfs/go-ipfs/core/coreunix"
+	mfs "github.com/ipfs/go-ipfs/mfs"
+
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+	dsq "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore/query"
+	logging "gx/ipfs/QmSpJByNKFX1sCsHBEp3R73FL4NF6FnQTEGyNAXHm2GS52/go-log"
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+)
+
+var log = logging.Logger("search")
+
+// The entries are kept under their path, so that the ones under a path are
+// found with a prefix query, and every word of their names keys them again
+// under the terms, so that the ones named with a word are too.
+var (
+	pathsPrefix = ds.NewKey("/local/search/paths")
+	termsPrefix = ds.NewKey("/local/search/terms")
+)
+
+func init() {
+	err := coreunix.RegisterIndexer(coreunix.Indexer{Name: "search", Index: index})
+	if err != nil {
+		panic(err)
+	}
+}
+
+// Entry is an indexed file. Path is /ipfs/<root>/<path> for the files added,
+// root being the top of the tree they were added in, and /mfs/<path> for the
+// files written to MFS. Name is the path the file was imported with.
+type Entry struct {
+	Path     string
+	Name     string
+	Cid      string
+	Size     uint64
+	MimeType string
+	Indexed  time.Time
+}
+
+// Query selects entries: those under Path, if set, whose name has words
+// starting with every term, ignoring case, and whose mime type starts with
+// MimeType.
+type Query struct {
+	Terms    []string
+	MimeType string
+	Path     string
+	// Limit bounds the number of results, if positive.
+	Limit int
+}
+
+func (q *Query) matches(e *Entry) bool {
+	if !under(e.Path, q.Path) || !strings.HasPrefix(e.MimeType, q.MimeType) {
+		return false
+	}
+	words := tokens(e.Name)
+	for _, t := range q.Terms {
+		for _, tt := range tokens(t) {
+			if !hasWordPrefix(words, tt) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// under tells whether p is dir or is under it.
+func under(p, dir string) bool {
+	dir = strings.TrimRight(dir, "/")
+	return dir == "" || p == dir || strings.HasPrefix(p, dir+"/")
+}
+
+// tokens returns the lowercased words of s.
+func tokens(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func hasWordPrefix(words []string, prefix string) bool {
+	for _, w := range words {
+		if strings.HasPrefix(w, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func index(ctx context.Context, f *coreunix.ImportedFile) error {
+	if f.Repo == nil {
+		return nil
+	}
+	cfg, err := f.Repo.Config()
+	if err != nil {
+		return err
+	}
+	if !cfg.Experimental.LocalSearchIndex {
+		return nil
+	}
+
+	return put(f.Repo.Datastore(), &Entry{
+		Path:     f.Path,
+		Name:     f.Name,
+		Cid:      f.Cid.String(),
+		Size:     f.Size,
+		MimeType: f.MimeType,
+		Indexed:  time.Now(),
+	})
+}
+
+func pathKey(p string) ds.Key {
+	return ds.NewKey(pathsPrefix.String() + p)
+}
+
+func termKey(term, p string) ds.Key {
+	return ds.NewKey(termsPrefix.String() + "/" + term + p)
+}
+
+// put indexes e, replacing the entry at its path.
+func put(d ds.Datastore, e *Entry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if err := d.Put(pathKey(e.Path), b); err != nil {
+		return err
+	}
+	for _, t := range tokens(e.Name) {
+		if err := d.Put(termKey(t, e.Path), []byte{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func get(d ds.Datastore, p string) (*Entry, error) {
+	v, err := d.Get(pathKey(p))
+	if err != nil {
+		return nil, err
+	}
+	b, ok := v.([]byte)
+	if !ok {
+		return nil, ds.ErrInvalidType
+	}
+	var e Entry
+	if err := json.Unmarshal(b, &e); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// Search returns the entries of the index of n matching q, leaving out the
+// ones of files no longer pinned or in MFS.
+//
+// Without terms, the entries under q.Path are read. With terms, the entries
+// keyed by the longest one are, so that only the entries named with it are.
+func Search(n *core.IpfsNode, q Query) ([]Entry, error) {
+	d := n.Repo.Datastore()
+
+	var prefix string
+	var longest string
+	for _, t := range q.Terms {
+		for _, tt := range tokens(t) {
+			if len(tt) > len(longest) {
+				longest = tt
+			}
+		}
+	}
+	if longest != "" {
+		prefix = termsPrefix.String() + "/" + longest
+	} else {
+		prefix = pathsPrefix.String() + strings.TrimRight(q.Path, "/")
+	}
+
+	res, err := d.Query(dsq.Query{Prefix: prefix, KeysOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	seen := make(map[string]bool)
+	var out []Entry
+	for r := range res.Next() {
+		if r.Error != nil {
+			return nil, r.Error
+		}
+
+		var p string
+		if longest != "" {
+			// the term is a word, the path follows it
+			rest := strings.TrimPrefix(r.Key, termsPrefix.String()+"/")
+			i := strings.Index(rest, "/")
+			if i < 0 {
+				continue
+			}
+			p = rest[i:]
+		} else {
+			p = strings.TrimPrefix(r.Key, pathsPrefix.String())
+		}
+		if seen[p] || !under(p, q.Path) {
+			continue
+		}
+		seen[p] = true
+
+		e, err := get(d, p)
+		if err == ds.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			log.Warningf("invalid search index entry %s: %s", p, err)
+			continue
+		}
+		if !q.matches(e) || !present(n, e) {
+			continue
+		}
+
+		out = append(out, *e)
+		if q.Limit > 0 && len(out) >= q.Limit {
+			break
+		}
+	}
+
+	sort.Sort(entriesByPath(out))
+	return out, nil
+}
+
+type entriesByPath []Entry
+
+func (s entriesByPath) Len() int           { return len(s) }
+func (s entriesByPath) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s entriesByPath) Less(i, j int) bool { return s[i].Path < s[j].Path }
+
+// present tells whether the file of e is still kept: in MFS at its path, or
+// pinned through the root of the tree it was added in.
+func present(n *core.IpfsNode, e *Entry) bool {
+	c, err := cid.Decode(e.Cid)
+	if err != nil {
+		return false
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(e.Path, "/"), "/", 3)
+	if len(parts) < 2 {
+		return false
+	}
+	switch parts[0] {
+	case "mfs":
+		if n.FilesRoot == nil {
+			return false
+		}
+		fsn, err := mfs.Lookup(n.FilesRoot, strings.TrimPrefix(e.Path, "/mfs"))
+		if err != nil {
+			return false
+		}
+		nd, err := fsn.GetNode()
+		if err != nil {
+			return false
+		}
+		return nd.Cid().Equals(c)
+	case "ipfs":
+		root, err := cid.Decode(parts[1])
+		if err != nil {
+			return false
+		}
+		_, pinned, err := n.Pinning.IsPinned(root)
+		return err == nil && pinned
+	default:
+		return false
+	}
+}
@@ -5,8 +5,10 @@ package bitswap
 import (
 	"context"
 	"errors"
+	"fmt"
 	"math"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	blocks "github.com/ipfs/go-ipfs/blocks"
@@ -19,6 +21,7 @@ import (
 	flags "github.com/ipfs/go-ipfs/flags"
 	"github.com/ipfs/go-ipfs/thirdparty/delay"
 
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
 	metrics "gx/ipfs/QmRg1gKTHzc3CZXSKzem8aR4E3TubFhbgXwfVuWnSK5CC5/go-metrics-interface"
 	process "gx/ipfs/QmSF8fPo3jgVBAy8fpdjjYqgG87dkJgUprRBHRd2tmfgpP/goprocess"
 	procctx "gx/ipfs/QmSF8fPo3jgVBAy8fpdjjYqgG87dkJgUprRBHRd2tmfgpP/goprocess/context"
@@ -60,6 +63,17 @@ func init() {
 	}
 }
 
+// SetBufferSizes sizes the queues of new blocks and of blocks to provide of
+// the instances created afterwards. Sizes of zero are left unchanged.
+func SetBufferSizes(hasBlock, provideKeys int) {
+	if hasBlock > 0 {
+		HasBlockBufferSize = hasBlock
+	}
+	if provideKeys > 0 {
+		provideKeysBufferSize = provideKeys
+	}
+}
+
 var rebroadcastDelay = delay.Fixed(time.Minute)
 
 // New initializes a BitSwap instance that communicates over the provided
@@ -68,6 +82,14 @@ var rebroadcastDelay = delay.Fixed(time.Minute)
 // Runs until context is cancelled.
 func New(parent context.Context, p peer.ID, network bsnet.BitSwapNetwork,
 	bstore blockstore.Blockstore, nice bool) exchange.Interface {
+	return NewWithProvideQueue(parent, p, network, bstore, nice, nil)
+}
+
+// NewWithProvideQueue is like New, but records the blocks awaiting their
+// provide announcement in d, and announces the ones left over from a
+// previous run. A nil d keeps the queue in memory only.
+func NewWithProvideQueue(parent context.Context, p peer.ID, network bsnet.BitSwapNetwork,
+	bstore blockstore.Blockstore, nice bool, d ds.Datastore) exchange.Interface {
 
 	// important to use provided parent context (since it may include important
 	// loggable data). It's probably not a good idea to allow bitswap to be
@@ -98,7 +120,9 @@ func New(parent context.Context, p peer.ID, network bsnet.BitSwapNetwork,
 		process:       px,
 		newBlocks:     make(chan *cid.Cid, HasBlockBufferSize),
 		provideKeys:   make(chan *cid.Cid, provideKeysBufferSize),
+		provides:      newProvideQueue(d),
 		wm:            NewWantManager(ctx, network),
+		wantStats:     newWantStats(),
 
 		dupMetric: dupHist,
 		allMetric: allHist,
@@ -109,6 +133,24 @@ func New(parent context.Context, p peer.ID, network bsnet.BitSwapNetwork,
 	// Start up bitswaps async worker routines
 	bs.startWorkers(px, ctx)
 
+	// announce what a previous run could not
+	restored, err := bs.provides.restore()
+	if err != nil {
+		log.Warningf("failed to restore the provide queue: %s", err)
+	}
+	if len(restored) > 0 {
+		log.Infof("restoring %d pending provides", len(restored))
+		px.Go(func(px process.Process) {
+			for _, c := range restored {
+				select {
+				case bs.newBlocks <- c:
+				case <-px.Closing():
+					return
+				}
+			}
+		})
+	}
+
 	// bind the context and process.
 	// do it over here to avoid closing before all setup is done.
 	go func() {
@@ -148,6 +190,19 @@ type Bitswap struct {
 	newBlocks chan *cid.Cid
 	// provideKeys directly feeds provide workers
 	provideKeys chan *cid.Cid
+	// provides records the keys not yet announced
+	provides *provideQueue
+	// onProvided holds the func(*cid.Cid) called with each key announced
+	onProvided atomic.Value
+	// providerHints holds the func(*cid.Cid) []peer.ID returning the
+	// peers known to provide a key
+	providerHints atomic.Value
+	// verifyReceived holds the func(*cid.Cid) error checking the blocks
+	// received, if they are verified
+	verifyReceived atomic.Value
+
+	// wantStats tracks the blocks wanted, by whom, for WantStats
+	wantStats *wantStats
 
 	process process.Process
 
@@ -159,6 +214,8 @@ type Bitswap struct {
 	blocksSent     int
 	dataSent       uint64
 	dataRecvd      uint64
+	// verifyFailures counts the blocks refused by the verification, by peer
+	verifyFailures map[peer.ID]int
 
 	// Metrics interface metrics
 	dupMetric metrics.Histogram
@@ -168,6 +225,31 @@ type Bitswap struct {
 type blockRequest struct {
 	Cid *cid.Cid
 	Ctx context.Context
+	// Rebroadcast is set for the keys still wanted long after the first
+	// search for their providers.
+	Rebroadcast bool
+}
+
+// OnProvided sets f to be called with each key once it is announced to the
+// network.
+func (bs *Bitswap) OnProvided(f func(*cid.Cid)) {
+	bs.onProvided.Store(f)
+}
+
+// SetProviderHints sets f to return the peers known to provide a key, whose
+// addresses are in the peerstore. Bitswap connects to them instead of
+// searching the routing system for providers, which it only does if none of
+// them could be reached, or when the key is still wanted at the next
+// rebroadcast.
+func (bs *Bitswap) SetProviderHints(f func(*cid.Cid) []peer.ID) {
+	bs.providerHints.Store(f)
+}
+
+// VerifyReceived makes bitswap verify the blocks received before storing
+// them: the blocks which were not wanted, whose data does not hash to their
+// CID, or which check refuses, are dropped, and counted by peer in Stat.
+func (bs *Bitswap) VerifyReceived(check func(*cid.Cid) error) {
+	bs.verifyReceived.Store(check)
 }
 
 // GetBlock attempts to retrieve a particular block from peers within the
@@ -251,7 +333,14 @@ func (bs *Bitswap) GetBlocks(ctx context.Context, keys []*cid.Cid) (<-chan block
 		log.Event(ctx, "Bitswap.GetBlockRequest.Start", k)
 	}
 
-	bs.wm.WantBlocks(ctx, keys)
+	requester := requesterFromContext(ctx)
+	bs.wantStats.add(requester, keys)
+
+	if fp := peersFromContext(ctx); fp != nil && fp.only {
+		bs.wm.WantBlocksFrom(ctx, keys, fp.peers)
+	} else {
+		bs.wm.WantBlocks(ctx, keys)
+	}
 
 	// NB: Optimization. Assumes that providers of key[0] are likely to
 	// be able to provide for all keys. This currently holds true in most
@@ -274,6 +363,7 @@ func (bs *Bitswap) GetBlocks(ctx context.Context, keys []*cid.Cid) (<-chan block
 		defer func() {
 			// can't just defer this call on its own, arguments are resolved *when* the defer is created
 			bs.CancelWants(remaining.Keys())
+			bs.wantStats.done(requester, remaining.Keys())
 		}()
 		for {
 			select {
@@ -283,6 +373,7 @@ func (bs *Bitswap) GetBlocks(ctx context.Context, keys []*cid.Cid) (<-chan block
 				}
 
 				remaining.Remove(blk.Cid())
+				bs.wantStats.done(requester, []*cid.Cid{blk.Cid()})
 				select {
 				case out <- blk:
 				case <-ctx.Done():
@@ -331,6 +422,8 @@ func (bs *Bitswap) HasBlock(blk blocks.Block) error {
 
 	bs.engine.AddBlock(blk)
 
+	bs.provides.add(blk.Cid())
+
 	select {
 	case bs.newBlocks <- blk.Cid():
 		// send block off to be reprovided
@@ -355,21 +448,33 @@ func (bs *Bitswap) ReceiveMessage(ctx context.Context, p peer.ID, incoming bsmsg
 
 	// quickly send out cancels, reduces chances of duplicate block receives
 	var keys []*cid.Cid
+	wanted := cid.NewSet()
 	for _, block := range iblocks {
 		if _, found := bs.wm.wl.Contains(block.Cid()); !found {
 			log.Infof("received un-asked-for %s from %s", block, p)
 			continue
 		}
 		keys = append(keys, block.Cid())
+		wanted.Add(block.Cid())
 	}
 	bs.wm.CancelWants(keys)
 
+	check, _ := bs.verifyReceived.Load().(func(*cid.Cid) error)
+
 	wg := sync.WaitGroup{}
 	for _, block := range iblocks {
 		wg.Add(1)
 		go func(b blocks.Block) {
 			defer wg.Done()
 
+			if check != nil {
+				if err := verifyBlock(b, wanted, check); err != nil {
+					log.Warningf("refused %s from %s: %s", b.Cid(), p, err)
+					bs.countVerifyFailure(p)
+					return
+				}
+			}
+
 			bs.updateReceiveCounters(b)
 
 			k := b.Cid()
@@ -386,6 +491,31 @@ func (bs *Bitswap) ReceiveMessage(ctx context.Context, p peer.ID, incoming bsmsg
 
 var ErrAlreadyHaveBlock = errors.New("already have block")
 
+// verifyBlock checks that b was wanted, that its data hashes to its CID, and
+// that check accepts it.
+func verifyBlock(b blocks.Block, wanted *cid.Set, check func(*cid.Cid) error) error {
+	if !wanted.Has(b.Cid()) {
+		return errors.New("the block was not wanted")
+	}
+	c, err := b.Cid().Prefix().Sum(b.RawData())
+	if err != nil {
+		return err
+	}
+	if !c.Equals(b.Cid()) {
+		return fmt.Errorf("the data hashes to %s", c)
+	}
+	return check(b.Cid())
+}
+
+func (bs *Bitswap) countVerifyFailure(p peer.ID) {
+	bs.counterLk.Lock()
+	defer bs.counterLk.Unlock()
+	if bs.verifyFailures == nil {
+		bs.verifyFailures = make(map[peer.ID]int)
+	}
+	bs.verifyFailures[p]++
+}
+
 func (bs *Bitswap) updateReceiveCounters(b blocks.Block) {
 	blkLen := len(b.RawData())
 	has, err := bs.blockstore.Has(b.Cid())
@@ -443,3 +573,10 @@ func (bs *Bitswap) GetWantlist() []*cid.Cid {
 func (bs *Bitswap) IsOnline() bool {
 	return true
 }
+
+// CheckHealth returns an error if the event loop of bitswap doesn't answer
+// before ctx is done.
+func (bs *Bitswap) CheckHealth(ctx context.Context) error {
+	_, err := bs.wm.connectedPeersCtx(ctx)
+	return err
+}
@@ -21,6 +21,7 @@ import (
 
 	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
 	p2ptestutil "gx/ipfs/Qma2j8dYePrvN5DoNgwh1uAuu3FFtEtrUQFmr737ws8nCp/go-libp2p-netutil"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
 )
 
 // FIXME the tests are really sensitive to the network delay. fix them to work
@@ -359,6 +360,81 @@ func TestBasicBitswap(t *testing.T) {
 	}
 }
 
+func TestGetBlockFromPeers(t *testing.T) {
+	net := tn.VirtualNetwork(mockrouting.NewServer(), delay.Fixed(kNetworkDelay))
+	sg := NewTestSessionGenerator(net)
+	defer sg.Close()
+	bg := blocksutil.NewBlockGenerator()
+
+	instances := sg.Instances(3)
+	blocks := bg.Blocks(1)
+	if err := instances[1].Exchange.HasBlock(blocks[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	// the peer without the block is the only one asked
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
+	defer cancel()
+	fctx := WithPeers(ctx, []peer.ID{instances[2].Peer}, true)
+	if _, err := instances[0].Exchange.GetBlock(fctx, blocks[0].Cid()); err != context.DeadlineExceeded {
+		t.Fatalf("expected the block not to be fetched, got %v", err)
+	}
+	if wl := instances[1].Exchange.WantlistForPeer(instances[0].Peer); len(wl) != 0 {
+		t.Fatalf("expected no wants to be sent to the other peers, got %s", wl)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	fctx = WithPeers(ctx, []peer.ID{instances[1].Peer}, true)
+	blk, err := instances[0].Exchange.GetBlock(fctx, blocks[0].Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !blk.Cid().Equals(blocks[0].Cid()) {
+		t.Fatal("fetched the wrong block")
+	}
+}
+
+func TestWantStats(t *testing.T) {
+	net := tn.VirtualNetwork(mockrouting.NewServer(), delay.Fixed(kNetworkDelay))
+	sg := NewTestSessionGenerator(net)
+	defer sg.Close()
+	bg := blocksutil.NewBlockGenerator()
+
+	instances := sg.Instances(2)
+	blocks := bg.Blocks(2)
+	if err := instances[1].Exchange.HasBlock(blocks[1]); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	rctx := WithRequester(ctx, "test")
+	if _, err := instances[0].Exchange.GetBlocks(rctx, []*cid.Cid{blocks[0].Cid()}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := instances[0].Exchange.GetBlock(rctx, blocks[1].Cid()); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(time.Millisecond * 50)
+	st := instances[0].Exchange.WantStats(0)
+	if len(st) != 1 || !st[0].Cid.Equals(blocks[0].Cid()) {
+		t.Fatalf("expected only the missing block to be wanted, got %v", st)
+	}
+	if len(st[0].Requesters) != 1 || st[0].Requesters[0] != "test" {
+		t.Fatalf("expected the block to be wanted by the test, got %v", st[0].Requesters)
+	}
+	if st := instances[0].Exchange.WantStats(time.Hour); len(st) != 0 {
+		t.Fatalf("expected no block wanted for an hour, got %v", st)
+	}
+
+	cancel()
+	time.Sleep(time.Millisecond * 50)
+	if st := instances[0].Exchange.WantStats(0); len(st) != 0 {
+		t.Fatalf("expected the wants to be dropped with the request, got %v", st)
+	}
+}
+
 func TestDoubleGet(t *testing.T) {
 	net := tn.VirtualNetwork(mockrouting.NewServer(), delay.Fixed(kNetworkDelay))
 	sg := NewTestSessionGenerator(net)
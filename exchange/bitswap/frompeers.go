@@ -0,0 +1,29 @@
+package bitswap
+
+import (
+	"context"
+
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+type fromPeersKey struct{}
+
+// fromPeers are the peers the blocks of a request are fetched from.
+type fromPeers struct {
+	peers []peer.ID
+	only  bool
+}
+
+// WithPeers returns a context under which bitswap fetches the blocks from
+// peers, connecting to them. If only is true, the wants are sent to them
+// alone, and the routing system is not searched for other providers;
+// otherwise they are tried first, as the providers hinted by
+// SetProviderHints.
+func WithPeers(ctx context.Context, peers []peer.ID, only bool) context.Context {
+	return context.WithValue(ctx, fromPeersKey{}, &fromPeers{peers: peers, only: only})
+}
+
+func peersFromContext(ctx context.Context) *fromPeers {
+	fp, _ := ctx.Value(fromPeersKey{}).(*fromPeers)
+	return fp
+}
@@ -0,0 +1,157 @@
+package bitswap
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+	dsq "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore/query"
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+)
+
+// ProvideQueuePrefix is the datastore namespace of the keys which have not
+// been announced yet.
+var ProvideQueuePrefix = ds.NewKey("/local/provides")
+
+// A failed provide is retried after provideRetryDelay, doubled on each
+// further failure, up to provideRetries times.
+var (
+	provideRetryDelay = 30 * time.Second
+	provideRetries    = 5
+)
+
+// provideQueue records the keys waiting for a provide announcement in a
+// datastore, so that they can be announced after a restart.
+type provideQueue struct {
+	d ds.Datastore
+
+	lk      sync.Mutex
+	pending map[string]struct{}
+	// failures counts the failed provides of the keys being retried
+	failures map[string]int
+}
+
+func newProvideQueue(d ds.Datastore) *provideQueue {
+	return &provideQueue{
+		d:        d,
+		pending:  make(map[string]struct{}),
+		failures: make(map[string]int),
+	}
+}
+
+func provideQueueKey(c *cid.Cid) ds.Key {
+	return ProvideQueuePrefix.ChildString(c.String())
+}
+
+// restore returns the keys left over from a previous run.
+func (q *provideQueue) restore() ([]*cid.Cid, error) {
+	if q.d == nil {
+		return nil, nil
+	}
+
+	res, err := q.d.Query(dsq.Query{
+		Prefix:   ProvideQueuePrefix.String(),
+		KeysOnly: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := res.Rest()
+	if err != nil {
+		return nil, err
+	}
+
+	q.lk.Lock()
+	defer q.lk.Unlock()
+
+	var out []*cid.Cid
+	for _, e := range entries {
+		k := ds.RawKey(e.Key)
+		c, err := cid.Decode(k.BaseNamespace())
+		if err != nil {
+			log.Warningf("dropping invalid provide queue entry %s: %s", k, err)
+			q.d.Delete(k)
+			continue
+		}
+		q.pending[c.KeyString()] = struct{}{}
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+// add records that c awaits its announcement.
+func (q *provideQueue) add(c *cid.Cid) {
+	if q.d == nil {
+		return
+	}
+
+	q.lk.Lock()
+	defer q.lk.Unlock()
+
+	if _, ok := q.pending[c.KeyString()]; ok {
+		return
+	}
+	if err := q.d.Put(provideQueueKey(c), []byte{}); err != nil {
+		log.Warningf("failed to persist provide of %s: %s", c, err)
+	}
+	q.pending[c.KeyString()] = struct{}{}
+}
+
+// done records that c was announced, or that its announcement was given up.
+func (q *provideQueue) done(c *cid.Cid) {
+	q.lk.Lock()
+	defer q.lk.Unlock()
+
+	delete(q.failures, c.KeyString())
+	if q.d == nil {
+		return
+	}
+	if _, ok := q.pending[c.KeyString()]; !ok {
+		return
+	}
+	if err := q.d.Delete(provideQueueKey(c)); err != nil && err != ds.ErrNotFound {
+		log.Warningf("failed to remove provide of %s from the queue: %s", c, err)
+	}
+	delete(q.pending, c.KeyString())
+}
+
+// failed records that the announcement of c failed, and returns how long to
+// wait before retrying it, or false once it has failed too many times.
+func (q *provideQueue) failed(c *cid.Cid) (time.Duration, bool) {
+	q.lk.Lock()
+	defer q.lk.Unlock()
+
+	n := q.failures[c.KeyString()]
+	if n >= provideRetries {
+		return 0, false
+	}
+	q.failures[c.KeyString()] = n + 1
+	return provideRetryDelay << uint(n), true
+}
+
+// Len returns the number of keys awaiting their announcement.
+func (q *provideQueue) Len() int {
+	q.lk.Lock()
+	defer q.lk.Unlock()
+	return len(q.pending)
+}
+
+// DrainProvides waits until all queued provide announcements have been sent,
+// or ctx is done. It is a no-op unless the queue is persisted.
+func (bs *Bitswap) DrainProvides(ctx context.Context) error {
+	ticker := time.NewTicker(time.Millisecond * 100)
+	defer ticker.Stop()
+
+	for bs.provides.Len() > 0 {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-bs.process.Closing():
+			return nil
+		}
+	}
+	return nil
+}
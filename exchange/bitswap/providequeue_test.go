@@ -0,0 +1,70 @@
+package bitswap
+
+import (
+	"testing"
+
+	blocksutil "github.com/ipfs/go-ipfs/blocks/blocksutil"
+
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+)
+
+func TestProvideQueueRestore(t *testing.T) {
+	d := ds.NewMapDatastore()
+	bg := blocksutil.NewBlockGenerator()
+	blks := bg.Blocks(3)
+
+	q := newProvideQueue(d)
+	for _, b := range blks {
+		q.add(b.Cid())
+	}
+	q.add(blks[0].Cid())
+	q.done(blks[1].Cid())
+
+	if q.Len() != 2 {
+		t.Fatalf("expected 2 pending provides, got %d", q.Len())
+	}
+
+	restored, err := newProvideQueue(d).restore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(restored) != 2 {
+		t.Fatalf("expected 2 restored provides, got %d", len(restored))
+	}
+	for _, c := range restored {
+		if c.Equals(blks[1].Cid()) {
+			t.Fatal("announced block was restored")
+		}
+	}
+}
+
+func TestProvideQueueRetry(t *testing.T) {
+	bg := blocksutil.NewBlockGenerator()
+	c := bg.Next().Cid()
+
+	q := newProvideQueue(ds.NewMapDatastore())
+	q.add(c)
+	for i := 0; i < provideRetries; i++ {
+		delay, ok := q.failed(c)
+		if !ok {
+			t.Fatalf("expected retry %d to be allowed", i+1)
+		}
+		if delay != provideRetryDelay<<uint(i) {
+			t.Fatalf("expected a delay of %s for retry %d, got %s", provideRetryDelay<<uint(i), i+1, delay)
+		}
+	}
+	if _, ok := q.failed(c); ok {
+		t.Fatal("expected the provide to be given up")
+	}
+	if q.Len() != 1 {
+		t.Fatal("expected the failed provide to stay pending until done")
+	}
+
+	q.done(c)
+	if q.Len() != 0 {
+		t.Fatal("expected done to drop the provide given up")
+	}
+	if _, ok := q.failed(c); !ok {
+		t.Fatal("expected done to reset the failures")
+	}
+}
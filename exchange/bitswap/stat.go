@@ -8,6 +8,7 @@ import (
 
 type Stat struct {
 	ProvideBufLen   int
+	ProvideQueueLen int
 	Wantlist        []*cid.Cid
 	Peers           []string
 	BlocksReceived  int
@@ -16,11 +17,15 @@ type Stat struct {
 	DataSent        uint64
 	DupBlksReceived int
 	DupDataReceived uint64
+	// VerifyFailures counts the blocks refused by the verification of the
+	// blocks received, by peer.
+	VerifyFailures map[string]int `json:",omitempty"`
 }
 
 func (bs *Bitswap) Stat() (*Stat, error) {
 	st := new(Stat)
 	st.ProvideBufLen = len(bs.newBlocks)
+	st.ProvideQueueLen = bs.provides.Len()
 	st.Wantlist = bs.GetWantlist()
 	bs.counterLk.Lock()
 	st.BlocksReceived = bs.blocksRecvd
@@ -29,6 +34,12 @@ func (bs *Bitswap) Stat() (*Stat, error) {
 	st.BlocksSent = bs.blocksSent
 	st.DataSent = bs.dataSent
 	st.DataReceived = bs.dataRecvd
+	if len(bs.verifyFailures) > 0 {
+		st.VerifyFailures = make(map[string]int, len(bs.verifyFailures))
+		for p, n := range bs.verifyFailures {
+			st.VerifyFailures[p.Pretty()] = n
+		}
+	}
 	bs.counterLk.Unlock()
 
 	for _, p := range bs.engine.Peers() {
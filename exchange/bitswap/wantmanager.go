@@ -17,7 +17,7 @@ import (
 
 type WantManager struct {
 	// sync channels for Run loop
-	incoming   chan []*bsmsg.Entry
+	incoming   chan *wantSet
 	connect    chan peer.ID        // notification channel for new peers connecting
 	disconnect chan peer.ID        // notification channel for peers disconnecting
 	peerReqs   chan chan []peer.ID // channel to request connected peers on
@@ -26,6 +26,11 @@ type WantManager struct {
 	peers map[peer.ID]*msgQueue
 	wl    *wantlist.ThreadSafe
 
+	// targets are the peers the wants sent to some peers only are sent to,
+	// by key
+	tlk     sync.Mutex
+	targets map[string]map[peer.ID]struct{}
+
 	network bsnet.BitSwapNetwork
 	ctx     context.Context
 	cancel  func()
@@ -41,12 +46,13 @@ func NewWantManager(ctx context.Context, network bsnet.BitSwapNetwork) *WantMana
 	sentHistogram := metrics.NewCtx(ctx, "sent_all_blocks_bytes", "Histogram of blocks sent by"+
 		" this bitswap").Histogram(metricsBuckets)
 	return &WantManager{
-		incoming:      make(chan []*bsmsg.Entry, 10),
+		incoming:      make(chan *wantSet, 10),
 		connect:       make(chan peer.ID, 10),
 		disconnect:    make(chan peer.ID, 10),
 		peerReqs:      make(chan chan []peer.ID),
 		peers:         make(map[peer.ID]*msgQueue),
 		wl:            wantlist.NewThreadSafe(),
+		targets:       make(map[string]map[peer.ID]struct{}),
 		network:       network,
 		ctx:           ctx,
 		cancel:        cancel,
@@ -70,17 +76,32 @@ type msgQueue struct {
 	done chan struct{}
 }
 
+// wantSet is a change of the wantlist, sent to targets, or to all the peers
+// if there are none.
+type wantSet struct {
+	entries []*bsmsg.Entry
+	targets []peer.ID
+}
+
 func (pm *WantManager) WantBlocks(ctx context.Context, ks []*cid.Cid) {
 	log.Infof("want blocks: %s", ks)
-	pm.addEntries(ctx, ks, false)
+	pm.addEntries(ctx, ks, nil, false)
+}
+
+// WantBlocksFrom wants the blocks from peers only: the wants are sent to
+// them, and not to the other peers, unless the blocks are also wanted from
+// all.
+func (pm *WantManager) WantBlocksFrom(ctx context.Context, ks []*cid.Cid, peers []peer.ID) {
+	log.Infof("want blocks from %s: %s", peers, ks)
+	pm.addEntries(ctx, ks, peers, false)
 }
 
 func (pm *WantManager) CancelWants(ks []*cid.Cid) {
 	log.Infof("cancel wants: %s", ks)
-	pm.addEntries(context.TODO(), ks, true)
+	pm.addEntries(context.TODO(), ks, nil, true)
 }
 
-func (pm *WantManager) addEntries(ctx context.Context, ks []*cid.Cid, cancel bool) {
+func (pm *WantManager) addEntries(ctx context.Context, ks []*cid.Cid, targets []peer.ID, cancel bool) {
 	var entries []*bsmsg.Entry
 	for i, k := range ks {
 		entries = append(entries, &bsmsg.Entry{
@@ -93,7 +114,7 @@ func (pm *WantManager) addEntries(ctx context.Context, ks []*cid.Cid, cancel boo
 		})
 	}
 	select {
-	case pm.incoming <- entries:
+	case pm.incoming <- &wantSet{entries: entries, targets: targets}:
 	case <-pm.ctx.Done():
 	case <-ctx.Done():
 	}
@@ -105,6 +126,24 @@ func (pm *WantManager) ConnectedPeers() []peer.ID {
 	return <-resp
 }
 
+// connectedPeersCtx is like ConnectedPeers, but gives up when ctx is done,
+// should the event loop be stuck.
+func (pm *WantManager) connectedPeersCtx(ctx context.Context) ([]peer.ID, error) {
+	// buffered, so that the loop doesn't block on an abandoned request
+	resp := make(chan []peer.ID, 1)
+	select {
+	case pm.peerReqs <- resp:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	select {
+	case peers := <-resp:
+		return peers, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
 func (pm *WantManager) SendBlock(ctx context.Context, env *engine.Envelope) {
 	// Blocks need to be sent synchronously to maintain proper backpressure
 	// throughout the network stack
@@ -133,7 +172,9 @@ func (pm *WantManager) startPeerHandler(p peer.ID) *msgQueue {
 	// new peer, we will want to give them our full wantlist
 	fullwantlist := bsmsg.New(true)
 	for _, e := range pm.wl.Entries() {
-		fullwantlist.AddEntry(e.Cid, e.Priority)
+		if pm.wantedFrom(e.Cid, p) {
+			fullwantlist.AddEntry(e.Cid, e.Priority)
+		}
 	}
 	mq.out = fullwantlist
 	mq.work <- struct{}{}
@@ -278,37 +319,53 @@ func (pm *WantManager) Run() {
 	defer tock.Stop()
 	for {
 		select {
-		case entries := <-pm.incoming:
+		case ws := <-pm.incoming:
 
 			// add changes to our wantlist
 			var filtered []*bsmsg.Entry
-			for _, e := range entries {
+			targeted := make(map[peer.ID][]*bsmsg.Entry)
+			for _, e := range ws.entries {
 				if e.Cancel {
 					if pm.wl.Remove(e.Cid) {
 						pm.wantlistGauge.Dec()
+						pm.untarget(e.Cid)
 						filtered = append(filtered, e)
 					}
-				} else {
-					if pm.wl.AddEntry(e.Entry) {
-						pm.wantlistGauge.Inc()
-						filtered = append(filtered, e)
+					continue
+				}
+
+				added := pm.wl.AddEntry(e.Entry)
+				if added {
+					pm.wantlistGauge.Inc()
+				}
+				switch {
+				case len(ws.targets) > 0 && (added || pm.targetedOnly(e.Cid)):
+					pm.target(e.Cid, ws.targets)
+					for _, p := range ws.targets {
+						targeted[p] = append(targeted[p], e)
 					}
+				case added || pm.untarget(e.Cid):
+					// now wanted from all the peers
+					filtered = append(filtered, e)
 				}
 			}
 
 			// broadcast those wantlist changes
-			for _, p := range pm.peers {
-				p.addMessage(filtered)
+			for id, p := range pm.peers {
+				p.addMessage(append(targeted[id], filtered...))
 			}
 
 		case <-tock.C:
 			// resend entire wantlist every so often (REALLY SHOULDNT BE NECESSARY)
-			var es []*bsmsg.Entry
-			for _, e := range pm.wl.Entries() {
-				es = append(es, &bsmsg.Entry{Entry: e})
-			}
+			entries := pm.wl.Entries()
+			for id, p := range pm.peers {
+				var es []*bsmsg.Entry
+				for _, e := range entries {
+					if pm.wantedFrom(e.Cid, id) {
+						es = append(es, &bsmsg.Entry{Entry: e})
+					}
+				}
 
-			for _, p := range pm.peers {
 				p.outlk.Lock()
 				p.out = bsmsg.New(true)
 				p.outlk.Unlock()
@@ -331,6 +388,51 @@ func (pm *WantManager) Run() {
 	}
 }
 
+// target records that the want of c is sent to peers, and not to the
+// other peers.
+func (pm *WantManager) target(c *cid.Cid, peers []peer.ID) {
+	pm.tlk.Lock()
+	defer pm.tlk.Unlock()
+	t, ok := pm.targets[c.KeyString()]
+	if !ok {
+		t = make(map[peer.ID]struct{})
+		pm.targets[c.KeyString()] = t
+	}
+	for _, p := range peers {
+		t[p] = struct{}{}
+	}
+}
+
+// untarget records that the want of c is sent to all the peers, and returns
+// whether it was sent to some peers only.
+func (pm *WantManager) untarget(c *cid.Cid) bool {
+	pm.tlk.Lock()
+	defer pm.tlk.Unlock()
+	_, ok := pm.targets[c.KeyString()]
+	delete(pm.targets, c.KeyString())
+	return ok
+}
+
+// targetedOnly returns whether c is only wanted from some peers.
+func (pm *WantManager) targetedOnly(c *cid.Cid) bool {
+	pm.tlk.Lock()
+	defer pm.tlk.Unlock()
+	_, ok := pm.targets[c.KeyString()]
+	return ok
+}
+
+// wantedFrom returns whether the want of c is sent to p.
+func (pm *WantManager) wantedFrom(c *cid.Cid, p peer.ID) bool {
+	pm.tlk.Lock()
+	defer pm.tlk.Unlock()
+	t, ok := pm.targets[c.KeyString()]
+	if !ok {
+		return true
+	}
+	_, ok = t[p]
+	return ok
+}
+
 func (wm *WantManager) newMsgQueue(p peer.ID) *msgQueue {
 	return &msgQueue{
 		done:    make(chan struct{}),
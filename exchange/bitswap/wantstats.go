@@ -0,0 +1,147 @@
+package bitswap
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+type requesterKey struct{}
+
+// WithRequester returns a context under which the blocks fetched are
+// reported by WantStats as wanted by name, such as the command or the
+// gateway request fetching them.
+func WithRequester(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, requesterKey{}, name)
+}
+
+func requesterFromContext(ctx context.Context) string {
+	name, _ := ctx.Value(requesterKey{}).(string)
+	if name == "" {
+		return "unknown"
+	}
+	return name
+}
+
+// WantStat describes a block wanted by the node.
+type WantStat struct {
+	Cid *cid.Cid
+	// Since is when the block was first wanted.
+	Since time.Time
+	// Requesters are the commands and requests waiting for the block.
+	Requesters []string
+	// Providers counts the providers of the block found, and Searches the
+	// searches of the routing system for them.
+	Providers int
+	Searches  int
+}
+
+type wantStat struct {
+	cid        *cid.Cid
+	since      time.Time
+	requesters map[string]int
+	providers  map[peer.ID]struct{}
+	searches   int
+}
+
+// wantStats tracks the blocks wanted by the requests, until they are
+// received or the requests give up on them.
+type wantStats struct {
+	lk    sync.Mutex
+	wants map[string]*wantStat
+}
+
+func newWantStats() *wantStats {
+	return &wantStats{wants: make(map[string]*wantStat)}
+}
+
+func (ws *wantStats) add(requester string, keys []*cid.Cid) {
+	now := time.Now()
+	ws.lk.Lock()
+	defer ws.lk.Unlock()
+	for _, k := range keys {
+		w, ok := ws.wants[k.KeyString()]
+		if !ok {
+			w = &wantStat{
+				cid:        k,
+				since:      now,
+				requesters: make(map[string]int),
+				providers:  make(map[peer.ID]struct{}),
+			}
+			ws.wants[k.KeyString()] = w
+		}
+		w.requesters[requester]++
+	}
+}
+
+func (ws *wantStats) done(requester string, keys []*cid.Cid) {
+	ws.lk.Lock()
+	defer ws.lk.Unlock()
+	for _, k := range keys {
+		w, ok := ws.wants[k.KeyString()]
+		if !ok {
+			continue
+		}
+		w.requesters[requester]--
+		if w.requesters[requester] <= 0 {
+			delete(w.requesters, requester)
+		}
+		if len(w.requesters) == 0 {
+			delete(ws.wants, k.KeyString())
+		}
+	}
+}
+
+func (ws *wantStats) searched(k *cid.Cid) {
+	ws.lk.Lock()
+	defer ws.lk.Unlock()
+	if w, ok := ws.wants[k.KeyString()]; ok {
+		w.searches++
+	}
+}
+
+func (ws *wantStats) foundProvider(k *cid.Cid, p peer.ID) {
+	ws.lk.Lock()
+	defer ws.lk.Unlock()
+	if w, ok := ws.wants[k.KeyString()]; ok {
+		w.providers[p] = struct{}{}
+	}
+}
+
+// WantStats returns the blocks wanted for longer than olderThan, oldest
+// first.
+func (bs *Bitswap) WantStats(olderThan time.Duration) []WantStat {
+	now := time.Now()
+	bs.wantStats.lk.Lock()
+	defer bs.wantStats.lk.Unlock()
+
+	var out []WantStat
+	for _, w := range bs.wantStats.wants {
+		if now.Sub(w.since) < olderThan {
+			continue
+		}
+		st := WantStat{
+			Cid:       w.cid,
+			Since:     w.since,
+			Providers: len(w.providers),
+			Searches:  w.searches,
+		}
+		for r := range w.requesters {
+			st.Requesters = append(st.Requesters, r)
+		}
+		sort.Strings(st.Requesters)
+		out = append(out, st)
+	}
+	sort.Sort(wantStatsBySince(out))
+	return out
+}
+
+type wantStatsBySince []WantStat
+
+func (s wantStatsBySince) Len() int           { return len(s) }
+func (s wantStatsBySince) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s wantStatsBySince) Less(i, j int) bool { return s[i].Since.Before(s[j].Since) }
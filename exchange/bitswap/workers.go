@@ -104,6 +104,18 @@ func (bs *Bitswap) provideWorker(px process.Process) {
 
 		if err := bs.network.Provide(ctx, k); err != nil {
 			log.Warning(err)
+			delay, ok := bs.provides.failed(k)
+			if !ok {
+				log.Warningf("giving up providing %s", k)
+				bs.provides.done(k)
+				return
+			}
+			go bs.retryProvide(px, k, delay)
+			return
+		}
+		bs.provides.done(k)
+		if f, ok := bs.onProvided.Load().(func(*cid.Cid)); ok {
+			f(k)
 		}
 	}
 
@@ -131,6 +143,21 @@ func (bs *Bitswap) provideWorker(px process.Process) {
 	}
 }
 
+// retryProvide queues k to be provided again after delay.
+func (bs *Bitswap) retryProvide(px process.Process, k *cid.Cid, delay time.Duration) {
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-px.Closing():
+		return
+	}
+	select {
+	case bs.newBlocks <- k:
+	case <-px.Closing():
+	}
+}
+
 func (bs *Bitswap) provideCollector(ctx context.Context) {
 	defer close(bs.provideKeys)
 	var toProvide []*cid.Cid
@@ -192,9 +219,14 @@ func (bs *Bitswap) rebroadcastWorker(parent context.Context) {
 			// TODO: come up with a better strategy for determining when to search
 			// for new providers for blocks.
 			i := rand.Intn(len(entries))
+			if bs.wm.targetedOnly(entries[i].Cid) {
+				// wanted from some peers, whatever the others provide
+				continue
+			}
 			bs.findKeys <- &blockRequest{
-				Cid: entries[i].Cid,
-				Ctx: ctx,
+				Cid:         entries[i].Cid,
+				Ctx:         ctx,
+				Rebroadcast: true,
 			}
 		case <-parent.Done():
 			return
@@ -226,9 +258,28 @@ func (bs *Bitswap) providerQueryManager(ctx context.Context) {
 			go func(e *blockRequest) {
 				child, cancel := context.WithTimeout(e.Ctx, providerRequestTimeout)
 				defer cancel()
+				defer func() {
+					activeLk.Lock()
+					kset.Remove(e.Cid)
+					activeLk.Unlock()
+				}()
+
+				if fp := peersFromContext(e.Ctx); fp != nil {
+					connected := bs.connectPeers(child, e.Cid, fp.peers, "requested")
+					if fp.only || (connected && !e.Rebroadcast) {
+						return
+					}
+				}
+
+				if bs.connectHinted(child, e.Cid) && !e.Rebroadcast {
+					return
+				}
+
+				bs.wantStats.searched(e.Cid)
 				providers := bs.network.FindProvidersAsync(child, e.Cid, maxProvidersPerRequest)
 				wg := &sync.WaitGroup{}
 				for p := range providers {
+					bs.wantStats.foundProvider(e.Cid, p)
 					wg.Add(1)
 					go func(p peer.ID) {
 						defer wg.Done()
@@ -239,9 +290,6 @@ func (bs *Bitswap) providerQueryManager(ctx context.Context) {
 					}(p)
 				}
 				wg.Wait()
-				activeLk.Lock()
-				kset.Remove(e.Cid)
-				activeLk.Unlock()
 			}(e)
 
 		case <-ctx.Done():
@@ -249,3 +297,39 @@ func (bs *Bitswap) providerQueryManager(ctx context.Context) {
 		}
 	}
 }
+
+// connectHinted connects to the peers hinted to provide k, and returns
+// true if it did to one of them at least.
+func (bs *Bitswap) connectHinted(ctx context.Context, k *cid.Cid) bool {
+	hints, ok := bs.providerHints.Load().(func(*cid.Cid) []peer.ID)
+	if !ok {
+		return false
+	}
+	return bs.connectPeers(ctx, k, hints(k), "hinted")
+}
+
+// connectPeers connects to the peers which may provide k, and returns
+// whether any of them could be reached.
+func (bs *Bitswap) connectPeers(ctx context.Context, k *cid.Cid, peers []peer.ID, why string) bool {
+	if len(peers) == 0 {
+		return false
+	}
+
+	connected := make(chan bool, len(peers))
+	for _, p := range peers {
+		go func(p peer.ID) {
+			err := bs.network.ConnectTo(ctx, p)
+			if err != nil {
+				log.Debugf("failed to connect to %s provider %s of %s: %s", why, p, k, err)
+			}
+			connected <- err == nil
+		}(p)
+	}
+	ok := false
+	for range peers {
+		if <-connected {
+			ok = true
+		}
+	}
+	return ok
+}
@@ -0,0 +1,240 @@
+package reprovide
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	routing "gx/ipfs/QmNdaQ8itUU9jEZUwTsG4gHMaPmRfi6FEe89QjQAFbep3M/go-libp2p-routing"
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+)
+
+// DefaultConcurrency is the number of announcements the Scheduler sends at
+// once by default.
+const DefaultConcurrency = 128
+
+// Lane is a priority lane of the announcements of a Scheduler.
+type Lane int
+
+const (
+	// LaneFresh announces the blocks just added or fetched. It is served
+	// first.
+	LaneFresh Lane = iota
+	// LaneReprovide announces the blocks again, periodically.
+	LaneReprovide
+
+	numLanes
+)
+
+func (l Lane) String() string {
+	switch l {
+	case LaneFresh:
+		return "fresh"
+	case LaneReprovide:
+		return "reprovide"
+	default:
+		return "unknown"
+	}
+}
+
+// LaneLimits limits the announcements of the lanes of a Scheduler.
+type LaneLimits struct {
+	// Concurrency is the number of announcements sent at once, in all
+	// lanes. DefaultConcurrency if 0.
+	Concurrency int
+	// Rates are the announcements sent per second in each lane, without
+	// limit if 0.
+	Rates [numLanes]int
+}
+
+// LaneStat is the state of a lane of a Scheduler.
+type LaneStat struct {
+	Lane     string
+	Rate     int // announcements per second, 0 if unlimited
+	Pending  int64
+	Active   int64
+	Provided uint64
+	Failed   uint64
+}
+
+// Scheduler sends the announcements of content to a routing system in
+// priority lanes: when it can send one, it sends the announcement of fresh
+// content waiting before any reprovide, so a large reprovide doesn't delay
+// the content a user just added. Each lane may also be rate limited.
+type Scheduler struct {
+	r       routing.ContentRouting
+	workers chan struct{}
+	lanes   [numLanes]*lane
+}
+
+type lane struct {
+	reqs chan *provideRequest
+	rate int
+
+	// counters, accessed atomically
+	pending  int64
+	active   int64
+	provided uint64
+	failed   uint64
+}
+
+type provideRequest struct {
+	ctx    context.Context
+	c      *cid.Cid
+	brdcst bool
+	done   chan error
+}
+
+// NewScheduler returns a Scheduler announcing through r, within limits. It
+// sends nothing until Run.
+func NewScheduler(r routing.ContentRouting, limits LaneLimits) *Scheduler {
+	if limits.Concurrency <= 0 {
+		limits.Concurrency = DefaultConcurrency
+	}
+
+	s := &Scheduler{
+		r:       r,
+		workers: make(chan struct{}, limits.Concurrency),
+	}
+	for i := range s.lanes {
+		s.lanes[i] = &lane{
+			reqs: make(chan *provideRequest),
+			rate: limits.Rates[i],
+		}
+	}
+	return s
+}
+
+// Lane returns a routing system which is r, but whose announcements go
+// through lane l of the Scheduler. Provide returns once the announcement is
+// sent.
+func (s *Scheduler) Lane(l Lane) routing.ContentRouting {
+	return &laneRouting{ContentRouting: s.r, s: s, l: l}
+}
+
+type laneRouting struct {
+	routing.ContentRouting
+	s *Scheduler
+	l Lane
+}
+
+func (lr *laneRouting) Provide(ctx context.Context, c *cid.Cid, brdcst bool) error {
+	return lr.s.provide(ctx, lr.l, c, brdcst)
+}
+
+func (s *Scheduler) provide(ctx context.Context, l Lane, c *cid.Cid, brdcst bool) error {
+	ln := s.lanes[l]
+	req := &provideRequest{ctx: ctx, c: c, brdcst: brdcst, done: make(chan error, 1)}
+
+	atomic.AddInt64(&ln.pending, 1)
+	select {
+	case ln.reqs <- req:
+	case <-ctx.Done():
+		atomic.AddInt64(&ln.pending, -1)
+		return ctx.Err()
+	}
+
+	select {
+	case err := <-req.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stat returns the state of the lanes.
+func (s *Scheduler) Stat() []LaneStat {
+	stats := make([]LaneStat, len(s.lanes))
+	for i, ln := range s.lanes {
+		stats[i] = LaneStat{
+			Lane:     Lane(i).String(),
+			Rate:     ln.rate,
+			Pending:  atomic.LoadInt64(&ln.pending),
+			Active:   atomic.LoadInt64(&ln.active),
+			Provided: atomic.LoadUint64(&ln.provided),
+			Failed:   atomic.LoadUint64(&ln.failed),
+		}
+	}
+	return stats
+}
+
+// Run sends the announcements until ctx is done.
+func (s *Scheduler) Run(ctx context.Context) {
+	// a rate limited lane is ready to send once a tick of its ticker since
+	// its last announcement
+	var ticks [numLanes]<-chan time.Time
+	var ready [numLanes]bool
+	for i, ln := range s.lanes {
+		ready[i] = true
+		if ln.rate > 0 {
+			t := time.NewTicker(time.Second / time.Duration(ln.rate))
+			defer t.Stop()
+			ticks[i] = t.C
+		}
+	}
+
+	for {
+		// wait for a worker first: the lane served is chosen when one is
+		// free
+		select {
+		case s.workers <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+
+		var reqs [numLanes]chan *provideRequest
+		for i, ln := range s.lanes {
+			if ready[i] {
+				reqs[i] = ln.reqs
+			}
+		}
+
+		var l Lane
+		var req *provideRequest
+		// fresh content first
+		select {
+		case req = <-reqs[LaneFresh]:
+			l = LaneFresh
+		default:
+		}
+		for req == nil {
+			select {
+			case req = <-reqs[LaneFresh]:
+				l = LaneFresh
+			case req = <-reqs[LaneReprovide]:
+				l = LaneReprovide
+			case <-ticks[LaneFresh]:
+				ready[LaneFresh] = true
+				reqs[LaneFresh] = s.lanes[LaneFresh].reqs
+			case <-ticks[LaneReprovide]:
+				ready[LaneReprovide] = true
+				reqs[LaneReprovide] = s.lanes[LaneReprovide].reqs
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		ln := s.lanes[l]
+		atomic.AddInt64(&ln.pending, -1)
+		if ln.rate > 0 {
+			ready[l] = false
+		}
+		go s.send(ln, req)
+	}
+}
+
+// send sends an announcement, and frees its worker.
+func (s *Scheduler) send(ln *lane, req *provideRequest) {
+	defer func() { <-s.workers }()
+
+	atomic.AddInt64(&ln.active, 1)
+	err := s.r.Provide(req.ctx, req.c, req.brdcst)
+	atomic.AddInt64(&ln.active, -1)
+
+	if err != nil {
+		atomic.AddUint64(&ln.failed, 1)
+	} else {
+		atomic.AddUint64(&ln.provided, 1)
+	}
+	req.done <- err
+}
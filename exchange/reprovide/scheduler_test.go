@@ -0,0 +1,115 @@
+package reprovide
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	blocks "github.com/ipfs/go-ipfs/blocks"
+
+	routing "gx/ipfs/QmNdaQ8itUU9jEZUwTsG4gHMaPmRfi6FEe89QjQAFbep3M/go-libp2p-routing"
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+)
+
+// orderRouting records the order of the announcements, each of which waits
+// for release.
+type orderRouting struct {
+	routing.ContentRouting
+
+	mu      sync.Mutex
+	order   []*cid.Cid
+	release chan struct{}
+}
+
+func (r *orderRouting) Provide(ctx context.Context, c *cid.Cid, brdcst bool) error {
+	r.mu.Lock()
+	r.order = append(r.order, c)
+	r.mu.Unlock()
+	<-r.release
+	return nil
+}
+
+func waitPending(t *testing.T, s *Scheduler, l Lane, n int64) {
+	for i := 0; i < 100; i++ {
+		if s.Stat()[l].Pending == n {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("expected %d announcements pending in lane %s", n, l)
+}
+
+func TestSchedulerFreshFirst(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r := &orderRouting{release: make(chan struct{})}
+	s := NewScheduler(r, LaneLimits{Concurrency: 1})
+	go s.Run(ctx)
+
+	a := blocks.NewBlock([]byte("a")).Cid()
+	b := blocks.NewBlock([]byte("b")).Cid()
+	c := blocks.NewBlock([]byte("c")).Cid()
+
+	var wg sync.WaitGroup
+	provide := func(l Lane, k *cid.Cid) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.Lane(l).Provide(ctx, k, true); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+
+	// a takes the only worker, b and c wait for it
+	provide(LaneReprovide, a)
+	for s.Stat()[LaneReprovide].Active != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	provide(LaneReprovide, b)
+	waitPending(t, s, LaneReprovide, 1)
+	provide(LaneFresh, c)
+	waitPending(t, s, LaneFresh, 1)
+
+	close(r.release)
+	wg.Wait()
+
+	expected := []*cid.Cid{a, c, b}
+	for i, k := range expected {
+		if !r.order[i].Equals(k) {
+			t.Fatalf("announcement %d: expected %s, got %s", i, k, r.order[i])
+		}
+	}
+
+	st := s.Stat()
+	if st[LaneFresh].Provided != 1 || st[LaneReprovide].Provided != 2 {
+		t.Fatalf("unexpected stats %v", st)
+	}
+}
+
+func TestSchedulerRate(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r := &orderRouting{release: make(chan struct{})}
+	close(r.release)
+
+	var limits LaneLimits
+	limits.Rates[LaneReprovide] = 20
+	s := NewScheduler(r, limits)
+	go s.Run(ctx)
+
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		k := blocks.NewBlock([]byte{byte(i)}).Cid()
+		if err := s.Lane(LaneReprovide).Provide(ctx, k, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the first is sent at once, the others a tick of 50ms apart
+	if d := time.Since(start); d < 100*time.Millisecond {
+		t.Fatalf("4 announcements at 20 per second took %s", d)
+	}
+}
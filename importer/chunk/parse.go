@@ -30,47 +30,85 @@ func FromString(r io.Reader, chunker string) (Splitter, error) {
 }
 
 func parseRabinString(r io.Reader, chunker string) (Splitter, error) {
+	min, avg, max, err := parseRabinSizes(chunker)
+	if err != nil {
+		return nil, err
+	}
+	return NewRabinMinMax(r, min, avg, max), nil
+}
+
+// parseRabinSizes returns the min, average and max block sizes of a rabin
+// chunker option.
+func parseRabinSizes(chunker string) (uint64, uint64, uint64, error) {
 	parts := strings.Split(chunker, "-")
 	switch len(parts) {
 	case 1:
-		return NewRabin(r, uint64(DefaultBlockSize)), nil
+		avg := uint64(DefaultBlockSize)
+		return avg / 3, avg, avg + (avg / 2), nil
 	case 2:
 		size, err := strconv.Atoi(parts[1])
 		if err != nil {
-			return nil, err
+			return 0, 0, 0, err
 		}
-		return NewRabin(r, uint64(size)), nil
+		avg := uint64(size)
+		return avg / 3, avg, avg + (avg / 2), nil
 	case 4:
 		sub := strings.Split(parts[1], ":")
 		if len(sub) > 1 && sub[0] != "min" {
-			return nil, errors.New("first label must be min")
+			return 0, 0, 0, errors.New("first label must be min")
 		}
 		min, err := strconv.Atoi(sub[len(sub)-1])
 		if err != nil {
-			return nil, err
+			return 0, 0, 0, err
 		}
 
 		sub = strings.Split(parts[2], ":")
 		if len(sub) > 1 && sub[0] != "avg" {
 			log.Error("sub == ", sub)
-			return nil, errors.New("second label must be avg")
+			return 0, 0, 0, errors.New("second label must be avg")
 		}
 		avg, err := strconv.Atoi(sub[len(sub)-1])
 		if err != nil {
-			return nil, err
+			return 0, 0, 0, err
 		}
 
 		sub = strings.Split(parts[3], ":")
 		if len(sub) > 1 && sub[0] != "max" {
-			return nil, errors.New("final label must be max")
+			return 0, 0, 0, errors.New("final label must be max")
 		}
 		max, err := strconv.Atoi(sub[len(sub)-1])
 		if err != nil {
-			return nil, err
+			return 0, 0, 0, err
+		}
+
+		return uint64(min), uint64(avg), uint64(max), nil
+	default:
+		return 0, 0, 0, errors.New("incorrect format (expected 'rabin' 'rabin-[avg]' or 'rabin-[min]-[avg]-[max]'")
+	}
+}
+
+// Canonical returns the explicit form of a chunker option, spelling out the
+// sizes implied by defaults, so that equal splitters have equal strings.
+func Canonical(chunker string) (string, error) {
+	switch {
+	case chunker == "" || chunker == "default":
+		return fmt.Sprintf("size-%d", DefaultBlockSize), nil
+
+	case strings.HasPrefix(chunker, "size-"):
+		size, err := strconv.Atoi(strings.TrimPrefix(chunker, "size-"))
+		if err != nil || size <= 0 {
+			return "", fmt.Errorf("invalid chunker size: %s", chunker)
+		}
+		return fmt.Sprintf("size-%d", size), nil
+
+	case strings.HasPrefix(chunker, "rabin"):
+		min, avg, max, err := parseRabinSizes(chunker)
+		if err != nil {
+			return "", err
 		}
+		return fmt.Sprintf("rabin-%d-%d-%d", min, avg, max), nil
 
-		return NewRabinMinMax(r, uint64(min), uint64(avg), uint64(max)), nil
 	default:
-		return nil, errors.New("incorrect format (expected 'rabin' 'rabin-[avg]' or 'rabin-[min]-[avg]-[max]'")
+		return "", fmt.Errorf("unrecognized chunker option: %s", chunker)
 	}
 }
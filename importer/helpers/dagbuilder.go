@@ -26,6 +26,9 @@ type DagBuilderHelper struct {
 	fullPath  string
 	stat      os.FileInfo
 	prefix    *cid.Prefix
+
+	// zeroLeaves are the leaves built for the chunks of zeros, by size
+	zeroLeaves map[int]*UnixfsNode
 }
 
 type DagBuilderParams struct {
@@ -57,6 +60,8 @@ func (dbp *DagBuilderParams) New(spl chunk.Splitter) *DagBuilderHelper {
 		prefix:    dbp.Prefix,
 		maxlinks:  dbp.Maxlinks,
 		batch:     dbp.Dagserv.Batch(),
+
+		zeroLeaves: make(map[int]*UnixfsNode),
 	}
 	if fi, ok := spl.Reader().(files.FileInfo); dbp.NoCopy && ok {
 		db.fullPath = fi.AbsPath()
@@ -164,6 +169,25 @@ func (db *DagBuilderHelper) GetNextDataNode() (*UnixfsNode, error) {
 		return nil, ErrSizeLimitExceeded
 	}
 
+	// the leaves of the filestore record where they are in the file, they
+	// can't be shared
+	if db.fullPath != "" || !isZero(data) {
+		return db.newLeaf(data)
+	}
+	if leaf, ok := db.zeroLeaves[len(data)]; ok {
+		return leaf, nil
+	}
+	leaf, err := db.newLeaf(data)
+	if err != nil {
+		return nil, err
+	}
+	leaf.zero = &zeroLeaf{links: make(map[string]*node.Link)}
+	db.zeroLeaves[len(data)] = leaf
+	return leaf, nil
+}
+
+// newLeaf returns a leaf holding data.
+func (db *DagBuilderHelper) newLeaf(data []byte) (*UnixfsNode, error) {
 	if db.rawLeaves {
 		if db.prefix == nil {
 			return &UnixfsNode{
@@ -187,6 +211,15 @@ func (db *DagBuilderHelper) GetNextDataNode() (*UnixfsNode, error) {
 	}
 }
 
+func isZero(data []byte) bool {
+	for _, b := range data {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
 func (db *DagBuilderHelper) SetPosInfo(node *UnixfsNode, offset uint64) {
 	if db.fullPath != "" {
 		node.SetPosInfo(offset, db.fullPath, db.stat)
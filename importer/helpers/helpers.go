@@ -45,6 +45,14 @@ type UnixfsNode struct {
 	node    *dag.ProtoNode
 	ufmt    *ft.FSNode
 	posInfo *pi.PosInfo
+	zero    *zeroLeaf
+}
+
+// zeroLeaf is a chunk of zeros shared by the runs of zeros of a file, such
+// as the holes of sparse files, whose nodes are built, hashed and stored
+// once.
+type zeroLeaf struct {
+	links map[string]*node.Link // by the kind of the nodes holding it
 }
 
 // NewUnixfsNodeFromDag reconstructs a Unixfs node from a given dag node
@@ -73,6 +81,7 @@ func (n *UnixfsNode) Set(other *UnixfsNode) {
 	n.node = other.node
 	n.raw = other.raw
 	n.rawnode = other.rawnode
+	n.zero = other.zero
 	if other.ufmt != nil {
 		n.ufmt.Data = other.ufmt.Data
 	}
@@ -98,6 +107,13 @@ func (n *UnixfsNode) GetChild(ctx context.Context, i int, ds dag.DAGService) (*U
 func (n *UnixfsNode) AddChild(child *UnixfsNode, db *DagBuilderHelper) error {
 	n.ufmt.AddBlockSize(child.FileSize())
 
+	if child.zero != nil {
+		if lnk, ok := child.zero.links[child.kind()]; ok {
+			// stored already, for another run of zeros
+			return n.node.AddRawLink("", lnk)
+		}
+	}
+
 	childnode, err := child.GetDagNode()
 	if err != nil {
 		return err
@@ -105,16 +121,29 @@ func (n *UnixfsNode) AddChild(child *UnixfsNode, db *DagBuilderHelper) error {
 
 	// Add a link to this node without storing a reference to the memory
 	// This way, we avoid nodes building up and consuming all of our RAM
-	err = n.node.AddNodeLinkClean("", childnode)
+	lnk, err := node.MakeLink(childnode)
 	if err != nil {
 		return err
 	}
+	n.node.AddRawLink("", lnk)
 
 	_, err = db.batch.Add(childnode)
+	if err == nil && child.zero != nil {
+		child.zero.links[child.kind()] = lnk
+	}
 
 	return err
 }
 
+// kind tells how the data of n is held, its leaves of zeros being shared by
+// the nodes of the same kind only.
+func (n *UnixfsNode) kind() string {
+	if n.raw {
+		return "raw"
+	}
+	return n.ufmt.Type.String()
+}
+
 // Removes the child node at the given index
 func (n *UnixfsNode) RemoveChild(index int, dbh *DagBuilderHelper) {
 	n.ufmt.RemoveBlockSize(index)
@@ -117,3 +117,39 @@ func runReadBench(b *testing.B, nd node.Node, ds dag.DAGService) {
 		cancel()
 	}
 }
+
+func TestSparseDag(t *testing.T) {
+	// runs of zeros between data, as in the images of disks
+	var buf []byte
+	rand := u.NewTimeSeededRand()
+	for i := 0; i < 6; i++ {
+		data := make([]byte, 700)
+		rand.Read(data)
+		buf = append(buf, data...)
+		buf = append(buf, make([]byte, 512*(10+i*30))...)
+	}
+
+	builders := map[string]func(dag.DAGService, chunk.Splitter) (node.Node, error){
+		"balanced": BuildDagFromReader,
+		"trickle":  BuildTrickleDagFromReader,
+	}
+	for name, build := range builders {
+		ds := mdtest.Mock()
+		nd, err := build(ds, chunk.NewSizeSplitter(bytes.NewReader(buf), 512))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		dr, err := uio.NewDagReader(context.Background(), nd, ds)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := ioutil.ReadAll(dr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(out, buf) {
+			t.Fatalf("%s: the file read back differs from the file added", name)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package keystore
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"strings"
+	"time"
+
+	ci "gx/ipfs/QmP1DfoUjiWH2ZBo1PBH6FupdBucbDepx3HpWmEY6JMUpY/go-libp2p-crypto"
+)
+
+// backupMagic starts the encrypted backups of keys, followed by the
+// Encryption of the backup on a line, and the sealed archive.
+var backupMagic = []byte("ipfs-key-backup/1\n")
+
+// backupKeyExt is the extension of the keys in the archives of backups.
+const backupKeyExt = ".key"
+
+// BackupKey is a key of a backup.
+type BackupKey struct {
+	Name string
+	Key  ci.PrivKey
+}
+
+// IsBackup returns whether b is an encrypted backup of keys.
+func IsBackup(b []byte) bool {
+	return bytes.HasPrefix(b, backupMagic)
+}
+
+// MarshalBackup returns the tar archive of keys, holding a file <name>.key
+// for each, in the libp2p format.
+func MarshalBackup(keys []BackupKey) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	w := tar.NewWriter(buf)
+	now := time.Now()
+	for _, k := range keys {
+		b, err := k.Key.Bytes()
+		if err != nil {
+			return nil, err
+		}
+		err = w.WriteHeader(&tar.Header{
+			Name:    k.Name + backupKeyExt,
+			Mode:    0600,
+			Size:    int64(len(b)),
+			ModTime: now,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(b); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBackup returns the keys of the tar archive of a backup.
+func UnmarshalBackup(archive []byte) ([]BackupKey, error) {
+	var keys []BackupKey
+	r := tar.NewReader(bytes.NewReader(archive))
+	for {
+		hdr, err := r.Next()
+		if err == io.EOF {
+			return keys, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		name := hdr.Name
+		if path.Base(name) != name || !strings.HasSuffix(name, backupKeyExt) {
+			return nil, fmt.Errorf("unexpected file %q in the backup", hdr.Name)
+		}
+		name = strings.TrimSuffix(name, backupKeyExt)
+		if err := validateName(name); err != nil {
+			return nil, err
+		}
+
+		b, err := ioutil.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		sk, err := ci.UnmarshalPrivateKey(b)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read the key %s of the backup: %s", name, err)
+		}
+		keys = append(keys, BackupKey{Name: name, Key: sk})
+	}
+}
+
+// EncryptBackup encrypts archive with a key derived from passphrase, as the
+// keys of an encrypted keystore are.
+func EncryptBackup(archive, passphrase []byte) ([]byte, error) {
+	e, err := NewEncryption(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := e.aead(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	hdr, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := seal(aead, archive)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, len(backupMagic)+len(hdr)+1+len(sealed))
+	out = append(out, backupMagic...)
+	out = append(out, hdr...)
+	out = append(out, '\n')
+	return append(out, sealed...), nil
+}
+
+// DecryptBackup returns the archive of the encrypted backup b.
+func DecryptBackup(b, passphrase []byte) ([]byte, error) {
+	if !IsBackup(b) {
+		return nil, errors.New("not a backup of keys")
+	}
+	b = b[len(backupMagic):]
+	i := bytes.IndexByte(b, '\n')
+	if i < 0 {
+		return nil, errors.New("invalid backup")
+	}
+
+	var e Encryption
+	if err := json.Unmarshal(b[:i], &e); err != nil {
+		return nil, fmt.Errorf("invalid backup: %s", err)
+	}
+	aead, err := e.aead(passphrase)
+	if err == ErrWrongPassphrase {
+		return nil, errors.New("wrong backup passphrase")
+	}
+	if err != nil {
+		return nil, err
+	}
+	archive, err := open(aead, b[i+1:])
+	if err != nil {
+		return nil, fmt.Errorf("cannot decrypt the backup: %s", err)
+	}
+	return archive, nil
+}
@@ -0,0 +1,63 @@
+package keystore
+
+import "testing"
+
+func TestBackupRoundTrip(t *testing.T) {
+	keys := []BackupKey{
+		{Name: "self", Key: privKeyOrFatal(t)},
+		{Name: "site", Key: privKeyOrFatal(t)},
+	}
+
+	archive, err := MarshalBackup(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := EncryptBackup(archive, []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsBackup(b) {
+		t.Fatal("expected the backup to be recognized")
+	}
+
+	if _, err := DecryptBackup(b, []byte("wrong")); err == nil {
+		t.Fatal("expected a wrong passphrase to be refused")
+	}
+
+	archive, err = DecryptBackup(b, []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored, err := UnmarshalBackup(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(restored) != len(keys) {
+		t.Fatalf("expected %d keys, got %d", len(keys), len(restored))
+	}
+	for i, k := range restored {
+		if k.Name != keys[i].Name || !k.Key.Equals(keys[i].Key) {
+			t.Fatalf("key %d was not restored: %s", i, k.Name)
+		}
+	}
+}
+
+func TestBackupRejectsPaths(t *testing.T) {
+	archive, err := MarshalBackup([]BackupKey{{Name: "../escape", Key: privKeyOrFatal(t)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := UnmarshalBackup(archive); err == nil {
+		t.Fatal("expected a key outside of the archive root to be refused")
+	}
+}
+
+func TestBackupBoundsScrypt(t *testing.T) {
+	// a header asking scrypt for 1 TiB of memory
+	b := append([]byte{}, backupMagic...)
+	b = append(b, `{"KDF":"scrypt","Salt":"AAAA","N":1073741824,"R":8,"P":1}`...)
+	b = append(b, '\n')
+	if _, err := DecryptBackup(b, []byte("secret")); err == nil {
+		t.Fatal("expected the scrypt parameters out of bounds to be refused")
+	}
+}
@@ -0,0 +1,185 @@
+package keystore
+
+import (
+	"bytes"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	ci "gx/ipfs/QmP1DfoUjiWH2ZBo1PBH6FupdBucbDepx3HpWmEY6JMUpY/go-libp2p-crypto"
+	pb "gx/ipfs/QmP1DfoUjiWH2ZBo1PBH6FupdBucbDepx3HpWmEY6JMUpY/go-libp2p-crypto/pb"
+	proto "gx/ipfs/QmZ4Qi3GaRbjcx28Sme5eMH7RQjGkt8wHxt2a65oLaeFEV/gogo-protobuf/proto"
+)
+
+// Encodings of exported keys
+const (
+	// FormatLibp2p is the protobuf encoding of libp2p, the one of the
+	// keystore files.
+	FormatLibp2p = "libp2p-protobuf-cleartext"
+	// FormatPEM is a PEM block of a PKCS #8 private key, as OpenSSL writes.
+	FormatPEM = "pem-pkcs8-cleartext"
+)
+
+const pemPrivateKey = "PRIVATE KEY"
+
+var (
+	oidRSA       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidEd25519   = asn1.ObjectIdentifier{1, 3, 101, 112}
+	oidEC        = asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1}
+	oidSecp256k1 = asn1.ObjectIdentifier{1, 3, 132, 0, 10}
+)
+
+// pkcs8 is a PKCS #8 private key (RFC 5208, RFC 8410 for ed25519).
+type pkcs8 struct {
+	Version    int
+	Algo       pkix.AlgorithmIdentifier
+	PrivateKey []byte
+}
+
+// ecPrivateKey is the private key of an elliptic curve key in PKCS #8 (RFC
+// 5915). The curve is given by the parameters of the algorithm, and only
+// repeated in it by some writers.
+type ecPrivateKey struct {
+	Version    int
+	PrivateKey []byte
+	Curve      asn1.ObjectIdentifier `asn1:"optional,explicit,tag:0"`
+	PublicKey  asn1.BitString        `asn1:"optional,explicit,tag:1"`
+}
+
+// MarshalKey encodes k in format.
+func MarshalKey(k ci.PrivKey, format string) ([]byte, error) {
+	switch format {
+	case FormatLibp2p:
+		return k.Bytes()
+	case FormatPEM:
+		der, err := marshalPKCS8(k)
+		if err != nil {
+			return nil, err
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: pemPrivateKey, Bytes: der}), nil
+	default:
+		return nil, fmt.Errorf("unknown key format %q, expected %q or %q", format, FormatLibp2p, FormatPEM)
+	}
+}
+
+// UnmarshalKey decodes a key encoded in format, or in the format it is
+// found to be in if format is empty. Encrypted OpenSSH keys are refused with
+// ErrPassphraseRequired, UnmarshalOpenSSHKey reads them.
+func UnmarshalKey(b []byte, format string) (ci.PrivKey, error) {
+	if format == "" {
+		format = FormatLibp2p
+		if IsOpenSSHKey(b) {
+			format = FormatOpenSSH
+		} else if bytes.HasPrefix(bytes.TrimSpace(b), []byte("-----BEGIN")) {
+			format = FormatPEM
+		}
+	}
+
+	switch format {
+	case FormatLibp2p:
+		return ci.UnmarshalPrivateKey(b)
+	case FormatPEM:
+		block, _ := pem.Decode(b)
+		if block == nil {
+			return nil, errors.New("no PEM block found")
+		}
+		if block.Type != pemPrivateKey {
+			return nil, fmt.Errorf("unsupported PEM block %q, expected %q", block.Type, pemPrivateKey)
+		}
+		return unmarshalPKCS8(block.Bytes)
+	case FormatOpenSSH:
+		return UnmarshalOpenSSHKey(b, nil)
+	default:
+		return nil, fmt.Errorf("unknown key format %q, expected %q, %q or %q", format, FormatLibp2p, FormatPEM, FormatOpenSSH)
+	}
+}
+
+func marshalPKCS8(k ci.PrivKey) ([]byte, error) {
+	b, err := k.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	var pk pb.PrivateKey
+	if err := proto.Unmarshal(b, &pk); err != nil {
+		return nil, err
+	}
+
+	var p pkcs8
+	switch pk.GetType() {
+	case pb.KeyType_RSA:
+		// the data of RSA keys is their PKCS #1 encoding, the parameters NULL
+		p.Algo = pkix.AlgorithmIdentifier{Algorithm: oidRSA, Parameters: asn1.RawValue{Tag: 5}}
+		p.PrivateKey = pk.GetData()
+	case pb.KeyType_Ed25519:
+		// the data of ed25519 keys starts with the seed
+		data := pk.GetData()
+		if len(data) < 32 {
+			return nil, errors.New("invalid ed25519 key")
+		}
+		p.Algo = pkix.AlgorithmIdentifier{Algorithm: oidEd25519}
+		p.PrivateKey, err = asn1.Marshal(data[:32])
+		if err != nil {
+			return nil, err
+		}
+	case pb.KeyType_Secp256k1:
+		// the data of secp256k1 keys is their 32 bytes scalar; the public
+		// key is left out, readers derive it
+		curve, err := asn1.Marshal(oidSecp256k1)
+		if err != nil {
+			return nil, err
+		}
+		p.Algo = pkix.AlgorithmIdentifier{Algorithm: oidEC, Parameters: asn1.RawValue{FullBytes: curve}}
+		p.PrivateKey, err = asn1.Marshal(ecPrivateKey{Version: 1, PrivateKey: pk.GetData()})
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("keys of type %s can't be encoded in PEM", pk.GetType())
+	}
+	return asn1.Marshal(p)
+}
+
+func unmarshalPKCS8(der []byte) (ci.PrivKey, error) {
+	var p pkcs8
+	if _, err := asn1.Unmarshal(der, &p); err != nil {
+		return nil, fmt.Errorf("invalid PKCS #8 key: %s", err)
+	}
+
+	switch {
+	case p.Algo.Algorithm.Equal(oidRSA):
+		if _, err := x509.ParsePKCS1PrivateKey(p.PrivateKey); err != nil {
+			return nil, fmt.Errorf("invalid RSA key: %s", err)
+		}
+		return ci.UnmarshalRsaPrivateKey(p.PrivateKey)
+	case p.Algo.Algorithm.Equal(oidEd25519):
+		var seed []byte
+		if _, err := asn1.Unmarshal(p.PrivateKey, &seed); err != nil || len(seed) != 32 {
+			return nil, errors.New("invalid ed25519 key")
+		}
+		// generating a key from its seed gives it back
+		sk, _, err := ci.GenerateEd25519Key(bytes.NewReader(seed))
+		return sk, err
+	case p.Algo.Algorithm.Equal(oidEC):
+		var curve asn1.ObjectIdentifier
+		if _, err := asn1.Unmarshal(p.Algo.Parameters.FullBytes, &curve); err != nil || !curve.Equal(oidSecp256k1) {
+			return nil, errors.New("unsupported elliptic curve, only secp256k1 keys are")
+		}
+		var ec ecPrivateKey
+		if _, err := asn1.Unmarshal(p.PrivateKey, &ec); err != nil || ec.Version != 1 || len(ec.PrivateKey) > 32 {
+			return nil, errors.New("invalid secp256k1 key")
+		}
+		// the scalar is padded to 32 bytes in libp2p keys
+		data := make([]byte, 32)
+		copy(data[32-len(ec.PrivateKey):], ec.PrivateKey)
+		b, err := proto.Marshal(&pb.PrivateKey{Type: pb.KeyType_Secp256k1.Enum(), Data: data})
+		if err != nil {
+			return nil, err
+		}
+		return ci.UnmarshalPrivateKey(b)
+	default:
+		return nil, fmt.Errorf("unsupported key algorithm %s", p.Algo.Algorithm)
+	}
+}
@@ -0,0 +1,283 @@
+package keystore
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"sync"
+
+	scrypt "github.com/ipfs/go-ipfs/thirdparty/scrypt"
+
+	ci "gx/ipfs/QmP1DfoUjiWH2ZBo1PBH6FupdBucbDepx3HpWmEY6JMUpY/go-libp2p-crypto"
+)
+
+// KDFScrypt derives the key of an encrypted keystore from its passphrase
+// with scrypt.
+const KDFScrypt = "scrypt"
+
+// Parameters of scrypt for new encrypted keystores.
+const (
+	DefaultScryptN = 1 << 15
+	DefaultScryptR = 8
+	DefaultScryptP = 1
+)
+
+// Bounds of the parameters of scrypt read from keystores and backups, which
+// may come from anywhere: scrypt takes 128*N*R bytes of memory, and P times
+// the time.
+const (
+	maxScryptMemory = 1 << 30
+	maxScryptP      = 16
+)
+
+var ErrWrongPassphrase = errors.New("wrong keystore passphrase")
+
+// encryptedPrefix starts the files of encrypted keys, which protobuf keys
+// never start with.
+var encryptedPrefix = []byte("ipfs-encrypted-key/1\n")
+
+// checkText is sealed in the Encryption, to tell wrong passphrases.
+var checkText = []byte("ipfs keystore")
+
+// Encryption is the derivation of the key the keys of a keystore are
+// encrypted with from its passphrase.
+type Encryption struct {
+	KDF  string
+	Salt []byte
+	N    int
+	R    int
+	P    int
+	// Check is a known text sealed with the key, to tell wrong passphrases.
+	Check []byte
+}
+
+// NewEncryption returns a new derivation of the key of a keystore from
+// passphrase.
+func NewEncryption(passphrase []byte) (*Encryption, error) {
+	return newEncryption(passphrase, DefaultScryptN, DefaultScryptR, DefaultScryptP)
+}
+
+func newEncryption(passphrase []byte, n, r, p int) (*Encryption, error) {
+	if len(passphrase) == 0 {
+		return nil, errors.New("the keystore passphrase must not be empty")
+	}
+
+	e := &Encryption{
+		KDF:  KDFScrypt,
+		Salt: make([]byte, 32),
+		N:    n,
+		R:    r,
+		P:    p,
+	}
+	if _, err := rand.Read(e.Salt); err != nil {
+		return nil, err
+	}
+
+	aead, err := e.aead(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	e.Check, err = seal(aead, checkText)
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// aead derives the key of passphrase and returns its cipher.
+func (e *Encryption) aead(passphrase []byte) (cipher.AEAD, error) {
+	if e.KDF != KDFScrypt {
+		return nil, fmt.Errorf("unknown keystore key derivation %q", e.KDF)
+	}
+	if e.N <= 0 || e.R <= 0 || e.P <= 0 || e.P > maxScryptP ||
+		e.N > maxScryptMemory/128/e.R {
+		return nil, fmt.Errorf("scrypt parameters out of bounds: N=%d r=%d p=%d", e.N, e.R, e.P)
+	}
+	key, err := scrypt.Key(passphrase, e.Salt, e.N, e.R, e.P, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.Check != nil {
+		text, err := open(aead, e.Check)
+		if err != nil || !bytes.Equal(text, checkText) {
+			return nil, ErrWrongPassphrase
+		}
+	}
+	return aead, nil
+}
+
+func isEncrypted(b []byte) bool {
+	return bytes.HasPrefix(b, encryptedPrefix)
+}
+
+// seal encrypts b in the format of the files of encrypted keys.
+func seal(aead cipher.AEAD, b []byte) ([]byte, error) {
+	out := make([]byte, len(encryptedPrefix)+aead.NonceSize(), len(encryptedPrefix)+aead.NonceSize()+len(b)+aead.Overhead())
+	copy(out, encryptedPrefix)
+	nonce := out[len(encryptedPrefix):]
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(out, nonce, b, encryptedPrefix), nil
+}
+
+func open(aead cipher.AEAD, b []byte) ([]byte, error) {
+	if !isEncrypted(b) || len(b) < len(encryptedPrefix)+aead.NonceSize() {
+		return nil, errors.New("invalid encrypted key")
+	}
+	b = b[len(encryptedPrefix):]
+	return aead.Open(nil, b[:aead.NonceSize()], b[aead.NonceSize():], encryptedPrefix)
+}
+
+// EncryptedKeystore is a FSKeystore which encrypts the keys it stores. The
+// passphrase is asked for when a key is first read or written, unless the
+// keystore is unlocked before. The keys stored in cleartext before the
+// keystore was encrypted are still read.
+type EncryptedKeystore struct {
+	fs         *FSKeystore
+	enc        *Encryption
+	passphrase func() ([]byte, error)
+
+	mu   sync.Mutex
+	aead cipher.AEAD
+}
+
+// NewEncryptedKeystore returns a keystore which encrypts the keys of fs as
+// enc tells, with the passphrase returned by passphrase.
+func NewEncryptedKeystore(fs *FSKeystore, enc *Encryption, passphrase func() ([]byte, error)) *EncryptedKeystore {
+	return &EncryptedKeystore{
+		fs:         fs,
+		enc:        enc,
+		passphrase: passphrase,
+	}
+}
+
+// Unlock asks for the passphrase, if it wasn't yet, and derives the key of
+// the keystore.
+func (ks *EncryptedKeystore) Unlock() error {
+	_, err := ks.unlock()
+	return err
+}
+
+func (ks *EncryptedKeystore) unlock() (cipher.AEAD, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if ks.aead != nil {
+		return ks.aead, nil
+	}
+
+	passphrase, err := ks.passphrase()
+	if err != nil {
+		return nil, fmt.Errorf("cannot unlock the keystore: %s", err)
+	}
+	ks.aead, err = ks.enc.aead(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return ks.aead, nil
+}
+
+// Has return whether or not a key exist in the Keystore
+func (ks *EncryptedKeystore) Has(name string) (bool, error) {
+	return ks.fs.Has(name)
+}
+
+// Put store a key in the Keystore
+func (ks *EncryptedKeystore) Put(name string, k ci.PrivKey) error {
+	if err := validateName(name); err != nil {
+		return err
+	}
+
+	b, err := k.Bytes()
+	if err != nil {
+		return err
+	}
+
+	aead, err := ks.unlock()
+	if err != nil {
+		return err
+	}
+
+	sealed, err := seal(aead, b)
+	if err != nil {
+		return err
+	}
+	return ks.fs.put(name, sealed)
+}
+
+// Get retrieve a key from the Keystore
+func (ks *EncryptedKeystore) Get(name string) (ci.PrivKey, error) {
+	data, err := ks.fs.read(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if isEncrypted(data) {
+		aead, err := ks.unlock()
+		if err != nil {
+			return nil, err
+		}
+		data, err = open(aead, data)
+		if err != nil {
+			return nil, fmt.Errorf("cannot decrypt key %s: %s", name, err)
+		}
+	}
+
+	return ci.UnmarshalPrivateKey(data)
+}
+
+// Delete remove a key from the Keystore
+func (ks *EncryptedKeystore) Delete(name string) error {
+	return ks.fs.Delete(name)
+}
+
+// List return a list of key identifier
+func (ks *EncryptedKeystore) List() ([]string, error) {
+	return ks.fs.List()
+}
+
+// EncryptAll encrypts the keys stored in cleartext and returns their names.
+func (ks *EncryptedKeystore) EncryptAll() ([]string, error) {
+	names, err := ks.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var encrypted []string
+	for _, name := range names {
+		data, err := ks.fs.read(name)
+		if err != nil {
+			return encrypted, err
+		}
+		if isEncrypted(data) {
+			continue
+		}
+
+		aead, err := ks.unlock()
+		if err != nil {
+			return encrypted, err
+		}
+		sealed, err := seal(aead, data)
+		if err != nil {
+			return encrypted, err
+		}
+		if err := ks.fs.replace(name, sealed); err != nil {
+			return encrypted, err
+		}
+		encrypted = append(encrypted, name)
+	}
+	return encrypted, nil
+}
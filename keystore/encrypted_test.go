@@ -0,0 +1,97 @@
+package keystore
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEncryptedKeystore(t *testing.T) {
+	tdir, err := ioutil.TempDir("", "keystore-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tdir)
+
+	fs, err := NewFSKeystore(tdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a key stored before the keystore is encrypted
+	k1 := privKeyOrFatal(t)
+	if err := fs.Put("old", k1); err != nil {
+		t.Fatal(err)
+	}
+
+	// cheap parameters, to keep the test fast
+	enc, err := newEncryption([]byte("secret"), 16, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	asked := 0
+	ks := NewEncryptedKeystore(fs, enc, func() ([]byte, error) {
+		asked++
+		return []byte("secret"), nil
+	})
+
+	k2 := privKeyOrFatal(t)
+	if err := ks.Put("new", k2); err != nil {
+		t.Fatal(err)
+	}
+	if err := assertFileEncrypted(filepath.Join(tdir, "new")); err != nil {
+		t.Fatal(err)
+	}
+	if err := assertGetKey(ks, "new", k2); err != nil {
+		t.Fatal(err)
+	}
+	if err := assertGetKey(ks, "old", k1); err != nil {
+		t.Fatal(err)
+	}
+	if asked != 1 {
+		t.Fatalf("expected the passphrase to be asked once, got %d", asked)
+	}
+
+	// the cleartext keystore can't read encrypted keys
+	if _, err := fs.Get("new"); err != ErrKeyEncrypted {
+		t.Fatalf("expected ErrKeyEncrypted, got %v", err)
+	}
+
+	encrypted, err := ks.EncryptAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(encrypted) != 1 || encrypted[0] != "old" {
+		t.Fatalf("expected the old key to be encrypted, got %v", encrypted)
+	}
+	if err := assertFileEncrypted(filepath.Join(tdir, "old")); err != nil {
+		t.Fatal(err)
+	}
+	if err := assertGetKey(ks, "old", k1); err != nil {
+		t.Fatal(err)
+	}
+	if err := assertDirContents(tdir, withMetadata("new", "old")); err != nil {
+		t.Fatal(err)
+	}
+
+	wrong := NewEncryptedKeystore(fs, enc, func() ([]byte, error) {
+		return []byte("wrong"), nil
+	})
+	if _, err := wrong.Get("new"); err != ErrWrongPassphrase {
+		t.Fatalf("expected ErrWrongPassphrase, got %v", err)
+	}
+}
+
+func assertFileEncrypted(path string) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if !isEncrypted(b) {
+		return fmt.Errorf("expected %s to be encrypted", path)
+	}
+	return nil
+}
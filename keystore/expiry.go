@@ -0,0 +1,58 @@
+package keystore
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ExpiresSoon is how long before their expiry keys are flagged as expiring.
+const ExpiresSoon = 14 * 24 * time.Hour
+
+// ParseExpiry parses the expiry of a key: a date, as 2006-01-02 or in
+// RFC 3339, or a duration from now, which may be in days, as "90d". "never"
+// returns nil.
+func ParseExpiry(s string, now time.Time) (*time.Time, error) {
+	if s == "never" {
+		return nil, nil
+	}
+
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return &t, nil
+		}
+	}
+
+	d, err := parseExpiryDuration(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expiry %q: expected a date, a duration such as 90d or 12h, or never", s)
+	}
+	if d <= 0 {
+		return nil, fmt.Errorf("invalid expiry %q: the duration must be positive", s)
+	}
+	t := now.Add(d).UTC().Truncate(time.Second)
+	return &t, nil
+}
+
+func parseExpiryDuration(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// Expired tells whether the key md describes expired at now.
+func (md *Metadata) Expired(now time.Time) bool {
+	return md != nil && md.Expires != nil && !now.Before(*md.Expires)
+}
+
+// ExpiringSoon tells whether the key md describes expires within
+// ExpiresSoon of now, and has not yet.
+func (md *Metadata) ExpiringSoon(now time.Time) bool {
+	return md != nil && md.Expires != nil && !md.Expired(now) && md.Expires.Sub(now) < ExpiresSoon
+}
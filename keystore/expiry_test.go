@@ -0,0 +1,49 @@
+package keystore
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseExpiry(t *testing.T) {
+	now := time.Date(2017, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, c := range []struct {
+		in  string
+		out time.Time
+	}{
+		{"90d", now.Add(90 * 24 * time.Hour)},
+		{"36h", now.Add(36 * time.Hour)},
+		{"2018-01-01", time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"2018-01-01T10:00:00Z", time.Date(2018, 1, 1, 10, 0, 0, 0, time.UTC)},
+	} {
+		exp, err := ParseExpiry(c.in, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if exp == nil || !exp.Equal(c.out) {
+			t.Fatalf("expected %s to expire at %s, got %v", c.in, c.out, exp)
+		}
+	}
+
+	if exp, err := ParseExpiry("never", now); err != nil || exp != nil {
+		t.Fatalf("expected no expiry, got %v, %v", exp, err)
+	}
+	for _, in := range []string{"soon", "-3d", "0h"} {
+		if _, err := ParseExpiry(in, now); err == nil {
+			t.Fatalf("expected %q to be refused", in)
+		}
+	}
+
+	exp := now.Add(10 * 24 * time.Hour)
+	md := &Metadata{Created: now, Expires: &exp}
+	if md.Expired(now) || !md.ExpiringSoon(now) {
+		t.Fatal("expected the key to expire soon")
+	}
+	if !md.Expired(exp) || md.ExpiringSoon(exp) {
+		t.Fatal("expected the key to be expired")
+	}
+	if (&Metadata{Created: now}).Expired(now.Add(1000 * 24 * time.Hour)) {
+		t.Fatal("expected a key without expiry never to expire")
+	}
+}
@@ -0,0 +1,118 @@
+package keystore
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// indexFile is the file of the index of a FSKeystore, which begins with a
+// period so that it is no key.
+const indexFile = ".index"
+
+// IndexEntry is what the index of a keystore records about a key, to list
+// it without reading and decoding it.
+type IndexEntry struct {
+	ID   string
+	Type string
+	Size int
+}
+
+// IndexedKeystore is a Keystore which keeps an index of its keys.
+type IndexedKeystore interface {
+	Keystore
+	// Index loads the index of the keys.
+	Index() (*KeyIndex, error)
+}
+
+// indexed is an IndexEntry, with the state of the file of the key when it
+// was recorded.
+type indexed struct {
+	IndexEntry
+	ModTime  time.Time
+	FileSize int64
+}
+
+// KeyIndex is the index of the keys of a FSKeystore. Its entries are kept
+// as long as the files of their keys are not changed.
+type KeyIndex struct {
+	ks      *FSKeystore
+	entries map[string]indexed
+	dirty   bool
+}
+
+func (ks *FSKeystore) indexPath() string {
+	return filepath.Join(ks.dir, indexFile)
+}
+
+// Index loads the index of the keys. A missing or damaged index is rebuilt
+// as the keys are listed.
+func (ks *FSKeystore) Index() (*KeyIndex, error) {
+	ix := &KeyIndex{ks: ks, entries: make(map[string]indexed)}
+	b, err := ioutil.ReadFile(ks.indexPath())
+	if os.IsNotExist(err) {
+		return ix, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &ix.entries); err != nil {
+		log.Warningf("rebuilding the damaged index of the keystore: %s", err)
+		ix.entries = make(map[string]indexed)
+	}
+	return ix, nil
+}
+
+// Index loads the index of the keys.
+func (ks *EncryptedKeystore) Index() (*KeyIndex, error) {
+	return ks.fs.Index()
+}
+
+// Get returns the entry of the key name, or nil if it has none, or if the
+// key changed since it was recorded.
+func (ix *KeyIndex) Get(name string) *IndexEntry {
+	e, ok := ix.entries[name]
+	if !ok {
+		return nil
+	}
+	fi, err := os.Stat(filepath.Join(ix.ks.dir, name))
+	if err != nil || !fi.ModTime().Equal(e.ModTime) || fi.Size() != e.FileSize {
+		delete(ix.entries, name)
+		ix.dirty = true
+		return nil
+	}
+	return &e.IndexEntry
+}
+
+// Set records the entry of the key name.
+func (ix *KeyIndex) Set(name string, e *IndexEntry) error {
+	fi, err := os.Stat(filepath.Join(ix.ks.dir, name))
+	if err != nil {
+		return err
+	}
+	ix.entries[name] = indexed{IndexEntry: *e, ModTime: fi.ModTime(), FileSize: fi.Size()}
+	ix.dirty = true
+	return nil
+}
+
+// Save writes the index, if it changed.
+func (ix *KeyIndex) Save() error {
+	if !ix.dirty {
+		return nil
+	}
+	b, err := json.Marshal(ix.entries)
+	if err != nil {
+		return err
+	}
+	tmp := ix.ks.indexPath() + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, ix.ks.indexPath()); err != nil {
+		return err
+	}
+	ix.dirty = false
+	return nil
+}
@@ -0,0 +1,65 @@
+package keystore
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestKeyIndex(t *testing.T) {
+	tdir, err := ioutil.TempDir("", "keystore-index-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tdir)
+
+	ks, err := NewFSKeystore(tdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.Put("foo", privKeyOrFatal(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	ix, err := ks.Index()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ix.Get("foo") != nil {
+		t.Fatal("expected no entry in a new index")
+	}
+	e := &IndexEntry{ID: "QmFoo", Type: "ed25519", Size: 256}
+	if err := ix.Set("foo", e); err != nil {
+		t.Fatal(err)
+	}
+	if err := ix.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := ks.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l) != 1 || l[0] != "foo" {
+		t.Fatalf("expected the index not to be listed as a key, got %v", l)
+	}
+
+	ix, err = ks.Index()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ix.Get("foo"); got == nil || *got != *e {
+		t.Fatalf("expected the saved entry, got %v", got)
+	}
+
+	// the entry of a key which changed is dropped
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(tdir, "foo"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	if ix.Get("foo") != nil {
+		t.Fatal("expected the entry of the changed key to be dropped")
+	}
+}
@@ -0,0 +1,200 @@
+package keystore
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+	"syscall"
+
+	ci "gx/ipfs/QmP1DfoUjiWH2ZBo1PBH6FupdBucbDepx3HpWmEY6JMUpY/go-libp2p-crypto"
+)
+
+// errKeychainNotFound is returned by a keychain for the items it doesn't
+// have.
+var errKeychainNotFound = errors.New("no such item in the keychain")
+
+// keychain is the credential store of the OS: the keychain of macOS, the
+// secret service of Linux desktops or the credential manager of Windows.
+// Its items are secrets, named by a service and an account.
+type keychain interface {
+	get(service, account string) ([]byte, error)
+	// set creates the item, or replaces its secret.
+	set(service, account string, secret []byte) error
+	delete(service, account string) error
+}
+
+// keychainIndex is the account of the item listing the names of the keys
+// of a service. Key names can't begin with a period.
+const keychainIndex = ".index"
+
+// KeychainKeystore is a Keystore whose keys are items of the credential
+// store of the OS, all under the same service, rather than files.
+type KeychainKeystore struct {
+	kc      keychain
+	service string
+}
+
+// NewKeychainKeystore returns the keystore of the keys kept in the
+// credential store of the OS under service.
+func NewKeychainKeystore(service string) (*KeychainKeystore, error) {
+	kc, err := osKeychain()
+	if err != nil {
+		return nil, err
+	}
+	return &KeychainKeystore{kc: kc, service: service}, nil
+}
+
+// Has return whether or not a key exist in the Keystore
+func (ks *KeychainKeystore) Has(name string) (bool, error) {
+	if err := validateName(name); err != nil {
+		return false, err
+	}
+
+	_, err := ks.kc.get(ks.service, name)
+	switch err {
+	case nil:
+		return true, nil
+	case errKeychainNotFound:
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+// Put store a key in the Keystore
+func (ks *KeychainKeystore) Put(name string, k ci.PrivKey) error {
+	has, err := ks.Has(name)
+	if err != nil {
+		return err
+	}
+	if has {
+		return ErrKeyExists
+	}
+
+	b, err := k.Bytes()
+	if err != nil {
+		return err
+	}
+	if err := ks.kc.set(ks.service, name, b); err != nil {
+		return err
+	}
+
+	names, err := ks.List()
+	if err != nil {
+		return err
+	}
+	return ks.putIndex(append(names, name))
+}
+
+// Get retrieve a key from the Keystore
+func (ks *KeychainKeystore) Get(name string) (ci.PrivKey, error) {
+	if err := validateName(name); err != nil {
+		return nil, err
+	}
+
+	b, err := ks.kc.get(ks.service, name)
+	if err == errKeychainNotFound {
+		return nil, ErrNoSuchKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ci.UnmarshalPrivateKey(b)
+}
+
+// Delete remove a key from the Keystore
+func (ks *KeychainKeystore) Delete(name string) error {
+	if err := validateName(name); err != nil {
+		return err
+	}
+
+	err := ks.kc.delete(ks.service, name)
+	if err == errKeychainNotFound {
+		return ErrNoSuchKey
+	}
+	if err != nil {
+		return err
+	}
+
+	names, err := ks.List()
+	if err != nil {
+		return err
+	}
+	out := names[:0]
+	for _, n := range names {
+		if n != name {
+			out = append(out, n)
+		}
+	}
+	return ks.putIndex(out)
+}
+
+// List return a list of key identifier
+func (ks *KeychainKeystore) List() ([]string, error) {
+	b, err := ks.kc.get(ks.service, keychainIndex)
+	if err == errKeychainNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	if err := json.Unmarshal(b, &names); err != nil {
+		return nil, fmt.Errorf("invalid index of the keys in the keychain: %s", err)
+	}
+	return names, nil
+}
+
+// putIndex records the names of the keys of the service, as most credential
+// stores can't list the items of a service.
+func (ks *KeychainKeystore) putIndex(names []string) error {
+	sort.Strings(names)
+	b, err := json.Marshal(names)
+	if err != nil {
+		return err
+	}
+	return ks.kc.set(ks.service, keychainIndex, b)
+}
+
+// keychainToolError is the failure of the command line tool of a
+// credential store.
+type keychainToolError struct {
+	Tool string
+	Code int // the exit status
+	Msg  string
+}
+
+func (e *keychainToolError) Error() string {
+	if e.Msg == "" {
+		return fmt.Sprintf("%s: exit status %d", e.Tool, e.Code)
+	}
+	return e.Tool + ": " + e.Msg
+}
+
+// runKeychainTool runs the command line tool of a credential store, with
+// stdin as its input, and returns its output. Secrets are passed on stdin,
+// as the arguments of a process can be seen by the other users.
+func runKeychainTool(stdin []byte, name string, args ...string) ([]byte, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if exit, ok := err.(*exec.ExitError); ok {
+		code := 1
+		if st, ok := exit.Sys().(syscall.WaitStatus); ok {
+			code = st.ExitStatus()
+		}
+		return out, &keychainToolError{
+			Tool: name,
+			Code: code,
+			Msg:  strings.TrimSpace(stderr.String()),
+		}
+	}
+	return out, err
+}
@@ -0,0 +1,67 @@
+package keystore
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// errSecItemNotFound is the exit status of security(1) for the items the
+// keychain doesn't have.
+const errSecItemNotFound = 44
+
+// macKeychain is the login keychain of macOS, used with security(1). The
+// secrets are stored in base64, as security(1) takes them as text.
+type macKeychain struct{}
+
+func osKeychain() (keychain, error) {
+	return macKeychain{}, nil
+}
+
+func macNotFound(err error) bool {
+	terr, ok := err.(*keychainToolError)
+	return ok && terr.Code == errSecItemNotFound
+}
+
+// quoteSecurityArg quotes s for the interactive mode of security(1).
+func quoteSecurityArg(s string) (string, error) {
+	if strings.ContainsAny(s, "\"\\\n") {
+		return "", fmt.Errorf("names with quotes, backslashes or newlines can't be kept in the keychain: %q", s)
+	}
+	return `"` + s + `"`, nil
+}
+
+func (macKeychain) get(service, account string) ([]byte, error) {
+	out, err := runKeychainTool(nil, "security", "find-generic-password", "-s", service, "-a", account, "-w")
+	if macNotFound(err) {
+		return nil, errKeychainNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(strings.TrimSpace(string(out)))
+}
+
+func (macKeychain) set(service, account string, secret []byte) error {
+	s, err := quoteSecurityArg(service)
+	if err != nil {
+		return err
+	}
+	a, err := quoteSecurityArg(account)
+	if err != nil {
+		return err
+	}
+
+	// the command is read on stdin, to keep the secret out of the arguments
+	cmd := fmt.Sprintf("add-generic-password -U -s %s -a %s -w %s\n", s, a, base64.StdEncoding.EncodeToString(secret))
+	_, err = runKeychainTool([]byte(cmd), "security", "-i")
+	return err
+}
+
+func (macKeychain) delete(service, account string) error {
+	_, err := runKeychainTool(nil, "security", "delete-generic-password", "-s", service, "-a", account)
+	if macNotFound(err) {
+		return errKeychainNotFound
+	}
+	return err
+}
@@ -0,0 +1,42 @@
+package keystore
+
+import (
+	"encoding/base64"
+	"strings"
+)
+
+// secretService is the secret service of the Linux desktops, such as GNOME
+// Keyring or KWallet, used with secret-tool(1). The secrets are stored in
+// base64, as secret-tool(1) prints them as text.
+type secretService struct{}
+
+func osKeychain() (keychain, error) {
+	return secretService{}, nil
+}
+
+func (secretService) get(service, account string) ([]byte, error) {
+	out, err := runKeychainTool(nil, "secret-tool", "lookup", "service", service, "account", account)
+	if terr, ok := err.(*keychainToolError); ok && terr.Code == 1 && len(out) == 0 && terr.Msg == "" {
+		// secret-tool fails silently for the items it doesn't find
+		return nil, errKeychainNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(strings.TrimSpace(string(out)))
+}
+
+func (secretService) set(service, account string, secret []byte) error {
+	_, err := runKeychainTool([]byte(base64.StdEncoding.EncodeToString(secret)), "secret-tool", "store",
+		"--label", "IPFS key "+account+" of "+service,
+		"service", service, "account", account)
+	return err
+}
+
+func (ss secretService) delete(service, account string) error {
+	if _, err := ss.get(service, account); err != nil {
+		return err
+	}
+	_, err := runKeychainTool(nil, "secret-tool", "clear", "service", service, "account", account)
+	return err
+}
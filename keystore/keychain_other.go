@@ -0,0 +1,12 @@
+// +build !darwin,!linux,!windows
+
+package keystore
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func osKeychain() (keychain, error) {
+	return nil, fmt.Errorf("the keystore can't be kept in the credential store of %s", runtime.GOOS)
+}
@@ -0,0 +1,93 @@
+package keystore
+
+import (
+	"sort"
+	"testing"
+)
+
+// mapKeychain is a keychain in memory.
+type mapKeychain map[string][]byte
+
+func (kc mapKeychain) get(service, account string) ([]byte, error) {
+	s, ok := kc[service+"/"+account]
+	if !ok {
+		return nil, errKeychainNotFound
+	}
+	return s, nil
+}
+
+func (kc mapKeychain) set(service, account string, secret []byte) error {
+	kc[service+"/"+account] = secret
+	return nil
+}
+
+func (kc mapKeychain) delete(service, account string) error {
+	if _, ok := kc[service+"/"+account]; !ok {
+		return errKeychainNotFound
+	}
+	delete(kc, service+"/"+account)
+	return nil
+}
+
+func TestKeychainKeystore(t *testing.T) {
+	kc := make(mapKeychain)
+	ks := &KeychainKeystore{kc: kc, service: "ipfs-a"}
+	other := &KeychainKeystore{kc: kc, service: "ipfs-b"}
+
+	if l, err := ks.List(); err != nil || len(l) != 0 {
+		t.Fatalf("expected no keys, got %v, %v", l, err)
+	}
+
+	k1 := privKeyOrFatal(t)
+	k2 := privKeyOrFatal(t)
+	if err := ks.Put("foo", k1); err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.Put("bar", k2); err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.Put("foo", k2); err != ErrKeyExists {
+		t.Fatalf("expected ErrKeyExists, got %v", err)
+	}
+	if err := ks.Put(".index", k2); err == nil {
+		t.Fatal("expected the name of the index to be refused")
+	}
+	if err := other.Put("foo", k2); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := ks.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(l)
+	if len(l) != 2 || l[0] != "bar" || l[1] != "foo" {
+		t.Fatalf("expected the keys bar and foo, got %v", l)
+	}
+
+	k, err := ks.Get("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !k.Equals(k1) {
+		t.Fatal("the key read is not the key stored")
+	}
+
+	if err := ks.Delete("foo"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ks.Get("foo"); err != ErrNoSuchKey {
+		t.Fatalf("expected ErrNoSuchKey, got %v", err)
+	}
+	if err := ks.Delete("foo"); err != ErrNoSuchKey {
+		t.Fatalf("expected ErrNoSuchKey, got %v", err)
+	}
+	if l, err := ks.List(); err != nil || len(l) != 1 || l[0] != "bar" {
+		t.Fatalf("expected the key bar, got %v, %v", l, err)
+	}
+
+	// the keys of the other service are kept apart
+	if k, err := other.Get("foo"); err != nil || !k.Equals(k2) {
+		t.Fatalf("expected the key foo of the other service, got %v", err)
+	}
+}
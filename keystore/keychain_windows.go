@@ -0,0 +1,119 @@
+package keystore
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var (
+	advapi32        = syscall.NewLazyDLL("advapi32.dll")
+	procCredReadW   = advapi32.NewProc("CredReadW")
+	procCredWriteW  = advapi32.NewProc("CredWriteW")
+	procCredDeleteW = advapi32.NewProc("CredDeleteW")
+	procCredFree    = advapi32.NewProc("CredFree")
+)
+
+const (
+	credTypeGeneric         = 1
+	credPersistLocalMachine = 2
+
+	errorNotFound = syscall.Errno(1168)
+)
+
+// credential is the CREDENTIALW structure of the credential manager.
+type credential struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        syscall.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+// credentialManager is the credential manager of Windows, which keeps the
+// secrets as generic credentials named service/account, of at most 2560
+// bytes.
+type credentialManager struct{}
+
+func osKeychain() (keychain, error) {
+	if err := advapi32.Load(); err != nil {
+		return nil, err
+	}
+	return credentialManager{}, nil
+}
+
+func credTarget(service, account string) (*uint16, error) {
+	return syscall.UTF16PtrFromString(service + "/" + account)
+}
+
+func (credentialManager) get(service, account string) ([]byte, error) {
+	target, err := credTarget(service, account)
+	if err != nil {
+		return nil, err
+	}
+
+	var cred *credential
+	ok, _, err := procCredReadW.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0, uintptr(unsafe.Pointer(&cred)))
+	if ok == 0 {
+		if err == errorNotFound {
+			return nil, errKeychainNotFound
+		}
+		return nil, err
+	}
+	defer procCredFree.Call(uintptr(unsafe.Pointer(cred)))
+
+	secret := make([]byte, cred.CredentialBlobSize)
+	if len(secret) > 0 {
+		copy(secret, (*[1 << 20]byte)(unsafe.Pointer(cred.CredentialBlob))[:len(secret):len(secret)])
+	}
+	return secret, nil
+}
+
+func (credentialManager) set(service, account string, secret []byte) error {
+	target, err := credTarget(service, account)
+	if err != nil {
+		return err
+	}
+	user, err := syscall.UTF16PtrFromString(account)
+	if err != nil {
+		return err
+	}
+
+	cred := credential{
+		Type:               credTypeGeneric,
+		TargetName:         target,
+		CredentialBlobSize: uint32(len(secret)),
+		Persist:            credPersistLocalMachine,
+		UserName:           user,
+	}
+	if len(secret) > 0 {
+		cred.CredentialBlob = &secret[0]
+	}
+	ok, _, err := procCredWriteW.Call(uintptr(unsafe.Pointer(&cred)), 0)
+	if ok == 0 {
+		return err
+	}
+	return nil
+}
+
+func (credentialManager) delete(service, account string) error {
+	target, err := credTarget(service, account)
+	if err != nil {
+		return err
+	}
+
+	ok, _, err := procCredDeleteW.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0)
+	if ok == 0 {
+		if err == errorNotFound {
+			return errKeychainNotFound
+		}
+		return err
+	}
+	return nil
+}
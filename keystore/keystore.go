@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	ci "gx/ipfs/QmP1DfoUjiWH2ZBo1PBH6FupdBucbDepx3HpWmEY6JMUpY/go-libp2p-crypto"
 )
@@ -25,6 +26,7 @@ type Keystore interface {
 
 var ErrNoSuchKey = fmt.Errorf("no key by the given name was found")
 var ErrKeyExists = fmt.Errorf("key by that name already exists, refusing to overwrite")
+var ErrKeyEncrypted = fmt.Errorf("key is encrypted, but Keystore.Encryption is not set")
 
 type FSKeystore struct {
 	dir string
@@ -88,9 +90,14 @@ func (ks *FSKeystore) Put(name string, k ci.PrivKey) error {
 		return err
 	}
 
+	return ks.put(name, b)
+}
+
+// put writes the file of a new key.
+func (ks *FSKeystore) put(name string, b []byte) error {
 	kp := filepath.Join(ks.dir, name)
 
-	_, err = os.Stat(kp)
+	_, err := os.Stat(kp)
 	if err == nil {
 		return ErrKeyExists
 	} else if !os.IsNotExist(err) {
@@ -104,12 +111,39 @@ func (ks *FSKeystore) Put(name string, k ci.PrivKey) error {
 	defer fi.Close()
 
 	_, err = fi.Write(b)
+	if err != nil {
+		return err
+	}
 
-	return err
+	return ks.SetMetadata(name, &Metadata{Created: time.Now().UTC()})
+}
+
+// replace overwrites the file of a key, atomically.
+func (ks *FSKeystore) replace(name string, b []byte) error {
+	// keys can't begin with a period, the temporary file is no key
+	tmp := filepath.Join(ks.dir, "."+name+".tmp")
+	if err := ioutil.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(ks.dir, name))
 }
 
 // Get retrieve a key from the Keystore
 func (ks *FSKeystore) Get(name string) (ci.PrivKey, error) {
+	data, err := ks.read(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if isEncrypted(data) {
+		return nil, ErrKeyEncrypted
+	}
+
+	return ci.UnmarshalPrivateKey(data)
+}
+
+// read returns the content of the file of a key.
+func (ks *FSKeystore) read(name string) ([]byte, error) {
 	if err := validateName(name); err != nil {
 		return nil, err
 	}
@@ -124,7 +158,7 @@ func (ks *FSKeystore) Get(name string) (ci.PrivKey, error) {
 		return nil, err
 	}
 
-	return ci.UnmarshalPrivateKey(data)
+	return data, nil
 }
 
 // Delete remove a key from the Keystore
@@ -135,7 +169,15 @@ func (ks *FSKeystore) Delete(name string) error {
 
 	kp := filepath.Join(ks.dir, name)
 
-	return os.Remove(kp)
+	if err := os.Remove(kp); err != nil {
+		return err
+	}
+
+	err := os.Remove(ks.metadataPath(name))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
 }
 
 // List return a list of key identifier
@@ -144,6 +186,19 @@ func (ks *FSKeystore) List() ([]string, error) {
 	if err != nil {
 		return nil, err
 	}
+	defer dir.Close()
+
+	names, err := dir.Readdirnames(0)
+	if err != nil {
+		return nil, err
+	}
 
-	return dir.Readdirnames(0)
+	// skip the temporary files, whose names are no valid key names
+	list := make([]string, 0, len(names))
+	for _, name := range names {
+		if validateName(name) == nil {
+			list = append(list, name)
+		}
+	}
+	return list, nil
 }
@@ -69,7 +69,7 @@ func TestKeystoreBasics(t *testing.T) {
 		t.Fatal("wrong entries listed")
 	}
 
-	if err := assertDirContents(tdir, []string{"foo", "bar"}); err != nil {
+	if err := assertDirContents(tdir, withMetadata("foo", "bar")); err != nil {
 		t.Fatal(err)
 	}
 
@@ -78,7 +78,7 @@ func TestKeystoreBasics(t *testing.T) {
 		t.Fatal("should not be able to overwrite key")
 	}
 
-	if err := assertDirContents(tdir, []string{"foo", "bar"}); err != nil {
+	if err := assertDirContents(tdir, withMetadata("foo", "bar")); err != nil {
 		t.Fatal(err)
 	}
 
@@ -102,7 +102,7 @@ func TestKeystoreBasics(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	if err := assertDirContents(tdir, []string{"foo"}); err != nil {
+	if err := assertDirContents(tdir, withMetadata("foo")); err != nil {
 		t.Fatal(err)
 	}
 
@@ -114,7 +114,7 @@ func TestKeystoreBasics(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	if err := assertDirContents(tdir, []string{"foo", "beep", "boop"}); err != nil {
+	if err := assertDirContents(tdir, withMetadata("foo", "beep", "boop")); err != nil {
 		t.Fatal(err)
 	}
 
@@ -183,6 +183,16 @@ func assertGetKey(ks Keystore, name string, exp ci.PrivKey) error {
 	return nil
 }
 
+// withMetadata returns the files of the keys names, along with the files of
+// their metadata.
+func withMetadata(names ...string) []string {
+	files := append([]string(nil), names...)
+	for _, name := range names {
+		files = append(files, "."+name+".meta")
+	}
+	return files
+}
+
 func assertDirContents(dir string, exp []string) error {
 	finfos, err := ioutil.ReadDir(dir)
 	if err != nil {
@@ -0,0 +1,120 @@
+package keystore
+
+import (
+	"errors"
+	"io"
+	"sync"
+
+	ci "gx/ipfs/QmP1DfoUjiWH2ZBo1PBH6FupdBucbDepx3HpWmEY6JMUpY/go-libp2p-crypto"
+)
+
+// LazyKeystore opens the keystore it stands for on the first use of its
+// keys, so that a repo whose keys are kept in a service, which needs
+// credentials or the network, opens without them for the commands which
+// don't use the keys. An open failing is retried on the next use.
+type LazyKeystore struct {
+	open func() (Keystore, error)
+
+	mu sync.Mutex
+	ks Keystore
+}
+
+// NewLazyKeystore returns the keystore which open opens, once its keys are
+// used. With generating set, the keystore is a GeneratingKeystore, and so
+// must the keystore open returns be.
+func NewLazyKeystore(open func() (Keystore, error), generating bool) Keystore {
+	lks := &LazyKeystore{open: open}
+	if generating {
+		return &lazyGeneratingKeystore{lks}
+	}
+	return lks
+}
+
+func (lks *LazyKeystore) get() (Keystore, error) {
+	lks.mu.Lock()
+	defer lks.mu.Unlock()
+
+	if lks.ks == nil {
+		ks, err := lks.open()
+		if err != nil {
+			return nil, err
+		}
+		lks.ks = ks
+	}
+	return lks.ks, nil
+}
+
+// Has return whether or not a key exist in the Keystore
+func (lks *LazyKeystore) Has(name string) (bool, error) {
+	ks, err := lks.get()
+	if err != nil {
+		return false, err
+	}
+	return ks.Has(name)
+}
+
+// Put store a key in the Keystore
+func (lks *LazyKeystore) Put(name string, k ci.PrivKey) error {
+	ks, err := lks.get()
+	if err != nil {
+		return err
+	}
+	return ks.Put(name, k)
+}
+
+// Get retrieve a key from the Keystore
+func (lks *LazyKeystore) Get(name string) (ci.PrivKey, error) {
+	ks, err := lks.get()
+	if err != nil {
+		return nil, err
+	}
+	return ks.Get(name)
+}
+
+// Delete remove a key from the Keystore
+func (lks *LazyKeystore) Delete(name string) error {
+	ks, err := lks.get()
+	if err != nil {
+		return err
+	}
+	return ks.Delete(name)
+}
+
+// List return a list of key identifier
+func (lks *LazyKeystore) List() ([]string, error) {
+	ks, err := lks.get()
+	if err != nil {
+		return nil, err
+	}
+	return ks.List()
+}
+
+// Close closes the keystore, if it was opened and needs closing.
+func (lks *LazyKeystore) Close() error {
+	lks.mu.Lock()
+	defer lks.mu.Unlock()
+
+	if c, ok := lks.ks.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// lazyGeneratingKeystore is a LazyKeystore of a GeneratingKeystore.
+type lazyGeneratingKeystore struct {
+	*LazyKeystore
+}
+
+var _ GeneratingKeystore = (*lazyGeneratingKeystore)(nil)
+
+func (lks *lazyGeneratingKeystore) Generate(name, typ string, size int) (ci.PrivKey, error) {
+	ks, err := lks.get()
+	if err != nil {
+		return nil, err
+	}
+	gks, ok := ks.(GeneratingKeystore)
+	if !ok {
+		return nil, errors.New("the keystore cannot generate keys")
+	}
+	return gks.Generate(name, typ, size)
+}
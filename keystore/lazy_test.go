@@ -0,0 +1,48 @@
+package keystore
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestLazyKeystore(t *testing.T) {
+	opened := 0
+	fail := errors.New("unreachable")
+	var err error
+	mks := NewMemKeystore()
+	ks := NewLazyKeystore(func() (Keystore, error) {
+		opened++
+		return mks, err
+	}, false)
+	if opened != 0 {
+		t.Fatal("expected the keystore not to be opened before its keys are used")
+	}
+	if _, ok := ks.(GeneratingKeystore); ok {
+		t.Fatal("expected a keystore which doesn't generate keys")
+	}
+
+	err = fail
+	if _, err := ks.List(); err != fail {
+		t.Fatalf("expected the error of the open, got %v", err)
+	}
+
+	// the open is retried, and done once
+	err = nil
+	if err := ks.Put("foo", privKeyOrFatal(t)); err != nil {
+		t.Fatal(err)
+	}
+	if has, err := ks.Has("foo"); err != nil || !has {
+		t.Fatalf("expected the key to be stored, got %v, %v", has, err)
+	}
+	if opened != 2 {
+		t.Fatalf("expected the keystore to be opened twice, got %d", opened)
+	}
+
+	gks, ok := NewLazyKeystore(func() (Keystore, error) { return mks, nil }, true).(GeneratingKeystore)
+	if !ok {
+		t.Fatal("expected a generating keystore")
+	}
+	if _, err := gks.Generate("bar", "rsa", 2048); err == nil {
+		t.Fatal("expected a keystore which can't generate keys to fail")
+	}
+}
@@ -0,0 +1,118 @@
+package keystore
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	ci "gx/ipfs/QmP1DfoUjiWH2ZBo1PBH6FupdBucbDepx3HpWmEY6JMUpY/go-libp2p-crypto"
+	pb "gx/ipfs/QmP1DfoUjiWH2ZBo1PBH6FupdBucbDepx3HpWmEY6JMUpY/go-libp2p-crypto/pb"
+	proto "gx/ipfs/QmZ4Qi3GaRbjcx28Sme5eMH7RQjGkt8wHxt2a65oLaeFEV/gogo-protobuf/proto"
+)
+
+// Metadata is what a keystore records about a key, besides the key.
+type Metadata struct {
+	Created time.Time
+	// Expires, if set, is when the key expires, after which IPNS records are
+	// published with it only with a warning, or not at all.
+	Expires *time.Time `json:",omitempty"`
+	// Policy restricts the uses of the key, see 'ipfs key protect'.
+	Policy *Policy `json:",omitempty"`
+}
+
+// MetadataKeystore is a Keystore which records the metadata of its keys.
+type MetadataKeystore interface {
+	Keystore
+	// Metadata returns the metadata of a key, or nil if it has none, as keys
+	// stored by older versions.
+	Metadata(string) (*Metadata, error)
+	// SetMetadata replaces the metadata of a key.
+	SetMetadata(string, *Metadata) error
+}
+
+// metadataPath returns the path of the file of the metadata of a key, which
+// begins with a period so that it is no key.
+func (ks *FSKeystore) metadataPath(name string) string {
+	return filepath.Join(ks.dir, "."+name+".meta")
+}
+
+// Metadata returns the metadata of a key, or nil if it has none.
+func (ks *FSKeystore) Metadata(name string) (*Metadata, error) {
+	if err := validateName(name); err != nil {
+		return nil, err
+	}
+
+	b, err := ioutil.ReadFile(ks.metadataPath(name))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var md Metadata
+	if err := json.Unmarshal(b, &md); err != nil {
+		return nil, err
+	}
+	return &md, nil
+}
+
+// SetMetadata replaces the metadata of a key.
+func (ks *FSKeystore) SetMetadata(name string, md *Metadata) error {
+	if err := validateName(name); err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(md)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(ks.metadataPath(name), b, 0600)
+}
+
+// Metadata returns the metadata of a key, or nil if it has none.
+func (ks *EncryptedKeystore) Metadata(name string) (*Metadata, error) {
+	return ks.fs.Metadata(name)
+}
+
+// SetMetadata replaces the metadata of a key.
+func (ks *EncryptedKeystore) SetMetadata(name string, md *Metadata) error {
+	return ks.fs.SetMetadata(name, md)
+}
+
+// KeyType returns the type of k, as named by 'ipfs key gen', and its size
+// in bits. It is read from the public key, as the private key may not be
+// readable.
+func KeyType(k ci.PrivKey) (string, int, error) {
+	b, err := k.GetPublic().Bytes()
+	if err != nil {
+		return "", 0, err
+	}
+	var pk pb.PublicKey
+	if err := proto.Unmarshal(b, &pk); err != nil {
+		return "", 0, err
+	}
+
+	typ := strings.ToLower(pk.GetType().String())
+	switch pk.GetType() {
+	case pb.KeyType_RSA:
+		pub, err := x509.ParsePKIXPublicKey(pk.GetData())
+		if err != nil {
+			return "", 0, err
+		}
+		rk, ok := pub.(*rsa.PublicKey)
+		if !ok {
+			return "", 0, fmt.Errorf("not an RSA public key")
+		}
+		return typ, rk.N.BitLen(), nil
+	default:
+		// ed25519 and secp256k1 keys are 256 bits
+		return typ, 256, nil
+	}
+}
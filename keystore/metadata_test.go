@@ -0,0 +1,79 @@
+package keystore
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	ci "gx/ipfs/QmP1DfoUjiWH2ZBo1PBH6FupdBucbDepx3HpWmEY6JMUpY/go-libp2p-crypto"
+)
+
+func TestKeystoreMetadata(t *testing.T) {
+	tdir, err := ioutil.TempDir("", "keystore-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tdir)
+
+	ks, err := NewFSKeystore(tdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	before := time.Now().Add(-time.Second)
+	if err := ks.Put("foo", privKeyOrFatal(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	md, err := ks.Metadata("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if md == nil || md.Created.Before(before) || md.Created.After(time.Now()) {
+		t.Fatalf("unexpected metadata %v", md)
+	}
+
+	created := time.Date(2017, 6, 1, 0, 0, 0, 0, time.UTC)
+	if err := ks.SetMetadata("foo", &Metadata{Created: created}); err != nil {
+		t.Fatal(err)
+	}
+	md, err = ks.Metadata("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !md.Created.Equal(created) {
+		t.Fatalf("expected the key to be created at %s, got %s", created, md.Created)
+	}
+
+	if err := ks.Delete("foo"); err != nil {
+		t.Fatal(err)
+	}
+	if md, err := ks.Metadata("foo"); err != nil || md != nil {
+		t.Fatalf("expected the metadata to be removed with the key, got %v, %v", md, err)
+	}
+}
+
+func TestKeyType(t *testing.T) {
+	rsaKey, _, err := ci.GenerateKeyPairWithReader(ci.RSA, 1024, rr{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		k    ci.PrivKey
+		typ  string
+		bits int
+	}{
+		{rsaKey, "rsa", 1024},
+		{privKeyOrFatal(t), "ed25519", 256},
+	} {
+		typ, bits, err := KeyType(c.k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if typ != c.typ || bits != c.bits {
+			t.Fatalf("expected a %d bits %s key, got %d bits %s", c.bits, c.typ, bits, typ)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package keystore
+
+import (
+	"bytes"
+	"crypto/rand"
+
+	bip39 "github.com/ipfs/go-ipfs/thirdparty/bip39"
+
+	ci "gx/ipfs/QmP1DfoUjiWH2ZBo1PBH6FupdBucbDepx3HpWmEY6JMUpY/go-libp2p-crypto"
+)
+
+// The derivation paths of the keys derived from a mnemonic, by default. The
+// keys of the keystore are told apart by the last index of their paths.
+const (
+	IdentityDerivationPath = "m/44'/4001'/0'"
+	KeyDerivationPath      = "m/44'/4001'/1'"
+)
+
+// NewMnemonic returns a new BIP 39 mnemonic of 24 words, to derive keys from
+// with KeyFromMnemonic.
+func NewMnemonic() (string, error) {
+	entropy := make([]byte, 32)
+	if _, err := rand.Read(entropy); err != nil {
+		return "", err
+	}
+	return bip39.NewMnemonic(entropy)
+}
+
+// KeyFromMnemonic returns the ed25519 key derived from the seed of a BIP 39
+// mnemonic along path, as SLIP-0010 defines it. The same mnemonic and path
+// always give the same key.
+func KeyFromMnemonic(mnemonic, path string) (ci.PrivKey, error) {
+	seed, err := bip39.Seed(mnemonic, "")
+	if err != nil {
+		return nil, err
+	}
+	k, err := bip39.DeriveEd25519(seed, path)
+	if err != nil {
+		return nil, err
+	}
+
+	// an ed25519 key is generated from the 32 bytes it reads
+	sk, _, err := ci.GenerateEd25519Key(bytes.NewReader(k))
+	return sk, err
+}
@@ -0,0 +1,36 @@
+package keystore
+
+import (
+	"testing"
+)
+
+func TestKeyFromMnemonic(t *testing.T) {
+	m, err := NewMnemonic()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	k1, err := KeyFromMnemonic(m, KeyDerivationPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k2, err := KeyFromMnemonic(m, KeyDerivationPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !k1.Equals(k2) {
+		t.Fatal("expected the same mnemonic and path to derive the same key")
+	}
+
+	id, err := KeyFromMnemonic(m, IdentityDerivationPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id.Equals(k1) {
+		t.Fatal("expected different paths to derive different keys")
+	}
+
+	if _, err := KeyFromMnemonic(m+" abandon", KeyDerivationPath); err == nil {
+		t.Fatal("expected an invalid mnemonic to fail")
+	}
+}
@@ -0,0 +1,191 @@
+package keystore
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+
+	bcryptpbkdf "github.com/ipfs/go-ipfs/thirdparty/bcryptpbkdf"
+
+	ci "gx/ipfs/QmP1DfoUjiWH2ZBo1PBH6FupdBucbDepx3HpWmEY6JMUpY/go-libp2p-crypto"
+)
+
+// FormatOpenSSH is the private key format of OpenSSH, the one ssh-keygen
+// writes ed25519 keys in. Only ed25519 keys can be read from it.
+const FormatOpenSSH = "openssh"
+
+const (
+	pemOpenSSHPrivateKey = "OPENSSH PRIVATE KEY"
+	openSSHMagic         = "openssh-key-v1\x00"
+	openSSHEd25519       = "ssh-ed25519"
+)
+
+// ErrPassphraseRequired is returned for the encrypted keys read without a
+// passphrase.
+var ErrPassphraseRequired = errors.New("the key is encrypted with a passphrase")
+
+// IsOpenSSHKey returns whether b is a private key in the OpenSSH format.
+func IsOpenSSHKey(b []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(b), []byte("-----BEGIN "+pemOpenSSHPrivateKey))
+}
+
+// sshReader reads the data types of the SSH protocol (RFC 4251).
+type sshReader struct {
+	b   []byte
+	err error
+}
+
+func (r *sshReader) uint32() uint32 {
+	if r.err != nil {
+		return 0
+	}
+	if len(r.b) < 4 {
+		r.err = errors.New("truncated key")
+		return 0
+	}
+	v := binary.BigEndian.Uint32(r.b)
+	r.b = r.b[4:]
+	return v
+}
+
+func (r *sshReader) bytes() []byte {
+	n := r.uint32()
+	if r.err != nil {
+		return nil
+	}
+	if uint32(len(r.b)) < n {
+		r.err = errors.New("truncated key")
+		return nil
+	}
+	v := r.b[:n]
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *sshReader) string() string {
+	return string(r.bytes())
+}
+
+// UnmarshalOpenSSHKey decodes an ed25519 private key in the OpenSSH format.
+// The passphrase of an encrypted key is returned by passphrase, which is
+// only called then; ErrPassphraseRequired is returned if it is nil.
+func UnmarshalOpenSSHKey(b []byte, passphrase func() ([]byte, error)) (ci.PrivKey, error) {
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	if block.Type != pemOpenSSHPrivateKey {
+		return nil, fmt.Errorf("unsupported PEM block %q, expected %q", block.Type, pemOpenSSHPrivateKey)
+	}
+	if !bytes.HasPrefix(block.Bytes, []byte(openSSHMagic)) {
+		return nil, errors.New("invalid OpenSSH key")
+	}
+
+	r := &sshReader{b: block.Bytes[len(openSSHMagic):]}
+	cipherName := r.string()
+	kdfName := r.string()
+	kdfOptions := r.bytes()
+	count := r.uint32()
+	r.bytes() // the public key, repeated in the private section
+	private := r.bytes()
+	if r.err != nil {
+		return nil, r.err
+	}
+	if count != 1 {
+		return nil, fmt.Errorf("the file holds %d keys, expected one", count)
+	}
+
+	encrypted := cipherName != "none"
+	if encrypted {
+		if passphrase == nil {
+			return nil, ErrPassphraseRequired
+		}
+		pass, err := passphrase()
+		if err != nil {
+			return nil, err
+		}
+		private, err = decryptOpenSSHKey(private, cipherName, kdfName, kdfOptions, pass)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	r = &sshReader{b: private}
+	check1, check2 := r.uint32(), r.uint32()
+	if r.err == nil && check1 != check2 {
+		// the check words only match when the key is decrypted right
+		if encrypted {
+			return nil, errors.New("wrong passphrase")
+		}
+		return nil, errors.New("invalid OpenSSH key")
+	}
+	keyType := r.string()
+	if r.err == nil && keyType != openSSHEd25519 {
+		return nil, fmt.Errorf("unsupported key type %q, only %s keys can be imported", keyType, openSSHEd25519)
+	}
+	pub := r.bytes()
+	priv := r.bytes()
+	if r.err != nil {
+		return nil, r.err
+	}
+	if len(pub) != 32 || len(priv) != 64 || !bytes.Equal(priv[32:], pub) {
+		return nil, errors.New("invalid ed25519 key")
+	}
+
+	// the private key is the seed followed by the public key
+	sk, _, err := ci.GenerateEd25519Key(bytes.NewReader(priv[:32]))
+	return sk, err
+}
+
+// decryptOpenSSHKey decrypts the private section of an OpenSSH key, with the
+// key derived from pass by bcrypt_pbkdf.
+func decryptOpenSSHKey(private []byte, cipherName, kdfName string, kdfOptions, pass []byte) ([]byte, error) {
+	var keyLen int
+	switch cipherName {
+	case "aes128-ctr", "aes128-cbc":
+		keyLen = 16
+	case "aes192-ctr", "aes192-cbc":
+		keyLen = 24
+	case "aes256-ctr", "aes256-cbc":
+		keyLen = 32
+	default:
+		return nil, fmt.Errorf("unsupported key cipher %q", cipherName)
+	}
+	ctr := strings.HasSuffix(cipherName, "-ctr")
+
+	if kdfName != "bcrypt" {
+		return nil, fmt.Errorf("unsupported key derivation function %q", kdfName)
+	}
+	r := &sshReader{b: kdfOptions}
+	salt := r.bytes()
+	rounds := r.uint32()
+	if r.err != nil {
+		return nil, fmt.Errorf("invalid key derivation options: %s", r.err)
+	}
+
+	k, err := bcryptpbkdf.Key(pass, salt, int(rounds), keyLen+aes.BlockSize)
+	if err != nil {
+		return nil, err
+	}
+	c, err := aes.NewCipher(k[:keyLen])
+	if err != nil {
+		return nil, err
+	}
+	if len(private)%aes.BlockSize != 0 {
+		return nil, errors.New("invalid encrypted key")
+	}
+
+	out := make([]byte, len(private))
+	iv := k[keyLen:]
+	if ctr {
+		cipher.NewCTR(c, iv).XORKeyStream(out, private)
+	} else {
+		cipher.NewCBCDecrypter(c, iv).CryptBlocks(out, private)
+	}
+	return out, nil
+}
@@ -0,0 +1,148 @@
+package keystore
+
+import (
+	"fmt"
+	"strings"
+
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+// Uses of keys, which a Policy restricts.
+const (
+	// UsePublish is the publishing of IPNS records.
+	UsePublish = "publish"
+	// UseSign is the signing of data and proofs, with 'ipfs key sign' and
+	// 'ipfs key prove'.
+	UseSign = "sign"
+	// UseExport is the export of the key, with 'ipfs key export' and 'ipfs
+	// key backup'.
+	UseExport = "export"
+)
+
+// Uses are all the uses of keys.
+var Uses = []string{UsePublish, UseSign, UseExport}
+
+// Policy restricts what a key is used for, so that a caller of the API
+// can't use every key for every purpose.
+type Policy struct {
+	// Uses are the uses allowed; none is if it is empty.
+	Uses []string
+
+	// PublishesPerHour caps the IPNS records published with the key in an
+	// hour, if positive.
+	PublishesPerHour int `json:",omitempty"`
+}
+
+// PolicyError is returned for the uses of keys their policies forbid.
+type PolicyError struct {
+	Key string
+	Use string
+}
+
+func (e *PolicyError) Error() string {
+	return fmt.Sprintf("the policy of the key %s forbids the use %q, see 'ipfs key protect'", e.Key, e.Use)
+}
+
+// Validate checks the uses of p.
+func (p *Policy) Validate() error {
+	for _, use := range p.Uses {
+		if !p.known(use) {
+			return fmt.Errorf("unknown use %q, expected one of %s", use, strings.Join(Uses, ", "))
+		}
+	}
+	if p.PublishesPerHour < 0 {
+		return fmt.Errorf("the publishes an hour can't be negative")
+	}
+	return nil
+}
+
+func (p *Policy) known(use string) bool {
+	for _, u := range Uses {
+		if u == use {
+			return true
+		}
+	}
+	return false
+}
+
+// Allows tells whether p allows use.
+func (p *Policy) Allows(use string) bool {
+	for _, u := range p.Uses {
+		if u == use {
+			return true
+		}
+	}
+	return false
+}
+
+// KeyPolicy returns the policy of the key name of ks, or nil if it has none.
+func KeyPolicy(ks Keystore, name string) (*Policy, error) {
+	mks, ok := ks.(MetadataKeystore)
+	if !ok {
+		return nil, nil
+	}
+	md, err := mks.Metadata(name)
+	if err != nil || md == nil {
+		return nil, err
+	}
+	return md.Policy, nil
+}
+
+// CheckUse returns a PolicyError if the policy of the key name of ks forbids
+// use.
+func CheckUse(ks Keystore, name, use string) error {
+	p, err := KeyPolicy(ks, name)
+	if err != nil {
+		return err
+	}
+	if p != nil && !p.Allows(use) {
+		return &PolicyError{Key: name, Use: use}
+	}
+	return nil
+}
+
+// PolicyByID returns the policy of the key of ks whose ID is id, and the
+// name of the key, or a nil policy if it has none.
+func PolicyByID(ks Keystore, id peer.ID) (string, *Policy, error) {
+	name, md, err := MetadataByID(ks, id)
+	if err != nil || md == nil {
+		return name, nil, err
+	}
+	return name, md.Policy, nil
+}
+
+// MetadataByID returns the metadata of the key of ks whose ID is id, and the
+// name of the key, or nil metadata if it has none restricting it. Only the
+// keys with a policy or an expiry are read.
+func MetadataByID(ks Keystore, id peer.ID) (string, *Metadata, error) {
+	mks, ok := ks.(MetadataKeystore)
+	if !ok {
+		return "", nil, nil
+	}
+	names, err := ks.List()
+	if err != nil {
+		return "", nil, err
+	}
+
+	for _, name := range names {
+		md, err := mks.Metadata(name)
+		if err != nil {
+			return "", nil, err
+		}
+		if md == nil || (md.Policy == nil && md.Expires == nil) {
+			continue
+		}
+		sk, err := ks.Get(name)
+		if err != nil {
+			return "", nil, err
+		}
+		kid, err := peer.IDFromPrivateKey(sk)
+		if err != nil {
+			return "", nil, err
+		}
+		if kid == id {
+			return name, md, nil
+		}
+	}
+	return "", nil, nil
+}
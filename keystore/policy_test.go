@@ -0,0 +1,77 @@
+package keystore
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+func TestKeyPolicy(t *testing.T) {
+	tdir, err := ioutil.TempDir("", "keystore-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tdir)
+
+	ks, err := NewFSKeystore(tdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	site, signer := privKeyOrFatal(t), privKeyOrFatal(t)
+	if err := ks.Put("site", site); err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.Put("signer", signer); err != nil {
+		t.Fatal(err)
+	}
+
+	// the keys without a policy are allowed every use
+	for _, use := range Uses {
+		if err := CheckUse(ks, "site", use); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	md, err := ks.Metadata("signer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	md.Policy = &Policy{Uses: []string{UseSign}}
+	if err := ks.SetMetadata("signer", md); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := CheckUse(ks, "signer", UseSign); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := CheckUse(ks, "signer", UsePublish).(*PolicyError); !ok {
+		t.Fatal("expected publishing with a signing key to be refused")
+	}
+
+	id, err := peer.IDFromPrivateKey(signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	name, p, err := PolicyByID(ks, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "signer" || p == nil || !p.Allows(UseSign) || p.Allows(UseExport) {
+		t.Fatalf("unexpected policy %v of %q", p, name)
+	}
+
+	id, err = peer.IDFromPrivateKey(site)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, p, err := PolicyByID(ks, id); err != nil || p != nil {
+		t.Fatalf("expected no policy, got %v, %v", p, err)
+	}
+
+	if err := (&Policy{Uses: []string{"launch"}}).Validate(); err == nil {
+		t.Fatal("expected an unknown use to be invalid")
+	}
+}
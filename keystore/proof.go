@@ -0,0 +1,140 @@
+package keystore
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	ci "gx/ipfs/QmP1DfoUjiWH2ZBo1PBH6FupdBucbDepx3HpWmEY6JMUpY/go-libp2p-crypto"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+// ProofVersion is the version of the proofs made by NewProof.
+const ProofVersion = 1
+
+// Kinds of identities a key can be proven to belong to.
+const (
+	IdentityDNS = "dns"
+	IdentityURL = "url"
+)
+
+var ErrInvalidProof = errors.New("invalid key proof signature")
+
+// Proof is a statement, signed by a key, that the key belongs to an external
+// identity: a domain name or an http(s) URL. On its own it only shows that
+// the holder of the key claims the identity; the owner of the identity
+// confirms the claim by publishing the proof, see ParseIdentity.
+type Proof struct {
+	Version   int
+	Key       string
+	PublicKey []byte
+	Identity  string
+	Created   time.Time
+	Signature []byte
+}
+
+// ParseIdentity normalizes identity, and returns it along with its kind.
+// Domain names, such as "example.com", are confirmed by a TXT record of
+// "_ipfs-key.example.com" set to "ipfs-key=<key id>". URLs, such as
+// "https://example.com/.well-known/ipfs-key", are confirmed by serving the
+// proof itself.
+func ParseIdentity(identity string) (string, string, error) {
+	if strings.Contains(identity, "://") {
+		u, err := url.Parse(identity)
+		if err != nil {
+			return "", "", err
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return "", "", fmt.Errorf("unsupported identity scheme: %s", u.Scheme)
+		}
+		if u.Host == "" {
+			return "", "", fmt.Errorf("identity URL has no host: %s", identity)
+		}
+		return u.String(), IdentityURL, nil
+	}
+
+	domain := strings.TrimSuffix(strings.ToLower(identity), ".")
+	if domain == "" || strings.ContainsAny(domain, "/: ") || !strings.Contains(domain, ".") {
+		return "", "", fmt.Errorf("invalid domain name: %q", identity)
+	}
+	return domain, IdentityDNS, nil
+}
+
+// NewProof returns a proof, signed by sk, that sk belongs to identity.
+func NewProof(sk ci.PrivKey, identity string, created time.Time) (*Proof, error) {
+	identity, _, err := ParseIdentity(identity)
+	if err != nil {
+		return nil, err
+	}
+
+	pk := sk.GetPublic()
+	id, err := peer.IDFromPublicKey(pk)
+	if err != nil {
+		return nil, err
+	}
+	pkb, err := pk.Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Proof{
+		Version:   ProofVersion,
+		Key:       id.Pretty(),
+		PublicKey: pkb,
+		Identity:  identity,
+		Created:   created.UTC(),
+	}
+	p.Signature, err = sk.Sign(p.signedData())
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *Proof) signedData() []byte {
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "ipfs key proof\nversion: %d\nkey: %s\nidentity: %s\ncreated: %s\n",
+		p.Version, p.Key, p.Identity, p.Created.UTC().Format(time.RFC3339Nano))
+	return buf.Bytes()
+}
+
+// Verify checks that p was signed by the key it names, and returns the kind
+// of its identity. It does not check that the owner of the identity
+// confirmed it.
+func (p *Proof) Verify() (string, error) {
+	if p.Version != ProofVersion {
+		return "", fmt.Errorf("unsupported key proof version: %d", p.Version)
+	}
+
+	identity, kind, err := ParseIdentity(p.Identity)
+	if err != nil {
+		return "", err
+	}
+	if identity != p.Identity {
+		return "", fmt.Errorf("identity of the proof is not normalized: %s", p.Identity)
+	}
+
+	pk, err := ci.UnmarshalPublicKey(p.PublicKey)
+	if err != nil {
+		return "", err
+	}
+	id, err := peer.IDFromPublicKey(pk)
+	if err != nil {
+		return "", err
+	}
+	if id.Pretty() != p.Key {
+		return "", fmt.Errorf("public key of the proof is not the one of %s", p.Key)
+	}
+
+	ok, err := pk.Verify(p.signedData(), p.Signature)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", ErrInvalidProof
+	}
+	return kind, nil
+}
@@ -0,0 +1,73 @@
+package keystore
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestProof(t *testing.T) {
+	sk := privKeyOrFatal(t)
+
+	p, err := NewProof(sk, "Example.COM.", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Identity != "example.com" {
+		t.Fatalf("identity not normalized: %s", p.Identity)
+	}
+
+	// proofs are passed around as JSON
+	b, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Proof
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	kind, err := got.Verify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kind != IdentityDNS {
+		t.Fatalf("expected a dns identity, got %s", kind)
+	}
+
+	got.Identity = "example.org"
+	if _, err := got.Verify(); err != ErrInvalidProof {
+		t.Fatalf("expected ErrInvalidProof, got %v", err)
+	}
+
+	other, err := NewProof(privKeyOrFatal(t), "https://example.com/key", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	other.Key = p.Key
+	if _, err := other.Verify(); err == nil {
+		t.Fatal("proof with the key of another peer verified")
+	}
+}
+
+func TestParseIdentity(t *testing.T) {
+	for in, kind := range map[string]string{
+		"example.com":                         IdentityDNS,
+		"sub.example.com.":                    IdentityDNS,
+		"https://example.com/.well-known/key": IdentityURL,
+		"http://example.com":                  IdentityURL,
+	} {
+		_, k, err := ParseIdentity(in)
+		if err != nil {
+			t.Fatalf("%s: %s", in, err)
+		}
+		if k != kind {
+			t.Fatalf("%s: expected %s, got %s", in, kind, k)
+		}
+	}
+
+	for _, in := range []string{"", "localhost", "ftp://example.com", "https:///path", "example.com/path"} {
+		if _, _, err := ParseIdentity(in); err == nil {
+			t.Fatalf("%q: expected an error", in)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package keystore
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	ci "gx/ipfs/QmP1DfoUjiWH2ZBo1PBH6FupdBucbDepx3HpWmEY6JMUpY/go-libp2p-crypto"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+// signaturePrefix starts the envelopes of the signatures made by Sign, and
+// tells their version.
+const signaturePrefix = "ipfs-sig1"
+
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// Signature is the signature of a payload by a key, along with the public
+// key, so that it can be checked against the ID of the signer alone.
+type Signature struct {
+	PublicKey []byte
+	Signature []byte
+}
+
+// Sign signs the payload read from r with sk. Only the SHA2-256 digest of the
+// payload is signed, in a statement which can't be mistaken for the other
+// data signed by keys, such as IPNS records.
+func Sign(sk ci.PrivKey, r io.Reader) (*Signature, error) {
+	data, err := signatureData(r)
+	if err != nil {
+		return nil, err
+	}
+
+	pkb, err := sk.GetPublic().Bytes()
+	if err != nil {
+		return nil, err
+	}
+	sig, err := sk.Sign(data)
+	if err != nil {
+		return nil, err
+	}
+	return &Signature{PublicKey: pkb, Signature: sig}, nil
+}
+
+func signatureData(r io.Reader) ([]byte, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return nil, err
+	}
+	return []byte(fmt.Sprintf("ipfs key signature\nsha2-256: %s\n", hex.EncodeToString(h.Sum(nil)))), nil
+}
+
+// String returns the envelope of s: "ipfs-sig1.<public key>.<signature>",
+// both in unpadded base64url.
+func (s *Signature) String() string {
+	enc := base64.RawURLEncoding
+	return signaturePrefix + "." + enc.EncodeToString(s.PublicKey) + "." + enc.EncodeToString(s.Signature)
+}
+
+// ParseSignature reads the envelope of a signature, as returned by String.
+func ParseSignature(s string) (*Signature, error) {
+	parts := strings.Split(strings.TrimSpace(s), ".")
+	if len(parts) != 3 || parts[0] != signaturePrefix {
+		return nil, fmt.Errorf("not a signature envelope, expected %s.<public key>.<signature>", signaturePrefix)
+	}
+
+	enc := base64.RawURLEncoding
+	pkb, err := enc.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key in signature: %s", err)
+	}
+	sig, err := enc.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature: %s", err)
+	}
+	return &Signature{PublicKey: pkb, Signature: sig}, nil
+}
+
+// ID returns the ID of the key which made s.
+func (s *Signature) ID() (peer.ID, error) {
+	pk, err := ci.UnmarshalPublicKey(s.PublicKey)
+	if err != nil {
+		return "", err
+	}
+	return peer.IDFromPublicKey(pk)
+}
+
+// Verify checks that s is the signature by the key of id of the payload read
+// from r.
+func (s *Signature) Verify(id peer.ID, r io.Reader) error {
+	pk, err := ci.UnmarshalPublicKey(s.PublicKey)
+	if err != nil {
+		return err
+	}
+	if !id.MatchesPublicKey(pk) {
+		return fmt.Errorf("signature is not made by %s", id.Pretty())
+	}
+
+	data, err := signatureData(r)
+	if err != nil {
+		return err
+	}
+	ok, err := pk.Verify(data, s.Signature)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidSignature
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package keystore
+
+import (
+	"strings"
+	"testing"
+
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+func TestSignature(t *testing.T) {
+	sk := privKeyOrFatal(t)
+	id, err := peer.IDFromPrivateKey(sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := Sign(sk, strings.NewReader("hello world"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// signatures are passed around as their envelope
+	got, err := ParseSignature(s.String() + "\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := got.ID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signer != id {
+		t.Fatalf("expected the signature to be made by %s, got %s", id.Pretty(), signer.Pretty())
+	}
+	if err := got.Verify(id, strings.NewReader("hello world")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := got.Verify(id, strings.NewReader("hello world!")); err != ErrInvalidSignature {
+		t.Fatalf("expected ErrInvalidSignature, got %v", err)
+	}
+
+	other, err := peer.IDFromPrivateKey(privKeyOrFatal(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := got.Verify(other, strings.NewReader("hello world")); err == nil {
+		t.Fatal("signature verified against the ID of another peer")
+	}
+
+	for _, env := range []string{"", "ipfs-sig1.abc", "ipfs-sig2.abc.def", "ipfs-sig1.a+b.def"} {
+		if _, err := ParseSignature(env); err == nil {
+			t.Fatalf("expected %q not to parse", env)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package keystore
+
+import (
+	"errors"
+
+	ci "gx/ipfs/QmP1DfoUjiWH2ZBo1PBH6FupdBucbDepx3HpWmEY6JMUpY/go-libp2p-crypto"
+)
+
+// ErrKeyNotExportable is returned for the private keys which are kept in a
+// token, and can't be read from it.
+var ErrKeyNotExportable = errors.New("the private key is kept in a token and cannot be exported")
+
+// ErrKeyNotImportable is returned when a key is put in a keystore which
+// can only create its keys, with 'ipfs key gen'.
+var ErrKeyNotImportable = errors.New("keys cannot be imported into a token, generate them with 'ipfs key gen'")
+
+// GeneratingKeystore is a Keystore which creates its keys itself, as a
+// token, rather than storing the keys it is given.
+type GeneratingKeystore interface {
+	Keystore
+	// Generate creates a key of the given type, as named by 'ipfs key gen',
+	// and size in bits.
+	Generate(name, typ string, size int) (ci.PrivKey, error)
+}
+
+// signer signs the data it is given, without revealing the key.
+type signer interface {
+	sign(data []byte) ([]byte, error)
+}
+
+// signerKey is a private key which never leaves a token: signing is
+// delegated to the token, and the key can't be marshaled.
+type signerKey struct {
+	pub ci.PubKey
+	s   signer
+}
+
+var _ ci.PrivKey = (*signerKey)(nil)
+
+// Bytes returns ErrKeyNotExportable.
+func (k *signerKey) Bytes() ([]byte, error) {
+	return nil, ErrKeyNotExportable
+}
+
+// Equals reports whether o is a key of the same public key.
+func (k *signerKey) Equals(o ci.Key) bool {
+	sk, ok := o.(ci.PrivKey)
+	if !ok {
+		return false
+	}
+	return k.pub.Equals(sk.GetPublic())
+}
+
+// Sign signs data with the key in the token.
+func (k *signerKey) Sign(data []byte) ([]byte, error) {
+	return k.s.sign(data)
+}
+
+// GetPublic returns the public key.
+func (k *signerKey) GetPublic() ci.PubKey {
+	return k.pub
+}
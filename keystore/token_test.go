@@ -0,0 +1,57 @@
+package keystore
+
+import (
+	"crypto/rand"
+	"testing"
+
+	ci "gx/ipfs/QmP1DfoUjiWH2ZBo1PBH6FupdBucbDepx3HpWmEY6JMUpY/go-libp2p-crypto"
+)
+
+// keySigner signs with a key in memory, as a token would.
+type keySigner struct {
+	sk ci.PrivKey
+}
+
+func (s keySigner) sign(data []byte) ([]byte, error) {
+	return s.sk.Sign(data)
+}
+
+func TestSignerKey(t *testing.T) {
+	sk, pk, err := ci.GenerateKeyPairWithReader(ci.RSA, 1024, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k := &signerKey{pub: pk, s: keySigner{sk}}
+
+	if _, err := k.Bytes(); err != ErrKeyNotExportable {
+		t.Fatalf("expected ErrKeyNotExportable, got %v", err)
+	}
+	if _, err := MarshalKey(k, FormatPEM); err != ErrKeyNotExportable {
+		t.Fatalf("expected ErrKeyNotExportable, got %v", err)
+	}
+
+	data := []byte("some data")
+	sig, err := k.Sign(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ok, err := pk.Verify(data, sig)
+	if err != nil || !ok {
+		t.Fatalf("the signature is not valid: %v", err)
+	}
+
+	if !k.Equals(sk) {
+		t.Fatal("the key should equal the key it signs with")
+	}
+	if k.Equals(privKeyOrFatal(t)) {
+		t.Fatal("the key should not equal another key")
+	}
+
+	typ, size, err := KeyType(k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if typ != "rsa" || size != 1024 {
+		t.Fatalf("expected a 1024 bits rsa key, got %d bits %s", size, typ)
+	}
+}
@@ -0,0 +1,575 @@
+package keystore
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	ci "gx/ipfs/QmP1DfoUjiWH2ZBo1PBH6FupdBucbDepx3HpWmEY6JMUpY/go-libp2p-crypto"
+	pb "gx/ipfs/QmP1DfoUjiWH2ZBo1PBH6FupdBucbDepx3HpWmEY6JMUpY/go-libp2p-crypto/pb"
+	logging "gx/ipfs/QmSpJByNKFX1sCsHBEp3R73FL4NF6FnQTEGyNAXHm2GS52/go-log"
+	proto "gx/ipfs/QmZ4Qi3GaRbjcx28Sme5eMH7RQjGkt8wHxt2a65oLaeFEV/gogo-protobuf/proto"
+)
+
+var log = logging.Logger("keystore")
+
+// Engines of Vault a VaultConfig can keep the keys in.
+const (
+	// VaultKV stores the keys as secrets of a KV version 2 mount.
+	VaultKV = "kv"
+	// VaultTransit creates the keys in a transit mount, which signs with
+	// them.
+	VaultTransit = "transit"
+)
+
+// DefaultVaultCacheTTL is how long the keys read from Vault are kept in
+// memory by default.
+const DefaultVaultCacheTTL = 5 * time.Minute
+
+// VaultConfig locates the keys of a keystore in HashiCorp Vault.
+type VaultConfig struct {
+	Address string // such as https://vault:8200
+	Token   string
+	Engine  string // VaultKV or VaultTransit
+	Mount   string // the path the engine is mounted at
+	// Path is where the keys are under a KV mount.
+	Path string
+	// CacheTTL is how long the keys read are kept in memory. While Vault
+	// can't be reached, the keys read last are used past it.
+	CacheTTL time.Duration
+	// Client makes the requests, http.DefaultClient if nil.
+	Client *http.Client
+}
+
+// NewVaultKeystore returns the keystore of the keys of cfg, after checking
+// that Vault is unsealed and can be reached.
+func NewVaultKeystore(cfg VaultConfig) (Keystore, error) {
+	c := &vaultClient{
+		addr:   strings.TrimSuffix(cfg.Address, "/"),
+		token:  cfg.Token,
+		client: cfg.Client,
+	}
+	if c.client == nil {
+		c.client = http.DefaultClient
+	}
+	if err := c.Health(); err != nil {
+		return nil, err
+	}
+
+	ttl := cfg.CacheTTL
+	if ttl == 0 {
+		ttl = DefaultVaultCacheTTL
+	}
+	cache := &keyCache{ttl: ttl, keys: make(map[string]cachedKey)}
+	mount := strings.Trim(cfg.Mount, "/")
+
+	switch cfg.Engine {
+	case VaultKV:
+		return &VaultKVKeystore{
+			c:     c,
+			mount: mount,
+			path:  strings.Trim(cfg.Path, "/"),
+			cache: cache,
+		}, nil
+	case VaultTransit:
+		return &VaultTransitKeystore{c: c, mount: mount, cache: cache}, nil
+	default:
+		return nil, fmt.Errorf("unknown Vault engine: %s", cfg.Engine)
+	}
+}
+
+// vaultClient makes the requests of the Vault HTTP API.
+type vaultClient struct {
+	addr   string
+	token  string
+	client *http.Client
+}
+
+// vaultError is an error returned by Vault.
+type vaultError struct {
+	Status int
+	Errors []string
+}
+
+func (e *vaultError) Error() string {
+	if len(e.Errors) == 0 {
+		return fmt.Sprintf("vault: status %d", e.Status)
+	}
+	return "vault: " + strings.Join(e.Errors, ", ")
+}
+
+func isVaultNotFound(err error) bool {
+	verr, ok := err.(*vaultError)
+	return ok && verr.Status == http.StatusNotFound
+}
+
+// do sends the request method to the API path, with in marshaled as JSON
+// if not nil, and decodes the data of the response into out if not nil.
+func (c *vaultClient) do(method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequest(method, c.addr+"/v1/"+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Vault-Token", c.token)
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		verr := &vaultError{Status: resp.StatusCode}
+		json.NewDecoder(resp.Body).Decode(verr)
+		return verr
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(&struct {
+		Data interface{} `json:"data"`
+	}{out})
+}
+
+// Health returns an error unless Vault is initialized, unsealed, and
+// answers as the active node or a standby.
+func (c *vaultClient) Health() error {
+	resp, err := c.client.Get(c.addr + "/v1/sys/health?standbyok=true")
+	if err != nil {
+		return fmt.Errorf("vault cannot be reached: %s", err)
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusNotImplemented:
+		return fmt.Errorf("vault at %s is not initialized", c.addr)
+	case http.StatusServiceUnavailable:
+		return fmt.Errorf("vault at %s is sealed", c.addr)
+	default:
+		return fmt.Errorf("vault at %s is unhealthy: status %d", c.addr, resp.StatusCode)
+	}
+}
+
+// list returns the names of the keys listed at path, without the folders.
+func (c *vaultClient) list(path string) ([]string, error) {
+	var data struct {
+		Keys []string `json:"keys"`
+	}
+	err := c.do("LIST", path, nil, &data)
+	if isVaultNotFound(err) {
+		// nothing is listed at path
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]string, 0, len(data.Keys))
+	for _, k := range data.Keys {
+		// keys made by other programs may have names we can't use
+		if validateName(k) == nil {
+			list = append(list, k)
+		}
+	}
+	return list, nil
+}
+
+// keyCache keeps the keys read from Vault, so that a busy node doesn't ask
+// Vault for every signature, and keeps working while Vault can't be reached.
+type keyCache struct {
+	ttl  time.Duration
+	mu   sync.Mutex
+	keys map[string]cachedKey
+}
+
+type cachedKey struct {
+	key  ci.PrivKey
+	read time.Time
+}
+
+// get returns the key cached as name, if it was read within the TTL.
+func (kc *keyCache) get(name string) ci.PrivKey {
+	kc.mu.Lock()
+	defer kc.mu.Unlock()
+	ck, ok := kc.keys[name]
+	if !ok || time.Since(ck.read) > kc.ttl {
+		return nil
+	}
+	return ck.key
+}
+
+// load returns the key cached as name, or reads it with read. A key read
+// earlier is returned when read fails for another reason than the key
+// missing, as when Vault can't be reached.
+func (kc *keyCache) load(name string, read func() (ci.PrivKey, error)) (ci.PrivKey, error) {
+	if k := kc.get(name); k != nil {
+		return k, nil
+	}
+
+	k, err := read()
+	kc.mu.Lock()
+	defer kc.mu.Unlock()
+	switch {
+	case err == nil:
+		kc.keys[name] = cachedKey{key: k, read: time.Now()}
+		return k, nil
+	case err == ErrNoSuchKey:
+		delete(kc.keys, name)
+		return nil, err
+	default:
+		ck, ok := kc.keys[name]
+		if !ok {
+			return nil, err
+		}
+		log.Warningf("using the cached key %s, as it cannot be read from vault: %s", name, err)
+		return ck.key, nil
+	}
+}
+
+func (kc *keyCache) drop(name string) {
+	kc.mu.Lock()
+	defer kc.mu.Unlock()
+	delete(kc.keys, name)
+}
+
+// VaultKVKeystore is a Keystore whose keys are kept as secrets of a Vault KV
+// version 2 mount, and only held in memory by the node.
+type VaultKVKeystore struct {
+	c     *vaultClient
+	mount string
+	path  string
+	cache *keyCache
+}
+
+// kvSecret is the secret a key is stored as.
+type kvSecret struct {
+	Key string `json:"key"` // the marshaled key, in base64
+}
+
+func (ks *VaultKVKeystore) secretPath(kind, name string) string {
+	p := ks.mount + "/" + kind
+	if ks.path != "" {
+		p += "/" + ks.path
+	}
+	if name != "" {
+		p += "/" + url.PathEscape(name)
+	}
+	return p
+}
+
+// Has return whether or not a key exist in the Keystore
+func (ks *VaultKVKeystore) Has(name string) (bool, error) {
+	_, err := ks.Get(name)
+	switch err {
+	case nil:
+		return true, nil
+	case ErrNoSuchKey:
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+// Put store a key in the Keystore
+func (ks *VaultKVKeystore) Put(name string, k ci.PrivKey) error {
+	if err := validateName(name); err != nil {
+		return err
+	}
+
+	b, err := k.Bytes()
+	if err != nil {
+		return err
+	}
+
+	// cas 0 only writes the secret if it does not exist
+	err = ks.c.do("POST", ks.secretPath("data", name), map[string]interface{}{
+		"data":    kvSecret{Key: base64.StdEncoding.EncodeToString(b)},
+		"options": map[string]int{"cas": 0},
+	}, nil)
+	if verr, ok := err.(*vaultError); ok && verr.Status == http.StatusBadRequest {
+		if has, herr := ks.Has(name); herr == nil && has {
+			return ErrKeyExists
+		}
+	}
+	return err
+}
+
+// Get retrieve a key from the Keystore
+func (ks *VaultKVKeystore) Get(name string) (ci.PrivKey, error) {
+	if err := validateName(name); err != nil {
+		return nil, err
+	}
+
+	return ks.cache.load(name, func() (ci.PrivKey, error) {
+		var data struct {
+			Data *kvSecret `json:"data"`
+		}
+		err := ks.c.do("GET", ks.secretPath("data", name), nil, &data)
+		if isVaultNotFound(err) {
+			return nil, ErrNoSuchKey
+		}
+		if err != nil {
+			return nil, err
+		}
+		// the last version of a deleted secret has no data
+		if data.Data == nil {
+			return nil, ErrNoSuchKey
+		}
+
+		b, err := base64.StdEncoding.DecodeString(data.Data.Key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %s in vault: %s", name, err)
+		}
+		return ci.UnmarshalPrivateKey(b)
+	})
+}
+
+// Delete remove a key from the Keystore
+func (ks *VaultKVKeystore) Delete(name string) error {
+	if err := validateName(name); err != nil {
+		return err
+	}
+	has, err := ks.Has(name)
+	if err != nil {
+		return err
+	}
+	if !has {
+		return ErrNoSuchKey
+	}
+
+	// the metadata goes with every version of the secret
+	ks.cache.drop(name)
+	return ks.c.do("DELETE", ks.secretPath("metadata", name), nil, nil)
+}
+
+// List return a list of key identifier
+func (ks *VaultKVKeystore) List() ([]string, error) {
+	return ks.c.list(ks.secretPath("metadata", ""))
+}
+
+// VaultTransitKeystore is a Keystore whose keys are created in a Vault
+// transit mount, and never leave it: the keys it returns sign with Vault.
+// The keys must be deletable, as 'ipfs key rm' makes them.
+type VaultTransitKeystore struct {
+	c     *vaultClient
+	mount string
+	cache *keyCache
+}
+
+var _ GeneratingKeystore = (*VaultTransitKeystore)(nil)
+
+func (ks *VaultTransitKeystore) keyPath(kind, name string) string {
+	return ks.mount + "/" + kind + "/" + url.PathEscape(name)
+}
+
+// Has return whether or not a key exist in the Keystore
+func (ks *VaultTransitKeystore) Has(name string) (bool, error) {
+	_, err := ks.Get(name)
+	switch err {
+	case nil:
+		return true, nil
+	case ErrNoSuchKey:
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+// Put returns ErrKeyNotImportable: the keys of a transit mount are created
+// in it.
+func (ks *VaultTransitKeystore) Put(name string, k ci.PrivKey) error {
+	return ErrKeyNotImportable
+}
+
+// transitKey is a key of a transit mount, as read.
+type transitKey struct {
+	Type          string `json:"type"`
+	LatestVersion int    `json:"latest_version"`
+	Keys          map[string]struct {
+		PublicKey string `json:"public_key"`
+	} `json:"keys"`
+}
+
+// Get returns a key which signs with Vault.
+func (ks *VaultTransitKeystore) Get(name string) (ci.PrivKey, error) {
+	if err := validateName(name); err != nil {
+		return nil, err
+	}
+
+	return ks.cache.load(name, func() (ci.PrivKey, error) {
+		var tk transitKey
+		err := ks.c.do("GET", ks.keyPath("keys", name), nil, &tk)
+		if isVaultNotFound(err) {
+			return nil, ErrNoSuchKey
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		pk, err := transitPublicKey(&tk)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read the public key %s: %s", name, err)
+		}
+		s := &transitSigner{ks: ks, name: name, rsa: strings.HasPrefix(tk.Type, "rsa-")}
+		return &signerKey{pub: pk, s: s}, nil
+	})
+}
+
+// transitPublicKey returns the public key of the latest version of tk: raw
+// in base64 for ed25519, in PEM for RSA.
+func transitPublicKey(tk *transitKey) (ci.PubKey, error) {
+	v, ok := tk.Keys[fmt.Sprint(tk.LatestVersion)]
+	if !ok {
+		return nil, fmt.Errorf("no version %d", tk.LatestVersion)
+	}
+
+	var pbk pb.PublicKey
+	switch {
+	case tk.Type == "ed25519":
+		raw, err := base64.StdEncoding.DecodeString(v.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		pbk = pb.PublicKey{Type: pb.KeyType_Ed25519.Enum(), Data: raw}
+	case strings.HasPrefix(tk.Type, "rsa-"):
+		block, _ := pem.Decode([]byte(v.PublicKey))
+		if block == nil {
+			return nil, fmt.Errorf("invalid PEM public key")
+		}
+		pbk = pb.PublicKey{Type: pb.KeyType_RSA.Enum(), Data: block.Bytes}
+	default:
+		return nil, fmt.Errorf("keys of type %s are not supported", tk.Type)
+	}
+
+	b, err := proto.Marshal(&pbk)
+	if err != nil {
+		return nil, err
+	}
+	return ci.UnmarshalPublicKey(b)
+}
+
+// Generate creates a key of the type, ed25519 or rsa, and size in bits, in
+// the transit mount.
+func (ks *VaultTransitKeystore) Generate(name, typ string, size int) (ci.PrivKey, error) {
+	if err := validateName(name); err != nil {
+		return nil, err
+	}
+
+	var vtype string
+	switch typ {
+	case "ed25519":
+		vtype = "ed25519"
+	case "rsa":
+		switch size {
+		case 2048, 3072, 4096:
+			vtype = fmt.Sprintf("rsa-%d", size)
+		default:
+			return nil, fmt.Errorf("vault makes rsa keys of 2048, 3072 or 4096 bits, not %d", size)
+		}
+	default:
+		return nil, fmt.Errorf("only ed25519 and rsa keys can be generated in vault")
+	}
+
+	// creating a key which exists leaves it as it is
+	has, err := ks.Has(name)
+	if err != nil {
+		return nil, err
+	}
+	if has {
+		return nil, ErrKeyExists
+	}
+
+	err = ks.c.do("POST", ks.keyPath("keys", name), map[string]interface{}{
+		"type":       vtype,
+		"exportable": false,
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = ks.c.do("POST", ks.keyPath("keys", name)+"/config", map[string]interface{}{
+		"deletion_allowed": true,
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	ks.cache.drop(name)
+	return ks.Get(name)
+}
+
+// Delete destroys the key in the transit mount.
+func (ks *VaultTransitKeystore) Delete(name string) error {
+	if err := validateName(name); err != nil {
+		return err
+	}
+	has, err := ks.Has(name)
+	if err != nil {
+		return err
+	}
+	if !has {
+		return ErrNoSuchKey
+	}
+
+	ks.cache.drop(name)
+	return ks.c.do("DELETE", ks.keyPath("keys", name), nil, nil)
+}
+
+// List return a list of key identifier
+func (ks *VaultTransitKeystore) List() ([]string, error) {
+	return ks.c.list(ks.mount + "/keys")
+}
+
+// transitSigner signs with a key of a transit mount.
+type transitSigner struct {
+	ks   *VaultTransitKeystore
+	name string
+	rsa  bool
+}
+
+// sign signs data as libp2p keys do: ed25519 over the data itself, RSA with
+// PKCS #1 v1.5 over SHA-256.
+func (s *transitSigner) sign(data []byte) ([]byte, error) {
+	in := map[string]interface{}{
+		"input": base64.StdEncoding.EncodeToString(data),
+	}
+	if s.rsa {
+		in["hash_algorithm"] = "sha2-256"
+		in["signature_algorithm"] = "pkcs1v15"
+	}
+
+	var out struct {
+		Signature string `json:"signature"`
+	}
+	if err := s.ks.c.do("POST", s.ks.keyPath("sign", s.name), in, &out); err != nil {
+		return nil, err
+	}
+
+	// signatures are written vault:v<version>:<base64>
+	parts := strings.SplitN(out.Signature, ":", 3)
+	if len(parts) != 3 || parts[0] != "vault" {
+		return nil, fmt.Errorf("invalid signature from vault: %q", out.Signature)
+	}
+	return base64.StdEncoding.DecodeString(parts[2])
+}
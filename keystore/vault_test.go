@@ -0,0 +1,292 @@
+package keystore
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	ci "gx/ipfs/QmP1DfoUjiWH2ZBo1PBH6FupdBucbDepx3HpWmEY6JMUpY/go-libp2p-crypto"
+	pb "gx/ipfs/QmP1DfoUjiWH2ZBo1PBH6FupdBucbDepx3HpWmEY6JMUpY/go-libp2p-crypto/pb"
+	proto "gx/ipfs/QmZ4Qi3GaRbjcx28Sme5eMH7RQjGkt8wHxt2a65oLaeFEV/gogo-protobuf/proto"
+)
+
+// fakeVault answers as much of the API of Vault as the keystores use, with a
+// KV version 2 mount at kv and a transit mount at transit.
+type fakeVault struct {
+	mu      sync.Mutex
+	sealed  bool
+	secrets map[string]json.RawMessage
+	transit map[string]ci.PrivKey
+}
+
+func newFakeVault() *fakeVault {
+	return &fakeVault{
+		secrets: make(map[string]json.RawMessage),
+		transit: make(map[string]ci.PrivKey),
+	}
+}
+
+func (v *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if r.URL.Path == "/v1/sys/health" {
+		if v.sealed {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		return
+	}
+	if r.Header.Get("X-Vault-Token") != "token" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	var in map[string]json.RawMessage
+	json.NewDecoder(r.Body).Decode(&in)
+	reply := func(data interface{}) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+	}
+
+	p := strings.TrimPrefix(r.URL.Path, "/v1/")
+	switch {
+	case strings.HasPrefix(p, "kv/data/ipfs/"):
+		name := strings.TrimPrefix(p, "kv/data/ipfs/")
+		switch r.Method {
+		case "POST":
+			if _, ok := v.secrets[name]; ok {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			v.secrets[name] = in["data"]
+		case "GET":
+			s, ok := v.secrets[name]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			reply(map[string]json.RawMessage{"data": s})
+		}
+	case p == "kv/metadata/ipfs" && r.Method == "LIST":
+		v.list(w, len(v.secrets), func(add func(string)) {
+			for name := range v.secrets {
+				add(name)
+			}
+		})
+	case strings.HasPrefix(p, "kv/metadata/ipfs/") && r.Method == "DELETE":
+		delete(v.secrets, strings.TrimPrefix(p, "kv/metadata/ipfs/"))
+		w.WriteHeader(http.StatusNoContent)
+	case p == "transit/keys" && r.Method == "LIST":
+		v.list(w, len(v.transit), func(add func(string)) {
+			for name := range v.transit {
+				add(name)
+			}
+		})
+	case strings.HasPrefix(p, "transit/keys/"):
+		name := strings.TrimPrefix(p, "transit/keys/")
+		switch {
+		case strings.HasSuffix(name, "/config"):
+		case r.Method == "POST":
+			sk, _, err := ci.GenerateEd25519Key(rand.Reader)
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			v.transit[name] = sk
+		case r.Method == "DELETE":
+			delete(v.transit, name)
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == "GET":
+			sk, ok := v.transit[name]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			b, _ := sk.GetPublic().Bytes()
+			var pbk pb.PublicKey
+			proto.Unmarshal(b, &pbk)
+			reply(map[string]interface{}{
+				"type":           "ed25519",
+				"latest_version": 1,
+				"keys": map[string]interface{}{
+					"1": map[string]string{"public_key": base64.StdEncoding.EncodeToString(pbk.Data)},
+				},
+			})
+		}
+	case strings.HasPrefix(p, "transit/sign/"):
+		sk, ok := v.transit[strings.TrimPrefix(p, "transit/sign/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var input string
+		json.Unmarshal(in["input"], &input)
+		data, _ := base64.StdEncoding.DecodeString(input)
+		sig, _ := sk.Sign(data)
+		reply(map[string]string{"signature": "vault:v1:" + base64.StdEncoding.EncodeToString(sig)})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (v *fakeVault) list(w http.ResponseWriter, n int, each func(func(string))) {
+	if n == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	keys := []string{}
+	each(func(name string) { keys = append(keys, name) })
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data": map[string][]string{"keys": keys},
+	})
+}
+
+func TestVaultHealth(t *testing.T) {
+	v := newFakeVault()
+	v.sealed = true
+	srv := httptest.NewServer(v)
+	defer srv.Close()
+
+	_, err := NewVaultKeystore(VaultConfig{Address: srv.URL, Token: "token", Engine: VaultKV, Mount: "kv"})
+	if err == nil || !strings.Contains(err.Error(), "sealed") {
+		t.Fatalf("expected a sealed vault to fail, got %v", err)
+	}
+}
+
+func TestVaultKVKeystore(t *testing.T) {
+	v := newFakeVault()
+	srv := httptest.NewServer(v)
+	defer srv.Close()
+
+	ks, err := NewVaultKeystore(VaultConfig{
+		Address: srv.URL,
+		Token:   "token",
+		Engine:  VaultKV,
+		Mount:   "kv",
+		Path:    "ipfs",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if l, err := ks.List(); err != nil || len(l) != 0 {
+		t.Fatalf("expected no keys, got %v, %v", l, err)
+	}
+
+	a, _, err := ci.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _, err := ci.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.Put("a", a); err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.Put("b", b); err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.Put("a", b); err != ErrKeyExists {
+		t.Fatalf("expected ErrKeyExists, got %v", err)
+	}
+
+	k, err := ks.Get("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !k.Equals(a) {
+		t.Fatal("the key read is not the key stored")
+	}
+
+	// the key read is cached, and used while vault can't be reached
+	srv.Close()
+	if k, err := ks.Get("a"); err != nil || !k.Equals(a) {
+		t.Fatalf("expected the cached key, got %v", err)
+	}
+	srv2 := httptest.NewServer(v)
+	defer srv2.Close()
+	ks.(*VaultKVKeystore).c.addr = srv2.URL
+
+	l, err := ks.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(l)
+	if len(l) != 2 || l[0] != "a" || l[1] != "b" {
+		t.Fatalf("expected the keys a and b, got %v", l)
+	}
+
+	if err := ks.Delete("a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ks.Get("a"); err != ErrNoSuchKey {
+		t.Fatalf("expected ErrNoSuchKey, got %v", err)
+	}
+	if err := ks.Delete("a"); err != ErrNoSuchKey {
+		t.Fatalf("expected ErrNoSuchKey, got %v", err)
+	}
+}
+
+func TestVaultTransitKeystore(t *testing.T) {
+	srv := httptest.NewServer(newFakeVault())
+	defer srv.Close()
+
+	ks, err := NewVaultKeystore(VaultConfig{
+		Address: srv.URL,
+		Token:   "token",
+		Engine:  VaultTransit,
+		Mount:   "transit",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gks := ks.(GeneratingKeystore)
+
+	sk, _, err := ci.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.Put("a", sk); err != ErrKeyNotImportable {
+		t.Fatalf("expected ErrKeyNotImportable, got %v", err)
+	}
+	if _, err := gks.Generate("a", "rsa", 1024); err == nil {
+		t.Fatal("expected a 1024 bits rsa key to be refused")
+	}
+
+	k, err := gks.Generate("a", "ed25519", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gks.Generate("a", "ed25519", 0); err != ErrKeyExists {
+		t.Fatalf("expected ErrKeyExists, got %v", err)
+	}
+	if _, err := k.Bytes(); err != ErrKeyNotExportable {
+		t.Fatalf("expected ErrKeyNotExportable, got %v", err)
+	}
+
+	data := []byte("some data")
+	sig, err := k.Sign(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ok, err := k.GetPublic().Verify(data, sig)
+	if err != nil || !ok {
+		t.Fatalf("the signature of vault is not valid: %v", err)
+	}
+
+	if l, err := ks.List(); err != nil || len(l) != 1 || l[0] != "a" {
+		t.Fatalf("expected the key a, got %v, %v", l, err)
+	}
+	if err := ks.Delete("a"); err != nil {
+		t.Fatal(err)
+	}
+	if has, err := ks.Has("a"); err != nil || has {
+		t.Fatalf("expected the key a to be deleted, got %v, %v", has, err)
+	}
+}
@@ -68,12 +68,13 @@ func (n *dagService) Add(nd node.Node) (*cid.Cid, error) {
 func (n *dagService) Batch() *Batch {
 	return &Batch{
 		ds:      n,
-		MaxSize: 8 << 20,
+		MaxSize: 32 << 20,
 
-		// By default, only batch up to 128 nodes at a time.
-		// The current implementation of flatfs opens this many file
-		// descriptors at the same time for the optimized batch write.
-		MaxBlocks: 128,
+		// The blocks of a batch are synced to disk together, the larger
+		// the batch the fewer syncs. The repo writes them to flatfs, which
+		// opens a file descriptor per block of its batches, in smaller
+		// batches.
+		MaxBlocks: 1024,
 	}
 }
 
@@ -0,0 +1,127 @@
+package merkledag
+
+import (
+	"context"
+	"sync"
+
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+)
+
+// WalkItem is a node for WalkConcurrent to walk.
+type WalkItem struct {
+	Cid *cid.Cid
+	// Path and Depth are where the node was reached from, for the walks
+	// which keep track of them.
+	Path  string
+	Depth int
+}
+
+// WalkStep walks item, and returns the items under it to walk next.
+type WalkStep func(ctx context.Context, item WalkItem) ([]WalkItem, error)
+
+// WalkConcurrent walks roots, and the items step returns under them, with
+// workers goroutines which take the items to walk from a shared stack, so
+// that the items waiting to be walked stay bounded by the depth of the dags
+// rather than by their width. With a single worker, the items are walked
+// depth first, in the order step returns them.
+//
+// step is called concurrently, with a context cancelled once the walk stops.
+// The walk stops at the first error step returns, which it returns.
+func WalkConcurrent(ctx context.Context, roots []WalkItem, workers int, step WalkStep) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	w := &walker{ctx: ctx, cancel: cancel, step: step}
+	w.cond = sync.NewCond(&w.lk)
+	w.push(roots)
+
+	if workers < 1 {
+		workers = 1
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.work()
+		}()
+	}
+
+	// wake the workers up should ctx be cancelled while they wait
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			w.lk.Lock()
+			w.fail(ctx.Err())
+			w.lk.Unlock()
+		case <-done:
+		}
+	}()
+
+	wg.Wait()
+	close(done)
+	return w.err
+}
+
+type walker struct {
+	ctx    context.Context
+	cancel func()
+	step   WalkStep
+
+	lk     sync.Mutex
+	cond   *sync.Cond
+	stack  []WalkItem
+	active int
+	err    error
+}
+
+// push adds items to the stack backwards, so that the first one is walked
+// first. w.lk must be held.
+func (w *walker) push(items []WalkItem) {
+	for i := len(items) - 1; i >= 0; i-- {
+		w.stack = append(w.stack, items[i])
+	}
+}
+
+// fail stops the walk with err, unless it stopped already. w.lk must be
+// held.
+func (w *walker) fail(err error) {
+	if w.err == nil {
+		w.err = err
+		w.cancel()
+	}
+	w.cond.Broadcast()
+}
+
+func (w *walker) work() {
+	for {
+		w.lk.Lock()
+		for len(w.stack) == 0 && w.active > 0 && w.err == nil {
+			w.cond.Wait()
+		}
+		if len(w.stack) == 0 || w.err != nil {
+			// nothing left, or given up: let the other workers know
+			w.cond.Broadcast()
+			w.lk.Unlock()
+			return
+		}
+		it := w.stack[len(w.stack)-1]
+		w.stack = w.stack[:len(w.stack)-1]
+		w.active++
+		w.lk.Unlock()
+
+		next, err := w.step(w.ctx, it)
+
+		w.lk.Lock()
+		w.active--
+		if err != nil {
+			w.fail(err)
+		} else {
+			w.push(next)
+			w.cond.Broadcast()
+		}
+		w.lk.Unlock()
+	}
+}
@@ -0,0 +1,71 @@
+package merkledag_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	. "github.com/ipfs/go-ipfs/merkledag"
+
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+)
+
+func TestWalkConcurrent(t *testing.T) {
+	// a binary tree of 15 items, numbered breadth first from 1
+	cids := make(map[string]int)
+	cidOf := make([]*cid.Cid, 16)
+	for i := 1; i <= 15; i++ {
+		cidOf[i] = NewRawNode([]byte{byte(i)}).Cid()
+		cids[cidOf[i].KeyString()] = i
+	}
+	root := WalkItem{Cid: cidOf[1]}
+
+	var lk sync.Mutex
+	var walked []int
+	step := func(ctx context.Context, it WalkItem) ([]WalkItem, error) {
+		i := cids[it.Cid.KeyString()]
+		lk.Lock()
+		walked = append(walked, i)
+		lk.Unlock()
+		if it.Depth == 3 {
+			return nil, nil
+		}
+		return []WalkItem{
+			{Cid: cidOf[2*i], Depth: it.Depth + 1},
+			{Cid: cidOf[2*i+1], Depth: it.Depth + 1},
+		}, nil
+	}
+
+	if err := WalkConcurrent(context.Background(), []WalkItem{root}, 1, step); err != nil {
+		t.Fatal(err)
+	}
+	expected := []int{1, 2, 4, 8, 9, 5, 10, 11, 3, 6, 12, 13, 7, 14, 15}
+	if len(walked) != len(expected) {
+		t.Fatalf("expected a depth first walk %v, got %v", expected, walked)
+	}
+	for i := range expected {
+		if walked[i] != expected[i] {
+			t.Fatalf("expected a depth first walk %v, got %v", expected, walked)
+		}
+	}
+
+	walked = nil
+	if err := WalkConcurrent(context.Background(), []WalkItem{root}, 8, step); err != nil {
+		t.Fatal(err)
+	}
+	if len(walked) != 15 {
+		t.Fatalf("expected 15 items walked, got %d", len(walked))
+	}
+
+	fail := errors.New("fail")
+	err := WalkConcurrent(context.Background(), []WalkItem{root}, 8, func(ctx context.Context, it WalkItem) ([]WalkItem, error) {
+		if cids[it.Cid.KeyString()] == 5 {
+			return nil, fail
+		}
+		return step(ctx, it)
+	})
+	if err != fail {
+		t.Fatalf("expected the error of the step, got %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+package mfs
+
+import (
+	"errors"
+	"path"
+)
+
+var ErrBatchInProgress = errors.New("a batch is already in progress")
+var ErrNoBatch = errors.New("no batch in progress")
+
+// Begin starts a batch: the root stops being published, so that the changes
+// made until Commit are persisted all at once. Should the node stop before
+// Commit, the changes of the batch are lost and the root published before
+// Begin is kept. Changes are still visible to readers of the root while the
+// batch is in progress.
+func (kr *Root) Begin() error {
+	if kr.repub == nil {
+		return errors.New("this root is never published, batches are meaningless")
+	}
+
+	kr.batchLk.Lock()
+	defer kr.batchLk.Unlock()
+
+	if kr.inBatch {
+		return ErrBatchInProgress
+	}
+	kr.repub.Hold()
+	kr.inBatch = true
+	return nil
+}
+
+// Commit ends the batch started by Begin, and publishes the root with all
+// its changes.
+func (kr *Root) Commit() error {
+	kr.batchLk.Lock()
+	defer kr.batchLk.Unlock()
+
+	if !kr.inBatch {
+		return ErrNoBatch
+	}
+
+	nd, err := kr.GetValue().GetNode()
+	if err != nil {
+		return err
+	}
+
+	kr.repub.Update(nd.Cid())
+	kr.repub.Release()
+	kr.inBatch = false
+	kr.repub.WaitPub()
+	return nil
+}
+
+// InBatch tells whether a batch is in progress.
+func (kr *Root) InBatch() bool {
+	kr.batchLk.Lock()
+	defer kr.batchLk.Unlock()
+	return kr.inBatch
+}
+
+// FlushParent writes the directory holding pth, with its pending changes, to
+// the dag service, without propagating them to the root.
+func FlushParent(rt *Root, pth string) error {
+	dir, err := lookupDir(rt, path.Dir(pth))
+	if err != nil {
+		return err
+	}
+
+	_, err = dir.GetNode()
+	return err
+}
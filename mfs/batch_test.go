@@ -0,0 +1,113 @@
+package mfs
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+)
+
+type pubRecorder struct {
+	lk   sync.Mutex
+	last *cid.Cid
+}
+
+func (r *pubRecorder) publish(ctx context.Context, c *cid.Cid) error {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	r.last = c
+	return nil
+}
+
+func (r *pubRecorder) get() *cid.Cid {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	return r.last
+}
+
+func TestBatchCommit(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ds := getDagserv(t)
+	pub := new(pubRecorder)
+	rt, err := NewRoot(ctx, ds, emptyDirNode(), pub.publish)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := rt.Begin(); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.Begin(); err != ErrBatchInProgress {
+		t.Fatalf("expected ErrBatchInProgress, got %v", err)
+	}
+
+	for _, name := range []string{"a", "b"} {
+		if err := Mkdir(rt, "/"+name, false, true); err != nil {
+			t.Fatal(err)
+		}
+		if err := FlushPath(rt, "/"+name); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if c := pub.get(); c != nil {
+		t.Fatalf("root published during the batch: %s", c)
+	}
+
+	if err := rt.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	nd, err := rt.GetValue().GetNode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := pub.get(); c == nil || !c.Equals(nd.Cid()) {
+		t.Fatalf("expected %s to be published, got %v", nd.Cid(), c)
+	}
+
+	if err := rt.Commit(); err != ErrNoBatch {
+		t.Fatalf("expected ErrNoBatch, got %v", err)
+	}
+}
+
+// a node stopping in the middle of a batch must not persist any of it
+func TestBatchCrash(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ds := getDagserv(t)
+	pub := new(pubRecorder)
+	rt, err := NewRoot(ctx, ds, emptyDirNode(), pub.publish)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Mkdir(rt, "/before", false, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := FlushPath(rt, "/before"); err != nil {
+		t.Fatal(err)
+	}
+	before := pub.get()
+	if before == nil {
+		t.Fatal("root not published")
+	}
+
+	if err := rt.Begin(); err != nil {
+		t.Fatal(err)
+	}
+	if err := Mkdir(rt, "/during", false, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if c := pub.get(); !c.Equals(before) {
+		t.Fatalf("batch leaked into the published root: %s instead of %s", c, before)
+	}
+}
@@ -58,12 +58,19 @@ type Root struct {
 
 	repub *Republisher
 
+	// OnChange, if set, is called with the new root cid on every change,
+	// before the change is published.
+	OnChange func(*cid.Cid)
+
 	dserv dag.DAGService
 
 	Type string
 
 	// Prefix to use for any children created
 	Prefix *cid.Prefix
+
+	batchLk sync.Mutex
+	inBatch bool
 }
 
 type PubFunc func(context.Context, *cid.Cid) error
@@ -120,6 +127,9 @@ func (kr *Root) Flush() error {
 		return err
 	}
 
+	if kr.OnChange != nil {
+		kr.OnChange(nd.Cid())
+	}
 	if kr.repub != nil {
 		kr.repub.Update(nd.Cid())
 	}
@@ -134,6 +144,9 @@ func (kr *Root) closeChild(name string, nd node.Node, sync bool) error {
 		return err
 	}
 
+	if kr.OnChange != nil {
+		kr.OnChange(c)
+	}
 	if kr.repub != nil {
 		kr.repub.Update(c)
 	}
@@ -168,6 +181,7 @@ type Republisher struct {
 	lk      sync.Mutex
 	val     *cid.Cid
 	lastpub *cid.Cid
+	held    bool
 }
 
 // NewRepublisher creates a new Republisher object to republish the given root
@@ -193,7 +207,7 @@ func (p *Republisher) setVal(c *cid.Cid) {
 
 func (p *Republisher) WaitPub() {
 	p.lk.Lock()
-	consistent := p.lastpub == p.val
+	consistent := p.lastpub == p.val || p.held
 	p.lk.Unlock()
 	if consistent {
 		return
@@ -204,12 +218,42 @@ func (p *Republisher) WaitPub() {
 	<-wait
 }
 
+// Close publishes the last value, unless publishing is held, in which case
+// the changes since Hold are discarded.
 func (p *Republisher) Close() error {
-	err := p.publish(p.ctx)
+	var err error
+	if !p.isHeld() {
+		err = p.publish(p.ctx)
+	}
 	p.cancel()
 	return err
 }
 
+// Hold stops the publishing of new values until Release is called.
+func (p *Republisher) Hold() {
+	p.lk.Lock()
+	defer p.lk.Unlock()
+	p.held = true
+}
+
+// Release resumes publishing, publishing the last value given to Update.
+func (p *Republisher) Release() {
+	p.lk.Lock()
+	p.held = false
+	p.lk.Unlock()
+
+	select {
+	case p.Publish <- struct{}{}:
+	default:
+	}
+}
+
+func (p *Republisher) isHeld() bool {
+	p.lk.Lock()
+	defer p.lk.Unlock()
+	return p.held
+}
+
 // Touch signals that an update has occurred since the last publish.
 // Multiple consecutive touches may extend the time period before
 // the next Publish occurs in order to more efficiently batch updates
@@ -243,6 +287,13 @@ func (np *Republisher) Run() {
 			case pubnowresp = <-np.pubnowch:
 			}
 
+			if np.isHeld() {
+				if pubnowresp != nil {
+					pubnowresp <- struct{}{}
+				}
+				continue
+			}
+
 			err := np.publish(np.ctx)
 			if pubnowresp != nil {
 				pubnowresp <- struct{}{}
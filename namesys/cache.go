@@ -0,0 +1,145 @@
+package namesys
+
+import (
+	"context"
+	"time"
+
+	pb "github.com/ipfs/go-ipfs/namesys/pb"
+	path "github.com/ipfs/go-ipfs/path"
+)
+
+const DefaultResolverCacheTTL = time.Minute
+
+// DefaultRevalidateTimeout bounds the background lookup refreshing a stale
+// cache entry.
+const DefaultRevalidateTimeout = time.Minute
+
+// CacheOptions configures the resolution cache of a NameSystem. As a node
+// shares a single NameSystem between the gateway and the API, the cache
+// saves popular names from being looked up once per request.
+type CacheOptions struct {
+	// Size is the number of names kept in the lru cache. Setting it to '0'
+	// disables caching.
+	Size int
+
+	// TTL is how long an answer stays fresh when its record does not
+	// specify a TTL of its own. It defaults to DefaultResolverCacheTTL.
+	TTL time.Duration
+
+	// StaleTTL is how long past its expiry an answer may still be served,
+	// while it is refreshed in the background. Zero disables serving stale
+	// answers.
+	StaleTTL time.Duration
+
+	// NegativeTTL is how long a failed resolution is remembered before the
+	// routing system is queried again. Zero disables negative caching.
+	NegativeTTL time.Duration
+}
+
+type cacheEntry struct {
+	val path.Path
+	seq uint64
+	eol time.Time
+	err error
+}
+
+// cacheLookup returns the cache entry for name, if any, and whether it is
+// still fresh. Entries past their end of life are only returned while they
+// are within the stale window.
+func (r *routingResolver) cacheLookup(name string) (cacheEntry, bool, bool) {
+	if r.cache == nil {
+		return cacheEntry{}, false, false
+	}
+
+	ientry, ok := r.cache.Get(name)
+	if !ok {
+		return cacheEntry{}, false, false
+	}
+
+	entry, ok := ientry.(cacheEntry)
+	if !ok {
+		// should never happen, purely for sanity
+		log.Panicf("unexpected type %T in cache for %q.", ientry, name)
+	}
+
+	now := time.Now()
+	if now.Before(entry.eol) {
+		return entry, true, true
+	}
+
+	// failures are never served stale.
+	if entry.err == nil && now.Before(entry.eol.Add(r.cacheOpts.StaleTTL)) {
+		return entry, false, true
+	}
+
+	r.cache.Remove(name)
+
+	return cacheEntry{}, false, false
+}
+
+func (r *routingResolver) cacheSet(name string, val path.Path, rec *pb.IpnsEntry) {
+	if r.cache == nil {
+		return
+	}
+
+	// if completely unspecified, use the configured default
+	ttl := r.cacheOpts.TTL
+	if rec.Ttl != nil {
+		recttl := time.Duration(rec.GetTtl())
+		if recttl >= 0 {
+			ttl = recttl
+		}
+	}
+
+	cacheTil := time.Now().Add(ttl)
+	eol, ok := checkEOL(rec)
+	if ok && eol.Before(cacheTil) {
+		cacheTil = eol
+	}
+
+	r.cache.Add(name, cacheEntry{
+		val: val,
+		seq: rec.GetSequence(),
+		eol: cacheTil,
+	})
+}
+
+// cacheNegative remembers that resolving name failed with err.
+func (r *routingResolver) cacheNegative(name string, err error) {
+	if r.cache == nil || r.cacheOpts.NegativeTTL <= 0 {
+		return
+	}
+
+	r.cache.Add(name, cacheEntry{
+		err: err,
+		eol: time.Now().Add(r.cacheOpts.NegativeTTL),
+	})
+}
+
+// revalidate refreshes the cache entry for name in the background. Only one
+// refresh per name runs at a time, and a failed refresh leaves the stale
+// entry in place until its stale window runs out.
+func (r *routingResolver) revalidate(name string) {
+	r.refreshLk.Lock()
+	if _, ok := r.refreshing[name]; ok {
+		r.refreshLk.Unlock()
+		return
+	}
+	r.refreshing[name] = struct{}{}
+	r.refreshLk.Unlock()
+
+	go func() {
+		defer func() {
+			r.refreshLk.Lock()
+			delete(r.refreshing, name)
+			r.refreshLk.Unlock()
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), DefaultRevalidateTimeout)
+		defer cancel()
+
+		if _, err := r.lookup(ctx, name, 0); err != nil {
+			log.Debugf("RoutingResolve: revalidating %s failed: %s", name, err)
+		}
+	}()
+}
@@ -0,0 +1,123 @@
+package namesys
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	path "github.com/ipfs/go-ipfs/path"
+	mockrouting "github.com/ipfs/go-ipfs/routing/mock"
+	testutil "github.com/ipfs/go-ipfs/thirdparty/testutil"
+
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+	dssync "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore/sync"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+func TestNegativeCache(t *testing.T) {
+	ctx := context.Background()
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	d := mockrouting.NewServer().ClientWithDatastore(ctx, testutil.RandIdentityOrFatal(t), dstore)
+
+	resolver := NewRoutingResolverWithCache(d, CacheOptions{
+		Size:        16,
+		NegativeTTL: time.Hour,
+	})
+	publisher := NewRoutingPublisher(d, dstore)
+
+	privk, pubk, err := testutil.RandTestKeyPair(512)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := peer.IDFromPublicKey(pubk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := resolver.Resolve(ctx, "not-a-multihash"); err == nil {
+		t.Fatal("expected resolving an invalid name to fail")
+	}
+	if resolver.cache.Len() != 0 {
+		t.Fatal("expected the invalid name not to be cached")
+	}
+
+	if _, err := resolver.Resolve(ctx, id.Pretty()); err == nil {
+		t.Fatal("expected resolving an unpublished name to fail")
+	}
+	if resolver.cache.Len() != 1 {
+		t.Fatal("expected the unpublished name to be cached")
+	}
+
+	h := path.FromString("/ipfs/QmZULkCELmmk5XNfCgTnCyFgAVxBRBXyDHGGMVoLFLiXEN")
+	if err := publisher.Publish(ctx, privk, h); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := resolver.Resolve(ctx, id.Pretty()); err == nil {
+		t.Fatal("expected the failure to be served from the cache")
+	}
+
+	// bypassing the cache sees the new record
+	if err := verifyCanResolve(NewRoutingResolver(d, 0), id.Pretty(), h); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStaleWhileRevalidate(t *testing.T) {
+	ctx := context.Background()
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	d := mockrouting.NewServer().ClientWithDatastore(ctx, testutil.RandIdentityOrFatal(t), dstore)
+
+	resolver := NewRoutingResolverWithCache(d, CacheOptions{
+		Size:     16,
+		TTL:      time.Millisecond * 50,
+		StaleTTL: time.Hour,
+	})
+	publisher := NewRoutingPublisher(d, dstore)
+
+	privk, pubk, err := testutil.RandTestKeyPair(512)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := peer.IDFromPublicKey(pubk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h1 := path.FromString("/ipfs/QmZULkCELmmk5XNfCgTnCyFgAVxBRBXyDHGGMVoLFLiXEN")
+	h2 := path.FromString("/ipfs/QmatmE9msSfkKxoffpHwNLNKgwZG8eT9Bud6YoPab52vpy")
+
+	if err := publisher.Publish(ctx, privk, h1); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyCanResolve(resolver, id.Pretty(), h1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := publisher.Publish(ctx, privk, h2); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond * 100)
+
+	// the expired entry is still served, and refreshed in the background
+	if err := verifyCanResolve(resolver, id.Pretty(), h1); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(time.Second * 5)
+	for {
+		p, err := resolver.Resolve(ctx, id.Pretty())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p == h2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("stale entry was never revalidated")
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+}
@@ -25,10 +25,19 @@ func NewDNSResolver() Resolver {
 	return &DNSResolver{lookupTXT: net.LookupTXT}
 }
 
+// NewDNSResolverWithLookup constructs a name resolver using DNS TXT records
+// obtained through lookup, see NewLookupTXT.
+func NewDNSResolverWithLookup(lookup LookupTXTFunc) Resolver {
+	return newDNSResolver(lookup).(Resolver)
+}
+
 // newDNSResolver constructs a name resolver using DNS TXT records,
 // returning a resolver instead of NewDNSResolver's Resolver.
-func newDNSResolver() resolver {
-	return &DNSResolver{lookupTXT: net.LookupTXT}
+func newDNSResolver(lookup LookupTXTFunc) resolver {
+	if lookup == nil {
+		lookup = net.LookupTXT
+	}
+	return &DNSResolver{lookupTXT: lookup}
 }
 
 // Resolve implements Resolver.
@@ -95,22 +104,39 @@ func (r *DNSResolver) resolveOnce(ctx context.Context, name string) (path.Path,
 }
 
 func workDomain(r *DNSResolver, name string, res chan lookupRes) {
-	txt, err := r.lookupTXT(name)
+	_, p, err := r.lookupLink(name)
+	res <- lookupRes{p, err}
+}
 
+// lookupLink looks up the TXT records of name, and returns the path of the
+// first one that is a link, with the records found.
+func (r *DNSResolver) lookupLink(name string) (DNSLookup, path.Path, error) {
+	l := DNSLookup{Name: name, Used: -1}
+	txt, err := r.lookupTXT(name)
 	if err != nil {
-		// Error is != nil
-		res <- lookupRes{"", err}
-		return
+		l.Error = err.Error()
+		return l, "", err
 	}
 
+	var found path.Path
 	for _, t := range txt {
+		rec := TXTRecord{Text: t}
 		p, err := parseEntry(t)
-		if err == nil {
-			res <- lookupRes{p, nil}
-			return
+		if err != nil {
+			rec.Error = err.Error()
+		} else {
+			rec.Path = p.String()
+			if l.Used < 0 {
+				l.Used = len(l.Records)
+				found = p
+			}
 		}
+		l.Records = append(l.Records, rec)
+	}
+	if l.Used < 0 {
+		return l, "", ErrResolveFailed
 	}
-	res <- lookupRes{"", ErrResolveFailed}
+	return l, found, nil
 }
 
 func parseEntry(txt string) (path.Path, error) {
@@ -1,6 +1,7 @@
 package namesys
 
 import (
+	"context"
 	"fmt"
 	"testing"
 )
@@ -158,3 +159,43 @@ func TestDNSResolution(t *testing.T) {
 	testResolution(t, r, "double.example.com", DefaultDepthLimit, "/ipfs/QmY3hE8xgFCjGcz6PHgnvJz5HZi1BaKRfPkn1ghZUcYMjD", nil)
 	testResolution(t, r, "conflict.example.com", DefaultDepthLimit, "/ipfs/QmY3hE8xgFCjGcz6PHgnvJz5HZi1BaKRfPkn1ghZUcYMjE", nil)
 }
+
+func TestDNSTrace(t *testing.T) {
+	mock := newMockDNS()
+	r := &DNSResolver{lookupTXT: mock.lookupTXT}
+
+	steps, p, err := r.Trace(context.Background(), "dns1.example.com", DefaultDepthLimit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.String() != "/ipfs/QmY3hE8xgFCjGcz6PHgnvJz5HZi1BaKRfPkn1ghZUcYMjD" {
+		t.Fatalf("unexpected path %s", p)
+	}
+	if len(steps) != 2 || steps[0].Domain != "dns1.example.com" || steps[1].Domain != "ipfs.example.com" {
+		t.Fatalf("expected a step for each domain, got %v", steps)
+	}
+	sub, root := steps[0].Lookups[0], steps[0].Lookups[1]
+	if sub.Name != "_dnslink.dns1.example.com" || sub.Error == "" || sub.Used != -1 {
+		t.Fatalf("expected the _dnslink lookup to fail, got %v", sub)
+	}
+	if root.Used != 0 || root.Records[0].Path != "/ipns/ipfs.example.com" {
+		t.Fatalf("expected the first record of the domain to be used, got %v", root)
+	}
+
+	// both lookups are reported, even when the _dnslink one is enough
+	steps, _, err = r.Trace(context.Background(), "double.example.com", DefaultDepthLimit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if steps[0].Lookups[0].Used != 0 || steps[0].Lookups[1].Used != 0 {
+		t.Fatalf("expected the records of both lookups, got %v", steps)
+	}
+
+	steps, _, err = r.Trace(context.Background(), "bad.example.com", DefaultDepthLimit)
+	if err != ErrResolveFailed {
+		t.Fatalf("expected %s, got %v", ErrResolveFailed, err)
+	}
+	if len(steps) != 1 || steps[0].Error == "" || steps[0].Lookups[1].Records[0].Error == "" {
+		t.Fatalf("expected the records to be reported invalid, got %v", steps)
+	}
+}
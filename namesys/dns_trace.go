@@ -0,0 +1,110 @@
+package namesys
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	path "github.com/ipfs/go-ipfs/path"
+
+	isd "gx/ipfs/QmZmmuAXgX73UQmX1jRKjTGmjzq24Jinqkq8vzkBtno4uX/go-is-domain"
+)
+
+// TXTRecord is a TXT record found by a lookup, and the path it is a link
+// to, or why it is not a link.
+type TXTRecord struct {
+	Text  string
+	Path  string `json:",omitempty"`
+	Error string `json:",omitempty"`
+}
+
+// DNSLookup is a TXT lookup done to resolve a domain.
+type DNSLookup struct {
+	Name    string
+	Records []TXTRecord
+	Error   string `json:",omitempty"`
+	// Used is the index of the first record which is a link, -1 if none
+	// is.
+	Used int
+}
+
+// DNSStep is the resolution of one domain: the lookup of its _dnslink.
+// subdomain, which wins, and of the domain itself, which is the fallback.
+type DNSStep struct {
+	Domain  string
+	Lookups []DNSLookup
+	Path    string `json:",omitempty"`
+	Error   string `json:",omitempty"`
+}
+
+// Trace resolves name as ResolveN does, recording each lookup made, the
+// records found, and which one was used, to diagnose DNSLink setups. Both
+// lookups of a domain are always made, so that a link in the wrong place
+// shows. The trace is returned along with the errors.
+func (r *DNSResolver) Trace(ctx context.Context, name string, depth int) ([]DNSStep, path.Path, error) {
+	var steps []DNSStep
+	for {
+		if err := ctx.Err(); err != nil {
+			return steps, "", err
+		}
+
+		step, p, err := r.traceOnce(name)
+		steps = append(steps, step)
+		if err != nil {
+			return steps, "", err
+		}
+
+		if strings.HasPrefix(p.String(), "/ipfs/") {
+			return steps, p, nil
+		}
+		if depth == 1 {
+			return steps, p, ErrResolveRecursion
+		}
+		if !strings.HasPrefix(p.String(), "/ipns/") {
+			return steps, p, nil
+		}
+		name = strings.TrimPrefix(p.String(), "/ipns/")
+		if depth > 1 {
+			depth--
+		}
+	}
+}
+
+// traceOnce is resolveOnce, recording the lookups.
+func (r *DNSResolver) traceOnce(name string) (DNSStep, path.Path, error) {
+	segments := strings.SplitN(name, "/", 2)
+	domain := segments[0]
+	step := DNSStep{Domain: domain}
+
+	if !isd.IsDomain(domain) {
+		err := errors.New("not a valid domain name")
+		step.Error = err.Error()
+		return step, "", err
+	}
+
+	sub, subPath, subErr := r.lookupLink("_dnslink." + domain)
+	root, rootPath, rootErr := r.lookupLink(domain)
+	step.Lookups = []DNSLookup{sub, root}
+
+	var p path.Path
+	switch {
+	case subErr == nil:
+		p = subPath
+	case rootErr == nil:
+		p = rootPath
+	default:
+		step.Error = ErrResolveFailed.Error()
+		return step, "", ErrResolveFailed
+	}
+
+	if len(segments) > 1 {
+		var err error
+		p, err = path.FromSegments("", strings.TrimRight(p.String(), "/"), segments[1])
+		if err != nil {
+			step.Error = err.Error()
+			return step, "", err
+		}
+	}
+	step.Path = p.String()
+	return step, p, nil
+}
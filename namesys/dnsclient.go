@@ -0,0 +1,332 @@
+package namesys
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DNSResolverSystem selects the operating system's resolver.
+const DNSResolverSystem = "system"
+
+// DefaultDNSTimeout bounds a single query to a configured DNS resolver.
+const DefaultDNSTimeout = 10 * time.Second
+
+const (
+	dnsTypeTXT   = 16
+	dnsClassINET = 1
+)
+
+var (
+	errDNSTruncated = errors.New("dns: truncated response")
+	errDNSMismatch  = errors.New("dns: response does not match query")
+	// errDNSTC is returned for the responses the server truncated, setting
+	// their TC bit, as they did not fit in a datagram.
+	errDNSTC = errors.New("dns: response truncated by the server")
+)
+
+type txtRoute struct {
+	suffix string
+	lookup LookupTXTFunc
+}
+
+// txtRoutes sorts routes by decreasing suffix length, so that the most
+// specific suffix matches first and the catch-all "" comes last.
+type txtRoutes []txtRoute
+
+func (r txtRoutes) Len() int           { return len(r) }
+func (r txtRoutes) Less(i, j int) bool { return len(r[i].suffix) > len(r[j].suffix) }
+func (r txtRoutes) Swap(i, j int)      { r[i], r[j] = r[j], r[i] }
+
+// NewLookupTXT builds a LookupTXTFunc which sends each name to the resolver
+// configured for its longest matching domain suffix. Keys of resolvers are
+// domain suffixes such as "eth" or "example.com"; the "." key applies to all
+// other names. Values are either "system", a UDP server address such as
+// "8.8.8.8:53", or a DNS-over-HTTPS (RFC 8484) URL such as
+// "https://cloudflare-dns.com/dns-query". Names without a matching entry use
+// the system resolver.
+func NewLookupTXT(resolvers map[string]string) (LookupTXTFunc, error) {
+	var routes txtRoutes
+	for suffix, spec := range resolvers {
+		lookup, err := newTXTLookup(spec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid resolver for %q: %s", suffix, err)
+		}
+		routes = append(routes, txtRoute{
+			suffix: strings.Trim(strings.ToLower(suffix), "."),
+			lookup: lookup,
+		})
+	}
+
+	sort.Sort(routes)
+
+	return func(name string) ([]string, error) {
+		domain := strings.Trim(strings.ToLower(name), ".")
+		for _, r := range routes {
+			if r.suffix == "" || domain == r.suffix || strings.HasSuffix(domain, "."+r.suffix) {
+				return r.lookup(name)
+			}
+		}
+		return net.LookupTXT(name)
+	}, nil
+}
+
+func newTXTLookup(spec string) (LookupTXTFunc, error) {
+	switch {
+	case spec == "" || spec == DNSResolverSystem:
+		return net.LookupTXT, nil
+	case strings.HasPrefix(spec, "https://"):
+		client := &http.Client{Timeout: DefaultDNSTimeout}
+		return func(name string) ([]string, error) {
+			return lookupTXTOverHTTPS(client, spec, name)
+		}, nil
+	case strings.Contains(spec, "://") && !strings.HasPrefix(spec, "udp://"):
+		return nil, fmt.Errorf("unsupported resolver %q", spec)
+	default:
+		addr := strings.TrimPrefix(spec, "udp://")
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			addr = net.JoinHostPort(addr, "53")
+		}
+		if _, port, err := net.SplitHostPort(addr); err != nil {
+			return nil, err
+		} else if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+			return nil, fmt.Errorf("invalid port in %q", spec)
+		}
+		return func(name string) ([]string, error) {
+			txts, err := lookupTXTOverUDP(addr, name)
+			if err == errDNSTC {
+				// the records do not fit in a datagram, ask again over TCP
+				return lookupTXTOverTCP(addr, name)
+			}
+			return txts, err
+		}, nil
+	}
+}
+
+func lookupTXTOverUDP(addr, name string) ([]string, error) {
+	id := uint16(rand.Intn(1 << 16))
+	query, err := packTXTQuery(id, name)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.DialTimeout("udp", addr, DefaultDNSTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(DefaultDNSTimeout))
+	if _, err := conn.Write(query); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 4096)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+
+		txts, err := unpackTXTResponse(id, buf[:n])
+		if err == errDNSMismatch {
+			// a stray or spoofed answer, keep waiting for ours.
+			continue
+		}
+		return txts, err
+	}
+}
+
+// lookupTXTOverTCP sends the query to the server at addr over TCP, each
+// message prefixed by its length as RFC 1035 describes.
+func lookupTXTOverTCP(addr, name string) ([]string, error) {
+	id := uint16(rand.Intn(1 << 16))
+	query, err := packTXTQuery(id, name)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.DialTimeout("tcp", addr, DefaultDNSTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(DefaultDNSTimeout))
+	msg := make([]byte, 2+len(query))
+	binary.BigEndian.PutUint16(msg, uint16(len(query)))
+	copy(msg[2:], query)
+	if _, err := conn.Write(msg); err != nil {
+		return nil, err
+	}
+
+	var size uint16
+	if err := binary.Read(conn, binary.BigEndian, &size); err != nil {
+		return nil, err
+	}
+	resp := make([]byte, size)
+	if _, err := io.ReadFull(conn, resp); err != nil {
+		return nil, err
+	}
+	return unpackTXTResponse(id, resp)
+}
+
+func lookupTXTOverHTTPS(client *http.Client, url, name string) ([]string, error) {
+	// RFC 8484 recommends an ID of 0 to keep responses cacheable.
+	query, err := packTXTQuery(0, name)
+	if err != nil {
+		return nil, err
+	}
+
+	sep := "?"
+	if strings.Contains(url, "?") {
+		sep = "&"
+	}
+
+	req, err := http.NewRequest("GET", url+sep+"dns="+base64.RawURLEncoding.EncodeToString(query), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/dns-message")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("dns: %s responded with %s", url, resp.Status)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return unpackTXTResponse(0, body)
+}
+
+// packTXTQuery builds a recursive DNS query for the TXT records of name.
+func packTXTQuery(id uint16, name string) ([]byte, error) {
+	buf := new(bytes.Buffer)
+
+	header := []uint16{id, 1 << 8 /* RD */, 1, 0, 0, 0}
+	for _, v := range header {
+		binary.Write(buf, binary.BigEndian, v)
+	}
+
+	for _, label := range strings.Split(strings.Trim(name, "."), ".") {
+		if len(label) == 0 || len(label) > 63 {
+			return nil, fmt.Errorf("dns: invalid name %q", name)
+		}
+		buf.WriteByte(byte(len(label)))
+		buf.WriteString(label)
+	}
+	buf.WriteByte(0)
+
+	binary.Write(buf, binary.BigEndian, uint16(dnsTypeTXT))
+	binary.Write(buf, binary.BigEndian, uint16(dnsClassINET))
+	return buf.Bytes(), nil
+}
+
+// unpackTXTResponse extracts the TXT records from the answer section of msg.
+// The strings of a record are concatenated, like net.LookupTXT does.
+func unpackTXTResponse(id uint16, msg []byte) ([]string, error) {
+	if len(msg) < 12 {
+		return nil, errDNSTruncated
+	}
+
+	flags := binary.BigEndian.Uint16(msg[2:4])
+	if binary.BigEndian.Uint16(msg[0:2]) != id || flags&(1<<15) == 0 {
+		return nil, errDNSMismatch
+	}
+	if flags&(1<<9) != 0 {
+		return nil, errDNSTC
+	}
+	switch rcode := flags & 0xf; rcode {
+	case 0:
+	case 3:
+		return nil, errors.New("dns: no such host")
+	default:
+		return nil, fmt.Errorf("dns: server failure (rcode %d)", rcode)
+	}
+
+	qdcount := int(binary.BigEndian.Uint16(msg[4:6]))
+	ancount := int(binary.BigEndian.Uint16(msg[6:8]))
+
+	off := 12
+	var err error
+	for i := 0; i < qdcount; i++ {
+		if off, err = skipDNSName(msg, off); err != nil {
+			return nil, err
+		}
+		off += 4 // type, class
+	}
+
+	var txts []string
+	for i := 0; i < ancount; i++ {
+		if off, err = skipDNSName(msg, off); err != nil {
+			return nil, err
+		}
+		if off+10 > len(msg) {
+			return nil, errDNSTruncated
+		}
+
+		typ := binary.BigEndian.Uint16(msg[off : off+2])
+		rdlen := int(binary.BigEndian.Uint16(msg[off+8 : off+10]))
+		off += 10
+		if off+rdlen > len(msg) {
+			return nil, errDNSTruncated
+		}
+
+		if typ == dnsTypeTXT {
+			rdata := msg[off : off+rdlen]
+			var txt []byte
+			for len(rdata) > 0 {
+				l := int(rdata[0])
+				if 1+l > len(rdata) {
+					return nil, errDNSTruncated
+				}
+				txt = append(txt, rdata[1:1+l]...)
+				rdata = rdata[1+l:]
+			}
+			txts = append(txts, string(txt))
+		}
+		off += rdlen
+	}
+
+	return txts, nil
+}
+
+// skipDNSName returns the offset following the (possibly compressed) domain
+// name starting at off.
+func skipDNSName(msg []byte, off int) (int, error) {
+	for {
+		if off >= len(msg) {
+			return 0, errDNSTruncated
+		}
+
+		l := int(msg[off])
+		switch {
+		case l == 0:
+			return off + 1, nil
+		case l&0xc0 == 0xc0:
+			// compression pointer, the name ends here.
+			return off + 2, nil
+		default:
+			off += 1 + l
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package namesys
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+)
+
+// txtAnswer builds a response to query carrying the given TXT records, each
+// split into strings of at most 255 bytes.
+func txtAnswer(query []byte, records ...string) []byte {
+	msg := append([]byte{}, query...)
+	binary.BigEndian.PutUint16(msg[2:4], 1<<15|1<<8|1<<7)
+	binary.BigEndian.PutUint16(msg[6:8], uint16(len(records)))
+
+	for _, rec := range records {
+		var rdata []byte
+		for len(rec) > 0 {
+			n := len(rec)
+			if n > 255 {
+				n = 255
+			}
+			rdata = append(rdata, byte(n))
+			rdata = append(rdata, rec[:n]...)
+			rec = rec[n:]
+		}
+
+		// name: pointer to the question
+		msg = append(msg, 0xc0, 12)
+		msg = append(msg, 0, dnsTypeTXT, 0, dnsClassINET, 0, 0, 0, 60)
+		msg = append(msg, byte(len(rdata)>>8), byte(len(rdata)))
+		msg = append(msg, rdata...)
+	}
+	return msg
+}
+
+func TestPackUnpackTXT(t *testing.T) {
+	query, err := packTXTQuery(42, "_dnslink.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	long := "dnslink=/ipfs/"
+	for len(long) < 300 {
+		long += "a"
+	}
+
+	txts, err := unpackTXTResponse(42, txtAnswer(query, "dnslink=/ipns/ipfs.io", long))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(txts) != 2 || txts[0] != "dnslink=/ipns/ipfs.io" || txts[1] != long {
+		t.Fatalf("unexpected records: %q", txts)
+	}
+
+	if _, err := unpackTXTResponse(43, txtAnswer(query)); err != errDNSMismatch {
+		t.Fatalf("expected a mismatched id to be rejected, got %v", err)
+	}
+
+	nx := txtAnswer(query)
+	nx[3] |= 3
+	if _, err := unpackTXTResponse(42, nx); err == nil {
+		t.Fatal("expected NXDOMAIN to fail")
+	}
+
+	if _, err := packTXTQuery(0, "bad..name"); err == nil {
+		t.Fatal("expected an empty label to be rejected")
+	}
+}
+
+func TestLookupTXTRoutes(t *testing.T) {
+	lookup, err := NewLookupTXT(map[string]string{
+		".":   "system",
+		"eth": "127.0.0.1:0",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lookup == nil {
+		t.Fatal("expected a lookup function")
+	}
+
+	if _, err := NewLookupTXT(map[string]string{"eth": "http://example.com/dns-query"}); err == nil {
+		t.Fatal("expected a plain http resolver to be rejected")
+	}
+}
+
+func TestLookupTXTOverUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	go func() {
+		buf := make([]byte, 512)
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		conn.WriteTo(txtAnswer(buf[:n], "dnslink=/ipfs/QmatmE9msSfkKxoffpHwNLNKgwZG8eT9Bud6YoPab52vpy"), addr)
+	}()
+
+	lookup, err := NewLookupTXT(map[string]string{"example.com": conn.LocalAddr().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	txts, err := lookup("_dnslink.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txts) != 1 || txts[0] != "dnslink=/ipfs/QmatmE9msSfkKxoffpHwNLNKgwZG8eT9Bud6YoPab52vpy" {
+		t.Fatalf("unexpected records: %q", txts)
+	}
+}
+
+func TestLookupTXTOverTCPOnTruncation(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	conn, err := net.ListenPacket("udp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// over UDP, an empty answer with the TC bit set
+	go func() {
+		buf := make([]byte, 512)
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		resp := txtAnswer(buf[:n])
+		resp[2] |= 1 << 1
+		conn.WriteTo(resp, addr)
+	}()
+
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+
+		var size uint16
+		if err := binary.Read(c, binary.BigEndian, &size); err != nil {
+			return
+		}
+		query := make([]byte, size)
+		if _, err := io.ReadFull(c, query); err != nil {
+			return
+		}
+		resp := txtAnswer(query, "dnslink=/ipfs/QmatmE9msSfkKxoffpHwNLNKgwZG8eT9Bud6YoPab52vpy")
+		binary.Write(c, binary.BigEndian, uint16(len(resp)))
+		c.Write(resp)
+	}()
+
+	lookup, err := NewLookupTXT(map[string]string{".": ln.Addr().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	txts, err := lookup("_dnslink.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txts) != 1 || txts[0] != "dnslink=/ipfs/QmatmE9msSfkKxoffpHwNLNKgwZG8eT9Bud6YoPab52vpy" {
+		t.Fatalf("unexpected records: %q", txts)
+	}
+}
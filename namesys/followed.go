@@ -0,0 +1,178 @@
+package namesys
+
+import (
+	"fmt"
+	"time"
+
+	pb "github.com/ipfs/go-ipfs/namesys/pb"
+
+	ci "gx/ipfs/QmP1DfoUjiWH2ZBo1PBH6FupdBucbDepx3HpWmEY6JMUpY/go-libp2p-crypto"
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+	dsq "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore/query"
+	proto "gx/ipfs/QmZ4Qi3GaRbjcx28Sme5eMH7RQjGkt8wHxt2a65oLaeFEV/gogo-protobuf/proto"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+// The records of the names resolved through the routing system, with the
+// public keys they were verified with, are kept under these prefixes when
+// Options.KeepResolved is set, for the republisher to serve them again while
+// they are valid, even when their publishers are offline.
+var (
+	followedRecordsPrefix = ds.NewKey("/ipns-followed/records")
+	followedKeysPrefix    = ds.NewKey("/ipns-followed/keys")
+)
+
+// MaxFollowedNames caps the names whose records are kept.
+var MaxFollowedNames = 1000
+
+// FollowedRecord is the record of a resolved name, kept to be republished.
+type FollowedRecord struct {
+	ID     peer.ID
+	Entry  *pb.IpnsEntry
+	PubKey ci.PubKey
+}
+
+// KeepFollowed keeps the record entry of id, verified with pubk, to be
+// republished, unless it has expired, a later record of id is kept already,
+// or MaxFollowedNames are kept.
+func KeepFollowed(d ds.Datastore, id peer.ID, entry *pb.IpnsEntry, pubk ci.PubKey) error {
+	eol, ok := checkEOL(entry)
+	if !ok || time.Now().After(eol) {
+		return nil
+	}
+
+	reck := followedRecordsPrefix.ChildString(id.Pretty())
+	prev, err := getFollowed(d, id)
+	if err != nil {
+		return err
+	}
+	switch {
+	case prev != nil && prev.GetSequence() > entry.GetSequence():
+		return nil
+	case prev == nil:
+		n, err := countFollowed(d)
+		if err != nil {
+			return err
+		}
+		if n >= MaxFollowedNames {
+			log.Debugf("not keeping the record of %s, %d names are kept already", id.Pretty(), n)
+			return nil
+		}
+	}
+
+	data, err := proto.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	pkb, err := pubk.Bytes()
+	if err != nil {
+		return err
+	}
+	if err := d.Put(followedKeysPrefix.ChildString(id.Pretty()), pkb); err != nil {
+		return err
+	}
+	return d.Put(reck, data)
+}
+
+// DropFollowed forgets the record kept of id.
+func DropFollowed(d ds.Datastore, id peer.ID) error {
+	for _, k := range []ds.Key{
+		followedRecordsPrefix.ChildString(id.Pretty()),
+		followedKeysPrefix.ChildString(id.Pretty()),
+	} {
+		if err := d.Delete(k); err != nil && err != ds.ErrNotFound {
+			return err
+		}
+	}
+	return nil
+}
+
+// FollowedRecords returns the records kept of the resolved names, dropping
+// the expired ones.
+func FollowedRecords(d ds.Datastore) ([]FollowedRecord, error) {
+	ids, err := followedNames(d)
+	if err != nil {
+		return nil, err
+	}
+
+	var recs []FollowedRecord
+	for _, id := range ids {
+		entry, err := getFollowed(d, id)
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			continue
+		}
+		if eol, ok := checkEOL(entry); !ok || time.Now().After(eol) {
+			if err := DropFollowed(d, id); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		v, err := d.Get(followedKeysPrefix.ChildString(id.Pretty()))
+		if err != nil {
+			return nil, err
+		}
+		pkb, ok := v.([]byte)
+		if !ok {
+			return nil, fmt.Errorf("unexpected type returned from datastore: %#v", v)
+		}
+		pubk, err := ci.UnmarshalPublicKey(pkb)
+		if err != nil {
+			return nil, err
+		}
+		recs = append(recs, FollowedRecord{ID: id, Entry: entry, PubKey: pubk})
+	}
+	return recs, nil
+}
+
+// getFollowed returns the record kept of id, or nil if there is none.
+func getFollowed(d ds.Datastore, id peer.ID) (*pb.IpnsEntry, error) {
+	v, err := d.Get(followedRecordsPrefix.ChildString(id.Pretty()))
+	switch err {
+	case nil:
+	case ds.ErrNotFound:
+		return nil, nil
+	default:
+		return nil, err
+	}
+
+	b, ok := v.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("unexpected type returned from datastore: %#v", v)
+	}
+	e := new(pb.IpnsEntry)
+	if err := proto.Unmarshal(b, e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func followedNames(d ds.Datastore) ([]peer.ID, error) {
+	res, err := d.Query(dsq.Query{Prefix: followedRecordsPrefix.String(), KeysOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	var ids []peer.ID
+	for e := range res.Next() {
+		if e.Error != nil {
+			return nil, e.Error
+		}
+		id, err := peer.IDB58Decode(ds.NewKey(e.Key).BaseNamespace())
+		if err != nil {
+			log.Warningf("ignoring the invalid followed name %s", e.Key)
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func countFollowed(d ds.Datastore) (int, error) {
+	ids, err := followedNames(d)
+	return len(ids), err
+}
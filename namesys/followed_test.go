@@ -0,0 +1,95 @@
+package namesys
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	path "github.com/ipfs/go-ipfs/path"
+	mockrouting "github.com/ipfs/go-ipfs/routing/mock"
+	testutil "github.com/ipfs/go-ipfs/thirdparty/testutil"
+
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+	dssync "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore/sync"
+	proto "gx/ipfs/QmZ4Qi3GaRbjcx28Sme5eMH7RQjGkt8wHxt2a65oLaeFEV/gogo-protobuf/proto"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+func TestKeepResolved(t *testing.T) {
+	serv := mockrouting.NewServer()
+	pubds := dssync.MutexWrap(ds.NewMapDatastore())
+	pubr := serv.ClientWithDatastore(context.Background(), testutil.RandIdentityOrFatal(t), pubds)
+	publisher := NewRoutingPublisher(pubr, pubds)
+
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	r := serv.ClientWithDatastore(context.Background(), testutil.RandIdentityOrFatal(t), dstore)
+	ns := NewNameSystemWithOptions(r, dstore, Options{KeepResolved: true})
+
+	privk, pubk, err := testutil.RandTestKeyPair(512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := peer.IDFromPublicKey(pubk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := path.FromString("/ipfs/QmZULkCELmmk5XNfCgTnCyFgAVxBRBXyDHGGMVoLFLiXEN")
+	if err := publisher.Publish(context.Background(), privk, h); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ns.Resolve(context.Background(), "/ipns/"+id.Pretty()); err != nil {
+		t.Fatal(err)
+	}
+
+	recs, err := FollowedRecords(dstore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 1 || recs[0].ID != id || string(recs[0].Entry.GetValue()) != h.String() {
+		t.Fatalf("expected the record resolved to be kept, got %+v", recs)
+	}
+	if !recs[0].PubKey.Equals(pubk) {
+		t.Fatal("expected the public key of the name to be kept")
+	}
+
+	// an older record doesn't replace the one kept
+	old, err := CreateRoutingEntryData(privk, path.FromString("/ipfs/QmOld"), 0, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	latest := recs[0].Entry
+	latest.Sequence = proto.Uint64(1)
+	if err := KeepFollowed(dstore, id, latest, pubk); err != nil {
+		t.Fatal(err)
+	}
+	if err := KeepFollowed(dstore, id, old, pubk); err != nil {
+		t.Fatal(err)
+	}
+	if e, _ := getFollowed(dstore, id); e.GetSequence() != 1 {
+		t.Fatal("expected the later record to be kept")
+	}
+
+	// and the expired records are dropped
+	expired, err := CreateRoutingEntryData(privk, h, 2, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dstore.Put(followedRecordsPrefix.ChildString(id.Pretty()), mustMarshal(t, expired)); err != nil {
+		t.Fatal(err)
+	}
+	if recs, err = FollowedRecords(dstore); err != nil || len(recs) != 0 {
+		t.Fatalf("expected the expired record to be dropped, got %d, %v", len(recs), err)
+	}
+	if e, _ := getFollowed(dstore, id); e != nil {
+		t.Fatal("expected the expired record to be removed")
+	}
+}
+
+func mustMarshal(t *testing.T, m proto.Message) []byte {
+	b, err := proto.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
@@ -2,9 +2,12 @@ package namesys
 
 import (
 	"context"
+	"fmt"
 	"strings"
+	"sync"
 	"time"
 
+	keystore "github.com/ipfs/go-ipfs/keystore"
 	path "github.com/ipfs/go-ipfs/path"
 
 	routing "gx/ipfs/QmNdaQ8itUU9jEZUwTsG4gHMaPmRfi6FEe89QjQAFbep3M/go-libp2p-routing"
@@ -25,24 +28,83 @@ import (
 type mpns struct {
 	resolvers  map[string]resolver
 	publishers map[string]Publisher
+
+	// psub, if set, answers the routing names before the resolvers, and
+	// broadcasts the records published
+	psub *pubsubResolver
+
+	keyPolicy KeyPolicyFunc
+
+	pubLk     sync.Mutex
+	published map[peer.ID][]time.Time // the publishes of the last hour
+}
+
+// KeyPolicyFunc returns the policy of the key id, and its name, or a nil
+// policy if it has none.
+type KeyPolicyFunc func(id peer.ID) (string, *keystore.Policy, error)
+
+// Options configures the construction of a NameSystem.
+type Options struct {
+	// Cache configures the cache of routing answers.
+	Cache CacheOptions
+
+	// LookupTXT resolves the TXT records of DNSLink names. It defaults to
+	// the system resolver.
+	LookupTXT LookupTXTFunc
+
+	// KeyPolicy, if set, returns the policies of the keys, which records
+	// are only published with if they allow it.
+	KeyPolicy KeyPolicyFunc
+
+	// Pubsub, if set, enables IPNS over pubsub.
+	Pubsub *PubsubOptions
+
+	// KeepResolved keeps the records of the names resolved through the
+	// routing system, for the republisher to serve them again, see
+	// FollowedRecords.
+	KeepResolved bool
+
+	// RecordCount is the number of records the resolutions through the
+	// routing system wait for, and Timeout how long they wait, unless
+	// their context sets them. Zero leaves them to the routing system.
+	RecordCount int
+	Timeout     time.Duration
 }
 
 // NewNameSystem will construct the IPFS naming system based on Routing
 func NewNameSystem(r routing.ValueStore, ds ds.Datastore, cachesize int) NameSystem {
-	return &mpns{
+	return NewNameSystemWithOptions(r, ds, Options{
+		Cache: CacheOptions{Size: cachesize},
+	})
+}
+
+// NewNameSystemWithOptions constructs the IPFS naming system based on
+// Routing, as described by opts.
+func NewNameSystemWithOptions(r routing.ValueStore, ds ds.Datastore, opts Options) NameSystem {
+	dht := NewRoutingResolverWithCache(r, opts.Cache)
+	if opts.KeepResolved {
+		dht.keep = ds
+	}
+	dht.records = opts.RecordCount
+	dht.timeout = opts.Timeout
+	ns := &mpns{
 		resolvers: map[string]resolver{
-			"dns":      newDNSResolver(),
+			"dns":      newDNSResolver(opts.LookupTXT),
 			"proquint": new(ProquintResolver),
-			"dht":      NewRoutingResolver(r, cachesize),
+			"dht":      dht,
 		},
 		publishers: map[string]Publisher{
 			"/ipns/": NewRoutingPublisher(r, ds),
 		},
+		keyPolicy: opts.KeyPolicy,
+		published: make(map[peer.ID][]time.Time),
 	}
+	if opts.Pubsub != nil {
+		ns.psub = newPubsubResolver(*opts.Pubsub, r, ds)
+	}
+	return ns
 }
 
-const DefaultResolverCacheTTL = time.Minute
-
 // Resolve implements Resolver.
 func (ns *mpns) Resolve(ctx context.Context, name string) (path.Path, error) {
 	return ns.ResolveN(ctx, name, DefaultDepthLimit)
@@ -72,15 +134,24 @@ func (ns *mpns) resolveOnce(ctx context.Context, name string) (path.Path, error)
 		return "", ErrResolveFailed
 	}
 
+	withRest := func(p path.Path) (path.Path, error) {
+		if len(segments) > 3 {
+			return path.FromSegments("", strings.TrimRight(p.String(), "/"), segments[3])
+		}
+		return p, nil
+	}
+
+	if ns.psub != nil {
+		if p, err := ns.psub.resolveOnce(ctx, segments[2]); err == nil {
+			return withRest(p)
+		}
+	}
+
 	for protocol, resolver := range ns.resolvers {
 		log.Debugf("Attempting to resolve %s with %s", segments[2], protocol)
 		p, err := resolver.resolveOnce(ctx, segments[2])
 		if err == nil {
-			if len(segments) > 3 {
-				return path.FromSegments("", strings.TrimRight(p.String(), "/"), segments[3])
-			} else {
-				return p, err
-			}
+			return withRest(p)
 		}
 	}
 	log.Warningf("No resolver found for %s", name)
@@ -89,20 +160,77 @@ func (ns *mpns) resolveOnce(ctx context.Context, name string) (path.Path, error)
 
 // Publish implements Publisher
 func (ns *mpns) Publish(ctx context.Context, name ci.PrivKey, value path.Path) error {
+	if err := ns.checkPolicy(name); err != nil {
+		return err
+	}
 	err := ns.publishers["/ipns/"].Publish(ctx, name, value)
 	if err != nil {
 		return err
 	}
 	ns.addToDHTCache(name, value, time.Now().Add(DefaultRecordTTL))
+	ns.broadcast(name)
 	return nil
 }
 
 func (ns *mpns) PublishWithEOL(ctx context.Context, name ci.PrivKey, value path.Path, eol time.Time) error {
+	if err := ns.checkPolicy(name); err != nil {
+		return err
+	}
 	err := ns.publishers["/ipns/"].PublishWithEOL(ctx, name, value, eol)
 	if err != nil {
 		return err
 	}
 	ns.addToDHTCache(name, value, eol)
+	ns.broadcast(name)
+	return nil
+}
+
+// broadcast sends the record just published with k to the nodes subscribed
+// to its topic. The record is in the routing system already, failing to
+// broadcast it only delays its resolvers.
+func (ns *mpns) broadcast(k ci.PrivKey) {
+	if ns.psub == nil {
+		return
+	}
+	if err := ns.psub.publish(k); err != nil {
+		log.Warningf("cannot broadcast the record over pubsub: %s", err)
+	}
+}
+
+// checkPolicy returns an error if the policy of the key k forbids publishing
+// with it, or it published too often already.
+func (ns *mpns) checkPolicy(k ci.PrivKey) error {
+	if ns.keyPolicy == nil {
+		return nil
+	}
+	id, err := peer.IDFromPrivateKey(k)
+	if err != nil {
+		return err
+	}
+	name, p, err := ns.keyPolicy(id)
+	if err != nil || p == nil {
+		return err
+	}
+	if !p.Allows(keystore.UsePublish) {
+		return &keystore.PolicyError{Key: name, Use: keystore.UsePublish}
+	}
+	if p.PublishesPerHour <= 0 {
+		return nil
+	}
+
+	ns.pubLk.Lock()
+	defer ns.pubLk.Unlock()
+
+	since := time.Now().Add(-time.Hour)
+	times := ns.published[id]
+	for len(times) > 0 && times[0].Before(since) {
+		times = times[1:]
+	}
+	if len(times) >= p.PublishesPerHour {
+		ns.published[id] = times
+		return fmt.Errorf("the key %s published %d records in the last hour, the most its policy allows", name, len(times))
+	}
+	ns.published[id] = append(times, time.Now())
 	return nil
 }
 
@@ -130,8 +258,8 @@ func (ns *mpns) addToDHTCache(key ci.PrivKey, value path.Path, eol time.Time) {
 		return
 	}
 
-	if time.Now().Add(DefaultResolverCacheTTL).Before(eol) {
-		eol = time.Now().Add(DefaultResolverCacheTTL)
+	if time.Now().Add(rr.cacheOpts.TTL).Before(eol) {
+		eol = time.Now().Add(rr.cacheOpts.TTL)
 	}
 	rr.cache.Add(name.Pretty(), cacheEntry{
 		val: value,
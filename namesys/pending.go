@@ -0,0 +1,49 @@
+package namesys
+
+import (
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+	dsq "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore/query"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+// pendingPrefix keys the names whose last record was only stored in the
+// datastore, by 'ipfs name publish --allow-offline', and is still to be sent
+// to the routing system.
+var pendingPrefix = ds.NewKey("/ipns-pending")
+
+// MarkPending records that the last record of id was only stored locally.
+func MarkPending(d ds.Datastore, id peer.ID) error {
+	return d.Put(pendingPrefix.ChildString(id.Pretty()), []byte{})
+}
+
+// ClearPending records that the last record of id was sent.
+func ClearPending(d ds.Datastore, id peer.ID) error {
+	err := d.Delete(pendingPrefix.ChildString(id.Pretty()))
+	if err == ds.ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+// PendingNames returns the names whose last record is still to be sent.
+func PendingNames(d ds.Datastore) ([]peer.ID, error) {
+	res, err := d.Query(dsq.Query{Prefix: pendingPrefix.String(), KeysOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	var ids []peer.ID
+	for e := range res.Next() {
+		if e.Error != nil {
+			return nil, e.Error
+		}
+		id, err := peer.IDB58Decode(ds.NewKey(e.Key).BaseNamespace())
+		if err != nil {
+			log.Warningf("ignoring the invalid pending name %s", e.Key)
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
@@ -122,16 +122,17 @@ func (p *ipnsPublisher) getPreviousSeqNo(ctx context.Context, ipnskey string) (u
 	return e.GetSequence(), nil
 }
 
-// setting the TTL on published records is an experimental feature.
-// as such, i'm using the context to wire it through to avoid changing too
-// much code along the way.
-func checkCtxTTL(ctx context.Context) (time.Duration, bool) {
-	v := ctx.Value("ipns-publish-ttl")
-	if v == nil {
-		return 0, false
-	}
+type publishTTLKey struct{}
 
-	d, ok := v.(time.Duration)
+// WithPublishTTL returns a context under which the records published carry
+// ttl, how long resolvers may cache them before looking them up again. The
+// context wires it through the publishers, which don't all take options.
+func WithPublishTTL(ctx context.Context, ttl time.Duration) context.Context {
+	return context.WithValue(ctx, publishTTLKey{}, ttl)
+}
+
+func checkCtxTTL(ctx context.Context) (time.Duration, bool) {
+	d, ok := ctx.Value(publishTTLKey{}).(time.Duration)
 	return d, ok
 }
 
@@ -343,3 +344,31 @@ func IpnsKeysForID(id peer.ID) (name, ipns string) {
 
 	return namekey, ipnskey
 }
+
+// LocalRecord returns the IPNS record of id kept in d, as last published by
+// this node or stored for a peer, or nil if there is none.
+func LocalRecord(d ds.Datastore, id peer.ID) (*pb.IpnsEntry, error) {
+	_, ipnskey := IpnsKeysForID(id)
+	val, err := d.Get(dshelp.NewKeyFromBinary([]byte(ipnskey)))
+	switch err {
+	case nil:
+	case ds.ErrNotFound:
+		return nil, nil
+	default:
+		return nil, err
+	}
+
+	b, ok := val.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("unexpected type returned from datastore: %#v", val)
+	}
+	dhtrec := new(dhtpb.Record)
+	if err := proto.Unmarshal(b, dhtrec); err != nil {
+		return nil, err
+	}
+	e := new(pb.IpnsEntry)
+	if err := proto.Unmarshal(dhtrec.GetValue(), e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
@@ -0,0 +1,395 @@
+package namesys
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	blocks "github.com/ipfs/go-ipfs/blocks"
+	pb "github.com/ipfs/go-ipfs/namesys/pb"
+	path "github.com/ipfs/go-ipfs/path"
+
+	routing "gx/ipfs/QmNdaQ8itUU9jEZUwTsG4gHMaPmRfi6FEe89QjQAFbep3M/go-libp2p-routing"
+	ci "gx/ipfs/QmP1DfoUjiWH2ZBo1PBH6FupdBucbDepx3HpWmEY6JMUpY/go-libp2p-crypto"
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+	floodsub "gx/ipfs/QmUpeULWfmtsgCnfuRN3BHsfhHvBxNphoYh4La4CMxGt2Z/floodsub"
+	p2phost "gx/ipfs/QmUywuGNZoUKV8B9iyvup9bPkLiMrhTsyVMkeSXW5VxAfC/go-libp2p-host"
+	pstore "gx/ipfs/QmXZSd1qR5BxZkPyuwfT5jpqQFScZccoZvDneXsKzCNHWX/go-libp2p-peerstore"
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+	proto "gx/ipfs/QmZ4Qi3GaRbjcx28Sme5eMH7RQjGkt8wHxt2a65oLaeFEV/gogo-protobuf/proto"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+// pubsubTimeout bounds the lookups of public keys, records, providers and
+// peers done for the topics of the names.
+const pubsubTimeout = time.Minute
+
+const (
+	// defaultPubsubMaxNames bounds the topics subscribed to, the ones of the
+	// names resolved the longest ago being cancelled first.
+	defaultPubsubMaxNames = 1000
+
+	// defaultPubsubIdle is how long the topic of a name not resolved stays
+	// subscribed to.
+	defaultPubsubIdle = 24 * time.Hour
+)
+
+// PubsubOptions enables IPNS over pubsub: the records published are also
+// broadcast on a topic per name, which the nodes resolving the name
+// subscribe to, so that they get the new records as soon as they are
+// published. The first resolution of a name still goes through the routing
+// system.
+type PubsubOptions struct {
+	// Context bounds the subscriptions to the topics.
+	Context context.Context
+	PubSub  *floodsub.PubSub
+
+	// Host and ContentRouting, if set, connect the nodes subscribed to a
+	// topic to the ones publishing on it, which announce themselves as
+	// providers of the topic.
+	Host           p2phost.Host
+	ContentRouting routing.ContentRouting
+
+	// MaxNames bounds the topics subscribed to, and Idle cancels the ones of
+	// the names not resolved for that long. They default to 1000 and 24h.
+	MaxNames int
+	Idle     time.Duration
+}
+
+// PubsubTopic returns the topic the records of id are broadcast on.
+func PubsubTopic(id peer.ID) string {
+	return "/ipns/" + id.Pretty()
+}
+
+// pubsubTopicCid is the content the nodes publishing on topic provide, as
+// 'ipfs pubsub sub --discover' looks them up.
+func pubsubTopicCid(topic string) *cid.Cid {
+	return blocks.NewBlock([]byte("floodsub:" + topic)).Cid()
+}
+
+// pubsubResolver answers the names from the records received on their
+// topics, and broadcasts the records the node publishes.
+type pubsubResolver struct {
+	opts    PubsubOptions
+	routing routing.ValueStore // for the public keys of the names
+	ds      ds.Datastore       // for the records published by the node
+
+	mu   sync.Mutex
+	subs map[peer.ID]*pubsubName
+}
+
+// pubsubName is the subscription to the topic of a name, and the record of
+// highest sequence number received on it.
+type pubsubName struct {
+	sub   *floodsub.Subscription
+	entry *pb.IpnsEntry
+
+	// seeded is closed once the record of the routing system is in entry,
+	// the records received are only compared with it then.
+	seeded chan struct{}
+
+	// used is when the name was last resolved.
+	used time.Time
+}
+
+func newPubsubResolver(opts PubsubOptions, r routing.ValueStore, d ds.Datastore) *pubsubResolver {
+	if opts.Context == nil {
+		opts.Context = context.Background()
+	}
+	if opts.MaxNames <= 0 {
+		opts.MaxNames = defaultPubsubMaxNames
+	}
+	if opts.Idle <= 0 {
+		opts.Idle = defaultPubsubIdle
+	}
+	return &pubsubResolver{
+		opts:    opts,
+		routing: r,
+		ds:      d,
+		subs:    make(map[peer.ID]*pubsubName),
+	}
+}
+
+// resolveOnce answers name from the last record received on its topic. The
+// first time a name is resolved, its topic is subscribed to and the routing
+// system answers, until records are received.
+func (r *pubsubResolver) resolveOnce(ctx context.Context, name string) (path.Path, error) {
+	id, err := peer.IDB58Decode(name)
+	if err != nil {
+		return "", err
+	}
+
+	r.mu.Lock()
+	var entry *pb.IpnsEntry
+	if s, ok := r.subs[id]; ok {
+		entry = s.entry
+		s.used = time.Now()
+	} else if err := r.subscribe(id); err != nil {
+		log.Warningf("cannot subscribe to the topic of %s: %s", name, err)
+	}
+	r.mu.Unlock()
+
+	if entry == nil {
+		return "", ErrResolveFailed
+	}
+	if eol, ok := checkEOL(entry); !ok || time.Now().After(eol) {
+		return "", ErrResolveFailed
+	}
+	return entryPath(entry)
+}
+
+// subscribe subscribes to the topic of id, cancelling the idle
+// subscriptions, and the least recently used one above the cap. r.mu must be
+// held.
+func (r *pubsubResolver) subscribe(id peer.ID) error {
+	r.expire()
+
+	topic := PubsubTopic(id)
+	sub, err := r.opts.PubSub.Subscribe(topic)
+	if err != nil {
+		return err
+	}
+	s := &pubsubName{
+		sub:    sub,
+		seeded: make(chan struct{}),
+		used:   time.Now(),
+	}
+	r.subs[id] = s
+
+	go r.seed(id, s)
+	go r.receive(id, s)
+	go r.connect(topic)
+	return nil
+}
+
+// expire cancels the subscriptions idle for too long, and the least recently
+// used ones to make room for another one. r.mu must be held.
+func (r *pubsubResolver) expire() {
+	now := time.Now()
+	var lru peer.ID
+	var lruUsed time.Time
+	for id, s := range r.subs {
+		if now.Sub(s.used) > r.opts.Idle {
+			delete(r.subs, id)
+			s.sub.Cancel()
+			continue
+		}
+		if lru == "" || s.used.Before(lruUsed) {
+			lru, lruUsed = id, s.used
+		}
+	}
+	if len(r.subs) >= r.opts.MaxNames {
+		r.subs[lru].sub.Cancel()
+		delete(r.subs, lru)
+	}
+}
+
+// seed sets the record of id in the routing system as the one to beat, so
+// that an older record replayed on the topic isn't taken for the last one.
+func (r *pubsubResolver) seed(id peer.ID, s *pubsubName) {
+	defer close(s.seeded)
+
+	ctx, cancel := context.WithTimeout(r.opts.Context, pubsubTimeout)
+	defer cancel()
+	data, err := r.routing.GetValue(ctx, "/ipns/"+string(id))
+	if err != nil {
+		log.Debugf("no record of %s in the routing system: %s", id.Pretty(), err)
+		return
+	}
+	entry := new(pb.IpnsEntry)
+	if err := proto.Unmarshal(data, entry); err != nil {
+		log.Debugf("invalid record of %s in the routing system: %s", id.Pretty(), err)
+		return
+	}
+	if err := r.verify(ctx, id, entry); err != nil {
+		log.Debugf("invalid record of %s in the routing system: %s", id.Pretty(), err)
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if s.entry == nil || entry.GetSequence() > s.entry.GetSequence() {
+		s.entry = entry
+	}
+}
+
+// receive reads the records broadcast for id until the subscription ends.
+func (r *pubsubResolver) receive(id peer.ID, s *pubsubName) {
+	defer s.sub.Cancel()
+	for {
+		msg, err := s.sub.Next(r.opts.Context)
+		if err != nil {
+			return
+		}
+		if err := r.handle(id, s, msg.GetData()); err != nil {
+			log.Debugf("ignoring a record for %s from %s: %s", id.Pretty(), peer.ID(msg.GetFrom()).Pretty(), err)
+		}
+	}
+}
+
+// handle checks a record received for id, and keeps it if it is newer than
+// the last one, once the one of the routing system is known.
+func (r *pubsubResolver) handle(id peer.ID, s *pubsubName, data []byte) error {
+	entry := new(pb.IpnsEntry)
+	if err := proto.Unmarshal(data, entry); err != nil {
+		return err
+	}
+
+	eol, ok := checkEOL(entry)
+	if !ok {
+		return errors.New("the record has no valid EOL")
+	}
+	if time.Now().After(eol) {
+		return errors.New("the record has expired")
+	}
+
+	ctx, cancel := context.WithTimeout(r.opts.Context, pubsubTimeout)
+	defer cancel()
+	if err := r.verify(ctx, id, entry); err != nil {
+		return err
+	}
+
+	select {
+	case <-s.seeded:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if s.entry != nil && entry.GetSequence() <= s.entry.GetSequence() {
+		return fmt.Errorf("the record of sequence %d is not newer than %d", entry.GetSequence(), s.entry.GetSequence())
+	}
+	s.entry = entry
+	return nil
+}
+
+// verify checks that entry is signed by the key of id.
+func (r *pubsubResolver) verify(ctx context.Context, id peer.ID, entry *pb.IpnsEntry) error {
+	pubkey, err := routing.GetPublicKey(r.routing, ctx, []byte(id))
+	if err != nil {
+		return fmt.Errorf("cannot get the public key: %s", err)
+	}
+	if ok, err := pubkey.Verify(ipnsEntryDataForSig(entry), entry.GetSignature()); err != nil || !ok {
+		return errors.New("the record is not signed by the key of the name")
+	}
+	return nil
+}
+
+// connect connects to the nodes publishing on topic.
+func (r *pubsubResolver) connect(topic string) {
+	if r.opts.Host == nil || r.opts.ContentRouting == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.opts.Context, pubsubTimeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for pi := range r.opts.ContentRouting.FindProvidersAsync(ctx, pubsubTopicCid(topic), 10) {
+		if pi.ID == r.opts.Host.ID() {
+			continue
+		}
+		wg.Add(1)
+		go func(pi pstore.PeerInfo) {
+			defer wg.Done()
+			if err := r.opts.Host.Connect(ctx, pi); err != nil {
+				log.Debugf("cannot connect to %s for %s: %s", pi.ID.Pretty(), topic, err)
+			}
+		}(pi)
+	}
+	wg.Wait()
+}
+
+// publish broadcasts the record last published with k on its topic, and
+// announces the node as a publisher of the topic.
+func (r *pubsubResolver) publish(k ci.PrivKey) error {
+	id, err := peer.IDFromPrivateKey(k)
+	if err != nil {
+		return err
+	}
+	entry, err := LocalRecord(r.ds, id)
+	if err != nil {
+		return err
+	}
+	if entry == nil {
+		return fmt.Errorf("no record of %s to broadcast", id.Pretty())
+	}
+	data, err := proto.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	topic := PubsubTopic(id)
+	if r.opts.ContentRouting != nil {
+		go func() {
+			ctx, cancel := context.WithTimeout(r.opts.Context, pubsubTimeout)
+			defer cancel()
+			if err := r.opts.ContentRouting.Provide(ctx, pubsubTopicCid(topic), true); err != nil {
+				log.Debugf("cannot announce the topic %s: %s", topic, err)
+			}
+		}()
+	}
+	return r.opts.PubSub.Publish(topic, data)
+}
+
+// PubsubSubscriptions reports and cancels the subscriptions to the topics
+// of the names resolved, when IPNS over pubsub is enabled.
+type PubsubSubscriptions interface {
+	// PubsubEnabled returns whether IPNS over pubsub is enabled.
+	PubsubEnabled() bool
+
+	// PubsubNames returns the names whose topics are subscribed to.
+	PubsubNames() []peer.ID
+
+	// CancelPubsub cancels the subscription to the topic of id, returning
+	// false if there was none. The next resolution of id subscribes again.
+	CancelPubsub(id peer.ID) bool
+}
+
+// PubsubEnabled implements PubsubSubscriptions.
+func (ns *mpns) PubsubEnabled() bool {
+	return ns.psub != nil
+}
+
+// PubsubNames implements PubsubSubscriptions.
+func (ns *mpns) PubsubNames() []peer.ID {
+	if ns.psub == nil {
+		return nil
+	}
+	return ns.psub.names()
+}
+
+// CancelPubsub implements PubsubSubscriptions.
+func (ns *mpns) CancelPubsub(id peer.ID) bool {
+	if ns.psub == nil {
+		return false
+	}
+	return ns.psub.cancel(id)
+}
+
+// names returns the names whose topics are subscribed to.
+func (r *pubsubResolver) names() []peer.ID {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ids := make([]peer.ID, 0, len(r.subs))
+	for id := range r.subs {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// cancel cancels the subscription to the topic of id, forgetting the last
+// record received on it.
+func (r *pubsubResolver) cancel(id peer.ID) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.subs[id]
+	if !ok {
+		return false
+	}
+	delete(r.subs, id)
+	s.sub.Cancel()
+	return true
+}
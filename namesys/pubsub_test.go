@@ -0,0 +1,96 @@
+package namesys
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	path "github.com/ipfs/go-ipfs/path"
+	mockrouting "github.com/ipfs/go-ipfs/routing/mock"
+	testutil "github.com/ipfs/go-ipfs/thirdparty/testutil"
+
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+	dssync "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore/sync"
+	proto "gx/ipfs/QmZ4Qi3GaRbjcx28Sme5eMH7RQjGkt8wHxt2a65oLaeFEV/gogo-protobuf/proto"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+func TestPubsubRecords(t *testing.T) {
+	ctx := context.Background()
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	d := mockrouting.NewServer().ClientWithDatastore(ctx, testutil.RandIdentityOrFatal(t), dstore)
+	publisher := NewRoutingPublisher(d, dstore)
+
+	privk, pubk, err := testutil.RandTestKeyPair(512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := peer.IDFromPublicKey(pubk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := func(p path.Path) []byte {
+		if err := publisher.Publish(ctx, privk, p); err != nil {
+			t.Fatal(err)
+		}
+		e, err := LocalRecord(dstore, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := proto.Marshal(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	r := newPubsubResolver(PubsubOptions{}, d, dstore)
+	s := &pubsubName{seeded: make(chan struct{})}
+	r.subs[id] = s
+	r.seed(id, s)
+
+	if _, err := r.resolveOnce(ctx, id.Pretty()); err != ErrResolveFailed {
+		t.Fatalf("expected the routing system to answer before any record, got %v", err)
+	}
+
+	first := path.FromString("/ipfs/QmZULkCELmmk5XNfCgTnCyFgAVxBRBXyDHGGMVoLFLiXEN")
+	old := record(first)
+	if err := r.handle(id, s, old); err != nil {
+		t.Fatal(err)
+	}
+	second := path.FromString("/ipfs/QmSeBrgugPsxvTjn1A8WWq9ZcScNDQC6NsLtsUm6dZmfqx")
+	if err := r.handle(id, s, record(second)); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.handle(id, s, old); err == nil {
+		t.Fatal("expected an older record to be ignored")
+	}
+	p, err := r.resolveOnce(ctx, id.Pretty())
+	if err != nil || p != second {
+		t.Fatalf("expected %s, got %s, %v", second, p, err)
+	}
+
+	// a new subscription starts from the record of the routing system
+	s2 := &pubsubName{seeded: make(chan struct{})}
+	r.seed(id, s2)
+	if err := r.handle(id, s2, old); err == nil {
+		t.Fatal("expected a record older than the one of the routing system to be ignored")
+	}
+
+	// a record of another key
+	otherk, _, err := testutil.RandTestKeyPair(512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged, err := CreateRoutingEntryData(otherk, first, 100, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := proto.Marshal(forged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.handle(id, s, data); err == nil {
+		t.Fatal("expected a record not signed by the key of the name to be refused")
+	}
+}
@@ -0,0 +1,59 @@
+package republisher
+
+import (
+	"fmt"
+	"time"
+
+	config "github.com/ipfs/go-ipfs/repo/config"
+
+	u "gx/ipfs/QmWbjfz3u6HkAdPh34dgPchGbQjob6LXLhAeCGii2TX69n/go-ipfs-util"
+)
+
+// The bounds of Ipns.RepublishPeriod and Ipns.RecordLifetime, not enforced
+// when debugging.
+const (
+	MinRepublishPeriod = time.Minute
+	MaxRepublishPeriod = 7 * 24 * time.Hour
+
+	MinRecordLifetime = time.Hour
+	MaxRecordLifetime = 365 * 24 * time.Hour
+)
+
+// ParseConfig returns the republish period and the record lifetime set in
+// cfg, or their defaults when unset. Records must outlive the period, so
+// that they are republished before they expire.
+func ParseConfig(cfg config.Ipns) (period, lifetime time.Duration, err error) {
+	period, err = parseBounded("Ipns.RepublishPeriod", cfg.RepublishPeriod,
+		DefaultRebroadcastInterval, MinRepublishPeriod, MaxRepublishPeriod)
+	if err != nil {
+		return 0, 0, err
+	}
+	lifetime, err = parseBounded("Ipns.RecordLifetime", cfg.RecordLifetime,
+		DefaultRecordLifetime, MinRecordLifetime, MaxRecordLifetime)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if lifetime <= period {
+		return 0, 0, fmt.Errorf("config setting Ipns.RecordLifetime (%s) must be longer than Ipns.RepublishPeriod (%s)", lifetime, period)
+	}
+	return period, lifetime, nil
+}
+
+func parseBounded(name, val string, def, min, max time.Duration) (time.Duration, error) {
+	if val == "" {
+		return def, nil
+	}
+
+	d, err := time.ParseDuration(val)
+	if err != nil {
+		return 0, fmt.Errorf("failure to parse config setting %s: %s", name, err)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("config setting %s must be positive: %s", name, d)
+	}
+	if !u.Debug && (d < min || d > max) {
+		return 0, fmt.Errorf("config setting %s is not between %s and %s: %s", name, min, max, d)
+	}
+	return d, nil
+}
@@ -0,0 +1,41 @@
+package republisher
+
+import (
+	"testing"
+	"time"
+
+	config "github.com/ipfs/go-ipfs/repo/config"
+)
+
+func TestParseConfig(t *testing.T) {
+	period, lifetime, err := ParseConfig(config.Ipns{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if period != DefaultRebroadcastInterval || lifetime != DefaultRecordLifetime {
+		t.Fatalf("expected the defaults, got %s and %s", period, lifetime)
+	}
+
+	period, lifetime, err = ParseConfig(config.Ipns{RepublishPeriod: "24h", RecordLifetime: "168h"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if period != 24*time.Hour || lifetime != 7*24*time.Hour {
+		t.Fatalf("expected 24h and 168h, got %s and %s", period, lifetime)
+	}
+
+	for _, cfg := range []config.Ipns{
+		{RepublishPeriod: "often"},
+		{RepublishPeriod: "10s"},
+		{RepublishPeriod: "200h", RecordLifetime: "300h"},
+		{RecordLifetime: "-1h"},
+		{RecordLifetime: "30m"},
+		{RecordLifetime: "10000h"},
+		{RepublishPeriod: "24h"},
+		{RepublishPeriod: "2h", RecordLifetime: "2h"},
+	} {
+		if _, _, err := ParseConfig(cfg); err == nil {
+			t.Errorf("expected %+v to be refused", cfg)
+		}
+	}
+}
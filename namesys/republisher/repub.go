@@ -18,6 +18,7 @@ import (
 	gpctx "gx/ipfs/QmSF8fPo3jgVBAy8fpdjjYqgG87dkJgUprRBHRd2tmfgpP/goprocess/context"
 	logging "gx/ipfs/QmSpJByNKFX1sCsHBEp3R73FL4NF6FnQTEGyNAXHm2GS52/go-log"
 	recpb "gx/ipfs/QmWYCqr6UDqqD1bfRybaAPtbAqcN3TSJpveaBXMwbQ3ePZ/go-libp2p-record/pb"
+	u "gx/ipfs/QmWbjfz3u6HkAdPh34dgPchGbQjob6LXLhAeCGii2TX69n/go-ipfs-util"
 	proto "gx/ipfs/QmZ4Qi3GaRbjcx28Sme5eMH7RQjGkt8wHxt2a65oLaeFEV/gogo-protobuf/proto"
 	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
 )
@@ -30,6 +31,10 @@ var DefaultRebroadcastInterval = time.Hour * 4
 
 const DefaultRecordLifetime = time.Hour * 24
 
+// DefaultPendingInterval is how often the records only stored locally, by
+// 'ipfs name publish --allow-offline', are sent again.
+var DefaultPendingInterval = time.Minute
+
 type Republisher struct {
 	r    routing.ValueStore
 	ds   ds.Datastore
@@ -40,23 +45,33 @@ type Republisher struct {
 
 	// how long records that are republished should be valid for
 	RecordLifetime time.Duration
+
+	// how often the records only stored locally are tried again
+	PendingInterval time.Duration
+
+	// Followed republishes, with the node's own, the records kept of the
+	// names it resolved, see namesys.Options.KeepResolved
+	Followed bool
 }
 
 // NewRepublisher creates a new Republisher
 func NewRepublisher(r routing.ValueStore, ds ds.Datastore, self ic.PrivKey, ks keystore.Keystore) *Republisher {
 	return &Republisher{
-		r:              r,
-		ds:             ds,
-		self:           self,
-		ks:             ks,
-		Interval:       DefaultRebroadcastInterval,
-		RecordLifetime: DefaultRecordLifetime,
+		r:               r,
+		ds:              ds,
+		self:            self,
+		ks:              ks,
+		Interval:        DefaultRebroadcastInterval,
+		RecordLifetime:  DefaultRecordLifetime,
+		PendingInterval: DefaultPendingInterval,
 	}
 }
 
 func (rp *Republisher) Run(proc goprocess.Process) {
 	tick := time.NewTicker(rp.Interval)
 	defer tick.Stop()
+	pending := time.NewTicker(rp.PendingInterval)
+	defer pending.Stop()
 
 	for {
 		select {
@@ -65,6 +80,16 @@ func (rp *Republisher) Run(proc goprocess.Process) {
 			if err != nil {
 				log.Error("Republisher failed to republish: ", err)
 			}
+			if rp.Followed {
+				if err := rp.republishFollowed(proc); err != nil {
+					log.Error("Republisher failed to republish the followed records: ", err)
+				}
+			}
+		case <-pending.C:
+			err := rp.publishPending(proc)
+			if err != nil {
+				log.Error("Republisher failed to send the pending records: ", err)
+			}
 		case <-proc.Closing():
 			return
 		}
@@ -130,6 +155,133 @@ func (rp *Republisher) republishEntry(ctx context.Context, priv ic.PrivKey) erro
 	return nil
 }
 
+// publishPending sends the records only stored locally, until the routing
+// system takes them.
+func (rp *Republisher) publishPending(p goprocess.Process) error {
+	ids, err := namesys.PendingNames(rp.ds)
+	if err != nil || len(ids) == 0 {
+		return err
+	}
+	ctx, cancel := context.WithCancel(gpctx.OnClosingContext(p))
+	defer cancel()
+
+	for _, id := range ids {
+		entry, err := namesys.LocalRecord(rp.ds, id)
+		if err != nil {
+			return err
+		}
+		if entry == nil || time.Now().After(recordEOL(entry)) {
+			// nothing left worth sending, the periodic republishing
+			// signs the record anew if the key is still around
+			log.Warningf("dropping the pending record of %s, expired", id.Pretty())
+			if err := namesys.ClearPending(rp.ds, id); err != nil {
+				return err
+			}
+			continue
+		}
+
+		priv, err := rp.key(id)
+		if err != nil {
+			return err
+		}
+		if priv == nil {
+			log.Warningf("dropping the pending record of %s, its key is gone", id.Pretty())
+			if err := namesys.ClearPending(rp.ds, id); err != nil {
+				return err
+			}
+			continue
+		}
+
+		namekey, ipnskey := namesys.IpnsKeysForID(id)
+		err = namesys.PublishEntry(ctx, rp.r, ipnskey, entry)
+		if err == nil {
+			err = namesys.PublishPublicKey(ctx, rp.r, namekey, priv.GetPublic())
+		}
+		if err != nil {
+			// most likely still offline, tried again later
+			log.Debugf("cannot send the pending record of %s yet: %s", id.Pretty(), err)
+			continue
+		}
+		log.Infof("sent the record of %s published offline", id.Pretty())
+		if err := namesys.ClearPending(rp.ds, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// republishFollowed serves again, as they were signed, the records kept of
+// the names the node resolved, while they are valid. The node's own names
+// are left to republishEntries.
+func (rp *Republisher) republishFollowed(p goprocess.Process) error {
+	recs, err := namesys.FollowedRecords(rp.ds)
+	if err != nil || len(recs) == 0 {
+		return err
+	}
+	ctx, cancel := context.WithCancel(gpctx.OnClosingContext(p))
+	defer cancel()
+
+	for _, rec := range recs {
+		priv, err := rp.key(rec.ID)
+		if err != nil {
+			return err
+		}
+		if priv != nil {
+			continue
+		}
+
+		namekey, ipnskey := namesys.IpnsKeysForID(rec.ID)
+		err = namesys.PublishEntry(ctx, rp.r, ipnskey, rec.Entry)
+		if err == nil {
+			err = namesys.PublishPublicKey(ctx, rp.r, namekey, rec.PubKey)
+		}
+		if err != nil {
+			log.Debugf("cannot republish the record of %s: %s", rec.ID.Pretty(), err)
+			continue
+		}
+		log.Debugf("republished the followed record of %s", rec.ID.Pretty())
+	}
+	return nil
+}
+
+// key returns the private key of id, among the node's and the keystore's,
+// or nil if there is none.
+func (rp *Republisher) key(id peer.ID) (ic.PrivKey, error) {
+	keys := []ic.PrivKey{rp.self}
+	if rp.ks != nil {
+		names, err := rp.ks.List()
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			k, err := rp.ks.Get(name)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, k)
+		}
+	}
+	for _, k := range keys {
+		if kid, err := peer.IDFromPrivateKey(k); err == nil && kid == id {
+			return k, nil
+		}
+	}
+	return nil, nil
+}
+
+// recordEOL returns when entry expires, or the zero time if it has no
+// valid EOL.
+func recordEOL(entry *pb.IpnsEntry) time.Time {
+	if entry.GetValidityType() != pb.IpnsEntry_EOL {
+		return time.Time{}
+	}
+	eol, err := u.ParseRFC3339(string(entry.GetValidity()))
+	if err != nil {
+		return time.Time{}
+	}
+	return eol
+}
+
 func (rp *Republisher) getLastVal(k string) (path.Path, uint64, error) {
 	ival, err := rp.ds.Get(dshelp.NewKeyFromBinary([]byte(k)))
 	if err != nil {
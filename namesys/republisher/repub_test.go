@@ -13,6 +13,7 @@ import (
 	namesys "github.com/ipfs/go-ipfs/namesys"
 	. "github.com/ipfs/go-ipfs/namesys/republisher"
 	path "github.com/ipfs/go-ipfs/path"
+	offroute "github.com/ipfs/go-ipfs/routing/offline"
 	mocknet "gx/ipfs/QmQA5mdxru8Bh6dpC9PJfSkumqnmHgJX7knxSgBo5Lpime/go-libp2p/p2p/net/mock"
 	pstore "gx/ipfs/QmXZSd1qR5BxZkPyuwfT5jpqQFScZccoZvDneXsKzCNHWX/go-libp2p-peerstore"
 )
@@ -94,6 +95,80 @@ func TestRepublish(t *testing.T) {
 	}
 }
 
+func TestRepublishPending(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mn := mocknet.New(ctx)
+
+	var nodes []*core.IpfsNode
+	for i := 0; i < 5; i++ {
+		nd, err := core.NewNode(ctx, &core.BuildCfg{
+			Online: true,
+			Host:   mock.MockHostOption(mn),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		nd.Namesys = namesys.NewNameSystem(nd.Routing, nd.Repo.Datastore(), 0)
+
+		nodes = append(nodes, nd)
+	}
+
+	mn.LinkAll()
+
+	bsinf := core.BootstrapConfigWithPeers(
+		[]pstore.PeerInfo{
+			nodes[0].Peerstore.PeerInfo(nodes[0].Identity),
+		},
+	)
+
+	for _, n := range nodes[1:] {
+		if err := n.Bootstrap(bsinf); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the record is only stored by the publisher, as if it were offline
+	publisher := nodes[3]
+	others := append(append([]*core.IpfsNode{}, nodes[:3]...), nodes[4:]...)
+	p := path.FromString("/ipfs/QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn")
+	dstore := publisher.Repo.Datastore()
+	rp := namesys.NewRoutingPublisher(offroute.NewOfflineRouter(dstore, publisher.PrivateKey), dstore)
+	if err := rp.Publish(ctx, publisher.PrivateKey, p); err != nil {
+		t.Fatal(err)
+	}
+	if err := namesys.MarkPending(dstore, publisher.Identity); err != nil {
+		t.Fatal(err)
+	}
+
+	name := "/ipns/" + publisher.Identity.Pretty()
+	if err := verifyResolutionFails(others, name); err != nil {
+		t.Fatal(err)
+	}
+
+	repub := NewRepublisher(publisher.Routing, dstore, publisher.PrivateKey, publisher.Repo.Keystore())
+	repub.Interval = time.Hour
+	repub.PendingInterval = time.Millisecond * 100
+
+	proc := goprocess.Go(repub.Run)
+	defer proc.Close()
+
+	time.Sleep(time.Second * 2)
+
+	if err := verifyResolution(others, name, p); err != nil {
+		t.Fatal(err)
+	}
+	ids, err := namesys.PendingNames(dstore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 0 {
+		t.Fatalf("expected the record to be sent, %d still pending", len(ids))
+	}
+}
+
 func verifyResolution(nodes []*core.IpfsNode, key string, exp path.Path) error {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -10,6 +10,7 @@ import (
 	mockrouting "github.com/ipfs/go-ipfs/routing/mock"
 	testutil "github.com/ipfs/go-ipfs/thirdparty/testutil"
 
+	routing "gx/ipfs/QmNdaQ8itUU9jEZUwTsG4gHMaPmRfi6FEe89QjQAFbep3M/go-libp2p-routing"
 	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
 	dssync "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore/sync"
 	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
@@ -136,3 +137,88 @@ func verifyCanResolve(r Resolver, name string, exp path.Path) error {
 
 	return nil
 }
+
+// countingRouting records the number of records asked for, and blocks the
+// lookups of records if block is set.
+type countingRouting struct {
+	routing.ValueStore
+	count int
+	block bool
+}
+
+func (r *countingRouting) GetValues(ctx context.Context, key string, count int) ([]routing.RecvdVal, error) {
+	r.count = count
+	if r.block {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return r.ValueStore.GetValues(ctx, key, count)
+}
+
+func TestResolveDefaults(t *testing.T) {
+	ctx := context.Background()
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	d := mockrouting.NewServer().ClientWithDatastore(ctx, testutil.RandIdentityOrFatal(t), dstore)
+	publisher := NewRoutingPublisher(d, dstore)
+
+	privk, pubk, err := testutil.RandTestKeyPair(512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := peer.IDFromPublicKey(pubk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := path.FromString("/ipfs/QmZULkCELmmk5XNfCgTnCyFgAVxBRBXyDHGGMVoLFLiXEN")
+	if err := publisher.Publish(ctx, privk, h); err != nil {
+		t.Fatal(err)
+	}
+
+	cr := &countingRouting{ValueStore: d}
+	ns := NewNameSystemWithOptions(cr, dstore, Options{RecordCount: 4, Timeout: 50 * time.Millisecond})
+	if _, err := ns.Resolve(ctx, "/ipns/"+id.Pretty()); err != nil {
+		t.Fatal(err)
+	}
+	if cr.count != 4 {
+		t.Fatalf("expected the default of 4 records to be asked for, got %d", cr.count)
+	}
+
+	rctx := context.WithValue(ctx, "ipns-dht-record-count", 2)
+	if _, err := ns.Resolve(rctx, "/ipns/"+id.Pretty()); err != nil {
+		t.Fatal(err)
+	}
+	if cr.count != 2 {
+		t.Fatalf("expected the 2 records of the context to be asked for, got %d", cr.count)
+	}
+
+	cr.block = true
+	start := time.Now()
+	if _, err := ns.Resolve(ctx, "/ipns/"+id.Pretty()); err == nil {
+		t.Fatal("expected the resolution to time out")
+	}
+	if time.Since(start) > time.Second {
+		t.Fatal("expected the default timeout to bound the resolution")
+	}
+
+	tctx, cancel := context.WithTimeout(WithDHTTimeout(ctx, 10*time.Millisecond), time.Second)
+	defer cancel()
+	start = time.Now()
+	NewNameSystemWithOptions(cr, dstore, Options{RecordCount: 4}).Resolve(tctx, "/ipns/"+id.Pretty())
+	if time.Since(start) > 500*time.Millisecond {
+		t.Fatal("expected the timeout of the context to bound the resolution")
+	}
+
+	// running out of time is not cached as a failure
+	cns := NewNameSystemWithOptions(cr, dstore, Options{
+		Cache:       CacheOptions{Size: 16, NegativeTTL: time.Hour},
+		RecordCount: 4,
+		Timeout:     50 * time.Millisecond,
+	})
+	if _, err := cns.Resolve(ctx, "/ipns/"+id.Pretty()); err == nil {
+		t.Fatal("expected the resolution to time out")
+	}
+	cr.block = false
+	if _, err := cns.Resolve(ctx, "/ipns/"+id.Pretty()); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	pb "github.com/ipfs/go-ipfs/namesys/pb"
@@ -11,12 +12,14 @@ import (
 
 	routing "gx/ipfs/QmNdaQ8itUU9jEZUwTsG4gHMaPmRfi6FEe89QjQAFbep3M/go-libp2p-routing"
 	ci "gx/ipfs/QmP1DfoUjiWH2ZBo1PBH6FupdBucbDepx3HpWmEY6JMUpY/go-libp2p-crypto"
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
 	logging "gx/ipfs/QmSpJByNKFX1sCsHBEp3R73FL4NF6FnQTEGyNAXHm2GS52/go-log"
 	mh "gx/ipfs/QmVGtdTZdTFaLsaj2RwdVG8jcjNNcp1DE914DKZ2kHmXHw/go-multihash"
 	lru "gx/ipfs/QmVYxfoJQiZijTgPNHCHgHELvQpbsJNTg6Crmc3dQkj3yy/golang-lru"
 	u "gx/ipfs/QmWbjfz3u6HkAdPh34dgPchGbQjob6LXLhAeCGii2TX69n/go-ipfs-util"
 	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
 	proto "gx/ipfs/QmZ4Qi3GaRbjcx28Sme5eMH7RQjGkt8wHxt2a65oLaeFEV/gogo-protobuf/proto"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
 )
 
 var log = logging.Logger("namesys")
@@ -25,63 +28,19 @@ var log = logging.Logger("namesys")
 type routingResolver struct {
 	routing routing.ValueStore
 
-	cache *lru.Cache
-}
-
-func (r *routingResolver) cacheGet(name string) (path.Path, bool) {
-	if r.cache == nil {
-		return "", false
-	}
-
-	ientry, ok := r.cache.Get(name)
-	if !ok {
-		return "", false
-	}
-
-	entry, ok := ientry.(cacheEntry)
-	if !ok {
-		// should never happen, purely for sanity
-		log.Panicf("unexpected type %T in cache for %q.", ientry, name)
-	}
-
-	if time.Now().Before(entry.eol) {
-		return entry.val, true
-	}
+	cache     *lru.Cache
+	cacheOpts CacheOptions
 
-	r.cache.Remove(name)
+	refreshLk  sync.Mutex
+	refreshing map[string]struct{}
 
-	return "", false
-}
-
-func (r *routingResolver) cacheSet(name string, val path.Path, rec *pb.IpnsEntry) {
-	if r.cache == nil {
-		return
-	}
-
-	// if completely unspecified, just use one minute
-	ttl := DefaultResolverCacheTTL
-	if rec.Ttl != nil {
-		recttl := time.Duration(rec.GetTtl())
-		if recttl >= 0 {
-			ttl = recttl
-		}
-	}
-
-	cacheTil := time.Now().Add(ttl)
-	eol, ok := checkEOL(rec)
-	if ok && eol.Before(cacheTil) {
-		cacheTil = eol
-	}
-
-	r.cache.Add(name, cacheEntry{
-		val: val,
-		eol: cacheTil,
-	})
-}
+	// keep, if set, keeps the records resolved to be republished
+	keep ds.Datastore
 
-type cacheEntry struct {
-	val path.Path
-	eol time.Time
+	// the defaults of the number of records to wait for, and of how long
+	// to wait for them, when the context doesn't set them
+	records int
+	timeout time.Duration
 }
 
 // NewRoutingResolver constructs a name resolver using the IPFS Routing system
@@ -89,18 +48,30 @@ type cacheEntry struct {
 // cachesize is the limit of the number of entries in the lru cache. Setting it
 // to '0' will disable caching.
 func NewRoutingResolver(route routing.ValueStore, cachesize int) *routingResolver {
+	return NewRoutingResolverWithCache(route, CacheOptions{Size: cachesize})
+}
+
+// NewRoutingResolverWithCache constructs a name resolver using the IPFS
+// Routing system, caching its answers as described by opts.
+func NewRoutingResolverWithCache(route routing.ValueStore, opts CacheOptions) *routingResolver {
 	if route == nil {
 		panic("attempt to create resolver with nil routing system")
 	}
 
 	var cache *lru.Cache
-	if cachesize > 0 {
-		cache, _ = lru.New(cachesize)
+	if opts.Size > 0 {
+		cache, _ = lru.New(opts.Size)
+	}
+
+	if opts.TTL <= 0 {
+		opts.TTL = DefaultResolverCacheTTL
 	}
 
 	return &routingResolver{
-		routing: route,
-		cache:   cache,
+		routing:    route,
+		cache:      cache,
+		cacheOpts:  opts,
+		refreshing: make(map[string]struct{}),
 	}
 }
 
@@ -118,12 +89,42 @@ func (r *routingResolver) ResolveN(ctx context.Context, name string, depth int)
 // resolve SFS-like names.
 func (r *routingResolver) resolveOnce(ctx context.Context, name string) (path.Path, error) {
 	log.Debugf("RoutingResolve: '%s'", name)
-	cached, ok := r.cacheGet(name)
+	name = strings.TrimPrefix(name, "/ipns/")
+
+	// a name which isn't a multihash can't have a record, error out before
+	// caching anything for it.
+	if _, err := mh.FromB58String(name); err != nil {
+		log.Warningf("RoutingResolve: bad input hash: [%s]\n", name)
+		return "", err
+	}
+
+	entry, fresh, ok := r.cacheLookup(name)
 	if ok {
-		return cached, nil
+		if !fresh {
+			// serve the stale answer, but refresh it for the next caller.
+			r.revalidate(name)
+		}
+		return entry.val, entry.err
 	}
 
-	name = strings.TrimPrefix(name, "/ipns/")
+	p, err := r.lookup(ctx, name, checkCtxRecordCount(ctx))
+	// only cache that the name has no record. Other failures, like the DHT
+	// timeout of the resolution running out, say nothing about the name.
+	if ctx.Err() == nil && isNotFound(err) {
+		r.cacheNegative(name, err)
+	}
+	return p, err
+}
+
+// isNotFound tells whether err says there is no record. Routing systems
+// backed by a datastore return its ErrNotFound.
+func isNotFound(err error) bool {
+	return err == routing.ErrNotFound || err == ds.ErrNotFound
+}
+
+// lookup resolves name through the routing system, bypassing and then
+// updating the cache.
+func (r *routingResolver) lookup(ctx context.Context, name string, count int) (path.Path, error) {
 	hash, err := mh.FromB58String(name)
 	if err != nil {
 		// name should be a multihash. if it isn't, error out here.
@@ -131,6 +132,35 @@ func (r *routingResolver) resolveOnce(ctx context.Context, name string) (path.Pa
 		return "", err
 	}
 
+	entry, err := r.getEntry(ctx, hash, count)
+	if err != nil {
+		return "", err
+	}
+
+	p, err := entryPath(entry)
+	if err != nil {
+		return "", err
+	}
+
+	r.cacheSet(name, p, entry)
+	return p, nil
+}
+
+// getEntry fetches and verifies the IPNS entry published under the given
+// multihash. If count is greater than zero, up to count records are requested
+// from the routing system and the best valid one is selected; otherwise the
+// routing system's default selection is used.
+func (r *routingResolver) getEntry(ctx context.Context, hash mh.Multihash, count int) (*pb.IpnsEntry, error) {
+	timeout, ok := checkCtxTimeout(ctx)
+	if !ok {
+		timeout = r.timeout
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
 	// use the routing system to get the name.
 	// /ipns/<name>
 	h := []byte("/ipns/" + string(hash))
@@ -141,7 +171,7 @@ func (r *routingResolver) resolveOnce(ctx context.Context, name string) (path.Pa
 	resp := make(chan error, 2)
 	go func() {
 		ipnsKey := string(h)
-		val, err := r.routing.GetValue(ctx, ipnsKey)
+		val, err := r.getValue(ctx, ipnsKey, count)
 		if err != nil {
 			log.Warning("RoutingResolve get failed.")
 			resp <- err
@@ -171,37 +201,105 @@ func (r *routingResolver) resolveOnce(ctx context.Context, name string) (path.Pa
 	}()
 
 	for i := 0; i < 2; i++ {
-		err = <-resp
+		err := <-resp
 		if err != nil {
-			return "", err
+			return nil, err
 		}
 	}
 
 	// check sig with pk
 	if ok, err := pubkey.Verify(ipnsEntryDataForSig(entry), entry.GetSignature()); err != nil || !ok {
-		return "", fmt.Errorf("Invalid value. Not signed by PrivateKey corresponding to %v", pubkey)
+		return nil, fmt.Errorf("Invalid value. Not signed by PrivateKey corresponding to %v", pubkey)
 	}
 
 	// ok sig checks out. this is a valid name.
+	if r.keep != nil {
+		if err := KeepFollowed(r.keep, peer.ID(hash), entry, pubkey); err != nil {
+			log.Warningf("cannot keep the record of %s: %s", peer.ID(hash).Pretty(), err)
+		}
+	}
+	return entry, nil
+}
+
+// getValue retrieves the raw record stored under ipnsKey. A count greater
+// than zero, or else the default of the resolver, overrides the number of
+// records the routing system waits for before selecting the best one.
+func (r *routingResolver) getValue(ctx context.Context, ipnsKey string, count int) ([]byte, error) {
+	if count <= 0 {
+		count = r.records
+	}
+	if count <= 0 {
+		return r.routing.GetValue(ctx, ipnsKey)
+	}
+
+	recvd, err := r.routing.GetValues(ctx, ipnsKey, count)
+	if err != nil {
+		return nil, err
+	}
+
+	vals := make([][]byte, 0, len(recvd))
+	for _, rv := range recvd {
+		if err := ValidateIpnsRecord(ipnsKey, rv.Val); err != nil {
+			log.Debugf("RoutingResolve: discarding invalid record from %s: %s", rv.From, err)
+			continue
+		}
+		vals = append(vals, rv.Val)
+	}
+
+	if len(vals) == 0 {
+		return nil, routing.ErrNotFound
+	}
+
+	i, err := IpnsSelectorFunc(ipnsKey, vals)
+	if err != nil {
+		return nil, err
+	}
 
+	return vals[i], nil
+}
+
+// entryPath extracts the path stored in a verified IPNS entry.
+func entryPath(entry *pb.IpnsEntry) (path.Path, error) {
 	// check for old style record:
 	valh, err := mh.Cast(entry.GetValue())
 	if err != nil {
 		// Not a multihash, probably a new record
-		p, err := path.ParsePath(string(entry.GetValue()))
-		if err != nil {
-			return "", err
-		}
+		return path.ParsePath(string(entry.GetValue()))
+	}
 
-		r.cacheSet(name, p, entry)
-		return p, nil
-	} else {
-		// Its an old style multihash record
-		log.Warning("Detected old style multihash record")
-		p := path.FromCid(cid.NewCidV0(valh))
-		r.cacheSet(name, p, entry)
-		return p, nil
+	// Its an old style multihash record
+	log.Warning("Detected old style multihash record")
+	return path.FromCid(cid.NewCidV0(valh)), nil
+}
+
+// the number of records to wait for when resolving is an experimental
+// option. like the publish TTL, it is wired through the context to avoid
+// changing the Resolver interface.
+func checkCtxRecordCount(ctx context.Context) int {
+	v := ctx.Value("ipns-dht-record-count")
+	if v == nil {
+		return 0
+	}
+
+	c, ok := v.(int)
+	if !ok {
+		return 0
 	}
+	return c
+}
+
+// WithDHTTimeout returns a context bounding the time resolutions through the
+// routing system wait for records, the best of the records received by then
+// being selected. Zero waits until the routing system is done.
+func WithDHTTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, dhtTimeoutKey{}, timeout)
+}
+
+type dhtTimeoutKey struct{}
+
+func checkCtxTimeout(ctx context.Context) (time.Duration, bool) {
+	d, ok := ctx.Value(dhtTimeoutKey{}).(time.Duration)
+	return d, ok
 }
 
 func checkEOL(e *pb.IpnsEntry) (time.Time, bool) {
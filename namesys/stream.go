@@ -0,0 +1,222 @@
+package namesys
+
+import (
+	"context"
+	"strings"
+
+	path "github.com/ipfs/go-ipfs/path"
+
+	mh "gx/ipfs/QmVGtdTZdTFaLsaj2RwdVG8jcjNNcp1DE914DKZ2kHmXHw/go-multihash"
+)
+
+const (
+	// ResultSourceCache marks a result answered from the resolver cache.
+	ResultSourceCache = "cache"
+
+	// ResultSourceRouting marks a result built from the first record the
+	// routing system returned.
+	ResultSourceRouting = "routing"
+
+	// ResultSourceQuorum marks a result selected from a full set of records
+	// returned by the routing system.
+	ResultSourceQuorum = "quorum"
+)
+
+// ResolveResult is a single, possibly intermediate, answer produced while
+// streaming the resolution of a name.
+type ResolveResult struct {
+	Path     path.Path
+	Sequence uint64
+	Source   string
+	Err      error
+}
+
+// StreamResolver is a Resolver that can report progressively better answers
+// while a resolution is still in flight.
+type StreamResolver interface {
+	// ResolveStream resolves name like ResolveN, but sends every answer
+	// that improves upon the previous one on the returned channel: a cache
+	// hit first, then the first record found in the routing system, then
+	// the best of up to records records (the routing system's default if
+	// records is zero). The channel is closed once resolution completes.
+	ResolveStream(ctx context.Context, name string, depth int, records int) <-chan ResolveResult
+}
+
+// ResolveStream implements StreamResolver.
+func (ns *mpns) ResolveStream(ctx context.Context, name string, depth int, records int) <-chan ResolveResult {
+	if strings.HasPrefix(name, "/ipfs/") || !strings.HasPrefix(name, "/") {
+		p, err := ns.ResolveN(ctx, name, depth)
+		return singleResult(p, err)
+	}
+
+	segments := strings.SplitN(strings.TrimPrefix(name, "/ipns/"), "/", 2)
+	if _, err := mh.FromB58String(segments[0]); err != nil {
+		// not a routing name (dns, proquint, ...), nothing to stream.
+		p, err := ns.ResolveN(ctx, name, depth)
+		return singleResult(p, err)
+	}
+
+	rr, ok := ns.resolvers["dht"].(*routingResolver)
+	if !ok {
+		// should never happen, purely for sanity
+		log.Panicf("unexpected type %T as DHT resolver.", ns.resolvers["dht"])
+	}
+
+	return continueStream(ctx, ns, rr.resolveStream(ctx, segments[0], records), segments, depth)
+}
+
+// ResolveStream implements StreamResolver.
+func (r *routingResolver) ResolveStream(ctx context.Context, name string, depth int, records int) <-chan ResolveResult {
+	segments := strings.SplitN(strings.TrimPrefix(name, "/ipns/"), "/", 2)
+	return continueStream(ctx, r, r.resolveStream(ctx, segments[0], records), segments, depth)
+}
+
+// resolveStream looks up a routing name once, sending each answer with a
+// higher sequence number than the last one sent.
+func (r *routingResolver) resolveStream(ctx context.Context, name string, records int) <-chan ResolveResult {
+	out := make(chan ResolveResult)
+
+	go func() {
+		defer close(out)
+
+		hash, err := mh.FromB58String(name)
+		if err != nil {
+			log.Warningf("RoutingResolve: bad input hash: [%s]\n", name)
+			sendResult(ctx, out, ResolveResult{Err: err})
+			return
+		}
+
+		var best ResolveResult
+		sent := false
+		if entry, _, ok := r.cacheLookup(name); ok && entry.err == nil {
+			best = ResolveResult{Path: entry.val, Sequence: entry.seq, Source: ResultSourceCache}
+			if !sendResult(ctx, out, best) {
+				return
+			}
+			sent = true
+		}
+
+		results := make(chan ResolveResult, 2)
+		lookup := func(count int, source string) {
+			entry, err := r.getEntry(ctx, hash, count)
+			if err != nil {
+				results <- ResolveResult{Source: source, Err: err}
+				return
+			}
+
+			p, err := entryPath(entry)
+			if err != nil {
+				results <- ResolveResult{Source: source, Err: err}
+				return
+			}
+
+			if source == ResultSourceQuorum {
+				r.cacheSet(name, p, entry)
+			}
+			results <- ResolveResult{Path: p, Sequence: entry.GetSequence(), Source: source}
+		}
+
+		pending := 1
+		go lookup(1, ResultSourceRouting)
+		if records != 1 {
+			pending++
+			go lookup(records, ResultSourceQuorum)
+		}
+
+		var lastErr error
+		for ; pending > 0; pending-- {
+			var res ResolveResult
+			select {
+			case res = <-results:
+			case <-ctx.Done():
+				return
+			}
+
+			if res.Err != nil {
+				log.Debugf("RoutingResolve: %s lookup of %s failed: %s", res.Source, name, res.Err)
+				lastErr = res.Err
+				continue
+			}
+
+			if sent && !improves(best, res) {
+				continue
+			}
+
+			best = res
+			if !sendResult(ctx, out, res) {
+				return
+			}
+			sent = true
+		}
+
+		if !sent {
+			if lastErr == nil {
+				lastErr = ErrResolveFailed
+			}
+			sendResult(ctx, out, ResolveResult{Err: lastErr})
+		}
+	}()
+
+	return out
+}
+
+// improves reports whether next is a better answer than prev. Records with
+// a higher sequence number always win; a cached answer is also replaced by a
+// routing answer pointing elsewhere, as the cache may predate sequence
+// tracking.
+func improves(prev, next ResolveResult) bool {
+	if next.Sequence > prev.Sequence {
+		return true
+	}
+	return prev.Source == ResultSourceCache && next.Sequence == prev.Sequence && next.Path != prev.Path
+}
+
+// continueStream appends the remainder of the original name to each streamed
+// answer and, unless depth forbids it, recursively resolves answers that are
+// themselves IPNS names.
+func continueStream(ctx context.Context, r Resolver, in <-chan ResolveResult, segments []string, depth int) <-chan ResolveResult {
+	out := make(chan ResolveResult)
+
+	go func() {
+		defer close(out)
+
+		for res := range in {
+			if res.Err == nil && len(segments) > 1 {
+				res.Path, res.Err = path.FromSegments("", strings.TrimRight(res.Path.String(), "/"), segments[1])
+			}
+
+			if res.Err == nil && strings.HasPrefix(res.Path.String(), "/ipns/") {
+				switch depth {
+				case 1:
+					res.Err = ErrResolveRecursion
+				case UnlimitedDepth:
+					res.Path, res.Err = r.ResolveN(ctx, res.Path.String(), UnlimitedDepth)
+				default:
+					res.Path, res.Err = r.ResolveN(ctx, res.Path.String(), depth-1)
+				}
+			}
+
+			if !sendResult(ctx, out, res) {
+				return
+			}
+		}
+	}()
+
+	return out
+}
+
+func singleResult(p path.Path, err error) <-chan ResolveResult {
+	out := make(chan ResolveResult, 1)
+	out <- ResolveResult{Path: p, Err: err}
+	close(out)
+	return out
+}
+
+func sendResult(ctx context.Context, out chan<- ResolveResult, res ResolveResult) bool {
+	select {
+	case out <- res:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,96 @@
+package namesys
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	path "github.com/ipfs/go-ipfs/path"
+	mockrouting "github.com/ipfs/go-ipfs/routing/mock"
+	testutil "github.com/ipfs/go-ipfs/thirdparty/testutil"
+
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+	dssync "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore/sync"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+func TestResolveStream(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	d := mockrouting.NewServer().ClientWithDatastore(ctx, testutil.RandIdentityOrFatal(t), dstore)
+
+	resolver := NewRoutingResolver(d, 16)
+	publisher := NewRoutingPublisher(d, dstore)
+
+	privk, pubk, err := testutil.RandTestKeyPair(512)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := peer.IDFromPublicKey(pubk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := path.FromString("/ipfs/QmZULkCELmmk5XNfCgTnCyFgAVxBRBXyDHGGMVoLFLiXEN")
+	err = publisher.Publish(ctx, privk, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var last ResolveResult
+	count := 0
+	for res := range resolver.ResolveStream(ctx, "/ipns/"+id.Pretty(), 1, 3) {
+		if res.Err != nil {
+			t.Fatal(res.Err)
+		}
+		last = res
+		count++
+	}
+
+	if count == 0 {
+		t.Fatal("expected at least one result")
+	}
+	if last.Path != h {
+		t.Fatalf("expected %s, got %s", h, last.Path)
+	}
+
+	// the quorum answer is cached, so a second stream must start with it.
+	res, ok := <-resolver.ResolveStream(ctx, "/ipns/"+id.Pretty(), 1, 3)
+	if !ok || res.Err != nil {
+		t.Fatal("expected a cached result")
+	}
+	if res.Source != ResultSourceCache || res.Path != h {
+		t.Fatalf("expected cached %s, got %s from %s", h, res.Path, res.Source)
+	}
+}
+
+func TestResolveStreamNotFound(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	d := mockrouting.NewServer().ClientWithDatastore(ctx, testutil.RandIdentityOrFatal(t), dstore)
+	resolver := NewRoutingResolver(d, 0)
+
+	_, pubk, err := testutil.RandTestKeyPair(512)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := peer.IDFromPublicKey(pubk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var results []ResolveResult
+	for res := range resolver.ResolveStream(ctx, id.Pretty(), 1, 0) {
+		results = append(results, res)
+	}
+
+	if len(results) != 1 || results[0].Err == nil {
+		t.Fatalf("expected a single error result, got %v", results)
+	}
+}
@@ -0,0 +1,80 @@
+package namesys
+
+import (
+	"context"
+	"time"
+
+	path "github.com/ipfs/go-ipfs/path"
+)
+
+// DefaultWatchInterval is how often Watch resolves a name again.
+const DefaultWatchInterval = time.Minute
+
+// Watch resolves name every interval until ctx is done, sending its value
+// first and then each value it changes to. The first result carries the
+// error if the name can't be resolved; the later failures are only logged,
+// the name keeping its last value. Routing names are looked up in the
+// routing system every time, with records as in ResolveStream, if r is a
+// StreamResolver; a cached value would hide the changes until it expires.
+func Watch(ctx context.Context, r Resolver, name string, depth int, records int, interval time.Duration) <-chan ResolveResult {
+	out := make(chan ResolveResult)
+
+	go func() {
+		defer close(out)
+
+		t := time.NewTicker(interval)
+		defer t.Stop()
+
+		var last path.Path
+		sent := false
+		for {
+			res := resolveLatest(ctx, r, name, depth, records)
+			switch {
+			case ctx.Err() != nil:
+				return
+			case !sent || (res.Err == nil && res.Path != last):
+				if !sendResult(ctx, out, res) {
+					return
+				}
+				sent = true
+				if res.Err == nil {
+					last = res.Path
+				}
+			case res.Err != nil:
+				log.Debugf("watching %s: %s", name, res.Err)
+			}
+
+			select {
+			case <-t.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out
+}
+
+// resolveLatest resolves name once, to the best answer of a stream if r
+// streams them.
+func resolveLatest(ctx context.Context, r Resolver, name string, depth int, records int) ResolveResult {
+	sr, ok := r.(StreamResolver)
+	if !ok {
+		p, err := r.ResolveN(ctx, name, depth)
+		return ResolveResult{Path: p, Err: err}
+	}
+
+	var best ResolveResult
+	found := false
+	for res := range sr.ResolveStream(ctx, name, depth, records) {
+		if res.Err == nil {
+			best, found = res, true
+		} else if !found {
+			best = res
+		}
+	}
+	if !found && best.Err == nil {
+		best.Err = ErrResolveFailed
+	}
+	return best
+}
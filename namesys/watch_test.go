@@ -0,0 +1,70 @@
+package namesys
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	path "github.com/ipfs/go-ipfs/path"
+	mockrouting "github.com/ipfs/go-ipfs/routing/mock"
+	testutil "github.com/ipfs/go-ipfs/thirdparty/testutil"
+
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+	dssync "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore/sync"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+func TestWatch(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	d := mockrouting.NewServer().ClientWithDatastore(ctx, testutil.RandIdentityOrFatal(t), dstore)
+
+	resolver := NewRoutingResolver(d, 16)
+	publisher := NewRoutingPublisher(d, dstore)
+
+	privk, pubk, err := testutil.RandTestKeyPair(512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := peer.IDFromPublicKey(pubk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := path.FromString("/ipfs/QmZULkCELmmk5XNfCgTnCyFgAVxBRBXyDHGGMVoLFLiXEN")
+	if err := publisher.Publish(ctx, privk, first); err != nil {
+		t.Fatal(err)
+	}
+
+	results := Watch(ctx, resolver, "/ipns/"+id.Pretty(), 1, 1, 10*time.Millisecond)
+	next := func() ResolveResult {
+		select {
+		case res := <-results:
+			return res
+		case <-ctx.Done():
+			t.Fatal("timed out waiting for a value")
+		}
+		return ResolveResult{}
+	}
+
+	if res := next(); res.Err != nil || res.Path != first {
+		t.Fatalf("expected %s, got %s, %v", first, res.Path, res.Err)
+	}
+
+	second := path.FromString("/ipfs/QmSeBrgugPsxvTjn1A8WWq9ZcScNDQC6NsLtsUm6dZmfqx")
+	if err := publisher.Publish(ctx, privk, second); err != nil {
+		t.Fatal(err)
+	}
+	if res := next(); res.Err != nil || res.Path != second {
+		t.Fatalf("expected %s, got %s, %v", second, res.Path, res.Err)
+	}
+
+	// the value is only sent again once it changes
+	select {
+	case res := <-results:
+		t.Fatalf("expected no value while the name is unchanged, got %s", res.Path)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
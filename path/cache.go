@@ -0,0 +1,66 @@
+package path
+
+import (
+	"strings"
+	"time"
+
+	lru "gx/ipfs/QmVYxfoJQiZijTgPNHCHgHELvQpbsJNTg6Crmc3dQkj3yy/golang-lru"
+)
+
+// Cache remembers what paths resolve to. The resolution of /ipfs paths never
+// changes, so they are only evicted to make room; paths of other namespaces
+// are cached for the TTL of their namespace, or until invalidated.
+type Cache struct {
+	lru *lru.Cache
+}
+
+type cacheEntry struct {
+	to  Path
+	eol time.Time // zero for entries which never expire
+}
+
+// NewCache returns a cache of at most size resolutions.
+func NewCache(size int) (*Cache, error) {
+	l, err := lru.New(size)
+	if err != nil {
+		return nil, err
+	}
+	return &Cache{lru: l}, nil
+}
+
+// Get returns what p resolves to, if cached.
+func (c *Cache) Get(p Path) (Path, bool) {
+	v, ok := c.lru.Get(p.String())
+	if !ok {
+		return "", false
+	}
+	e := v.(cacheEntry)
+	if !e.eol.IsZero() && time.Now().After(e.eol) {
+		c.lru.Remove(p.String())
+		return "", false
+	}
+	return e.to, true
+}
+
+// Put caches that p resolves to to, for ttl, or until evicted if ttl is 0.
+func (c *Cache) Put(p, to Path, ttl time.Duration) {
+	e := cacheEntry{to: to}
+	if ttl > 0 {
+		e.eol = time.Now().Add(ttl)
+	}
+	c.lru.Add(p.String(), e)
+}
+
+// Invalidate drops the cached resolutions of the paths of namespace ns, or
+// of every namespace but /ipfs if ns is empty.
+func (c *Cache) Invalidate(ns string) {
+	for _, k := range c.lru.Keys() {
+		p := k.(string)
+		if strings.HasPrefix(p, "/ipfs/") {
+			continue
+		}
+		if ns == "" || strings.HasPrefix(p, "/"+ns+"/") || p == "/"+ns {
+			c.lru.Remove(k)
+		}
+	}
+}
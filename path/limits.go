@@ -0,0 +1,84 @@
+package path
+
+import (
+	"context"
+	"fmt"
+
+	dag "github.com/ipfs/go-ipfs/merkledag"
+
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+	node "gx/ipfs/Qmb3Hm9QDFmfYuET4pu7Kyg8JV78jFa1nvZx5vnCZsK4ck/go-ipld-format"
+)
+
+// Limits bound the work of resolving a path, so that crafted dags, very deep
+// or very wide, can't make a public gateway spend itself on a request. Zero
+// values set no limit.
+type Limits struct {
+	// MaxSegments is the most segments of a path after its root.
+	MaxSegments int
+	// MaxLinkDepth is the most links followed resolving a path, counting
+	// the inner nodes of sharded directories.
+	MaxLinkDepth int
+	// MaxDirectoryEntries is the most entries of a directory listed in the
+	// answer to a request. The resolver doesn't list directories: it is
+	// enforced by their listings.
+	MaxDirectoryEntries int
+}
+
+// LimitError is returned for the paths over the limits of their resolution.
+type LimitError struct {
+	Limit string
+	Max   int
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("over the limit of %d %s", e.Max, e.Limit)
+}
+
+type limitsKey struct{}
+
+// WithLimits returns a context under which the paths are resolved within l.
+func WithLimits(ctx context.Context, l Limits) context.Context {
+	return context.WithValue(ctx, limitsKey{}, l)
+}
+
+// LimitsFromContext returns the limits set by WithLimits, if any.
+func LimitsFromContext(ctx context.Context) Limits {
+	l, _ := ctx.Value(limitsKey{}).(Limits)
+	return l
+}
+
+// checkSegments returns a LimitError if parts, the segments of a path after
+// its root, are too many under ctx.
+func checkSegments(ctx context.Context, parts []string) error {
+	l := LimitsFromContext(ctx)
+	if l.MaxSegments > 0 && len(parts) > l.MaxSegments {
+		return &LimitError{Limit: "path segments", Max: l.MaxSegments}
+	}
+	return nil
+}
+
+// depthLimitedDAG counts the nodes fetched through it, and fails past max.
+type depthLimitedDAG struct {
+	dag.DAGService
+	max     int
+	fetched int
+}
+
+// limitDepth returns ds, counting the links followed through it against the
+// limit set in ctx, if any.
+func limitDepth(ctx context.Context, ds dag.DAGService) dag.DAGService {
+	l := LimitsFromContext(ctx)
+	if l.MaxLinkDepth <= 0 {
+		return ds
+	}
+	return &depthLimitedDAG{DAGService: ds, max: l.MaxLinkDepth}
+}
+
+func (d *depthLimitedDAG) Get(ctx context.Context, c *cid.Cid) (node.Node, error) {
+	d.fetched++
+	if d.fetched > d.max {
+		return nil, &LimitError{Limit: "links followed", Max: d.max}
+	}
+	return d.DAGService.Get(ctx, c)
+}
@@ -0,0 +1,46 @@
+package path
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// NamespaceResolver resolves the paths of a namespace other than /ipfs and
+// /ipns, such as /mfs, to paths of another namespace.
+type NamespaceResolver struct {
+	// TTL is how long the resolutions are cached; zero disables caching.
+	TTL time.Duration
+
+	Resolve func(ctx context.Context, p Path) (Path, error)
+}
+
+var namespaces = struct {
+	sync.RWMutex
+	names map[string]struct{}
+}{names: make(map[string]struct{})}
+
+// RegisterNamespace makes ParsePath accept the paths starting with
+// /<name>/, for a namespace resolved by a NamespaceResolver.
+func RegisterNamespace(name string) error {
+	if name == "" || name == "ipfs" || name == "ipns" {
+		return fmt.Errorf("invalid namespace name %q", name)
+	}
+
+	namespaces.Lock()
+	defer namespaces.Unlock()
+
+	if _, ok := namespaces.names[name]; ok {
+		return fmt.Errorf("namespace %s is already registered", name)
+	}
+	namespaces.names[name] = struct{}{}
+	return nil
+}
+
+func isNamespace(name string) bool {
+	namespaces.RLock()
+	defer namespaces.RUnlock()
+	_, ok := namespaces.names[name]
+	return ok
+}
@@ -96,7 +96,7 @@ func ParsePath(txt string) (Path, error) {
 		if _, err := ParseCidToPath(parts[2]); err != nil {
 			return "", err
 		}
-	} else if parts[1] != "ipns" {
+	} else if parts[1] != "ipns" && !isNamespace(parts[1]) {
 		return "", ErrBadPath
 	}
 
@@ -5,6 +5,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	dag "github.com/ipfs/go-ipfs/merkledag"
@@ -16,6 +17,9 @@ import (
 
 var log = logging.Logger("path")
 
+// maxNamespaceDepth bounds the namespaces a path is resolved through.
+const maxNamespaceDepth = 8
+
 // Paths after a protocol must contain at least one component
 var ErrNoComponents = errors.New(
 	"path must contain at least one component")
@@ -38,6 +42,14 @@ type Resolver struct {
 	DAG dag.DAGService
 
 	ResolveOnce func(ctx context.Context, ds dag.DAGService, nd node.Node, names []string) (*node.Link, []string, error)
+
+	// Cache, if set, keeps what paths resolve to, so that resolving them
+	// again, or paths below them, skips the nodes on the way.
+	Cache *Cache
+
+	// Namespaces resolve the registered namespaces other than /ipfs and
+	// /ipns, by name.
+	Namespaces map[string]NamespaceResolver
 }
 
 func NewBasicResolver(ds dag.DAGService) *Resolver {
@@ -78,12 +90,16 @@ func (r *Resolver) ResolveToLastNode(ctx context.Context, fpath Path) (node.Node
 	if err != nil {
 		return nil, nil, err
 	}
+	if err := checkSegments(ctx, p); err != nil {
+		return nil, nil, err
+	}
 
 	nd, err := r.DAG.Get(ctx, c)
 	if err != nil {
 		return nil, nil, err
 	}
 
+	ds := limitDepth(ctx, r.DAG)
 	for len(p) > 0 {
 		val, rest, err := nd.Resolve(p)
 		if err != nil {
@@ -92,7 +108,7 @@ func (r *Resolver) ResolveToLastNode(ctx context.Context, fpath Path) (node.Node
 
 		switch val := val.(type) {
 		case *node.Link:
-			next, err := val.GetNode(ctx, r.DAG)
+			next, err := val.GetNode(ctx, ds)
 			if err != nil {
 				return nil, nil, err
 			}
@@ -113,6 +129,15 @@ func (s *Resolver) ResolvePath(ctx context.Context, fpath Path) (node.Node, erro
 	if err := fpath.IsValid(); err != nil {
 		return nil, err
 	}
+	if _, parts, err := SplitAbsPath(fpath); err == nil {
+		if err := checkSegments(ctx, parts); err != nil {
+			return nil, err
+		}
+	}
+
+	if s.Cache != nil {
+		return s.resolveCached(ctx, fpath)
+	}
 
 	nodes, err := s.ResolvePathComponents(ctx, fpath)
 	if err != nil || nodes == nil {
@@ -121,6 +146,86 @@ func (s *Resolver) ResolvePath(ctx context.Context, fpath Path) (node.Node, erro
 	return nodes[len(nodes)-1], err
 }
 
+// resolveCached resolves fpath from its longest prefix found in the cache,
+// and caches the prefixes it resolves on the way.
+func (s *Resolver) resolveCached(ctx context.Context, fpath Path) (node.Node, error) {
+	c, parts, err := SplitAbsPath(fpath)
+	if err != nil {
+		return nil, err
+	}
+
+	root := FromCid(c).String()
+	prefix := func(i int) Path {
+		return Path(strings.Join(append([]string{root}, parts[:i]...), "/"))
+	}
+
+	start := 0
+	for i := len(parts); i > 0; i-- {
+		to, ok := s.Cache.Get(prefix(i))
+		if !ok {
+			continue
+		}
+		if cc, rest, err := SplitAbsPath(to); err == nil && len(rest) == 0 {
+			c, start = cc, i
+			break
+		}
+	}
+
+	nd, err := s.DAG.Get(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes, err := s.ResolveLinks(ctx, nd, parts[start:])
+	if err != nil {
+		return nil, err
+	}
+
+	// nodes match names one to one, unless a hop went through several
+	// names at once, as in sharded directories
+	last := nodes[len(nodes)-1]
+	if len(nodes) == len(parts)-start+1 {
+		for i := start + 1; i <= len(parts); i++ {
+			s.Cache.Put(prefix(i), FromCid(nodes[i-start].Cid()), 0)
+		}
+	} else {
+		s.Cache.Put(prefix(len(parts)), FromCid(last.Cid()), 0)
+	}
+	return last, nil
+}
+
+// ResolveNamespace resolves the paths of the namespaces in s.Namespaces to
+// the paths they point to, possibly through several namespaces. Other paths
+// are returned unchanged.
+func (s *Resolver) ResolveNamespace(ctx context.Context, p Path) (Path, error) {
+	for depth := 0; ; depth++ {
+		seg := p.Segments()
+		ns, ok := s.Namespaces[seg[0]]
+		if !ok {
+			return p, nil
+		}
+		if depth >= maxNamespaceDepth {
+			return "", fmt.Errorf("could not resolve %s within %d namespaces", p, maxNamespaceDepth)
+		}
+
+		if s.Cache != nil {
+			if to, ok := s.Cache.Get(p); ok {
+				p = to
+				continue
+			}
+		}
+
+		to, err := ns.Resolve(ctx, p)
+		if err != nil {
+			return "", err
+		}
+		if s.Cache != nil && ns.TTL > 0 {
+			s.Cache.Put(p, to, ns.TTL)
+		}
+		p = to
+	}
+}
+
 // ResolveSingle simply resolves one hop of a path through a graph with no
 // extra context (does not opaquely resolve through sharded nodes)
 func ResolveSingle(ctx context.Context, ds dag.DAGService, nd node.Node, names []string) (*node.Link, []string, error) {
@@ -135,6 +240,9 @@ func (s *Resolver) ResolvePathComponents(ctx context.Context, fpath Path) ([]nod
 	if err != nil {
 		return nil, err
 	}
+	if err := checkSegments(ctx, parts); err != nil {
+		return nil, err
+	}
 
 	log.Debug("resolve dag get")
 	nd, err := s.DAG.Get(ctx, h)
@@ -148,7 +256,8 @@ func (s *Resolver) ResolvePathComponents(ctx context.Context, fpath Path) ([]nod
 // ResolveLinks iteratively resolves names by walking the link hierarchy.
 // Every node is fetched from the DAGService, resolving the next name.
 // Returns the list of nodes forming the path, starting with ndd. This list is
-// guaranteed never to be empty.
+// guaranteed never to be empty. The links followed are bounded by the Limits
+// of ctx, if any.
 //
 // ResolveLinks(nd, []string{"foo", "bar", "baz"})
 // would retrieve "baz" in ("bar" in ("foo" in nd.Links).Links).Links
@@ -157,6 +266,7 @@ func (s *Resolver) ResolveLinks(ctx context.Context, ndd node.Node, names []stri
 	result := make([]node.Node, 0, len(names)+1)
 	result = append(result, ndd)
 	nd := ndd // dup arg workaround
+	ds := limitDepth(ctx, s.DAG)
 
 	// for each of the path components
 	for len(names) > 0 {
@@ -164,14 +274,14 @@ func (s *Resolver) ResolveLinks(ctx context.Context, ndd node.Node, names []stri
 		ctx, cancel = context.WithTimeout(ctx, time.Minute)
 		defer cancel()
 
-		lnk, rest, err := s.ResolveOnce(ctx, s.DAG, nd, names)
+		lnk, rest, err := s.ResolveOnce(ctx, ds, nd, names)
 		if err == dag.ErrLinkNotFound {
 			return result, ErrNoLink{Name: names[0], Node: nd.Cid()}
 		} else if err != nil {
 			return result, err
 		}
 
-		nextnode, err := lnk.GetNode(ctx, s.DAG)
+		nextnode, err := lnk.GetNode(ctx, ds)
 		if err != nil {
 			return result, err
 		}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"testing"
+	"time"
 
 	merkledag "github.com/ipfs/go-ipfs/merkledag"
 	dagmock "github.com/ipfs/go-ipfs/merkledag/test"
@@ -67,3 +68,157 @@ func TestRecurivePathResolution(t *testing.T) {
 			p.String(), key.String(), cKey.String()))
 	}
 }
+
+func TestCachedPathResolution(t *testing.T) {
+	ctx := context.Background()
+	dagService := dagmock.Mock()
+
+	a := randNode()
+	b := randNode()
+	c := randNode()
+	if err := b.AddNodeLink("grandchild", c); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.AddNodeLink("child", b); err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []node.Node{a, b, c} {
+		if _, err := dagService.Add(n); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cache, err := path.NewCache(16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolver := path.NewBasicResolver(dagService)
+	resolver.Cache = cache
+
+	p := path.FromCid(a.Cid()) + "/child/grandchild"
+	nd, err := resolver.ResolvePath(ctx, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !nd.Cid().Equals(c.Cid()) {
+		t.Fatalf("expected %s, got %s", c.Cid(), nd.Cid())
+	}
+
+	for _, e := range []struct {
+		p  path.Path
+		to path.Path
+	}{
+		{path.FromCid(a.Cid()) + "/child", path.FromCid(b.Cid())},
+		{p, path.FromCid(c.Cid())},
+	} {
+		to, ok := cache.Get(e.p)
+		if !ok || to != e.to {
+			t.Fatalf("expected %s to be cached as %s, got %s", e.p, e.to, to)
+		}
+	}
+
+	// the cached resolution is used even once the middle node is gone
+	if err := dagService.Remove(b); err != nil {
+		t.Fatal(err)
+	}
+	nd, err = resolver.ResolvePath(ctx, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !nd.Cid().Equals(c.Cid()) {
+		t.Fatalf("expected %s, got %s", c.Cid(), nd.Cid())
+	}
+}
+
+func TestResolveNamespace(t *testing.T) {
+	if err := path.RegisterNamespace("test"); err != nil {
+		t.Fatal(err)
+	}
+	if err := path.RegisterNamespace("ipns"); err == nil {
+		t.Fatal("expected /ipns to be refused as a custom namespace")
+	}
+
+	target := path.FromCid(randNode().Cid())
+	p, err := path.ParsePath("/test/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cache, err := path.NewCache(16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := 0
+	resolver := path.NewBasicResolver(dagmock.Mock())
+	resolver.Cache = cache
+	resolver.Namespaces = map[string]path.NamespaceResolver{
+		"test": {
+			TTL: time.Minute,
+			Resolve: func(ctx context.Context, p path.Path) (path.Path, error) {
+				calls++
+				return target, nil
+			},
+		},
+	}
+
+	for i := 0; i < 2; i++ {
+		to, err := resolver.ResolveNamespace(context.Background(), p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if to != target {
+			t.Fatalf("expected %s, got %s", target, to)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("expected the resolution to be cached, resolved %d times", calls)
+	}
+
+	cache.Invalidate("test")
+	if _, err := resolver.ResolveNamespace(context.Background(), p); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Fatalf("expected the invalidated resolution to be resolved again, resolved %d times", calls)
+	}
+}
+
+func TestResolutionLimits(t *testing.T) {
+	dagService := dagmock.Mock()
+
+	a := randNode()
+	b := randNode()
+	c := randNode()
+	if err := b.AddNodeLink("grandchild", c); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.AddNodeLink("child", b); err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []node.Node{a, b, c} {
+		if _, err := dagService.Add(n); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p, err := path.FromSegments("/ipfs/", a.Cid().String(), "child", "grandchild")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolver := path.NewBasicResolver(dagService)
+
+	for _, c := range []struct {
+		limits path.Limits
+		ok     bool
+	}{
+		{path.Limits{MaxSegments: 2, MaxLinkDepth: 2}, true},
+		{path.Limits{MaxSegments: 1}, false},
+		{path.Limits{MaxLinkDepth: 1}, false},
+	} {
+		ctx := path.WithLimits(context.Background(), c.limits)
+		_, err := resolver.ResolvePath(ctx, p)
+		if _, limited := err.(*path.LimitError); c.ok && err != nil || !c.ok && !limited {
+			t.Fatalf("unexpected error resolving within %+v: %v", c.limits, err)
+		}
+	}
+}
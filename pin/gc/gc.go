@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync/atomic"
 
 	bstore "github.com/ipfs/go-ipfs/blocks/blockstore"
 	dag "github.com/ipfs/go-ipfs/merkledag"
@@ -14,9 +15,11 @@ import (
 )
 
 // Result represents an incremental output from a garbage collection
-// run.  It contains either an error, or the cid of a removed object.
+// run.  It contains either an error, or the cid of a removed object and
+// its size.
 type Result struct {
 	KeyRemoved *cid.Cid
+	Size       uint64
 	Error      error
 }
 
@@ -27,6 +30,8 @@ type Result struct {
 // - all directly pinned blocks
 // - all blocks utilized internally by the pinner
 //
+// The marking walks the dags concurrently, see Descendants and MarkSet.
+//
 // The routine then iterates over every block in the blockstore and
 // deletes any block that is not found in the marked set.
 //
@@ -62,6 +67,12 @@ func GC(ctx context.Context, bs bstore.GCBlockstore, ls dag.LinkService, pn pin.
 					break loop
 				}
 				if !gcs.Has(k) {
+					// the size is only informative, a block which can't
+					// be read is still removed
+					var size uint64
+					if b, err := bs.Get(k); err == nil {
+						size = uint64(len(b.RawData()))
+					}
 					err := bs.DeleteBlock(k)
 					if err != nil {
 						errors = true
@@ -71,7 +82,7 @@ func GC(ctx context.Context, bs bstore.GCBlockstore, ls dag.LinkService, pn pin.
 						continue loop
 					}
 					select {
-					case output <- Result{KeyRemoved: k}:
+					case output <- Result{KeyRemoved: k, Size: size}:
 					case <-ctx.Done():
 						break loop
 					}
@@ -88,52 +99,37 @@ func GC(ctx context.Context, bs bstore.GCBlockstore, ls dag.LinkService, pn pin.
 	return output
 }
 
-func Descendants(ctx context.Context, getLinks dag.GetLinks, set *cid.Set, roots []*cid.Cid) error {
-	for _, c := range roots {
-		set.Add(c)
-
-		// EnumerateChildren recursively walks the dag and adds the keys to the given set
-		err := dag.EnumerateChildren(ctx, getLinks, c, set.Visit)
-		if err != nil {
-			return err
-		}
-	}
-
-	return nil
-}
-
 // ColoredSet computes the set of nodes in the graph that are pinned by the
 // pins in the given pinner.
-func ColoredSet(ctx context.Context, pn pin.Pinner, ls dag.LinkService, bestEffortRoots []*cid.Cid, output chan<- Result) (*cid.Set, error) {
-	// KeySet currently implemented in memory, in the future, may be bloom filter or
-	// disk backed to conserve memory.
-	errors := false
-	gcs := cid.NewSet()
+func ColoredSet(ctx context.Context, pn pin.Pinner, ls dag.LinkService, bestEffortRoots []*cid.Cid, output chan<- Result) (*MarkSet, error) {
+	// links are fetched concurrently, hence the atomic flag
+	var failed int32
+	gcs := NewMarkSet()
 	getLinks := func(ctx context.Context, cid *cid.Cid) ([]*node.Link, error) {
 		links, err := ls.GetLinks(ctx, cid)
 		if err != nil {
-			errors = true
+			atomic.StoreInt32(&failed, 1)
 			output <- Result{Error: &CannotFetchLinksError{cid, err}}
 		}
 		return links, nil
 	}
 	err := Descendants(ctx, getLinks, gcs, pn.RecursiveKeys())
 	if err != nil {
-		errors = true
+		atomic.StoreInt32(&failed, 1)
 		output <- Result{Error: err}
 	}
 
 	bestEffortGetLinks := func(ctx context.Context, cid *cid.Cid) ([]*node.Link, error) {
 		links, err := ls.GetLinks(ctx, cid)
 		if err != nil && err != dag.ErrNotFound {
-			errors = true
+			atomic.StoreInt32(&failed, 1)
 			output <- Result{Error: &CannotFetchLinksError{cid, err}}
 		}
 		return links, nil
 	}
 	err = Descendants(ctx, bestEffortGetLinks, gcs, bestEffortRoots)
 	if err != nil {
-		errors = true
+		atomic.StoreInt32(&failed, 1)
 		output <- Result{Error: err}
 	}
 
@@ -143,11 +139,11 @@ func ColoredSet(ctx context.Context, pn pin.Pinner, ls dag.LinkService, bestEffo
 
 	err = Descendants(ctx, getLinks, gcs, pn.InternalPins())
 	if err != nil {
-		errors = true
+		atomic.StoreInt32(&failed, 1)
 		output <- Result{Error: err}
 	}
 
-	if errors {
+	if atomic.LoadInt32(&failed) != 0 {
 		return nil, ErrCannotFetchAllLinks
 	}
 
@@ -0,0 +1,153 @@
+package gc
+
+import (
+	"context"
+	"sync"
+
+	dag "github.com/ipfs/go-ipfs/merkledag"
+
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+	bloom "gx/ipfs/QmeiMCBkYHxkDkDfnDadzz4YxY5ruL5Pj499essE4vRsGM/bbloom"
+)
+
+// MarkConcurrency is the number of links fetched concurrently while marking
+// the blocks to keep.
+var MarkConcurrency = 8
+
+// MarkBloomCapacity is the number of blocks the first bloom filter of a
+// MarkSet is sized for. Once it is full, a filter twice as large is added,
+// and so on, so that repos of any size get a reasonable false positive rate
+// without having to be counted first.
+var MarkBloomCapacity = 1 << 20
+
+// markBloomFPRate is the false positive rate of the first filter; every
+// following filter gets half the rate of the previous one, which bounds the
+// overall rate to twice this.
+const markBloomFPRate = 0.01
+
+// MarkSet is the set of blocks marked to be kept during a garbage collection.
+//
+// Every marked block is added to a chain of bloom filters, which is what the
+// sweep consults: a false positive only keeps a block that could have been
+// removed. The walk needs an exact answer, as skipping a block it has not
+// walked would leave its descendants unmarked, so the blocks that have links
+// are also kept in an exact set. Leaves, the bulk of most repos, thus only
+// cost a few bits each, instead of a map entry.
+type MarkSet struct {
+	lk sync.Mutex
+
+	blooms []*bloom.Bloom
+	// capacity and fpRate of the last filter
+	capacity int
+	fpRate   float64
+	// count of blocks added to the last filter
+	count int
+
+	// walked holds the blocks with links, and the ones waiting to be walked
+	walked *cid.Set
+}
+
+// NewMarkSet returns an empty MarkSet.
+func NewMarkSet() *MarkSet {
+	return &MarkSet{walked: cid.NewSet()}
+}
+
+// Add marks c, without walking it.
+func (s *MarkSet) Add(c *cid.Cid) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	k := c.Bytes()
+	if !s.bloomHas(k) {
+		s.bloomAdd(k)
+	}
+}
+
+// Has tells whether c is marked. It may wrongly return true for a small
+// fraction of the blocks that are not, but never wrongly returns false.
+func (s *MarkSet) Has(c *cid.Cid) bool {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	return s.bloomHas(c.Bytes())
+}
+
+// visit marks c, and tells whether it has to be walked: only the blocks
+// known to have been walked, or to be waiting for it, are skipped.
+func (s *MarkSet) visit(c *cid.Cid) bool {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	k := c.Bytes()
+	if !s.bloomHas(k) {
+		s.bloomAdd(k)
+	} else if s.walked.Has(c) {
+		return false
+	}
+	// either new, a leaf walked before, or a false positive: walking a leaf
+	// twice is cheaper than remembering it
+	s.walked.Add(c)
+	return true
+}
+
+// walkedLeaf forgets about c having been walked, as it has no links.
+func (s *MarkSet) walkedLeaf(c *cid.Cid) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	s.walked.Remove(c)
+}
+
+func (s *MarkSet) bloomHas(k []byte) bool {
+	for _, bl := range s.blooms {
+		if bl.Has(k) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *MarkSet) bloomAdd(k []byte) {
+	if len(s.blooms) == 0 || s.count >= s.capacity {
+		capacity, fpRate := MarkBloomCapacity, markBloomFPRate
+		if len(s.blooms) > 0 {
+			capacity, fpRate = 2*s.capacity, s.fpRate/2
+		}
+		bl, err := bloom.New(float64(capacity), fpRate)
+		if err != nil {
+			// only happens with invalid parameters, which these are not
+			panic(err)
+		}
+		s.blooms = append(s.blooms, bl)
+		s.capacity, s.fpRate, s.count = capacity, fpRate, 0
+	}
+	s.blooms[len(s.blooms)-1].Add(k)
+	s.count++
+}
+
+// Descendants marks the given roots and all of their descendants in set,
+// fetching links with MarkConcurrency workers.
+func Descendants(ctx context.Context, getLinks dag.GetLinks, set *MarkSet, roots []*cid.Cid) error {
+	var items []dag.WalkItem
+	for _, c := range roots {
+		if set.visit(c) {
+			items = append(items, dag.WalkItem{Cid: c})
+		}
+	}
+
+	return dag.WalkConcurrent(ctx, items, MarkConcurrency, func(ctx context.Context, it dag.WalkItem) ([]dag.WalkItem, error) {
+		links, err := getLinks(ctx, it.Cid)
+		if err != nil {
+			return nil, err
+		}
+		if len(links) == 0 {
+			set.walkedLeaf(it.Cid)
+		}
+
+		var next []dag.WalkItem
+		for _, l := range links {
+			if set.visit(l.Cid) {
+				next = append(next, dag.WalkItem{Cid: l.Cid})
+			}
+		}
+		return next, nil
+	})
+}
@@ -0,0 +1,96 @@
+package gc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	mdtest "github.com/ipfs/go-ipfs/merkledag/test"
+
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+	node "gx/ipfs/Qmb3Hm9QDFmfYuET4pu7Kyg8JV78jFa1nvZx5vnCZsK4ck/go-ipld-format"
+)
+
+// makeTree adds a tree of the given depth and fanout, sharing the shared
+// node at every level, and returns its root and all of its cids.
+func makeTree(t *testing.T, ds dag.DAGService, prefix string, depth, fanout int, shared *dag.ProtoNode) (*cid.Cid, []*cid.Cid) {
+	nd := dag.NodeWithData([]byte(prefix))
+	var all []*cid.Cid
+	if depth > 0 {
+		for i := 0; i < fanout; i++ {
+			c, sub := makeTree(t, ds, fmt.Sprintf("%s/%d", prefix, i), depth-1, fanout, shared)
+			child, err := ds.Get(context.Background(), c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := nd.AddNodeLink(fmt.Sprint(i), child); err != nil {
+				t.Fatal(err)
+			}
+			all = append(all, sub...)
+		}
+		if err := nd.AddNodeLink("shared", shared); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c, err := ds.Add(nd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, append(all, c)
+}
+
+func TestDescendantsMarksAll(t *testing.T) {
+	defer func(c int) { MarkBloomCapacity = c }(MarkBloomCapacity)
+	// force the set through several filters
+	MarkBloomCapacity = 16
+
+	ds := mdtest.Mock()
+	shared := dag.NodeWithData([]byte("shared"))
+	if _, err := ds.Add(shared); err != nil {
+		t.Fatal(err)
+	}
+
+	var roots, all []*cid.Cid
+	for _, name := range []string{"a", "b", "c"} {
+		root, sub := makeTree(t, ds, name, 3, 4, shared)
+		roots = append(roots, root)
+		all = append(all, sub...)
+	}
+	all = append(all, shared.Cid())
+
+	set := NewMarkSet()
+	if err := Descendants(context.Background(), dag.GetLinksDirect(ds), set, roots); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range all {
+		if !set.Has(c) {
+			t.Fatalf("%s not marked", c)
+		}
+	}
+	if len(set.blooms) < 2 {
+		t.Fatalf("expected the set to have grown, it has %d filters", len(set.blooms))
+	}
+	// only the nodes with links should be remembered exactly
+	if set.walked.Has(shared.Cid()) {
+		t.Fatal("leaf kept in the exact set")
+	}
+}
+
+func TestDescendantsError(t *testing.T) {
+	ds := mdtest.Mock()
+	root, _ := makeTree(t, ds, "a", 2, 3, dag.NodeWithData([]byte("shared")))
+
+	fail := errors.New("failed")
+	getLinks := func(ctx context.Context, c *cid.Cid) ([]*node.Link, error) {
+		if !c.Equals(root) {
+			return nil, fail
+		}
+		return dag.GetLinksDirect(ds)(ctx, c)
+	}
+
+	if err := Descendants(context.Background(), getLinks, NewMarkSet(), []*cid.Cid{root}); err != fail {
+		t.Fatalf("expected %v, got %v", fail, err)
+	}
+}
@@ -0,0 +1,110 @@
+package pin
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	mdag "github.com/ipfs/go-ipfs/merkledag"
+
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+)
+
+// JobTracker tracks the pins being added, which may fetch their blocks for
+// long, so that they can be listed and canceled.
+type JobTracker struct {
+	lk   sync.Mutex
+	next int
+	jobs map[int]*Job
+}
+
+// Job is a pin being added.
+type Job struct {
+	ID        int
+	Cid       *cid.Cid
+	Recursive bool
+	Started   time.Time
+	// Resumed is true for the pins interrupted by the shutdown of the node,
+	// and resumed by the next daemon.
+	Resumed bool
+
+	progress *mdag.ProgressTracker
+	cancel   context.CancelFunc
+}
+
+// Fetched returns the number of nodes fetched, or found locally, by the job.
+func (j *Job) Fetched() int {
+	return j.progress.Value()
+}
+
+// NewJobTracker returns a tracker of no jobs.
+func NewJobTracker() *JobTracker {
+	return &JobTracker{jobs: make(map[int]*Job)}
+}
+
+// Start tracks the pin of c until Done, and returns the context to pin it
+// with, which the job cancels. The progress of the pin is counted on the
+// progress tracker of ctx, if it has one.
+func (t *JobTracker) Start(ctx context.Context, c *cid.Cid, recursive, resumed bool) (*Job, context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	progress, ok := ctx.Value("progress").(*mdag.ProgressTracker)
+	if !ok {
+		progress = new(mdag.ProgressTracker)
+		ctx = progress.DeriveContext(ctx)
+	}
+
+	t.lk.Lock()
+	defer t.lk.Unlock()
+	t.next++
+	j := &Job{
+		ID:        t.next,
+		Cid:       c,
+		Recursive: recursive,
+		Started:   time.Now(),
+		Resumed:   resumed,
+		progress:  progress,
+		cancel:    cancel,
+	}
+	t.jobs[j.ID] = j
+	return j, ctx
+}
+
+// Done stops tracking j.
+func (t *JobTracker) Done(j *Job) {
+	t.lk.Lock()
+	defer t.lk.Unlock()
+	delete(t.jobs, j.ID)
+	j.cancel()
+}
+
+// Jobs returns the jobs tracked, oldest first.
+func (t *JobTracker) Jobs() []*Job {
+	t.lk.Lock()
+	defer t.lk.Unlock()
+	jobs := make([]*Job, 0, len(t.jobs))
+	for _, j := range t.jobs {
+		jobs = append(jobs, j)
+	}
+	sort.Sort(jobsByID(jobs))
+	return jobs
+}
+
+// Cancel cancels the job id, and returns it, or nil if there is no such
+// job.
+func (t *JobTracker) Cancel(id int) *Job {
+	t.lk.Lock()
+	defer t.lk.Unlock()
+	j, ok := t.jobs[id]
+	if !ok {
+		return nil
+	}
+	j.cancel()
+	return j
+}
+
+type jobsByID []*Job
+
+func (j jobsByID) Len() int           { return len(j) }
+func (j jobsByID) Swap(a, b int)      { j[a], j[b] = j[b], j[a] }
+func (j jobsByID) Less(a, b int) bool { return j[a].ID < j[b].ID }
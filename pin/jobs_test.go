@@ -0,0 +1,45 @@
+package pin
+
+import (
+	"context"
+	"testing"
+
+	mdag "github.com/ipfs/go-ipfs/merkledag"
+)
+
+func TestJobTracker(t *testing.T) {
+	jt := NewJobTracker()
+	a := mdag.NodeWithData([]byte("a")).Cid()
+	b := mdag.NodeWithData([]byte("b")).Cid()
+
+	ja, actx := jt.Start(context.Background(), a, true, false)
+	progress := new(mdag.ProgressTracker)
+	jb, _ := jt.Start(progress.DeriveContext(context.Background()), b, false, true)
+
+	jobs := jt.Jobs()
+	if len(jobs) != 2 || jobs[0] != ja || jobs[1] != jb {
+		t.Fatalf("expected the jobs of a and b, in order, got %v", jobs)
+	}
+
+	// the progress of b is counted on the tracker of its context
+	progress.Increment()
+	if jb.Fetched() != 1 || ja.Fetched() != 0 {
+		t.Fatalf("unexpected progress: a %d, b %d", ja.Fetched(), jb.Fetched())
+	}
+
+	if jt.Cancel(ja.ID) != ja {
+		t.Fatal("expected to cancel the job of a")
+	}
+	if actx.Err() != context.Canceled {
+		t.Fatal("expected the context of a to be canceled")
+	}
+	if jt.Cancel(42) != nil {
+		t.Fatal("canceled a job which does not exist")
+	}
+
+	jt.Done(ja)
+	jt.Done(jb)
+	if jobs := jt.Jobs(); len(jobs) != 0 {
+		t.Fatalf("expected no jobs, got %v", jobs)
+	}
+}
@@ -5,4 +5,11 @@ type Addresses struct {
 	Swarm   []string // addresses for the swarm network
 	API     string   // address for the local API (RPC)
 	Gateway string   // address to listen on for IPFS HTTP object gateway
+
+	// Announce replaces the swarm addresses announced to peers. Addresses
+	// may use the {public-ip4} and {public-ip6} placeholders, and
+	// unspecified IPs for every interface.
+	Announce []string `json:",omitempty"`
+	// NoAnnounce lists swarm addresses, or ipcidr masks, never announced.
+	NoAnnounce []string `json:",omitempty"`
 }
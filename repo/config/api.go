@@ -1,5 +1,26 @@
 package config
 
 type API struct {
-	HTTPHeaders map[string][]string // HTTP headers to return with the API.
+	HTTPHeaders   map[string][]string // HTTP headers to return with the API.
+	WebUIWritable bool                // allow the WebUI endpoints to change pins and config
+
+	// CommandLimits caps the executions at once of the commands, by path
+	// such as "repo/gc". The requests over the limit are queued, and served
+	// by caller in turn.
+	CommandLimits map[string]int `json:",omitempty"`
+
+	// Idempotency bounds the results kept to answer the retries of the
+	// requests carrying an Idempotency-Key header.
+	Idempotency Idempotency
+}
+
+// Idempotency is the cache of the results of the requests of the mutating
+// commands carrying an Idempotency-Key header.
+type Idempotency struct {
+	// MaxEntries caps the results kept, 1000 if 0; the header is ignored
+	// if it is negative.
+	MaxEntries int `json:",omitempty"`
+
+	// TTL is how long a result is kept, as "24h", the default.
+	TTL string `json:",omitempty"`
 }
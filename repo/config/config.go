@@ -20,14 +20,23 @@ type Config struct {
 	Mounts           Mounts                // local node's mount points
 	Discovery        Discovery             // local node's discovery mechanisms
 	Ipns             Ipns                  // Ipns settings
+	DNS              DNS                   // DNSLink resolution settings
 	Bootstrap        []string              // local nodes's bootstrap peer addresses
 	Tour             Tour                  // local node's tour position
 	Gateway          Gateway               // local node's gateway server options
 	SupernodeRouting SupernodeClientConfig // local node's routing servers (if SupernodeRouting enabled)
+	Routing          Routing               // local node's router stack
 	API              API                   // local node's API settings
 	Swarm            SwarmConfig
 
+	// Remotes are the daemons the CLI knows by name
+	Remotes map[string]Remote `json:",omitempty"`
+
 	Reprovider   Reprovider
+	Memory       Memory
+	Import       Import
+	Keystore     Keystore
+	Pinning      Pinning
 	Experimental Experiments
 }
 
@@ -17,8 +17,55 @@ type Datastore struct {
 
 	Params          *json.RawMessage
 	NoSync          bool
+	Durability      string // "strict", "batch" or "none"
 	HashOnRead      bool
 	BloomFilterSize int
+	SlowOpThreshold string // in ns, us, ms, s, m, h
+
+	// DeniedHashes are the hash functions, named as in multihash, of the
+	// blocks which are neither stored nor served, such as "sha1".
+	DeniedHashes []string `json:",omitempty"`
+	// VerifyOnReceive verifies the blocks received from the network
+	// before they are stored.
+	VerifyOnReceive bool
+
+	Eviction Eviction
+	Journal  Journal
+	Scrub    Scrub
+}
+
+// Durability levels of the blocks written to the datastore.
+const (
+	// DurabilityStrict syncs each write before it completes; the blocks
+	// of a batch are synced together.
+	DurabilityStrict = "strict"
+	// DurabilityBatch syncs the writes in groups, soon after they complete.
+	DurabilityBatch = "batch"
+	// DurabilityNone leaves writes to the operating system, like NoSync.
+	DurabilityNone = "none"
+)
+
+// Eviction removes unpinned blocks continuously, to keep the repo within a
+// budget without full garbage collections.
+type Eviction struct {
+	Policy   string // "lru", "lfu", or "" to disable eviction
+	Budget   string // in B, kB, kiB, MB, ...
+	Interval string // in ns, us, ms, s, m, h
+}
+
+// Journal records the blocks added to and removed from the repo, for
+// incremental exports.
+type Journal struct {
+	Enabled   bool
+	Retention string // in ns, us, ms, s, m, h; "0" to keep every entry
+}
+
+// Scrub verifies the blocks of the repo against their hashes in the
+// background, to detect their corruption on disk.
+type Scrub struct {
+	// DailyFraction is the part of the blocks verified a day, such as 0.1
+	// to verify them all every ten days; 0 disables the scrubbing.
+	DailyFraction float64
 }
 
 func (d *Datastore) ParamData() []byte {
@@ -0,0 +1,9 @@
+package config
+
+// DNS contains options for resolving DNSLink names.
+type DNS struct {
+	// Resolvers maps domain suffixes (e.g. "eth", or "." for all other
+	// names) to the resolver used for them: "system", a UDP server address
+	// such as "8.8.8.8:53", or a DNS-over-HTTPS URL.
+	Resolvers map[string]string
+}
@@ -6,4 +6,32 @@ type Gateway struct {
 	RootRedirect string
 	Writable     bool
 	PathPrefixes []string
+	Previews     GatewayPreviews
+
+	// MimeTypes maps file extensions, such as ".md", to the content type
+	// files with them are served with, overriding the built-in ones.
+	MimeTypes map[string]string `json:",omitempty"`
+
+	// MaxPathSegments, MaxLinkDepth and MaxDirectoryEntries bound the work
+	// of each request, so that crafted dags can't exhaust the gateway. 0
+	// sets no limit.
+	MaxPathSegments     int `json:",omitempty"`
+	MaxLinkDepth        int `json:",omitempty"`
+	MaxDirectoryEntries int `json:",omitempty"`
+}
+
+// GatewayPreviews configures the thumbnails of images and videos shown in
+// directory listings and served for '?preview=1'.
+type GatewayPreviews struct {
+	Enabled bool
+
+	// Size is the length in pixels of the longest side of thumbnails.
+	Size int `json:",omitempty"`
+
+	// MaxSourceSize is the size in bytes above which files get no thumbnail.
+	MaxSourceSize int64 `json:",omitempty"`
+
+	// FFmpeg is the path of the ffmpeg binary used for video thumbnails,
+	// which are disabled if it is empty.
+	FFmpeg string `json:",omitempty"`
 }
@@ -0,0 +1,13 @@
+package config
+
+// Import configures the adds of content the node fetches itself, as with
+// 'ipfs add --from-url'.
+type Import struct {
+	URLSchemes []string // schemes of the URLs fetched; http and https if empty
+	URLMaxSize string   // in B, kB, kiB, MB, ...; "0" for no limit
+	URLTimeout string   // in ns, us, ms, s, m, h; "0" for no timeout
+	// URLAllowPrivate allows the URLs of the hosts with private or loopback
+	// addresses, such as the ones of the local network, which are denied by
+	// default.
+	URLAllowPrivate bool
+}
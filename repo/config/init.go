@@ -15,7 +15,20 @@ func Init(out io.Writer, nBitsForKeypair int) (*Config, error) {
 	if err != nil {
 		return nil, err
 	}
+	return initWithIdentity(identity)
+}
+
+// InitWithKey returns a new config, for the node of the key sk.
+func InitWithKey(out io.Writer, sk ci.PrivKey) (*Config, error) {
+	identity, err := IdentityFromKey(sk)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(out, "peer identity: %s\n", identity.PeerID)
+	return initWithIdentity(identity)
+}
 
+func initWithIdentity(identity Identity) (*Config, error) {
 	bootstrapPeers, err := DefaultBootstrapPeers()
 	if err != nil {
 		return nil, err
@@ -101,12 +114,24 @@ func identityConfig(out io.Writer, nbits int) (Identity, error) {
 	}
 
 	fmt.Fprintf(out, "generating %v-bit RSA keypair...", nbits)
-	sk, pk, err := ci.GenerateKeyPair(ci.RSA, nbits)
+	sk, _, err := ci.GenerateKeyPair(ci.RSA, nbits)
 	if err != nil {
 		return ident, err
 	}
 	fmt.Fprintf(out, "done\n")
 
+	ident, err = IdentityFromKey(sk)
+	if err != nil {
+		return ident, err
+	}
+	fmt.Fprintf(out, "peer identity: %s\n", ident.PeerID)
+	return ident, nil
+}
+
+// IdentityFromKey returns the identity of the node of the key sk.
+func IdentityFromKey(sk ci.PrivKey) (Identity, error) {
+	ident := Identity{}
+
 	// currently storing key unencrypted. in the future we need to encrypt it.
 	// TODO(security)
 	skbytes, err := sk.Bytes()
@@ -115,11 +140,10 @@ func identityConfig(out io.Writer, nbits int) (Identity, error) {
 	}
 	ident.PrivKey = base64.StdEncoding.EncodeToString(skbytes)
 
-	id, err := peer.IDFromPublicKey(pk)
+	id, err := peer.IDFromPublicKey(sk.GetPublic())
 	if err != nil {
 		return ident, err
 	}
 	ident.PeerID = id.Pretty()
-	fmt.Fprintf(out, "peer identity: %s\n", ident.PeerID)
 	return ident, nil
 }
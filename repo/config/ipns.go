@@ -4,5 +4,22 @@ type Ipns struct {
 	RepublishPeriod string
 	RecordLifetime  string
 
-	ResolveCacheSize int
+	// RepublishResolved keeps the records of the names resolved through
+	// the routing system, and republishes them with the node's own while
+	// they are valid, for the names whose publishers are often offline.
+	RepublishResolved bool `json:",omitempty"`
+
+	ResolveCacheSize        int
+	ResolveCacheTTL         string // how long answers without a record TTL stay fresh
+	ResolveCacheStaleTTL    string // how long expired answers are served while refreshed
+	ResolveCacheNegativeTTL string // how long failed resolutions are remembered
+
+	// ResolveRecordCount is the number of records the resolutions through
+	// the DHT wait for, and ResolveTimeout how long they wait, the best of
+	// the records received being selected. 'ipfs name resolve
+	// --dht-record-count' and '--dht-timeout' override them.
+	ResolveRecordCount int    `json:",omitempty"`
+	ResolveTimeout     string `json:",omitempty"`
+
+	ExpiredKeys string // "warn" or "refuse" to publish with expired keys
 }
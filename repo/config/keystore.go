@@ -0,0 +1,49 @@
+package config
+
+// Keystore configures the storage of the keys of 'ipfs key'.
+type Keystore struct {
+	// Type is where the keys are kept: "fs", the default, for files in the
+	// keystore directory of the repo, "vault" for HashiCorp Vault, or
+	// "keychain" for the credential store of the OS.
+	Type string `json:",omitempty"`
+
+	// Encryption of the keys at rest, set by 'ipfs key encrypt'. The keys
+	// are stored in cleartext if nil.
+	Encryption *KeystoreEncryption `json:",omitempty"`
+
+	// Vault is the mount of the "vault" keystore.
+	Vault *KeystoreVault `json:",omitempty"`
+
+	// Keychain names the keys of the "keychain" keystore.
+	Keychain *KeystoreKeychain `json:",omitempty"`
+}
+
+// KeystoreVault is the Vault mount the keys are kept in. The token of Vault
+// is read from the environment, and is never stored in the config.
+type KeystoreVault struct {
+	Address string // such as https://vault:8200
+	Engine  string // "kv" or "transit"
+	Mount   string // the path the engine is mounted at
+	// Path is where the keys are under a "kv" mount.
+	Path string `json:",omitempty"`
+	// CacheTTL is how long the keys read are kept in memory, such as "1m".
+	CacheTTL string `json:",omitempty"`
+}
+
+// KeystoreKeychain names the keys kept in the credential store of the OS.
+type KeystoreKeychain struct {
+	// Service is the service the keys are items of, "ipfs-" and the peer
+	// ID of the node by default, so that each repo has its own keys.
+	Service string `json:",omitempty"`
+}
+
+// KeystoreEncryption is the derivation of the key the keys are encrypted
+// with from the passphrase of the keystore.
+type KeystoreEncryption struct {
+	KDF   string // "scrypt"
+	Salt  []byte
+	N     int
+	R     int
+	P     int
+	Check []byte // a known text encrypted with the key
+}
@@ -0,0 +1,36 @@
+package config
+
+// Memory tunes the memory use of the daemon, for nodes too large or too
+// small for the defaults.
+type Memory struct {
+	// GCPercent sets the garbage collection target percentage, as GOGC does;
+	// 0 keeps the Go default, and GOGC, when set, wins over it.
+	GCPercent int `json:",omitempty"`
+
+	// Ballast is the size, such as "1GB", of a heap allocation kept for the
+	// life of the daemon, which makes the collector run less often on small
+	// heaps without using physical memory.
+	Ballast string `json:",omitempty"`
+
+	// BitswapHasBlockBuffer and BitswapProvideBuffer size the queues of new
+	// blocks and of blocks to provide in bitswap.
+	BitswapHasBlockBuffer int `json:",omitempty"`
+	BitswapProvideBuffer  int `json:",omitempty"`
+
+	// MuxerWindowSize, such as "512KB", is the largest receive window of a
+	// yamux stream, which is buffered in memory.
+	MuxerWindowSize string `json:",omitempty"`
+
+	// MuxerAcceptBacklog is the number of yamux streams opened by peers
+	// and not yet accepted.
+	MuxerAcceptBacklog int `json:",omitempty"`
+
+	// ShedHeapAbove, such as "2GB", is the live heap, ballast excluded,
+	// above which the API and the gateway reject the expensive requests
+	// with a 503 until it goes down.
+	ShedHeapAbove string `json:",omitempty"`
+
+	// ShedFDPercent is the percentage of the file descriptor limit in use
+	// above which the expensive requests are rejected; 0 disables it.
+	ShedFDPercent int `json:",omitempty"`
+}
@@ -0,0 +1,8 @@
+package config
+
+// Pinning configures the pins kept by the daemon.
+type Pinning struct {
+	// FollowInterval is how often the paths followed by
+	// 'ipfs pin add --follow' are resolved, "1m" by default.
+	FollowInterval string `json:",omitempty"`
+}
@@ -0,0 +1,8 @@
+package config
+
+// ReleaseKeys are the base64 encoded public keys of the go-ipfs maintainers,
+// which 'ipfs update apply' accepts release signatures from. They are kept
+// in the source, rather than set when building, so that every build of a
+// version verifies its updates alike. A maintainer signing releases adds
+// their key here; until then, updates cannot be verified and are refused.
+var ReleaseKeys = []string{}
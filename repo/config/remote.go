@@ -0,0 +1,12 @@
+package config
+
+// Remote is a daemon the CLI runs commands on when given its name with
+// --api, see 'ipfs remote'.
+type Remote struct {
+	// API is the multiaddr of the API of the daemon.
+	API string
+
+	// Auth is sent to the API with each request: user:password as basic
+	// credentials, anything else as a bearer token.
+	Auth string `json:",omitempty"`
+}
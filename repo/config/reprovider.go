@@ -1,5 +1,14 @@
 package config
 
 type Reprovider struct {
-	Interval string // Time period to reprovide locally stored objects to the network
+	Interval     string // Time period to reprovide locally stored objects to the network
+	DrainTimeout string // Time to wait on shutdown for pending provides to be announced
+
+	// Concurrency is the number of announcements sent at once, 0 for the
+	// default.
+	Concurrency int `json:",omitempty"`
+	// ProvideRate and ReprovideRate limit the announcements per second of
+	// the new blocks and of the periodic reprovides, 0 for no limit.
+	ProvideRate   int `json:",omitempty"`
+	ReprovideRate int `json:",omitempty"`
 }
@@ -0,0 +1,47 @@
+package config
+
+// Routing composes the routers of the daemon. Without routers, the daemon
+// uses the one given by its --routing option.
+type Routing struct {
+	// Routers are queried in order to find content, peers and values, or
+	// all at once if Parallel is set. The content is provided to all of
+	// them at once.
+	Routers  []Router `json:",omitempty"`
+	Parallel bool     `json:",omitempty"`
+
+	// ProviderHints are the peers known to provide some content, which the
+	// exchange connects to before searching the routers.
+	ProviderHints []ProviderHint `json:",omitempty"`
+}
+
+// ProviderHint maps content to the peers providing it.
+type ProviderHint struct {
+	// Roots are the CIDs of the DAGs the peers provide.
+	Roots []string `json:",omitempty"`
+
+	// Prefixes match the CIDs whose string form starts with one of them.
+	Prefixes []string `json:",omitempty"`
+
+	// Peers are the /ipfs/ addresses of the providers.
+	Peers []string
+}
+
+// Router is a routing system of the node.
+type Router struct {
+	// Type is one of "dht", "dhtclient", "http", "static" or "mdns".
+	Type string
+
+	// Roles lists what the router is used for: "find", "provide", or both.
+	// All the roles the router supports when empty.
+	Roles []string `json:",omitempty"`
+
+	// Timeout, such as "10s", bounds each operation on the router.
+	Timeout string `json:",omitempty"`
+
+	// Endpoint is the URL of the API of the node an "http" router
+	// delegates to.
+	Endpoint string `json:",omitempty"`
+
+	// Path is the mapping file of a "static" router, relative to the repo.
+	Path string `json:",omitempty"`
+}
@@ -0,0 +1,105 @@
+package config
+
+import (
+	"strings"
+)
+
+// SecretKeys are the config keys holding secrets, redacted when the config
+// is shown, "*" standing for any key of a map.
+var SecretKeys = []string{
+	PrivKeySelector,
+	"Remotes.*.Auth",
+}
+
+// SplitSecrets moves the secrets of the config map m to a map of the same
+// shape, which it returns. Keys are matched regardless of case, as they are
+// when the config is decoded.
+func SplitSecrets(m map[string]interface{}) map[string]interface{} {
+	secrets := make(map[string]interface{})
+	for _, key := range SecretKeys {
+		splitSecret(m, secrets, strings.Split(key, "."))
+	}
+	return secrets
+}
+
+func splitSecret(m, secrets map[string]interface{}, key []string) {
+	for mkey, val := range m {
+		if key[0] != "*" && !strings.EqualFold(mkey, key[0]) {
+			continue
+		}
+
+		if len(key) == 1 {
+			if val != nil && val != "" {
+				secrets[mkey] = val
+			}
+			delete(m, mkey)
+			continue
+		}
+
+		sub, ok := val.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		subsecrets, _ := secrets[mkey].(map[string]interface{})
+		if subsecrets == nil {
+			subsecrets = make(map[string]interface{})
+		}
+		splitSecret(sub, subsecrets, key[1:])
+		if len(subsecrets) > 0 {
+			secrets[mkey] = subsecrets
+		}
+	}
+}
+
+// MergeSecrets puts the secrets split by SplitSecrets back in the config map
+// m. A secret is dropped if the value it belongs to, such as a remote, is
+// gone from m, or if m has its own, set by hand since.
+func MergeSecrets(m, secrets map[string]interface{}) {
+	for skey, sval := range secrets {
+		mkey := skey
+		for k := range m {
+			if strings.EqualFold(k, skey) {
+				mkey = k
+				break
+			}
+		}
+
+		subsecrets, ok := sval.(map[string]interface{})
+		if !ok {
+			if v, ok := m[mkey]; !ok || v == nil || v == "" {
+				m[mkey] = sval
+			}
+			continue
+		}
+		sub, ok := m[mkey].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		MergeSecrets(sub, subsecrets)
+	}
+}
+
+// WithoutSecrets returns a copy of cfg with its secrets left out.
+func WithoutSecrets(cfg *Config) (*Config, error) {
+	m, err := ToMap(cfg)
+	if err != nil {
+		return nil, err
+	}
+	SplitSecrets(m)
+	return FromMap(m)
+}
+
+// KeepSecrets returns a copy of cfg with the secrets it lacks taken from
+// cur, as when a config shown without its secrets replaces the current one.
+func KeepSecrets(cfg, cur *Config) (*Config, error) {
+	m, err := ToMap(cfg)
+	if err != nil {
+		return nil, err
+	}
+	curm, err := ToMap(cur)
+	if err != nil {
+		return nil, err
+	}
+	MergeSecrets(m, SplitSecrets(curm))
+	return FromMap(m)
+}
@@ -4,4 +4,140 @@ type SwarmConfig struct {
 	AddrFilters             []string
 	DisableBandwidthMetrics bool
 	DisableNatPortMap       bool
+
+	// DisableHealthCheck stops the node from answering the health checks of
+	// peers, see 'ipfs ping --health'.
+	DisableHealthCheck bool
+
+	// DialTransports ranks transports, preferred first, when dialing the
+	// addresses of a peer. Unlisted transports come last.
+	DialTransports     []string `json:",omitempty"`
+	DisableDialRanking bool
+
+	// AddressFamily is ip4 or ip6 to prefer that family when dialing and
+	// announcing, or ip4-only or ip6-only to use no other.
+	AddressFamily string `json:",omitempty"`
+
+	Gater GaterConfig
+
+	PeerAuth PeerAuthConfig
+
+	// BandwidthProfiles limit the bandwidth and connections of the node
+	// during time windows; the first profile matching the current local time
+	// applies, and none when no profile matches.
+	BandwidthProfiles []BandwidthProfile `json:",omitempty"`
+
+	// ProtocolLimits cap the streams and bandwidth of protocols, so that one
+	// can't crowd out the others; the first limit matching a protocol
+	// applies to it.
+	ProtocolLimits []ProtocolLimit `json:",omitempty"`
+
+	Peerstore PeerstoreConfig
+
+	BlockPush BlockPushConfig
+}
+
+// BlockPushConfig lets trusted peers push blocks to the node, rather than
+// waiting for it to fetch them, see 'ipfs replicate --peer'.
+type BlockPushConfig struct {
+	// AllowedPeers are the peers whose blocks are accepted; none are if it
+	// is empty.
+	AllowedPeers []string `json:",omitempty"`
+
+	// Quota caps the size of the blocks each peer pushes in an hour, as
+	// "10GB"; no cap if empty.
+	Quota string `json:",omitempty"`
+}
+
+// PeerstoreConfig controls the recording of the peerstore in the datastore.
+type PeerstoreConfig struct {
+	// DisablePersistence keeps the learned peers in memory only.
+	DisablePersistence bool
+
+	// MaxAddrTTL caps how long a learned address is kept, as a duration;
+	// defaults to 24h.
+	MaxAddrTTL string `json:",omitempty"`
+}
+
+// BandwidthProfile is a set of limits, and the times it applies at.
+type BandwidthProfile struct {
+	Name string
+
+	// Days are the days the profile starts on, from "mon" to "sun", every
+	// day if empty.
+	Days []string `json:",omitempty"`
+
+	// Start and End are local times, as "15:04", between which the profile
+	// applies; the whole day if both are empty. Windows with an End before
+	// their Start end on the next day.
+	Start string `json:",omitempty"`
+	End   string `json:",omitempty"`
+
+	// RateIn and RateOut cap the bandwidth, as "5Mbps" or "500KB/s"; no cap
+	// if empty.
+	RateIn  string `json:",omitempty"`
+	RateOut string `json:",omitempty"`
+
+	// MaxConns caps the number of connections, if positive.
+	MaxConns int `json:",omitempty"`
+}
+
+// ProtocolLimit is a cap on the streams and bandwidth of protocols.
+type ProtocolLimit struct {
+	// Protocol is the prefix of the protocol IDs limited, such as
+	// "/ipfs/kad/", or one of "bitswap", "dht" and "pubsub".
+	Protocol string
+
+	// MaxStreams caps the streams open at once, in both directions, if
+	// positive.
+	MaxStreams int `json:",omitempty"`
+
+	// RateIn and RateOut cap the bandwidth of all the streams, as "5Mbps" or
+	// "500KB/s"; no cap if empty.
+	RateIn  string `json:",omitempty"`
+	RateOut string `json:",omitempty"`
+}
+
+// PeerAuthConfig restricts the peers the node talks to, as their connections
+// are secured, to an allowlist and to the holders of certificates signed by
+// trusted keys.
+type PeerAuthConfig struct {
+	Enabled bool
+
+	AllowedPeers []string `json:",omitempty"`
+
+	// CAKeys are the base64 encoded public keys trusted to sign peer
+	// certificates.
+	CAKeys []string `json:",omitempty"`
+
+	// Certificate is the certificate of this node, as output by
+	// 'ipfs swarm cert issue'.
+	Certificate string `json:",omitempty"`
+}
+
+// Actions of the connection gater.
+const (
+	GaterAllow = "allow"
+	GaterDeny  = "deny"
+)
+
+// GaterConfig configures the connection gater. Rules are checked in order,
+// the first matching one decides; DefaultAction applies when none does.
+type GaterConfig struct {
+	DefaultAction string      `json:",omitempty"`
+	Rules         []GaterRule `json:",omitempty"`
+}
+
+// GaterRule matches the connections meeting all of its criteria. Empty
+// criteria match everything.
+type GaterRule struct {
+	Action string
+
+	// Stages among "dial", "accept" and "upgrade"
+	Stages []string `json:",omitempty"`
+	Peers  []string `json:",omitempty"`
+	CIDRs  []string `json:",omitempty"`
+
+	// Hours is a local time window, such as "22:00-06:00"
+	Hours string `json:",omitempty"`
 }
@@ -3,9 +3,11 @@ package fsrepo
 import (
 	"fmt"
 	"path"
+	"time"
 
 	repo "github.com/ipfs/go-ipfs/repo"
 	config "github.com/ipfs/go-ipfs/repo/config"
+	ds2 "github.com/ipfs/go-ipfs/thirdparty/datastore2"
 	"github.com/ipfs/go-ipfs/thirdparty/dir"
 
 	measure "gx/ipfs/QmNPv1yzXBqxzqjfTzHCeBoicxxZgHzLezdY2hMCZ3r6EU/go-ds-measure"
@@ -32,18 +34,49 @@ func openDefaultDatastore(r *FSRepo) (repo.Datastore, error) {
 		return nil, fmt.Errorf("unable to open leveldb datastore: %v", err)
 	}
 
-	syncfs := !r.config.Datastore.NoSync
+	durability, err := parseDurability(r.config.Datastore)
+	if err != nil {
+		return nil, err
+	}
 
 	// 2 characters of base32 suffix gives us 10 bits of freedom.
-	// Leaving us with 10 bits, or 1024 way sharding
-	blocksDS, err := flatfs.CreateOrOpen(path.Join(r.path, flatfsDirectory), flatfs.NextToLast(2), syncfs)
+	// Leaving us with 10 bits, or 1024 way sharding. Repos may have been
+	// resharded since, in which case the recorded shard function is used.
+	blocksPath := path.Join(r.path, flatfsDirectory)
+	shard, err := readShardFunc(blocksPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read flatfs shard function: %v", err)
+	}
+	shardID, err := flatfs.ParseShardFunc(shard.String())
+	if err != nil {
+		return nil, fmt.Errorf("unable to read flatfs shard function: %v", err)
+	}
+
+	// with strict durability, flatfs syncs each file before renaming it in
+	// place, so that a crash never leaves a truncated block under its key;
+	// otherwise the writes are synced in groups, or not at all
+	strict := durability == config.DurabilityStrict
+	flatfsDS, err := flatfs.CreateOrOpen(blocksPath, shardID, strict)
 	if err != nil {
 		return nil, fmt.Errorf("unable to open flatfs datastore: %v", err)
 	}
+	blocksDS := newSyncedFlatfs(flatfsDS, blocksPath, shard, durability)
+
+	var slow time.Duration
+	if t := r.config.Datastore.SlowOpThreshold; t != "" {
+		slow, err = time.ParseDuration(t)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Datastore.SlowOpThreshold: %s", err)
+		}
+	}
+
+	latencyBlocks := ds2.LatencyWrap("blocks", blocksDS, slow)
+	latencyLevelDB := ds2.LatencyWrap("leveldb", leveldbDS, slow)
+	r.latency = []*ds2.LatencyDatastore{latencyBlocks, latencyLevelDB}
 
 	prefix := "ipfs.fsrepo.datastore."
-	metricsBlocks := measure.New(prefix+"blocks", blocksDS)
-	metricsLevelDB := measure.New(prefix+"leveldb", leveldbDS)
+	metricsBlocks := measure.New(prefix+"blocks", latencyBlocks)
+	metricsLevelDB := measure.New(prefix+"leveldb", latencyLevelDB)
 	mountDS := mount.New([]mount.Mount{
 		{
 			Prefix:    ds.NewKey("/blocks"),
@@ -0,0 +1,324 @@
+package fsrepo
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Files maintained by flatfs at the root of the blocks directory.
+const (
+	flatfsShardingFile = "SHARDING"
+	flatfsReadmeFile   = "_README"
+	flatfsDiskUsage    = "diskUsage.cache"
+	flatfsExtension    = ".data"
+	flatfsShardPrefix  = "/repo/flatfs/shard/v1/"
+)
+
+// DefaultShardFunc is the shard function of newly created repos.
+const DefaultShardFunc = flatfsShardPrefix + "next-to-last/2"
+
+// shardFunc mirrors the shard functions of go-ds-flatfs, which maps a key to
+// the directory its block is stored in.
+type shardFunc struct {
+	name  string
+	param int
+}
+
+func parseShardFunc(s string) (shardFunc, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, flatfsShardPrefix) {
+		return shardFunc{}, fmt.Errorf("invalid or unsupported shard function: %q", s)
+	}
+
+	parts := strings.Split(strings.TrimPrefix(s, flatfsShardPrefix), "/")
+	if len(parts) != 2 {
+		return shardFunc{}, fmt.Errorf("invalid shard function: %q", s)
+	}
+
+	param, err := strconv.Atoi(parts[1])
+	if err != nil || param < 1 {
+		return shardFunc{}, fmt.Errorf("invalid shard function parameter: %q", parts[1])
+	}
+
+	switch parts[0] {
+	case "prefix", "suffix", "next-to-last":
+	default:
+		return shardFunc{}, fmt.Errorf("unknown shard function: %q", parts[0])
+	}
+	return shardFunc{name: parts[0], param: param}, nil
+}
+
+func (f shardFunc) String() string {
+	return fmt.Sprintf("%s%s/%d", flatfsShardPrefix, f.name, f.param)
+}
+
+// dir returns the shard directory of key.
+func (f shardFunc) dir(key string) string {
+	switch f.name {
+	case "prefix":
+		str := key + strings.Repeat("_", f.param)
+		return str[:f.param]
+	case "suffix":
+		str := strings.Repeat("_", f.param) + key
+		return str[len(str)-f.param:]
+	default:
+		str := strings.Repeat("_", f.param+1) + key
+		offset := len(str) - f.param - 1
+		return str[offset : offset+f.param]
+	}
+}
+
+func readShardFunc(blocksPath string) (shardFunc, error) {
+	b, err := ioutil.ReadFile(filepath.Join(blocksPath, flatfsShardingFile))
+	if os.IsNotExist(err) {
+		// repos created before the SHARDING file existed
+		return parseShardFunc(DefaultShardFunc)
+	}
+	if err != nil {
+		return shardFunc{}, err
+	}
+	return parseShardFunc(string(b))
+}
+
+func writeShardFunc(blocksPath string, f shardFunc) error {
+	tmp := filepath.Join(blocksPath, flatfsShardingFile+".tmp")
+	if err := ioutil.WriteFile(tmp, []byte(f.String()+"\n"), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(blocksPath, flatfsShardingFile))
+}
+
+// FlatfsProblem is an inconsistency found in the blocks directory.
+type FlatfsProblem struct {
+	Path     string
+	Problem  string
+	Repaired bool
+	Error    string `json:",omitempty"`
+}
+
+// Problems found by FsckFlatfs.
+const (
+	ProblemTempFile  = "orphaned temporary file"
+	ProblemMisplaced = "misplaced block"
+	ProblemUnknown   = "unknown file"
+)
+
+// FsckFlatfs checks that every block in the flatfs datastore at blocksPath
+// is stored in the directory its shard function assigns it to, and looks for
+// temporary files left behind by interrupted writes. Unless dryRun is set,
+// misplaced blocks are moved into place and temporary files removed. Shard
+// directories are checked by up to workers goroutines. It must not run while
+// the repo is in use.
+func FsckFlatfs(blocksPath string, dryRun bool, workers int) ([]FlatfsProblem, error) {
+	shard, err := readShardFunc(blocksPath)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := ioutil.ReadDir(blocksPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		mu       sync.Mutex
+		problems []FlatfsProblem
+	)
+	report := func(p FlatfsProblem) {
+		mu.Lock()
+		problems = append(problems, p)
+		mu.Unlock()
+	}
+
+	var dirs []string
+	for _, e := range entries {
+		name := e.Name()
+		switch {
+		case e.IsDir():
+			dirs = append(dirs, name)
+		case name == flatfsShardingFile || name == flatfsReadmeFile || name == flatfsDiskUsage:
+		case isFlatfsTemp(name):
+			report(removeTemp(filepath.Join(blocksPath, name), dryRun))
+		case strings.HasSuffix(name, flatfsExtension):
+			report(moveBlock(blocksPath, "", name, shard, dryRun))
+		default:
+			report(FlatfsProblem{Path: filepath.Join(blocksPath, name), Problem: ProblemUnknown})
+		}
+	}
+
+	err = forEachDir(dirs, workers, func(dir string) error {
+		files, err := ioutil.ReadDir(filepath.Join(blocksPath, dir))
+		if err != nil {
+			return err
+		}
+
+		for _, f := range files {
+			name := f.Name()
+			switch {
+			case isFlatfsTemp(name):
+				report(removeTemp(filepath.Join(blocksPath, dir, name), dryRun))
+			case f.IsDir() || !strings.HasSuffix(name, flatfsExtension):
+				report(FlatfsProblem{Path: filepath.Join(blocksPath, dir, name), Problem: ProblemUnknown})
+			case shard.dir(strings.TrimSuffix(name, flatfsExtension)) != dir:
+				report(moveBlock(blocksPath, dir, name, shard, dryRun))
+			}
+		}
+		return nil
+	})
+	return problems, err
+}
+
+// ReshardFlatfs changes the shard function of the flatfs datastore at
+// blocksPath to newShard, moving blocks with up to workers goroutines. The
+// new shard function is recorded first so that, if interrupted, the move can
+// be completed by FsckFlatfs. It must not run while the repo is in use.
+func ReshardFlatfs(blocksPath, newShard string, workers int) (int, error) {
+	shard, err := parseShardFunc(newShard)
+	if err != nil {
+		return 0, err
+	}
+
+	old, err := readShardFunc(blocksPath)
+	if err != nil {
+		return 0, err
+	}
+	if old == shard {
+		return 0, nil
+	}
+
+	if err := writeShardFunc(blocksPath, shard); err != nil {
+		return 0, err
+	}
+
+	problems, err := FsckFlatfs(blocksPath, false, workers)
+	if err != nil {
+		return 0, err
+	}
+
+	moved := 0
+	for _, p := range problems {
+		if p.Problem != ProblemMisplaced {
+			continue
+		}
+		if p.Error != "" {
+			return moved, fmt.Errorf("failed to move %s: %s", p.Path, p.Error)
+		}
+		moved++
+	}
+
+	// drop the directories of the old layout
+	entries, err := ioutil.ReadDir(blocksPath)
+	if err != nil {
+		return moved, err
+	}
+	for _, e := range entries {
+		if e.IsDir() {
+			// fails, as intended, on directories still holding files
+			os.Remove(filepath.Join(blocksPath, e.Name()))
+		}
+	}
+	return moved, nil
+}
+
+func isFlatfsTemp(name string) bool {
+	return strings.HasPrefix(name, "put-") || strings.HasPrefix(name, ".tmp") || strings.HasSuffix(name, ".tmp")
+}
+
+func removeTemp(path string, dryRun bool) FlatfsProblem {
+	p := FlatfsProblem{Path: path, Problem: ProblemTempFile}
+	if dryRun {
+		return p
+	}
+
+	if err := os.Remove(path); err != nil {
+		p.Error = err.Error()
+	} else {
+		p.Repaired = true
+	}
+	return p
+}
+
+// moveBlock moves the block file name, currently in dir, to the directory
+// shard assigns it to. A block already present at the destination is kept.
+func moveBlock(blocksPath, dir, name string, shard shardFunc, dryRun bool) FlatfsProblem {
+	src := filepath.Join(blocksPath, dir, name)
+	p := FlatfsProblem{Path: src, Problem: ProblemMisplaced}
+	if dryRun {
+		return p
+	}
+
+	dstDir := filepath.Join(blocksPath, shard.dir(strings.TrimSuffix(name, flatfsExtension)))
+	dst := filepath.Join(dstDir, name)
+
+	err := os.MkdirAll(dstDir, 0755)
+	if err == nil {
+		if _, serr := os.Stat(dst); serr == nil {
+			// blocks are content addressed, the copy in place is as good
+			err = os.Remove(src)
+		} else {
+			err = os.Rename(src, dst)
+		}
+	}
+
+	if err != nil {
+		p.Error = err.Error()
+	} else {
+		p.Repaired = true
+	}
+	return p
+}
+
+// forEachDir runs f on every dir with up to workers goroutines, returning the
+// first error.
+func forEachDir(dirs []string, workers int, f func(string) error) error {
+	if workers < 1 {
+		workers = 1
+	}
+
+	work := make(chan string)
+	errs := make(chan error, workers)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for d := range work {
+				if err := f(d); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+
+	var err error
+loop:
+	for _, d := range dirs {
+		select {
+		case work <- d:
+		case err = <-errs:
+			break loop
+		}
+	}
+	close(work)
+	wg.Wait()
+
+	if err == nil {
+		select {
+		case err = <-errs:
+		default:
+		}
+	}
+	return err
+}
+
+// FlatfsPath returns the path of the flatfs datastore of the repo at
+// repoPath.
+func FlatfsPath(repoPath string) string {
+	return filepath.Join(repoPath, flatfsDirectory)
+}
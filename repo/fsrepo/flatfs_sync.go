@@ -0,0 +1,296 @@
+package fsrepo
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+
+	config "github.com/ipfs/go-ipfs/repo/config"
+
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+)
+
+const (
+	// flatfsBatchFiles is the number of blocks written per flatfs batch,
+	// which keeps a file open for each of them until it commits.
+	flatfsBatchFiles = 128
+
+	// syncWorkers is the number of files synced in parallel, which lets the
+	// disk order the writes instead of seeking for each.
+	syncWorkers = 16
+
+	// groupCommitInterval and groupCommitFiles bound how long, and how many
+	// files, writes wait to be synced with DurabilityBatch.
+	groupCommitInterval = 500 * time.Millisecond
+	groupCommitFiles    = 4096
+)
+
+// syncedFlatfs syncs the writes to a flatfs datastore opened without
+// syncing with DurabilityBatch, so that the writes of several batches and
+// puts are synced together, after they are all written, rather than one
+// after the other as flatfs does. With DurabilityStrict, flatfs is opened
+// syncing, and syncs each file before renaming it in place; with
+// DurabilityNone, nothing is synced.
+type syncedFlatfs struct {
+	ds.Batching
+	path  string
+	shard shardFunc
+	level string
+
+	mu      sync.Mutex
+	pending map[string]bool
+	kick    chan struct{}
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+func parseDurability(cfg config.Datastore) (string, error) {
+	switch cfg.Durability {
+	case "":
+		if cfg.NoSync {
+			return config.DurabilityNone, nil
+		}
+		return config.DurabilityStrict, nil
+	case config.DurabilityStrict, config.DurabilityBatch, config.DurabilityNone:
+		return cfg.Durability, nil
+	default:
+		return "", fmt.Errorf("invalid Datastore.Durability %q, expected %q, %q or %q",
+			cfg.Durability, config.DurabilityStrict, config.DurabilityBatch, config.DurabilityNone)
+	}
+}
+
+func newSyncedFlatfs(d ds.Batching, path string, shard shardFunc, level string) *syncedFlatfs {
+	s := &syncedFlatfs{
+		Batching: d,
+		path:     path,
+		shard:    shard,
+		level:    level,
+		pending:  make(map[string]bool),
+		kick:     make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+	if level == config.DurabilityBatch {
+		s.wg.Add(1)
+		go s.groupCommit()
+	}
+	return s
+}
+
+// file returns the path of the file flatfs stores key in.
+func (s *syncedFlatfs) file(key ds.Key) string {
+	name := key.String()[1:]
+	return filepath.Join(s.path, s.shard.dir(name), name+flatfsExtension)
+}
+
+func (s *syncedFlatfs) Put(key ds.Key, value interface{}) error {
+	if err := s.Batching.Put(key, value); err != nil {
+		return err
+	}
+	return s.written([]string{s.file(key)})
+}
+
+func (s *syncedFlatfs) Batch() (ds.Batch, error) {
+	return &syncedBatch{s: s, puts: make(map[ds.Key]interface{})}, nil
+}
+
+// written syncs files, now or with the next group, following the level.
+func (s *syncedFlatfs) written(files []string) error {
+	switch s.level {
+	case config.DurabilityBatch:
+		s.mu.Lock()
+		for _, f := range files {
+			s.pending[f] = true
+		}
+		full := len(s.pending) >= groupCommitFiles
+		s.mu.Unlock()
+		if full {
+			select {
+			case s.kick <- struct{}{}:
+			default:
+			}
+		}
+	}
+	return nil
+}
+
+func (s *syncedFlatfs) groupCommit() {
+	defer s.wg.Done()
+	t := time.NewTicker(groupCommitInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+		case <-s.kick:
+		case <-s.done:
+			if err := s.syncPending(); err != nil {
+				log.Errorf("failed to sync blocks: %s", err)
+			}
+			return
+		}
+		if err := s.syncPending(); err != nil {
+			log.Errorf("failed to sync blocks: %s", err)
+		}
+	}
+}
+
+func (s *syncedFlatfs) syncPending() error {
+	s.mu.Lock()
+	pending := s.pending
+	s.pending = make(map[string]bool)
+	s.mu.Unlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+	files := make([]string, 0, len(pending))
+	for f := range pending {
+		files = append(files, f)
+	}
+	return s.sync(files)
+}
+
+// sync syncs files, then the directories they are in and the root, as
+// flatfs may have created shard directories.
+func (s *syncedFlatfs) sync(files []string) error {
+	dirs := map[string]bool{s.path: true}
+	for _, f := range files {
+		dirs[filepath.Dir(f)] = true
+	}
+
+	if err := syncPaths(files); err != nil {
+		return err
+	}
+	if runtime.GOOS == "windows" {
+		// directories cannot be synced there
+		return nil
+	}
+	dirList := make([]string, 0, len(dirs))
+	for d := range dirs {
+		dirList = append(dirList, d)
+	}
+	return syncPaths(dirList)
+}
+
+func syncPaths(paths []string) error {
+	jobs := make(chan string)
+	errs := make(chan error, syncWorkers)
+	var wg sync.WaitGroup
+
+	workers := syncWorkers
+	if len(paths) < workers {
+		workers = len(paths)
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var first error
+			for p := range jobs {
+				if err := syncPath(p); err != nil && first == nil {
+					first = err
+				}
+			}
+			if first != nil {
+				errs <- first
+			}
+		}()
+	}
+	for _, p := range paths {
+		jobs <- p
+	}
+	close(jobs)
+	wg.Wait()
+	close(errs)
+	return <-errs
+}
+
+func syncPath(p string) error {
+	f, err := os.Open(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// deleted since written
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+	return f.Sync()
+}
+
+// Close syncs the pending writes, and closes the datastore.
+func (s *syncedFlatfs) Close() error {
+	close(s.done)
+	s.wg.Wait()
+	if c, ok := s.Batching.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+var _ ds.Batching = &syncedFlatfs{}
+
+// syncedBatch writes its blocks in flatfs batches of flatfsBatchFiles, then
+// syncs them all at once.
+type syncedBatch struct {
+	s       *syncedFlatfs
+	puts    map[ds.Key]interface{}
+	deletes []ds.Key
+}
+
+func (b *syncedBatch) Put(key ds.Key, value interface{}) error {
+	b.puts[key] = value
+	return nil
+}
+
+func (b *syncedBatch) Delete(key ds.Key) error {
+	b.deletes = append(b.deletes, key)
+	return nil
+}
+
+func (b *syncedBatch) Commit() error {
+	files := make([]string, 0, len(b.puts))
+	var fb ds.Batch
+	n := 0
+	for k, v := range b.puts {
+		if fb == nil {
+			var err error
+			fb, err = b.s.Batching.Batch()
+			if err != nil {
+				return err
+			}
+		}
+		if err := fb.Put(k, v); err != nil {
+			return err
+		}
+		files = append(files, b.s.file(k))
+		n++
+		if n == flatfsBatchFiles {
+			if err := fb.Commit(); err != nil {
+				return err
+			}
+			fb, n = nil, 0
+		}
+	}
+	if fb != nil {
+		if err := fb.Commit(); err != nil {
+			return err
+		}
+	}
+
+	for _, k := range b.deletes {
+		if err := b.s.Batching.Delete(k); err != nil && err != ds.ErrNotFound {
+			return err
+		}
+	}
+
+	b.puts = make(map[ds.Key]interface{})
+	b.deletes = nil
+	if len(files) == 0 {
+		return nil
+	}
+	return b.s.written(files)
+}
@@ -0,0 +1,174 @@
+package fsrepo
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	config "github.com/ipfs/go-ipfs/repo/config"
+
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+	flatfs "gx/ipfs/QmXZEfbEv9sXG9JnLoMNhREDMDgkq5Jd7uWJ7d77VJ4pxn/go-ds-flatfs"
+)
+
+func TestShardFuncDir(t *testing.T) {
+	cases := []struct {
+		spec, key, dir string
+	}{
+		{"/repo/flatfs/shard/v1/next-to-last/2", "CIQABCDE", "CD"},
+		{"/repo/flatfs/shard/v1/next-to-last/2", "A", "__"},
+		{"/repo/flatfs/shard/v1/prefix/3", "CIQABCDE", "CIQ"},
+		{"/repo/flatfs/shard/v1/suffix/2", "CIQABCDE", "DE"},
+	}
+
+	for _, c := range cases {
+		f, err := parseShardFunc(c.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d := f.dir(c.key); d != c.dir {
+			t.Fatalf("%s: expected %s in %s, got %s", c.spec, c.key, c.dir, d)
+		}
+	}
+
+	if _, err := parseShardFunc("/repo/flatfs/shard/v1/middle/2"); err == nil {
+		t.Fatal("expected an unknown shard function to be rejected")
+	}
+}
+
+func TestFsckAndReshardFlatfs(t *testing.T) {
+	blocks, err := ioutil.TempDir("", "flatfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(blocks)
+
+	write := func(p string) {
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(filepath.Join(blocks, "CD", "CIQABCDE.data"))
+	write(filepath.Join(blocks, "XX", "CIQAXYZE.data"))
+	write(filepath.Join(blocks, "CD", "put-123456"))
+
+	problems, err := FsckFlatfs(blocks, true, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) != 2 {
+		t.Fatalf("expected 2 problems, got %v", problems)
+	}
+	if _, err := os.Stat(filepath.Join(blocks, "XX", "CIQAXYZE.data")); err != nil {
+		t.Fatal("dry run moved a block")
+	}
+
+	if _, err := FsckFlatfs(blocks, false, 2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(blocks, "YZ", "CIQAXYZE.data")); err != nil {
+		t.Fatal("misplaced block was not moved")
+	}
+	if _, err := os.Stat(filepath.Join(blocks, "CD", "put-123456")); !os.IsNotExist(err) {
+		t.Fatal("temporary file was not removed")
+	}
+
+	moved, err := ReshardFlatfs(blocks, "/repo/flatfs/shard/v1/prefix/4", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moved != 2 {
+		t.Fatalf("expected 2 blocks to move, got %d", moved)
+	}
+
+	for _, p := range []string{"CIQA/CIQABCDE.data", "CIQA/CIQAXYZE.data", "SHARDING"} {
+		if _, err := os.Stat(filepath.Join(blocks, p)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(blocks, "CD")); !os.IsNotExist(err) {
+		t.Fatal("old shard directory was not removed")
+	}
+}
+
+func TestSyncedFlatfsBatch(t *testing.T) {
+	for _, level := range []string{config.DurabilityStrict, config.DurabilityBatch, config.DurabilityNone} {
+		blocks, err := ioutil.TempDir("", "flatfs")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(blocks)
+
+		shard, err := parseShardFunc(DefaultShardFunc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		shardID, err := flatfs.ParseShardFunc(shard.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		fds, err := flatfs.CreateOrOpen(blocks, shardID, level == config.DurabilityStrict)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := newSyncedFlatfs(fds, blocks, shard, level)
+
+		// more blocks than a flatfs batch takes
+		b, err := s.Batch()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var keys []ds.Key
+		for i := 0; i < 3*flatfsBatchFiles/2; i++ {
+			k := ds.NewKey(fmt.Sprintf("CIQBLOCK%04d", i))
+			keys = append(keys, k)
+			if err := b.Put(k, []byte{byte(i)}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := b.Commit(); err != nil {
+			t.Fatalf("%s: %s", level, err)
+		}
+		if err := s.Put(ds.NewKey("CIQSINGLE"), []byte("x")); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := s.Close(); err != nil {
+			t.Fatal(err)
+		}
+		for _, k := range append(keys, ds.NewKey("CIQSINGLE")) {
+			if _, err := os.Stat(s.file(k)); err != nil {
+				t.Fatalf("%s: block %s not where flatfs stores it: %s", level, k, err)
+			}
+		}
+	}
+}
+
+func TestParseDurability(t *testing.T) {
+	cases := []struct {
+		cfg   config.Datastore
+		level string
+	}{
+		{config.Datastore{}, config.DurabilityStrict},
+		{config.Datastore{NoSync: true}, config.DurabilityNone},
+		{config.Datastore{NoSync: true, Durability: "batch"}, config.DurabilityBatch},
+	}
+	for _, c := range cases {
+		level, err := parseDurability(c.cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if level != c.level {
+			t.Fatalf("expected %s, got %s", c.level, level)
+		}
+	}
+	if _, err := parseDurability(config.Datastore{Durability: "sometimes"}); err == nil {
+		t.Fatal("expected an unknown durability to be rejected")
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	filestore "github.com/ipfs/go-ipfs/filestore"
 	keystore "github.com/ipfs/go-ipfs/keystore"
@@ -19,6 +20,7 @@ import (
 	lockfile "github.com/ipfs/go-ipfs/repo/fsrepo/lock"
 	mfsr "github.com/ipfs/go-ipfs/repo/fsrepo/migrations"
 	serialize "github.com/ipfs/go-ipfs/repo/fsrepo/serialize"
+	ds2 "github.com/ipfs/go-ipfs/thirdparty/datastore2"
 	dir "github.com/ipfs/go-ipfs/thirdparty/dir"
 
 	"github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/mitchellh/go-homedir"
@@ -97,6 +99,8 @@ type FSRepo struct {
 	ds       repo.Datastore
 	keystore keystore.Keystore
 	filemgr  *filestore.FileManager
+	// latency records operation latencies of each mounted datastore
+	latency []*ds2.LatencyDatastore
 }
 
 var _ repo.Repo = (*FSRepo)(nil)
@@ -177,6 +181,30 @@ func open(repoPath string) (repo.Repo, error) {
 	return r, nil
 }
 
+// OpenKeystore opens the keystore of the FSRepo at path, and only it: the
+// repo is not locked, so that the keys can be managed by commands while a
+// daemon runs, without its datastore. A keystore which is an io.Closer is
+// closed by the caller.
+func OpenKeystore(repoPath string) (keystore.Keystore, error) {
+	packageLock.Lock()
+	defer packageLock.Unlock()
+
+	r, err := newFSRepo(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkInitialized(r.path); err != nil {
+		return nil, err
+	}
+	if err := r.openConfig(); err != nil {
+		return nil, err
+	}
+	if err := r.openKeystore(); err != nil {
+		return nil, err
+	}
+	return r.keystore, nil
+}
+
 func newFSRepo(rpath string) (*FSRepo, error) {
 	expPath, err := homedir.Expand(filepath.Clean(rpath))
 	if err != nil {
@@ -321,6 +349,12 @@ func (r *FSRepo) Path() string {
 	return r.path
 }
 
+// APIFilePath returns the path of the file the API address is written to
+// while a daemon runs on the repo at repoPath.
+func APIFilePath(repoPath string) string {
+	return filepath.Join(repoPath, apiFile)
+}
+
 // SetAPIAddr writes the API Addr to the /api file.
 func (r *FSRepo) SetAPIAddr(addr ma.Multiaddr) error {
 	f, err := os.Create(filepath.Join(r.path, apiFile))
@@ -348,6 +382,21 @@ func (r *FSRepo) openConfig() error {
 }
 
 func (r *FSRepo) openKeystore() error {
+	switch r.config.Keystore.Type {
+	case "", "fs":
+	case "vault":
+		// Vault is only reached, with its token, once the keys are used
+		v := r.config.Keystore.Vault
+		transit := v != nil && v.Engine == keystore.VaultTransit
+		r.keystore = keystore.NewLazyKeystore(r.openVaultKeystore, transit)
+		return nil
+	case "keychain":
+		r.keystore = keystore.NewLazyKeystore(r.openKeychainKeystore, false)
+		return nil
+	default:
+		return fmt.Errorf("unknown keystore type: %s", r.config.Keystore.Type)
+	}
+
 	ksp := filepath.Join(r.path, "keystore")
 	ks, err := keystore.NewFSKeystore(ksp)
 	if err != nil {
@@ -355,10 +404,74 @@ func (r *FSRepo) openKeystore() error {
 	}
 
 	r.keystore = ks
+	if e := r.config.Keystore.Encryption; e != nil {
+		r.keystore = keystore.NewEncryptedKeystore(ks, &keystore.Encryption{
+			KDF:   e.KDF,
+			Salt:  e.Salt,
+			N:     e.N,
+			R:     e.R,
+			P:     e.P,
+			Check: e.Check,
+		}, func() ([]byte, error) {
+			return KeystorePassphrase(false)
+		})
+	}
 
 	return nil
 }
 
+// openVaultKeystore opens the mount of Keystore.Vault, with the token of
+// Vault set in the environment.
+func (r *FSRepo) openVaultKeystore() (keystore.Keystore, error) {
+	cfg := r.config.Keystore
+	if cfg.Encryption != nil {
+		return nil, errors.New("the keys of a vault keystore are kept in vault, Keystore.Encryption must not be set")
+	}
+	v := cfg.Vault
+	if v == nil || v.Address == "" || v.Engine == "" || v.Mount == "" {
+		return nil, errors.New("a vault keystore needs Keystore.Vault.Address, Keystore.Vault.Engine and Keystore.Vault.Mount")
+	}
+
+	token := os.Getenv(EnvKeystoreVaultToken)
+	if token == "" {
+		return nil, fmt.Errorf("no token given for vault, set %s", EnvKeystoreVaultToken)
+	}
+
+	var ttl time.Duration
+	if v.CacheTTL != "" {
+		d, err := time.ParseDuration(v.CacheTTL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Keystore.Vault.CacheTTL: %s", err)
+		}
+		ttl = d
+	}
+
+	return keystore.NewVaultKeystore(keystore.VaultConfig{
+		Address:  v.Address,
+		Token:    token,
+		Engine:   v.Engine,
+		Mount:    v.Mount,
+		Path:     v.Path,
+		CacheTTL: ttl,
+	})
+}
+
+// openKeychainKeystore opens the keys kept in the credential store of the
+// OS, under the service of Keystore.Keychain.
+func (r *FSRepo) openKeychainKeystore() (keystore.Keystore, error) {
+	cfg := r.config.Keystore
+	if cfg.Encryption != nil {
+		return nil, errors.New("the keys of a keychain keystore are protected by the keychain, Keystore.Encryption must not be set")
+	}
+
+	service := "ipfs-" + r.config.Identity.PeerID
+	if cfg.Keychain != nil && cfg.Keychain.Service != "" {
+		service = cfg.Keychain.Service
+	}
+
+	return keystore.NewKeychainKeystore(service)
+}
+
 // openDatastore returns an error if the config file is not present.
 func (r *FSRepo) openDatastore() error {
 	switch r.config.Datastore.Type {
@@ -397,6 +510,13 @@ func (r *FSRepo) Close() error {
 		return err
 	}
 
+	// a token keystore logs out of the token
+	if c, ok := r.keystore.(io.Closer); ok {
+		if err := c.Close(); err != nil {
+			log.Warning("error closing the keystore: ", err)
+		}
+	}
+
 	// This code existed in the previous versions, but
 	// EventlogComponent.Close was never called. Preserving here
 	// pending further discussion.
@@ -580,6 +700,16 @@ func (r *FSRepo) Datastore() repo.Datastore {
 	return d
 }
 
+// DatastoreLatency returns the latency of operations on each mounted
+// datastore, keyed by mount name then operation.
+func (r *FSRepo) DatastoreLatency() map[string]map[string]ds2.LatencyStats {
+	out := make(map[string]map[string]ds2.LatencyStats, len(r.latency))
+	for _, d := range r.latency {
+		out[d.Name()] = d.Stats()
+	}
+	return out
+}
+
 // GetStorageUsage computes the storage space taken by the repo in bytes
 func (r *FSRepo) GetStorageUsage() (uint64, error) {
 	pth, err := config.PathRoot()
@@ -117,3 +117,36 @@ func TestOpenMoreThanOnceInSameProcess(t *testing.T) {
 	assert.Nil(r1.Close(), t)
 	assert.Nil(r2.Close(), t)
 }
+
+func TestSecretsKeptInConfig(t *testing.T) {
+	t.Parallel()
+	path := testRepoPath("secrets", t)
+	cfg := &config.Config{
+		Identity: config.Identity{PeerID: "QmPeer", PrivKey: "c2VjcmV0"},
+		Remotes:  map[string]config.Remote{"nc": {API: "/ip4/127.0.0.1/tcp/5001", Auth: "tok"}},
+	}
+	assert.Nil(Init(path, cfg), t, "should initialize successfully")
+
+	r, err := Open(path)
+	assert.Nil(err, t, "open the repo")
+	cur, err := r.Config()
+	assert.Nil(err, t, "get the config")
+
+	// a config shown without its secrets replaces the current one
+	shown, err := config.WithoutSecrets(cur)
+	assert.Nil(err, t, "leave out the secrets")
+	if shown.Identity.PrivKey != "" || shown.Remotes["nc"].Auth != "" {
+		t.Fatalf("expected the secrets to be left out, got %+v", shown)
+	}
+	updated, err := config.KeepSecrets(shown, cur)
+	assert.Nil(err, t, "keep the secrets")
+	assert.Nil(r.SetConfig(updated), t, "set the config")
+	assert.Nil(r.Close(), t, "close the repo")
+
+	// the secrets stay in the config file, as older versions read them
+	data, err := ioutil.ReadFile(filepath.Join(path, config.DefaultConfigFile))
+	assert.Nil(err, t, "read the config file")
+	if !bytes.Contains(data, []byte("c2VjcmV0")) || !bytes.Contains(data, []byte("tok")) {
+		t.Fatalf("expected the secrets to be kept in the config file, got %s", data)
+	}
+}
@@ -0,0 +1,29 @@
+package fsrepo
+
+import (
+	"fmt"
+	"os"
+)
+
+// EnvKeystorePassphrase is the environment variable of the passphrase of an
+// encrypted keystore.
+const EnvKeystorePassphrase = "IPFS_KEYSTORE_PASSPHRASE"
+
+// EnvKeystoreVaultToken is the environment variable of the token of Vault,
+// for a vault keystore.
+const EnvKeystoreVaultToken = "VAULT_TOKEN"
+
+// KeystorePassphrase returns the passphrase of the keystore, confirmed if
+// confirm is true, as when the keystore is encrypted. It is EnvPassphrase by
+// default; programs with a terminal can replace it to prompt for it.
+var KeystorePassphrase = EnvPassphrase
+
+// EnvPassphrase returns the passphrase of the keystore set in the
+// environment.
+func EnvPassphrase(confirm bool) ([]byte, error) {
+	p := os.Getenv(EnvKeystorePassphrase)
+	if p == "" {
+		return nil, fmt.Errorf("no passphrase given, set %s", EnvKeystorePassphrase)
+	}
+	return []byte(p), nil
+}
@@ -0,0 +1,154 @@
+// Package delegated implements a router which delegates the finding of
+// providers and peers to another node, through its HTTP API.
+package delegated
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	stack "github.com/ipfs/go-ipfs/routing/stack"
+
+	routing "gx/ipfs/QmNdaQ8itUU9jEZUwTsG4gHMaPmRfi6FEe89QjQAFbep3M/go-libp2p-routing"
+	notif "gx/ipfs/QmNdaQ8itUU9jEZUwTsG4gHMaPmRfi6FEe89QjQAFbep3M/go-libp2p-routing/notifications"
+	logging "gx/ipfs/QmSpJByNKFX1sCsHBEp3R73FL4NF6FnQTEGyNAXHm2GS52/go-log"
+	pstore "gx/ipfs/QmXZSd1qR5BxZkPyuwfT5jpqQFScZccoZvDneXsKzCNHWX/go-libp2p-peerstore"
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+var log = logging.Logger("delegated")
+
+// Router asks the node serving the API at its endpoint, such as
+// "http://10.0.0.1:5001", for providers and peers, with 'ipfs dht findprovs'
+// and 'ipfs dht findpeer'. It announces nothing: the delegate would announce
+// itself, not this node.
+type Router struct {
+	endpoint string
+	client   *http.Client
+}
+
+// New returns a router delegating to the API at endpoint.
+func New(endpoint string) (*Router, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("the endpoint %q is not an http or https URL", endpoint)
+	}
+	return &Router{
+		endpoint: strings.TrimRight(endpoint, "/"),
+		client:   http.DefaultClient,
+	}, nil
+}
+
+// query runs the API command cmd, and calls f with the query events it
+// outputs until f returns false.
+func (r *Router) query(ctx context.Context, cmd string, args url.Values, f func(*notif.QueryEvent) bool) error {
+	req, err := http.NewRequest("POST", r.endpoint+"/api/v0/"+cmd+"?"+args.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := r.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var e struct{ Message string }
+		b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+		if json.Unmarshal(b, &e) == nil && e.Message != "" {
+			return fmt.Errorf("%s: %s", cmd, e.Message)
+		}
+		return fmt.Errorf("%s: %s", cmd, resp.Status)
+	}
+
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var ev notif.QueryEvent
+		if err := dec.Decode(&ev); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if !f(&ev) {
+			return nil
+		}
+	}
+}
+
+func (r *Router) FindProvidersAsync(ctx context.Context, c *cid.Cid, count int) <-chan pstore.PeerInfo {
+	out := make(chan pstore.PeerInfo)
+	go func() {
+		defer close(out)
+		args := url.Values{"arg": {c.String()}}
+		if count > 0 {
+			args.Set("num-providers", fmt.Sprint(count))
+		}
+		err := r.query(ctx, "dht/findprovs", args, func(ev *notif.QueryEvent) bool {
+			if ev.Type != notif.Provider {
+				return true
+			}
+			for _, pi := range ev.Responses {
+				select {
+				case out <- *pi:
+				case <-ctx.Done():
+					return false
+				}
+			}
+			return true
+		})
+		if err != nil && ctx.Err() == nil {
+			log.Debugf("delegated lookup of the providers of %s failed: %s", c, err)
+		}
+	}()
+	return out
+}
+
+func (r *Router) FindPeer(ctx context.Context, p peer.ID) (pstore.PeerInfo, error) {
+	var found *pstore.PeerInfo
+	err := r.query(ctx, "dht/findpeer", url.Values{"arg": {p.Pretty()}}, func(ev *notif.QueryEvent) bool {
+		if ev.Type == notif.FinalPeer && len(ev.Responses) > 0 {
+			found = ev.Responses[0]
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		return pstore.PeerInfo{}, err
+	}
+	if found == nil {
+		return pstore.PeerInfo{}, routing.ErrNotFound
+	}
+	return *found, nil
+}
+
+func (r *Router) Provide(context.Context, *cid.Cid, bool) error {
+	return stack.ErrNotSupported
+}
+
+func (r *Router) PutValue(context.Context, string, []byte) error {
+	return stack.ErrNotSupported
+}
+
+func (r *Router) GetValue(context.Context, string) ([]byte, error) {
+	return nil, stack.ErrNotSupported
+}
+
+func (r *Router) GetValues(context.Context, string, int) ([]routing.RecvdVal, error) {
+	return nil, stack.ErrNotSupported
+}
+
+func (r *Router) Bootstrap(context.Context) error {
+	return nil
+}
+
+var _ routing.IpfsRouting = &Router{}
@@ -0,0 +1,143 @@
+// Package lan implements a router limited to the peers of the local
+// network, such as the ones found by mDNS discovery.
+package lan
+
+import (
+	"context"
+	"net"
+
+	stack "github.com/ipfs/go-ipfs/routing/stack"
+
+	routing "gx/ipfs/QmNdaQ8itUU9jEZUwTsG4gHMaPmRfi6FEe89QjQAFbep3M/go-libp2p-routing"
+	p2phost "gx/ipfs/QmUywuGNZoUKV8B9iyvup9bPkLiMrhTsyVMkeSXW5VxAfC/go-libp2p-host"
+	pstore "gx/ipfs/QmXZSd1qR5BxZkPyuwfT5jpqQFScZccoZvDneXsKzCNHWX/go-libp2p-peerstore"
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+	ma "gx/ipfs/QmcyqRMCAXVtYPS4DiBrA7sezL9rRGfW8Ctx7cywL4TXJj/go-multiaddr"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+	manet "gx/ipfs/Qmf1Gq7N45Rpuw7ev47uWgH6dLPtdnvcMRNPkVBwqjLJg2/go-multiaddr-net"
+)
+
+// localNets are the ranges of the addresses of local networks.
+var localNets []*net.IPNet
+
+func init() {
+	for _, s := range []string{
+		"10.0.0.0/8",
+		"172.16.0.0/12",
+		"192.168.0.0/16",
+		"169.254.0.0/16",
+		"127.0.0.0/8",
+		"fc00::/7",
+		"fe80::/10",
+		"::1/128",
+	} {
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			panic(err)
+		}
+		localNets = append(localNets, n)
+	}
+}
+
+// IsLocal tells whether a is an address of a local network.
+func IsLocal(a ma.Multiaddr) bool {
+	na, err := manet.ToNetAddr(a)
+	if err != nil {
+		return false
+	}
+	var ip net.IP
+	switch na := na.(type) {
+	case *net.TCPAddr:
+		ip = na.IP
+	case *net.UDPAddr:
+		ip = na.IP
+	case *net.IPAddr:
+		ip = na.IP
+	default:
+		return false
+	}
+	for _, n := range localNets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Router offers the peers connected over the local network as providers of
+// any content, leaving it to the exchange to ask them, and finds these
+// peers only. With mDNS discovery, which connects to the peers of the local
+// network, no query ever leaves it. It announces nothing, as the peers ask
+// each other directly.
+type Router struct {
+	host p2phost.Host
+}
+
+func New(h p2phost.Host) *Router {
+	return &Router{host: h}
+}
+
+// localPeers returns the peers connected over the local network, with
+// their local addresses.
+func (r *Router) localPeers() []pstore.PeerInfo {
+	var out []pstore.PeerInfo
+	seen := make(map[peer.ID]int)
+	for _, c := range r.host.Network().Conns() {
+		a := c.RemoteMultiaddr()
+		if !IsLocal(a) {
+			continue
+		}
+		p := c.RemotePeer()
+		if i, ok := seen[p]; ok {
+			out[i].Addrs = append(out[i].Addrs, a)
+			continue
+		}
+		seen[p] = len(out)
+		out = append(out, pstore.PeerInfo{ID: p, Addrs: []ma.Multiaddr{a}})
+	}
+	return out
+}
+
+func (r *Router) FindProvidersAsync(ctx context.Context, c *cid.Cid, count int) <-chan pstore.PeerInfo {
+	peers := r.localPeers()
+	if count > 0 && len(peers) > count {
+		peers = peers[:count]
+	}
+	out := make(chan pstore.PeerInfo, len(peers))
+	for _, pi := range peers {
+		out <- pi
+	}
+	close(out)
+	return out
+}
+
+func (r *Router) FindPeer(_ context.Context, p peer.ID) (pstore.PeerInfo, error) {
+	for _, pi := range r.localPeers() {
+		if pi.ID == p {
+			return pi, nil
+		}
+	}
+	return pstore.PeerInfo{}, routing.ErrNotFound
+}
+
+func (r *Router) Provide(context.Context, *cid.Cid, bool) error {
+	return stack.ErrNotSupported
+}
+
+func (r *Router) PutValue(context.Context, string, []byte) error {
+	return stack.ErrNotSupported
+}
+
+func (r *Router) GetValue(context.Context, string) ([]byte, error) {
+	return nil, routing.ErrNotFound
+}
+
+func (r *Router) GetValues(context.Context, string, int) ([]routing.RecvdVal, error) {
+	return nil, routing.ErrNotFound
+}
+
+func (r *Router) Bootstrap(context.Context) error {
+	return nil
+}
+
+var _ routing.IpfsRouting = &Router{}
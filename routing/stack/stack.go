@@ -0,0 +1,336 @@
+// Package stack composes routers, such as the DHT, delegates and static
+// mappings, into one routing system. Each router has roles, finding and
+// providing, and a timeout; the routers that find are queried in order, or
+// all at once.
+package stack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	routing "gx/ipfs/QmNdaQ8itUU9jEZUwTsG4gHMaPmRfi6FEe89QjQAFbep3M/go-libp2p-routing"
+	pstore "gx/ipfs/QmXZSd1qR5BxZkPyuwfT5jpqQFScZccoZvDneXsKzCNHWX/go-libp2p-peerstore"
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+// Roles of routers.
+const (
+	// RoleFind routers find providers, peers and values.
+	RoleFind = "find"
+	// RoleProvide routers announce content and publish values.
+	RoleProvide = "provide"
+)
+
+// ErrNotSupported is returned by routers for the operations outside of
+// their roles.
+var ErrNotSupported = errors.New("operation not supported by this router")
+
+var errNoRouter = errors.New("no router has this role")
+
+// Router is a routing system in a stack.
+type Router struct {
+	routing.IpfsRouting
+
+	// Name identifies the router in errors.
+	Name string
+	// CanFind and CanProvide are the roles of the router.
+	CanFind    bool
+	CanProvide bool
+	// Timeout bounds each operation on the router, if positive.
+	Timeout time.Duration
+}
+
+func (r *Router) context(ctx context.Context) (context.Context, context.CancelFunc) {
+	if r.Timeout > 0 {
+		return context.WithTimeout(ctx, r.Timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// Stack is a routing system made of routers. Content is provided and values
+// published to all the providing routers at once, and it succeeds if one of
+// them does. The finding routers are queried in order, the next one when a
+// router fails or runs out of time, or, if parallel, all at once.
+type Stack struct {
+	routers  []*Router
+	parallel bool
+}
+
+// New composes routers.
+func New(routers []*Router, parallel bool) *Stack {
+	return &Stack{routers: routers, parallel: parallel}
+}
+
+// Routers returns the routers of the stack.
+func (s *Stack) Routers() []*Router {
+	return s.routers
+}
+
+func (s *Stack) finders() []*Router {
+	var out []*Router
+	for _, r := range s.routers {
+		if r.CanFind {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+func (s *Stack) providers() []*Router {
+	var out []*Router
+	for _, r := range s.routers {
+		if r.CanProvide {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// combine returns routing.ErrNotFound if any router didn't find the key, or
+// the errors of all the routers.
+func combine(routers []*Router, errs []error) error {
+	if len(errs) == 1 {
+		return errs[0]
+	}
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		if err == routing.ErrNotFound {
+			return err
+		}
+		msgs[i] = fmt.Sprintf("%s: %s", routers[i].Name, err)
+	}
+	return errors.New(strings.Join(msgs, "; "))
+}
+
+// all runs f on every router at once, and succeeds if it does on one of
+// them. It succeeds if there are no routers.
+func (s *Stack) all(ctx context.Context, routers []*Router, f func(context.Context, *Router) error) error {
+	if len(routers) == 0 {
+		return nil
+	}
+	errs := make([]error, len(routers))
+	var wg sync.WaitGroup
+	for i, r := range routers {
+		wg.Add(1)
+		go func(i int, r *Router) {
+			defer wg.Done()
+			rctx, cancel := r.context(ctx)
+			defer cancel()
+			errs[i] = f(rctx, r)
+		}(i, r)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err == nil {
+			return nil
+		}
+	}
+	return combine(routers, errs)
+}
+
+// first runs f on the routers, in order or all at once, until it succeeds
+// on one of them, and returns the index of that router. f stores its
+// results by the index of the router.
+func (s *Stack) first(ctx context.Context, routers []*Router, f func(context.Context, *Router, int) error) (int, error) {
+	if len(routers) == 0 {
+		return -1, errNoRouter
+	}
+
+	if !s.parallel {
+		var errs []error
+		for i, r := range routers {
+			rctx, cancel := r.context(ctx)
+			err := f(rctx, r, i)
+			cancel()
+			if err == nil {
+				return i, nil
+			}
+			if ctx.Err() != nil {
+				return -1, ctx.Err()
+			}
+			errs = append(errs, err)
+		}
+		return -1, combine(routers, errs)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	type result struct {
+		i   int
+		err error
+	}
+	results := make(chan result, len(routers))
+	for i, r := range routers {
+		go func(i int, r *Router) {
+			rctx, cancel := r.context(ctx)
+			defer cancel()
+			results <- result{i, f(rctx, r, i)}
+		}(i, r)
+	}
+
+	errs := make([]error, len(routers))
+	for range routers {
+		res := <-results
+		if res.err == nil {
+			return res.i, nil
+		}
+		errs[res.i] = res.err
+	}
+	return -1, combine(routers, errs)
+}
+
+func (s *Stack) Provide(ctx context.Context, c *cid.Cid, brdcst bool) error {
+	return s.all(ctx, s.providers(), func(ctx context.Context, r *Router) error {
+		return r.Provide(ctx, c, brdcst)
+	})
+}
+
+func (s *Stack) PutValue(ctx context.Context, key string, val []byte) error {
+	return s.all(ctx, s.providers(), func(ctx context.Context, r *Router) error {
+		return r.PutValue(ctx, key, val)
+	})
+}
+
+func (s *Stack) GetValue(ctx context.Context, key string) ([]byte, error) {
+	routers := s.finders()
+	vals := make([][]byte, len(routers))
+	i, err := s.first(ctx, routers, func(ctx context.Context, r *Router, i int) error {
+		val, err := r.GetValue(ctx, key)
+		vals[i] = val
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return vals[i], nil
+}
+
+func (s *Stack) GetValues(ctx context.Context, key string, count int) ([]routing.RecvdVal, error) {
+	routers := s.finders()
+	vals := make([][]routing.RecvdVal, len(routers))
+	i, err := s.first(ctx, routers, func(ctx context.Context, r *Router, i int) error {
+		val, err := r.GetValues(ctx, key, count)
+		vals[i] = val
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return vals[i], nil
+}
+
+func (s *Stack) FindPeer(ctx context.Context, p peer.ID) (pstore.PeerInfo, error) {
+	routers := s.finders()
+	infos := make([]pstore.PeerInfo, len(routers))
+	i, err := s.first(ctx, routers, func(ctx context.Context, r *Router, i int) error {
+		pi, err := r.FindPeer(ctx, p)
+		if err == nil && len(pi.Addrs) == 0 {
+			// a peer with no address is as good as not found
+			err = routing.ErrNotFound
+		}
+		infos[i] = pi
+		return err
+	})
+	if err != nil {
+		return pstore.PeerInfo{}, err
+	}
+	return infos[i], nil
+}
+
+// FindProvidersAsync returns up to count providers, all of them if count
+// isn't positive, found by the routers one after the other or all at once.
+func (s *Stack) FindProvidersAsync(ctx context.Context, c *cid.Cid, count int) <-chan pstore.PeerInfo {
+	out := make(chan pstore.PeerInfo)
+	routers := s.finders()
+
+	go func() {
+		defer close(out)
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		seen := make(map[peer.ID]bool)
+		// send forwards a provider, and returns false once enough were
+		send := func(pi pstore.PeerInfo) bool {
+			if seen[pi.ID] {
+				return true
+			}
+			seen[pi.ID] = true
+			select {
+			case out <- pi:
+			case <-ctx.Done():
+				return false
+			}
+			return count <= 0 || len(seen) < count
+		}
+
+		if !s.parallel {
+			for _, r := range routers {
+				left := count
+				if count > 0 {
+					left = count - len(seen)
+				}
+				rctx, rcancel := r.context(ctx)
+				more := true
+				for pi := range r.FindProvidersAsync(rctx, c, left) {
+					if more = send(pi); !more {
+						break
+					}
+				}
+				rcancel()
+				if !more || ctx.Err() != nil {
+					return
+				}
+			}
+			return
+		}
+
+		merged := make(chan pstore.PeerInfo)
+		var wg sync.WaitGroup
+		for _, r := range routers {
+			wg.Add(1)
+			go func(r *Router) {
+				defer wg.Done()
+				rctx, rcancel := r.context(ctx)
+				defer rcancel()
+				for pi := range r.FindProvidersAsync(rctx, c, count) {
+					select {
+					case merged <- pi:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}(r)
+		}
+		go func() {
+			wg.Wait()
+			close(merged)
+		}()
+		for pi := range merged {
+			if !send(pi) {
+				// let the routers see the cancellation and merged close
+				cancel()
+				for range merged {
+				}
+				return
+			}
+		}
+	}()
+	return out
+}
+
+func (s *Stack) Bootstrap(ctx context.Context) error {
+	for _, r := range s.routers {
+		if err := r.Bootstrap(ctx); err != nil {
+			return fmt.Errorf("%s: %s", r.Name, err)
+		}
+	}
+	return nil
+}
+
+var _ routing.IpfsRouting = &Stack{}
@@ -0,0 +1,156 @@
+package stack
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	testutil "github.com/ipfs/go-ipfs/thirdparty/testutil"
+
+	routing "gx/ipfs/QmNdaQ8itUU9jEZUwTsG4gHMaPmRfi6FEe89QjQAFbep3M/go-libp2p-routing"
+	pstore "gx/ipfs/QmXZSd1qR5BxZkPyuwfT5jpqQFScZccoZvDneXsKzCNHWX/go-libp2p-peerstore"
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+// fakeRouter finds its providers after a delay, and records what it was
+// asked to provide.
+type fakeRouter struct {
+	providers []peer.ID
+	delay     time.Duration
+	err       error
+	provided  []*cid.Cid
+}
+
+func (f *fakeRouter) wait(ctx context.Context) error {
+	select {
+	case <-time.After(f.delay):
+		return f.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (f *fakeRouter) FindProvidersAsync(ctx context.Context, _ *cid.Cid, count int) <-chan pstore.PeerInfo {
+	out := make(chan pstore.PeerInfo)
+	go func() {
+		defer close(out)
+		if f.wait(ctx) != nil {
+			return
+		}
+		for _, p := range f.providers {
+			select {
+			case out <- pstore.PeerInfo{ID: p}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+func (f *fakeRouter) FindPeer(ctx context.Context, p peer.ID) (pstore.PeerInfo, error) {
+	if err := f.wait(ctx); err != nil {
+		return pstore.PeerInfo{}, err
+	}
+	return pstore.PeerInfo{}, routing.ErrNotFound
+}
+
+func (f *fakeRouter) Provide(ctx context.Context, c *cid.Cid, _ bool) error {
+	if err := f.wait(ctx); err != nil {
+		return err
+	}
+	f.provided = append(f.provided, c)
+	return nil
+}
+
+func (f *fakeRouter) PutValue(context.Context, string, []byte) error { return ErrNotSupported }
+func (f *fakeRouter) GetValue(ctx context.Context, _ string) ([]byte, error) {
+	if err := f.wait(ctx); err != nil {
+		return nil, err
+	}
+	return []byte("value"), nil
+}
+func (f *fakeRouter) GetValues(context.Context, string, int) ([]routing.RecvdVal, error) {
+	return nil, routing.ErrNotFound
+}
+func (f *fakeRouter) Bootstrap(context.Context) error { return nil }
+
+func collect(ch <-chan pstore.PeerInfo) []peer.ID {
+	var out []peer.ID
+	for pi := range ch {
+		out = append(out, pi.ID)
+	}
+	return out
+}
+
+func TestStackOrder(t *testing.T) {
+	a, b, c := testutil.RandPeerIDFatal(t), testutil.RandPeerIDFatal(t), testutil.RandPeerIDFatal(t)
+	slow := &fakeRouter{providers: []peer.ID{c}, delay: time.Second}
+	first := &fakeRouter{providers: []peer.ID{a, b}}
+	second := &fakeRouter{providers: []peer.ID{b, c}}
+
+	s := New([]*Router{
+		{IpfsRouting: slow, Name: "slow", CanFind: true, Timeout: 50 * time.Millisecond},
+		{IpfsRouting: first, Name: "first", CanFind: true, CanProvide: true},
+		{IpfsRouting: second, Name: "second", CanFind: true},
+	}, false)
+
+	c0 := testCid(t)
+	provs := collect(s.FindProvidersAsync(context.Background(), c0, 0))
+	if len(provs) != 3 || provs[0] != a || provs[1] != b || provs[2] != c {
+		t.Fatalf("expected the providers in the order of the routers, without duplicates, got %v", provs)
+	}
+
+	provs = collect(s.FindProvidersAsync(context.Background(), c0, 1))
+	if len(provs) != 1 || provs[0] != a {
+		t.Fatalf("expected one provider, got %v", provs)
+	}
+
+	if err := s.Provide(context.Background(), c0, true); err != nil {
+		t.Fatal(err)
+	}
+	if len(first.provided) != 1 || len(second.provided) != 0 || len(slow.provided) != 0 {
+		t.Fatal("expected the content to be provided to the providing router only")
+	}
+}
+
+func TestStackParallel(t *testing.T) {
+	a := testutil.RandPeerIDFatal(t)
+	failing := &fakeRouter{err: errors.New("down")}
+	slow := &fakeRouter{delay: time.Second}
+	fast := &fakeRouter{providers: []peer.ID{a}, delay: 10 * time.Millisecond}
+
+	s := New([]*Router{
+		{IpfsRouting: failing, Name: "failing", CanFind: true},
+		{IpfsRouting: slow, Name: "slow", CanFind: true},
+		{IpfsRouting: fast, Name: "fast", CanFind: true},
+	}, true)
+
+	start := time.Now()
+	val, err := s.GetValue(context.Background(), "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(val) != "value" || time.Since(start) > 500*time.Millisecond {
+		t.Fatal("expected the first answer of the routers queried at once")
+	}
+
+	provs := collect(s.FindProvidersAsync(context.Background(), testCid(t), 1))
+	if len(provs) != 1 || provs[0] != a {
+		t.Fatalf("expected the provider of the fast router, got %v", provs)
+	}
+
+	if _, err := s.FindPeer(context.Background(), a); err != routing.ErrNotFound {
+		t.Fatalf("expected %s, got %v", routing.ErrNotFound, err)
+	}
+}
+
+func testCid(t *testing.T) *cid.Cid {
+	c, err := cid.Decode("QmY3hE8xgFCjGcz6PHgnvJz5HZi1BaKRfPkn1ghZUcYMjD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
@@ -0,0 +1,137 @@
+// Package static implements a router over a file mapping CIDs to the peers
+// providing them, for deployments where content lives at known places.
+package static
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	stack "github.com/ipfs/go-ipfs/routing/stack"
+	ipfsaddr "github.com/ipfs/go-ipfs/thirdparty/ipfsaddr"
+
+	routing "gx/ipfs/QmNdaQ8itUU9jEZUwTsG4gHMaPmRfi6FEe89QjQAFbep3M/go-libp2p-routing"
+	pstore "gx/ipfs/QmXZSd1qR5BxZkPyuwfT5jpqQFScZccoZvDneXsKzCNHWX/go-libp2p-peerstore"
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+	ma "gx/ipfs/QmcyqRMCAXVtYPS4DiBrA7sezL9rRGfW8Ctx7cywL4TXJj/go-multiaddr"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+// Router finds the providers of the CIDs of its mapping, and the peers
+// whose addresses it lists. It announces nothing.
+type Router struct {
+	providers map[string][]peer.ID
+	addrs     map[peer.ID][]ma.Multiaddr
+}
+
+// Load reads the mapping of the file at path, see Parse.
+func Load(path string) (*Router, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+// Parse reads a mapping made of lines of a CID followed by the addresses of
+// its providers, such as:
+//
+//	QmHash /ip4/10.0.0.1/tcp/4001/ipfs/QmPeer /ip4/10.0.0.2/tcp/4001/ipfs/QmPeer2
+//
+// Empty lines and lines starting with '#' are ignored.
+func Parse(r io.Reader) (*Router, error) {
+	sr := &Router{
+		providers: make(map[string][]peer.ID),
+		addrs:     make(map[peer.ID][]ma.Multiaddr),
+	}
+
+	scan := bufio.NewScanner(r)
+	for line := 1; scan.Scan(); line++ {
+		fields := strings.Fields(scan.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		c, err := cid.Decode(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid cid: %s", line, err)
+		}
+		if len(fields) == 1 {
+			return nil, fmt.Errorf("line %d: no provider for %s", line, c)
+		}
+		for _, s := range fields[1:] {
+			addr, err := ipfsaddr.ParseString(s)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid provider address %q: %s", line, s, err)
+			}
+			sr.add(c, addr.ID(), addr.Transport())
+		}
+	}
+	if err := scan.Err(); err != nil {
+		return nil, err
+	}
+	return sr, nil
+}
+
+func (sr *Router) add(c *cid.Cid, p peer.ID, addr ma.Multiaddr) {
+	k := c.KeyString()
+	found := false
+	for _, q := range sr.providers[k] {
+		if q == p {
+			found = true
+			break
+		}
+	}
+	if !found {
+		sr.providers[k] = append(sr.providers[k], p)
+	}
+	if addr != nil {
+		sr.addrs[p] = append(sr.addrs[p], addr)
+	}
+}
+
+func (sr *Router) FindProvidersAsync(ctx context.Context, c *cid.Cid, count int) <-chan pstore.PeerInfo {
+	provs := sr.providers[c.KeyString()]
+	if count > 0 && len(provs) > count {
+		provs = provs[:count]
+	}
+	out := make(chan pstore.PeerInfo, len(provs))
+	for _, p := range provs {
+		out <- pstore.PeerInfo{ID: p, Addrs: sr.addrs[p]}
+	}
+	close(out)
+	return out
+}
+
+func (sr *Router) FindPeer(_ context.Context, p peer.ID) (pstore.PeerInfo, error) {
+	addrs, ok := sr.addrs[p]
+	if !ok {
+		return pstore.PeerInfo{}, routing.ErrNotFound
+	}
+	return pstore.PeerInfo{ID: p, Addrs: addrs}, nil
+}
+
+func (sr *Router) Provide(context.Context, *cid.Cid, bool) error {
+	return stack.ErrNotSupported
+}
+
+func (sr *Router) PutValue(context.Context, string, []byte) error {
+	return stack.ErrNotSupported
+}
+
+func (sr *Router) GetValue(context.Context, string) ([]byte, error) {
+	return nil, routing.ErrNotFound
+}
+
+func (sr *Router) GetValues(context.Context, string, int) ([]routing.RecvdVal, error) {
+	return nil, routing.ErrNotFound
+}
+
+func (sr *Router) Bootstrap(context.Context) error {
+	return nil
+}
+
+var _ routing.IpfsRouting = &Router{}
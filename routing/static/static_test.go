@@ -0,0 +1,61 @@
+package static
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	routing "gx/ipfs/QmNdaQ8itUU9jEZUwTsG4gHMaPmRfi6FEe89QjQAFbep3M/go-libp2p-routing"
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+const mapping = `
+# the origins of the site
+QmY3hE8xgFCjGcz6PHgnvJz5HZi1BaKRfPkn1ghZUcYMjD /ip4/10.0.0.1/tcp/4001/ipfs/QmSoLnSGccFuZQJzRadHn95W2CrSFmZuTdDWP8HXaHca9z /ip4/10.0.0.2/tcp/4001/ipfs/QmSoLPppuBtQSGwKDZT2M73ULpjvfd3aZ6ha4oFGL1KrGM
+QmRzTuh2Lpuz7Gr39stNr6mTFdqAghsZec1JoUnfySUzcy /ip4/10.0.0.3/tcp/4001/ipfs/QmSoLnSGccFuZQJzRadHn95W2CrSFmZuTdDWP8HXaHca9z
+`
+
+func TestStaticRouter(t *testing.T) {
+	r, err := Parse(strings.NewReader(mapping))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, _ := cid.Decode("QmY3hE8xgFCjGcz6PHgnvJz5HZi1BaKRfPkn1ghZUcYMjD")
+	var provs []peer.ID
+	for pi := range r.FindProvidersAsync(context.Background(), c, 0) {
+		provs = append(provs, pi.ID)
+	}
+	if len(provs) != 2 || provs[0].Pretty() != "QmSoLnSGccFuZQJzRadHn95W2CrSFmZuTdDWP8HXaHca9z" {
+		t.Fatalf("unexpected providers %v", provs)
+	}
+
+	pi, err := r.FindPeer(context.Background(), provs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pi.Addrs) != 2 {
+		t.Fatalf("expected the addresses of both lines, got %v", pi.Addrs)
+	}
+
+	other, _ := cid.Decode("QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn")
+	if _, ok := <-r.FindProvidersAsync(context.Background(), other, 0); ok {
+		t.Fatal("expected no provider for content out of the mapping")
+	}
+	if _, err := r.FindPeer(context.Background(), peer.ID("unknown")); err != routing.ErrNotFound {
+		t.Fatalf("expected %s, got %v", routing.ErrNotFound, err)
+	}
+}
+
+func TestStaticRouterInvalid(t *testing.T) {
+	for _, m := range []string{
+		"notacid /ip4/10.0.0.1/tcp/4001/ipfs/QmSoLnSGccFuZQJzRadHn95W2CrSFmZuTdDWP8HXaHca9z",
+		"QmY3hE8xgFCjGcz6PHgnvJz5HZi1BaKRfPkn1ghZUcYMjD",
+		"QmY3hE8xgFCjGcz6PHgnvJz5HZi1BaKRfPkn1ghZUcYMjD /ip4/10.0.0.1/tcp/4001",
+	} {
+		if _, err := Parse(strings.NewReader(m)); err == nil {
+			t.Errorf("expected an error for %q", m)
+		}
+	}
+}
@@ -112,6 +112,27 @@ test_expect_success "'ipfs commands --flags' output looks good" '
 	grep "ipfs repo gc --quiet / ipfs repo gc -q" commands.txt
 '
 
+test_expect_success "'ipfs commands inspect' succeeds" '
+	ipfs commands inspect >schema.json
+'
+
+test_expect_success "'ipfs commands inspect' output looks good" '
+	grep "\"Name\": \"ipfs pin add\"" schema.json &&
+	grep "\"Name\": \"ipfs commands inspect\"" schema.json
+'
+
+test_expect_success "'ipfs commands inspect' describes a command" '
+	ipfs commands inspect pin add >schema.json &&
+	grep "\"Name\": \"ipfs pin add\"" schema.json &&
+	grep "\"Type\": \"bool\"" schema.json &&
+	grep "\"Default\": true" schema.json &&
+	test_must_fail grep "\"Name\": \"ipfs pin ls\"" schema.json
+'
+
+test_expect_success "'ipfs commands inspect' fails on unknown commands" '
+	test_must_fail ipfs commands inspect nocommand
+'
+
 
 
 test_done
@@ -126,6 +126,32 @@ test_expect_success "clean up ipfs dir" '
 	rm -rf "$IPFS_PATH"
 '
 
+test_expect_success "'ipfs init --new-mnemonic' succeeds" '
+	ipfs init --new-mnemonic --empty-repo >actual_init &&
+	PHRASE=$(sed -n "/write it down/{n;p}" actual_init) &&
+	test $(echo $PHRASE | wc -w) -eq 24 &&
+	PEERID=$(ipfs config Identity.PeerID)
+'
+
+test_expect_success "clean up ipfs dir" '
+	rm -rf "$IPFS_PATH"
+'
+
+test_expect_success "'ipfs init --mnemonic' recovers the peer ID" '
+	ipfs init --mnemonic="$PHRASE" --empty-repo &&
+	test "$(ipfs config Identity.PeerID)" = "$PEERID"
+'
+
+test_expect_success "clean up ipfs dir" '
+	rm -rf "$IPFS_PATH"
+'
+
+test_expect_success "'ipfs init --mnemonic' fails on a wrong checksum" '
+	WRONG="abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon" &&
+	test_must_fail ipfs init --mnemonic="$WRONG" 2>init_err &&
+	grep "invalid mnemonic" init_err
+'
+
 test_init_ipfs
 
 test_launch_ipfs_daemon
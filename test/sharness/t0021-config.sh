@@ -131,8 +131,17 @@ test_config_cmd() {
        grep "\"PrivKey\":" "$IPFS_PATH/config" | grep -e ": \".\+\"" >/dev/null
   '
 
+  test_expect_success "'ipfs config show --include-secrets' includes privkey" '
+       ipfs config show --include-secrets > real_config &&
+       grep "\"PrivKey\":" real_config | grep -e ": \".\+\"" >/dev/null
+  '
+
+  test_expect_success "'ipfs config show --redacted=false' includes privkey" '
+       ipfs config show --redacted=false > unredacted_config &&
+       test_cmp real_config unredacted_config
+  '
+
   test_expect_success "'ipfs config replace' with privkey errors out" '
-       cp "$IPFS_PATH/config" real_config &&
        test_expect_code 1 ipfs config replace - < real_config 2> replace_out
   '
 
@@ -142,7 +151,6 @@ test_config_cmd() {
   '
 
   test_expect_success "'ipfs config replace' with lower case privkey errors out" '
-       cp "$IPFS_PATH/config" real_config &&
        sed -i -e '\''s/PrivKey/privkey/'\'' real_config &&
        test_expect_code 1 ipfs config replace - < real_config 2> replace_out
   '
@@ -161,6 +169,19 @@ test_config_cmd
 # should work online
 test_launch_ipfs_daemon
 test_config_cmd
+
+test_expect_success "'config/show?include-secrets' is refused through the API" '
+  curl -s "http://$API_ADDR/api/v0/config/show?include-secrets=true" > api_show_out &&
+  grep "cannot show the secrets of the config through API" api_show_out &&
+  test_expect_code 1 grep PrivKey api_show_out
+'
+
+test_expect_success "'config/show?redacted=false' is refused through the API" '
+  curl -s "http://$API_ADDR/api/v0/config/show?redacted=false" > api_show_out &&
+  grep "cannot show the secrets of the config through API" api_show_out &&
+  test_expect_code 1 grep PrivKey api_show_out
+'
+
 test_kill_ipfs_daemon
 
 
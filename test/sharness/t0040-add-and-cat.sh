@@ -482,6 +482,25 @@ test_expect_success "ipfs add --cid-version=9 fails" '
 	grep -q "unknown CID version" add_out
 '
 
+test_expect_success "ipfs add --reproducible prints its profile" '
+	echo "reproducible" > rfile.txt &&
+	ipfs add --reproducible --cid-version=1 -q rfile.txt >repro_hash 2>repro_err &&
+	grep "^reproducible profile: " repro_err | sed "s/^reproducible profile: //" >repro_profile &&
+	echo "v=1,chunker=size-262144,layout=balanced,cid-version=1,raw-leaves=true,hash=sha2-256,wrap=false,hidden=false" >repro_expected &&
+	test_cmp repro_expected repro_profile
+'
+
+test_expect_success "ipfs add --profile --expect reproduces the hash" '
+	ipfs add --profile="$(cat repro_profile)" --expect="$(cat repro_hash)" -q rfile.txt >repro_hash2 2>/dev/null &&
+	test_cmp repro_hash repro_hash2
+'
+
+test_expect_success "ipfs add --expect fails on a mismatch" '
+	echo "different" > rfile2.txt &&
+	test_must_fail ipfs add --expect="$(cat repro_hash)" rfile2.txt 2>&1 | tee expect_out &&
+	grep -q "does not match the expected" expect_out
+'
+
 test_kill_ipfs_daemon
 
 # should work offline
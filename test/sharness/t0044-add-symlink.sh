@@ -65,6 +65,56 @@ adding with no symlink" '
 		ipfs add -rq files2/a/d/c > sym &&
 		test_cmp no_sym sym
 	'
+
+	test_expect_success "ipfs add --follow-symlinks adds the file pointed to" '
+		ipfs add -q --follow-symlinks files/bar/baz > followed_out &&
+		ipfs add -q files/foo/baz > followed_exp &&
+		test_cmp followed_exp followed_out
+	'
+
+	test_expect_success "ipfs add -r --follow-symlinks replaces the links" '
+		ipfs add -q -r --follow-symlinks files/bar > followed_all &&
+		DIR=$(tail -n 1 followed_all) &&
+		ipfs cat $DIR/baz > followed_cat &&
+		echo "some text" > followed_cat_exp &&
+		test_cmp followed_cat_exp followed_cat
+	'
+
+	test_expect_success "ipfs add --follow-symlinks fails on a broken link" '
+		test_must_fail ipfs add -q --follow-symlinks files/bad 2> broken_err &&
+		grep "cannot follow the symlink" broken_err
+	'
+
+	test_expect_success "ipfs add -r --follow-symlinks fails on a loop" '
+		mkdir -p loop/sub &&
+		ln -sf .. loop/sub/up &&
+		test_must_fail ipfs add -q -r --follow-symlinks loop 2> loop_err &&
+		grep "loops back to" loop_err
+	'
+
+	test_expect_success "ipfs ls shows the targets of symlinks" '
+		ipfs ls $(ipfs add -q -r files | tail -n 1)/bar > ls_out &&
+		grep "baz -> files/foo/baz" ls_out &&
+		ipfs file ls $(cat goodlink_out) > file_ls_out &&
+		grep -- "-> files/foo/baz" file_ls_out
+	'
+
+	test_expect_success "ipfs get recreates the symlinks" '
+		rm -rf got &&
+		ipfs get -o got $(ipfs add -q -r files | tail -n 1) &&
+		echo files/foo/baz > readlink_exp &&
+		readlink got/bar/baz > readlink_out &&
+		test_cmp readlink_exp readlink_out
+	'
+
+	test_expect_success "ipfs get refuses symlinks pointing out of the directory" '
+		mkdir -p escape &&
+		ln -sf ../../outside escape/up &&
+		HASH=$(ipfs add -q -r escape | tail -n 1) &&
+		rm -rf got_escape &&
+		test_must_fail ipfs get -o got_escape $HASH 2> escape_err &&
+		grep "outside of the output directory" escape_err
+	'
 }
 
 test_init_ipfs
@@ -164,6 +164,30 @@ test_expect_success "'ipfs ls --resolve-type=false' ok and does not hang" '
 	go-timeout 2 ipfs ls --resolve-type=false $DIR
 '
 
+# with --offline, the commands fail at once rather than searching the
+# network for the missing block
+test_expect_success "'ipfs ls --offline' fails without waiting" '
+	test_must_fail go-timeout 2 ipfs ls --offline $DIR 2>ls_err &&
+	grep -q "not found" ls_err
+'
+
+test_expect_success "'ipfs cat --offline' fails without waiting" '
+	test_must_fail go-timeout 2 ipfs cat --offline $FILE 2>cat_err &&
+	grep -q "not found" cat_err
+'
+
+test_expect_success "'ipfs block stat --offline' fails without waiting" '
+	test_must_fail go-timeout 2 ipfs block stat --offline $FILE 2>stat_err &&
+	grep -q "not found" stat_err
+'
+
+test_expect_success "'ipfs cat --offline' reads the local blocks" '
+	echo local >local_file &&
+	LOCAL=$(ipfs add -q local_file) &&
+	ipfs cat --offline $LOCAL >local_out &&
+	test_cmp local_file local_out
+'
+
 test_kill_ipfs_daemon
 
 test_done
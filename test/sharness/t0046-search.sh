@@ -0,0 +1,75 @@
+#!/bin/sh
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test the local search index"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success "files added with the index disabled are not indexed" '
+	mkdir -p photos &&
+	echo "not indexed" > photos/unindexed.txt &&
+	ipfs add -r -q photos > /dev/null &&
+	ipfs search unindexed > search_out &&
+	test_must_be_empty search_out
+'
+
+test_expect_success "enable the index" '
+	ipfs config --json Experimental.LocalSearchIndex true
+'
+
+test_expect_success "added files are indexed" '
+	echo "<html><body>hi</body></html>" > photos/Holiday.html &&
+	echo "notes" > photos/notes.txt &&
+	PHOTOS=$(ipfs add -r -Q photos) &&
+	HOLIDAY=$(ipfs add -q -n photos/Holiday.html) &&
+	ipfs search holiday > search_out &&
+	grep "$HOLIDAY" search_out &&
+	grep "/ipfs/$PHOTOS/Holiday.html" search_out &&
+	test $(wc -l < search_out) -eq 1
+'
+
+test_expect_success "search filters on mime types" '
+	ipfs search --type=text/html photos > search_out &&
+	test $(wc -l < search_out) -eq 1 &&
+	grep "Holiday.html" search_out
+'
+
+test_expect_success "search matches the start of words" '
+	ipfs search holi > search_out &&
+	grep "Holiday.html" search_out &&
+	ipfs search oliday > search_out &&
+	test_must_be_empty search_out
+'
+
+test_expect_success "files written to MFS are indexed" '
+	echo "draft" | ipfs files write --create /draft.txt &&
+	ipfs search draft > search_out &&
+	grep "/mfs/draft.txt" search_out
+'
+
+test_expect_success "search filters on paths" '
+	ipfs search --path=/mfs draft > search_out &&
+	grep "/mfs/draft.txt" search_out &&
+	ipfs search --path=/ipfs/$PHOTOS draft > search_out &&
+	test_must_be_empty search_out &&
+	ipfs search --path=/ipfs/$PHOTOS > search_out &&
+	test $(wc -l < search_out) -eq 3
+'
+
+test_expect_success "files no longer pinned are not listed" '
+	ipfs pin rm $PHOTOS &&
+	ipfs search holiday > search_out &&
+	test_must_be_empty search_out
+'
+
+test_expect_success "files removed from MFS are not listed" '
+	ipfs files rm /draft.txt &&
+	ipfs search draft > search_out &&
+	test_must_be_empty search_out
+'
+
+test_done
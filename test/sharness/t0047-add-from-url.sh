@@ -0,0 +1,74 @@
+#!/bin/sh
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test add --from-url"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success "add a file to serve from the gateway" '
+	echo "hello from a URL" > hello.txt &&
+	HASH=$(ipfs add -q hello.txt)
+'
+
+test_expect_success "allow the fetches from the local gateway" '
+	ipfs config --json Import.URLAllowPrivate true
+'
+
+test_launch_ipfs_daemon
+
+test_expect_success "ipfs add --from-url adds the content of a URL" '
+	ipfs add --from-url="http://$GWAY_ADDR/ipfs/$HASH" > url_out &&
+	echo "added $HASH $HASH" > url_exp &&
+	test_cmp url_exp url_out
+'
+
+test_expect_success "ipfs add --from-url -w keeps the name of the file" '
+	ipfs add -w -Q --from-url="http://$GWAY_ADDR/ipfs/$HASH" > wrap_out &&
+	ipfs ls $(cat wrap_out) | grep -q "$HASH"
+'
+
+test_expect_success "ipfs add --from-url fails on a missing URL" '
+	test_must_fail ipfs add --from-url="http://$GWAY_ADDR/foo" 2>&1 | tee missing_out &&
+	grep -q "404" missing_out
+'
+
+test_expect_success "ipfs add --from-url can't be given files" '
+	test_must_fail ipfs add --from-url="http://$GWAY_ADDR/ipfs/$HASH" hello.txt
+'
+
+test_expect_success "ipfs add --from-url refuses schemes not allowed" '
+	ipfs config --json Import.URLSchemes "[\"https\"]" &&
+	test_must_fail ipfs add --from-url="http://$GWAY_ADDR/ipfs/$HASH" 2>&1 | tee scheme_out &&
+	grep -q "fetching http URLs is not allowed" scheme_out
+'
+
+test_expect_success "ipfs add --from-url refuses content too large" '
+	ipfs config --json Import.URLSchemes "[\"http\"]" &&
+	ipfs config Import.URLMaxSize 10B &&
+	test_must_fail ipfs add --from-url="http://$GWAY_ADDR/ipfs/$HASH" 2>&1 | tee size_out &&
+	grep -q "exceeds Import.URLMaxSize" size_out
+'
+
+test_expect_success "ipfs add --from-url denies private addresses by default" '
+	ipfs config Import.URLMaxSize 1MB &&
+	ipfs config --json Import.URLAllowPrivate false &&
+	test_must_fail ipfs add --from-url="http://$GWAY_ADDR/ipfs/$HASH" 2>&1 | tee private_out &&
+	grep -q "Import.URLAllowPrivate" private_out
+'
+
+test_expect_success "ipfs add --from-url can't be given stdin" '
+	test_must_fail sh -c "echo foo | ipfs add --from-url=http://$GWAY_ADDR/ipfs/$HASH"
+'
+
+test_expect_success "ipfs add requires files without a URL" '
+	curl -s -X POST "http://$API_ADDR/api/v0/add" > none_out &&
+	grep -q "File argument .path. is required" none_out
+'
+
+test_kill_ipfs_daemon
+
+test_done
@@ -209,4 +209,41 @@ test_expect_success "no panic in output" '
 	test_expect_code 1 grep "panic" stat_out
 '
 
+#
+# Hash policy
+#
+
+test_expect_success "put a sha1 block" '
+	SHA1HASH=$(echo "sha1 block" | ipfs block put --format=raw --mhtype=sha1)
+'
+
+test_expect_success "deny sha1" '
+	ipfs config --json Datastore.DeniedHashes "[\"sha1\"]"
+'
+
+test_expect_success "'ipfs block put' refuses sha1 blocks" '
+	echo "another sha1 block" | test_must_fail ipfs block put --format=raw --mhtype=sha1 2> put_err &&
+	grep "is denied" put_err
+'
+
+test_expect_success "'ipfs block get' refuses sha1 blocks" '
+	test_must_fail ipfs block get $SHA1HASH 2> get_err &&
+	grep "is denied" get_err
+'
+
+test_expect_success "sha2 blocks are still stored" '
+	echo "sha2 block" | ipfs block put --format=raw
+'
+
+test_expect_success "'ipfs block rm' removes sha1 blocks" '
+	ipfs block rm $SHA1HASH
+'
+
+test_expect_success "unknown hash functions are refused" '
+	ipfs config --json Datastore.DeniedHashes "[\"sha0\"]" &&
+	test_must_fail ipfs block stat $HASH 2> policy_err &&
+	grep "invalid Datastore.DeniedHashes" policy_err &&
+	ipfs config --json Datastore.DeniedHashes "[]"
+'
+
 test_done
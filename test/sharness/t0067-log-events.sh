@@ -0,0 +1,61 @@
+#!/bin/sh
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test the lifecycle events of content"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+test_launch_ipfs_daemon
+
+test_expect_success "unknown event types are rejected" '
+	test_must_fail ipfs log tail --event-types=added,moved 2> tail_err &&
+	grep "unknown event type \"moved\"" tail_err
+'
+
+test_expect_success "start tailing the events" '
+	ipfs log tail --event-types=added,pinned,unpinned,gced > events_out &
+	TAIL_PID=$! &&
+	go-sleep 500ms
+'
+
+test_expect_success "add, unpin and gc a file" '
+	HASH=$(echo "lifecycle" | ipfs add -q) &&
+	ipfs pin rm $HASH &&
+	ipfs repo gc &&
+	go-sleep 500ms
+'
+
+test_expect_success "stop tailing" '
+	kill $TAIL_PID
+'
+
+test_expect_success "the events were output" '
+	grep "\"Type\":\"added\",\"Cid\":\"$HASH\"" events_out &&
+	grep "\"Type\":\"pinned\",\"Cid\":\"$HASH\"" events_out &&
+	grep "\"Type\":\"unpinned\",\"Cid\":\"$HASH\"" events_out &&
+	grep "\"Type\":\"gced\",\"Cid\":\"$HASH\"" events_out
+'
+
+test_expect_success "the API serves the events over http" '
+	curl -sN "http://$API_ADDR/events?types=pinned" > http_events &
+	CURL_PID=$! &&
+	go-sleep 500ms &&
+	HASH2=$(echo "served" | ipfs add -q) &&
+	go-sleep 500ms &&
+	kill $CURL_PID &&
+	grep "\"Type\":\"pinned\",\"Cid\":\"$HASH2\"" http_events &&
+	test_must_fail grep "\"Type\":\"added\"" http_events
+'
+
+test_expect_success "the API rejects unknown event types" '
+	curl -s -o /dev/null -w "%{http_code}" "http://$API_ADDR/events?types=moved" > http_code &&
+	echo 400 > expected &&
+	test_cmp expected http_code
+'
+
+test_kill_ipfs_daemon
+
+test_done
@@ -0,0 +1,66 @@
+#!/bin/sh
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test the recovery of the repo after a daemon crash"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success "no recovery is reported on a new repo" '
+	ipfs repo recovery-report > report_out &&
+	echo "No unclean shutdown was recovered from." > report_exp &&
+	test_cmp report_exp report_out
+'
+
+test_launch_ipfs_daemon
+
+test_kill_ipfs_daemon
+
+test_expect_success "a clean shutdown needs no recovery" '
+	ipfs repo recovery-report > report_out &&
+	test_cmp report_exp report_out
+'
+
+test_launch_ipfs_daemon
+
+test_expect_success "crash the daemon" '
+	kill -9 $IPFS_PID &&
+	test -f "$IPFS_PATH/api"
+'
+
+test_expect_success "leave behind interrupted writes" '
+	mkdir -p "$IPFS_PATH/blocks/XY" &&
+	echo "partial" > "$IPFS_PATH/blocks/XY/put-123456" &&
+	echo "partial" > "$IPFS_PATH/keystore/.mykey.tmp"
+'
+
+test_launch_ipfs_daemon
+
+test_expect_success "the daemon reports the recovery" '
+	grep "Recovered from an unclean shutdown of the daemon" actual_daemon &&
+	grep "partial writes: 2 problems found, 2 fixed" actual_daemon
+'
+
+test_expect_success "the interrupted writes were removed" '
+	test ! -e "$IPFS_PATH/blocks/XY/put-123456" &&
+	test ! -e "$IPFS_PATH/keystore/.mykey.tmp"
+'
+
+test_expect_success "'ipfs repo recovery-report' shows the report" '
+	ipfs repo recovery-report > report_out &&
+	grep "from the unclean shutdown of the daemon" report_out &&
+	grep "the repo lock was released with the crashed daemon" report_out &&
+	grep "orphaned temporary key .*\.mykey\.tmp, fixed" report_out
+'
+
+test_kill_ipfs_daemon
+
+test_expect_success "the report is kept after a clean shutdown" '
+	ipfs repo recovery-report > report_out &&
+	grep "from the unclean shutdown of the daemon" report_out
+'
+
+test_done
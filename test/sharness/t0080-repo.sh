@@ -108,6 +108,17 @@ test_expect_success "'ipfs repo gc' removes file" '
 	grep "removed $PATCH_ROOT" actual7
 '
 
+test_expect_success "'ipfs repo gc --last-runs' shows the last run" '
+	REMOVED=$(grep -c "^removed" actual7) &&
+	ipfs repo gc --last-runs=1 >gc_runs &&
+	test $(wc -l < gc_runs) -eq 1 &&
+	grep "removed $REMOVED blocks" gc_runs
+'
+
+test_expect_success "'ipfs repo gc --last-runs' rejects non positive counts" '
+	test_expect_code 1 ipfs repo gc --last-runs=0
+'
+
 test_expect_success "'ipfs refs local' no longer shows file" '
 	EMPTY_DIR=QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn &&
 	ipfs refs local >actual8 &&
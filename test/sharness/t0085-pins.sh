@@ -97,6 +97,246 @@ test_pin_progress() {
 	'
 }
 
+test_pin_ls_cid_version() {
+	test_expect_success "create pins of both codecs" '
+		HASH_V0=$(echo "cid version" | ipfs add -q) &&
+		HASH_RAW=$(echo "raw cid" | ipfs add -q --raw-leaves)
+	'
+
+	test_expect_success "'ipfs pin ls --cid-version=1' writes version 1 CIDs" '
+		HASH_V1=$(ipfs pin ls --cid-version=1 -q $HASH_V0) &&
+		test "$HASH_V1" != "$HASH_V0" &&
+		ipfs pin ls --cid-version=1 -q > ls_v1 &&
+		grep $HASH_V1 ls_v1 &&
+		test_must_fail grep $HASH_V0 ls_v1
+	'
+
+	test_expect_success "'ipfs pin ls --cid-version=0' writes version 0 CIDs" '
+		ipfs pin ls --cid-version=0 -q > ls_v0 &&
+		grep $HASH_V0 ls_v0 &&
+		test_must_fail grep $HASH_V1 ls_v0
+	'
+
+	test_expect_success "raw CIDs stay in version 1" '
+		grep $HASH_RAW ls_v0
+	'
+
+	test_expect_success "'ipfs pin ls --codec' filters by codec" '
+		ipfs pin ls --codec=raw -q > ls_raw &&
+		grep $HASH_RAW ls_raw &&
+		test_must_fail grep $HASH_V0 ls_raw
+	'
+
+	test_expect_success "'ipfs pin ls --prefix' filters by prefix" '
+		ipfs pin ls --cid-version=1 --prefix=$(echo $HASH_V1 | cut -c1-20) -q > ls_prefix &&
+		echo $HASH_V1 > ls_prefix_exp &&
+		test_cmp ls_prefix_exp ls_prefix
+	'
+
+	test_expect_success "'ipfs pin ls' rejects unknown versions and codecs" '
+		test_must_fail ipfs pin ls --cid-version=2 &&
+		test_must_fail ipfs pin ls --codec=foo
+	'
+
+	test_expect_success "unpin the CID version test files" '
+		ipfs pin rm $HASH_V0 $HASH_RAW
+	'
+}
+
+# interrupts the pin of $RESUME_ROOT, which waits for its missing block, by
+# stopping the daemon
+test_interrupt_pin() {
+	test_launch_ipfs_daemon
+
+	test_expect_success "'ipfs pin add' waits for the missing block" '
+		ipfs pin add $RESUME_ROOT > /dev/null 2>&1 &
+		PIN_PID=$! &&
+		for i in $(test_seq 1 100)
+		do
+			ipfs pin ls --in-progress -q | grep -q $RESUME_ROOT && return
+			go-sleep 100ms
+		done &&
+		false
+	'
+
+	test_expect_success "'ipfs pin jobs ls' lists the pin" '
+		ipfs pin jobs ls > jobs_out &&
+		grep "$RESUME_ROOT recursive" jobs_out
+	'
+
+	test_kill_ipfs_daemon
+
+	test_expect_success "'ipfs pin add' stopped with the daemon" '
+		for i in $(test_seq 1 100)
+		do
+			! kill -0 $PIN_PID 2>/dev/null && return
+			go-sleep 100ms
+		done &&
+		false
+	'
+}
+
+# a pin interrupted by the shutdown of the daemon is resumed by the next one
+test_pin_resume() {
+	test_expect_success "create a dag missing a block" '
+		mkdir resume &&
+		echo "resume a" > resume/a &&
+		echo "resume b" > resume/b &&
+		RESUME_ROOT=$(ipfs add -r -q --pin=false resume | tail -n1) &&
+		RESUME_B=$(ipfs add -q --only-hash resume/b) &&
+		ipfs block get $RESUME_B > resume_b_block &&
+		ipfs block rm $RESUME_B
+	'
+
+	test_interrupt_pin
+
+	test_expect_success "the interrupted pin is listed in progress" '
+		echo "$RESUME_ROOT recursive" > in_progress_exp &&
+		ipfs pin ls --in-progress > in_progress &&
+		test_cmp in_progress_exp in_progress
+	'
+
+	test_expect_success "'ipfs pin ls --in-progress' takes no arguments" '
+		test_must_fail ipfs pin ls --in-progress $RESUME_ROOT
+	'
+
+	test_expect_success "'ipfs pin rm' drops an interrupted pin" '
+		ipfs pin rm $RESUME_ROOT &&
+		ipfs pin ls --in-progress > in_progress &&
+		test_must_be_empty in_progress
+	'
+
+	test_interrupt_pin
+
+	test_launch_ipfs_daemon
+
+	test_expect_success "the daemon resumes the pin" '
+		for i in $(test_seq 1 100)
+		do
+			ipfs pin ls --in-progress | grep -q "resumed" && break
+			go-sleep 100ms
+		done &&
+		echo "$RESUME_ROOT recursive resumed 1 times" > in_progress_exp &&
+		ipfs pin ls --in-progress > in_progress &&
+		test_cmp in_progress_exp in_progress
+	'
+
+	test_expect_success "the pin completes with the missing block" '
+		ipfs block put < resume_b_block &&
+		for i in $(test_seq 1 100)
+		do
+			ipfs pin ls --type=recursive $RESUME_ROOT && return
+			go-sleep 100ms
+		done &&
+		false
+	'
+
+	test_expect_success "the completed pin is no longer in progress" '
+		ipfs pin ls --in-progress > in_progress &&
+		test_must_be_empty in_progress &&
+		ipfs pin jobs ls > jobs_out &&
+		test_must_be_empty jobs_out
+	'
+
+	test_expect_success "'ipfs pin jobs cancel' cancels a pin" '
+		ipfs pin rm $RESUME_ROOT &&
+		ipfs block rm $RESUME_B &&
+		{ ipfs pin add $RESUME_ROOT > /dev/null 2>&1; echo $? > pin_status; } &
+		PIN_PID=$! &&
+		for i in $(test_seq 1 100)
+		do
+			JOB=$(ipfs pin jobs ls | grep $RESUME_ROOT | cut -d" " -f1) &&
+			test -n "$JOB" && break
+			go-sleep 100ms
+		done &&
+		echo "canceled the pin of $RESUME_ROOT" > cancel_exp &&
+		ipfs pin jobs cancel $JOB > cancel_out &&
+		test_cmp cancel_exp cancel_out &&
+		wait $PIN_PID &&
+		test $(cat pin_status) -ne 0
+	'
+
+	test_expect_success "the canceled pin is dropped" '
+		ipfs pin ls --in-progress > in_progress &&
+		test_must_be_empty in_progress &&
+		test_must_fail ipfs pin jobs cancel $JOB
+	'
+
+	test_kill_ipfs_daemon
+
+	test_expect_success "'ipfs pin jobs' needs the daemon" '
+		test_must_fail ipfs pin jobs ls
+	'
+}
+
+test_pin_follow() {
+	test_expect_success "create a directory in the files of the node" '
+		ipfs files mkdir /follow &&
+		echo "version 1" | ipfs files write --create /follow/file &&
+		FOLLOW_V1=$(ipfs files stat --hash /follow)
+	'
+
+	test_expect_success "'ipfs pin add --follow' pins the path" '
+		echo "pinned $FOLLOW_V1 recursively" >expected_follow &&
+		ipfs pin add --follow /follow >actual_follow &&
+		test_cmp expected_follow actual_follow &&
+		ipfs pin ls --type=recursive $FOLLOW_V1
+	'
+
+	test_expect_success "'ipfs pin follow ls' lists the path" '
+		echo "/follow $FOLLOW_V1 recursive" >expected_follow &&
+		ipfs pin follow ls >actual_follow &&
+		test_cmp expected_follow actual_follow
+	'
+
+	test_expect_success "/ipfs/ paths can't be followed" '
+		test_must_fail ipfs pin add --follow /ipfs/$FOLLOW_V1 2>follow_err &&
+		grep "never changes" follow_err
+	'
+
+	test_expect_success "--keep-old needs --follow" '
+		test_must_fail ipfs pin add --keep-old $FOLLOW_V1
+	'
+
+	test_expect_success "set a short follow interval" '
+		ipfs config Pinning.FollowInterval 1s
+	'
+
+	test_launch_ipfs_daemon --offline
+
+	test_expect_success "change the followed directory" '
+		echo "version 2" | ipfs files write --truncate /follow/file &&
+		FOLLOW_V2=$(ipfs files stat --hash /follow)
+	'
+
+	test_expect_success "the daemon pins the new version" '
+		for i in $(test_seq 1 20); do
+			ipfs pin follow ls | grep -q $FOLLOW_V2 && break
+			go-sleep 500ms
+		done &&
+		ipfs pin ls --type=recursive $FOLLOW_V2
+	'
+
+	test_expect_success "the old version is unpinned" '
+		test_must_fail ipfs pin ls --type=recursive $FOLLOW_V1
+	'
+
+	test_expect_success "'ipfs pin follow rm' stops following the path" '
+		echo "stopped following /follow" >expected_follow &&
+		ipfs pin follow rm /follow >actual_follow &&
+		test_cmp expected_follow actual_follow &&
+		test_must_fail ipfs pin ls --type=recursive $FOLLOW_V2 &&
+		ipfs pin follow ls >actual_follow &&
+		test_must_be_empty actual_follow
+	'
+
+	test_expect_success "'ipfs pin follow rm' fails for paths not followed" '
+		test_must_fail ipfs pin follow rm /follow
+	'
+
+	test_kill_ipfs_daemon
+}
+
 test_init_ipfs
 
 test_pins
@@ -110,6 +350,8 @@ test_pin_dag --raw-leaves
 
 test_pin_progress
 
+test_pin_ls_cid_version
+
 test_launch_ipfs_daemon --offline
 
 test_pins
@@ -123,6 +365,12 @@ test_pin_dag --raw-leaves
 
 test_pin_progress
 
+test_pin_ls_cid_version
+
 test_kill_ipfs_daemon
 
+test_pin_resume
+
+test_pin_follow
+
 test_done
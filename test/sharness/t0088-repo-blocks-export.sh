@@ -0,0 +1,64 @@
+#!/bin/sh
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test ipfs repo blocks export"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success "export needs the journal" '
+	test_must_fail ipfs repo blocks export 2> export_err &&
+	grep "Datastore.Journal.Enabled" export_err
+'
+
+test_expect_success "enable the journal" '
+	ipfs config --json Datastore.Journal.Enabled true
+'
+
+test_expect_success "add a file" '
+	HASH1=$(echo "first backup" | ipfs add -q)
+'
+
+test_expect_success "export the blocks" '
+	ipfs repo blocks export -o full > export_out &&
+	test -f full/blocks.car &&
+	test -f full/manifest.json &&
+	grep "$HASH1" full/manifest.json &&
+	CHECKPOINT=$(sed -n "s/.*checkpoint \([0-9]*\)\./\1/p" export_out) &&
+	test -n "$CHECKPOINT"
+'
+
+test_expect_success "the car holds the blocks" '
+	grep -a "first backup" full/blocks.car
+'
+
+test_expect_success "add another file" '
+	HASH2=$(echo "second backup" | ipfs add -q)
+'
+
+test_expect_success "an incremental export only has the new blocks" '
+	ipfs repo blocks export --since=$CHECKPOINT -o incr &&
+	grep "$HASH2" incr/manifest.json &&
+	test_must_fail grep "$HASH1" incr/manifest.json &&
+	grep -a "second backup" incr/blocks.car
+'
+
+test_expect_success "the default directory is named after the checkpoints" '
+	ipfs repo blocks export --since=$CHECKPOINT &&
+	test -f blocks-$CHECKPOINT-*/manifest.json
+'
+
+test_expect_success "exports filter by prefix" '
+	ipfs repo blocks export --prefix=zzz -o none &&
+	test_must_fail grep "$HASH1" none/manifest.json
+'
+
+test_expect_success "invalid times are rejected" '
+	test_must_fail ipfs repo blocks export --added-after=yesterday 2> after_err &&
+	grep "invalid --added-after" after_err
+'
+
+test_done
@@ -0,0 +1,56 @@
+#!/bin/sh
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test ipfs repo journal"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success "the journal needs to be enabled" '
+	test_must_fail ipfs repo journal 2> journal_err &&
+	grep "Datastore.Journal.Enabled" journal_err
+'
+
+test_expect_success "enable the journal" '
+	ipfs config --json Datastore.Journal.Enabled true
+'
+
+test_expect_success "add a file" '
+	HASH=$(echo "journaled" | ipfs add -q)
+'
+
+test_expect_success "the addition is journaled" '
+	ipfs repo journal --op=put --prefix=$HASH > journal_out &&
+	test_line_count = 1 journal_out &&
+	grep "put	local	$HASH	18" journal_out
+'
+
+test_expect_success "unpin the file and gc" '
+	ipfs pin rm $HASH &&
+	ipfs repo gc
+'
+
+test_expect_success "the removal is journaled" '
+	ipfs repo journal --op=delete --source=gc > journal_out &&
+	grep "delete	gc	$HASH" journal_out
+'
+
+test_expect_success "--limit shows the last entries" '
+	ipfs repo journal --limit=1 > journal_out &&
+	test_line_count = 1 journal_out
+'
+
+test_expect_success "--since skips the first entries" '
+	LAST=$(ipfs repo journal --limit=1 | cut -f1) &&
+	ipfs repo journal --since=$LAST > journal_out &&
+	test_line_count = 0 journal_out
+'
+
+test_expect_success "unknown operations are rejected" '
+	test_must_fail ipfs repo journal --op=move
+'
+
+test_done
@@ -125,6 +125,21 @@ test_get_cmd() {
 		test_must_fail ipfs get ../.. 2>actual &&
 		test_cmp expected actual
 	'
+
+	test_expect_success "ipfs get --sparse round-trips a sparse file" '
+		rm -f sparse sparse_out &&
+		echo "start" > sparse &&
+		dd if=/dev/zero of=sparse bs=1024 count=1 seek=4096 2>/dev/null &&
+		echo "end" >> sparse &&
+		SPARSE_HASH=$(ipfs add -q sparse) &&
+		ipfs get --sparse -o sparse_out "$SPARSE_HASH" &&
+		test_cmp sparse sparse_out
+	'
+
+	test_expect_success "ipfs get --sparse refuses archives" '
+		test_must_fail ipfs get --sparse -a "$SPARSE_HASH" 2>actual &&
+		grep -- "--sparse doesn.t apply to archives" actual
+	'
 }
 
 test_get_fail() {
@@ -90,4 +90,104 @@ test_expect_success "publish an explicit node ID as key name looks good" '
 	test_cmp expected_node_id_publish actual_node_id_publish
 '
 
+# publish with a lifetime and a ttl
+
+test_expect_success "'ipfs name publish --lifetime --ttl' gives the EOL and TTL" '
+	ipfs name publish --lifetime=2h --ttl=5m --enc=json "/ipfs/$HASH_WELCOME_DOCS" >publish_out &&
+	grep "\"TTL\":\"5m0s\"" publish_out &&
+	grep "\"EOL\":\"20" publish_out
+'
+
+test_expect_success "the record carries the lifetime" '
+	ipfs key info self | grep "^published:  /ipfs/$HASH_WELCOME_DOCS, sequence .*, expires "
+'
+
+test_expect_success "'ipfs name publish' refuses invalid lifetimes and ttls" '
+	test_must_fail ipfs name publish --lifetime=-1h "/ipfs/$HASH_WELCOME_DOCS" &&
+	test_must_fail ipfs name publish --ttl=-1m "/ipfs/$HASH_WELCOME_DOCS" &&
+	test_must_fail ipfs name publish --ttl=soon "/ipfs/$HASH_WELCOME_DOCS"
+'
+
+test_expect_success "'ipfs name publish' refuses an invalid Ipns.RecordLifetime" '
+	ipfs config Ipns.RecordLifetime 10m &&
+	test_must_fail ipfs name publish "/ipfs/$HASH_WELCOME_DOCS" 2>publish_err &&
+	grep "Ipns.RecordLifetime is not between" publish_err &&
+	ipfs config Ipns.RecordLifetime 168h &&
+	ipfs name publish "/ipfs/$HASH_WELCOME_DOCS" &&
+	ipfs config Ipns.RecordLifetime ""
+'
+
+# publish offline
+
+test_expect_success "'ipfs name publish --allow-offline' stores the record to send it later" '
+	ipfs name publish --allow-offline --enc=json "/ipfs/$HASH_WELCOME_DOCS" >publish_out &&
+	grep "\"Pending\":true" publish_out &&
+	ipfs name resolve >resolve_out &&
+	echo "/ipfs/$HASH_WELCOME_DOCS" >expected_resolve &&
+	test_cmp expected_resolve resolve_out
+'
+
+test_expect_success "'ipfs name publish --allow-offline' says the record is pending" '
+	ipfs name publish --allow-offline "/ipfs/$HASH_WELCOME_DOCS" 2>publish_err &&
+	grep "will be sent once it is online" publish_err
+'
+
+# list the records
+
+test_expect_success "'ipfs name list' shows the records of the keys" '
+	ipfs name list >list_out &&
+	grep "^self  *${PEERID}  */ipfs/$HASH_WELCOME_DOCS " list_out &&
+	grep "^keyname  *${NEWID}  */ipfs/$HASH_WELCOME_DOCS " list_out
+'
+
+test_expect_success "'ipfs name list' leaves out the keys without records" '
+	ipfs key gen --type=ed25519 unpublished >/dev/null &&
+	ipfs name list >list_out &&
+	test_must_fail grep "^unpublished " list_out
+'
+
+test_expect_success "'ipfs name list --key' filters by name or peer ID" '
+	ipfs name list --key=keyname >list_key &&
+	test_must_fail grep "^self " list_key &&
+	grep "^keyname " list_key &&
+	ipfs name list --key=${NEWID} --enc=json >list_id &&
+	grep "\"Key\":\"keyname\"" list_id &&
+	test_must_fail ipfs name list --key=nosuchkey
+'
+
+test_expect_success "'ipfs name list' flags the records published offline" '
+	grep "(pending)" list_out
+'
+
+# watch a name
+
+test_expect_success "'ipfs name resolve --watch' refuses invalid options" '
+	test_must_fail ipfs name resolve --watch --stream &&
+	test_must_fail ipfs name resolve --watch --watch-interval=0s &&
+	test_must_fail ipfs name resolve --watch --watch-interval=often
+'
+
+test_expect_success "'ipfs name resolve --watch' fails for an unknown name" '
+	test_must_fail ipfs name resolve --watch QmaCpDMGvV2BGHeYERUEnRQAwe3N8SzbUtfsmvsqQLuvuJ
+'
+
+# DHT resolution options
+
+test_expect_success "'ipfs name resolve' refuses invalid DHT timeouts" '
+	test_must_fail ipfs name resolve --dht-timeout=soon QmaCpDMGvV2BGHeYERUEnRQAwe3N8SzbUtfsmvsqQLuvuJ &&
+	test_must_fail ipfs name resolve --dht-timeout=-1s QmaCpDMGvV2BGHeYERUEnRQAwe3N8SzbUtfsmvsqQLuvuJ
+'
+
+# IPNS over pubsub
+
+test_expect_success "'ipfs name pubsub state' shows it is disabled" '
+	echo disabled >expected_state &&
+	ipfs name pubsub state >actual_state &&
+	test_cmp expected_state actual_state
+'
+
+test_expect_success "'ipfs name pubsub subs' fails when it is disabled" '
+	test_must_fail ipfs name pubsub subs
+'
+
 test_done
@@ -56,6 +56,29 @@ test_expect_success "GET IPFS non existent file returns code expected (404)" '
   test_curl_resp_http_code "http://127.0.0.1:$port/ipfs/$HASH2/pleaseDontAddMe" "HTTP/1.1 404 Not Found"
 '
 
+test_expect_success "GET IPFS directory as tar succeeds" '
+  curl -sfo dir.tar "http://127.0.0.1:$port/ipfs/$HASH2?format=tar" &&
+  mkdir untar &&
+  tar -xf dir.tar -C untar
+'
+
+test_expect_success "GET IPFS directory as tar output looks good" '
+  test_cmp dir/test "untar/$HASH2/test"
+'
+
+test_expect_success "GET IPFS directory as zip succeeds" '
+  curl -sfo dir.zip "http://127.0.0.1:$port/ipfs/$HASH2?format=zip" &&
+  unzip -p dir.zip "$HASH2/test" >actual
+'
+
+test_expect_success "GET IPFS directory as zip output looks good" '
+  test_cmp dir/test actual
+'
+
+test_expect_success "GET IPFS file as archive returns code expected (400)" '
+  test_curl_resp_http_code "http://127.0.0.1:$port/ipfs/$HASH2/test?format=zip" "HTTP/1.1 400 Bad Request"
+'
+
 test_expect_failure "GET IPNS path succeeds" '
   ipfs name publish "$HASH" &&
   PEERID=$(ipfs config Identity.PeerID) &&
@@ -157,4 +180,36 @@ test_expect_success "try fetching it from gateway" '
 
 test_kill_ipfs_daemon
 
+test_expect_success "set limits on the requests of the gateway" '
+  mkdir -p deep/a/b/c wide &&
+  echo "bottom" >deep/a/b/c/file &&
+  for i in 1 2 3 4 5; do echo "$i" >wide/file$i; done &&
+  DEEP=$(ipfs add -r -q deep | tail -n 1) &&
+  WIDE=$(ipfs add -r -q wide | tail -n 1) &&
+  ipfs config --json Gateway.MaxPathSegments 3 &&
+  ipfs config --json Gateway.MaxLinkDepth 3 &&
+  ipfs config --json Gateway.MaxDirectoryEntries 2
+'
+
+test_launch_ipfs_daemon
+
+test_expect_success "GET paths within the limits succeeds" '
+  curl -sf "http://127.0.0.1:$port/ipfs/$DEEP/a/b/c" >/dev/null
+'
+
+test_expect_success "GET paths over the limits returns code expected (400)" '
+  test_curl_resp_http_code "http://127.0.0.1:$port/ipfs/$DEEP/a/b/c/file" "HTTP/1.1 400 Bad Request" &&
+  test_curl_resp_http_code "http://127.0.0.1:$port/ipfs/$DEEP/a/b/c/x/y/z" "HTTP/1.1 400 Bad Request"
+'
+
+test_expect_success "GET directories lists at most Gateway.MaxDirectoryEntries" '
+  curl -sf "http://127.0.0.1:$port/ipfs/$WIDE" >listing &&
+  grep "file1" listing &&
+  grep "file2" listing &&
+  test_must_fail grep "file3" listing &&
+  grep "More entries are not listed." listing
+'
+
+test_kill_ipfs_daemon
+
 test_done
@@ -0,0 +1,51 @@
+#!/bin/sh
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test fetching the blocks from given peers"
+
+. lib/test-lib.sh
+
+test_expect_success "set up tcp testbed" '
+	iptb init -n 3 -p 0 -f --bootstrap=none
+'
+
+startup_cluster 3
+
+test_expect_success "add a file on node 1" '
+	random 100000 41 >fromfile &&
+	FROM_HASH=$(ipfsi 1 add -q fromfile) &&
+	PEER1=$(iptb get id 1) &&
+	PEER2=$(iptb get id 2)
+'
+
+test_expect_success "'ipfs cat --from' a peer without the file times out" '
+	test_expect_code 1 ipfsi 0 cat --timeout=2s --from=$PEER2 $FROM_HASH >/dev/null
+'
+
+test_expect_success "'ipfs cat --prefer-from' falls back to the other peers" '
+	ipfsi 0 cat --prefer-from --from=$PEER2 $FROM_HASH >from_out &&
+	test_cmp fromfile from_out
+'
+
+test_expect_success "'ipfs get --from' the address of the peer with the file" '
+	ADDR1=$(ipfsi 1 id -f="<addrs>" | grep "/ip4/127.0.0.1/tcp" | head -n1 | sed "s|/ipfs/.*||") &&
+	ipfsi 2 get --from="$ADDR1/ipfs/$PEER1" -o from_get $FROM_HASH &&
+	test_cmp fromfile from_get
+'
+
+test_expect_success "'ipfs cat' rejects invalid peers" '
+	test_must_fail ipfsi 0 cat --from=notapeer $FROM_HASH 2>from_err &&
+	grep "invalid peer ID" from_err
+'
+
+test_expect_success "--prefer-from needs --from" '
+	test_must_fail ipfsi 0 cat --prefer-from $FROM_HASH
+'
+
+test_expect_success "shut down nodes" '
+	iptb stop
+'
+
+test_done
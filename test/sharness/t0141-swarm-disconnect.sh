@@ -0,0 +1,61 @@
+#!/bin/sh
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test swarm disconnect by address, peer ID and subnet"
+
+. lib/test-lib.sh
+
+test_expect_success "set up tcp testbed" '
+	iptb init -n 3 -p 0 -f --bootstrap=none
+'
+
+startup_cluster 3
+
+test_expect_success "get the peer ids" '
+	PEERID_1=$(iptb get id 1) &&
+	PEERID_2=$(iptb get id 2)
+'
+
+test_expect_success "disconnect by peer id" '
+	ipfsi 0 swarm disconnect $PEERID_1 > disc_peer &&
+	grep "disconnect $PEERID_1 success (1 connections)" disc_peer &&
+	ipfsi 0 swarm peers > peers &&
+	test_expect_code 1 grep $PEERID_1 peers
+'
+
+test_expect_success "disconnect from a peer not connected fails" '
+	ipfsi 0 swarm disconnect $PEERID_1 > disc_again &&
+	grep "disconnect $PEERID_1 failure: conn not found" disc_again
+'
+
+test_expect_success "disconnect with a ban" '
+	iptb connect 0 1 &&
+	ipfsi 0 swarm disconnect --ban=1h $PEERID_1 > disc_ban &&
+	grep "disconnect $PEERID_1 success (1 connections), banned until" disc_ban
+'
+
+test_expect_success "banned peer cannot reconnect" '
+	test_must_fail iptb connect 0 1 ||
+	(ipfsi 0 swarm peers > peers_ban && test_expect_code 1 grep $PEERID_1 peers_ban)
+'
+
+test_expect_success "disconnect by subnet" '
+	ipfsi 0 swarm disconnect 127.0.0.0/8 > disc_net &&
+	grep "disconnect 127.0.0.0/8 success (1 connections)" disc_net &&
+	ipfsi 0 swarm peers > peers_net &&
+	test_expect_code 1 grep $PEERID_2 peers_net
+'
+
+test_expect_success "invalid targets are refused" '
+	test_must_fail ipfsi 0 swarm disconnect 127.0.0.0/99 &&
+	test_must_fail ipfsi 0 swarm disconnect notapeer &&
+	test_must_fail ipfsi 0 swarm disconnect --ban=-1m $PEERID_2
+'
+
+test_expect_success "shut down nodes" '
+	iptb stop
+'
+
+test_done
@@ -0,0 +1,51 @@
+#!/bin/sh
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test the stream and bandwidth limits of protocols"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success "stats protocols needs the daemon" '
+	test_must_fail ipfs stats protocols
+'
+
+test_expect_success "set up tcp testbed" '
+	iptb init -n 2 -p 0 -f --bootstrap=none
+'
+
+test_expect_success "limit the protocols of node 0" '
+	ipfsi 0 config --json Swarm.ProtocolLimits "[{\"Protocol\": \"dht\", \"MaxStreams\": 8, \"RateOut\": \"1Mbps\"}, {\"Protocol\": \"bitswap\", \"RateIn\": \"10MB/s\"}]"
+'
+
+startup_cluster 2
+
+test_expect_success "node 0 fetches a file from node 1" '
+	random 100000 13 >afile &&
+	HASH=$(ipfsi 1 add -q afile) &&
+	ipfsi 0 cat $HASH >fetched &&
+	test_cmp afile fetched
+'
+
+test_expect_success "stats protocols shows the use of the limits" '
+	ipfsi 0 stats protocols >stats_out &&
+	grep "^dht  *[0-9]*/8 " stats_out &&
+	grep "^bitswap " stats_out &&
+	ipfsi 0 stats protocols --enc=json >stats_json &&
+	grep "\"Protocol\":\"bitswap\"" stats_json &&
+	grep "\"LimitOut\":125000" stats_json
+'
+
+test_expect_success "stats protocols says when nothing is limited" '
+	ipfsi 1 stats protocols >stats_none &&
+	grep "no protocol is limited" stats_none
+'
+
+test_expect_success "stop the testbed" '
+	iptb stop
+'
+
+test_done
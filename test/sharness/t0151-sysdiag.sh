@@ -45,4 +45,29 @@ test_expect_success "output is similar to uname" '
 	esac
 '
 
+test_expect_success "ipfs diag protocols fails offline" '
+	test_expect_code 1 ipfs diag protocols
+'
+
+test_expect_success "set a heap ballast" '
+	ipfs config Memory.Ballast 16MB
+'
+
+test_launch_ipfs_daemon
+
+test_expect_success "ipfs diag protocols succeeds" '
+	ipfs diag protocols --enc=json > protocols_out &&
+	grep "\"Peers\"" protocols_out &&
+	grep "\"Samples\"" protocols_out
+'
+
+test_expect_success "ipfs diag mem succeeds" '
+	ipfs diag mem --gc > mem_out &&
+	grep "Heap allocated:" mem_out &&
+	grep "SUBSYSTEM" mem_out &&
+	grep "Ballast:        16 MB" mem_out
+'
+
+test_kill_ipfs_daemon
+
 test_done
@@ -0,0 +1,67 @@
+#!/bin/sh
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test the encryption of the keystore"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success "create a key in cleartext" '
+	OLD_ID=$(ipfs key gen old --type=ed25519)
+'
+
+test_expect_success "ipfs key encrypt needs a passphrase" '
+	test_must_fail ipfs key encrypt 2>&1 < /dev/null | tee encrypt_out &&
+	grep -q "IPFS_KEYSTORE_PASSPHRASE" encrypt_out
+'
+
+test_expect_success "ipfs key encrypt encrypts the existing keys" '
+	IPFS_KEYSTORE_PASSPHRASE=secret ipfs key encrypt > encrypt_out &&
+	echo "encrypted $OLD_ID old" > encrypt_exp &&
+	test_cmp encrypt_exp encrypt_out &&
+	grep -q "ipfs-encrypted-key" "$IPFS_PATH/keystore/old" &&
+	ipfs config Keystore.Encryption.KDF > kdf_out &&
+	echo scrypt > kdf_exp &&
+	test_cmp kdf_exp kdf_out
+'
+
+test_expect_success "ipfs key encrypt refuses an encrypted keystore" '
+	test_must_fail env IPFS_KEYSTORE_PASSPHRASE=secret ipfs key encrypt 2>&1 | tee encrypt_out &&
+	grep -q "already encrypted" encrypt_out
+'
+
+test_expect_success "new keys are encrypted" '
+	NEW_ID=$(IPFS_KEYSTORE_PASSPHRASE=secret ipfs key gen new --type=ed25519) &&
+	grep -q "ipfs-encrypted-key" "$IPFS_PATH/keystore/new"
+'
+
+test_expect_success "the keys are read with the passphrase" '
+	IPFS_KEYSTORE_PASSPHRASE=secret ipfs key list -l > list_out &&
+	grep -q "$OLD_ID old" list_out &&
+	grep -q "$NEW_ID new" list_out
+'
+
+test_expect_success "the keys are not read with a wrong passphrase" '
+	test_must_fail env IPFS_KEYSTORE_PASSPHRASE=wrong ipfs key list -l 2>&1 | tee list_out &&
+	grep -q "wrong keystore passphrase" list_out
+'
+
+test_expect_success "the daemon doesn't start without the passphrase" '
+	test_must_fail ipfs daemon < /dev/null 2>&1 | tee daemon_out &&
+	grep -q "cannot unlock the keystore" daemon_out
+'
+
+export IPFS_KEYSTORE_PASSPHRASE=secret
+test_launch_ipfs_daemon
+
+test_expect_success "the daemon reads the keys" '
+	ipfs key list -l > list_out &&
+	grep -q "$NEW_ID new" list_out
+'
+
+test_kill_ipfs_daemon
+
+test_done
@@ -0,0 +1,83 @@
+#!/bin/sh
+
+test_description="Test IPNS over pubsub"
+
+. lib/test-lib.sh
+
+NUM_NODES=3
+test_expect_success 'init iptb' '
+  iptb init -n $NUM_NODES --bootstrap=none --port=0
+'
+
+startup_cluster $NUM_NODES --enable-namesys-pubsub
+
+test_expect_success 'peer ids' '
+  PEERID_0=$(iptb get id 0)
+'
+
+test_expect_success 'add the contents' '
+  HASH_A=$(echo "first" | ipfsi 0 add -q) &&
+  HASH_B=$(echo "second" | ipfsi 0 add -q)
+'
+
+test_expect_success 'publish a first record' '
+  ipfsi 0 name publish $HASH_A
+'
+
+test_expect_success 'the first resolve goes through the DHT' '
+  echo /ipfs/$HASH_A > expected_a &&
+  ipfsi 1 name resolve $PEERID_0 > actual_a &&
+  test_cmp expected_a actual_a
+'
+
+test_expect_success 'the resolver subscribed to the topic of the name' '
+  sleep 1 &&
+  ipfsi 1 pubsub ls > topics &&
+  grep "/ipns/$PEERID_0" topics
+'
+
+test_expect_success "'name pubsub state' shows it is enabled" '
+  echo enabled > expected_state &&
+  ipfsi 1 name pubsub state > actual_state &&
+  test_cmp expected_state actual_state
+'
+
+test_expect_success "'name pubsub subs' lists the name resolved" '
+  echo /ipns/$PEERID_0 > expected_subs &&
+  ipfsi 1 name pubsub subs > actual_subs &&
+  test_cmp expected_subs actual_subs
+'
+
+test_expect_success 'publish a new record' '
+  ipfsi 0 name publish $HASH_B
+'
+
+test_expect_success 'the new record is resolved without waiting for the cache' '
+  sleep 1 &&
+  echo /ipfs/$HASH_B > expected_b &&
+  ipfsi 1 name resolve $PEERID_0 > actual_b &&
+  test_cmp expected_b actual_b
+'
+
+test_expect_success "'name pubsub cancel' drops the subscription" '
+  ipfsi 1 name pubsub cancel /ipns/$PEERID_0 > cancel_out &&
+  echo canceled > expected_cancel &&
+  test_cmp expected_cancel cancel_out &&
+  ipfsi 1 name pubsub subs > subs_after &&
+  test_must_be_empty subs_after &&
+  ipfsi 1 name pubsub cancel $PEERID_0 --enc=json > cancel_json &&
+  grep "\"Canceled\":false" cancel_json
+'
+
+test_expect_success "the name subscribes again when resolved" '
+  ipfsi 1 name resolve $PEERID_0 > actual_c &&
+  test_cmp expected_b actual_c &&
+  ipfsi 1 name pubsub subs > actual_subs &&
+  test_cmp expected_subs actual_subs
+'
+
+test_expect_success 'stop iptb' '
+  iptb stop
+'
+
+test_done
@@ -19,6 +19,7 @@ test_expect_success "'ipfs bitswap stat' output looks good" '
 	cat >expected <<EOF &&
 bitswap status
 	provides buffer: 0 / 256
+	provides pending: 0
 	blocks received: 0
 	blocks sent: 0
 	data received: 0
@@ -57,6 +58,7 @@ test_expect_success "'ipfs bitswap stat' output looks good" '
 	cat >expected <<EOF &&
 bitswap status
 	provides buffer: 0 / 256
+	provides pending: 0
 	blocks received: 0
 	blocks sent: 0
 	data received: 0
@@ -77,6 +79,27 @@ test_expect_success "'ipfs bitswap wantlist -p' output looks good" '
 	test_cmp wantlist_out wantlist_p_out
 '
 
+test_expect_success "'ipfs stats want' lists nothing when no block is wanted" '
+	ipfs stats want --older-than=0s >want_out &&
+	test_must_fail grep -v "^CID" want_out
+'
+
+test_expect_success "fetch a block nobody has" '
+	WANTED=$(echo "nobody has this block" | ipfs add -q -n) &&
+	(go-timeout 5 ipfs block get $WANTED >/dev/null 2>&1 &) &&
+	go-sleep 500ms
+'
+
+test_expect_success "'ipfs stats want' lists the wanted block" '
+	ipfs stats want --older-than=0s >want_out &&
+	grep "^$WANTED .* block/get #[0-9]*$" want_out
+'
+
+test_expect_success "'ipfs stats want --older-than' filters recent wants" '
+	ipfs stats want --older-than=1h >want_out &&
+	test_must_fail grep "$WANTED" want_out
+'
+
 test_kill_ipfs_daemon
 
 test_done
@@ -0,0 +1,47 @@
+#!/bin/sh
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test warming the cache of a node with another node's dags"
+
+. lib/test-lib.sh
+
+test_expect_success "set up tcp testbed" '
+	iptb init -n 2 -p 0 -f --bootstrap=none
+'
+
+startup_cluster 2
+
+test_expect_success "add a dir on node 0" '
+	random-files -depth=2 -dirs=2 -files=3 -seed=7 warmdir > /dev/null &&
+	DIR_HASH=$(ipfsi 0 add -r -q warmdir | tail -n1) &&
+	ipfsi 0 refs -r -u $DIR_HASH | wc -l | tr -d " " > refs_count
+'
+
+test_expect_success "warming the root only fetches one block" '
+	ipfsi 1 cache warm --depth=0 $DIR_HASH > warm_root &&
+	grep "warmed $DIR_HASH: 1 blocks, 0 already local, 1 fetched" warm_root
+'
+
+test_expect_success "warming the dir fetches the rest" '
+	ipfsi 1 cache warm --progress $DIR_HASH > warm_all &&
+	echo "warmed $DIR_HASH: $(($(cat refs_count) + 1)) blocks, 1 already local, $(cat refs_count) fetched" > expected &&
+	cut -d"(" -f1 warm_all | sed "s/ $//" > warm_all_trimmed &&
+	test_cmp expected warm_all_trimmed
+'
+
+test_expect_success "the warmed blocks are not pinned" '
+	test_must_fail ipfsi 1 pin ls $DIR_HASH
+'
+
+test_expect_success "warming again fetches nothing" '
+	ipfsi 1 cache warm $DIR_HASH > warm_again &&
+	grep "0 fetched" warm_again
+'
+
+test_expect_success "shut down nodes" '
+	iptb stop
+'
+
+test_done
@@ -0,0 +1,57 @@
+#!/bin/sh
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test ping over a given transport and peer health checks"
+
+. lib/test-lib.sh
+
+test_expect_success "set up tcp testbed" '
+	iptb init -n 2 -p 0 -f --bootstrap=none
+'
+
+startup_cluster 2
+
+test_expect_success "get the peer id of node 1" '
+	PEERID_1=$(iptb get id 1)
+'
+
+test_expect_success "ping over tcp" '
+	ipfsi 0 ping -n 2 --transport=tcp $PEERID_1 > ping_tcp &&
+	grep "Connected to $PEERID_1 over /ip4/.*/tcp/" ping_tcp &&
+	grep "Pong received" ping_tcp
+'
+
+test_expect_success "ping over a transport the peer lacks fails" '
+	ipfsi 0 ping -n 1 --transport=utp $PEERID_1 > ping_utp &&
+	grep "Connect error" ping_utp
+'
+
+test_expect_success "ping with an unknown transport fails" '
+	test_must_fail ipfsi 0 ping -n 1 --transport=nope $PEERID_1
+'
+
+test_expect_success "peer health is reported" '
+	ipfsi 0 ping --health $PEERID_1 > health &&
+	grep "bitswap: ok" health &&
+	grep "dht: ok" health
+'
+
+test_expect_success "disable health checks on node 1" '
+	iptb stop 1 &&
+	ipfsi 1 config --json Swarm.DisableHealthCheck true &&
+	iptb start 1 &&
+	iptb connect 0 1
+'
+
+test_expect_success "peer health is not reported" '
+	ipfsi 0 ping --health $PEERID_1 > no_health &&
+	grep "Health check error" no_health
+'
+
+test_expect_success "shut down nodes" '
+	iptb stop
+'
+
+test_done
@@ -0,0 +1,44 @@
+#!/bin/sh
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test probing the availability of content on the network"
+
+. lib/test-lib.sh
+
+test_expect_success "set up tcp testbed" '
+	iptb init -n 3 -p 0 -f --bootstrap=none
+'
+
+startup_cluster 3
+
+test_expect_success "add a dir on node 0" '
+	random-files -depth=2 -dirs=2 -files=3 -seed=7 availdir > /dev/null &&
+	DIR_HASH=$(ipfsi 0 add -r -q availdir | tail -n1)
+'
+
+test_expect_success "content nobody else has scores 0" '
+	ipfsi 0 availability --timeout=2s $DIR_HASH > avail_none &&
+	grep "^score: 0.00$" avail_none &&
+	grep "^providers: 0 responsive of 0 found$" avail_none
+'
+
+test_expect_success "node 1 fetches and provides the dir" '
+	ipfsi 1 pin add $DIR_HASH &&
+	ipfsi 1 dht provide -r $DIR_HASH > /dev/null
+'
+
+test_expect_success "node 1 is found and sends the sampled blocks" '
+	ipfsi 0 availability --depth=2 --samples=2 $DIR_HASH > avail_one &&
+	grep "^providers: 1 responsive of 1 found$" avail_one &&
+	PEERID_1=$(iptb get id 1) &&
+	grep "$PEERID_1 *\([0-9]*\)/\1 blocks" avail_one &&
+	grep "^score: 0.33$" avail_one
+'
+
+test_expect_success "shut down nodes" '
+	iptb stop
+'
+
+test_done
@@ -0,0 +1,83 @@
+#!/bin/sh
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test pushing dags to another node"
+
+. lib/test-lib.sh
+
+test_expect_success "set up tcp testbed" '
+	iptb init -n 2 -p 0 -f --bootstrap=none
+'
+
+startup_cluster 2
+
+test_expect_success "add a dir on node 0" '
+	random-files -depth=2 -dirs=2 -files=3 -seed=11 repldir > /dev/null &&
+	DIR_HASH=$(ipfsi 0 add -r -q repldir | tail -n1) &&
+	ipfsi 0 refs -r -u $DIR_HASH | wc -l | tr -d " " > refs_count &&
+	API_1=$(cat "$IPTB_ROOT/1/api")
+'
+
+test_expect_success "replicate needs a target" '
+	test_must_fail ipfsi 0 replicate $DIR_HASH 2>&1 | tee replicate_err &&
+	grep -q "give the API of the target with --to" replicate_err
+'
+
+test_expect_success "replicate pushes the dir to node 1 and pins it" '
+	ipfsi 0 replicate --to=$API_1 --pin $DIR_HASH > replicate_out &&
+	echo "replicated $DIR_HASH: $(($(cat refs_count) + 1)) blocks, 0 already present, $(($(cat refs_count) + 1)) pushed" > expected &&
+	cut -d"(" -f1 replicate_out | head -n1 | sed "s/ $//" > replicate_trimmed &&
+	test_cmp expected replicate_trimmed &&
+	grep "^pinned $DIR_HASH on the target$" replicate_out
+'
+
+test_expect_success "node 1 has the blocks and the pin" '
+	ipfsi 1 refs -r --offline $DIR_HASH | wc -l | tr -d " " > refs_count_1 &&
+	test_cmp refs_count refs_count_1 &&
+	ipfsi 1 pin ls --type=recursive $DIR_HASH
+'
+
+test_expect_success "replicating again pushes nothing" '
+	ipfsi 0 replicate --to=$API_1 $DIR_HASH > replicate_again &&
+	grep "0 pushed" replicate_again
+'
+
+test_expect_success "add another dir on node 0" '
+	random-files -depth=2 -dirs=2 -files=3 -seed=12 pushdir > /dev/null &&
+	PUSH_HASH=$(ipfsi 0 add -r -q pushdir | tail -n1) &&
+	PEERID_0=$(iptb get id 0) &&
+	PEERID_1=$(iptb get id 1)
+'
+
+test_expect_success "node 1 doesn't take blocks pushed by default" '
+	test_must_fail ipfsi 0 replicate --peer=$PEERID_1 $PUSH_HASH
+'
+
+test_expect_success "allow node 0 to push blocks to node 1" '
+	iptb stop 1 &&
+	ipfsi 1 config --json Swarm.BlockPush.AllowedPeers "[\"$PEERID_0\"]" &&
+	iptb start 1 &&
+	iptb connect 0 1
+'
+
+test_expect_success "replicate --peer pushes the dir to node 1" '
+	ipfsi 0 replicate --peer=$PEERID_1 $PUSH_HASH > push_out &&
+	grep "0 already present" push_out &&
+	ipfsi 0 refs -r -u $PUSH_HASH | wc -l | tr -d " " > push_count &&
+	ipfsi 1 refs -r --offline $PUSH_HASH | wc -l | tr -d " " > push_count_1 &&
+	test_cmp push_count push_count_1
+'
+
+test_expect_success "replicate --peer --pin pins the dir on node 1" '
+	ipfsi 0 replicate --peer=$PEERID_1 --pin $PUSH_HASH > push_pin_out &&
+	grep "pinned $PUSH_HASH on the target" push_pin_out &&
+	ipfsi 1 pin ls --type=recursive $PUSH_HASH
+'
+
+test_expect_success "shut down nodes" '
+	iptb stop
+'
+
+test_done
@@ -29,4 +29,69 @@ test_expect_success "api flag does not appear in request" '
 	test_expect_code 1 grep "api=/ip4" nc_out
 '
 
+
+test_expect_success "can send basic credentials" '
+	nc -ld 5005 > nc_auth_out &
+	NCPID=$!
+	go-sleep 0.5s && kill "$NCPID" &
+	ipfs cat /ipfs/Qmabcdef --api /ip4/127.0.0.1/tcp/5005 --api-auth admin:secret || true
+'
+
+test_expect_success "request has basic credentials" '
+	grep "Authorization: Basic YWRtaW46c2VjcmV0" nc_auth_out
+'
+
+test_expect_success "api-auth flag does not appear in request" '
+	test_expect_code 1 grep "api-auth" nc_auth_out
+'
+
+test_expect_success "add a remote with a token" '
+	ipfs remote add nc /ip4/127.0.0.1/tcp/5005 --auth=tok123 &&
+	ipfs remote ls > remote_ls &&
+	echo "nc /ip4/127.0.0.1/tcp/5005 (auth)" > remote_expected &&
+	test_cmp remote_expected remote_ls
+'
+
+test_expect_success "remote ls does not show credentials" '
+	test_expect_code 1 grep tok123 remote_ls
+'
+
+test_expect_success "config show redacts credentials" '
+	ipfs config show > show_config &&
+	test_expect_code 1 grep tok123 show_config
+'
+
+test_expect_success "can make http request against a remote by name" '
+	nc -ld 5005 > nc_remote_out &
+	NCPID=$!
+	go-sleep 0.5s && kill "$NCPID" &
+	ipfs version --api nc || true
+'
+
+test_expect_success "request went to the remote with its token" '
+	grep "POST /api/v0/version" nc_remote_out &&
+	grep "Authorization: Bearer tok123" nc_remote_out
+'
+
+test_expect_success "credentials are not sent over plain http to other hosts" '
+	test_must_fail ipfs version --api http://192.0.2.1:5005 --api-auth tok123 2> plain_err &&
+	grep "refusing to send the API credentials over plain http" plain_err
+'
+
+test_expect_success "unknown remotes are refused" '
+	test_must_fail ipfs id --api nope 2> unknown_err &&
+	grep "no remote named \"nope\"" unknown_err
+'
+
+test_expect_success "local-only commands refuse --api" '
+	test_must_fail ipfs config edit --api nc 2> edit_err &&
+	grep "cannot run on an api given with --api" edit_err
+'
+
+test_expect_success "remove the remote" '
+	ipfs remote rm nc &&
+	ipfs remote ls > remote_ls_empty &&
+	test_must_be_empty remote_ls_empty
+'
+
 test_done
@@ -0,0 +1,57 @@
+#!/bin/sh
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test the Idempotency-Key header of the API"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success "add a file to pin" '
+	echo "run me once" >afile &&
+	HASH=$(ipfs add -q afile) &&
+	ipfs pin rm $HASH
+'
+
+test_launch_ipfs_daemon
+
+test_expect_success "a request with a key runs" '
+	curl -si -X POST -H "Idempotency-Key: k1" "http://$API_ADDR/api/v0/pin/add?arg=$HASH" >first &&
+	grep "^HTTP/1.1 200 OK" first &&
+	test_must_fail grep "Idempotent-Replayed" first &&
+	ipfs pin ls --type=recursive $HASH
+'
+
+test_expect_success "its retry is answered again without running" '
+	ipfs pin rm $HASH &&
+	curl -si -X POST -H "Idempotency-Key: k1" "http://$API_ADDR/api/v0/pin/add?arg=$HASH" >retry &&
+	grep "^HTTP/1.1 200 OK" retry &&
+	grep "^Idempotent-Replayed: true" retry &&
+	grep "\"Pins\":\[\"$HASH\"\]" retry &&
+	test_must_fail ipfs pin ls --type=recursive $HASH
+'
+
+test_expect_success "a key reused for another request is refused" '
+	curl -si -X POST -H "Idempotency-Key: k1" "http://$API_ADDR/api/v0/pin/rm?arg=$HASH" >reused &&
+	grep "^HTTP/1.1 422" reused
+'
+
+test_expect_success "a key reused for another body is refused" '
+	echo "other content" >bfile &&
+	curl -sf -X POST -H "Idempotency-Key: k2" -F "file=@afile" "http://$API_ADDR/api/v0/add" >/dev/null &&
+	curl -si -X POST -H "Idempotency-Key: k2" -F "file=@bfile" "http://$API_ADDR/api/v0/add" >reused_body &&
+	grep "^HTTP/1.1 422" reused_body
+'
+
+test_expect_success "the requests without a key run every time" '
+	curl -sf -X POST "http://$API_ADDR/api/v0/pin/add?arg=$HASH" >/dev/null &&
+	ipfs pin rm $HASH &&
+	curl -sf -X POST "http://$API_ADDR/api/v0/pin/add?arg=$HASH" >/dev/null &&
+	ipfs pin ls --type=recursive $HASH
+'
+
+test_kill_ipfs_daemon
+
+test_done
@@ -138,6 +138,12 @@ test_files_api() {
 		test_cmp expected actual
 	'
 
+	test_expect_success "stat --with-locality finds the whole dag local" '
+		ipfs files stat --with-locality / >actual &&
+		grep "^Local: .* (100.00%)$" actual &&
+		grep "^Missing: 0 blocks, 0 bytes$" actual
+	'
+
 	test_expect_success "check root hash" '
 		ipfs files stat --hash / > roothash
 	'
@@ -534,7 +540,78 @@ test_launch_ipfs_daemon
 
 ONLINE=1 # set online flag so tests can easily tell
 test_files_api
+
+test_expect_success "files write rejects unknown flush levels" '
+	echo "data" | test_expect_code 1 ipfs files write --create --flush-level=some /level
+'
+
+test_expect_success "files write with flush-level=parent succeeds" '
+	ipfs files mkdir /leveldir &&
+	echo "data" | ipfs files write --create --flush-level=parent /leveldir/file &&
+	ipfs files read /leveldir/file > level_out &&
+	echo "data" > level_exp &&
+	test_cmp level_exp level_out
+'
+
+test_expect_success "files begin starts a batch" '
+	ipfs files begin &&
+	test_expect_code 1 ipfs files begin
+'
+
+test_expect_success "changes are visible during the batch" '
+	echo "batched" | ipfs files write --create /batched &&
+	ipfs files ls / | grep batched
+'
+
+test_expect_success "files commit applies the batch" '
+	ipfs files commit &&
+	test_expect_code 1 ipfs files commit
+'
+
+test_expect_success "batch is visible after commit" '
+	ipfs files ls / | grep batched
+'
+
+test_expect_success "start a batch which is never committed" '
+	ipfs files begin &&
+	echo "lost" | ipfs files write --create /lost
+'
+
 test_kill_ipfs_daemon
+test_launch_ipfs_daemon
+
+test_expect_success "uncommitted batch is discarded on restart" '
+	ipfs files ls / > batch_ls &&
+	grep batched batch_ls &&
+	test_expect_code 1 grep lost batch_ls
+'
+
+test_expect_success "clean up batch files" '
+	ipfs files rm -r /batched /leveldir
+'
+
+test_kill_ipfs_daemon
+
+test_expect_success "add a directory and remove one of its files" '
+	mkdir locality &&
+	random 1000 1 > locality/file1 &&
+	random 1000 2 > locality/file2 &&
+	ipfs add --pin=false -q -r locality > locality_hashes &&
+	ipfs block rm $(head -1 locality_hashes)
+'
+
+test_expect_success "files stat --with-locality shows the missing block" '
+	ipfs files cp /ipfs/$(tail -1 locality_hashes) /locality &&
+	ipfs files stat --with-locality /locality > locality_stat &&
+	grep "^Local: 2 blocks, " locality_stat &&
+	grep "^Missing: 1 blocks, 10[0-9][0-9] bytes$" locality_stat
+'
+
+test_expect_success "files stat --with-locality --enc=json shows the counts" '
+	ipfs files stat --with-locality --enc=json /locality > locality_json &&
+	grep "\"MissingBlocks\": *1" locality_json &&
+	ipfs files rm -r /locality
+'
 
 test_expect_success "enable sharding in config" '
 	ipfs config --json Experimental.ShardingEnabled true
@@ -0,0 +1,60 @@
+#!/bin/sh
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test ipfs add --link-mode"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success "create a dataset" '
+	mkdir somedir &&
+	random    1000  1 > somedir/file1 &&
+	random 1000000  2 > somedir/file2
+'
+
+test_expect_success "the link modes other than copy need the filestore" '
+	test_must_fail ipfs add -r --link-mode=auto somedir 2>&1 | tee add_out &&
+	grep -q "filestore is not enabled" add_out
+'
+
+test_expect_success "enable filestore config setting" '
+	ipfs config --json Experimental.FilestoreEnabled true
+'
+
+test_expect_success "ipfs add refuses unknown link modes" '
+	test_must_fail ipfs add -r --link-mode=hardlink somedir 2>&1 | tee add_out &&
+	grep -q "unknown link mode" add_out
+'
+
+test_expect_success "ipfs add refuses --nocopy with another link mode" '
+	test_must_fail ipfs add -r --nocopy --link-mode=reflink somedir
+'
+
+test_expect_success "ipfs add --link-mode=auto gives the hashes of a copy" '
+	EXPHASH=$(ipfs add -r -Q --raw-leaves --only-hash somedir) &&
+	ipfs add -r -Q --link-mode=auto somedir > hash_out &&
+	echo $EXPHASH > hash_exp &&
+	test_cmp hash_exp hash_out
+'
+
+test_expect_success "the files added with --link-mode=auto can be changed" '
+	ipfs cat $EXPHASH/file2 > file2_before &&
+	random 1000000 3 > somedir/file2 &&
+	ipfs cat $EXPHASH/file2 > file2_after &&
+	test_cmp file2_before file2_after
+'
+
+test_expect_success "ipfs add --link-mode=reflink clones or fails" '
+	if ipfs add -r -Q --link-mode=reflink somedir > hash_out 2> add_err
+	then
+		test_path_is_dir "$IPFS_PATH/reflinks" &&
+		ipfs filestore ls | grep -q reflinks
+	else
+		grep -q "cannot clone" add_err
+	fi
+'
+
+test_done
@@ -0,0 +1,83 @@
+// Package bcryptpbkdf implements bcrypt_pbkdf, the key derivation function
+// of the encrypted private keys of OpenSSH, after
+// golang.org/x/crypto/ssh/internal/bcrypt_pbkdf.
+package bcryptpbkdf
+
+import (
+	"crypto/sha512"
+	"errors"
+)
+
+const blockSize = 32
+
+// magic is the text bcrypt encrypts.
+var magic = []byte("OxychromaticBlowfishSwatDynamite")
+
+// Key derives a key of keyLen bytes from password and salt with the given
+// number of rounds.
+func Key(password, salt []byte, rounds, keyLen int) ([]byte, error) {
+	if rounds < 1 {
+		return nil, errors.New("bcrypt_pbkdf: number of rounds is too small")
+	}
+	if len(password) == 0 {
+		return nil, errors.New("bcrypt_pbkdf: empty password")
+	}
+	if len(salt) == 0 || len(salt) > 1<<20 {
+		return nil, errors.New("bcrypt_pbkdf: bad salt length")
+	}
+
+	numBlocks := (keyLen + blockSize - 1) / blockSize
+	key := make([]byte, numBlocks*blockSize)
+
+	h := sha512.New()
+	h.Write(password)
+	shapass := h.Sum(nil)
+
+	shasalt := make([]byte, 0, sha512.Size)
+	cnt, tmp := make([]byte, 4), make([]byte, blockSize)
+	for block := 1; block <= numBlocks; block++ {
+		h.Reset()
+		h.Write(salt)
+		cnt[0] = byte(block >> 24)
+		cnt[1] = byte(block >> 16)
+		cnt[2] = byte(block >> 8)
+		cnt[3] = byte(block)
+		h.Write(cnt)
+		bcryptHash(tmp, shapass, h.Sum(shasalt))
+
+		out := make([]byte, blockSize)
+		copy(out, tmp)
+		for i := 2; i <= rounds; i++ {
+			h.Reset()
+			h.Write(tmp)
+			bcryptHash(tmp, shapass, h.Sum(shasalt))
+			for j := range out {
+				out[j] ^= tmp[j]
+			}
+		}
+
+		// the bytes of the blocks are interleaved
+		for i, v := range out {
+			key[i*numBlocks+(block-1)] = v
+		}
+	}
+	return key[:keyLen], nil
+}
+
+func bcryptHash(out, shapass, shasalt []byte) {
+	c := newSaltedBlowfish(shapass, shasalt)
+	for i := 0; i < 64; i++ {
+		c.expandKey(shasalt)
+		c.expandKey(shapass)
+	}
+	copy(out, magic)
+	for i := 0; i < blockSize; i += 8 {
+		for j := 0; j < 64; j++ {
+			c.encrypt(out[i : i+8])
+		}
+	}
+	// the words are written little endian
+	for i := 0; i < blockSize; i += 4 {
+		out[i+3], out[i+2], out[i+1], out[i] = out[i], out[i+1], out[i+2], out[i+3]
+	}
+}
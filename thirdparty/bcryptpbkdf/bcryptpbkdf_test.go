@@ -0,0 +1,33 @@
+package bcryptpbkdf
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// test vectors of OpenBSD
+var vectors = []struct {
+	password, salt string
+	rounds         int
+	key            string
+}{
+	{"password", "salt", 4, "5bbf0cc293587f1c3635555c27796598d47e579071bf427e9d8fbe842aba34d9"},
+}
+
+func TestKey(t *testing.T) {
+	for i, v := range vectors {
+		expected, _ := hex.DecodeString(v.key)
+		k, err := Key([]byte(v.password), []byte(v.salt), v.rounds, len(expected))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(k, expected) {
+			t.Fatalf("vector %d: expected %x, got %x", i, expected, k)
+		}
+	}
+
+	if _, err := Key([]byte("password"), []byte("salt"), 0, 32); err == nil {
+		t.Fatal("expected 0 rounds to be refused")
+	}
+}
@@ -0,0 +1,84 @@
+package bcryptpbkdf
+
+import "encoding/binary"
+
+// blowfish is the state of the Blowfish cipher, with the key schedule of
+// bcrypt, after golang.org/x/crypto/blowfish.
+type blowfish struct {
+	p              [18]uint32
+	s0, s1, s2, s3 [256]uint32
+}
+
+// newSaltedBlowfish returns the cipher of key, expanded with salt.
+func newSaltedBlowfish(key, salt []byte) *blowfish {
+	c := &blowfish{p: p, s0: s0, s1: s1, s2: s2, s3: s3}
+	c.expandKeyWithSalt(key, salt)
+	return c
+}
+
+// nextWord returns the next 32 bits of b from *pos, cycling through b.
+func nextWord(b []byte, pos *int) uint32 {
+	var w uint32
+	j := *pos
+	for i := 0; i < 4; i++ {
+		w = w<<8 | uint32(b[j])
+		j++
+		if j >= len(b) {
+			j = 0
+		}
+	}
+	*pos = j
+	return w
+}
+
+func (c *blowfish) expandKey(key []byte) {
+	c.expandKeyWithSalt(key, nil)
+}
+
+// expandKeyWithSalt mixes key into the state, and salt, if any, into the
+// blocks encrypted to replace it.
+func (c *blowfish) expandKeyWithSalt(key, salt []byte) {
+	j := 0
+	for i := range c.p {
+		c.p[i] ^= nextWord(key, &j)
+	}
+
+	j = 0
+	var l, r uint32
+	expand := func(t []uint32) {
+		for i := 0; i < len(t); i += 2 {
+			if len(salt) > 0 {
+				l ^= nextWord(salt, &j)
+				r ^= nextWord(salt, &j)
+			}
+			l, r = c.encryptBlock(l, r)
+			t[i], t[i+1] = l, r
+		}
+	}
+	expand(c.p[:])
+	expand(c.s0[:])
+	expand(c.s1[:])
+	expand(c.s2[:])
+	expand(c.s3[:])
+}
+
+func (c *blowfish) f(x uint32) uint32 {
+	return ((c.s0[byte(x>>24)] + c.s1[byte(x>>16)]) ^ c.s2[byte(x>>8)]) + c.s3[byte(x)]
+}
+
+func (c *blowfish) encryptBlock(l, r uint32) (uint32, uint32) {
+	xl, xr := l^c.p[0], r
+	for i := 1; i < 17; i += 2 {
+		xr ^= c.f(xl) ^ c.p[i]
+		xl ^= c.f(xr) ^ c.p[i+1]
+	}
+	xr ^= c.p[17]
+	return xr, xl
+}
+
+// encrypt encrypts the 8 bytes block of b in place.
+func (c *blowfish) encrypt(b []byte) {
+	l, r := c.encryptBlock(binary.BigEndian.Uint32(b), binary.BigEndian.Uint32(b[4:]))
+	binary.BigEndian.PutUint32(b, l)
+	binary.BigEndian.PutUint32(b[4:], r)
+}
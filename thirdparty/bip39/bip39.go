@@ -0,0 +1,162 @@
+// Package bip39 implements the mnemonic sentences of BIP 39, with the
+// English word list, and the derivation of ed25519 keys from their seeds of
+// SLIP-0010.
+package bip39
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	pbkdf2 "github.com/ipfs/go-ipfs/thirdparty/pbkdf2"
+)
+
+// ErrInvalidMnemonic is returned for mnemonics whose checksum is wrong.
+var ErrInvalidMnemonic = errors.New("invalid mnemonic: wrong checksum")
+
+var wordIndex = make(map[string]int, len(english))
+
+func init() {
+	for i, w := range english {
+		wordIndex[w] = i
+	}
+}
+
+// NewMnemonic returns the mnemonic of entropy, of 16 to 32 bytes by steps of
+// 4: 12 words for 16 bytes, 24 for 32.
+func NewMnemonic(entropy []byte) (string, error) {
+	n := len(entropy)
+	if n < 16 || n > 32 || n%4 != 0 {
+		return "", fmt.Errorf("the entropy of a mnemonic is 16 to 32 bytes by steps of 4, not %d", n)
+	}
+
+	// the checksum is the first bits of the SHA-256 of the entropy, one bit
+	// per 4 bytes
+	sum := sha256.Sum256(entropy)
+	bits := append(append([]byte{}, entropy...), sum[0])
+
+	words := make([]string, n*8/32*3)
+	for i := range words {
+		words[i] = english[bitsAt(bits, i*11, 11)]
+	}
+	return strings.Join(words, " "), nil
+}
+
+// MnemonicToEntropy returns the entropy of mnemonic, checking its words and
+// checksum.
+func MnemonicToEntropy(mnemonic string) ([]byte, error) {
+	words := strings.Fields(mnemonic)
+	n := len(words)
+	if n < 12 || n > 24 || n%3 != 0 {
+		return nil, fmt.Errorf("invalid mnemonic: 12 to 24 words by steps of 3 expected, got %d", n)
+	}
+
+	bits := make([]byte, (n*11+7)/8)
+	for i, w := range words {
+		idx, ok := wordIndex[strings.ToLower(w)]
+		if !ok {
+			return nil, fmt.Errorf("invalid mnemonic: unknown word %q", w)
+		}
+		setBitsAt(bits, i*11, 11, idx)
+	}
+
+	size := n * 11 * 32 / 33 / 8
+	entropy := bits[:size]
+	sum := sha256.Sum256(entropy)
+	csBits := n * 11 / 33
+	if bitsAt(bits, size*8, csBits) != bitsAt(sum[:], 0, csBits) {
+		return nil, ErrInvalidMnemonic
+	}
+	return entropy, nil
+}
+
+// Seed returns the seed of mnemonic, protected by passphrase, after checking
+// the mnemonic. Passphrases are expected in NFKD, as ASCII ones are.
+func Seed(mnemonic, passphrase string) ([]byte, error) {
+	if _, err := MnemonicToEntropy(mnemonic); err != nil {
+		return nil, err
+	}
+	normalized := strings.ToLower(strings.Join(strings.Fields(mnemonic), " "))
+	return pbkdf2.Key([]byte(normalized), []byte("mnemonic"+passphrase), 2048, 64, sha512.New), nil
+}
+
+// bitsAt returns the n bits of b at offset off, n up to 32.
+func bitsAt(b []byte, off, n int) int {
+	v := 0
+	for i := 0; i < n; i++ {
+		bit := off + i
+		v <<= 1
+		if b[bit/8]&(0x80>>uint(bit%8)) != 0 {
+			v |= 1
+		}
+	}
+	return v
+}
+
+// setBitsAt sets the n bits of b at offset off to v.
+func setBitsAt(b []byte, off, n, v int) {
+	for i := 0; i < n; i++ {
+		if v&(1<<uint(n-1-i)) != 0 {
+			bit := off + i
+			b[bit/8] |= 0x80 >> uint(bit%8)
+		}
+	}
+}
+
+// hardened is the first index of hardened derivation.
+const hardened = 1 << 31
+
+// DeriveEd25519 returns the private key, a 32 bytes ed25519 seed, derived
+// from seed along path, such as "m/44'/4001'/0'", as SLIP-0010 defines it.
+// The indexes of ed25519 paths are all hardened.
+func DeriveEd25519(seed []byte, path string) ([]byte, error) {
+	indexes, err := ParsePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	mac := hmac.New(sha512.New, []byte("ed25519 seed"))
+	mac.Write(seed)
+	i := mac.Sum(nil)
+	key, chain := i[:32], i[32:]
+
+	for _, idx := range indexes {
+		var ser [4]byte
+		binary.BigEndian.PutUint32(ser[:], idx)
+
+		mac := hmac.New(sha512.New, chain)
+		mac.Write([]byte{0})
+		mac.Write(key)
+		mac.Write(ser[:])
+		i := mac.Sum(nil)
+		key, chain = i[:32], i[32:]
+	}
+	return key, nil
+}
+
+// ParsePath returns the indexes of a derivation path of hardened indexes,
+// such as "m/44'/4001'/0'".
+func ParsePath(path string) ([]uint32, error) {
+	parts := strings.Split(path, "/")
+	if parts[0] != "m" {
+		return nil, fmt.Errorf("invalid derivation path %q: it must begin with m", path)
+	}
+
+	indexes := make([]uint32, 0, len(parts)-1)
+	for _, p := range parts[1:] {
+		if !strings.HasSuffix(p, "'") {
+			return nil, fmt.Errorf("invalid derivation path %q: the indexes of ed25519 keys must be hardened, as %s'", path, p)
+		}
+		idx, err := strconv.ParseUint(strings.TrimSuffix(p, "'"), 10, 31)
+		if err != nil {
+			return nil, fmt.Errorf("invalid derivation path %q: %s", path, err)
+		}
+		indexes = append(indexes, uint32(idx)+hardened)
+	}
+	return indexes, nil
+}
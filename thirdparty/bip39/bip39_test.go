@@ -0,0 +1,93 @@
+package bip39
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// test vectors of the reference implementation of BIP 39, with the
+// passphrase "TREZOR"
+var vectors = []struct {
+	entropy, mnemonic, seed string
+}{
+	{
+		"00000000000000000000000000000000",
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+		"c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04",
+	},
+	{
+		"7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f",
+		"legal winner thank year wave sausage worth useful legal winner thank yellow",
+		"2e8905819b8723fe2c1d161860e5ee1830318dbf49a83bd451cfb8440c28bd6fa457fe1296106559a3c80937a1c1069be3a3a5bd381ee6260e8d9739fce1f607",
+	},
+	{
+		"ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff",
+		"zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo vote",
+		"dd48c104698c30cfe2b6142103248622fb7bb0ff692eebb00089b32d22484e1613912f0a5b694407be899ffd31ed3992c456cdf60f5d4564b8ba3f05a69890ad",
+	},
+}
+
+func TestMnemonic(t *testing.T) {
+	for _, v := range vectors {
+		entropy, _ := hex.DecodeString(v.entropy)
+		m, err := NewMnemonic(entropy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m != v.mnemonic {
+			t.Fatalf("expected %q, got %q", v.mnemonic, m)
+		}
+
+		e, err := MnemonicToEntropy(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(e, entropy) {
+			t.Fatalf("expected the entropy %x, got %x", entropy, e)
+		}
+
+		seed, err := Seed(m, "TREZOR")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hex.EncodeToString(seed) != v.seed {
+			t.Fatalf("expected the seed %s, got %x", v.seed, seed)
+		}
+	}
+}
+
+func TestInvalidMnemonic(t *testing.T) {
+	wrong := strings.Replace(vectors[0].mnemonic, "about", "abandon", 1)
+	if _, err := MnemonicToEntropy(wrong); err != ErrInvalidMnemonic {
+		t.Fatalf("expected ErrInvalidMnemonic, got %v", err)
+	}
+	if _, err := MnemonicToEntropy("abandon abandon"); err == nil {
+		t.Fatal("expected too short a mnemonic to fail")
+	}
+	if _, err := MnemonicToEntropy(strings.Replace(vectors[0].mnemonic, "about", "ipfs", 1)); err == nil {
+		t.Fatal("expected an unknown word to fail")
+	}
+}
+
+// test vector 1 of SLIP-0010 for ed25519
+func TestDeriveEd25519(t *testing.T) {
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	for path, key := range map[string]string{
+		"m":    "2b4be7f19ee27bbf30c667b642d5f4aa69fd169872f8fc3059c08ebae2eb19e7",
+		"m/0'": "68e0fe46dfb67e368c75379acec591dad19df3cde26e63b93a8e704f1dade7a3",
+	} {
+		k, err := DeriveEd25519(seed, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hex.EncodeToString(k) != key {
+			t.Fatalf("%s: expected %s, got %x", path, key, k)
+		}
+	}
+
+	if _, err := DeriveEd25519(seed, "m/0"); err == nil {
+		t.Fatal("expected a path of unhardened indexes to fail")
+	}
+}
@@ -0,0 +1,199 @@
+package datastore2
+
+import (
+	"io"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+	dsq "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore/query"
+	logging "gx/ipfs/QmSpJByNKFX1sCsHBEp3R73FL4NF6FnQTEGyNAXHm2GS52/go-log"
+)
+
+var log = logging.Logger("datastore2")
+
+// LatencySamples is the number of recent operations percentiles are
+// computed over.
+const LatencySamples = 1024
+
+// Operations tracked by a LatencyDatastore.
+const (
+	OpGet    = "get"
+	OpPut    = "put"
+	OpHas    = "has"
+	OpDelete = "delete"
+	OpQuery  = "query"
+	OpBatch  = "batch"
+)
+
+var latencyOps = []string{OpGet, OpPut, OpHas, OpDelete, OpQuery, OpBatch}
+
+// LatencyStats summarizes the latency of one kind of operation.
+type LatencyStats struct {
+	// Count is the number of operations since the datastore was opened.
+	Count uint64
+
+	// P50, P90, P99 and Max are computed over the last LatencySamples
+	// operations.
+	P50 time.Duration
+	P90 time.Duration
+	P99 time.Duration
+	Max time.Duration
+}
+
+// LatencyDatastore records the latency of every operation on the wrapped
+// datastore, and logs the operations slower than a threshold along with the
+// stack which issued them.
+type LatencyDatastore struct {
+	child ds.Datastore
+	name  string
+	slow  time.Duration
+
+	windows map[string]*latencyWindow
+}
+
+// LatencyWrap wraps child, logging operations slower than slow under name.
+// A zero slow disables logging, latencies are recorded regardless.
+func LatencyWrap(name string, child ds.Datastore, slow time.Duration) *LatencyDatastore {
+	d := &LatencyDatastore{
+		child:   child,
+		name:    name,
+		slow:    slow,
+		windows: make(map[string]*latencyWindow),
+	}
+	for _, op := range latencyOps {
+		d.windows[op] = new(latencyWindow)
+	}
+	return d
+}
+
+// Name returns the name the datastore was wrapped with.
+func (d *LatencyDatastore) Name() string {
+	return d.name
+}
+
+// Stats returns the latency of each kind of operation.
+func (d *LatencyDatastore) Stats() map[string]LatencyStats {
+	out := make(map[string]LatencyStats, len(d.windows))
+	for op, w := range d.windows {
+		out[op] = w.stats()
+	}
+	return out
+}
+
+func (d *LatencyDatastore) record(op string, key ds.Key, start time.Time) {
+	took := time.Since(start)
+	d.windows[op].add(took)
+
+	if d.slow > 0 && took >= d.slow {
+		log.Warningf("slow datastore operation: %s %s on %s took %s\n%s", op, key, d.name, took, debug.Stack())
+	}
+}
+
+func (d *LatencyDatastore) Put(key ds.Key, value interface{}) error {
+	defer d.record(OpPut, key, time.Now())
+	return d.child.Put(key, value)
+}
+
+func (d *LatencyDatastore) Get(key ds.Key) (interface{}, error) {
+	defer d.record(OpGet, key, time.Now())
+	return d.child.Get(key)
+}
+
+func (d *LatencyDatastore) Has(key ds.Key) (bool, error) {
+	defer d.record(OpHas, key, time.Now())
+	return d.child.Has(key)
+}
+
+func (d *LatencyDatastore) Delete(key ds.Key) error {
+	defer d.record(OpDelete, key, time.Now())
+	return d.child.Delete(key)
+}
+
+// Query records the time taken to start the query; results are streamed
+// by the wrapped datastore.
+func (d *LatencyDatastore) Query(q dsq.Query) (dsq.Results, error) {
+	defer d.record(OpQuery, ds.NewKey(q.Prefix), time.Now())
+	return d.child.Query(q)
+}
+
+func (d *LatencyDatastore) Batch() (ds.Batch, error) {
+	bds, ok := d.child.(ds.Batching)
+	if !ok {
+		return nil, ds.ErrBatchUnsupported
+	}
+
+	b, err := bds.Batch()
+	if err != nil {
+		return nil, err
+	}
+	return &latencyBatch{Batch: b, d: d}, nil
+}
+
+func (d *LatencyDatastore) Close() error {
+	if c, ok := d.child.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+var _ ds.Batching = &LatencyDatastore{}
+
+// latencyBatch records the time taken to commit a batch.
+type latencyBatch struct {
+	ds.Batch
+	d *LatencyDatastore
+}
+
+func (b *latencyBatch) Commit() error {
+	defer b.d.record(OpBatch, ds.NewKey("/"), time.Now())
+	return b.Batch.Commit()
+}
+
+// latencyWindow keeps the last LatencySamples latencies of an operation.
+type latencyWindow struct {
+	mu      sync.Mutex
+	count   uint64
+	samples [LatencySamples]time.Duration
+}
+
+func (w *latencyWindow) add(took time.Duration) {
+	w.mu.Lock()
+	w.samples[w.count%LatencySamples] = took
+	w.count++
+	w.mu.Unlock()
+}
+
+func (w *latencyWindow) stats() LatencyStats {
+	w.mu.Lock()
+	n := w.count
+	if n > LatencySamples {
+		n = LatencySamples
+	}
+	sorted := make(durations, n)
+	copy(sorted, w.samples[:n])
+	st := LatencyStats{Count: w.count}
+	w.mu.Unlock()
+
+	if n == 0 {
+		return st
+	}
+
+	sort.Sort(sorted)
+	at := func(p int) time.Duration {
+		return sorted[(len(sorted)-1)*p/100]
+	}
+	st.P50 = at(50)
+	st.P90 = at(90)
+	st.P99 = at(99)
+	st.Max = sorted[len(sorted)-1]
+	return st
+}
+
+type durations []time.Duration
+
+func (d durations) Len() int           { return len(d) }
+func (d durations) Less(i, j int) bool { return d[i] < d[j] }
+func (d durations) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
@@ -0,0 +1,87 @@
+package datastore2
+
+import (
+	"testing"
+	"time"
+
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+)
+
+func TestLatencyWindowPercentiles(t *testing.T) {
+	var w latencyWindow
+	if st := w.stats(); st != (LatencyStats{}) {
+		t.Fatalf("expected empty stats, got %+v", st)
+	}
+
+	// added out of order, 1ms to 100ms
+	for i := 100; i > 0; i-- {
+		w.add(time.Duration(i) * time.Millisecond)
+	}
+
+	st := w.stats()
+	expected := LatencyStats{
+		Count: 100,
+		P50:   50 * time.Millisecond,
+		P90:   90 * time.Millisecond,
+		P99:   99 * time.Millisecond,
+		Max:   100 * time.Millisecond,
+	}
+	if st != expected {
+		t.Fatalf("expected %+v, got %+v", expected, st)
+	}
+}
+
+func TestLatencyWindowKeepsLastSamples(t *testing.T) {
+	var w latencyWindow
+	for i := 0; i < LatencySamples; i++ {
+		w.add(time.Hour)
+	}
+	for i := 0; i < LatencySamples; i++ {
+		w.add(time.Millisecond)
+	}
+
+	st := w.stats()
+	if st.Count != 2*LatencySamples {
+		t.Fatalf("expected a count of %d, got %d", 2*LatencySamples, st.Count)
+	}
+	if st.Max != time.Millisecond || st.P50 != time.Millisecond {
+		t.Fatalf("expected the older samples to be dropped, got %+v", st)
+	}
+}
+
+func TestLatencyWrapRecords(t *testing.T) {
+	d := LatencyWrap("test", ds.NewMapDatastore(), 0)
+	if d.Name() != "test" {
+		t.Fatalf("unexpected name %q", d.Name())
+	}
+
+	k := ds.NewKey("/a")
+	if err := d.Put(k, []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := d.Get(k); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := d.Has(k); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Delete(k); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := d.Stats()
+	for op, count := range map[string]uint64{OpPut: 1, OpGet: 3, OpHas: 1, OpDelete: 1, OpQuery: 0, OpBatch: 0} {
+		st, ok := stats[op]
+		if !ok {
+			t.Fatalf("no stats for %s", op)
+		}
+		if st.Count != count {
+			t.Fatalf("expected %d %s operations, got %d", count, op, st.Count)
+		}
+		if st.P50 > st.P90 || st.P90 > st.P99 || st.P99 > st.Max {
+			t.Fatalf("%s: percentiles out of order: %+v", op, st)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package scrypt
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// test vectors of RFC 7914
+var vectors = []struct {
+	password, salt string
+	N, r, p        int
+	key            string
+}{
+	{"", "", 16, 1, 1, "77d6576238657b203b19ca42c18a0497f16b4844e3074ae8dfdffa3fede21442fcd0069ded0948f8326a753a0fc81f17e8d3e0fb2e0d3628cf35e20c38d18906"},
+	{"password", "NaCl", 1024, 8, 16, "fdbabe1c9d3472007856e7190d01e9fe7c6ad7cbc8237830e77376634b3731622eaf30d92e22a3886ff109279d9830dac727afb94a83ee6d8360cbdfa2cc0640"},
+}
+
+func TestKey(t *testing.T) {
+	for i, v := range vectors {
+		expected, _ := hex.DecodeString(v.key)
+		k, err := Key([]byte(v.password), []byte(v.salt), v.N, v.r, v.p, len(expected))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(k, expected) {
+			t.Fatalf("vector %d: expected %x, got %x", i, expected, k)
+		}
+	}
+
+	if _, err := Key([]byte("password"), nil, 1000, 8, 1, 32); err == nil {
+		t.Fatal("expected N not a power of 2 to be refused")
+	}
+}
@@ -13,6 +13,10 @@ import (
 type Extractor struct {
 	Path     string
 	Progress func(int64) int64
+
+	// Sparse, if set, leaves the runs of zeros of the files as holes
+	// rather than writing them, on the filesystems supporting them.
+	Sparse bool
 }
 
 func (te *Extractor) Extract(reader io.Reader) error {
@@ -51,7 +55,7 @@ func (te *Extractor) Extract(reader io.Reader) error {
 				return err
 			}
 		case tar.TypeSymlink:
-			if err := te.extractSymlink(header); err != nil {
+			if err := te.extractSymlink(header, i, rootExists, rootIsDir); err != nil {
 				return err
 			}
 		default:
@@ -61,33 +65,116 @@ func (te *Extractor) Extract(reader io.Reader) error {
 	return nil
 }
 
-// outputPath returns the path at whicht o place tarPath
-func (te *Extractor) outputPath(tarPath string) string {
+// outputPath returns the path at which to place tarPath, which must stay
+// inside the root of the extraction.
+func (te *Extractor) outputPath(tarPath string) (string, error) {
 	elems := strings.Split(tarPath, "/") // break into elems
 	elems = elems[1:]                    // remove original root
 
 	path := fp.Join(elems...)     // join elems
 	path = fp.Join(te.Path, path) // rebase on extractor root
-	return path
+	if !within(te.Path, path) {
+		return "", &EscapeError{Name: tarPath}
+	}
+	return path, nil
+}
+
+// EscapeError is returned for the entries of an archive which would be
+// written outside of the directory it is extracted to.
+type EscapeError struct {
+	Name   string
+	Target string // of symlinks
+}
+
+func (e *EscapeError) Error() string {
+	if e.Target != "" {
+		return fmt.Sprintf("refusing to extract %s: its target %s is outside of the output directory", e.Name, e.Target)
+	}
+	return fmt.Sprintf("refusing to extract %s: it is outside of the output directory", e.Name)
+}
+
+// within tells whether path is root or under it.
+func within(root, path string) bool {
+	rel, err := fp.Rel(root, path)
+	if err != nil || fp.IsAbs(rel) {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(fp.Separator))
+}
+
+// checkParent fails unless the directory path is written in stays inside the
+// root once the symlinks already extracted are followed, so that nothing is
+// written through a link out of it. path itself must not be a symlink. The
+// root and the parent are returned with their links followed.
+func (te *Extractor) checkParent(h *tar.Header, path string) (root, parent string, err error) {
+	root, err = fp.EvalSymlinks(te.Path)
+	if err != nil {
+		return "", "", err
+	}
+	parent, err = fp.EvalSymlinks(fp.Dir(path))
+	if err != nil {
+		return "", "", err
+	}
+	if !within(root, parent) {
+		return "", "", &EscapeError{Name: h.Name}
+	}
+	if st, err := os.Lstat(path); err == nil && st.Mode()&os.ModeSymlink != 0 {
+		return "", "", &EscapeError{Name: h.Name}
+	}
+	return root, parent, nil
 }
 
 func (te *Extractor) extractDir(h *tar.Header, depth int) error {
-	path := te.outputPath(h.Name)
+	path, err := te.outputPath(h.Name)
+	if err != nil {
+		return err
+	}
 
 	if depth == 0 {
 		// if this is the root root directory, use it as the output path for remaining files
 		te.Path = path
+	} else if _, _, err := te.checkParent(h, path); err != nil {
+		return err
 	}
 
 	return os.MkdirAll(path, 0755)
 }
 
-func (te *Extractor) extractSymlink(h *tar.Header) error {
-	return os.Symlink(h.Linkname, te.outputPath(h.Name))
+// extractSymlink recreates a symlink. In a directory, the links pointing out
+// of it are refused, whether their targets are absolute or go up too far.
+func (te *Extractor) extractSymlink(h *tar.Header, depth int, rootExists bool, rootIsDir bool) error {
+	path, err := te.outputPath(h.Name)
+	if err != nil {
+		return err
+	}
+
+	if depth == 0 { // the link alone is extracted, nothing is written through it
+		if rootExists && rootIsDir {
+			path = fp.Join(path, gopath.Base(h.Name))
+		}
+		return os.Symlink(h.Linkname, path)
+	}
+
+	root, parent, err := te.checkParent(h, path)
+	if err != nil {
+		return err
+	}
+	target := fp.FromSlash(h.Linkname)
+	if fp.IsAbs(target) || strings.HasPrefix(h.Linkname, "/") {
+		return &EscapeError{Name: h.Name, Target: h.Linkname}
+	}
+	if !within(root, fp.Join(parent, target)) {
+		return &EscapeError{Name: h.Name, Target: h.Linkname}
+	}
+
+	return os.Symlink(h.Linkname, path)
 }
 
 func (te *Extractor) extractFile(h *tar.Header, r *tar.Reader, depth int, rootExists bool, rootIsDir bool) error {
-	path := te.outputPath(h.Name)
+	path, err := te.outputPath(h.Name)
+	if err != nil {
+		return err
+	}
 
 	if depth == 0 { // if depth is 0, this is the only file (we aren't 'ipfs get'ing a directory)
 		if rootExists && rootIsDir {
@@ -99,6 +186,8 @@ func (te *Extractor) extractFile(h *tar.Header, r *tar.Reader, depth int, rootEx
 				path = fp.Join(path, fnameo)
 			}
 		} // else if old file exists, just overwrite it.
+	} else if _, _, err := te.checkParent(h, path); err != nil {
+		return err
 	}
 
 	file, err := os.Create(path)
@@ -107,22 +196,86 @@ func (te *Extractor) extractFile(h *tar.Header, r *tar.Reader, depth int, rootEx
 	}
 	defer file.Close()
 
-	return copyWithProgress(file, r, te.Progress)
+	if !te.Sparse {
+		return copyWithProgress(file, r, te.Progress)
+	}
+	sw := &sparseWriter{f: file}
+	if err := copyWithProgress(sw, r, te.Progress); err != nil {
+		return err
+	}
+	return sw.Close()
+}
+
+// sparseBlockSize is the size of the blocks of zeros sparseWriter seeks over.
+const sparseBlockSize = 4096
+
+// sparseWriter writes to a new file, seeking over the blocks of zeros
+// instead of writing them, which leaves holes. Close sets the size of the
+// file, which may end with a hole.
+type sparseWriter struct {
+	f      *os.File
+	offset int64
+}
+
+func (w *sparseWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		// the runs of blocks, aligned on the blocks of the file, are either
+		// all zeros or written
+		n, zero := 0, false
+		for i := 0; n < len(p); i++ {
+			l := sparseBlockSize - int((w.offset+int64(n))%sparseBlockSize)
+			if l > len(p)-n {
+				l = len(p) - n
+			}
+			z := isZero(p[n : n+l])
+			if i > 0 && z != zero {
+				break
+			}
+			n, zero = n+l, z
+		}
+
+		if zero {
+			if _, err := w.f.Seek(int64(n), io.SeekCurrent); err != nil {
+				return written, err
+			}
+		} else if _, err := w.f.Write(p[:n]); err != nil {
+			return written, err
+		}
+		w.offset += int64(n)
+		written += n
+		p = p[n:]
+	}
+	return written, nil
+}
+
+func (w *sparseWriter) Close() error {
+	return w.f.Truncate(w.offset)
+}
+
+func isZero(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
 }
 
 func copyWithProgress(to io.Writer, from io.Reader, cb func(int64) int64) error {
 	buf := make([]byte, 4096)
 	for {
+		// the last bytes may come with io.EOF
 		n, err := from.Read(buf)
-		if err != nil {
-			if err == io.EOF {
-				return nil
+		if n > 0 {
+			cb(int64(n))
+			if _, err := to.Write(buf[:n]); err != nil {
+				return err
 			}
-			return err
 		}
-
-		cb(int64(n))
-		_, err = to.Write(buf[:n])
+		if err == io.EOF {
+			return nil
+		}
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,117 @@
+package tar
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	fp "path/filepath"
+	"testing"
+)
+
+type entry struct {
+	name, link, data string
+	dir              bool
+}
+
+func archive(t *testing.T, entries ...entry) *bytes.Buffer {
+	buf := new(bytes.Buffer)
+	w := tar.NewWriter(buf)
+	for _, e := range entries {
+		h := &tar.Header{Name: e.name, Mode: 0644}
+		switch {
+		case e.dir:
+			h.Typeflag, h.Mode = tar.TypeDir, 0755
+		case e.link != "":
+			h.Typeflag, h.Linkname = tar.TypeSymlink, e.link
+		default:
+			h.Typeflag, h.Size = tar.TypeReg, int64(len(e.data))
+		}
+		if err := w.WriteHeader(h); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(e.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf
+}
+
+func TestExtractSymlinks(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "extractor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	progress := func(n int64) int64 { return n }
+
+	out := fp.Join(tmp, "out")
+	te := &Extractor{Path: out, Progress: progress}
+	err = te.Extract(archive(t,
+		entry{name: "root", dir: true},
+		entry{name: "root/a", data: "hello"},
+		entry{name: "root/sub", dir: true},
+		entry{name: "root/sub/up", link: "../a"},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	target, err := os.Readlink(fp.Join(out, "sub", "up"))
+	if err != nil || target != "../a" {
+		t.Fatalf("expected the link to ../a to be kept, got %q, %v", target, err)
+	}
+
+	escapes := [][]entry{
+		{{name: "root", dir: true}, {name: "root/../evil", data: "x"}},
+		{{name: "root", dir: true}, {name: "root/abs", link: "/etc/passwd"}},
+		{{name: "root", dir: true}, {name: "root/up", link: "../evil"}},
+		// the link is inside, but it leads out once its parent is followed
+		{{name: "root", dir: true}, {name: "root/here", link: "."}, {name: "root/here/up", link: ".."}},
+		// a file written over a link
+		{{name: "root", dir: true}, {name: "root/link", link: "a"}, {name: "root/link", data: "x"}},
+	}
+	for i, entries := range escapes {
+		out := fp.Join(tmp, "escape", fmt.Sprint(i))
+		te := &Extractor{Path: out, Progress: progress}
+		err := te.Extract(archive(t, entries...))
+		if _, ok := err.(*EscapeError); !ok {
+			t.Errorf("case %d: expected an escape to be refused, got %v", i, err)
+		}
+	}
+	if _, err := os.Lstat(fp.Join(tmp, "escape", "evil")); !os.IsNotExist(err) {
+		t.Fatal("a file was written outside of the output directory")
+	}
+}
+
+func TestExtractSparse(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "extractor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	zeros := string(make([]byte, 3*sparseBlockSize+100))
+	for i, data := range []string{
+		zeros,
+		"start" + zeros,
+		zeros + "middle" + zeros,
+		zeros + "end",
+	} {
+		out := fp.Join(tmp, fmt.Sprint(i))
+		te := &Extractor{Path: out, Progress: func(n int64) int64 { return n }, Sparse: true}
+		if err := te.Extract(archive(t, entry{name: "file", data: data})); err != nil {
+			t.Fatal(err)
+		}
+		got, err := ioutil.ReadFile(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != data {
+			t.Errorf("case %d: the file extracted differs, %d bytes instead of %d", i, len(got), len(data))
+		}
+	}
+}
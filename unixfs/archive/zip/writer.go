@@ -0,0 +1,126 @@
+package zip
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"time"
+
+	mdag "github.com/ipfs/go-ipfs/merkledag"
+	ft "github.com/ipfs/go-ipfs/unixfs"
+	uio "github.com/ipfs/go-ipfs/unixfs/io"
+	upb "github.com/ipfs/go-ipfs/unixfs/pb"
+
+	proto "gx/ipfs/QmZ4Qi3GaRbjcx28Sme5eMH7RQjGkt8wHxt2a65oLaeFEV/gogo-protobuf/proto"
+	node "gx/ipfs/Qmb3Hm9QDFmfYuET4pu7Kyg8JV78jFa1nvZx5vnCZsK4ck/go-ipld-format"
+)
+
+// Writer writes unixfs merkledag nodes as a zip archive, as they are read:
+// the files are stored uncompressed, with their sizes and checksums after
+// their data, so that nothing is buffered.
+type Writer struct {
+	Dag  mdag.DAGService
+	ZipW *zip.Writer
+
+	ctx context.Context
+}
+
+// NewWriter wraps given io.Writer.
+func NewWriter(ctx context.Context, dag mdag.DAGService, w io.Writer) *Writer {
+	return &Writer{
+		Dag:  dag,
+		ZipW: zip.NewWriter(w),
+		ctx:  ctx,
+	}
+}
+
+func (w *Writer) writeDir(nd *mdag.ProtoNode, fpath string) error {
+	if _, err := w.create(fpath+"/", os.ModeDir|0755); err != nil {
+		return err
+	}
+
+	for i, ng := range mdag.GetDAG(w.ctx, w.Dag, nd) {
+		child, err := ng.Get(w.ctx)
+		if err != nil {
+			return err
+		}
+
+		npath := path.Join(fpath, nd.Links()[i].Name)
+		if err := w.WriteNode(child, npath); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *Writer) writeFile(nd *mdag.ProtoNode, pb *upb.Data, fpath string) error {
+	fw, err := w.create(fpath, 0644)
+	if err != nil {
+		return err
+	}
+
+	dagr := uio.NewPBFileReader(w.ctx, nd, pb, w.Dag)
+	_, err = dagr.WriteTo(fw)
+	return err
+}
+
+// WriteNode writes nd and, if it is a directory, its children under fpath.
+func (w *Writer) WriteNode(nd node.Node, fpath string) error {
+	switch nd := nd.(type) {
+	case *mdag.ProtoNode:
+		pb := new(upb.Data)
+		if err := proto.Unmarshal(nd.Data(), pb); err != nil {
+			return err
+		}
+
+		switch pb.GetType() {
+		case upb.Data_Metadata:
+			fallthrough
+		case upb.Data_Directory:
+			return w.writeDir(nd, fpath)
+		case upb.Data_Raw:
+			fallthrough
+		case upb.Data_File:
+			return w.writeFile(nd, pb, fpath)
+		case upb.Data_Symlink:
+			// symlinks hold their target, as zip does
+			fw, err := w.create(fpath, os.ModeSymlink|0777)
+			if err != nil {
+				return err
+			}
+			_, err = fw.Write(pb.GetData())
+			return err
+		default:
+			return ft.ErrUnrecognizedType
+		}
+	case *mdag.RawNode:
+		fw, err := w.create(fpath, 0644)
+		if err != nil {
+			return err
+		}
+		_, err = fw.Write(nd.RawData())
+		return err
+	default:
+		return fmt.Errorf("nodes of type %T are not supported in unixfs", nd)
+	}
+}
+
+func (w *Writer) create(fpath string, mode os.FileMode) (io.Writer, error) {
+	h := &zip.FileHeader{
+		Name:   fpath,
+		Method: zip.Store,
+		// TODO: set mode, dates, etc. when added to unixFS
+	}
+	h.SetModTime(time.Now())
+	h.SetMode(mode)
+	return w.ZipW.CreateHeader(h)
+}
+
+// Close writes the central directory of the archive.
+func (w *Writer) Close() error {
+	return w.ZipW.Close()
+}